@@ -1,40 +1,32 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
 	"log"
-	"net/http"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/you/gnasty-chat/internal/core"
 	"github.com/you/gnasty-chat/internal/httpapi"
 	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/internal/version"
 )
 
-type emitReq struct {
-	ID            string           `json:"id,omitempty"`
-	Platform      string           `json:"platform"`
-	PlatformMsgID string           `json:"platform_msg_id,omitempty"`
-	Username      string           `json:"username"`
-	Text          string           `json:"text"`
-	Ts            time.Time        `json:"ts,omitempty"`
-	EmotesJSON    string           `json:"emotes_json,omitempty"`
-	RawJSON       string           `json:"raw_json,omitempty"`
-	BadgesJSON    string           `json:"badges_json,omitempty"`
-	Badges        []core.ChatBadge `json:"badges,omitempty"`
-	BadgesRaw     core.BadgesRaw   `json:"badges_raw,omitempty"`
-	Colour        string           `json:"colour,omitempty"`
-}
-
 func main() {
 	var (
-		addr   string
-		sqlite string
+		addr        string
+		sqlite      string
+		corsOrigins string
+		emitToken   string
 	)
 
 	flag.StringVar(&addr, "addr", ":8765", "HTTP listen address")
 	flag.StringVar(&sqlite, "db", "devapi.db", "SQLite database path")
+	flag.StringVar(&corsOrigins, "cors-origins", "*", "Comma-separated list of allowed CORS origins")
+	flag.StringVar(&emitToken, "emit-token", "dev", "Bearer token required by POST /emit")
 	flag.Parse()
 
 	s, err := sink.OpenSQLite(sqlite)
@@ -46,88 +38,37 @@ func main() {
 		log.Fatalf("ping: %v", err)
 	}
 
-	log.Printf("devapi listening on %s (db=%s)", addr, sqlite)
-
-	mux := http.NewServeMux()
-
-	mux.HandleFunc("POST /emit", func(w http.ResponseWriter, r *http.Request) {
-		defer r.Body.Close()
-		var req emitReq
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "bad json", http.StatusBadRequest)
-			return
-		}
-		if req.Platform == "" || req.Username == "" || req.Text == "" {
-			http.Error(w, "platform, username, text required", http.StatusBadRequest)
-			return
-		}
-		if req.Ts.IsZero() {
-			req.Ts = time.Now().UTC()
-		}
-		if req.ID == "" {
-			req.ID = req.Platform + "-" + req.Ts.Format("20060102T150405.000000000Z07:00")
-		}
-
-		if req.PlatformMsgID == "" {
-			req.PlatformMsgID = req.ID
-		}
-		msg := core.ChatMessage{
-			ID:            req.ID,
-			PlatformMsgID: req.PlatformMsgID,
-			Ts:            req.Ts,
-			Username:      req.Username,
-			Platform:      req.Platform,
-			Text:          req.Text,
-			EmotesJSON:    req.EmotesJSON,
-			RawJSON:       req.RawJSON,
-			BadgesJSON:    req.BadgesJSON,
-			Badges:        req.Badges,
-			BadgesRaw:     req.BadgesRaw,
-			Colour:        req.Colour,
-		}
-		if err := s.Write(msg, nil); err != nil {
-			http.Error(w, "insert failed: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{"ok": true, "id": msg.ID})
+	// Serve the production API so streaming, CORS, metrics and filters
+	// behave exactly as they do behind the harvester; rate limiting is
+	// left off for local tooling.
+	api := httpapi.New(s, httpapi.Options{
+		Addr:            addr,
+		CORSOrigins:     strings.Split(corsOrigins, ","),
+		EnableMetrics:   true,
+		EnableAccessLog: true,
+		EnableUI:        true,
+		EmitToken:       emitToken,
+		Build:           httpapi.BuildInfo{Version: version.Version, Revision: version.Commit},
 	})
-
-	mux.HandleFunc("GET /count", func(w http.ResponseWriter, r *http.Request) {
-		filters, err := httpapi.FiltersFromRequest(r)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		n, err := s.CountMessages(r.Context(), filters)
-		if err != nil {
-			http.Error(w, "count failed: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(map[string]any{"count": n})
+	// Emitted messages are stored and broadcast like the harvester's.
+	emitter := sink.NewTransformWriter(sink.WithAPI(s, api), sink.ULIDTransformer(), sink.ColourTransformer(true))
+	api.SetEmitter(func(ctx context.Context, msg core.ChatMessage) error {
+		return emitter.Write(msg, nil)
 	})
 
-	mux.HandleFunc("GET /messages", func(w http.ResponseWriter, r *http.Request) {
-		filters, err := httpapi.FiltersFromRequest(r)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		list, err := s.ListMessages(r.Context(), filters)
-		if err != nil {
-			http.Error(w, "list failed: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		w.Header().Set("Content-Type", "application/json")
-		_ = json.NewEncoder(w).Encode(list)
-	})
-
-	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusNoContent)
-	})
+	log.Printf("devapi listening on %s (db=%s)", addr, sqlite)
 
-	if err := http.ListenAndServe(addr, mux); err != nil {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := api.Shutdown(shutdownCtx); err != nil {
+			log.Printf("devapi: shutdown: %v", err)
+		}
+	}()
+	if err := api.Start(); err != nil {
 		log.Fatal(err)
 	}
 }
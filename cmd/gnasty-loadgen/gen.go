@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// genOptions shapes the synthetic chat.
+type genOptions struct {
+	Users int
+	// Skew is the Zipf exponent for picking authors (> 1); higher values
+	// concentrate traffic on fewer chatters, as in real channels.
+	Skew float64
+	// EmoteDensity is the probability that a message carries emotes.
+	EmoteDensity float64
+	// BadgeDensity is the probability that an author wears badges.
+	BadgeDensity float64
+	// TwitchShare is the fraction of messages attributed to Twitch; the
+	// rest are YouTube.
+	TwitchShare float64
+}
+
+var (
+	twitchEmotes  = []struct{ id, name string }{{"25", "Kappa"}, {"88", "PogChamp"}, {"354", "4Head"}, {"1902", "Keepo"}, {"425618", "LUL"}, {"305954156", "PogU"}}
+	youtubeEmotes = []string{":face-blue-smiling:", ":hand-pink-waving:", ":yt:", ":oops:", ":text-green-game-over:"}
+	words         = strings.Fields("gg wp nice clutch lol what no way hype lets go chat is this real insane play again that was close rip f in the chat first time here hello from brazil love the stream")
+	twitchBadges  = []string{"subscriber", "moderator", "vip", "founder", "turbo", "premium"}
+	youtubeBadges = []string{"member", "moderator", "verified"}
+	colours       = []string{"#FF0000", "#0000FF", "#008000", "#B22222", "#FF7F50", "#9ACD32", "#FF4500", "#2E8B57", "#DAA520", "#D2691E", "#5F9EA0", "#1E90FF", "#FF69B4", "#8A2BE2", "#00FF7F"}
+)
+
+type author struct {
+	name   string
+	colour string
+	badges []string
+}
+
+// generator produces messages from a fixed population of authors. It is
+// not safe for concurrent use.
+type generator struct {
+	opts    genOptions
+	rng     *rand.Rand
+	zipf    *rand.Zipf
+	authors []author
+	seq     int64
+}
+
+func newGenerator(opts genOptions, seed int64) *generator {
+	if opts.Users <= 0 {
+		opts.Users = 1
+	}
+	if opts.Skew <= 1 {
+		opts.Skew = 1.1
+	}
+	rng := rand.New(rand.NewSource(seed))
+	g := &generator{
+		opts: opts,
+		rng:  rng,
+		zipf: rand.NewZipf(rng, opts.Skew, 1, uint64(opts.Users-1)),
+	}
+	g.authors = make([]author, opts.Users)
+	for i := range g.authors {
+		a := author{
+			name:   fmt.Sprintf("viewer_%04d", i),
+			colour: colours[rng.Intn(len(colours))],
+		}
+		if rng.Float64() < opts.BadgeDensity {
+			a.badges = append(a.badges, twitchBadges[rng.Intn(len(twitchBadges))])
+			if rng.Intn(3) == 0 {
+				a.badges = append(a.badges, twitchBadges[rng.Intn(len(twitchBadges))])
+			}
+		}
+		g.authors[i] = a
+	}
+	return g
+}
+
+// next returns a message timestamped ts.
+func (g *generator) next(ts time.Time) core.ChatMessage {
+	g.seq++
+	a := g.authors[g.zipf.Uint64()]
+	platform := "YouTube"
+	if g.rng.Float64() < g.opts.TwitchShare {
+		platform = "Twitch"
+	}
+
+	n := 1 + g.rng.Intn(8)
+	parts := make([]string, 0, n+2)
+	for i := 0; i < n; i++ {
+		parts = append(parts, words[g.rng.Intn(len(words))])
+	}
+	msg := core.ChatMessage{
+		ID:       fmt.Sprintf("loadgen-%d-%d", ts.UnixNano(), g.seq),
+		Ts:       ts,
+		Platform: platform,
+		Username: a.name,
+	}
+	if platform == "Twitch" {
+		msg.Colour = a.colour
+	}
+
+	withEmotes := g.rng.Float64() < g.opts.EmoteDensity
+	switch {
+	case withEmotes && platform == "Twitch":
+		e := twitchEmotes[g.rng.Intn(len(twitchEmotes))]
+		count := 1 + g.rng.Intn(3)
+		var ranges []string
+		text := strings.Join(parts, " ")
+		for i := 0; i < count; i++ {
+			start := len([]rune(text)) + 1
+			text += " " + e.name
+			ranges = append(ranges, fmt.Sprintf("%d-%d", start, start+len(e.name)-1))
+		}
+		msg.Text = text
+		msg.EmotesJSON = mustJSON([]string{e.id + ":" + strings.Join(ranges, ",")})
+	case withEmotes:
+		name := youtubeEmotes[g.rng.Intn(len(youtubeEmotes))]
+		msg.Text = strings.Join(append(parts, name), " ")
+		msg.EmotesJSON = mustJSON([]map[string]string{{"name": name}})
+	default:
+		msg.Text = strings.Join(parts, " ")
+	}
+
+	for _, id := range a.badges {
+		if platform == "YouTube" {
+			id = youtubeBadges[len(id)%len(youtubeBadges)]
+		}
+		msg.Badges = append(msg.Badges, core.ChatBadge{Platform: strings.ToLower(platform), ID: id, Version: "1"})
+	}
+	if len(msg.Badges) > 0 {
+		msg.BadgesJSON = mustJSON(msg.Badges)
+	}
+	return msg
+}
+
+func mustJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
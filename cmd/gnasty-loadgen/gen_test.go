@@ -0,0 +1,88 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/moments"
+)
+
+func TestGeneratorShape(t *testing.T) {
+	opts := genOptions{Users: 200, Skew: 1.3, EmoteDensity: 0.5, BadgeDensity: 0.3, TwitchShare: 0.7}
+	g := newGenerator(opts, 42)
+	ts := time.Unix(1700000000, 0).UTC()
+
+	const n = 4000
+	var (
+		msgs       []core.ChatMessage
+		twitch     int
+		withEmotes int
+		authors    = map[string]int{}
+		ids        = map[string]bool{}
+	)
+	for i := 0; i < n; i++ {
+		msg := g.next(ts)
+		msgs = append(msgs, msg)
+		if msg.Platform == "Twitch" {
+			twitch++
+		}
+		if msg.EmotesJSON != "" {
+			withEmotes++
+		}
+		authors[msg.Username]++
+		if ids[msg.ID] {
+			t.Fatalf("duplicate id %s", msg.ID)
+		}
+		ids[msg.ID] = true
+	}
+
+	if share := float64(twitch) / n; share < 0.65 || share > 0.75 {
+		t.Fatalf("twitch share = %.2f, want ~0.7", share)
+	}
+	if density := float64(withEmotes) / n; density < 0.45 || density > 0.55 {
+		t.Fatalf("emote density = %.2f, want ~0.5", density)
+	}
+	if top := authors["viewer_0000"]; top < n/10 {
+		t.Fatalf("expected skewed authorship, top chatter sent %d of %d", top, n)
+	}
+
+	// Emote positions must line up with the text for downstream parsing.
+	emotes, _ := moments.TopTerms(msgs, 20)
+	known := map[string]bool{}
+	for _, e := range twitchEmotes {
+		known[e.name] = true
+	}
+	for _, name := range youtubeEmotes {
+		known[name] = true
+	}
+	if len(emotes) == 0 {
+		t.Fatalf("expected emotes to be recognised")
+	}
+	for _, term := range emotes {
+		if !known[term.Text] {
+			t.Fatalf("unexpected emote %q; positions are misaligned", term.Text)
+		}
+	}
+
+	again := newGenerator(opts, 42).next(ts)
+	if again.Text != msgs[0].Text || again.Username != msgs[0].Username {
+		t.Fatalf("generator is not deterministic for a seed")
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	if got := percentile(sorted, 50); got != 50*time.Millisecond {
+		t.Fatalf("p50 = %s", got)
+	}
+	if got := percentile(sorted, 99); got != 99*time.Millisecond {
+		t.Fatalf("p99 = %s", got)
+	}
+	if got := percentile(nil, 50); got != 0 {
+		t.Fatalf("empty p50 = %s", got)
+	}
+}
@@ -0,0 +1,217 @@
+// Command gnasty-loadgen emits synthetic chat at a fixed rate, either
+// straight into a SQLite sink or through a devapi /emit endpoint, and reports
+// write and delivery latency.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/pkg/client"
+)
+
+func main() {
+	var (
+		rate     float64
+		duration time.Duration
+		seed     int64
+		gen      genOptions
+		dbPath   string
+		batch    int
+		flush    time.Duration
+		emitURL  string
+		token    string
+		workers  int
+		watch    bool
+	)
+	flag.Float64Var(&rate, "rate", 50, "Messages per second")
+	flag.DurationVar(&duration, "duration", 30*time.Second, "How long to generate load")
+	flag.Int64Var(&seed, "seed", 0, "Random seed (0 uses the current time)")
+	flag.IntVar(&gen.Users, "users", 500, "Number of distinct chatters")
+	flag.Float64Var(&gen.Skew, "skew", 1.2, "Zipf exponent for chatter activity (> 1; higher is more concentrated)")
+	flag.Float64Var(&gen.EmoteDensity, "emote-density", 0.3, "Probability that a message contains emotes")
+	flag.Float64Var(&gen.BadgeDensity, "badge-density", 0.25, "Probability that a chatter wears badges")
+	flag.Float64Var(&gen.TwitchShare, "twitch-share", 0.7, "Fraction of messages from Twitch (rest YouTube)")
+	flag.StringVar(&dbPath, "sqlite", "", "Write directly into this SQLite database")
+	flag.IntVar(&batch, "batch", 1, "Buffered writer batch size for -sqlite")
+	flag.DurationVar(&flush, "flush", 0, "Buffered writer flush interval for -sqlite (0 flushes only on full batches)")
+	flag.StringVar(&emitURL, "emit", "", "Base URL of a devapi instance to POST /emit to")
+	flag.StringVar(&token, "emit-token", "dev", "Bearer token for /emit (devapi's -emit-token or the harvester's GNASTY_SQLITE_WRITE_TOKEN)")
+	flag.IntVar(&workers, "workers", 8, "Concurrent /emit requests")
+	flag.BoolVar(&watch, "watch", false, "With -emit, follow /ws and report end-to-end delivery latency")
+	flag.Parse()
+
+	if (dbPath == "") == (emitURL == "") {
+		log.Fatal("gnasty-loadgen: exactly one of -sqlite or -emit is required")
+	}
+	if rate <= 0 {
+		log.Fatal("gnasty-loadgen: -rate must be positive")
+	}
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var (
+		write    func(core.ChatMessage) error
+		closeFn  func() error
+		writeLat = &latencies{}
+		e2eLat   *latencies
+	)
+	if dbPath != "" {
+		db, err := sink.OpenSQLite(dbPath)
+		if err != nil {
+			log.Fatalf("gnasty-loadgen: open sqlite: %v", err)
+		}
+		defer db.Close()
+		buffered := sink.NewBufferedWriter(db, sink.BufferedOptions{BatchSize: batch, FlushInterval: flush})
+		write = func(msg core.ChatMessage) error { return buffered.Write(msg, nil) }
+		closeFn = buffered.Close
+		workers = 1
+	} else {
+		base := strings.TrimSuffix(emitURL, "/")
+		httpClient := &http.Client{Timeout: 10 * time.Second}
+		write = func(msg core.ChatMessage) error { return emit(ctx, httpClient, base, token, msg) }
+		if watch {
+			e2eLat = &latencies{}
+			go follow(ctx, base, e2eLat)
+			time.Sleep(500 * time.Millisecond) // let the stream connect
+		}
+	}
+
+	log.Printf("gnasty-loadgen: %.1f msg/s for %s (users=%d seed=%d)", rate, duration, gen.Users, seed)
+	sent, elapsed := run(ctx, newGenerator(gen, seed), rate, duration, workers, write, writeLat)
+	if closeFn != nil {
+		if err := closeFn(); err != nil {
+			log.Printf("gnasty-loadgen: flush: %v", err)
+		}
+	}
+	if e2eLat != nil {
+		// Give in-flight deliveries a moment to arrive.
+		time.Sleep(time.Second)
+	}
+
+	fmt.Printf("sent=%d elapsed=%s achieved=%.1f msg/s\n", sent, elapsed.Round(time.Millisecond), float64(sent)/elapsed.Seconds())
+	fmt.Println(writeLat.summary("write"))
+	if e2eLat != nil {
+		fmt.Println(e2eLat.summary("delivery"))
+	}
+}
+
+// run paces generation on a fixed schedule so slow writes show up as
+// latency and a lower achieved rate rather than silently stretching time.
+func run(ctx context.Context, g *generator, rate float64, duration time.Duration, workers int, write func(core.ChatMessage) error, lat *latencies) (int, time.Duration) {
+	if workers < 1 {
+		workers = 1
+	}
+	queue := make(chan core.ChatMessage, workers*2)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for msg := range queue {
+				start := time.Now()
+				if err := write(msg); err != nil {
+					lat.fail()
+					log.Printf("gnasty-loadgen: write: %v", err)
+					continue
+				}
+				lat.add(time.Since(start))
+			}
+		}()
+	}
+
+	interval := time.Duration(float64(time.Second) / rate)
+	start := time.Now()
+	sent := 0
+	for next := start; next.Sub(start) < duration; next = next.Add(interval) {
+		if wait := time.Until(next); wait > 0 {
+			select {
+			case <-ctx.Done():
+				close(queue)
+				wg.Wait()
+				return sent, time.Since(start)
+			case <-time.After(wait):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- g.next(time.Now().UTC())
+		sent++
+	}
+	close(queue)
+	wg.Wait()
+	return sent, time.Since(start)
+}
+
+// emit POSTs msg in the /emit request format.
+func emit(ctx context.Context, httpClient *http.Client, base, token string, msg core.ChatMessage) error {
+	body, err := json.Marshal(map[string]any{
+		"id":          msg.ID,
+		"platform":    msg.Platform,
+		"username":    msg.Username,
+		"text":        msg.Text,
+		"ts":          msg.Ts,
+		"emotes_json": msg.EmotesJSON,
+		"badges_json": msg.BadgesJSON,
+		"badges":      msg.Badges,
+		"colour":      msg.Colour,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+"/emit", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("emit: %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// follow records how long generated messages take to come back over /ws.
+func follow(ctx context.Context, base string, lat *latencies) {
+	c, err := client.New(base, client.Options{})
+	if err != nil {
+		log.Printf("gnasty-loadgen: watch: %v", err)
+		return
+	}
+	err = c.StreamWS(ctx, client.Query{}, func(msg client.Message) error {
+		if strings.HasPrefix(msg.ID, "loadgen-") {
+			lat.add(time.Since(msg.Ts))
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintf(os.Stderr, "gnasty-loadgen: watch: %v\n", err)
+	}
+}
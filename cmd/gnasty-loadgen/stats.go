@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencies collects samples for percentile reporting. It is safe for
+// concurrent use.
+type latencies struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	errors  int
+}
+
+func (l *latencies) add(d time.Duration) {
+	l.mu.Lock()
+	l.samples = append(l.samples, d)
+	l.mu.Unlock()
+}
+
+func (l *latencies) fail() {
+	l.mu.Lock()
+	l.errors++
+	l.mu.Unlock()
+}
+
+// percentile returns the p-th (0-100) percentile using nearest rank.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted)) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
+// summary formats count, errors and p50/p95/p99/max for label.
+func (l *latencies) summary(label string) string {
+	l.mu.Lock()
+	sorted := append([]time.Duration(nil), l.samples...)
+	errs := l.errors
+	l.mu.Unlock()
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	if len(sorted) == 0 {
+		return fmt.Sprintf("%-9s n=0 errors=%d", label, errs)
+	}
+	return fmt.Sprintf("%-9s n=%d errors=%d p50=%s p95=%s p99=%s max=%s",
+		label, len(sorted), errs,
+		percentile(sorted, 50), percentile(sorted, 95), percentile(sorted, 99), sorted[len(sorted)-1])
+}
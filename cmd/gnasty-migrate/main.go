@@ -0,0 +1,92 @@
+// Command gnasty-migrate copies archived messages from one sink to another,
+// for example from a single SQLite file into the per-channel layout, or into
+// a fresh database created with the current schema. Progress is saved to a
+// checkpoint file after every batch so an interrupted copy resumes where it
+// stopped, and messages already in the destination are updated in place
+// rather than duplicated.
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+func main() {
+	var (
+		from       string
+		to         string
+		checkpoint string
+		batch      int
+		every      time.Duration
+		restart    bool
+	)
+	flag.StringVar(&from, "from", "", "Source sink, e.g. sqlite:/data/chat.db ("+sinkKinds()+")")
+	flag.StringVar(&to, "to", "", "Destination sink, in the same form as -from")
+	flag.StringVar(&checkpoint, "checkpoint", "", "Checkpoint file (default: next to the destination)")
+	flag.IntVar(&batch, "batch", 500, "Messages copied per batch")
+	flag.DurationVar(&every, "progress", 5*time.Second, "How often to log progress")
+	flag.BoolVar(&restart, "restart", false, "Ignore an existing checkpoint and copy from the beginning")
+	flag.Parse()
+
+	if from == "" || to == "" {
+		log.Fatal("gnasty-migrate: -from and -to are required")
+	}
+	if batch <= 0 {
+		log.Fatal("gnasty-migrate: -batch must be positive")
+	}
+	src, err := parseSpec(from)
+	if err != nil {
+		log.Fatalf("gnasty-migrate: -from: %v", err)
+	}
+	dst, err := parseSpec(to)
+	if err != nil {
+		log.Fatalf("gnasty-migrate: -to: %v", err)
+	}
+	if src == dst {
+		log.Fatal("gnasty-migrate: -from and -to name the same sink")
+	}
+	if checkpoint == "" {
+		checkpoint = dst.path + ".migrate-checkpoint"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	source, err := openSource(src)
+	if err != nil {
+		log.Fatalf("gnasty-migrate: open source: %v", err)
+	}
+	defer source.Close()
+	dest, err := openDestination(dst)
+	if err != nil {
+		log.Fatalf("gnasty-migrate: open destination: %v", err)
+	}
+	defer dest.Close()
+
+	cp := newCheckpoint(from)
+	if !restart {
+		if cp, err = loadCheckpoint(checkpoint, from); err != nil {
+			log.Fatalf("gnasty-migrate: %v", err)
+		}
+		if cp.Copied > 0 {
+			log.Printf("gnasty-migrate: resuming from %s (%d messages already copied)", checkpoint, cp.Copied)
+		}
+	}
+
+	log.Printf("gnasty-migrate: copying %s to %s", from, to)
+	start := time.Now()
+	copied, err := migrate(ctx, source, dest, cp, migrateOptions{
+		Batch:    batch,
+		Progress: every,
+		Save:     func(cp *checkpointState) error { return cp.save(checkpoint) },
+	})
+	elapsed := time.Since(start)
+	if err != nil {
+		log.Fatalf("gnasty-migrate: stopped after %d messages: %v (rerun to resume)", copied, err)
+	}
+	log.Printf("gnasty-migrate: done: copied %d messages in %s (%d in total)", copied, elapsed.Round(time.Millisecond), cp.Copied)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// checkpointState records how far a copy got: the last source row id copied
+// from each partition.
+type checkpointState struct {
+	Source  string           `json:"source"`
+	After   map[string]int64 `json:"after"`
+	Copied  int64            `json:"copied"`
+	Updated time.Time        `json:"updated"`
+}
+
+func newCheckpoint(source string) *checkpointState {
+	return &checkpointState{Source: source, After: map[string]int64{}}
+}
+
+// loadCheckpoint reads the checkpoint at path, returning a fresh one when
+// the file does not exist. A checkpoint left by a copy from another source
+// is refused rather than silently skipping rows.
+func loadCheckpoint(path, source string) (*checkpointState, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return newCheckpoint(source), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+	cp := newCheckpoint(source)
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("parse checkpoint %s: %w", path, err)
+	}
+	if cp.Source != source {
+		return nil, fmt.Errorf("checkpoint %s belongs to a copy from %s; pass -restart or another -checkpoint", path, cp.Source)
+	}
+	if cp.After == nil {
+		cp.After = map[string]int64{}
+	}
+	return cp, nil
+}
+
+// save replaces the checkpoint file atomically so a crash mid-write leaves
+// the previous checkpoint intact.
+func (cp *checkpointState) save(path string) error {
+	cp.Updated = time.Now().UTC()
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write checkpoint: %w", err)
+	}
+	return nil
+}
+
+type migrateOptions struct {
+	Batch int
+	// Progress is how often to log progress; zero disables it.
+	Progress time.Duration
+	// Save persists the checkpoint after each stored batch.
+	Save func(*checkpointState) error
+}
+
+// migrate copies every message in src's partitions after the checkpointed
+// row ids into dst, advancing cp as batches are stored, and returns how many
+// messages it copied. Destinations upsert on platform and message id, so a
+// batch repeated after a crash updates rows instead of duplicating them.
+func migrate(ctx context.Context, src source, dst destination, cp *checkpointState, opts migrateOptions) (int64, error) {
+	del, _ := dst.(deleter)
+	var (
+		copied   int64
+		lastLog  = time.Now()
+		lastRate int64
+	)
+	for _, part := range src.Partitions() {
+		for {
+			if err := ctx.Err(); err != nil {
+				return copied, err
+			}
+			msgs, rowIDs, err := part.db.MessagesAfter(ctx, cp.After[part.name], opts.Batch)
+			if err != nil {
+				return copied, fmt.Errorf("read %s: %w", part.name, err)
+			}
+			if len(msgs) == 0 {
+				break
+			}
+			for i := range msgs {
+				// Without a platform id the reader fills ID with the source
+				// row id; clear it so the destination dedupes on content.
+				if msgs[i].PlatformMsgID == "" {
+					msgs[i].ID = ""
+				}
+			}
+			if err := dst.WriteBatch(msgs, nil); err != nil {
+				return copied, fmt.Errorf("write batch: %w", err)
+			}
+			if del != nil {
+				if err := copyDeletions(ctx, del, msgs); err != nil {
+					return copied, err
+				}
+			}
+			cp.After[part.name] = rowIDs[len(rowIDs)-1]
+			cp.Copied += int64(len(msgs))
+			copied += int64(len(msgs))
+			if opts.Save != nil {
+				if err := opts.Save(cp); err != nil {
+					return copied, err
+				}
+			}
+			if opts.Progress > 0 && time.Since(lastLog) >= opts.Progress {
+				rate := float64(copied-lastRate) / time.Since(lastLog).Seconds()
+				log.Printf("gnasty-migrate: %s: copied %d messages (row %d, %.0f msg/s)", part.name, copied, cp.After[part.name], rate)
+				lastLog, lastRate = time.Now(), copied
+			}
+			if len(msgs) < opts.Batch {
+				break
+			}
+		}
+	}
+	return copied, nil
+}
+
+// copyDeletions re-applies the soft deletions of msgs, which a plain write
+// does not carry.
+func copyDeletions(ctx context.Context, del deleter, msgs []core.ChatMessage) error {
+	for _, msg := range msgs {
+		if msg.DeletedAt == nil || msg.PlatformMsgID == "" {
+			continue
+		}
+		if _, err := del.DeleteMessages(ctx, core.MessageDeletion{
+			Platform:  msg.Platform,
+			Channel:   msg.Channel,
+			ID:        msg.PlatformMsgID,
+			DeletedAt: *msg.DeletedAt,
+			DeletedBy: msg.DeletedBy,
+		}); err != nil {
+			return fmt.Errorf("copy deletion of %s: %w", msg.PlatformMsgID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+	"github.com/you/gnasty-chat/internal/sink"
+)
+
+func TestMigrateResumesWithoutDuplicates(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	srcPath := filepath.Join(dir, "old.db")
+
+	db, err := sink.OpenSQLite(srcPath)
+	if err != nil {
+		t.Fatalf("open source: %v", err)
+	}
+	base := time.Unix(1700000000, 0).UTC()
+	var msgs []core.ChatMessage
+	for i := 0; i < 7; i++ {
+		msgs = append(msgs, core.ChatMessage{ID: fmt.Sprintf("m%d", i), Platform: "Twitch", Channel: "elora",
+			Username: "viewer", Text: fmt.Sprintf("hello %d", i), Ts: base.Add(time.Duration(i) * time.Second)})
+	}
+	// A YouTube message without a platform id, deduped on content.
+	msgs = append(msgs, core.ChatMessage{Platform: "YouTube", Username: "fan", Text: "no id", Ts: base})
+	if err := db.WriteBatch(msgs, nil); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	if _, err := db.DeleteMessages(ctx, core.MessageDeletion{Platform: "Twitch", Channel: "elora", ID: "m3", DeletedAt: base.Add(time.Minute), DeletedBy: "mod"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	_ = db.Close()
+
+	src, err := openSource(sinkSpec{kind: "sqlite", path: srcPath})
+	if err != nil {
+		t.Fatalf("open source: %v", err)
+	}
+	defer src.Close()
+	dst, err := openDestination(sinkSpec{kind: "sqlite", path: filepath.Join(dir, "new.db")})
+	if err != nil {
+		t.Fatalf("open destination: %v", err)
+	}
+	defer dst.Close()
+
+	// Stop after the second batch, as if the process had been killed.
+	cpPath := filepath.Join(dir, "checkpoint")
+	errStop := errors.New("stop")
+	batches := 0
+	cp := newCheckpoint("sqlite:" + srcPath)
+	copied, err := migrate(ctx, src, dst, cp, migrateOptions{Batch: 3, Save: func(cp *checkpointState) error {
+		if err := cp.save(cpPath); err != nil {
+			return err
+		}
+		if batches++; batches == 2 {
+			return errStop
+		}
+		return nil
+	}})
+	if !errors.Is(err, errStop) || copied != 6 {
+		t.Fatalf("first run: copied=%d err=%v", copied, err)
+	}
+
+	if _, err := loadCheckpoint(cpPath, "sqlite:/elsewhere.db"); err == nil {
+		t.Fatal("expected a checkpoint from another source to be refused")
+	}
+	cp, err = loadCheckpoint(cpPath, "sqlite:"+srcPath)
+	if err != nil || cp.Copied != 6 {
+		t.Fatalf("load checkpoint: %+v %v", cp, err)
+	}
+	// Rewind one batch to replay rows the destination already holds.
+	cp.After["messages"] = 3
+	copied, err = migrate(ctx, src, dst, cp, migrateOptions{Batch: 3})
+	if err != nil || copied != 5 {
+		t.Fatalf("resume: copied=%d err=%v", copied, err)
+	}
+	copied, err = migrate(ctx, src, dst, cp, migrateOptions{Batch: 3})
+	if err != nil || copied != 0 {
+		t.Fatalf("rerun after completion: copied=%d err=%v", copied, err)
+	}
+
+	out := dst.(*sink.SQLiteSink)
+	n, err := out.CountMessages(ctx, httpapi.Filters{IncludeDeleted: true})
+	if err != nil || n != int64(len(msgs)) {
+		t.Fatalf("expected %d messages in destination, got %d (%v)", len(msgs), n, err)
+	}
+	got, err := out.ListMessages(ctx, httpapi.Filters{Platforms: []string{"Twitch"}, Limit: 10, IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	deleted := 0
+	for _, msg := range got {
+		if msg.DeletedAt != nil {
+			deleted++
+			if msg.ID != "m3" || msg.DeletedBy != "mod" {
+				t.Fatalf("unexpected deletion carried over: %+v", msg)
+			}
+		}
+	}
+	if deleted != 1 {
+		t.Fatalf("expected the deletion to be carried over, got %d deleted", deleted)
+	}
+}
+
+func TestMigrateIntoChannelLayout(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	db, err := sink.OpenSQLite(filepath.Join(dir, "old.db"))
+	if err != nil {
+		t.Fatalf("open source: %v", err)
+	}
+	ts := time.Unix(1700000000, 0).UTC()
+	if err := db.WriteBatch([]core.ChatMessage{
+		{ID: "a", Platform: "Twitch", Channel: "elora", Username: "x", Text: "hi", Ts: ts},
+		{ID: "b", Platform: "Twitch", Channel: "ranboo", Username: "y", Text: "yo", Ts: ts},
+	}, nil); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	_ = db.Close()
+
+	src, err := openSource(sinkSpec{kind: "sqlite", path: filepath.Join(dir, "old.db")})
+	if err != nil {
+		t.Fatalf("open source: %v", err)
+	}
+	defer src.Close()
+	dst, err := openDestination(sinkSpec{kind: "sqlite-channels", path: filepath.Join(dir, "channels")})
+	if err != nil {
+		t.Fatalf("open destination: %v", err)
+	}
+	if _, err := migrate(ctx, src, dst, newCheckpoint("old"), migrateOptions{Batch: 10}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	_ = dst.Close()
+
+	back, err := openSource(sinkSpec{kind: "sqlite-channels", path: filepath.Join(dir, "channels")})
+	if err != nil {
+		t.Fatalf("reopen channels: %v", err)
+	}
+	defer back.Close()
+	if parts := back.Partitions(); len(parts) != 2 {
+		t.Fatalf("expected one shard per channel, got %+v", parts)
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	if spec, err := parseSpec("sqlite:/data/chat.db"); err != nil || spec.kind != "sqlite" || spec.path != "/data/chat.db" {
+		t.Fatalf("unexpected %+v %v", spec, err)
+	}
+	for _, bad := range []string{"/data/chat.db", "postgres://db/chat", "sqlite:"} {
+		if _, err := parseSpec(bad); err == nil {
+			t.Fatalf("%q: expected an error", bad)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/sink"
+)
+
+// sinkSpec names a sink as kind:path, e.g. sqlite:/data/chat.db.
+type sinkSpec struct {
+	kind string
+	path string
+}
+
+// partition is one database a source reads in row order. Single-file sinks
+// have one partition; the per-channel layout has one per shard.
+type partition struct {
+	// name keys the partition's position in the checkpoint.
+	name string
+	db   *sink.SQLiteSink
+}
+
+// source is a sink messages can be read back from.
+type source interface {
+	Partitions() []partition
+	Close() error
+}
+
+// destination is a sink messages are copied into. Writes must be
+// idempotent so a resumed batch does not duplicate messages.
+type destination interface {
+	sink.BatchWriter
+	Close() error
+}
+
+// deleter is implemented by destinations that can carry over deletions.
+type deleter interface {
+	DeleteMessages(ctx context.Context, del core.MessageDeletion) (int64, error)
+}
+
+// sinkKind opens one registered kind of sink as a source, destination, or
+// both.
+type sinkKind struct {
+	openSource      func(path string) (source, error)
+	openDestination func(path string) (destination, error)
+}
+
+// sinkKinds lists the registered kinds for help text.
+func sinkKinds() string {
+	names := make([]string, 0, len(kinds))
+	for name := range kinds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+var kinds = map[string]sinkKind{
+	"sqlite": {
+		openSource: func(path string) (source, error) {
+			db, err := sink.OpenSQLite(path)
+			if err != nil {
+				return nil, err
+			}
+			return singleSource{db}, nil
+		},
+		openDestination: func(path string) (destination, error) {
+			return sink.OpenSQLite(path)
+		},
+	},
+	"sqlite-channels": {
+		openSource: openChannelSource,
+		openDestination: func(path string) (destination, error) {
+			return sink.OpenChannelSQLite(path)
+		},
+	},
+}
+
+func parseSpec(s string) (sinkSpec, error) {
+	kind, path, ok := strings.Cut(s, ":")
+	if !ok || path == "" {
+		return sinkSpec{}, fmt.Errorf("%q is not kind:path (kinds: %s)", s, sinkKinds())
+	}
+	if _, ok := kinds[kind]; !ok {
+		return sinkSpec{}, fmt.Errorf("unknown sink %q (kinds: %s)", kind, sinkKinds())
+	}
+	return sinkSpec{kind: kind, path: filepath.Clean(path)}, nil
+}
+
+func openSource(spec sinkSpec) (source, error) {
+	return kinds[spec.kind].openSource(spec.path)
+}
+
+func openDestination(spec sinkSpec) (destination, error) {
+	return kinds[spec.kind].openDestination(spec.path)
+}
+
+type singleSource struct{ db *sink.SQLiteSink }
+
+func (s singleSource) Partitions() []partition { return []partition{{name: "messages", db: s.db}} }
+func (s singleSource) Close() error            { return s.db.Close() }
+
+// channelSource reads each shard of a per-channel directory on its own, so
+// shards are copied one after another.
+type channelSource struct {
+	catalog *sink.ChannelSQLite
+	parts   []partition
+}
+
+func openChannelSource(dir string) (source, error) {
+	catalog, err := sink.OpenChannelSQLite(dir)
+	if err != nil {
+		return nil, err
+	}
+	src := &channelSource{catalog: catalog}
+	for _, shard := range catalog.Shards() {
+		db, err := sink.OpenSQLite(filepath.Join(dir, shard.Path))
+		if err != nil {
+			_ = src.Close()
+			return nil, fmt.Errorf("open shard %s: %w", shard.Path, err)
+		}
+		src.parts = append(src.parts, partition{name: filepath.ToSlash(shard.Path), db: db})
+	}
+	return src, nil
+}
+
+func (c *channelSource) Partitions() []partition { return c.parts }
+
+func (c *channelSource) Close() error {
+	for _, p := range c.parts {
+		_ = p.db.Close()
+	}
+	return c.catalog.Close()
+}
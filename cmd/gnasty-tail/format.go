@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/pkg/client"
+)
+
+// badgeAbbrev maps well-known badge IDs (Twitch and YouTube) to the short
+// markers printed before the username. Unknown badges are omitted.
+var badgeAbbrev = map[string]string{
+	"broadcaster": "B",
+	"owner":       "B",
+	"moderator":   "M",
+	"vip":         "V",
+	"staff":       "S",
+	"admin":       "A",
+	"partner":     "P",
+	"verified":    "P",
+	"founder":     "F",
+	"subscriber":  "s",
+	"member":      "s",
+	"turbo":       "t",
+	"prime":       "t",
+}
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiDim   = "\x1b[2m"
+	ansiBold  = "\x1b[1m"
+)
+
+// platformStyle is the tag and colour printed for each platform.
+var platformStyle = map[string]struct{ tag, colour string }{
+	"Twitch":  {"TW", "\x1b[35m"},
+	"YouTube": {"YT", "\x1b[31m"},
+}
+
+type formatter struct {
+	colour bool
+	loc    *time.Location
+}
+
+// badges returns the abbreviations for msg's badges, deduplicated and in
+// the order they were reported.
+func badges(msg client.Message) string {
+	var b strings.Builder
+	seen := make(map[string]bool)
+	for _, badge := range msg.Badges {
+		abbrev, ok := badgeAbbrev[strings.ToLower(badge.ID)]
+		if !ok || seen[abbrev] {
+			continue
+		}
+		seen[abbrev] = true
+		b.WriteString(abbrev)
+	}
+	return b.String()
+}
+
+// hexColour parses "#RRGGBB" into a 24-bit ANSI foreground sequence.
+func hexColour(raw string) (string, bool) {
+	raw = strings.TrimPrefix(strings.TrimSpace(raw), "#")
+	if len(raw) != 6 {
+		return "", false
+	}
+	v, err := strconv.ParseUint(raw, 16, 32)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("\x1b[38;2;%d;%d;%dm", v>>16&0xff, v>>8&0xff, v&0xff), true
+}
+
+// format renders one message as a single terminal line.
+func (f formatter) format(msg client.Message) string {
+	var b strings.Builder
+	paint := func(code, s string) {
+		if f.colour && code != "" {
+			b.WriteString(code)
+			b.WriteString(s)
+			b.WriteString(ansiReset)
+			return
+		}
+		b.WriteString(s)
+	}
+
+	ts := msg.Ts
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	paint(ansiDim, ts.In(f.loc).Format("15:04:05"))
+	b.WriteByte(' ')
+
+	style, ok := platformStyle[msg.Platform]
+	if !ok {
+		style.tag = strings.ToUpper(msg.Platform)
+		if len(style.tag) > 2 {
+			style.tag = style.tag[:2]
+		}
+	}
+	paint(style.colour, "["+style.tag+"]")
+	b.WriteByte(' ')
+
+	if abbrev := badges(msg); abbrev != "" {
+		paint(ansiBold, abbrev)
+		b.WriteByte(' ')
+	}
+
+	name, _ := hexColour(msg.Colour)
+	if name == "" {
+		name = ansiBold
+	}
+	paint(name, sanitize(msg.Username))
+	b.WriteString(": ")
+	b.WriteString(sanitize(msg.Text))
+	return b.String()
+}
+
+// sanitize keeps chat content from breaking lines or injecting terminal
+// escape sequences.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\r' || r == '\t':
+			return ' '
+		case r < 0x20 || (r >= 0x7f && r < 0xa0):
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/pkg/client"
+)
+
+func TestFormatPlain(t *testing.T) {
+	msg := client.Message{
+		Platform: "Twitch",
+		Username: "Mod\x1b[2JGuy",
+		Text:     "hello\nworld\u009b31m",
+		Ts:       time.Date(2024, 5, 1, 12, 34, 56, 0, time.UTC),
+		Colour:   "#FF8800",
+		Badges: []core.ChatBadge{
+			{ID: "moderator"},
+			{ID: "subscriber", Version: "12"},
+			{ID: "glhf-pledge"},
+			{ID: "founder"},
+		},
+	}
+	got := formatter{loc: time.UTC}.format(msg)
+	want := "12:34:56 [TW] MsF Mod[2JGuy: hello world31m"
+	if got != want {
+		t.Fatalf("format = %q, want %q", got, want)
+	}
+}
+
+func TestFormatColour(t *testing.T) {
+	msg := client.Message{Platform: "YouTube", Username: "viewer", Text: "hi", Colour: "#0a0b0c", Ts: time.Unix(0, 0)}
+	got := formatter{colour: true, loc: time.UTC}.format(msg)
+	want := ansiDim + "00:00:00" + ansiReset + " \x1b[31m[YT]" + ansiReset + " \x1b[38;2;10;11;12mviewer" + ansiReset + ": hi"
+	if got != want {
+		t.Fatalf("format = %q, want %q", got, want)
+	}
+
+	if _, ok := hexColour("blue"); ok {
+		t.Fatalf("expected named colour to be rejected")
+	}
+}
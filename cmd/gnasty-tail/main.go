@@ -0,0 +1,135 @@
+// Command gnasty-tail follows a harvester's live chat stream and prints it to
+// the terminal, for headless monitoring over SSH.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/you/gnasty-chat/pkg/client"
+)
+
+func main() {
+	var (
+		apiURL    string
+		transport string
+		platforms string
+		usernames string
+		grep      string
+		colour    string
+		history   int
+		utc       bool
+	)
+	flag.StringVar(&apiURL, "url", "http://localhost:8765", "Base URL of the harvester HTTP API")
+	flag.StringVar(&transport, "transport", "ws", "Stream transport: ws or sse")
+	flag.StringVar(&platforms, "platform", "", "Comma-separated platforms to follow (twitch, youtube)")
+	flag.StringVar(&usernames, "username", "", "Comma-separated username substrings to follow")
+	flag.StringVar(&grep, "grep", "", "Only print messages whose text matches this regular expression (case-insensitive)")
+	flag.StringVar(&colour, "color", "auto", "Colour output: auto, always or never")
+	flag.IntVar(&history, "history", 0, "Print this many stored messages before following (max 1000)")
+	flag.BoolVar(&utc, "utc", false, "Print timestamps in UTC instead of local time")
+	flag.Parse()
+
+	if err := run(apiURL, transport, platforms, usernames, grep, colour, history, utc); err != nil {
+		fmt.Fprintf(os.Stderr, "gnasty-tail: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(apiURL, transport, platforms, usernames, grep, colour string, history int, utc bool) error {
+	var match *regexp.Regexp
+	if grep != "" {
+		re, err := regexp.Compile("(?i)" + grep)
+		if err != nil {
+			return fmt.Errorf("invalid -grep: %w", err)
+		}
+		match = re
+	}
+
+	f := formatter{loc: time.Local}
+	if utc {
+		f.loc = time.UTC
+	}
+	switch colour {
+	case "always":
+		f.colour = true
+	case "never":
+	case "auto":
+		f.colour = isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	default:
+		return fmt.Errorf("invalid -color %q (want auto, always or never)", colour)
+	}
+
+	c, err := client.New(apiURL, client.Options{})
+	if err != nil {
+		return err
+	}
+	var stream func(context.Context, client.Query, client.Handler) error
+	switch transport {
+	case "ws":
+		stream = c.StreamWS
+	case "sse":
+		stream = c.StreamSSE
+	default:
+		return fmt.Errorf("invalid -transport %q (want ws or sse)", transport)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	q := client.Query{Platforms: splitList(platforms), Usernames: splitList(usernames)}
+	show := func(msg client.Message) error {
+		if match != nil && !match.MatchString(msg.Text) {
+			return nil
+		}
+		_, err := fmt.Fprintln(os.Stdout, f.format(msg))
+		return err
+	}
+
+	if history > 0 {
+		hq := q
+		hq.Limit = history
+		rows, err := c.Query(ctx, hq)
+		if err != nil {
+			return err
+		}
+		// Rows arrive newest first; print oldest first and resume the
+		// stream after the newest so nothing is shown twice.
+		for i := len(rows) - 1; i >= 0; i-- {
+			if err := show(rows[i]); err != nil {
+				return err
+			}
+		}
+		if len(rows) > 0 {
+			q.LastEventID = rows[0].EventID()
+		}
+	}
+
+	if err := stream(ctx, q, show); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+	return nil
+}
+
+func splitList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
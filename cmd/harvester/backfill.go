@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/backfill"
+	"github.com/you/gnasty-chat/internal/config"
+	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/internal/twitchvod"
+	"github.com/you/gnasty-chat/internal/ytlive"
+)
+
+// newBackfiller returns the catch-up backfiller for the configured
+// receivers, or nil when backfill is off or no platform can replay chat.
+// Twitch VODs are listed through Helix, so Twitch needs the client
+// credentials. Missing messages are written through writer, the ingest
+// chain, so they are transformed and reach every sink.
+func newBackfiller(cfg config.Config, db *sink.SQLiteSink, writer sink.Writer, out *outbound, twClientID, twClientSecret string) *backfill.Backfiller {
+	if !cfg.Backfill.Enabled || db == nil {
+		return nil
+	}
+	sources := map[string]backfill.Source{}
+	if len(cfg.Twitch.Channels) > 0 {
+		if strings.TrimSpace(twClientID) != "" && strings.TrimSpace(twClientSecret) != "" {
+			resolver := out.twitchResolver(twClientID, twClientSecret)
+			sources["Twitch"] = twitchvod.New(resolver, resolver, out.twitchHTTP)
+		} else {
+			log.Printf("harvester: twitch backfill needs client id/secret; skipping")
+		}
+	}
+	if strings.TrimSpace(cfg.YouTube.LiveURL) != "" {
+		sources["YouTube"] = ytlive.NewReplay(&http.Client{Transport: out.youtube, Jar: out.youtubeJar, Timeout: 20 * time.Second})
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+	return backfill.New(backfill.Config{
+		Sources: sources,
+		Store:   db,
+		Writer:  writer,
+		Delay:   cfg.BackfillDelay(),
+		MaxGap:  cfg.BackfillMaxGap(),
+	})
+}
+
+type lastMessageStore interface {
+	LastMessageTime(ctx context.Context, platform, channel string) (time.Time, bool, error)
+}
+
+// scheduleRestartGaps queues, for each Twitch channel, the window since its
+// newest stored message, covering chat missed while the harvester was
+// down. YouTube resumes from its checkpoint instead.
+func scheduleRestartGaps(ctx context.Context, db lastMessageStore, channels []string, now time.Time, schedule func(backfill.Gap)) {
+	for _, channel := range channels {
+		login := twitchLogin(channel)
+		if login == "" {
+			continue
+		}
+		last, found, err := db.LastMessageTime(ctx, "Twitch", login)
+		if err != nil {
+			log.Printf("backfill: last message in #%s: %v", login, err)
+			continue
+		}
+		if found && last.Before(now) {
+			schedule(backfill.Gap{Platform: "Twitch", Channel: login, Start: last, End: now})
+		}
+	}
+}
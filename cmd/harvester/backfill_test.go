@@ -0,0 +1,30 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/backfill"
+)
+
+type fakeLastMessages map[string]time.Time
+
+func (f fakeLastMessages) LastMessageTime(_ context.Context, platform, channel string) (time.Time, bool, error) {
+	ts, ok := f[platform+"/"+channel]
+	return ts, ok, nil
+}
+
+func TestScheduleRestartGaps(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	store := fakeLastMessages{"Twitch/elora": now.Add(-time.Hour)}
+
+	var gaps []backfill.Gap
+	scheduleRestartGaps(context.Background(), store, []string{"#Elora", "quiet", " "}, now, func(g backfill.Gap) {
+		gaps = append(gaps, g)
+	})
+	want := backfill.Gap{Platform: "Twitch", Channel: "elora", Start: now.Add(-time.Hour), End: now}
+	if len(gaps) != 1 || gaps[0] != want {
+		t.Fatalf("gaps = %+v, want only %+v", gaps, want)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/you/gnasty-chat/internal/config"
+	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/internal/triggers"
+	"github.com/you/gnasty-chat/internal/ytlive"
+)
+
+// checkConfig validates the effective configuration, prints the redacted
+// snapshot to stdout, reports problems to stderr, and returns the process
+// exit code (0 when the configuration is usable).
+func checkConfig(cfg config.Config, stdout, stderr io.Writer) int {
+	snapshot, _ := json.MarshalIndent(cfg.Redacted(), "", "  ")
+	fmt.Fprintf(stdout, "%s\n", snapshot)
+
+	problems := configProblems(cfg)
+	for _, err := range problems {
+		fmt.Fprintf(stderr, "config error: %v\n", err)
+	}
+	if len(problems) > 0 {
+		fmt.Fprintf(stderr, "config check failed (%d problem(s))\n", len(problems))
+		return 1
+	}
+	fmt.Fprintln(stderr, "config check ok")
+	return 0
+}
+
+func configProblems(cfg config.Config) []error {
+	var problems []error
+	if err := cfg.Validate(); err != nil {
+		problems = append(problems, unjoin(err)...)
+	}
+
+	if cfg.YouTube.Enabled && strings.TrimSpace(cfg.YouTube.LiveURL) != "" {
+		if err := ytlive.ValidateURL(cfg.YouTube.LiveURL); err != nil {
+			problems = append(problems, fmt.Errorf("youtube url %q: %w", cfg.YouTube.LiveURL, err))
+		}
+	}
+
+	if path := cfg.Replay.File; path != "" {
+		if _, err := os.Stat(path); err != nil {
+			problems = append(problems, fmt.Errorf("replay file: %w", err))
+		}
+	}
+
+	if path := strings.TrimSpace(cfg.Twitch.RefreshTokenFile); path != "" {
+		if _, err := os.ReadFile(path); err != nil {
+			problems = append(problems, fmt.Errorf("twitch refresh token file: %w", err))
+		}
+	}
+
+	if path := strings.TrimSpace(cfg.Triggers.File); path != "" {
+		if _, err := triggers.Load(path); err != nil {
+			problems = append(problems, fmt.Errorf("triggers file: %w", err))
+		}
+	}
+
+	if fields := strings.Fields(cfg.Plugin.Command); len(fields) > 0 {
+		if _, err := exec.LookPath(fields[0]); err != nil {
+			problems = append(problems, fmt.Errorf("exec plugin: %w", err))
+		}
+	}
+
+	if cfg.HasSink("sqlite") && strings.TrimSpace(cfg.Sink.SQLite.Path) != "" {
+		if err := sink.CheckSQLite(cfg.Sink.SQLite.Path); err != nil {
+			problems = append(problems, fmt.Errorf("sqlite sink %s unreachable: %w", cfg.Sink.SQLite.Path, err))
+		}
+	}
+	return problems
+}
+
+func unjoin(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
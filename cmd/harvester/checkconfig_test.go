@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/you/gnasty-chat/internal/config"
+)
+
+func TestCheckConfig(t *testing.T) {
+	cfg := config.Config{
+		Sinks:   []string{"sqlite"},
+		Sink:    config.SinkConfig{SQLite: config.SQLiteConfig{Path: filepath.Join(t.TempDir(), "chat.db")}},
+		YouTube: config.YouTubeConfig{Enabled: true, LiveURL: "https://youtube.com/@creator/live"},
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := checkConfig(cfg, &stdout, &stderr); code != 0 {
+		t.Fatalf("expected exit 0, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), `"sqlite_path"`) {
+		t.Fatalf("expected redacted config on stdout, got %q", stdout.String())
+	}
+
+	cfg.YouTube.LiveURL = "https://example.test/watch"
+	cfg.Sink.SQLite.Path = filepath.Join(t.TempDir(), "missing", "chat.db")
+	stdout.Reset()
+	stderr.Reset()
+	if code := checkConfig(cfg, &stdout, &stderr); code != 1 {
+		t.Fatalf("expected exit 1, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), "youtube url") || !strings.Contains(stderr.String(), "unreachable") {
+		t.Fatalf("expected youtube and sqlite problems, got %q", stderr.String())
+	}
+}
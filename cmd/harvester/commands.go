@@ -0,0 +1,441 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/config"
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/internal/twitch"
+	"github.com/you/gnasty-chat/internal/twitchauth"
+)
+
+// command is a harvester subcommand. run receives the arguments after the
+// command name and returns the process exit code.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+func commands() []command {
+	return []command{
+		{"run", "Run the receivers, sinks and servers (the default)", func(args []string) int {
+			runHarvester(context.Background(), args)
+			return 0
+		}},
+		{"migrate", "Apply pending SQLite schema migrations", migrateCommand},
+		{"export", "Write stored messages as NDJSON or CSV", exportCommand},
+		{"verify-export", "Check an export manifest's checksums and signature", verifyExportCommand},
+		{"import", "Load NDJSON messages into the SQLite archive", importCommand},
+		{"check-config", "Validate the configuration and print the effective settings", func(args []string) int {
+			runHarvester(context.Background(), append([]string{"-check-config"}, args...))
+			return 0
+		}},
+		{"login", "Refresh and validate the Twitch token and print the login", loginCommand},
+		{"backfill", "Recompute derived columns over stored messages", backfillCommand},
+		{"service", "Install, remove, start or stop the Windows service", serviceCommand},
+		{"version", "Print build version", func(args []string) int {
+			runHarvester(context.Background(), append([]string{"-version"}, args...))
+			return 0
+		}},
+	}
+}
+
+func main() {
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	os.Exit(dispatch(os.Args[1:], os.Stderr))
+}
+
+// dispatch runs the command named by args[0]. Without a command, or when the
+// first argument is a flag, the arguments go to run so existing invocations
+// keep working.
+func dispatch(args []string, stderr io.Writer) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		runHarvester(context.Background(), args)
+		return 0
+	}
+	name := args[0]
+	if name == "help" {
+		usage(stderr)
+		return 0
+	}
+	for _, cmd := range commands() {
+		if cmd.name == name {
+			return cmd.run(args[1:])
+		}
+	}
+	fmt.Fprintf(stderr, "harvester: unknown command %q\n\n", name)
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: harvester [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "commands:")
+	for _, cmd := range commands() {
+		fmt.Fprintf(w, "  %-13s %s\n", cmd.name, cmd.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, `Run "harvester <command> -h" for a command's flags.`)
+}
+
+// newCommandFlags returns a flag set for name with the shared -sqlite flag,
+// which defaults to the configured archive path.
+func newCommandFlags(name string, cfg config.Config) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	dbPath := fs.String("sqlite", cfg.Sink.SQLite.Path, "Path to SQLite database file")
+	return fs, dbPath
+}
+
+// openArchive opens and migrates the SQLite archive at path.
+func openArchive(ctx context.Context, path string) (*sink.SQLiteSink, error) {
+	db, err := sink.OpenSQLite(strings.TrimSpace(path))
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+	if err := migrateSQLite(ctx, db.RawDB()); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite migrate: %w", err)
+	}
+	return db, nil
+}
+
+func migrateCommand(args []string) int {
+	fs, dbPath := newCommandFlags("migrate", config.Load())
+	_ = fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	db, err := openArchive(ctx, *dbPath)
+	if err != nil {
+		log.Printf("harvester: migrate: %v", err)
+		return 1
+	}
+	defer db.Close()
+	log.Printf("harvester: migrate: %s is up to date", *dbPath)
+	return 0
+}
+
+func exportCommand(args []string) int {
+	cfg := config.Load()
+	fs, dbPath := newCommandFlags("export", cfg)
+	format := fs.String("format", "ndjson", "Output format (ndjson or csv)")
+	out := fs.String("out", "", "Write to this file instead of stdout")
+	manifest := fs.Bool("manifest", false, "Write <out>.manifest.json with SHA-256 checksums (requires -out)")
+	keyPath := fs.String("signing-key", cfg.Export.SigningKeyFile, "PEM Ed25519 private key used to sign the manifest (implies -manifest)")
+	values := url.Values{}
+	for _, name := range []string{"platform", "username", "channel", "user_id", "channel_id", "session_id", "type", "since", "until"} {
+		fs.Func(name, "Only export messages matching this "+name+" (same syntax as the HTTP API)", func(v string) error {
+			values.Add(name, v)
+			return nil
+		})
+	}
+	_ = fs.Parse(args)
+
+	filters, err := httpapi.ParseFilters(values)
+	if err != nil {
+		log.Printf("harvester: export: %v", err)
+		return 2
+	}
+	if *format != "ndjson" && *format != "csv" {
+		log.Printf("harvester: export: format must be ndjson or csv")
+		return 2
+	}
+	if *out == "" {
+		explicit := false
+		fs.Visit(func(f *flag.Flag) { explicit = explicit || f.Name == "manifest" || f.Name == "signing-key" })
+		if explicit {
+			log.Printf("harvester: export: -manifest and -signing-key require -out")
+			return 2
+		}
+		// A configured key only applies to exports written to a file.
+		*manifest, *keyPath = false, ""
+	}
+	var key ed25519.PrivateKey
+	if *keyPath != "" {
+		if key, err = loadSigningKey(*keyPath); err != nil {
+			log.Printf("harvester: export: %v", err)
+			return 2
+		}
+		*manifest = true
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	db, err := openArchive(ctx, *dbPath)
+	if err != nil {
+		log.Printf("harvester: export: %v", err)
+		return 1
+	}
+	defer db.Close()
+
+	w := io.Writer(os.Stdout)
+	var file *os.File
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Printf("harvester: export: %v", err)
+			return 1
+		}
+		defer f.Close()
+		w, file = f, f
+	}
+	n, err := exportMessages(ctx, db, filters, *format, w)
+	if err != nil {
+		log.Printf("harvester: export: %v", err)
+		return 1
+	}
+	log.Printf("harvester: export: wrote %d messages", n)
+	if !*manifest {
+		return 0
+	}
+	// Flush to disk before checksumming; the deferred Close is then a no-op.
+	if err := file.Close(); err != nil {
+		log.Printf("harvester: export: %v", err)
+		return 1
+	}
+	path, err := writeExportManifest(exportManifest{
+		Version:   manifestVersion,
+		CreatedAt: time.Now().UTC(),
+		Format:    *format,
+		Messages:  n,
+		Filters:   values.Encode(),
+	}, []string{*out}, key)
+	if err != nil {
+		log.Printf("harvester: export: manifest: %v", err)
+		return 1
+	}
+	if key != nil {
+		log.Printf("harvester: export: wrote signed manifest %s", path)
+	} else {
+		log.Printf("harvester: export: wrote manifest %s", path)
+	}
+	return 0
+}
+
+// exportMessages writes every message matching filters to w and returns how
+// many were written. CSV uses the same columns as the user message export.
+func exportMessages(ctx context.Context, db *sink.SQLiteSink, filters httpapi.Filters, format string, w io.Writer) (int, error) {
+	bw := bufio.NewWriter(w)
+	var write func(core.ChatMessage) error
+	var flush func() error
+	if format == "csv" {
+		cw := csv.NewWriter(bw)
+		_ = cw.Write([]string{"id", "ts", "platform", "username", "author_channel_id", "session_id", "text"})
+		write = func(m core.ChatMessage) error {
+			return cw.Write([]string{m.ID, m.Ts.UTC().Format(time.RFC3339Nano), m.Platform, m.Username, m.AuthorChannelID, m.SessionID, m.Text})
+		}
+		flush = func() error {
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return err
+			}
+			return bw.Flush()
+		}
+	} else {
+		enc := json.NewEncoder(bw)
+		write = func(m core.ChatMessage) error { return enc.Encode(m) }
+		flush = bw.Flush
+	}
+
+	n := 0
+	err := db.ExportMessages(ctx, filters, func(msg core.ChatMessage) error {
+		n++
+		return write(msg)
+	})
+	if err != nil {
+		return n, err
+	}
+	return n, flush()
+}
+
+func importCommand(args []string) int {
+	fs, dbPath := newCommandFlags("import", config.Load())
+	in := fs.String("in", "", "Read NDJSON from this file instead of stdin")
+	_ = fs.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	db, err := openArchive(ctx, *dbPath)
+	if err != nil {
+		log.Printf("harvester: import: %v", err)
+		return 1
+	}
+	defer db.Close()
+
+	r := io.Reader(os.Stdin)
+	if *in != "" {
+		f, err := os.Open(*in)
+		if err != nil {
+			log.Printf("harvester: import: %v", err)
+			return 1
+		}
+		defer f.Close()
+		r = f
+	}
+	n, err := importMessages(ctx, db, r)
+	log.Printf("harvester: import: stored %d messages", n)
+	if err != nil {
+		log.Printf("harvester: import: %v", err)
+		return 1
+	}
+	return 0
+}
+
+const importBatch = 500
+
+// importMessages stores the NDJSON messages read from r in batches and
+// returns how many were stored. Messages already in the archive are updated
+// in place, so re-importing an export is harmless.
+func importMessages(ctx context.Context, db *sink.SQLiteSink, r io.Reader) (int, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	batch := make([]core.ChatMessage, 0, importBatch)
+	stored := 0
+	flush := func() error {
+		if err := db.WriteBatch(batch, nil); err != nil {
+			return err
+		}
+		stored += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+	for line := 1; ; line++ {
+		if err := ctx.Err(); err != nil {
+			return stored, err
+		}
+		var msg core.ChatMessage
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return stored, fmt.Errorf("message %d: %w", line, err)
+		}
+		if strings.TrimSpace(msg.ID) == "" || strings.TrimSpace(msg.Platform) == "" {
+			return stored, fmt.Errorf("message %d: id and platform are required", line)
+		}
+		batch = append(batch, msg)
+		if len(batch) == importBatch {
+			if err := flush(); err != nil {
+				return stored, err
+			}
+		}
+	}
+	return stored, flush()
+}
+
+func loginCommand(args []string) int {
+	cfg := config.Load()
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	token := fs.String("twitch-token", cfg.Twitch.Token, "Twitch OAuth token (format: oauth:xxxxx)")
+	tokenFile := fs.String("twitch-token-file", cfg.Twitch.TokenFile, "Path to file containing the Twitch OAuth token")
+	clientID := fs.String("twitch-client-id", cfg.Twitch.ClientID, "Twitch application client ID")
+	clientSecret := fs.String("twitch-client-secret", cfg.Twitch.ClientSecret, "Twitch application client secret")
+	refreshFile := fs.String("twitch-refresh-token-file", cfg.Twitch.RefreshTokenFile, "Path to file containing the Twitch refresh token")
+	proxyURL := fs.String("twitch-proxy", cfg.TwitchProxy(), "Outbound proxy for the Twitch OAuth requests")
+	_ = fs.Parse(args)
+
+	cfg.Twitch.Proxy = strings.TrimSpace(*proxyURL)
+	out, err := newOutbound(cfg)
+	if err != nil {
+		log.Printf("harvester: login: %v", err)
+		return 2
+	}
+
+	files := twitchauth.TokenFiles{
+		AccessPath:   strings.TrimSpace(*tokenFile),
+		RefreshPath:  strings.TrimSpace(*refreshFile),
+		ClientID:     strings.TrimSpace(*clientID),
+		ClientSecret: strings.TrimSpace(*clientSecret),
+	}
+	if files.AccessPath != "" && files.RefreshPath != "" && files.ClientID != "" && files.ClientSecret != "" {
+		if err := twitch.Refresh(out.twitchHTTP, files.ClientID, files.ClientSecret, files.RefreshPath, files.AccessPath); err != nil {
+			log.Printf("harvester: login: %v", err)
+			return 1
+		}
+		log.Printf("harvester: login: refreshed %s", files.AccessPath)
+	}
+
+	access := strings.TrimPrefix(strings.TrimSpace(*token), "oauth:")
+	if files.AccessPath != "" {
+		if access, err = files.ReadAccess(); err != nil {
+			log.Printf("harvester: login: %v", err)
+			return 1
+		}
+	}
+	if access == "" {
+		log.Printf("harvester: login: no twitch token configured")
+		return 2
+	}
+	login, err := twitchauth.ValidateLogin(out.twitchHTTP, access)
+	if err != nil {
+		log.Printf("harvester: login: %v", err)
+		return 1
+	}
+	fmt.Println(login)
+	return 0
+}
+
+func backfillCommand(args []string) int {
+	cfg := config.Load()
+	fs, dbPath := newCommandFlags("backfill", cfg)
+	what := fs.String("what", "all", "What to backfill: usernames, enrich or all")
+	_ = fs.Parse(args)
+	if *what != "usernames" && *what != "enrich" && *what != "all" {
+		log.Printf("harvester: backfill: -what must be usernames, enrich or all")
+		return 2
+	}
+	cfg.Sink.SQLite.Path = *dbPath
+
+	if *what == "usernames" || *what == "all" {
+		if code := backfillUsernames(cfg); code != 0 {
+			return code
+		}
+	}
+	if *what == "enrich" || *what == "all" {
+		return reenrich(cfg)
+	}
+	return 0
+}
+
+// backfillUsernames recomputes username_norm with the configured rules.
+func backfillUsernames(cfg config.Config) int {
+	rules, err := core.ParseUsernameRules(cfg.UsernameRules)
+	if err != nil {
+		log.Printf("harvester: backfill: %v", err)
+		return 2
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	db, err := openArchive(ctx, cfg.Sink.SQLite.Path)
+	if err != nil {
+		log.Printf("harvester: backfill: %v", err)
+		return 1
+	}
+	defer db.Close()
+	db.SetUsernameNormalizer(rules)
+	n, err := db.BackfillUsernameNorm(ctx)
+	log.Printf("harvester: backfill: username_norm rows=%d", n)
+	if err != nil {
+		log.Printf("harvester: backfill: usernames: %v", err)
+		return 1
+	}
+	return 0
+}
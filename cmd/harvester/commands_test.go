@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+func TestDispatchUnknownCommand(t *testing.T) {
+	var stderr bytes.Buffer
+	if code := dispatch([]string{"frobnicate"}, &stderr); code != 2 {
+		t.Fatalf("expected exit code 2, got %d", code)
+	}
+	if !strings.Contains(stderr.String(), `unknown command "frobnicate"`) || !strings.Contains(stderr.String(), "export") {
+		t.Fatalf("expected usage with the command list, got %q", stderr.String())
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	src, err := openArchive(ctx, filepath.Join(t.TempDir(), "src.db"))
+	if err != nil {
+		t.Fatalf("open source: %v", err)
+	}
+	defer src.Close()
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, msg := range []core.ChatMessage{
+		{ID: "tw-1", Platform: "Twitch", Username: "elora", Text: "hello", Ts: base},
+		{ID: "yt-1", Platform: "YouTube", Username: "gnasty", Text: "hi", Ts: base.Add(time.Second)},
+		{ID: "tw-2", Platform: "Twitch", Username: "elora", Text: "again, with a comma", Ts: base.Add(2 * time.Second)},
+	} {
+		if err := src.Write(msg, nil); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+
+	filters, err := httpapi.ParseFilters(url.Values{"platform": {"twitch"}})
+	if err != nil {
+		t.Fatalf("filters: %v", err)
+	}
+	var ndjson bytes.Buffer
+	n, err := exportMessages(ctx, src, filters, "ndjson", &ndjson)
+	if err != nil || n != 2 {
+		t.Fatalf("export: n=%d err=%v", n, err)
+	}
+
+	var csvOut bytes.Buffer
+	if _, err := exportMessages(ctx, src, filters, "csv", &csvOut); err != nil {
+		t.Fatalf("export csv: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "id,ts,platform") || !strings.Contains(lines[2], `"again, with a comma"`) {
+		t.Fatalf("unexpected csv export %q", csvOut.String())
+	}
+
+	dst, err := openArchive(ctx, filepath.Join(t.TempDir(), "dst.db"))
+	if err != nil {
+		t.Fatalf("open destination: %v", err)
+	}
+	defer dst.Close()
+	if n, err := importMessages(ctx, dst, bytes.NewReader(ndjson.Bytes())); err != nil || n != 2 {
+		t.Fatalf("import: n=%d err=%v", n, err)
+	}
+	// Importing the same export again updates rows rather than duplicating.
+	if _, err := importMessages(ctx, dst, bytes.NewReader(ndjson.Bytes())); err != nil {
+		t.Fatalf("re-import: %v", err)
+	}
+
+	all, _ := httpapi.ParseFilters(url.Values{})
+	var got []string
+	if err := dst.ExportMessages(ctx, all, func(msg core.ChatMessage) error {
+		got = append(got, msg.ID+":"+msg.Text)
+		return nil
+	}); err != nil {
+		t.Fatalf("read back: %v", err)
+	}
+	if strings.Join(got, "|") != "tw-1:hello|tw-2:again, with a comma" {
+		t.Fatalf("unexpected imported rows %v", got)
+	}
+
+	if _, err := importMessages(ctx, dst, strings.NewReader(`{"text":"no id"}`)); err == nil {
+		t.Fatalf("expected an error for a message without an id")
+	}
+}
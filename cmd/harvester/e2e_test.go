@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/testservers"
+)
+
+// TestHarvesterEndToEnd runs the real run command against the fake Twitch
+// IRC and YouTube servers and reads the stored chat back through the HTTP
+// API.
+func TestHarvesterEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("end-to-end test")
+	}
+	irc, err := testservers.NewTwitchIRC()
+	if err != nil {
+		t.Fatalf("start irc: %v", err)
+	}
+	defer irc.Close()
+	yt := testservers.NewYouTube("fakevideo01")
+	defer yt.Close()
+	yt.SetPollInterval(100 * time.Millisecond)
+
+	outboundOverride = func(out *outbound) {
+		out.twitchDial = irc.Dial
+		out.youtube = yt.Transport()
+	}
+	defer func() { outboundOverride = nil }()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("reserve port: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runHarvester(ctx, []string{
+			"-sqlite", filepath.Join(t.TempDir(), "chat.db"),
+			"-twitch-channel", "elora",
+			"-twitch-nick", "bot",
+			"-twitch-token", "oauth:fake",
+			"-twitch-tls=false",
+			"-youtube-url", yt.WatchURL(),
+			"-http-addr", addr,
+		})
+	}()
+
+	if err := irc.WaitJoin(ctx, "elora"); err != nil {
+		t.Fatalf("twitch join: %v", err)
+	}
+	irc.Privmsg("elora", testservers.TwitchMessage{ID: "tw-1", User: "alice", UserID: "10", Text: "hello from twitch", Color: "#FF0000"})
+	yt.Push(testservers.YouTubeMessage{ID: "yt-1", Author: "Carol", Text: "hello from youtube"})
+
+	// A dropped IRC connection is redialled and rejoined.
+	irc.DropClients()
+	if err := irc.WaitConnections(ctx, 2); err != nil {
+		t.Fatalf("twitch reconnect: %v", err)
+	}
+	if err := irc.WaitJoin(ctx, "elora"); err != nil {
+		t.Fatalf("twitch rejoin: %v", err)
+	}
+	irc.Privmsg("elora", testservers.TwitchMessage{ID: "tw-2", User: "bob", Text: "after reconnect"})
+
+	want := []string{"tw-1", "tw-2", "yt-1"}
+	var got []core.ChatMessage
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		got = fetchMessages(t, "http://"+addr+"/messages")
+		if len(got) >= len(want) || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	ids := make([]string, 0, len(got))
+	byID := map[string]core.ChatMessage{}
+	for _, msg := range got {
+		ids = append(ids, msg.ID)
+		byID[msg.ID] = msg
+	}
+	sort.Strings(ids)
+	if len(ids) != len(want) || ids[0] != want[0] || ids[1] != want[1] || ids[2] != want[2] {
+		t.Fatalf("expected messages %v, got %v", want, ids)
+	}
+	if msg := byID["tw-1"]; msg.Platform != "Twitch" || msg.Channel != "elora" || msg.Username != "alice" || msg.Colour != "#FF0000" {
+		t.Fatalf("unexpected twitch message %+v", msg)
+	}
+	if msg := byID["yt-1"]; msg.Platform != "YouTube" || msg.Username != "Carol" || msg.Text != "hello from youtube" {
+		t.Fatalf("unexpected youtube message %+v", msg)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(15 * time.Second):
+		t.Fatalf("harvester did not shut down")
+	}
+}
+
+func fetchMessages(t *testing.T, url string) []core.ChatMessage {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+	var msgs []core.ChatMessage
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&msgs) != nil {
+		return nil
+	}
+	return msgs
+}
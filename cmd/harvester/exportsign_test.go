@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportManifestSignAndVerify(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "chat.ndjson")
+	if err := os.WriteFile(out, []byte(`{"ID":"tw-1","Text":"hello"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPath := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := loadSigningKey(keyPath)
+	if err != nil {
+		t.Fatalf("load key: %v", err)
+	}
+
+	path, err := writeExportManifest(exportManifest{Version: manifestVersion, CreatedAt: time.Now().UTC(), Format: "ndjson", Messages: 1}, []string{out}, key)
+	if err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	if path != out+".manifest.json" {
+		t.Fatalf("manifest path = %s", path)
+	}
+	m, signed, err := verifyExportManifest(path, pub)
+	if err != nil || !signed {
+		t.Fatalf("verify: signed=%v err=%v", signed, err)
+	}
+	if len(m.Files) != 1 || m.Files[0].Name != "chat.ndjson" || len(m.Files[0].SHA256) != 64 {
+		t.Fatalf("unexpected manifest %+v", m)
+	}
+
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if _, _, err := verifyExportManifest(path, otherPub); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("expected a signature error with the wrong key, got %v", err)
+	}
+
+	// Tampering with the export breaks the checksum.
+	if err := os.WriteFile(out, []byte(`{"ID":"tw-1","Text":"goodbye"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := verifyExportManifest(path, nil); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+
+	// Tampering with the manifest breaks the signature.
+	data, _ := os.ReadFile(path)
+	if err := os.WriteFile(path, []byte(strings.Replace(string(data), `"messages": 1`, `"messages": 2`, 1)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := verifyExportManifest(path, nil); err == nil || !strings.Contains(err.Error(), "signature") {
+		t.Fatalf("expected a signature error, got %v", err)
+	}
+
+	// Unsigned manifests verify checksums only, unless a key is required.
+	if _, err := writeExportManifest(exportManifest{Version: manifestVersion}, []string{out}, nil); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(signaturePath(path))
+	if _, signed, err := verifyExportManifest(path, nil); err != nil || signed {
+		t.Fatalf("unsigned verify: signed=%v err=%v", signed, err)
+	}
+	if _, _, err := verifyExportManifest(path, pub); err == nil {
+		t.Fatal("expected an error for an unsigned manifest when a key is required")
+	}
+}
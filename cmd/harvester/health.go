@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// healthReporter receives receiver health changes; *httpapi.Server
+// satisfies it.
+type healthReporter interface {
+	ReportReceiverHealth(receiver string, healthy bool, detail string)
+}
+
+// receiverHealth decides what a fatal receiver error does. By default the
+// receiver is marked unhealthy and everything else keeps running; with
+// failFast the whole harvester is cancelled, as it used to be.
+type receiverHealth struct {
+	failFast bool
+	cancel   context.CancelFunc
+	reporter healthReporter
+
+	mu sync.Mutex
+	// running maps every receiver that has started to whether it still is.
+	running map[string]bool
+	// lastIngest is when a message last entered the writer chain, or when
+	// the first receiver started.
+	lastIngest time.Time
+}
+
+// up marks receiver as running.
+func (h *receiverHealth) up(receiver string) {
+	h.mu.Lock()
+	if h.running == nil {
+		h.running = make(map[string]bool)
+	}
+	h.running[receiver] = true
+	if h.lastIngest.IsZero() {
+		h.lastIngest = time.Now()
+	}
+	h.mu.Unlock()
+	if h.reporter != nil {
+		h.reporter.ReportReceiverHealth(receiver, true, "")
+	}
+}
+
+// fail handles a receiver that stopped with err.
+func (h *receiverHealth) fail(receiver string, err error) {
+	if h.failFast {
+		log.Printf("harvester: %s exited: %v; stopping (-fail-fast)", receiver, err)
+		h.cancel()
+		return
+	}
+	log.Printf("harvester: %s exited: %v; marked unhealthy", receiver, err)
+	h.mu.Lock()
+	if h.running != nil {
+		h.running[receiver] = false
+	}
+	h.mu.Unlock()
+	if h.reporter != nil {
+		h.reporter.ReportReceiverHealth(receiver, false, err.Error())
+	}
+}
+
+// ingested records that a message entered the writer chain.
+func (h *receiverHealth) ingested() {
+	h.mu.Lock()
+	h.lastIngest = time.Now()
+	h.mu.Unlock()
+}
+
+// check reports whether the receivers look alive for the systemd watchdog:
+// once any receiver has started, at least one must still be running and,
+// when idle is positive, a message must have arrived within idle. An
+// instance that never started a receiver (a cluster standby) always passes.
+func (h *receiverHealth) check(now time.Time, idle time.Duration) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.running) == 0 {
+		return nil
+	}
+	alive := false
+	for _, up := range h.running {
+		alive = alive || up
+	}
+	if !alive {
+		return errors.New("every receiver has stopped")
+	}
+	if quiet := now.Sub(h.lastIngest); idle > 0 && quiet > idle {
+		return fmt.Errorf("no message ingested for %s", quiet.Truncate(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type healthRecorder map[string]bool
+
+func (h healthRecorder) ReportReceiverHealth(receiver string, healthy bool, _ string) {
+	h[receiver] = healthy
+}
+
+func TestReceiverHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rec := healthRecorder{}
+	h := &receiverHealth{cancel: cancel, reporter: rec}
+
+	h.up("youtube")
+	h.fail("youtube", errors.New("invalid url"))
+	if rec["youtube"] || ctx.Err() != nil {
+		t.Fatalf("failure should mark unhealthy without cancelling: %v %v", rec, ctx.Err())
+	}
+
+	h.failFast = true
+	h.fail("twitch-irc", errors.New("no nick"))
+	if ctx.Err() == nil {
+		t.Fatalf("fail-fast should cancel the harvester")
+	}
+}
+
+func TestReceiverHealthCheck(t *testing.T) {
+	h := &receiverHealth{cancel: func() {}}
+	now := time.Now()
+	if err := h.check(now.Add(time.Hour), time.Minute); err != nil {
+		t.Fatalf("an instance without receivers should pass: %v", err)
+	}
+
+	h.up("twitch-irc")
+	h.up("youtube")
+	if err := h.check(time.Now(), time.Minute); err != nil {
+		t.Fatalf("fresh receivers should pass: %v", err)
+	}
+	if err := h.check(time.Now().Add(2*time.Minute), time.Minute); err == nil {
+		t.Fatalf("expected the idle check to fail after a quiet spell")
+	}
+	if err := h.check(time.Now().Add(2*time.Minute), 0); err != nil {
+		t.Fatalf("a zero idle should disable the freshness check: %v", err)
+	}
+	h.ingested()
+	if err := h.check(time.Now(), time.Minute); err != nil {
+		t.Fatalf("ingest should refresh the clock: %v", err)
+	}
+
+	h.fail("youtube", errors.New("gone"))
+	if err := h.check(time.Now(), 0); err != nil {
+		t.Fatalf("one running receiver should be enough: %v", err)
+	}
+	h.fail("twitch-irc", errors.New("gone"))
+	if err := h.check(time.Now(), 0); err == nil {
+		t.Fatalf("expected failure once every receiver stopped")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+// runHeartbeat periodically logs the store statistics exposed on /info so
+// operators get one consolidated ingest line instead of per-receiver chatter.
+func runHeartbeat(ctx context.Context, store httpapi.StatsStore, interval time.Duration, started time.Time) {
+	if store == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		statsCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		stats, err := store.Stats(statsCtx, httpapi.StatsWindow)
+		cancel()
+		if err != nil {
+			log.Printf("harvester: heartbeat stats: %v", err)
+			continue
+		}
+		log.Printf(
+			"harvester: heartbeat total=%d last_minute=%s db_bytes=%d uptime=%s",
+			stats.TotalMessages,
+			formatPlatformCounts(stats.RecentByPlatform),
+			stats.DBSizeBytes,
+			time.Since(started).Truncate(time.Second),
+		)
+	}
+}
+
+func formatPlatformCounts(counts map[string]int64) string {
+	if len(counts) == 0 {
+		return "{}"
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s:%d", k, counts[k]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
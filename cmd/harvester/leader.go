@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// leaseStore is the part of *sink.SQLiteSink used for leader election.
+type leaseStore interface {
+	AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	ReleaseLease(ctx context.Context, name, holder string) error
+}
+
+// leaderElector runs ingestion for a source only while this instance holds
+// the source's lease, so several harvesters can share a database and API
+// without storing messages twice. A nil elector always leads.
+type leaderElector struct {
+	store  leaseStore
+	holder string
+	ttl    time.Duration
+}
+
+func newLeaderElector(store leaseStore, holder string, ttl time.Duration) *leaderElector {
+	return &leaderElector{store: store, holder: holder, ttl: ttl}
+}
+
+// run calls fn whenever this instance becomes leader for name. The context
+// passed to fn is cancelled as soon as leadership is lost, and the lease is
+// released only after fn returns. run returns when ctx is cancelled.
+func (e *leaderElector) run(ctx context.Context, name string, fn func(context.Context)) {
+	if e == nil {
+		fn(ctx)
+		return
+	}
+	for {
+		ok, err := e.store.AcquireLease(ctx, name, e.holder, e.ttl)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("harvester: leader %s: %v", name, err)
+		}
+		if ok {
+			log.Printf("harvester: leader %s: acquired by %s", name, e.holder)
+			e.lead(ctx, name, fn)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("harvester: leader %s: lost, standing by", name)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.renewInterval()):
+		}
+	}
+}
+
+func (e *leaderElector) renewInterval() time.Duration {
+	return e.ttl / 3
+}
+
+// lead runs fn and renews the lease until ctx ends, fn returns, or the
+// lease can no longer be confirmed before it would expire.
+func (e *leaderElector) lead(ctx context.Context, name string, fn func(context.Context)) {
+	leadCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(leadCtx)
+	}()
+	defer func() {
+		cancel()
+		<-done
+		releaseCtx, cancelRelease := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelRelease()
+		if err := e.store.ReleaseLease(releaseCtx, name, e.holder); err != nil {
+			log.Printf("harvester: leader %s: release: %v", name, err)
+		}
+	}()
+
+	renew := e.renewInterval()
+	ticker := time.NewTicker(renew)
+	defer ticker.Stop()
+	confirmed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		ok, err := e.store.AcquireLease(ctx, name, e.holder, e.ttl)
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("harvester: leader %s: renew: %v", name, err)
+			// Step down before the lease can expire under us so two
+			// instances never ingest at once.
+			if time.Since(confirmed) >= e.ttl-renew {
+				return
+			}
+		case !ok:
+			return
+		default:
+			confirmed = time.Now()
+		}
+	}
+}
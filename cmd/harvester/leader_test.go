@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/sink"
+)
+
+func TestLeaderElectorFailover(t *testing.T) {
+	db, err := sink.OpenSQLite(filepath.Join(t.TempDir(), "leader.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	const ttl = 150 * time.Millisecond
+	var leading atomic.Int32
+	var maxLeading atomic.Int32
+	ingest := func(ctx context.Context) {
+		n := leading.Add(1)
+		if n > maxLeading.Load() {
+			maxLeading.Store(n)
+		}
+		<-ctx.Done()
+		leading.Add(-1)
+	}
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	doneA := make(chan struct{})
+	go func() {
+		defer close(doneA)
+		newLeaderElector(db, "a", ttl).run(ctxA, "twitch:elora", ingest)
+	}()
+	waitFor(t, func() bool { return leading.Load() == 1 })
+
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+	go newLeaderElector(db, "b", ttl).run(ctxB, "twitch:elora", ingest)
+	time.Sleep(2 * ttl)
+	if got := leading.Load(); got != 1 {
+		t.Fatalf("expected exactly one leader, got %d", got)
+	}
+
+	// Stopping the leader releases the lease and the standby takes over.
+	cancelA()
+	<-doneA
+	waitFor(t, func() bool { return leading.Load() == 1 })
+	if got := maxLeading.Load(); got != 1 {
+		t.Fatalf("expected leaders never to overlap, saw %d at once", got)
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met before deadline")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -9,21 +9,32 @@ import (
 	"log/slog"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/you/gnasty-chat/internal/backfill"
 	"github.com/you/gnasty-chat/internal/config"
 	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/duckdb"
+	"github.com/you/gnasty-chat/internal/errlog"
+	"github.com/you/gnasty-chat/internal/execplugin"
 	"github.com/you/gnasty-chat/internal/harvester"
 	httpadmin "github.com/you/gnasty-chat/internal/http"
 	"github.com/you/gnasty-chat/internal/httpapi"
 	"github.com/you/gnasty-chat/internal/ingesttrace"
+	"github.com/you/gnasty-chat/internal/ircbridge"
+	"github.com/you/gnasty-chat/internal/luahook"
+	"github.com/you/gnasty-chat/internal/moments"
+	"github.com/you/gnasty-chat/internal/replay"
+	"github.com/you/gnasty-chat/internal/sdnotify"
 	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/internal/triggers"
 	"github.com/you/gnasty-chat/internal/twitch"
 	"github.com/you/gnasty-chat/internal/twitchauth"
-	"github.com/you/gnasty-chat/internal/twitchbadges"
+	"github.com/you/gnasty-chat/internal/twitcheventsub"
 	"github.com/you/gnasty-chat/internal/twitchirc"
 	"github.com/you/gnasty-chat/internal/version"
 	"github.com/you/gnasty-chat/internal/ytlive"
@@ -35,12 +46,34 @@ func (noopWriter) Write(core.ChatMessage, *ingesttrace.MessageTrace) error {
 	return errors.New("no sink configured")
 }
 
-func main() {
-	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+// limitIngest caps the chat receiver writes to w at ratePerSec, counting
+// the overflow on api's metrics. A zero rate returns w unchanged.
+func limitIngest(w sink.Writer, receiver string, ratePerSec, sample float64, api *httpapi.Server) sink.Writer {
+	if ratePerSec <= 0 {
+		return w
+	}
+	opts := sink.IngestLimiterOptions{Receiver: receiver, Rate: ratePerSec, Sample: sample}
+	if api != nil {
+		opts.OnOverflow = api.ReportIngestOverflow
+	}
+	log.Printf("harvester: %s ingest limited to %g messages/sec (overflow sample=%g)", receiver, ratePerSec, sample)
+	return sink.NewTransformWriter(w, sink.NewIngestLimiter(opts))
+}
+
+// runHarvester is the run command: it starts the receivers, sinks and
+// servers described by the configuration and flags, and blocks until it is
+// signalled to stop or parent is cancelled.
+func runHarvester(parent context.Context, args []string) {
+	started := time.Now()
 
 	var (
 		versionFlag     bool
+		checkCfgFlag    bool
+		reenrichFlag    bool
+		failFast        bool
 		dbPath          string
+		sqliteDir       string
+		hashChain       bool
 		twChannel       string
 		twNick          string
 		twToken         string
@@ -51,35 +84,115 @@ func main() {
 		twRefreshFile   string
 		twTLS           bool
 		ytURL           string
+		ytLiveSelect    string
+		proxyURL        string
+		twProxyURL      string
+		ytProxyURL      string
+		ytCookies       string
+		replayFile      string
+		replaySpeed     float64
+		defaultColours  bool
+		mirrorWindow    time.Duration
+		transformScript string
 		httpAddr        string
 		httpCorsOrigins string
 		httpRateRPS     int
 		httpRateBurst   int
+		httpStreamRPS   int
+		httpStreamBurst int
+		httpProxies     string
+		httpRateAllow   string
+		httpTLSCert     string
+		httpTLSKey      string
+		httpAutocert    string
+		httpAutocertDir string
+		httpAutocertTo  string
+		httpDrain       time.Duration
+		httpWSDeflate   bool
 		httpMetrics     bool
 		httpAccessLog   bool
+		httpAccessFile  string
+		httpAccessFmt   string
+		httpAccessMaxMB int
+		httpAccessAge   time.Duration
+		httpAccessKeep  int
 		httpPprof       bool
+		httpUI          bool
+		httpMaskWords   string
+		httpAPIKeys     string
+		httpQueryDuckDB string
+		httpQuerySource string
+		httpQueryTime   time.Duration
+		httpQueryRows   int
+		ircAddr         string
+		ircPassword     string
+		ircHistory      int
+		ircWhispers     bool
 	)
 
-	flag.BoolVar(&versionFlag, "version", false, "Print build version and exit")
-	flag.StringVar(&dbPath, "sqlite", "chat.db", "Path to SQLite database file")
-	flag.StringVar(&twChannel, "twitch-channel", "", "Twitch channel to join (without #)")
-	flag.StringVar(&twNick, "twitch-nick", "", "Twitch nickname to login as")
-	flag.StringVar(&twToken, "twitch-token", "", "Twitch OAuth token (format: oauth:xxxxx)")
-	flag.StringVar(&twTokenFile, "twitch-token-file", "", "Path to file containing the Twitch OAuth token")
-	flag.StringVar(&twClientID, "twitch-client-id", "", "Twitch application client ID")
-	flag.StringVar(&twClientSecret, "twitch-client-secret", "", "Twitch application client secret")
-	flag.StringVar(&twRefreshToken, "twitch-refresh-token", "", "Twitch OAuth refresh token")
-	flag.StringVar(&twRefreshFile, "twitch-refresh-token-file", "", "Path to file containing the Twitch refresh token")
-	flag.BoolVar(&twTLS, "twitch-tls", true, "Use TLS (port 6697) for Twitch IRC connection")
-	flag.StringVar(&ytURL, "youtube-url", "", "YouTube live/watch URL")
-	flag.StringVar(&httpAddr, "http-addr", "", "HTTP status/stream address (e.g., :8765)")
-	flag.StringVar(&httpCorsOrigins, "http-cors-origins", "", "Comma-separated list of allowed CORS origins")
-	flag.IntVar(&httpRateRPS, "http-rate-rps", 20, "Maximum HTTP requests per second per client")
-	flag.IntVar(&httpRateBurst, "http-rate-burst", 40, "Burst size for HTTP rate limiter")
-	flag.BoolVar(&httpMetrics, "http-metrics", true, "Expose Prometheus metrics endpoint")
-	flag.BoolVar(&httpAccessLog, "http-access-log", true, "Log HTTP access records")
-	flag.BoolVar(&httpPprof, "http-pprof", false, "Expose pprof handlers under /debug/pprof")
-	flag.Parse()
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.BoolVar(&versionFlag, "version", false, "Print build version and exit")
+	fs.BoolVar(&checkCfgFlag, "check-config", false, "Validate configuration, print the redacted effective config, and exit")
+	fs.BoolVar(&reenrichFlag, "reenrich", false, "Re-run badge/emote enrichment over stored messages and exit")
+	fs.BoolVar(&failFast, "fail-fast", false, "Exit when any receiver stops with a fatal error instead of marking it unhealthy")
+	fs.StringVar(&dbPath, "sqlite", "chat.db", "Path to SQLite database file")
+	fs.StringVar(&sqliteDir, "sqlite-dir", "chat-data", "Data directory for the sqlite-channels sink (one database per channel)")
+	fs.BoolVar(&hashChain, "sqlite-hash-chain", false, "Link stored messages into per-session SHA-256 hash chains verified by GET /admin/verify")
+	fs.StringVar(&twChannel, "twitch-channel", "", "Twitch channel to join (without #)")
+	fs.StringVar(&twNick, "twitch-nick", "", "Twitch nickname to login as")
+	fs.StringVar(&twToken, "twitch-token", "", "Twitch OAuth token (format: oauth:xxxxx)")
+	fs.StringVar(&twTokenFile, "twitch-token-file", "", "Path to file containing the Twitch OAuth token")
+	fs.StringVar(&twClientID, "twitch-client-id", "", "Twitch application client ID")
+	fs.StringVar(&twClientSecret, "twitch-client-secret", "", "Twitch application client secret")
+	fs.StringVar(&twRefreshToken, "twitch-refresh-token", "", "Twitch OAuth refresh token")
+	fs.StringVar(&twRefreshFile, "twitch-refresh-token-file", "", "Path to file containing the Twitch refresh token")
+	fs.BoolVar(&twTLS, "twitch-tls", true, "Use TLS (port 6697) for Twitch IRC connection")
+	fs.StringVar(&ytURL, "youtube-url", "", "YouTube live/watch URL, channel ID or @handle")
+	fs.StringVar(&ytLiveSelect, "youtube-live-select", "first", "Which of a channel's simultaneous live streams to poll: first, all, or a title substring")
+	fs.StringVar(&replayFile, "replay-file", "", "Replay this NDJSON chat log as live chat instead of (or alongside) platform receivers")
+	fs.Float64Var(&replaySpeed, "replay-speed", 1, "Pacing of -replay-file relative to its timestamps (0 replays without pauses)")
+	fs.StringVar(&proxyURL, "proxy", "", "Outbound proxy for all receivers (http://, https://, socks5:// or socks5h://)")
+	fs.StringVar(&twProxyURL, "twitch-proxy", "", "Outbound proxy for Twitch IRC, Helix and OAuth (overrides -proxy)")
+	fs.StringVar(&ytProxyURL, "youtube-proxy", "", "Outbound proxy for YouTube (overrides -proxy)")
+	fs.StringVar(&ytCookies, "youtube-cookies", "", "Netscape cookies.txt for a signed-in YouTube account (member-only and unlisted chats)")
+	fs.BoolVar(&defaultColours, "default-colours", true, "Assign a stable palette colour to chatters without one")
+	fs.DurationVar(&mirrorWindow, "mirror-window", 0, "Tag messages repeated by the same user on another platform within this window as simulcast mirrors (0 disables)")
+	fs.StringVar(&transformScript, "transform-script", "", "Lua script whose transform(msg) function can modify or drop every message before it is stored")
+	fs.StringVar(&httpAddr, "http-addr", "", "HTTP status/stream address (e.g., :8765)")
+	fs.StringVar(&httpCorsOrigins, "http-cors-origins", "", "Comma-separated list of allowed CORS origins")
+	fs.IntVar(&httpRateRPS, "http-rate-rps", 20, "Maximum HTTP requests per second per client")
+	fs.IntVar(&httpRateBurst, "http-rate-burst", 40, "Burst size for HTTP rate limiter")
+	fs.IntVar(&httpStreamRPS, "http-stream-rate-rps", 2, "Maximum /stream and /ws connection attempts per second per client (0 uses -http-rate-rps)")
+	fs.IntVar(&httpStreamBurst, "http-stream-rate-burst", 10, "Burst size for the /stream and /ws rate limiter (0 uses -http-rate-burst)")
+	fs.StringVar(&httpProxies, "http-trusted-proxies", "", "Comma-separated CIDRs of reverse proxies whose X-Forwarded-For header is trusted")
+	fs.StringVar(&httpRateAllow, "http-rate-allowlist", "", "Comma-separated CIDRs exempt from HTTP rate limiting")
+	fs.StringVar(&httpTLSCert, "http-tls-cert", "", "PEM certificate file; serves the HTTP API over HTTPS (requires -http-tls-key)")
+	fs.StringVar(&httpTLSKey, "http-tls-key", "", "PEM private key file for -http-tls-cert")
+	fs.StringVar(&httpAutocert, "http-tls-autocert-domains", "", "Comma-separated host names to obtain Let's Encrypt certificates for; serves the HTTP API over HTTPS (listen on :443)")
+	fs.StringVar(&httpAutocertDir, "http-tls-autocert-cache", "autocert", "Directory caching Let's Encrypt account keys and certificates")
+	fs.StringVar(&httpAutocertTo, "http-tls-autocert-email", "", "Contact email for the Let's Encrypt account (optional)")
+	fs.BoolVar(&httpWSDeflate, "http-ws-compression", false, "Negotiate permessage-deflate compression with /ws clients that support it")
+	fs.DurationVar(&httpDrain, "http-shutdown-drain", 2*time.Second, "How long stream clients keep receiving queued messages on shutdown before being closed")
+	fs.BoolVar(&httpMetrics, "http-metrics", true, "Expose Prometheus metrics endpoint")
+	fs.BoolVar(&httpAccessLog, "http-access-log", true, "Log HTTP access records")
+	fs.StringVar(&httpAccessFile, "http-access-log-file", "", "Write HTTP access records to this file instead of the application log")
+	fs.StringVar(&httpAccessFmt, "http-access-log-format", "text", "HTTP access log format (text or json)")
+	fs.IntVar(&httpAccessMaxMB, "http-access-log-max-mb", 100, "Rotate the access log file after this many megabytes (0 disables)")
+	fs.DurationVar(&httpAccessAge, "http-access-log-max-age", 24*time.Hour, "Rotate the access log file after this duration (0 disables)")
+	fs.IntVar(&httpAccessKeep, "http-access-log-backups", 7, "Number of rotated access log files to keep (0 keeps all)")
+	fs.BoolVar(&httpPprof, "http-pprof", false, "Expose pprof handlers under /debug/pprof")
+	fs.BoolVar(&httpUI, "http-ui", true, "Serve the embedded chat viewer under /ui/")
+	fs.StringVar(&httpAPIKeys, "http-api-keys", "", "JSON file of API keys required by the HTTP API (optionally scoped to platforms/channels)")
+	fs.StringVar(&httpQueryDuckDB, "http-query-duckdb", "", "duckdb executable serving analytic SELECT queries on /query (empty disables /query)")
+	fs.StringVar(&httpQuerySource, "http-query-source", "", "SQLite archive or Parquet file/glob queried by /query (defaults to the sqlite sink path)")
+	fs.DurationVar(&httpQueryTime, "http-query-timeout", 10*time.Second, "Maximum run time of a /query request")
+	fs.IntVar(&httpQueryRows, "http-query-max-rows", 1000, "Maximum rows returned by a /query request")
+	fs.StringVar(&httpMaskWords, "http-mask-words", "", "Word list file (one per line) masked in message text for requests with masked=true")
+	fs.StringVar(&ircAddr, "irc-addr", "", "IRC bridge listen address (e.g., :6667); empty disables")
+	fs.StringVar(&ircPassword, "irc-password", "", "Password IRC bridge clients must send via PASS")
+	fs.IntVar(&ircHistory, "irc-history", 50, "Stored messages replayed to IRC bridge clients on JOIN (0 disables)")
+	fs.BoolVar(&ircWhispers, "irc-whispers", false, "Relay captured Twitch whispers to IRC bridge clients")
+	_ = fs.Parse(args)
 
 	if versionFlag {
 		fmt.Printf(
@@ -92,7 +205,7 @@ func main() {
 	}
 
 	overrides := make(map[string]bool)
-	flag.Visit(func(f *flag.Flag) {
+	fs.Visit(func(f *flag.Flag) {
 		overrides[f.Name] = true
 	})
 
@@ -109,6 +222,18 @@ func main() {
 		cfg.Sink.SQLite.Path = dbPath
 		addSink("sqlite")
 	}
+	if overrides["sqlite-dir"] {
+		cfg.Sink.SQLite.Dir = strings.TrimSpace(sqliteDir)
+		// The per-channel layout replaces the single-file sink unless
+		// -sqlite was given as well (which validation then rejects).
+		if !overrides["sqlite"] {
+			cfg.Sinks = slices.DeleteFunc(cfg.Sinks, func(name string) bool { return name == "sqlite" })
+		}
+		addSink("sqlite-channels")
+	}
+	if overrides["sqlite-hash-chain"] {
+		cfg.Sink.SQLite.HashChain = hashChain
+	}
 	if overrides["twitch-channel"] {
 		trimmed := strings.TrimSpace(twChannel)
 		if trimmed != "" {
@@ -146,21 +271,55 @@ func main() {
 		cfg.YouTube.LiveURL = strings.TrimSpace(ytURL)
 		cfg.YouTube.Enabled = cfg.YouTube.LiveURL != ""
 	}
+	if overrides["youtube-live-select"] {
+		cfg.YouTube.LiveSelect = strings.TrimSpace(ytLiveSelect)
+	}
+	if overrides["replay-file"] {
+		cfg.Replay.File = strings.TrimSpace(replayFile)
+	}
+	if overrides["replay-speed"] {
+		cfg.Replay.Speed = replaySpeed
+	}
+	if overrides["proxy"] {
+		cfg.Proxy = strings.TrimSpace(proxyURL)
+	}
+	if overrides["twitch-proxy"] {
+		cfg.Twitch.Proxy = strings.TrimSpace(twProxyURL)
+	}
+	if overrides["youtube-proxy"] {
+		cfg.YouTube.Proxy = strings.TrimSpace(ytProxyURL)
+	}
+	if overrides["youtube-cookies"] {
+		cfg.YouTube.CookieFile = strings.TrimSpace(ytCookies)
+	}
+	if overrides["default-colours"] {
+		cfg.DefaultColours = defaultColours
+	}
+	if overrides["mirror-window"] {
+		cfg.MirrorWindowMS = int(mirrorWindow.Milliseconds())
+	}
+	if overrides["transform-script"] {
+		cfg.Transform.Script = strings.TrimSpace(transformScript)
+	}
 
 	if len(cfg.Twitch.Channels) > 0 {
 		cfg.Twitch.Enabled = true
 	}
 
+	if checkCfgFlag {
+		os.Exit(checkConfig(cfg, os.Stdout, os.Stderr))
+	}
+	if reenrichFlag {
+		os.Exit(reenrich(cfg))
+	}
+
 	dbPath = cfg.Sink.SQLite.Path
 	if len(cfg.Sinks) == 0 {
-		log.Printf("harvester: no sinks configured; supported sinks: sqlite")
+		log.Printf("harvester: no sinks configured; supported sinks: %s", strings.Join(config.SupportedSinks(), ", "))
 	}
 
 	if len(cfg.Twitch.Channels) > 0 {
 		twChannel = cfg.Twitch.Channels[0]
-		if len(cfg.Twitch.Channels) > 1 {
-			log.Printf("harvester: twitch: multiple channels configured; using %s", twChannel)
-		}
 	} else {
 		twChannel = ""
 	}
@@ -192,6 +351,20 @@ func main() {
 	configSnapshot := cfg.Redacted()
 	log.Printf("%s", cfg.SummaryJSON())
 
+	out, err := newOutbound(cfg)
+	if err != nil {
+		log.Fatalf("harvester: %v", err)
+	}
+	if out.twitchProxy != nil {
+		log.Printf("harvester: twitch traffic via proxy %s", out.twitchProxy.Redacted())
+	}
+	if out.youtubeProxy != nil {
+		log.Printf("harvester: youtube traffic via proxy %s", out.youtubeProxy.Redacted())
+	}
+	if out.youtubeJar != nil {
+		log.Printf("harvester: youtube requests signed in with cookies from %s", cfg.YouTube.CookieFile)
+	}
+
 	tokenFiles := twitchauth.TokenFiles{
 		AccessPath:   twTokenFile,
 		RefreshPath:  twRefreshFile,
@@ -211,6 +384,7 @@ func main() {
 			ClientSecret: twClientSecret,
 			RefreshToken: twRefreshToken,
 			TokenFile:    twTokenFile,
+			HTTP:         out.twitchHTTP,
 		}
 	}
 	var refreshUpdater func(string)
@@ -218,8 +392,9 @@ func main() {
 		refreshUpdater = refreshMgr.SetRefreshToken
 	}
 	har := harvester.New(tokenFiles, nil, refreshUpdater)
+	errs := errlog.New(0)
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(parent)
 	defer cancel()
 
 	sigCh := make(chan os.Signal, 1)
@@ -231,14 +406,25 @@ func main() {
 	}()
 
 	var (
-		sinkDB   *sink.SQLiteSink
-		api      *httpapi.Server
-		writer   sink.Writer = noopWriter{}
-		buffered *sink.BufferedWriter
+		sinkDB       *sink.SQLiteSink
+		channelDB    *sink.ChannelSQLite
+		store        httpapi.Store
+		api          *httpapi.Server
+		bridge       *ircbridge.Server
+		broadcasters sink.Fanout
+		writer       sink.Writer = noopWriter{}
+		buffered     *sink.BufferedWriter
 	)
 
 	if cfg.HasSink("sqlite") {
-		db, err := sink.OpenSQLite(dbPath)
+		open := sink.OpenSQLite
+		if cfg.Sink.SQLite.Shared {
+			open = func(path string) (*sink.SQLiteSink, error) {
+				return sink.OpenSharedSQLite(path, cfg.SQLiteBusyTimeout())
+			}
+			log.Printf("harvester: sqlite shared with other writers busy_timeout=%s", cfg.SQLiteBusyTimeout())
+		}
+		db, err := open(dbPath)
 		if err != nil {
 			log.Fatalf("harvester: open sqlite: %v", err)
 		}
@@ -249,7 +435,72 @@ func main() {
 		if err := migrateSQLite(ctx, sinkDB.RawDB()); err != nil {
 			log.Fatalf("harvester: sqlite migrate: %v", err)
 		}
+		usernames, err := core.ParseUsernameRules(cfg.UsernameRules)
+		if err != nil {
+			log.Fatalf("harvester: %v", err)
+		}
+		sinkDB.SetUsernameNormalizer(usernames)
+		if cfg.Sink.SQLite.HashChain {
+			sinkDB.EnableHashChain()
+			log.Printf("harvester: sqlite hash chain enabled")
+		}
+		go func() {
+			n, err := sinkDB.BackfillUsernameNorm(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("harvester: sqlite: username backfill: %v", err)
+			}
+			if n > 0 {
+				log.Printf("harvester: sqlite: backfilled username_norm rows=%d", n)
+			}
+		}()
+		if cfg.Twitch.Profiles {
+			if strings.TrimSpace(twClientID) != "" && strings.TrimSpace(twClientSecret) != "" {
+				profiles := out.twitchResolver(twClientID, twClientSecret)
+				go runTwitchProfiles(ctx, sinkDB, profiles.Profiles)
+				log.Printf("harvester: twitch profile enrichment enabled")
+			} else {
+				log.Printf("harvester: twitch profiles requested but client id/secret missing; skipping")
+			}
+		}
+		if cfg.Twitch.StreamStatus && strings.TrimSpace(twChannel) != "" {
+			if strings.TrimSpace(twClientID) != "" && strings.TrimSpace(twClientSecret) != "" {
+				streams := out.twitchResolver(twClientID, twClientSecret)
+				go runTwitchStreamStatus(ctx, sinkDB, streams.Stream, twitchLogin(twChannel))
+				log.Printf("harvester: twitch stream status polling enabled")
+			} else {
+				log.Printf("harvester: twitch stream status requested but client id/secret missing; skipping")
+			}
+		}
+		if cfg.Moments.Enabled {
+			opts := moments.DefaultOptions()
+			opts.MinZScore = cfg.Moments.MinZScore
+			go runMoments(ctx, sinkDB, opts)
+			log.Printf("harvester: moment detection enabled (min z-score %.1f)", opts.MinZScore)
+		}
 		writer = sinkDB
+		store = sinkDB
+	} else if cfg.HasSink("sqlite-channels") {
+		db, err := sink.OpenChannelSQLite(cfg.Sink.SQLite.Dir)
+		if err != nil {
+			log.Fatalf("harvester: open per-channel sqlite: %v", err)
+		}
+		channelDB = db
+		usernames, err := core.ParseUsernameRules(cfg.UsernameRules)
+		if err != nil {
+			log.Fatalf("harvester: %v", err)
+		}
+		channelDB.SetUsernameNormalizer(usernames)
+		if cfg.Sink.SQLite.HashChain {
+			log.Printf("harvester: sqlite hash chain is not supported by the per-channel layout; ignoring")
+		}
+		defer func() {
+			if err := channelDB.Close(); err != nil {
+				log.Printf("harvester: closing per-channel sink: %v", err)
+			}
+		}()
+		writer = channelDB
+		store = channelDB
+		log.Printf("harvester: per-channel sqlite layout dir=%s shards=%d", cfg.Sink.SQLite.Dir, len(channelDB.Shards()))
 	} else {
 		log.Printf("harvester: sqlite sink disabled (configured sinks=%v)", cfg.Sinks)
 	}
@@ -262,15 +513,33 @@ func main() {
 		}()
 	}
 
-	var corsOrigins []string
-	if strings.TrimSpace(httpCorsOrigins) != "" {
-		for _, origin := range strings.Split(httpCorsOrigins, ",") {
-			origin = strings.TrimSpace(origin)
-			if origin != "" {
-				corsOrigins = append(corsOrigins, origin)
+	// twSender queues outbound Twitch chat within the room's slow-mode limits.
+	var twSender *twitchirc.Sender
+	if strings.TrimSpace(twChannel) != "" {
+		twSender = twitchirc.NewSender(twitchirc.SenderOptions{})
+		go twSender.Run(ctx)
+	}
+
+	// twPool spreads the Twitch channels across IRC connections.
+	var twPool *twitchirc.Pool
+	if strings.TrimSpace(twChannel) != "" {
+		twPool = twitchirc.NewPool(cfg.Twitch.Channels, twitchirc.PoolOptions{PerConnection: cfg.Twitch.ChannelsPerConn})
+		if len(cfg.Twitch.Channels) > 1 {
+			log.Printf("harvester: twitch: %d channels over %d connections", len(cfg.Twitch.Channels), len(twPool.Shards()))
+		}
+	}
+
+	splitCSV := func(raw string) []string {
+		var out []string
+		for _, item := range strings.Split(raw, ",") {
+			item = strings.TrimSpace(item)
+			if item != "" {
+				out = append(out, item)
 			}
 		}
+		return out
 	}
+	corsOrigins := splitCSV(httpCorsOrigins)
 
 	build := httpapi.BuildInfo{Version: version.Version, Revision: version.Commit}
 	if version.BuildTime != "" && version.BuildTime != "unknown" {
@@ -280,22 +549,83 @@ func main() {
 	}
 
 	if httpAddr != "" {
-		if sinkDB == nil {
+		if store == nil {
 			log.Printf("harvester: http api requested but sqlite sink is disabled; skipping listener")
 		} else {
-			api = httpapi.New(sinkDB, httpapi.Options{
-				Addr:            httpAddr,
-				CORSOrigins:     corsOrigins,
-				RateLimitRPS:    httpRateRPS,
-				RateLimitBurst:  httpRateBurst,
-				EnableMetrics:   httpMetrics,
-				EnableAccessLog: httpAccessLog,
-				EnablePprof:     httpPprof,
-				Build:           build,
-				ConfigSnapshot:  configSnapshot,
+			var apiKeys []httpapi.APIKey
+			if path := strings.TrimSpace(httpAPIKeys); path != "" {
+				keys, err := httpapi.LoadAPIKeys(path)
+				if err != nil {
+					log.Fatalf("harvester: %v", err)
+				}
+				apiKeys = keys
+				log.Printf("harvester: http api requires one of %d api keys", len(apiKeys))
+			}
+			var queryEngine httpapi.QueryEngine
+			if strings.TrimSpace(httpQueryDuckDB) != "" {
+				source := strings.TrimSpace(httpQuerySource)
+				if source == "" && sinkDB != nil {
+					source = dbPath
+				}
+				if source == "" {
+					log.Printf("harvester: /query needs -http-query-source without the sqlite sink; disabled")
+				} else if engine, err := duckdb.New(ctx, duckdb.Options{Binary: httpQueryDuckDB, Source: source}); err != nil {
+					log.Printf("harvester: %v; /query disabled", err)
+				} else {
+					queryEngine = engine
+					log.Printf("harvester: /query enabled source=%s", source)
+				}
+			}
+			api = httpapi.New(store, httpapi.Options{
+				Addr:                 httpAddr,
+				CORSOrigins:          corsOrigins,
+				RateLimitRPS:         httpRateRPS,
+				RateLimitBurst:       httpRateBurst,
+				StreamRateLimitRPS:   httpStreamRPS,
+				StreamRateLimitBurst: httpStreamBurst,
+				TrustedProxies:       splitCSV(httpProxies),
+				RateLimitAllowlist:   splitCSV(httpRateAllow),
+				TLSCertFile:          strings.TrimSpace(httpTLSCert),
+				TLSKeyFile:           strings.TrimSpace(httpTLSKey),
+				TLSAutocertDomains:   splitCSV(httpAutocert),
+				TLSAutocertCacheDir:  strings.TrimSpace(httpAutocertDir),
+				TLSAutocertEmail:     strings.TrimSpace(httpAutocertTo),
+				ShutdownDrain:        httpDrain,
+				WSCompression:        httpWSDeflate,
+				EnableMetrics:        httpMetrics,
+				EnableAccessLog:      httpAccessLog,
+				AccessLog: httpapi.AccessLogOptions{
+					Path:       httpAccessFile,
+					Format:     httpAccessFmt,
+					MaxSizeMB:  httpAccessMaxMB,
+					MaxAge:     httpAccessAge,
+					MaxBackups: httpAccessKeep,
+				},
+				EnablePprof:    httpPprof,
+				EnableUI:       httpUI,
+				APIKeys:        apiKeys,
+				EmitToken:      cfg.Sink.SQLite.WriteToken,
+				MaskWordsFile:  strings.TrimSpace(httpMaskWords),
+				QueryEngine:    queryEngine,
+				QueryTimeout:   httpQueryTime,
+				QueryMaxRows:   httpQueryRows,
+				Build:          build,
+				ConfigSnapshot: configSnapshot,
 			})
 			if har != nil {
 				admin := httpadmin.New(har)
+				if twSender != nil {
+					admin.SetSendQueue(twSender)
+				}
+				if twPool != nil {
+					admin.SetChannelPool(twPool)
+				}
+				admin.SetToken(cfg.Admin.Token)
+				admin.SetErrorLog(errs)
+				if sinkDB != nil {
+					admin.SetUserEraser(sinkDB, cfg.Admin.ErasureMode == "redact")
+					admin.SetChainVerifier(sinkDB)
+				}
 				admin.Register(api.Mux())
 			}
 			go func() {
@@ -303,12 +633,148 @@ func main() {
 					log.Fatalf("harvester: http api: %v", err)
 				}
 			}()
-			writer = sink.WithAPI(sinkDB, api)
+			broadcasters = append(broadcasters, api)
 			log.Printf("harvester: http api ready on %s", httpAddr)
 		}
 	}
 
-	if sinkDB != nil && (cfg.Batch() > 1 || cfg.FlushInterval() > 0) {
+	if ircAddr != "" {
+		if sinkDB == nil {
+			log.Printf("harvester: irc bridge requested but sqlite sink is disabled; skipping listener")
+		} else {
+			bridge = ircbridge.New(sinkDB, ircbridge.Options{
+				Addr:         ircAddr,
+				Password:     ircPassword,
+				HistoryLines: ircHistory,
+				Whispers:     ircWhispers,
+			})
+			go func() {
+				if err := bridge.Start(); err != nil {
+					log.Fatalf("harvester: irc bridge: %v", err)
+				}
+			}()
+			broadcasters = append(broadcasters, bridge)
+		}
+	}
+
+	if len(broadcasters) > 0 {
+		var target interface{ Broadcast(core.ChatMessage) } = broadcasters
+		if cfg.Redis.URL != "" {
+			bridge := sink.NewRedisBridge(sink.RedisOptions{
+				URL:      cfg.Redis.URL,
+				Channel:  cfg.Redis.Channel,
+				Whispers: cfg.Redis.Whispers,
+			}, broadcasters)
+			defer func() {
+				if err := bridge.Close(); err != nil {
+					log.Printf("harvester: closing redis bridge: %v", err)
+				}
+			}()
+			target = bridge
+			log.Printf("harvester: redis broadcast bridge enabled channel=%s", cfg.Redis.Channel)
+		}
+		if sinkDB != nil {
+			writer = sink.WithAPI(sinkDB, target)
+		} else {
+			writer = sink.NewBroadcastWriter(channelDB, target)
+		}
+	}
+
+	if path := cfg.Sink.Shadow.SQLitePath; path != "" && sinkDB != nil {
+		shadowDB, err := sink.OpenSQLite(path)
+		if err != nil {
+			log.Fatalf("harvester: open shadow sqlite: %v", err)
+		}
+		usernames, err := core.ParseUsernameRules(cfg.UsernameRules)
+		if err != nil {
+			log.Fatalf("harvester: %v", err)
+		}
+		shadowDB.SetUsernameNormalizer(usernames)
+		var onFail func()
+		if api != nil {
+			onFail = api.ReportShadowWriteFailure
+		}
+		shadow := sink.NewShadowWriter(shadowDB, onFail)
+		defer func() {
+			if err := shadow.Close(); err != nil {
+				log.Printf("harvester: closing shadow sink: %v", err)
+			}
+			if err := shadowDB.Close(); err != nil {
+				log.Printf("harvester: closing shadow sqlite: %v", err)
+			}
+		}()
+		writer = sink.MultiWriter{writer, shadow}
+		var reporter shadowReporter
+		if api != nil {
+			reporter = api
+		}
+		go runShadowVerify(ctx, sinkDB, shadowDB, cfg.ShadowVerifyInterval(), cfg.ShadowWindow(), reporter)
+		log.Printf("harvester: shadow sqlite sink enabled path=%s verify_every=%s", path, cfg.ShadowVerifyInterval())
+	}
+
+	if cfg.HasSink("mqtt") {
+		mqttSink := sink.NewMQTTPublisher(sink.MQTTOptions{
+			URL:      cfg.Sink.MQTT.URL,
+			ClientID: cfg.Sink.MQTT.ClientID,
+			Username: cfg.Sink.MQTT.Username,
+			Password: cfg.Sink.MQTT.Password,
+			Topic:    cfg.Sink.MQTT.Topic,
+			QoS:      byte(cfg.Sink.MQTT.QoS),
+			Retain:   cfg.Sink.MQTT.Retain,
+		})
+		defer func() {
+			if err := mqttSink.Close(); err != nil {
+				log.Printf("harvester: closing mqtt sink: %v", err)
+			}
+		}()
+		if store != nil {
+			writer = sink.MultiWriter{writer, mqttSink}
+		} else {
+			writer = mqttSink
+		}
+		log.Printf("harvester: mqtt sink enabled topic=%s", cfg.Sink.MQTT.Topic)
+	}
+
+	if cfg.HasSink("opensearch") {
+		indexer := sink.NewOpenSearchIndexer(sink.OpenSearchOptions{
+			URL:      cfg.Sink.OpenSearch.URL,
+			Username: cfg.Sink.OpenSearch.Username,
+			Password: cfg.Sink.OpenSearch.Password,
+			Index:    cfg.Sink.OpenSearch.Index,
+		})
+		defer func() {
+			if err := indexer.Close(); err != nil {
+				log.Printf("harvester: closing opensearch sink: %v", err)
+			}
+		}()
+		if _, none := writer.(noopWriter); none {
+			writer = indexer
+		} else {
+			writer = sink.MultiWriter{writer, indexer}
+		}
+		log.Printf("harvester: opensearch sink enabled index=%s-*", cfg.Sink.OpenSearch.Index)
+	}
+
+	if cfg.HasSink("streamerbot") {
+		sbSink := sink.NewStreamerBotPublisher(sink.StreamerBotOptions{
+			URL:      cfg.Sink.StreamerBot.URL,
+			Password: cfg.Sink.StreamerBot.Password,
+			Action:   cfg.Sink.StreamerBot.Action,
+		})
+		defer func() {
+			if err := sbSink.Close(); err != nil {
+				log.Printf("harvester: closing streamerbot sink: %v", err)
+			}
+		}()
+		if _, none := writer.(noopWriter); none {
+			writer = sbSink
+		} else {
+			writer = sink.MultiWriter{writer, sbSink}
+		}
+		log.Printf("harvester: streamerbot sink enabled url=%s action=%q", cfg.Sink.StreamerBot.URL, cfg.Sink.StreamerBot.Action)
+	}
+
+	if store != nil && (cfg.Batch() > 1 || cfg.FlushInterval() > 0) {
 		buffered = sink.NewBufferedWriter(writer, sink.BufferedOptions{
 			BatchSize:     cfg.Batch(),
 			FlushInterval: cfg.FlushInterval(),
@@ -324,7 +790,203 @@ func main() {
 		}()
 	}
 
-	started := 0
+	health := &receiverHealth{failFast: failFast, cancel: cancel}
+
+	// Assign ULIDs, normalize colours, run the transform script and tag
+	// simulcast mirrors ahead of every sink and trigger so stored rows and
+	// live broadcasts agree. The first step stamps ingest for the watchdog.
+	transforms := []sink.Transformer{
+		sink.TransformFunc(func(msg core.ChatMessage) (core.ChatMessage, bool, error) {
+			health.ingested()
+			return msg, true, nil
+		}),
+		sink.ULIDTransformer(),
+		sink.ColourTransformer(cfg.DefaultColours),
+	}
+	if path := cfg.Transform.Script; path != "" {
+		hook, err := luahook.Load(path, luahook.Options{})
+		if err != nil {
+			log.Fatalf("harvester: transform script: %v", err)
+		}
+		defer func() {
+			if err := hook.Close(); err != nil {
+				log.Printf("harvester: closing transform script: %v", err)
+			}
+		}()
+		transforms = append(transforms, hook)
+		log.Printf("harvester: transform script enabled path=%s", path)
+	}
+	if window := cfg.MirrorWindow(); window > 0 {
+		transforms = append(transforms, sink.NewMirrorDetector(window))
+		log.Printf("harvester: simulcast mirror detection enabled window=%s", window)
+	}
+	if cfg.Spam.Enabled {
+		opts := sink.SpamDetectorOptions{
+			Window:      cfg.SpamWindow(),
+			MinAccounts: cfg.Spam.MinAccounts,
+			OnFlag: func(msg core.ChatMessage, earlier bool) {
+				if api != nil {
+					api.ReportSpamFlagged(msg.Platform)
+				}
+				if earlier && sinkDB != nil {
+					if err := sinkDB.SetSpamScore(ctx, msg); err != nil {
+						log.Printf("harvester: flag spam message: %v", err)
+					}
+				}
+			},
+		}
+		if sinkDB != nil && cfg.Spam.NewAccountDays > 0 {
+			opts.NewAccountAge = time.Duration(cfg.Spam.NewAccountDays) * 24 * time.Hour
+			opts.AccountCreated = func(msg core.ChatMessage) (time.Time, bool) {
+				return sinkDB.AccountCreated(ctx, msg)
+			}
+		}
+		transforms = append(transforms, sink.NewSpamDetector(opts))
+		log.Printf("harvester: spam burst detection enabled window=%s min_accounts=%d", cfg.SpamWindow(), cfg.Spam.MinAccounts)
+	}
+	writer = sink.NewTransformWriter(writer, transforms...)
+
+	if path := strings.TrimSpace(cfg.Triggers.File); path != "" {
+		rules, err := triggers.Load(path)
+		if err != nil {
+			log.Fatalf("harvester: triggers: %v", err)
+		}
+		opts := triggers.Options{
+			Reply: func(ctx context.Context, msg core.ChatMessage, text string) error {
+				if msg.Platform != "Twitch" || twSender == nil {
+					return fmt.Errorf("replies to %s are not supported", msg.Platform)
+				}
+				_, err := twSender.Enqueue(twChannel, text)
+				return err
+			},
+		}
+		if sinkDB != nil {
+			opts.Markers = sinkDB
+			opts.TTS = sinkDB
+		}
+		if api != nil {
+			opts.Alert = func(msg core.ChatMessage, rule, label string) {
+				api.PublishAlert(httpapi.Alert{Type: httpapi.AlertTrigger, Rule: rule, Label: label, Message: msg})
+			}
+		}
+		engine := triggers.New(rules, opts)
+		defer func() {
+			if err := engine.Close(); err != nil {
+				log.Printf("harvester: closing triggers: %v", err)
+			}
+		}()
+		writer = sink.MultiWriter{writer, engine}
+		log.Printf("harvester: chat triggers enabled rules=%d", len(rules))
+	}
+
+	if fields := strings.Fields(cfg.Plugin.Command); len(fields) > 0 {
+		opts := execplugin.Options{
+			Command: fields[0],
+			Args:    fields[1:],
+			Timeout: time.Duration(cfg.Plugin.TimeoutMS) * time.Millisecond,
+		}
+		if api != nil {
+			opts.Reporter = api
+		}
+		plugin, err := execplugin.New(opts)
+		if err != nil {
+			log.Fatalf("harvester: exec plugin: %v", err)
+		}
+		defer func() {
+			if err := plugin.Close(); err != nil {
+				log.Printf("harvester: closing exec plugin: %v", err)
+			}
+		}()
+		writer = sink.NewTransformWriter(writer, plugin)
+		log.Printf("harvester: exec plugin enabled command=%s", fields[0])
+	}
+
+	if cfg.Sink.SQLite.Shared && sinkDB != nil {
+		var reporter externalWriteReporter
+		if api != nil {
+			reporter = api
+		}
+		go runExternalWatch(ctx, sinkDB, externalCheckInterval, reporter)
+		if cfg.Sink.SQLite.WriteToken != "" && api == nil {
+			log.Printf("harvester: GNASTY_SQLITE_WRITE_TOKEN needs the http api; /emit disabled")
+		}
+	}
+
+	if api != nil {
+		emitWriter := writer
+		api.SetEmitter(func(ctx context.Context, msg core.ChatMessage) error {
+			return emitWriter.Write(msg, nil)
+		})
+	}
+
+	sup := &supervisor{dir: cfg.CrashDir, errs: errs}
+	if api != nil {
+		sup.reporter = api
+		health.reporter = api
+		if sinkDB != nil {
+			sinkDB.SetSessionHook(func(st core.StreamState, sessionID string) {
+				api.ReportSession(st.Platform, st.Channel, sessionID, st.State == core.StreamLive, st.Ts)
+			})
+		}
+		if api.MetricsEnabled() {
+			if sinkDB != nil {
+				sinkDB.SetWriteHook(api.ReportIngestLatency)
+			} else if channelDB != nil {
+				channelDB.SetWriteHook(api.ReportIngestLatency)
+			}
+		}
+	}
+
+	if sinkDB != nil {
+		go runHeartbeat(ctx, sinkDB, cfg.HeartbeatInterval(), started)
+		var reporter maintenanceReporter
+		if api != nil {
+			reporter = api
+		}
+		go runMaintenance(ctx, sinkDB, cfg.MaintenanceInterval(), cfg.WALMaxBytes(), reporter)
+	}
+
+	var leader *leaderElector
+	if cfg.Cluster.LeaderElection {
+		if sinkDB == nil {
+			log.Fatal("harvester: leader election requires the sqlite sink")
+		}
+		leader = newLeaderElector(sinkDB, cfg.Cluster.InstanceID, cfg.LeaseTTL())
+		log.Printf("harvester: leader election enabled instance=%s lease_ttl=%s", cfg.Cluster.InstanceID, cfg.LeaseTTL())
+	}
+
+	if cfg.Viewers.Enabled && sinkDB != nil {
+		var sources []viewerSource
+		if login := twitchLogin(twChannel); login != "" {
+			if strings.TrimSpace(twClientID) != "" && strings.TrimSpace(twClientSecret) != "" {
+				sources = append(sources, twitchViewerSource(out.twitchResolver(twClientID, twClientSecret).Stream, login))
+			} else {
+				log.Printf("harvester: twitch viewer samples need client id/secret; skipping")
+			}
+		}
+		if strings.TrimSpace(ytURL) != "" {
+			sources = append(sources, youtubeViewerSource(ytlive.NewResolver(out.youtubeClient()), ytURL))
+		}
+		if len(sources) > 0 {
+			go leader.run(ctx, "viewers", func(ctx context.Context) {
+				runViewerSamples(ctx, sinkDB, cfg.ViewerSampleInterval(), sources)
+			})
+			log.Printf("harvester: viewer sampling enabled every %s (sources=%d)", cfg.ViewerSampleInterval(), len(sources))
+		}
+	}
+
+	backfiller := newBackfiller(cfg, sinkDB, writer, out, twClientID, twClientSecret)
+	if backfiller != nil {
+		scheduleRestartGaps(ctx, sinkDB, cfg.Twitch.Channels, time.Now().UTC(), backfiller.Schedule)
+		go leader.run(ctx, "backfill", func(ctx context.Context) {
+			if err := backfiller.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("backfill: %v", err)
+			}
+		})
+		log.Printf("harvester: backfill enabled (delay=%s max_gap=%s)", cfg.BackfillDelay(), cfg.BackfillMaxGap())
+	}
+
+	receivers := 0
 
 	channel := strings.TrimSpace(twChannel)
 	if channel != "" {
@@ -333,14 +995,16 @@ func main() {
 			log.Fatal("harvester: twitch-nick is required when twitch-channel/token provided")
 		}
 
+		twWriter := limitIngest(writer, "twitch", cfg.Twitch.IngestRate, cfg.IngestOverflowSample, api)
 		handler := func(msg core.ChatMessage, trace *ingesttrace.MessageTrace) {
 			if trace != nil {
 				trace.IncCounter(ingesttrace.StageNormalizedOK)
 				trace.LogTrace(slog.Default(), "normalized_ok")
 			}
 
-			if err := writer.Write(msg, trace); err != nil {
+			if err := twWriter.Write(msg, trace); err != nil {
 				log.Printf("harvester: write twitch message: %v", err)
+				errs.Record("sink", err)
 				if api != nil {
 					api.ReportDBWriteError()
 				}
@@ -384,7 +1048,7 @@ func main() {
 					log.Fatal("harvester: received empty twitch token after refresh")
 				}
 			} else {
-				if err := twitch.Refresh(twClientID, twClientSecret, refreshFilePath, tokenFilePath); err != nil {
+				if err := twitch.Refresh(out.twitchHTTP, twClientID, twClientSecret, refreshFilePath, tokenFilePath); err != nil {
 					log.Fatalf("harvester: twitch refresh: %v", err)
 				}
 
@@ -430,12 +1094,65 @@ func main() {
 
 			state := newTokenState(token)
 
+			if (cfg.Twitch.Polls || cfg.Twitch.Raids || cfg.Twitch.AutoMod || cfg.Twitch.Redemptions) && sinkDB != nil {
+				esCfg := twitcheventsub.Config{
+					ClientID:   twClientID,
+					Channel:    twitchLogin(channel),
+					Token:      state.Current,
+					OnError:    errs.Reporter("twitch-eventsub"),
+					HTTPClient: out.twitchHTTP,
+				}
+				if cfg.Twitch.Polls {
+					esCfg.OnPoll = func(p core.Poll) {
+						if err := sinkDB.RecordPoll(ctx, p); err != nil {
+							log.Printf("harvester: record twitch poll: %v", err)
+						}
+					}
+				}
+				if cfg.Twitch.Raids {
+					esCfg.OnRaid = func(raid core.Raid) { recordRaid(ctx, sinkDB, writer, raid) }
+				}
+				if cfg.Twitch.AutoMod {
+					esCfg.OnAutoMod = func(ev core.AutoModEvent) {
+						if err := sinkDB.RecordAutoMod(ctx, ev); err != nil {
+							log.Printf("harvester: record automod event: %v", err)
+						}
+					}
+				}
+				if cfg.Twitch.Redemptions {
+					esCfg.OnRedemption = func(r core.Redemption) {
+						if err := sinkDB.RecordRedemption(ctx, r); err != nil {
+							log.Printf("harvester: record redemption: %v", err)
+						}
+						if api != nil {
+							api.ReportRedemption(r)
+						}
+					}
+				}
+				eventsub := twitcheventsub.New(esCfg)
+				go leader.run(ctx, "twitch-eventsub", func(ctx context.Context) {
+					health.up("twitch-eventsub")
+					if err := sup.run(ctx, "twitch-eventsub", eventsub.Run); err != nil && !errors.Is(err, context.Canceled) {
+						health.fail("twitch-eventsub", err)
+					}
+				})
+				log.Printf("harvester: twitch eventsub enabled (polls=%t raids=%t automod=%t redemptions=%t)",
+					cfg.Twitch.Polls, cfg.Twitch.Raids, cfg.Twitch.AutoMod, cfg.Twitch.Redemptions)
+			}
+
 			var badgeResolver twitchirc.BadgeResolver
 			if twClientID != "" && twClientSecret != "" {
-				badgeResolver = twitchbadges.NewResolver(twClientID, twClientSecret)
+				badgeResolver = out.twitchResolver(twClientID, twClientSecret)
 				log.Printf("harvester: twitch badge resolver enabled")
 			}
 
+			whispers := cfg.Twitch.Whispers
+			var onPresence func(core.Presence)
+			if sinkDB != nil && cfg.Twitch.Presence {
+				onPresence = newPresenceRecorder(ctx, sinkDB, cfg.Twitch.PresenceSample)
+				log.Printf("harvester: twitch presence tracking enabled (sample=%g)", cfg.Twitch.PresenceSample)
+			}
+
 			cfg := twitchirc.Config{
 				Channel:       channel,
 				Nick:          nick,
@@ -443,6 +1160,33 @@ func main() {
 				UseTLS:        twTLS,
 				TokenProvider: state.Current,
 				Badges:        badgeResolver,
+				Sender:        twSender,
+				OnError:       errs.Reporter("twitch-irc"),
+				OnPresence:    onPresence,
+				Whispers:      whispers,
+				Backoff:       cfg.Twitch.Backoff.Policy(),
+				Dial:          out.twitchDial,
+			}
+			if sinkDB != nil {
+				cfg.OnRoomState = func(channel string, state twitchirc.RoomState) {
+					recordStreamState(ctx, sinkDB, core.StreamState{
+						Platform: "Twitch",
+						Channel:  channel,
+						State:    core.StreamRoomState,
+						Detail:   state.Fields(),
+					})
+				}
+				cfg.OnDelete = func(del core.MessageDeletion) {
+					if _, err := sinkDB.DeleteMessages(ctx, del); err != nil {
+						log.Printf("harvester: delete twitch messages: %v", err)
+					}
+				}
+				cfg.OnRaid = func(raid core.Raid) { recordRaid(ctx, sinkDB, writer, raid) }
+			}
+			if backfiller != nil {
+				cfg.OnGap = func(channel string, start, end time.Time) {
+					backfiller.Schedule(backfill.Gap{Platform: "Twitch", Channel: channel, Start: start, End: end})
+				}
 			}
 
 			if refreshMgr != nil {
@@ -489,72 +1233,114 @@ func main() {
 				}
 			}
 
-			started++
-			go runTwitchWithReload(ctx, cancel, cfg, handler, loader, state, tokenUpdates)
+			receivers++
+			go leader.run(ctx, "twitch:"+strings.ToLower(strings.TrimPrefix(channel, "#")), func(ctx context.Context) {
+				runTwitchWithReload(ctx, health, sup, twPool, cfg, handler, loader, state, tokenUpdates)
+			})
 			log.Printf("harvester: twitch receiver started for #%s", channel)
 		}
 	}
 
 	if ytURL != "" {
+		ytWriter := limitIngest(writer, "youtube", cfg.YouTube.IngestRate, cfg.IngestOverflowSample, api)
 		handler := func(msg core.ChatMessage) {
-			if err := writer.Write(msg, nil); err != nil {
+			if err := ytWriter.Write(msg, nil); err != nil {
 				log.Printf("harvester: write youtube message: %v", err)
+				errs.Record("sink", err)
 				if api != nil {
 					api.ReportDBWriteError()
 				}
 			}
 		}
 
-		resolver := ytlive.NewResolver(nil)
+		resolver := ytlive.NewResolver(out.youtubeClient())
 		retrySeconds := cfg.YouTube.RetrySeconds
 		if retrySeconds <= 0 {
 			retrySeconds = 30
 		}
 		retryDelay := time.Duration(retrySeconds) * time.Second
 
-		started++
-		go func() {
+		receivers++
+		go leader.run(ctx, "youtube:"+ytURL, func(ctx context.Context) {
+			type ytPoller struct {
+				cancel context.CancelFunc
+				done   <-chan struct{}
+			}
 			var (
-				currentCancel context.CancelFunc
-				currentDone   <-chan struct{}
-				currentWatch  string
+				pollers     = map[string]*ytPoller{}
+				primary     string // watch URL the stream session follows
+				currentMeta core.StreamMetadata
 			)
 
-			stopPoller := func() {
-				if currentCancel == nil {
+			stopPoller := func(watchURL string) {
+				p := pollers[watchURL]
+				if p == nil {
 					return
 				}
-				currentCancel()
-				if currentDone != nil {
-					<-currentDone
-				}
-				currentCancel = nil
-				currentDone = nil
-				currentWatch = ""
+				p.cancel()
+				<-p.done
+				delete(pollers, watchURL)
 			}
-			defer stopPoller()
+			defer func() {
+				for watchURL := range pollers {
+					stopPoller(watchURL)
+				}
+			}()
 
 			startPoller := func(watchURL string) {
-				stopPoller()
+				stopPoller(watchURL)
 				pollCtx, pollCancel := context.WithCancel(ctx)
 				done := make(chan struct{})
-				client := ytlive.New(ytlive.Config{
+				ytCfg := ytlive.Config{
 					LiveURL:         watchURL,
 					DumpUnhandled:   cfg.YouTube.DumpUnhandled,
 					PollTimeoutSecs: cfg.YouTube.PollTimeoutSecs,
 					PollIntervalMS:  cfg.YouTube.PollIntervalMS,
 					Debug:           cfg.YouTube.Debug,
-				}, handler)
+					Backoff:         cfg.YouTube.Backoff.Policy(),
+					Transport:       out.youtube,
+					Jar:             out.youtubeJar,
+				}
+				if api != nil {
+					ytCfg.Reporter = api
+				}
+				if sinkDB != nil && cfg.YouTube.CheckpointMaxAgeSecs > 0 {
+					ytCfg.Checkpoints = sinkDB
+					ytCfg.CheckpointMaxAge = time.Duration(cfg.YouTube.CheckpointMaxAgeSecs) * time.Second
+				}
+				client := ytlive.New(ytCfg, handler)
+				client.OnError(errs.Reporter("youtube"))
+				if backfiller != nil {
+					client.OnGap(func(start, end time.Time) {
+						backfiller.Schedule(backfill.Gap{Platform: "YouTube", Channel: watchURL, Start: start, End: end})
+					})
+				}
+				if sinkDB != nil {
+					client.OnUpdate(func(upd core.MessageUpdate) {
+						if _, err := sinkDB.EditMessage(ctx, upd); err != nil {
+							log.Printf("harvester: edit youtube message: %v", err)
+						}
+					})
+					client.OnDelete(func(del core.MessageDeletion) {
+						if _, err := sinkDB.DeleteMessages(ctx, del); err != nil {
+							log.Printf("harvester: delete youtube messages: %v", err)
+						}
+					})
+					client.OnPoll(func(p core.Poll) {
+						p.Channel = ytURL
+						if err := sinkDB.RecordPoll(ctx, p); err != nil {
+							log.Printf("harvester: record youtube poll: %v", err)
+						}
+					})
+				}
+				health.up("youtube")
 				go func() {
 					defer close(done)
-					if err := client.Run(pollCtx); err != nil && !errors.Is(err, context.Canceled) {
-						log.Printf("harvester: youtube client exited: %v", err)
-						cancel()
+					if err := sup.run(pollCtx, "youtube", client.Run); err != nil && !errors.Is(err, context.Canceled) {
+						health.fail("youtube", err)
 					}
 				}()
-				currentCancel = pollCancel
-				currentDone = done
-				currentWatch = watchURL
+				pollers[watchURL] = &ytPoller{cancel: pollCancel, done: done}
 			}
 
 			for {
@@ -562,31 +1348,84 @@ func main() {
 					return
 				}
 
-				res, err := resolver.Resolve(ctx, ytURL)
+				delay := retryDelay
+				selected, upcoming, err := resolveYouTube(ctx, resolver, ytURL, cfg.YouTube.LiveSelect)
 				if err != nil {
 					log.Printf("ytlive: resolve error: %v", err)
 				} else {
-					log.Printf("ytlive: resolved watch=%s chat=%s live=%t", res.WatchURL, res.ChatURL, res.Live)
-					if !res.Live {
-						stopPoller()
-						log.Printf("ytlive: channel %s not live, backing off %s", ytURL, retryDelay)
-					} else if res.WatchURL != "" {
-						if currentWatch != res.WatchURL {
-							if currentWatch == "" {
-								log.Printf("ytlive: live stream changed to %s", res.WatchURL)
-							} else {
-								log.Printf("ytlive: live stream changed from %s to %s", currentWatch, res.WatchURL)
-							}
+					wanted := make(map[string]bool, len(selected))
+					for _, res := range selected {
+						wanted[res.WatchURL] = true
+					}
+					for watchURL := range pollers {
+						if !wanted[watchURL] {
+							log.Printf("ytlive: live stream %s ended", watchURL)
+							stopPoller(watchURL)
+						}
+					}
+					for _, res := range selected {
+						p := pollers[res.WatchURL]
+						if p == nil {
+							log.Printf("ytlive: live stream changed to %s", res.WatchURL)
 							startPoller(res.WatchURL)
-						} else if currentCancel == nil {
+							continue
+						}
+						select {
+						case <-p.done:
 							startPoller(res.WatchURL)
+						default:
+						}
+					}
+
+					switch {
+					case len(selected) == 0:
+						if primary != "" && sinkDB != nil {
+							recordStreamState(ctx, sinkDB, core.StreamState{
+								Platform: "YouTube",
+								Channel:  ytURL,
+								State:    core.StreamEnded,
+								Detail:   map[string]any{"watch_url": primary},
+							})
+						}
+						primary = ""
+						if next, ok := nextUpcoming(upcoming); ok {
+							now := time.Now()
+							delay = upcomingPollDelay(next.ScheduledStart, now, retryDelay)
+							logUpcoming(next, now, delay)
+						} else {
+							log.Printf("ytlive: channel %s not live, backing off %s", ytURL, retryDelay)
+						}
+					case selected[0].WatchURL != primary:
+						res := selected[0]
+						if sinkDB != nil {
+							recordStreamState(ctx, sinkDB, core.StreamState{
+								Platform: "YouTube",
+								Channel:  ytURL,
+								State:    core.StreamLive,
+								Detail: map[string]any{
+									"watch_url":     res.WatchURL,
+									"video_id":      ytlive.VideoID(res.WatchURL),
+									"title":         res.Title,
+									"category":      res.Category,
+									"thumbnail_url": res.ThumbnailURL,
+								},
+							})
+						}
+						primary = res.WatchURL
+						currentMeta = youtubeStreamMetadata(ytURL, res)
+					default:
+						// Titles change mid-stream; keep the session current.
+						if meta := youtubeStreamMetadata(ytURL, selected[0]); sinkDB != nil && meta != currentMeta {
+							if _, err := sinkDB.UpdateSessionMetadata(ctx, meta); err != nil {
+								log.Printf("ytlive: update session metadata: %v", err)
+							} else {
+								currentMeta = meta
+							}
 						}
-					} else {
-						log.Printf("ytlive: resolved live stream without watch url, backing off %s", retryDelay)
 					}
 				}
 
-				timer := time.NewTimer(retryDelay)
+				timer := time.NewTimer(delay)
 				select {
 				case <-ctx.Done():
 					timer.Stop()
@@ -594,23 +1433,83 @@ func main() {
 				case <-timer.C:
 				}
 			}
-		}()
+		})
 		log.Printf("harvester: youtube resolver started for %s", ytURL)
 	}
 
-	if started == 0 {
+	if path := cfg.Replay.File; path != "" {
+		handler := func(msg core.ChatMessage) {
+			if err := writer.Write(msg, nil); err != nil {
+				log.Printf("harvester: write replayed message: %v", err)
+				errs.Record("sink", err)
+				if api != nil {
+					api.ReportDBWriteError()
+				}
+			}
+		}
+		src := replay.New(replay.Config{
+			Path:   path,
+			Speed:  cfg.Replay.Speed,
+			Loop:   cfg.Replay.Loop,
+			MaxGap: time.Duration(cfg.Replay.MaxGapMS) * time.Millisecond,
+		}, handler)
+		receivers++
+		go leader.run(ctx, "replay:"+path, func(ctx context.Context) {
+			health.up("replay")
+			err := sup.run(ctx, "replay", src.Run)
+			switch {
+			case err == nil:
+				log.Printf("harvester: replay of %s finished", path)
+			case !errors.Is(err, context.Canceled):
+				health.fail("replay", err)
+			}
+		})
+		log.Printf("harvester: replay receiver started for %s (speed=%g loop=%t)", path, cfg.Replay.Speed, cfg.Replay.Loop)
+	}
+
+	if receivers == 0 {
 		log.Printf("harvester: ERROR: No receivers configured. Set GNASTY_SINKS=sqlite and GNASTY_SINK_SQLITE_PATH=/data/elora.db (shared with elora-chat).")
 	}
 
+	if sent, err := sdnotify.Notify(sdnotify.Ready); err != nil {
+		log.Printf("harvester: sd_notify ready: %v", err)
+	} else if sent {
+		log.Printf("harvester: notified systemd ready (receivers=%d)", receivers)
+	}
+	if timeout := sdnotify.WatchdogInterval(); timeout > 0 {
+		idle := cfg.WatchdogIdle()
+		check := func() error {
+			if sinkDB != nil {
+				if err := sinkDB.Ping(); err != nil {
+					return err
+				}
+			}
+			return health.check(time.Now(), idle)
+		}
+		log.Printf("harvester: systemd watchdog enabled (timeout=%s)", timeout)
+		go runWatchdog(ctx, timeout, check)
+	}
+
 	<-ctx.Done()
 
+	if _, err := sdnotify.Notify(sdnotify.Stopping); err != nil {
+		log.Printf("harvester: sd_notify stopping: %v", err)
+	}
+
 	if api != nil {
-		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), httpDrain+5*time.Second)
 		if err := api.Shutdown(shutdownCtx); err != nil {
 			log.Printf("harvester: http api shutdown: %v", err)
 		}
 		cancelShutdown()
 	}
+	if bridge != nil {
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := bridge.Shutdown(shutdownCtx); err != nil {
+			log.Printf("harvester: irc bridge shutdown: %v", err)
+		}
+		cancelShutdown()
+	}
 
 	// allow receiver goroutines to finish cleanly
 	time.Sleep(100 * time.Millisecond)
@@ -619,7 +1518,9 @@ func main() {
 
 func runTwitchWithReload(
 	ctx context.Context,
-	cancel context.CancelFunc,
+	health *receiverHealth,
+	sup *supervisor,
+	pool *twitchirc.Pool,
 	baseCfg twitchirc.Config,
 	handler twitchirc.Handler,
 	loader *twitch.FileTokenLoader,
@@ -629,12 +1530,12 @@ func runTwitchWithReload(
 	startClient := func(cfg twitchirc.Config) (context.CancelFunc, <-chan struct{}) {
 		runCtx, runCancel := context.WithCancel(ctx)
 		done := make(chan struct{})
-		client := twitchirc.New(cfg, handler)
+		run := func(ctx context.Context) error { return pool.Run(ctx, cfg, handler) }
+		health.up("twitch-irc")
 		go func() {
 			defer close(done)
-			if err := client.Run(runCtx); err != nil && !errors.Is(err, context.Canceled) {
-				log.Printf("harvester: twitch client exited: %v", err)
-				cancel()
+			if err := sup.run(runCtx, "twitch-irc", run); err != nil && !errors.Is(err, context.Canceled) {
+				health.fail("twitch-irc", err)
 			}
 		}()
 		return runCancel, done
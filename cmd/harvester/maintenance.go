@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/sink"
+)
+
+// maintenanceCheckInterval is how often the WAL size is sampled between
+// scheduled maintenance passes.
+const maintenanceCheckInterval = time.Minute
+
+// maintenanceReporter receives WAL size samples and maintenance outcomes;
+// *httpapi.Server satisfies it.
+type maintenanceReporter interface {
+	ReportWALSize(int64)
+	ReportMaintenance(result string, checkpoint time.Duration)
+}
+
+// runMaintenance checkpoints the WAL and optimizes the database every
+// interval, or sooner once the WAL grows past walLimit, until ctx is
+// cancelled.
+func runMaintenance(ctx context.Context, db *sink.SQLiteSink, interval time.Duration, walLimit int64, report maintenanceReporter) {
+	if interval <= 0 && walLimit <= 0 {
+		return
+	}
+	check := maintenanceCheckInterval
+	if interval > 0 && interval < check {
+		check = interval
+	}
+	ticker := time.NewTicker(check)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		walSize := db.WALSize()
+		if report != nil {
+			report.ReportWALSize(walSize)
+		}
+		now := time.Now()
+		if !maintenanceDue(now, last, interval, walSize, walLimit) {
+			continue
+		}
+		last = now
+
+		res, err := db.Maintain(ctx)
+		result := "ok"
+		switch {
+		case err != nil:
+			result = "error"
+			if ctx.Err() == nil {
+				log.Printf("harvester: sqlite maintenance: %v", err)
+			}
+		case res.Busy:
+			result = "busy"
+		}
+		if report != nil {
+			report.ReportMaintenance(result, res.Checkpoint)
+			report.ReportWALSize(res.WALBytesAfter)
+		}
+		if err == nil {
+			log.Printf("harvester: sqlite maintenance wal_before=%d wal_after=%d checkpoint=%s busy=%t vacuumed_pages=%d",
+				res.WALBytesBefore, res.WALBytesAfter, res.Checkpoint.Round(time.Millisecond), res.Busy, res.VacuumedPages)
+		}
+	}
+}
+
+// maintenanceDue reports whether a pass should run now, either because
+// interval has elapsed since last or because the WAL exceeds walLimit.
+func maintenanceDue(now, last time.Time, interval time.Duration, walSize, walLimit int64) bool {
+	if interval > 0 && now.Sub(last) >= interval {
+		return true
+	}
+	return walLimit > 0 && walSize >= walLimit
+}
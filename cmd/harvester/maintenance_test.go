@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMaintenanceDue(t *testing.T) {
+	last := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name     string
+		elapsed  time.Duration
+		interval time.Duration
+		wal      int64
+		limit    int64
+		want     bool
+	}{
+		{"interval elapsed", time.Hour, time.Hour, 0, 64 << 20, true},
+		{"interval pending", time.Minute, time.Hour, 1 << 20, 64 << 20, false},
+		{"wal over limit", time.Minute, time.Hour, 65 << 20, 64 << 20, true},
+		{"size trigger disabled", time.Minute, time.Hour, 1 << 30, 0, false},
+		{"schedule disabled", 48 * time.Hour, 0, 1 << 20, 64 << 20, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := maintenanceDue(last.Add(tc.elapsed), last, tc.interval, tc.wal, tc.limit); got != tc.want {
+				t.Fatalf("maintenanceDue = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/httpapi"
+	"github.com/you/gnasty-chat/internal/moments"
+	"github.com/you/gnasty-chat/internal/sink"
+)
+
+const (
+	momentsInterval = 30 * time.Second
+	// momentsGrace keeps analyzing a session for a while after it ends so
+	// the final minutes are covered.
+	momentsGrace    = 10 * time.Minute
+	momentsSessions = 20
+	momentTopTerms  = 5
+	momentMaxRows   = 1000
+)
+
+// runMoments periodically scans live and recently ended sessions for chat
+// spikes until ctx is cancelled.
+func runMoments(ctx context.Context, db *sink.SQLiteSink, opts moments.Options) {
+	ticker := time.NewTicker(momentsInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := analyzeMoments(ctx, db, opts, time.Now().UTC()); err != nil && ctx.Err() == nil {
+			log.Printf("harvester: moments: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// analyzeMoments detects and stores moments for every session that is live
+// or ended within momentsGrace, returning how many moments were saved.
+func analyzeMoments(ctx context.Context, db *sink.SQLiteSink, opts moments.Options, now time.Time) (int, error) {
+	sessions, err := db.ListSessions(ctx, httpapi.Filters{Limit: momentsSessions})
+	if err != nil {
+		return 0, err
+	}
+	saved := 0
+	for _, session := range sessions {
+		if !session.Live && (session.EndedAt == nil || session.EndedAt.Before(now.Add(-momentsGrace))) {
+			continue
+		}
+		n, err := analyzeSession(ctx, db, session, opts, now)
+		saved += n
+		if err != nil {
+			return saved, err
+		}
+	}
+	return saved, nil
+}
+
+func analyzeSession(ctx context.Context, db *sink.SQLiteSink, session httpapi.Session, opts moments.Options, now time.Time) (int, error) {
+	counts, err := db.SessionMessageCounts(ctx, session, opts.Bucket, now)
+	if err != nil {
+		return 0, err
+	}
+	spikes := moments.Detect(counts, session.StartedAt, opts)
+	for i, spike := range spikes {
+		start, end := spike.Start, spike.End
+		msgs, err := db.ListMessages(ctx, httpapi.Filters{
+			SessionIDs: []string{session.ID},
+			Since:      &start,
+			Until:      &end,
+			Limit:      momentMaxRows,
+			Order:      httpapi.OrderAsc,
+		})
+		if err != nil {
+			return i, err
+		}
+		emotes, keywords := moments.TopTerms(msgs, momentTopTerms)
+		if err := db.SaveMoment(ctx, httpapi.Moment{
+			SessionID: session.ID,
+			Platform:  session.Platform,
+			Start:     spike.Start,
+			End:       spike.End,
+			Messages:  spike.Messages,
+			PeakRate:  spike.PeakRate,
+			ZScore:    spike.ZScore,
+			Emotes:    momentTerms(emotes),
+			Keywords:  momentTerms(keywords),
+		}); err != nil {
+			return i, err
+		}
+	}
+	return len(spikes), nil
+}
+
+func momentTerms(terms []moments.Term) []httpapi.MomentTerm {
+	out := make([]httpapi.MomentTerm, 0, len(terms))
+	for _, t := range terms {
+		out = append(out, httpapi.MomentTerm{Text: t.Text, Count: t.Count})
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+	"github.com/you/gnasty-chat/internal/moments"
+	"github.com/you/gnasty-chat/internal/sink"
+)
+
+func TestAnalyzeMoments(t *testing.T) {
+	db, err := sink.OpenSQLite(filepath.Join(t.TempDir(), "chat.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	start := time.Now().UTC().Add(-10 * time.Minute).Truncate(10 * time.Second)
+	if err := db.RecordStreamState(ctx, core.StreamState{Platform: "Twitch", Channel: "elora", State: core.StreamLive,
+		Detail: map[string]any{"stream_id": "4001"}, Ts: start}); err != nil {
+		t.Fatalf("record live: %v", err)
+	}
+
+	n := 0
+	write := func(ts time.Time, text, emotes string) {
+		t.Helper()
+		n++
+		msg := core.ChatMessage{ID: fmt.Sprint(n), Platform: "Twitch", Username: fmt.Sprintf("user%d", n), Text: text, EmotesJSON: emotes, Ts: ts}
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	// Two messages per 10s bucket for five minutes, then a burst.
+	for i := 0; i < 30; i++ {
+		write(start.Add(time.Duration(i)*10*time.Second), "chatting", "")
+		write(start.Add(time.Duration(i)*10*time.Second+5*time.Second), "still chatting", "")
+	}
+	burst := start.Add(300 * time.Second)
+	for i := 0; i < 20; i++ {
+		write(burst.Add(time.Duration(i)*400*time.Millisecond), "Kappa no way", `["25:0-4"]`)
+	}
+
+	saved, err := analyzeMoments(ctx, db, moments.DefaultOptions(), time.Now().UTC())
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if saved != 1 {
+		t.Fatalf("expected one moment, got %d", saved)
+	}
+	// A second pass refreshes rather than duplicates.
+	if _, err := analyzeMoments(ctx, db, moments.DefaultOptions(), time.Now().UTC()); err != nil {
+		t.Fatalf("reanalyze: %v", err)
+	}
+
+	list, err := db.ListMoments(ctx, httpapi.Filters{SessionIDs: []string{"twitch:4001"}})
+	if err != nil {
+		t.Fatalf("list moments: %v", err)
+	}
+	if len(list) != 1 {
+		t.Fatalf("expected one stored moment, got %+v", list)
+	}
+	m := list[0]
+	if !m.Start.Equal(burst) || m.Messages != 20 {
+		t.Fatalf("unexpected moment %+v", m)
+	}
+	if len(m.Emotes) == 0 || m.Emotes[0].Text != "Kappa" || m.Emotes[0].Count != 20 {
+		t.Fatalf("unexpected emotes %+v", m.Emotes)
+	}
+	if len(m.Keywords) == 0 || m.Keywords[0].Text != "way" {
+		t.Fatalf("unexpected keywords %+v", m.Keywords)
+	}
+}
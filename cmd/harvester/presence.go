@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+type presenceStore interface {
+	RecordPresence(ctx context.Context, p core.Presence) error
+	ClosePresence(ctx context.Context, platform string, at time.Time) (int64, error)
+}
+
+// newPresenceRecorder closes the Twitch presence intervals left open by the
+// previous run and returns an OnPresence hook storing the sampled chatters.
+func newPresenceRecorder(ctx context.Context, db presenceStore, sample float64) func(core.Presence) {
+	if n, err := db.ClosePresence(ctx, "Twitch", time.Now()); err != nil {
+		log.Printf("presence: close open intervals: %v", err)
+	} else if n > 0 {
+		log.Printf("presence: closed %d intervals left open by the previous run", n)
+	}
+	return func(p core.Presence) {
+		if !presenceSampled(p.Username, sample) {
+			return
+		}
+		if err := db.RecordPresence(ctx, p); err != nil && ctx.Err() == nil {
+			log.Printf("presence: record %s in #%s: %v", p.Username, p.Channel, err)
+		}
+	}
+}
+
+// presenceSampled picks a stable fraction of chatters by hashing their
+// login, so every join and part of a sampled chatter is kept.
+func presenceSampled(username string, sample float64) bool {
+	if sample >= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strings.ToLower(username)))
+	return float64(h.Sum64()>>11)/(1<<53) < sample
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+type fakePresenceStore struct {
+	recorded []core.Presence
+	closed   []string
+}
+
+func (f *fakePresenceStore) RecordPresence(_ context.Context, p core.Presence) error {
+	f.recorded = append(f.recorded, p)
+	return nil
+}
+
+func (f *fakePresenceStore) ClosePresence(_ context.Context, platform string, _ time.Time) (int64, error) {
+	f.closed = append(f.closed, platform)
+	return 0, nil
+}
+
+func TestPresenceRecorderSamples(t *testing.T) {
+	store := &fakePresenceStore{}
+	record := newPresenceRecorder(context.Background(), store, 0.25)
+	if len(store.closed) != 1 || store.closed[0] != "Twitch" {
+		t.Fatalf("expected open twitch intervals to be closed, got %v", store.closed)
+	}
+
+	for i := 0; i < 1000; i++ {
+		user := fmt.Sprintf("user%d", i)
+		record(core.Presence{Platform: "Twitch", Channel: "elora", Username: user, Joined: true})
+		record(core.Presence{Platform: "Twitch", Channel: "elora", Username: user})
+	}
+	if n := len(store.recorded); n < 400 || n > 600 {
+		t.Fatalf("expected about a quarter of 2000 events, got %d", n)
+	}
+	joins := map[string]int{}
+	for _, p := range store.recorded {
+		joins[p.Username]++
+	}
+	for user, n := range joins {
+		if n != 2 {
+			t.Fatalf("expected both events for sampled %s, got %d", user, n)
+		}
+	}
+}
+
+func TestPresenceSampledAll(t *testing.T) {
+	if !presenceSampled("anyone", 1) {
+		t.Fatalf("expected a sample of 1 to keep everyone")
+	}
+	if presenceSampled("Alice", 0.5) != presenceSampled("alice", 0.5) {
+		t.Fatalf("expected sampling to ignore case")
+	}
+}
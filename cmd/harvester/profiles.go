@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/internal/twitchbadges"
+)
+
+const (
+	profileRefreshInterval = time.Minute
+	profileStaleAfter      = 24 * time.Hour
+	profileBatch           = 100
+)
+
+type profileLookup func(ctx context.Context, logins []string) (map[string]twitchbadges.Profile, error)
+
+// runTwitchProfiles keeps Helix profile data for Twitch chatters in the users
+// table fresh until ctx is cancelled.
+func runTwitchProfiles(ctx context.Context, db *sink.SQLiteSink, lookup profileLookup) {
+	ticker := time.NewTicker(profileRefreshInterval)
+	defer ticker.Stop()
+	for {
+		for {
+			n, err := refreshTwitchProfiles(ctx, db, lookup, time.Now())
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("harvester: twitch profiles: %v", err)
+				}
+				break
+			}
+			if n < profileBatch {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshTwitchProfiles fetches one batch of missing or stale profiles and
+// returns how many users were checked.
+func refreshTwitchProfiles(ctx context.Context, db *sink.SQLiteSink, lookup profileLookup, now time.Time) (int, error) {
+	keys, err := db.PendingProfiles(ctx, "Twitch", now.Add(-profileStaleAfter), profileBatch)
+	if err != nil || len(keys) == 0 {
+		return 0, err
+	}
+	found, err := lookup(ctx, keys)
+	if err != nil {
+		return 0, err
+	}
+	profiles := make([]sink.UserProfile, 0, len(found))
+	for _, key := range keys {
+		p, ok := found[key]
+		if !ok {
+			continue
+		}
+		profiles = append(profiles, sink.UserProfile{
+			Key:              key,
+			UserID:           p.ID,
+			AvatarURL:        p.ProfileImageURL,
+			BroadcasterType:  p.BroadcasterType,
+			AccountCreatedAt: p.CreatedAt,
+		})
+	}
+	if err := db.UpdateProfiles(ctx, "Twitch", keys, profiles, now); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/internal/twitchbadges"
+)
+
+func TestRefreshTwitchProfiles(t *testing.T) {
+	db, err := sink.OpenSQLite(filepath.Join(t.TempDir(), "chat.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now().UTC()
+	for _, msg := range []core.ChatMessage{
+		{ID: "1", Platform: "Twitch", Username: "Elora", Text: "hi", Ts: now},
+		{ID: "2", Platform: "Twitch", Username: "ghost", Text: "boo", Ts: now},
+	} {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	created := time.Date(2016, 5, 1, 0, 0, 0, 0, time.UTC)
+	var calls int
+	lookup := func(ctx context.Context, logins []string) (map[string]twitchbadges.Profile, error) {
+		calls++
+		return map[string]twitchbadges.Profile{
+			"elora": {ID: "42", Login: "elora", ProfileImageURL: "https://example.test/elora.png", BroadcasterType: "affiliate", CreatedAt: created},
+		}, nil
+	}
+
+	n, err := refreshTwitchProfiles(context.Background(), db, lookup, now)
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 users checked, got %d", n)
+	}
+
+	user, found, err := db.GetUser(context.Background(), "Twitch", "elora")
+	if err != nil || !found {
+		t.Fatalf("get user: found=%v err=%v", found, err)
+	}
+	if user.AvatarURL != "https://example.test/elora.png" || user.BroadcasterType != "affiliate" || user.AuthorChannelID != "42" {
+		t.Fatalf("profile not applied: %+v", user)
+	}
+	if user.AccountCreatedAt == nil || !user.AccountCreatedAt.Equal(created) {
+		t.Fatalf("unexpected account created at: %v", user.AccountCreatedAt)
+	}
+
+	// Both users were checked, including the one Helix did not know.
+	if n, err := refreshTwitchProfiles(context.Background(), db, lookup, now.Add(time.Hour)); err != nil || n != 0 {
+		t.Fatalf("expected nothing pending, got n=%d err=%v", n, err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected one lookup, got %d", calls)
+	}
+
+	users, err := db.ListUsers(context.Background(), httpapi.Filters{Usernames: []string{"elo"}, Limit: 10})
+	if err != nil || len(users) != 1 || users[0].Key != "elora" {
+		t.Fatalf("list users: %+v err=%v", users, err)
+	}
+}
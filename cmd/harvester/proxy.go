@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/chaos"
+	"github.com/you/gnasty-chat/internal/config"
+	"github.com/you/gnasty-chat/internal/proxy"
+	"github.com/you/gnasty-chat/internal/twitchbadges"
+	"github.com/you/gnasty-chat/internal/ytlive"
+)
+
+// outbound carries the proxied clients and credentials shared by every
+// receiver of a platform.
+type outbound struct {
+	twitchProxy  *url.URL
+	youtubeProxy *url.URL
+	// twitchHTTP serves Helix, OAuth token and EventSub requests.
+	twitchHTTP *http.Client
+	// twitchDial opens Twitch IRC connections.
+	twitchDial proxy.DialFunc
+	// youtube carries watch page, Innertube and resolver requests.
+	youtube http.RoundTripper
+	// youtubeJar holds the signed-in YouTube cookies, if configured.
+	youtubeJar http.CookieJar
+}
+
+// outboundOverride, when set, adjusts every newOutbound result; the
+// end-to-end tests use it to point the receivers at fake platform servers.
+var outboundOverride func(*outbound)
+
+func newOutbound(cfg config.Config) (*outbound, error) {
+	twProxy, err := proxy.Parse(cfg.TwitchProxy())
+	if err != nil {
+		return nil, fmt.Errorf("twitch proxy: %w", err)
+	}
+	ytProxy, err := proxy.Parse(cfg.YouTubeProxy())
+	if err != nil {
+		return nil, fmt.Errorf("youtube proxy: %w", err)
+	}
+	out := &outbound{
+		twitchProxy:  twProxy,
+		youtubeProxy: ytProxy,
+		twitchHTTP:   proxy.Client(twProxy, 15*time.Second),
+		twitchDial:   proxy.Dialer(twProxy, 10*time.Second),
+		youtube:      proxy.Transport(ytProxy),
+	}
+	if cfg.Chaos.Enabled() {
+		inj := chaos.New(chaos.Config{
+			Disconnect:    cfg.Chaos.Disconnect,
+			SlowRead:      cfg.Chaos.SlowRead,
+			SlowReadDelay: time.Duration(cfg.Chaos.SlowReadMS) * time.Millisecond,
+			HTTPError:     cfg.Chaos.HTTPError,
+			Seed:          cfg.Chaos.Seed,
+		})
+		log.Printf("harvester: WARNING: chaos mode is injecting network failures (disconnect=%g slow_read=%g http_error=%g)",
+			cfg.Chaos.Disconnect, cfg.Chaos.SlowRead, cfg.Chaos.HTTPError)
+		out.twitchHTTP.Transport = inj.Transport(out.twitchHTTP.Transport)
+		out.twitchDial = inj.Dial(out.twitchDial)
+		out.youtube = inj.Transport(out.youtube)
+	}
+	if cfg.YouTube.CookieFile != "" {
+		if out.youtubeJar, err = ytlive.LoadCookieJar(cfg.YouTube.CookieFile); err != nil {
+			return nil, err
+		}
+	}
+	if outboundOverride != nil {
+		outboundOverride(out)
+	}
+	return out, nil
+}
+
+// twitchResolver returns a Helix resolver that goes through the Twitch proxy.
+func (o *outbound) twitchResolver(clientID, clientSecret string) *twitchbadges.Resolver {
+	r := twitchbadges.NewResolver(clientID, clientSecret)
+	r.HTTP = o.twitchHTTP
+	return r
+}
+
+// youtubeClient returns an HTTP client for one-off YouTube lookups.
+func (o *outbound) youtubeClient() *http.Client {
+	return &http.Client{Transport: o.youtube, Jar: o.youtubeJar, Timeout: 10 * time.Second}
+}
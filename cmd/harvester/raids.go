@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/sink"
+)
+
+type raidRecorder interface {
+	RecordRaid(ctx context.Context, r core.Raid) (bool, error)
+}
+
+// recordRaid stores raid and, the first time it is seen (IRC and EventSub
+// both report incoming raids), writes a raid message through w so it reaches
+// the archive and stream clients.
+func recordRaid(ctx context.Context, db raidRecorder, w sink.Writer, raid core.Raid) {
+	if raid.Ts.IsZero() {
+		raid.Ts = time.Now().UTC()
+	}
+	added, err := db.RecordRaid(ctx, raid)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("raids: record %s -> %s: %v", raid.FromChannel, raid.ToChannel, err)
+		}
+		return
+	}
+	if !added {
+		return
+	}
+	if err := w.Write(raidMessage(raid), nil); err != nil {
+		log.Printf("raids: write raid message: %v", err)
+	}
+}
+
+// raidMessage is the chat line announcing raid, attributed to the raiding
+// channel.
+func raidMessage(raid core.Raid) core.ChatMessage {
+	from := displayName(raid.FromName, raid.FromChannel)
+	text := raid.Text
+	if text == "" {
+		if raid.Direction == core.RaidOutgoing {
+			text = fmt.Sprintf("%s is raiding %s with %d viewers", from, displayName(raid.ToName, raid.ToChannel), raid.Viewers)
+		} else {
+			text = fmt.Sprintf("%d raiders from %s have joined!", raid.Viewers, from)
+		}
+	}
+	id := raid.ID
+	if id == "" {
+		id = fmt.Sprintf("raid-%s-%s-%d", raid.FromChannel, raid.ToChannel, raid.Ts.UnixMilli())
+	}
+	return core.ChatMessage{
+		ID:          id,
+		Platform:    raid.Platform,
+		Username:    from,
+		Text:        text,
+		Ts:          raid.Ts,
+		MessageType: core.MessageTypeRaid,
+	}
+}
+
+func displayName(name, login string) string {
+	if name != "" {
+		return name
+	}
+	return login
+}
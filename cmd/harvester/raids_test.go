@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+)
+
+type fakeRaidStore struct {
+	seen map[string]bool
+}
+
+func (f *fakeRaidStore) RecordRaid(_ context.Context, r core.Raid) (bool, error) {
+	key := r.FromChannel + ">" + r.ToChannel
+	if f.seen[key] {
+		return false, nil
+	}
+	f.seen[key] = true
+	return true, nil
+}
+
+type collectWriter struct{ msgs []core.ChatMessage }
+
+func (c *collectWriter) Write(msg core.ChatMessage, _ *ingesttrace.MessageTrace) error {
+	c.msgs = append(c.msgs, msg)
+	return nil
+}
+
+func TestRecordRaidWritesOnce(t *testing.T) {
+	store := &fakeRaidStore{seen: map[string]bool{}}
+	w := &collectWriter{}
+	ts := time.Unix(1700000000, 0).UTC()
+	raid := core.Raid{Platform: "Twitch", Direction: core.RaidIncoming, FromChannel: "friend", FromName: "Friend", ToChannel: "elora", Viewers: 42, Ts: ts}
+
+	recordRaid(context.Background(), store, w, raid)
+	recordRaid(context.Background(), store, w, raid)
+	if len(w.msgs) != 1 {
+		t.Fatalf("expected one raid message, got %+v", w.msgs)
+	}
+	msg := w.msgs[0]
+	if msg.MessageType != core.MessageTypeRaid || msg.Username != "Friend" || msg.Text != "42 raiders from Friend have joined!" || msg.ID == "" {
+		t.Fatalf("unexpected raid message %+v", msg)
+	}
+
+	out := raidMessage(core.Raid{Platform: "Twitch", Direction: core.RaidOutgoing, FromChannel: "elora", ToChannel: "pal", ToName: "Pal", Viewers: 300, Ts: ts})
+	if out.Username != "elora" || out.Text != "elora is raiding Pal with 300 viewers" {
+		t.Fatalf("unexpected outgoing raid message %+v", out)
+	}
+}
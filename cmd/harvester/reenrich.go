@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/config"
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/internal/twitchirc"
+	"github.com/you/gnasty-chat/internal/ytlive"
+)
+
+// reenrich is the -reenrich entry point: it re-runs badge and emote enrichment
+// over the configured SQLite archive and returns the process exit code.
+func reenrich(cfg config.Config) int {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	db, err := sink.OpenSQLite(cfg.Sink.SQLite.Path)
+	if err != nil {
+		log.Printf("harvester: reenrich: open sqlite: %v", err)
+		return 1
+	}
+	defer db.Close()
+	if err := migrateSQLite(ctx, db.RawDB()); err != nil {
+		log.Printf("harvester: reenrich: sqlite migrate: %v", err)
+		return 1
+	}
+
+	var badges twitchirc.BadgeResolver
+	if strings.TrimSpace(cfg.Twitch.ClientID) != "" && strings.TrimSpace(cfg.Twitch.ClientSecret) != "" {
+		out, err := newOutbound(cfg)
+		if err != nil {
+			log.Printf("harvester: reenrich: %v", err)
+			return 2
+		}
+		badges = out.twitchResolver(cfg.Twitch.ClientID, cfg.Twitch.ClientSecret)
+	} else {
+		log.Printf("harvester: reenrich: twitch client credentials not set; badge images will not be resolved")
+	}
+
+	start := time.Now()
+	stats, err := runReenrich(ctx, db, badges)
+	log.Printf("harvester: reenrich: scanned=%d updated=%d skipped=%d elapsed=%s",
+		stats.Scanned, stats.Updated, stats.Skipped, time.Since(start).Round(time.Millisecond))
+	if err != nil {
+		log.Printf("harvester: reenrich: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// runReenrich rewrites badges_json/emotes_json and user_id/channel_id for
+// every stored row using the current parsers and, for Twitch, the badge
+// resolver (nil skips image lookup).
+func runReenrich(ctx context.Context, db *sink.SQLiteSink, badges twitchirc.BadgeResolver) (sink.ReenrichStats, error) {
+	return db.Reenrich(ctx, func(ctx context.Context, msg core.ChatMessage) (core.ChatMessage, bool) {
+		switch strings.ToLower(strings.TrimSpace(msg.Platform)) {
+		case "twitch":
+			return twitchirc.Reenrich(ctx, msg, badges)
+		case "youtube":
+			return ytlive.Reenrich(msg)
+		default:
+			return msg, false
+		}
+	})
+}
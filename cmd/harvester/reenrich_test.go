@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/sink"
+)
+
+type stubBadgeResolver struct{}
+
+func (stubBadgeResolver) Enrich(_ context.Context, _ string, badges []core.ChatBadge) []core.ChatBadge {
+	out := make([]core.ChatBadge, len(badges))
+	copy(out, badges)
+	for i := range out {
+		out[i].Images = []core.ChatBadgeImage{{ID: out[i].ID + "-1x", URL: "https://example.test/" + out[i].ID + ".png"}}
+	}
+	return out
+}
+
+func TestRunReenrich(t *testing.T) {
+	db, err := sink.OpenSQLite(filepath.Join(t.TempDir(), "chat.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+
+	twitchRaw, _ := json.Marshal(map[string]any{
+		"tags": map[string]string{"badges": "subscriber/12", "emotes": "25:0-4", "room-id": "1234"},
+		"line": "@badges=subscriber/12 :elora!elora@elora.tmi.twitch.tv PRIVMSG #gnasty :Kappa",
+	})
+	ytRaw, _ := json.Marshal(map[string]any{
+		"id": "yt-1",
+		"message": map[string]any{"runs": []any{
+			map[string]any{"text": "hi "},
+			map[string]any{"emoji": map[string]any{"emojiId": "smile", "shortcuts": []any{":smile:"}}},
+		}},
+	})
+	now := time.Now().UTC()
+	for _, msg := range []core.ChatMessage{
+		{ID: "tw-1", Platform: "Twitch", Username: "elora", Text: "Kappa", Ts: now, RawJSON: string(twitchRaw)},
+		{ID: "yt-1", Platform: "YouTube", Username: "gnasty", Text: "hi :smile:", Ts: now, RawJSON: string(ytRaw)},
+		{ID: "yt-2", Platform: "YouTube", Username: "noraw", Text: "plain", Ts: now},
+	} {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write %s: %v", msg.ID, err)
+		}
+	}
+
+	stats, err := runReenrich(context.Background(), db, stubBadgeResolver{})
+	if err != nil {
+		t.Fatalf("reenrich: %v", err)
+	}
+	if stats.Scanned != 3 || stats.Updated != 2 || stats.Skipped != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	var badges, emotes string
+	if err := db.RawDB().QueryRow(`SELECT badges_json, emotes_json FROM messages WHERE platform_msg_id = 'tw-1'`).Scan(&badges, &emotes); err != nil {
+		t.Fatalf("read twitch row: %v", err)
+	}
+	if !strings.Contains(badges, "https://example.test/subscriber.png") {
+		t.Fatalf("expected enriched twitch badge images, got %s", badges)
+	}
+	if emotes != `["25:0-4"]` {
+		t.Fatalf("unexpected twitch emotes: %s", emotes)
+	}
+	if err := db.RawDB().QueryRow(`SELECT emotes_json FROM messages WHERE platform_msg_id = 'yt-1'`).Scan(&emotes); err != nil {
+		t.Fatalf("read youtube row: %v", err)
+	}
+	if !strings.Contains(emotes, `"smile"`) {
+		t.Fatalf("expected youtube emote payload, got %s", emotes)
+	}
+
+	again, err := runReenrich(context.Background(), db, stubBadgeResolver{})
+	if err != nil {
+		t.Fatalf("second reenrich: %v", err)
+	}
+	if again.Updated != 0 {
+		t.Fatalf("expected second pass to be a no-op, got %+v", again)
+	}
+}
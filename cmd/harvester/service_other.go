@@ -0,0 +1,15 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// serviceCommand is only available on Windows; elsewhere use systemd or a
+// container supervisor.
+func serviceCommand(args []string) int {
+	fmt.Fprintln(os.Stderr, "harvester: the service command is only supported on Windows")
+	return 2
+}
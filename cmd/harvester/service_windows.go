@@ -0,0 +1,233 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const defaultServiceName = "gnasty-harvester"
+
+// serviceCommand manages the harvester as a Windows service. Flags after
+// the service flags (or after "--") are passed to run when the service starts.
+func serviceCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: harvester service install|uninstall|start|stop|run [-name name] [-log-file path] [run flags...]")
+		return 2
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("service "+action, flag.ExitOnError)
+	name := fs.String("name", defaultServiceName, "Windows service name")
+	logFile := fs.String("log-file", "", "Append the service log to this file (default: harvester.log next to the executable)")
+	_ = fs.Parse(args[1:])
+	runArgs := fs.Args()
+
+	var err error
+	switch action {
+	case "install":
+		err = installService(*name, *logFile, runArgs)
+	case "uninstall":
+		err = uninstallService(*name)
+	case "start":
+		err = startService(*name)
+	case "stop":
+		err = stopService(*name)
+	case "run":
+		err = runService(*name, *logFile, runArgs)
+	default:
+		fmt.Fprintf(os.Stderr, "harvester: unknown service action %q\n", action)
+		return 2
+	}
+	if err != nil {
+		log.Printf("harvester: service %s: %v", action, err)
+		return 1
+	}
+	return 0
+}
+
+// runningAsService reports whether the process was started by the Windows
+// service control manager.
+func runningAsService() bool {
+	ok, err := svc.IsWindowsService()
+	return err == nil && ok
+}
+
+func installService(name, logFile string, runArgs []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if logFile == "" {
+		logFile = filepath.Join(filepath.Dir(exe), "harvester.log")
+	}
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", name)
+	}
+
+	serviceArgs := append([]string{"service", "run", "-name", name, "-log-file", logFile, "--"}, runArgs...)
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: "gnasty-chat harvester",
+		Description: "Archives Twitch and YouTube live chat.",
+		StartType:   mgr.StartAutomatic,
+	}, serviceArgs...)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	// Restart after crashes; the harvester exits non-zero on fatal errors.
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: 30 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+	}, uint32((24 * time.Hour).Seconds())); err != nil {
+		log.Printf("harvester: service install: recovery actions: %v", err)
+	}
+	if err := eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		log.Printf("harvester: service install: event log source: %v", err)
+	}
+	log.Printf("harvester: service %s installed (log file %s)", name, logFile)
+	return nil
+}
+
+func uninstallService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+	defer s.Close()
+	if err := s.Delete(); err != nil {
+		return err
+	}
+	_ = eventlog.Remove(name)
+	log.Printf("harvester: service %s removed", name)
+	return nil
+}
+
+func startService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+	defer s.Close()
+	return s.Start()
+}
+
+func stopService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+	defer s.Close()
+	status, err := s.Control(svc.Stop)
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(30 * time.Second)
+	for status.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for the service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if status, err = s.Query(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runService(name, logFile string, runArgs []string) error {
+	if !runningAsService() {
+		return errors.New("not started by the service control manager; use \"harvester run\" from a console")
+	}
+	if logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		log.SetOutput(f)
+	}
+	if elog, err := eventlog.Open(name); err == nil {
+		defer elog.Close()
+		_ = elog.Info(1, fmt.Sprintf("%s starting", name))
+		defer func() { _ = elog.Info(1, fmt.Sprintf("%s stopped", name)) }()
+	}
+	return svc.Run(name, &harvesterService{args: runArgs})
+}
+
+// harvesterService runs the harvester until the service control manager
+// asks it to stop or the machine shuts down.
+type harvesterService struct {
+	args []string
+}
+
+func (h *harvesterService) Execute(_ []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runHarvester(ctx, h.args)
+	}()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case <-done:
+			// The harvester only returns on its own after a failure.
+			return false, 1
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				log.Printf("harvester: service %s requested, shutting down", serviceCmdName(req.Cmd))
+				changes <- svc.Status{State: svc.StopPending, WaitHint: 15000}
+				cancel()
+				<-done
+				return false, 0
+			}
+		}
+	}
+}
+
+func serviceCmdName(cmd svc.Cmd) string {
+	if cmd == svc.Shutdown {
+		return "shutdown"
+	}
+	return "stop"
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/sink"
+)
+
+const (
+	// shadowLookback is how much history each verification pass compares.
+	shadowLookback = time.Hour
+	// shadowSettle leaves the newest messages out of a pass, since some
+	// may still be buffered or queued for the secondary.
+	shadowSettle = time.Minute
+	// shadowLogLimit bounds the divergent windows logged per pass.
+	shadowLogLimit = 5
+)
+
+// shadowReporter receives verification results; *httpapi.Server satisfies
+// it.
+type shadowReporter interface {
+	ReportShadowVerify(windows, divergent int)
+}
+
+// runShadowVerify compares the primary and shadow databases every interval
+// until ctx is cancelled.
+func runShadowVerify(ctx context.Context, primary, shadow sink.Digester, interval, width time.Duration, report shadowReporter) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := verifyShadow(ctx, primary, shadow, time.Now(), width, report); err != nil && ctx.Err() == nil {
+			log.Printf("harvester: shadow verify: %v", err)
+		}
+	}
+}
+
+// verifyShadow runs one pass over the windows of width ending shadowSettle
+// before now, logging each divergent window.
+func verifyShadow(ctx context.Context, primary, shadow sink.Digester, now time.Time, width time.Duration, report shadowReporter) error {
+	since, until := shadowRange(now, width)
+	diffs, windows, err := sink.VerifyShadow(ctx, primary, shadow, since, until, width)
+	if err != nil {
+		return err
+	}
+	if report != nil {
+		report.ReportShadowVerify(windows, len(diffs))
+	}
+	if len(diffs) == 0 {
+		log.Printf("harvester: shadow verify ok windows=%d since=%s", windows, since.Format(time.RFC3339))
+		return nil
+	}
+	log.Printf("harvester: shadow verify found %d of %d windows diverging since %s", len(diffs), windows, since.Format(time.RFC3339))
+	for i, d := range diffs {
+		if i == shadowLogLimit {
+			log.Printf("harvester: shadow verify: %d more divergent windows", len(diffs)-i)
+			break
+		}
+		log.Printf("harvester: shadow window %s primary=%d shadow=%d checksums_differ=%t",
+			d.Start.Format(time.RFC3339), d.PrimaryCount, d.SecondaryCount, d.ChecksumsDiffer)
+	}
+	return nil
+}
+
+// shadowRange returns the window-aligned range a pass at now compares, so
+// consecutive passes cut the same windows.
+func shadowRange(now time.Time, width time.Duration) (since, until time.Time) {
+	until = now.UTC().Add(-shadowSettle).Truncate(width)
+	return until.Add(-shadowLookback).Truncate(width), until
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestShadowRange(t *testing.T) {
+	now := time.Date(2026, 10, 15, 20, 31, 45, 0, time.UTC)
+	since, until := shadowRange(now, 5*time.Minute)
+	if want := time.Date(2026, 10, 15, 20, 30, 0, 0, time.UTC); !until.Equal(want) {
+		t.Fatalf("until = %s, want %s", until, want)
+	}
+	if want := time.Date(2026, 10, 15, 19, 30, 0, 0, time.UTC); !since.Equal(want) {
+		t.Fatalf("since = %s, want %s", since, want)
+	}
+}
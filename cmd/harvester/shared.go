@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const (
+	// externalCheckInterval is how often shared mode looks for rows written
+	// by other processes.
+	externalCheckInterval = 30 * time.Second
+	// externalQuietAfter is how long without external rows before the
+	// other writer is reported as gone quiet.
+	externalQuietAfter = 10 * time.Minute
+)
+
+// externalWriteStore is the part of *sink.SQLiteSink used to detect other
+// writers.
+type externalWriteStore interface {
+	ExternalWrites(ctx context.Context, after int64) (int64, int64, error)
+}
+
+// externalWriteReporter receives counts of externally written messages;
+// *httpapi.Server satisfies it.
+type externalWriteReporter interface {
+	ReportExternalWrites(n int64)
+}
+
+// externalWatch tracks whether another process is writing messages to the
+// shared database.
+type externalWatch struct {
+	store    externalWriteStore
+	report   externalWriteReporter
+	after    int64
+	active   bool
+	lastSeen time.Time
+}
+
+// runExternalWatch checks for external writers every interval until ctx is
+// cancelled. The first check covers the whole archive so the log says
+// whether the file has been shared before.
+func runExternalWatch(ctx context.Context, store externalWriteStore, interval time.Duration, report externalWriteReporter) {
+	w := &externalWatch{store: store, report: report}
+	n, last, err := store.ExternalWrites(ctx, 0)
+	if err != nil {
+		log.Printf("harvester: shared sqlite: %v", err)
+	} else {
+		w.after = last
+		log.Printf("harvester: shared sqlite: %d stored messages were written by other processes", n)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := w.check(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			log.Printf("harvester: shared sqlite: %v", err)
+		}
+	}
+}
+
+// check counts the rows written since the previous check, logging when an
+// external writer appears and when it goes quiet.
+func (w *externalWatch) check(ctx context.Context, now time.Time) error {
+	n, last, err := w.store.ExternalWrites(ctx, w.after)
+	if err != nil {
+		return err
+	}
+	w.after = last
+	if n > 0 {
+		if w.report != nil {
+			w.report.ReportExternalWrites(n)
+		}
+		if !w.active {
+			log.Printf("harvester: shared sqlite: another process is writing messages (%d since the last check)", n)
+		}
+		w.active, w.lastSeen = true, now
+		return nil
+	}
+	if w.active && now.Sub(w.lastSeen) >= externalQuietAfter {
+		log.Printf("harvester: shared sqlite: no external writes since %s", w.lastSeen.Format(time.RFC3339))
+		w.active = false
+	}
+	return nil
+}
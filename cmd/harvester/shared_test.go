@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type fakeExternalStore struct {
+	rows  []int64 // external row counts returned by successive calls
+	after []int64
+}
+
+func (f *fakeExternalStore) ExternalWrites(ctx context.Context, after int64) (int64, int64, error) {
+	f.after = append(f.after, after)
+	n := f.rows[0]
+	f.rows = f.rows[1:]
+	return n, after + 10, nil
+}
+
+type countingReporter struct{ total int64 }
+
+func (r *countingReporter) ReportExternalWrites(n int64) { r.total += n }
+
+func TestExternalWatch(t *testing.T) {
+	store := &fakeExternalStore{rows: []int64{3, 0, 0, 2}}
+	report := &countingReporter{}
+	w := &externalWatch{store: store, report: report, after: 5}
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	for i, at := range []time.Time{now, now.Add(time.Minute), now.Add(externalQuietAfter), now.Add(time.Hour)} {
+		if err := w.check(context.Background(), at); err != nil {
+			t.Fatalf("check %d: %v", i, err)
+		}
+		if i == 1 && !w.active {
+			t.Fatalf("expected the writer to stay active within %s", externalQuietAfter)
+		}
+		if i == 2 && w.active {
+			t.Fatalf("expected the writer to go quiet after %s", externalQuietAfter)
+		}
+	}
+	if !w.active || report.total != 5 {
+		t.Fatalf("expected 5 external writes and an active writer, got %d %v", report.total, w.active)
+	}
+	if want := []int64{5, 15, 25, 35}; len(store.after) != 4 || store.after[1] != want[1] || store.after[3] != want[3] {
+		t.Fatalf("watermark not advanced: %v", store.after)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/internal/twitchbadges"
+	"github.com/you/gnasty-chat/internal/ytlive"
+)
+
+const twitchStreamPollInterval = time.Minute
+
+type streamLookup func(ctx context.Context, login string) (twitchbadges.Stream, bool, error)
+
+// recordStreamState stores a live/ended/roomstate transition. Failures are
+// logged rather than interrupting ingest.
+func recordStreamState(ctx context.Context, db *sink.SQLiteSink, st core.StreamState) {
+	if st.Ts.IsZero() {
+		st.Ts = time.Now().UTC()
+	}
+	if err := db.RecordStreamState(ctx, st); err != nil && ctx.Err() == nil {
+		log.Printf("streams: record %s %s %s: %v", st.Platform, st.Channel, st.State, err)
+	}
+}
+
+// twitchLogin converts a configured channel ("#Elora") to the login used in
+// IRC and Helix ("elora").
+func twitchLogin(channel string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(channel), "#"))
+}
+
+// twitchStreamMonitor turns Helix stream polling into live/ended transitions.
+type twitchStreamMonitor struct {
+	db      *sink.SQLiteSink
+	lookup  streamLookup
+	login   string
+	current string // Helix stream ID while live
+	checked bool
+	meta    core.StreamMetadata // last stored title/category/thumbnail
+}
+
+// runTwitchStreamStatus polls Helix for the channel's live status until ctx
+// is cancelled so messages are grouped into broadcast sessions.
+func runTwitchStreamStatus(ctx context.Context, db *sink.SQLiteSink, lookup streamLookup, login string) {
+	m := &twitchStreamMonitor{db: db, lookup: lookup, login: login}
+	ticker := time.NewTicker(twitchStreamPollInterval)
+	defer ticker.Stop()
+	for {
+		if err := m.poll(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("harvester: twitch stream status: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func twitchStreamMetadata(login string, stream twitchbadges.Stream) core.StreamMetadata {
+	return core.StreamMetadata{
+		Platform:     "Twitch",
+		Channel:      login,
+		Title:        stream.Title,
+		Category:     stream.GameName,
+		ThumbnailURL: stream.ThumbnailURL,
+	}
+}
+
+func (m *twitchStreamMonitor) poll(ctx context.Context) error {
+	stream, live, err := m.lookup(ctx, m.login)
+	if err != nil {
+		return err
+	}
+	switch {
+	case live && stream.ID != m.current:
+		ts := stream.StartedAt
+		if ts.IsZero() {
+			ts = time.Now().UTC()
+		}
+		log.Printf("harvester: twitch: %s is live (stream %s)", m.login, stream.ID)
+		recordStreamState(ctx, m.db, core.StreamState{
+			Platform: "Twitch",
+			Channel:  m.login,
+			State:    core.StreamLive,
+			Detail: map[string]any{
+				"stream_id":     stream.ID,
+				"title":         stream.Title,
+				"category":      stream.GameName,
+				"thumbnail_url": stream.ThumbnailURL,
+			},
+			Ts: ts,
+		})
+		m.current = stream.ID
+		m.meta = twitchStreamMetadata(m.login, stream)
+	case live:
+		// Titles and categories change mid-stream; keep the session current.
+		if meta := twitchStreamMetadata(m.login, stream); meta != m.meta {
+			if _, err := m.db.UpdateSessionMetadata(ctx, meta); err != nil {
+				return err
+			}
+			m.meta = meta
+		}
+	case !live && (m.current != "" || !m.checked):
+		// The first offline poll also closes a session left open by a
+		// previous run that stopped mid-stream.
+		if m.current != "" {
+			log.Printf("harvester: twitch: %s went offline", m.login)
+		}
+		recordStreamState(ctx, m.db, core.StreamState{
+			Platform: "Twitch",
+			Channel:  m.login,
+			State:    core.StreamEnded,
+		})
+		m.current = ""
+		m.meta = core.StreamMetadata{}
+	}
+	m.checked = true
+	return nil
+}
+
+func youtubeStreamMetadata(channel string, res ytlive.ResolveResult) core.StreamMetadata {
+	return core.StreamMetadata{
+		Platform:     "YouTube",
+		Channel:      channel,
+		Title:        res.Title,
+		Category:     res.Category,
+		ThumbnailURL: res.ThumbnailURL,
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/internal/twitchbadges"
+)
+
+func TestTwitchStreamMonitorSessions(t *testing.T) {
+	db, err := sink.OpenSQLite(filepath.Join(t.TempDir(), "chat.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	var (
+		live   bool
+		stream = twitchbadges.Stream{ID: "4001", Login: "elora", Title: "Tuesday stream", GameName: "Just Chatting", StartedAt: time.Now().UTC().Add(-time.Minute)}
+	)
+	lookup := func(ctx context.Context, login string) (twitchbadges.Stream, bool, error) {
+		if login != "elora" {
+			t.Fatalf("unexpected login %q", login)
+		}
+		return stream, live, nil
+	}
+	m := &twitchStreamMonitor{db: db, lookup: lookup, login: twitchLogin("#Elora")}
+
+	if err := m.poll(ctx); err != nil {
+		t.Fatalf("offline poll: %v", err)
+	}
+	live = true
+	if err := m.poll(ctx); err != nil {
+		t.Fatalf("live poll: %v", err)
+	}
+	// A mid-stream title/category change updates the open session.
+	stream.Title, stream.GameName = "Tuesday stream: ranked", "Chess"
+	stream.ThumbnailURL = "https://static-cdn.jtvnw.net/previews-ttv/live_user_elora-1280x720.jpg"
+	if err := m.poll(ctx); err != nil {
+		t.Fatalf("metadata poll: %v", err)
+	}
+	if err := db.Write(core.ChatMessage{ID: "1", Platform: "Twitch", Username: "alice", Text: "hype", Ts: time.Now().UTC()}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	live = false
+	if err := m.poll(ctx); err != nil {
+		t.Fatalf("ended poll: %v", err)
+	}
+
+	sessions, err := db.ListSessions(ctx, httpapi.Filters{})
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("expected one session, got %+v", sessions)
+	}
+	s := sessions[0]
+	if s.ID != "twitch:4001" || s.Title != "Tuesday stream: ranked" || s.Live || s.Messages != 1 {
+		t.Fatalf("unexpected session %+v", s)
+	}
+	if s.Category != "Chess" || s.ThumbnailURL != stream.ThumbnailURL {
+		t.Fatalf("expected refreshed metadata, got category=%q thumbnail=%q", s.Category, s.ThumbnailURL)
+	}
+}
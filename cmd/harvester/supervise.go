@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/errlog"
+)
+
+const (
+	crashMinBackoff = time.Second
+	crashMaxBackoff = time.Minute
+)
+
+// crashReporter counts receiver panics; *httpapi.Server satisfies it.
+type crashReporter interface {
+	ReportReceiverCrash(receiver string)
+}
+
+// supervisor isolates receivers from each other: a panic inside one (in its
+// parser or a message callback) is recovered, written to a crash report and
+// counted, and the receiver is restarted with backoff. A nil supervisor runs
+// receivers unprotected.
+type supervisor struct {
+	// dir receives one crash report per panic; empty only logs the stack.
+	dir      string
+	reporter crashReporter
+	errs     *errlog.Log
+	// backoff is the first restart delay; zero means one second.
+	backoff time.Duration
+}
+
+// run calls fn until it returns without panicking, then returns its error.
+// The backoff between restarts doubles up to a minute and resets once fn has
+// stayed up for that long.
+func (s *supervisor) run(ctx context.Context, name string, fn func(context.Context) error) error {
+	if s == nil {
+		return fn(ctx)
+	}
+	initial := s.backoff
+	if initial <= 0 {
+		initial = crashMinBackoff
+	}
+	backoff := initial
+	for {
+		started := time.Now()
+		err, crashed := s.call(ctx, name, fn)
+		if !crashed {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if time.Since(started) >= crashMaxBackoff {
+			backoff = initial
+		}
+		log.Printf("harvester: %s crashed; restarting in %s", name, backoff)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > crashMaxBackoff {
+			backoff = crashMaxBackoff
+		}
+	}
+}
+
+// call runs fn once, converting a panic into a crash report.
+func (s *supervisor) call(ctx context.Context, name string, fn func(context.Context) error) (err error, crashed bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			crashed = true
+			s.crash(name, r, debug.Stack())
+		}
+	}()
+	return fn(ctx), false
+}
+
+func (s *supervisor) crash(name string, value any, stack []byte) {
+	now := time.Now().UTC()
+	log.Printf("harvester: %s panicked: %v\n%s", name, value, stack)
+	s.errs.Record(name, core.NewError(core.ErrorPanic, fmt.Errorf("panic: %v", value)))
+	if s.reporter != nil {
+		s.reporter.ReportReceiverCrash(name)
+	}
+	if s.dir == "" {
+		return
+	}
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		log.Printf("harvester: crash report: %v", err)
+		return
+	}
+	file := fmt.Sprintf("crash-%s-%s.log", strings.NewReplacer("/", "_", ":", "_").Replace(name), now.Format("20060102T150405.000000000Z"))
+	report := fmt.Sprintf("receiver: %s\ntime: %s\npanic: %v\n\n%s", name, now.Format(time.RFC3339Nano), value, stack)
+	path := filepath.Join(s.dir, file)
+	if err := os.WriteFile(path, []byte(report), 0o644); err != nil {
+		log.Printf("harvester: crash report: %v", err)
+		return
+	}
+	log.Printf("harvester: crash report written to %s", path)
+}
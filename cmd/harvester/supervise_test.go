@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/errlog"
+)
+
+type crashCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func (c *crashCounter) ReportReceiverCrash(receiver string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = map[string]int{}
+	}
+	c.counts[receiver]++
+}
+
+func TestSupervisorRecoversAndRestarts(t *testing.T) {
+	dir := t.TempDir()
+	counter := &crashCounter{}
+	errs := errlog.New(10)
+	sup := &supervisor{dir: dir, reporter: counter, errs: errs, backoff: time.Millisecond}
+
+	calls := 0
+	done := errors.New("done")
+	err := sup.run(context.Background(), "youtube", func(context.Context) error {
+		calls++
+		if calls < 3 {
+			var payload map[string]any
+			_ = payload["renderer"].(map[string]any)
+		}
+		return done
+	})
+	if !errors.Is(err, done) || calls != 3 {
+		t.Fatalf("run = %v after %d calls, want done after 3", err, calls)
+	}
+	if counter.counts["youtube"] != 2 {
+		t.Fatalf("crashes = %v", counter.counts)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read crash dir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("crash reports = %d, want 2", len(entries))
+	}
+	report, err := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	if !strings.HasPrefix(entries[0].Name(), "crash-youtube-") || !strings.Contains(string(report), "receiver: youtube") ||
+		!strings.Contains(string(report), "supervise_test.go") {
+		t.Fatalf("unexpected report %s:\n%s", entries[0].Name(), report)
+	}
+
+	snap := errs.Snapshot()
+	if snap.Counts[core.ErrorPanic] != 2 || len(snap.Errors) != 1 || snap.Errors[0].Source != "youtube" {
+		t.Fatalf("error log = %+v", snap)
+	}
+}
+
+func TestSupervisorStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sup := &supervisor{backoff: time.Hour}
+	result := make(chan error, 1)
+	go func() {
+		result <- sup.run(ctx, "twitch-irc", func(context.Context) error { panic("boom") })
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("run = %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("supervisor did not stop after cancel")
+	}
+
+	var nilSup *supervisor
+	if err := nilSup.run(context.Background(), "x", func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("nil supervisor = %v", err)
+	}
+}
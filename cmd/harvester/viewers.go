@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/internal/ytlive"
+)
+
+// viewerSource samples the concurrent viewers of one watched source.
+// count reports ok=false while the source is offline.
+type viewerSource struct {
+	platform string
+	channel  string
+	count    func(ctx context.Context) (viewers int, ok bool, err error)
+}
+
+// twitchViewerSource reads viewer_count from the Helix streams endpoint.
+func twitchViewerSource(lookup streamLookup, login string) viewerSource {
+	return viewerSource{
+		platform: "Twitch",
+		channel:  login,
+		count: func(ctx context.Context) (int, bool, error) {
+			stream, live, err := lookup(ctx, login)
+			return stream.ViewerCount, live, err
+		},
+	}
+}
+
+// youtubeViewerSource reads the "watching now" count from the live watch
+// page that rawURL resolves to.
+func youtubeViewerSource(resolver *ytlive.Resolver, rawURL string) viewerSource {
+	return viewerSource{
+		platform: "YouTube",
+		channel:  rawURL,
+		count: func(ctx context.Context) (int, bool, error) {
+			res, err := resolver.Resolve(ctx, rawURL)
+			if err != nil {
+				return 0, false, err
+			}
+			// A zero count means the page did not carry one.
+			return res.Viewers, res.Live && res.Viewers > 0, nil
+		},
+	}
+}
+
+// runViewerSamples records a viewer sample for every live source each
+// interval until ctx is cancelled.
+func runViewerSamples(ctx context.Context, db *sink.SQLiteSink, interval time.Duration, sources []viewerSource) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		sampleViewers(ctx, db, sources, time.Now().UTC())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sampleViewers stores one sample per live source, returning how many were
+// recorded. Lookup failures are logged and skipped.
+func sampleViewers(ctx context.Context, db *sink.SQLiteSink, sources []viewerSource, now time.Time) int {
+	recorded := 0
+	for _, src := range sources {
+		viewers, ok, err := src.count(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("harvester: viewers: %s %s: %v", src.platform, src.channel, err)
+			}
+			continue
+		}
+		if !ok {
+			continue
+		}
+		sample := core.ViewerSample{Platform: src.platform, Channel: src.channel, Viewers: viewers, Ts: now}
+		if err := db.RecordViewerSample(ctx, sample); err != nil {
+			if ctx.Err() == nil {
+				log.Printf("harvester: viewers: record %s %s: %v", src.platform, src.channel, err)
+			}
+			continue
+		}
+		recorded++
+	}
+	return recorded
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/httpapi"
+	"github.com/you/gnasty-chat/internal/sink"
+	"github.com/you/gnasty-chat/internal/twitchbadges"
+)
+
+func TestSampleViewers(t *testing.T) {
+	db, err := sink.OpenSQLite(filepath.Join(t.TempDir(), "chat.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	live := true
+	lookup := func(ctx context.Context, login string) (twitchbadges.Stream, bool, error) {
+		return twitchbadges.Stream{ID: "4001", ViewerCount: 250}, live, nil
+	}
+	failing := viewerSource{platform: "YouTube", channel: "@elora", count: func(ctx context.Context) (int, bool, error) {
+		return 0, false, errors.New("resolve failed")
+	}}
+	sources := []viewerSource{twitchViewerSource(lookup, "elora"), failing}
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if n := sampleViewers(ctx, db, sources, now); n != 1 {
+		t.Fatalf("expected 1 sample while live, got %d", n)
+	}
+	live = false
+	if n := sampleViewers(ctx, db, sources, now.Add(time.Minute)); n != 0 {
+		t.Fatalf("expected no samples while offline, got %d", n)
+	}
+
+	samples, err := db.ListViewerSamples(ctx, httpapi.Filters{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(samples) != 1 || samples[0].Platform != "Twitch" || samples[0].Channel != "elora" || samples[0].Viewers != 250 || !samples[0].Ts.Equal(now) {
+		t.Fatalf("unexpected samples %+v", samples)
+	}
+}
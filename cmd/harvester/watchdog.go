@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/sdnotify"
+)
+
+// runWatchdog pings the systemd watchdog at half the configured timeout for
+// as long as check succeeds. A failing check withholds the ping so systemd
+// restarts the unit once the timeout elapses.
+func runWatchdog(ctx context.Context, timeout time.Duration, check func() error) {
+	if timeout <= 0 {
+		return
+	}
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if check != nil {
+				if err := check(); err != nil {
+					log.Printf("harvester: watchdog check failed, withholding ping: %v", err)
+					continue
+				}
+			}
+			if _, err := sdnotify.Notify(sdnotify.Watchdog); err != nil {
+				log.Printf("harvester: watchdog notify: %v", err)
+			}
+		}
+	}
+}
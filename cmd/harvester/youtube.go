@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/ytlive"
+)
+
+// youtubeResolver is the part of ytlive.Resolver the YouTube receiver uses.
+type youtubeResolver interface {
+	Resolve(ctx context.Context, raw string) (ytlive.ResolveResult, error)
+	ResolveChannel(ctx context.Context, raw string) ([]ytlive.ResolveResult, error)
+}
+
+// resolveYouTube returns the live streams to poll for target and any
+// upcoming ones. Channel targets are looked up on the streams tab, so
+// simultaneous streams are all found and rotations are picked up; pref picks
+// among them (see ytlive.SelectLive). Other URLs resolve to at most one
+// stream.
+func resolveYouTube(ctx context.Context, resolver youtubeResolver, target, pref string) (live, upcoming []ytlive.ResolveResult, err error) {
+	var results []ytlive.ResolveResult
+	if ytlive.IsChannelTarget(target) {
+		if results, err = resolver.ResolveChannel(ctx, target); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		res, err := resolver.Resolve(ctx, target)
+		if err != nil {
+			return nil, nil, err
+		}
+		if res.Live && res.WatchURL == "" {
+			log.Printf("ytlive: resolved live stream without watch url")
+			res.Live = false
+		}
+		results = []ytlive.ResolveResult{res}
+	}
+	for _, res := range results {
+		if res.Upcoming {
+			upcoming = append(upcoming, res)
+		}
+	}
+	live = ytlive.SelectLive(results, pref)
+	for _, res := range live {
+		log.Printf("ytlive: resolved watch=%s chat=%s live=%t", res.WatchURL, res.ChatURL, res.Live)
+	}
+	return live, upcoming, nil
+}
+
+// youtubeUpcomingGrace is how long past its scheduled start an upcoming
+// stream keeps being checked quickly; streams often start a few minutes late.
+const youtubeUpcomingGrace = 30 * time.Minute
+
+// nextUpcoming returns the upcoming stream that starts first. Streams
+// without a published start time sort last.
+func nextUpcoming(upcoming []ytlive.ResolveResult) (ytlive.ResolveResult, bool) {
+	var best ytlive.ResolveResult
+	found := false
+	for _, res := range upcoming {
+		switch {
+		case !found:
+		case res.ScheduledStart.IsZero():
+			continue
+		case !best.ScheduledStart.IsZero() && !res.ScheduledStart.Before(best.ScheduledStart):
+			continue
+		}
+		best, found = res, true
+	}
+	return best, found
+}
+
+// upcomingPollDelay spaces out resolves while waiting for a scheduled
+// stream: a quarter of the remaining time, never more than ten minutes and
+// never less than five seconds, then every ten seconds from the scheduled
+// start until youtubeUpcomingGrace runs out. Without a start time, or once
+// the grace period is over, base applies.
+func upcomingPollDelay(start, now time.Time, base time.Duration) time.Duration {
+	if start.IsZero() {
+		return base
+	}
+	remaining := start.Sub(now)
+	switch {
+	case remaining <= -youtubeUpcomingGrace:
+		return base
+	case remaining <= 0:
+		return 10 * time.Second
+	}
+	delay := remaining / 4
+	if delay > 10*time.Minute {
+		delay = 10 * time.Minute
+	}
+	if delay < 5*time.Second {
+		delay = 5 * time.Second
+	}
+	return delay
+}
+
+// logUpcoming reports the countdown to an upcoming stream.
+func logUpcoming(res ytlive.ResolveResult, now time.Time, next time.Duration) {
+	switch remaining := res.ScheduledStart.Sub(now); {
+	case res.ScheduledStart.IsZero():
+		log.Printf("ytlive: waiting for upcoming stream %s %q (no start time published), next check in %s", res.WatchURL, res.Title, next)
+	case remaining > 0:
+		log.Printf("ytlive: waiting for upcoming stream %s %q, starts in %s (%s), next check in %s",
+			res.WatchURL, res.Title, remaining.Round(time.Second), res.ScheduledStart.Format(time.RFC3339), next)
+	default:
+		log.Printf("ytlive: waiting for upcoming stream %s %q, %s past its scheduled start, next check in %s",
+			res.WatchURL, res.Title, (-remaining).Round(time.Second), next)
+	}
+}
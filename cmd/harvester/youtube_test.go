@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/ytlive"
+)
+
+type stubYouTubeResolver struct {
+	single  ytlive.ResolveResult
+	channel []ytlive.ResolveResult
+}
+
+func (s stubYouTubeResolver) Resolve(context.Context, string) (ytlive.ResolveResult, error) {
+	return s.single, nil
+}
+
+func (s stubYouTubeResolver) ResolveChannel(context.Context, string) ([]ytlive.ResolveResult, error) {
+	return s.channel, nil
+}
+
+func TestResolveYouTubeUpcoming(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	resolver := stubYouTubeResolver{
+		single: ytlive.ResolveResult{WatchURL: "https://www.youtube.com/watch?v=soon", Upcoming: true, ScheduledStart: start},
+		channel: []ytlive.ResolveResult{
+			{Live: true, WatchURL: "https://www.youtube.com/watch?v=a", Title: "A"},
+			{Live: true, WatchURL: "https://www.youtube.com/watch?v=b", Title: "B"},
+			{WatchURL: "https://www.youtube.com/watch?v=later", Upcoming: true, ScheduledStart: start.Add(time.Hour)},
+			{WatchURL: "https://www.youtube.com/watch?v=soon", Upcoming: true, ScheduledStart: start},
+		},
+	}
+
+	live, upcoming, err := resolveYouTube(context.Background(), resolver, "https://www.youtube.com/watch?v=soon", "first")
+	if err != nil || len(live) != 0 || len(upcoming) != 1 {
+		t.Fatalf("single: live=%v upcoming=%v err=%v", live, upcoming, err)
+	}
+
+	live, upcoming, err = resolveYouTube(context.Background(), resolver, "@creator", "all")
+	if err != nil || len(live) != 2 || len(upcoming) != 2 {
+		t.Fatalf("channel: live=%v upcoming=%v err=%v", live, upcoming, err)
+	}
+	if next, ok := nextUpcoming(upcoming); !ok || next.WatchURL != "https://www.youtube.com/watch?v=soon" {
+		t.Fatalf("expected the earliest upcoming stream, got %+v", next)
+	}
+	if _, ok := nextUpcoming(nil); ok {
+		t.Fatalf("expected no upcoming stream")
+	}
+}
+
+func TestUpcomingPollDelay(t *testing.T) {
+	start := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	base := 30 * time.Second
+	for _, tc := range []struct {
+		now  time.Time
+		want time.Duration
+	}{
+		{start.Add(-24 * time.Hour), 10 * time.Minute},
+		{start.Add(-20 * time.Minute), 5 * time.Minute},
+		{start.Add(-10 * time.Second), 5 * time.Second},
+		{start.Add(2 * time.Minute), 10 * time.Second},
+		{start.Add(time.Hour), base},
+	} {
+		if got := upcomingPollDelay(start, tc.now, base); got != tc.want {
+			t.Fatalf("upcomingPollDelay at %s = %s, want %s", tc.now.Sub(start), got, tc.want)
+		}
+	}
+	if got := upcomingPollDelay(time.Time{}, start, base); got != base {
+		t.Fatalf("expected base delay without a start time, got %s", got)
+	}
+}
@@ -0,0 +1,288 @@
+// Package backfill closes holes in the archive: when a receiver reports a
+// window in which it may have missed chat, a job fetches what the platform
+// replays for that window (YouTube live chat replay, Twitch VOD comments)
+// and stores the messages that are not already archived.
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+)
+
+// ErrNotReady is returned by a Source when the platform does not replay the
+// gap yet, such as a YouTube stream that is still live or a Twitch VOD that
+// has not caught up. The job is retried later.
+var ErrNotReady = errors.New("backfill: replay not available yet")
+
+const (
+	// DefaultDelay is the wait before a job's first attempt and between
+	// retries.
+	DefaultDelay = 5 * time.Minute
+	// DefaultMaxGap is the longest window backfilled; longer gaps are
+	// clipped to their most recent part.
+	DefaultMaxGap = 6 * time.Hour
+	// DefaultGiveUp is how long after its gap a job is retried before it is
+	// dropped.
+	DefaultGiveUp = 24 * time.Hour
+	// maxFailures drops a job after this many errors other than
+	// ErrNotReady.
+	maxFailures = 5
+)
+
+// Gap is a window in which a receiver may have missed chat.
+type Gap struct {
+	Platform string
+	// Channel is the Twitch login or the YouTube watch URL.
+	Channel string
+	Start   time.Time
+	End     time.Time
+}
+
+func (g Gap) String() string {
+	return fmt.Sprintf("%s %s %s-%s", g.Platform, g.Channel, g.Start.UTC().Format(time.RFC3339), g.End.UTC().Format(time.RFC3339))
+}
+
+// Source fetches the chat a platform replays for a gap. Messages outside
+// the gap are ignored.
+type Source interface {
+	Fetch(ctx context.Context, gap Gap) ([]core.ChatMessage, error)
+}
+
+// Store tells which replayed messages are already archived;
+// *sink.SQLiteSink satisfies it.
+type Store interface {
+	KnownMessageIDs(ctx context.Context, platform string, ids []string) (map[string]bool, error)
+}
+
+// Writer receives the missing messages. The harvester passes its ingest
+// writer chain so backfilled messages are transformed and fanned out to
+// every sink like live ones.
+type Writer interface {
+	Write(msg core.ChatMessage, trace *ingesttrace.MessageTrace) error
+}
+
+// Config configures a Backfiller.
+type Config struct {
+	// Sources maps a platform ("Twitch", "YouTube") to its replay source.
+	// Gaps on other platforms are ignored.
+	Sources map[string]Source
+	Store   Store
+	Writer  Writer
+	// Delay is the wait before a job's first attempt and between retries;
+	// zero uses DefaultDelay.
+	Delay time.Duration
+	// MaxGap clips longer gaps to their most recent MaxGap; zero uses
+	// DefaultMaxGap.
+	MaxGap time.Duration
+	// GiveUp drops a job this long after its gap ended; zero uses
+	// DefaultGiveUp.
+	GiveUp time.Duration
+}
+
+// result summarizes one finished job: fetched counts the replayed messages
+// inside the gap, stored those that were missing from the archive.
+type result struct {
+	fetched int
+	stored  int
+}
+
+type job struct {
+	gap      Gap
+	due      time.Time
+	failures int
+}
+
+// Backfiller queues gaps and works through them in the background.
+type Backfiller struct {
+	cfg Config
+	now func() time.Time
+
+	mu   sync.Mutex
+	jobs []*job
+	wake chan struct{}
+}
+
+// New creates a backfiller; call Run to start working through gaps.
+func New(cfg Config) *Backfiller {
+	if cfg.Delay <= 0 {
+		cfg.Delay = DefaultDelay
+	}
+	if cfg.MaxGap <= 0 {
+		cfg.MaxGap = DefaultMaxGap
+	}
+	if cfg.GiveUp <= 0 {
+		cfg.GiveUp = DefaultGiveUp
+	}
+	return &Backfiller{cfg: cfg, now: time.Now, wake: make(chan struct{}, 1)}
+}
+
+// Schedule queues gap for backfill after Config.Delay. Gaps on platforms
+// without a source and empty windows are ignored; a gap overlapping one
+// already queued for the same channel is merged into it.
+func (b *Backfiller) Schedule(gap Gap) {
+	if _, ok := b.cfg.Sources[gap.Platform]; !ok || !gap.End.After(gap.Start) {
+		return
+	}
+	if gap.End.Sub(gap.Start) > b.cfg.MaxGap {
+		gap.Start = gap.End.Add(-b.cfg.MaxGap)
+	}
+	b.mu.Lock()
+	merged := false
+	for _, j := range b.jobs {
+		if j.gap.Platform == gap.Platform && j.gap.Channel == gap.Channel &&
+			!gap.Start.After(j.gap.End) && !j.gap.Start.After(gap.End) {
+			if gap.Start.Before(j.gap.Start) {
+				j.gap.Start = gap.Start
+			}
+			if gap.End.After(j.gap.End) {
+				j.gap.End = gap.End
+			}
+			merged = true
+			break
+		}
+	}
+	if !merged {
+		b.jobs = append(b.jobs, &job{gap: gap, due: b.now().Add(b.cfg.Delay)})
+	}
+	b.mu.Unlock()
+	log.Printf("backfill: scheduled %s", gap)
+	select {
+	case b.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Pending reports how many gaps are queued.
+func (b *Backfiller) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.jobs)
+}
+
+// Run works through due jobs until ctx is cancelled.
+func (b *Backfiller) Run(ctx context.Context) error {
+	for {
+		j, wait := b.next()
+		if j != nil {
+			b.attempt(ctx, j)
+			continue
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-b.wake:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// next removes and returns the earliest due job, or reports how long until
+// one is due.
+func (b *Backfiller) next() (*job, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.jobs) == 0 {
+		return nil, time.Hour
+	}
+	sort.Slice(b.jobs, func(i, k int) bool { return b.jobs[i].due.Before(b.jobs[k].due) })
+	if wait := b.jobs[0].due.Sub(b.now()); wait > 0 {
+		return nil, wait
+	}
+	j := b.jobs[0]
+	b.jobs = b.jobs[1:]
+	return j, 0
+}
+
+func (b *Backfiller) attempt(ctx context.Context, j *job) {
+	res, err := b.run(ctx, j.gap)
+	if err == nil {
+		log.Printf("backfill: %s: %d replayed, %d stored", j.gap, res.fetched, res.stored)
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	now := b.now()
+	if !errors.Is(err, ErrNotReady) {
+		j.failures++
+		log.Printf("backfill: %s: %v", j.gap, err)
+	}
+	if j.failures >= maxFailures || now.Sub(j.gap.End) >= b.cfg.GiveUp {
+		log.Printf("backfill: giving up on %s", j.gap)
+		return
+	}
+	j.due = now.Add(b.cfg.Delay)
+	b.mu.Lock()
+	b.jobs = append(b.jobs, j)
+	b.mu.Unlock()
+}
+
+// run fetches gap and stores the replayed messages the archive lacks.
+func (b *Backfiller) run(ctx context.Context, gap Gap) (result, error) {
+	var res result
+	msgs, err := b.cfg.Sources[gap.Platform].Fetch(ctx, gap)
+	if err != nil {
+		return res, err
+	}
+	var (
+		inGap []core.ChatMessage
+		ids   []string
+		seen  = map[string]bool{}
+	)
+	for _, msg := range msgs {
+		id := messageID(msg)
+		if id == "" || seen[id] || msg.Ts.Before(gap.Start) || msg.Ts.After(gap.End) {
+			continue
+		}
+		seen[id] = true
+		inGap = append(inGap, msg)
+		ids = append(ids, id)
+	}
+	res.fetched = len(inGap)
+	if len(inGap) == 0 {
+		return res, nil
+	}
+	known, err := b.cfg.Store.KnownMessageIDs(ctx, gap.Platform, ids)
+	if err != nil {
+		return res, err
+	}
+	received := b.now().UTC()
+	var missing []core.ChatMessage
+	for _, msg := range inGap {
+		if known[messageID(msg)] {
+			continue
+		}
+		msg.ReceivedAt = &received
+		missing = append(missing, msg)
+	}
+	if len(missing) == 0 {
+		return res, nil
+	}
+	for _, msg := range missing {
+		if err := b.cfg.Writer.Write(msg, nil); err != nil {
+			return res, err
+		}
+		res.stored++
+	}
+	return res, nil
+}
+
+// messageID is the ID the archive dedupes msg by.
+func messageID(msg core.ChatMessage) string {
+	if id := strings.TrimSpace(msg.PlatformMsgID); id != "" {
+		return id
+	}
+	return strings.TrimSpace(msg.ID)
+}
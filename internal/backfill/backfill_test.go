@@ -0,0 +1,128 @@
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+)
+
+type fakeSource struct {
+	mu       sync.Mutex
+	calls    int
+	notReady int
+	msgs     []core.ChatMessage
+}
+
+func (f *fakeSource) Fetch(ctx context.Context, gap Gap) ([]core.ChatMessage, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls++
+	if f.calls <= f.notReady {
+		return nil, ErrNotReady
+	}
+	return f.msgs, nil
+}
+
+type fakeStore struct {
+	mu      sync.Mutex
+	known   map[string]bool
+	written chan core.ChatMessage
+}
+
+func (f *fakeStore) KnownMessageIDs(ctx context.Context, platform string, ids []string) (map[string]bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := map[string]bool{}
+	for _, id := range ids {
+		if f.known[id] {
+			out[id] = true
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) Write(msg core.ChatMessage, trace *ingesttrace.MessageTrace) error {
+	f.written <- msg
+	return nil
+}
+
+func TestScheduleMergesAndClips(t *testing.T) {
+	b := New(Config{Sources: map[string]Source{"Twitch": &fakeSource{}}, MaxGap: time.Hour})
+	start := time.Date(2026, 10, 13, 18, 0, 0, 0, time.UTC)
+
+	b.Schedule(Gap{Platform: "YouTube", Channel: "x", Start: start, End: start.Add(time.Minute)})
+	b.Schedule(Gap{Platform: "Twitch", Channel: "elora", Start: start, End: start})
+	if b.Pending() != 0 {
+		t.Fatalf("pending = %d, want gaps without a source and empty gaps ignored", b.Pending())
+	}
+
+	b.Schedule(Gap{Platform: "Twitch", Channel: "elora", Start: start, End: start.Add(10 * time.Minute)})
+	b.Schedule(Gap{Platform: "Twitch", Channel: "elora", Start: start.Add(5 * time.Minute), End: start.Add(20 * time.Minute)})
+	b.Schedule(Gap{Platform: "Twitch", Channel: "other", Start: start, End: start.Add(3 * time.Hour)})
+	if b.Pending() != 2 {
+		t.Fatalf("pending = %d, want 2", b.Pending())
+	}
+	if got := b.jobs[0].gap; !got.Start.Equal(start) || !got.End.Equal(start.Add(20*time.Minute)) {
+		t.Fatalf("merged gap = %s", got)
+	}
+	if got := b.jobs[1].gap; !got.Start.Equal(start.Add(2 * time.Hour)) {
+		t.Fatalf("clipped gap = %s", got)
+	}
+}
+
+func TestRunStoresMissingMessages(t *testing.T) {
+	start := time.Now().Add(-time.Hour).UTC()
+	msg := func(id string, ts time.Time) core.ChatMessage {
+		return core.ChatMessage{ID: id, PlatformMsgID: id, Platform: "Twitch", Channel: "elora", Ts: ts}
+	}
+	source := &fakeSource{notReady: 1, msgs: []core.ChatMessage{
+		msg("before", start.Add(-time.Second)),
+		msg("known", start.Add(time.Second)),
+		msg("new1", start.Add(2*time.Second)),
+		msg("new1", start.Add(2*time.Second)),
+		msg("new2", start.Add(3*time.Second)),
+		msg("after", start.Add(time.Hour)),
+	}}
+	store := &fakeStore{known: map[string]bool{"known": true}, written: make(chan core.ChatMessage, 2)}
+	b := New(Config{Sources: map[string]Source{"Twitch": source}, Store: store, Writer: store, Delay: time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- b.Run(ctx) }()
+	b.Schedule(Gap{Platform: "Twitch", Channel: "elora", Start: start, End: start.Add(time.Minute)})
+
+	var written []core.ChatMessage
+	for len(written) < 2 {
+		select {
+		case msg := <-store.written:
+			written = append(written, msg)
+		case <-ctx.Done():
+			t.Fatalf("written %d messages, want 2", len(written))
+		}
+	}
+	cancel()
+	<-done
+
+	var ids []string
+	for _, m := range written {
+		ids = append(ids, m.ID)
+		if m.ReceivedAt == nil {
+			t.Fatalf("%s has no ReceivedAt", m.ID)
+		}
+	}
+	if fmt.Sprint(ids) != "[new1 new2]" {
+		t.Fatalf("written = %v", ids)
+	}
+	if source.calls != 2 {
+		t.Fatalf("calls = %d, want a retry after ErrNotReady", source.calls)
+	}
+	if b.Pending() != 0 {
+		t.Fatalf("pending = %d after the job finished", b.Pending())
+	}
+}
@@ -0,0 +1,84 @@
+// Package backoff computes jittered exponential reconnect delays.
+package backoff
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Default values used for zero Policy fields.
+const (
+	DefaultInitial    = time.Second
+	DefaultMax        = 60 * time.Second
+	DefaultMultiplier = 2.0
+	DefaultJitter     = 0.2
+)
+
+// Policy describes an exponential backoff. Zero fields take the package
+// defaults; use a negative Jitter to disable jitter.
+type Policy struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+	// Jitter randomly shortens each delay by up to this fraction (0-1), so
+	// harvesters that lost their connection together do not all retry at
+	// the same moment.
+	Jitter float64
+}
+
+func (p Policy) withDefaults() Policy {
+	if p.Initial <= 0 {
+		p.Initial = DefaultInitial
+	}
+	if p.Max <= 0 {
+		p.Max = DefaultMax
+	}
+	if p.Max < p.Initial {
+		p.Max = p.Initial
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultMultiplier
+	}
+	switch {
+	case p.Jitter == 0:
+		p.Jitter = DefaultJitter
+	case p.Jitter < 0:
+		p.Jitter = 0
+	case p.Jitter > 1:
+		p.Jitter = 1
+	}
+	return p
+}
+
+// Backoff hands out successive delays for a Policy. It is not safe for
+// concurrent use.
+type Backoff struct {
+	policy  Policy
+	current time.Duration
+	rand    func() float64
+}
+
+// New returns a Backoff starting at p.Initial.
+func New(p Policy) *Backoff {
+	p = p.withDefaults()
+	return &Backoff{policy: p, current: p.Initial, rand: rand.Float64}
+}
+
+// Next returns the delay to wait now and grows the following one.
+func (b *Backoff) Next() time.Duration {
+	d := b.current
+	grown := time.Duration(float64(b.current) * b.policy.Multiplier)
+	if grown > b.policy.Max || grown <= 0 {
+		grown = b.policy.Max
+	}
+	b.current = grown
+	if b.policy.Jitter > 0 {
+		d -= time.Duration(float64(d) * b.policy.Jitter * b.rand())
+	}
+	return d
+}
+
+// Reset starts the sequence over after a successful connection.
+func (b *Backoff) Reset() {
+	b.current = b.policy.Initial
+}
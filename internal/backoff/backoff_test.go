@@ -0,0 +1,37 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffGrowsAndCaps(t *testing.T) {
+	b := New(Policy{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 3, Jitter: -1})
+	want := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if got := b.Next(); got != w {
+			t.Fatalf("step %d = %s, want %s", i, got, w)
+		}
+	}
+	b.Reset()
+	if got := b.Next(); got != 100*time.Millisecond {
+		t.Fatalf("after reset = %s", got)
+	}
+}
+
+func TestBackoffJitter(t *testing.T) {
+	b := New(Policy{Initial: time.Second, Multiplier: 1, Jitter: 0.5})
+	b.rand = func() float64 { return 1 }
+	if got := b.Next(); got != 500*time.Millisecond {
+		t.Fatalf("full jitter = %s, want 500ms", got)
+	}
+	b.rand = func() float64 { return 0 }
+	if got := b.Next(); got != time.Second {
+		t.Fatalf("no jitter = %s, want 1s", got)
+	}
+
+	d := New(Policy{})
+	if d.policy.Initial != DefaultInitial || d.policy.Max != DefaultMax || d.policy.Multiplier != DefaultMultiplier || d.policy.Jitter != DefaultJitter {
+		t.Fatalf("defaults not applied: %+v", d.policy)
+	}
+}
@@ -0,0 +1,187 @@
+// Package chaos injects network failures into the receivers' outbound
+// connections: dropped connections, slow reads and HTTP 5xx responses at
+// configurable probabilities. It exists for resilience testing of the
+// reconnect and backoff logic and must never be enabled in production.
+package chaos
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/proxy"
+)
+
+// ErrInjected is returned by reads and requests failed on purpose.
+var ErrInjected = errors.New("chaos: injected disconnect")
+
+// httpErrorCodes are the statuses an injected HTTP failure answers with.
+var httpErrorCodes = []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+
+// Config sets the probability, from 0 to 1, of each failure.
+type Config struct {
+	// Disconnect applies to every read from a stream connection, which is
+	// closed and fails, and to every HTTP request, which fails without
+	// reaching the server.
+	Disconnect float64
+	// SlowRead delays a read or an HTTP request by SlowReadDelay.
+	SlowRead      float64
+	SlowReadDelay time.Duration
+	// HTTPError answers an HTTP request with a 5xx instead of sending it.
+	HTTPError float64
+	// Seed makes the failures reproducible; zero seeds from the clock.
+	Seed int64
+}
+
+// Stats counts the failures injected so far.
+type Stats struct {
+	Disconnects int64
+	SlowReads   int64
+	HTTPErrors  int64
+}
+
+// Injector decides which operations fail. It is safe for concurrent use.
+type Injector struct {
+	cfg Config
+
+	mu  sync.Mutex
+	rng *rand.Rand
+
+	disconnects atomic.Int64
+	slowReads   atomic.Int64
+	httpErrors  atomic.Int64
+}
+
+func New(cfg Config) *Injector {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+// Stats returns the failures injected so far.
+func (i *Injector) Stats() Stats {
+	return Stats{
+		Disconnects: i.disconnects.Load(),
+		SlowReads:   i.slowReads.Load(),
+		HTTPErrors:  i.httpErrors.Load(),
+	}
+}
+
+func (i *Injector) roll(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Float64() < p
+}
+
+func (i *Injector) pickStatus() int {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return httpErrorCodes[i.rng.Intn(len(httpErrorCodes))]
+}
+
+// slow sleeps for SlowReadDelay when a slow read is rolled.
+func (i *Injector) slow(ctx context.Context, what string) error {
+	if !i.roll(i.cfg.SlowRead) {
+		return nil
+	}
+	i.slowReads.Add(1)
+	log.Printf("chaos: delaying %s by %s", what, i.cfg.SlowReadDelay)
+	timer := time.NewTimer(i.cfg.SlowReadDelay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Dial wraps dial so the connections it opens suffer injected failures.
+func (i *Injector) Dial(dial proxy.DialFunc) proxy.DialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &faultyConn{Conn: conn, inj: i, addr: addr}, nil
+	}
+}
+
+type faultyConn struct {
+	net.Conn
+	inj  *Injector
+	addr string
+}
+
+func (c *faultyConn) Read(p []byte) (int, error) {
+	if c.inj.roll(c.inj.cfg.Disconnect) {
+		c.inj.disconnects.Add(1)
+		log.Printf("chaos: dropping connection to %s", c.addr)
+		c.Conn.Close()
+		return 0, ErrInjected
+	}
+	if err := c.inj.slow(context.Background(), "read from "+c.addr); err != nil {
+		return 0, err
+	}
+	return c.Conn.Read(p)
+}
+
+// Transport wraps base so the requests it sends suffer injected failures.
+// A nil base uses http.DefaultTransport.
+func (i *Injector) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, inj: i}
+}
+
+type transport struct {
+	base http.RoundTripper
+	inj  *Injector
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.inj.roll(t.inj.cfg.Disconnect) {
+		t.inj.disconnects.Add(1)
+		log.Printf("chaos: failing %s %s", req.Method, req.URL.Host)
+		return nil, ErrInjected
+	}
+	if err := t.inj.slow(req.Context(), "request to "+req.URL.Host); err != nil {
+		return nil, err
+	}
+	if t.inj.roll(t.inj.cfg.HTTPError) {
+		t.inj.httpErrors.Add(1)
+		status := t.inj.pickStatus()
+		log.Printf("chaos: answering %s %s with %d", req.Method, req.URL.Host, status)
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		body := fmt.Sprintf("chaos: injected %d", status)
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+			StatusCode:    status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"text/plain"}},
+			Body:          io.NopCloser(bytes.NewReader([]byte(body))),
+			ContentLength: int64(len(body)),
+			Request:       req,
+		}, nil
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,99 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTransportInjectsFailures(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	get := func(inj *Injector) (*http.Response, error) {
+		client := &http.Client{Transport: inj.Transport(nil)}
+		return client.Get(srv.URL)
+	}
+
+	inj := New(Config{HTTPError: 1, Seed: 1})
+	resp, err := get(inj)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 500 || inj.Stats().HTTPErrors != 1 {
+		t.Fatalf("expected an injected 5xx, got %d (%+v)", resp.StatusCode, inj.Stats())
+	}
+
+	inj = New(Config{Disconnect: 1, Seed: 1})
+	if _, err := get(inj); !errors.Is(err, ErrInjected) || inj.Stats().Disconnects != 1 {
+		t.Fatalf("expected an injected disconnect, got %v (%+v)", err, inj.Stats())
+	}
+
+	inj = New(Config{SlowRead: 1, SlowReadDelay: 20 * time.Millisecond, Seed: 1})
+	start := time.Now()
+	resp, err = get(inj)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || time.Since(start) < 20*time.Millisecond || inj.Stats().SlowReads != 1 {
+		t.Fatalf("expected a slow but successful request, got %d after %s", resp.StatusCode, time.Since(start))
+	}
+
+	inj = New(Config{Seed: 1})
+	resp, err = get(inj)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || inj.Stats() != (Stats{}) {
+		t.Fatalf("expected no failures with zero probabilities, got %d (%+v)", resp.StatusCode, inj.Stats())
+	}
+}
+
+func TestDialDropsConnections(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("hello\n"))
+	}()
+
+	inj := New(Config{Disconnect: 1, Seed: 1})
+	dial := inj.Dial((&net.Dialer{}).DialContext)
+	conn, err := dial(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Read(make([]byte, 16)); !errors.Is(err, ErrInjected) {
+		t.Fatalf("expected an injected disconnect, got %v", err)
+	}
+	if _, err := conn.Read(make([]byte, 16)); err == nil {
+		t.Fatalf("expected the dropped connection to stay closed")
+	}
+}
+
+func TestSeedIsReproducible(t *testing.T) {
+	a, b := New(Config{Disconnect: 0.5, Seed: 42}), New(Config{Disconnect: 0.5, Seed: 42})
+	for n := 0; n < 100; n++ {
+		if a.roll(0.5) != b.roll(0.5) {
+			t.Fatalf("rolls diverged at %d", n)
+		}
+	}
+}
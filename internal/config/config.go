@@ -2,41 +2,279 @@ package config
 
 import (
 	"encoding/json"
+	"net/url"
 	"os"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/you/gnasty-chat/internal/backoff"
+	"github.com/you/gnasty-chat/internal/core"
 )
 
 type Config struct {
-	Sinks   []string
-	Sink    SinkConfig
-	Twitch  TwitchConfig
-	YouTube YouTubeConfig
+	Sinks         []string
+	Sink          SinkConfig
+	Twitch        TwitchConfig
+	YouTube       YouTubeConfig
+	Replay        ReplayConfig
+	HeartbeatSecs int
+	// UsernameRules is the per-platform username normalization spec
+	// (see core.ParseUsernameRules).
+	UsernameRules string
+	// WatchdogIdleSecs withholds the systemd watchdog ping once no message
+	// has been ingested for this long while a receiver is running; zero
+	// disables the check.
+	WatchdogIdleSecs int
+	// DefaultColours assigns a stable palette colour to chatters who have
+	// none (most YouTube users).
+	DefaultColours bool
+	// MirrorWindowMS tags a message as a mirror when the same user sent
+	// the same text on another platform this many milliseconds earlier;
+	// zero disables detection.
+	MirrorWindowMS int
+	// IngestOverflowSample is the fraction of messages over a receiver's
+	// IngestRate that are stored anyway.
+	IngestOverflowSample float64
+	Moments              MomentsConfig
+	Spam                 SpamConfig
+	Admin                AdminConfig
+	Cluster              ClusterConfig
+	Redis                RedisConfig
+	Viewers              ViewersConfig
+	Triggers             TriggersConfig
+	Plugin               PluginConfig
+	Transform            TransformConfig
+	Export               ExportConfig
+	Backfill             BackfillConfig
+	// CrashDir receives a report for every recovered receiver panic.
+	CrashDir string
+	// Proxy is the default outbound proxy URL (http, https, socks5 or
+	// socks5h) for receivers without their own.
+	Proxy string
+	Chaos ChaosConfig
+}
+
+// ChaosConfig injects network failures into the Twitch and YouTube clients
+// for resilience testing. Each field except SlowReadMS and Seed is a
+// probability from 0 to 1.
+type ChaosConfig struct {
+	Disconnect float64
+	SlowRead   float64
+	SlowReadMS int
+	HTTPError  float64
+	Seed       int64
+}
+
+// Enabled reports whether any failure is injected.
+func (c ChaosConfig) Enabled() bool {
+	return c.Disconnect > 0 || c.SlowRead > 0 || c.HTTPError > 0
+}
+
+// YouTubeProxy is the proxy URL for YouTube traffic.
+func (c Config) YouTubeProxy() string {
+	if c.YouTube.Proxy != "" {
+		return c.YouTube.Proxy
+	}
+	return c.Proxy
+}
+
+// TwitchProxy is the proxy URL for Twitch IRC, Helix and OAuth traffic.
+func (c Config) TwitchProxy() string {
+	if c.Twitch.Proxy != "" {
+		return c.Twitch.Proxy
+	}
+	return c.Proxy
+}
+
+// PluginConfig configures the exec enrichment plugin.
+type PluginConfig struct {
+	// Command is the plugin command line, split on whitespace.
+	Command   string
+	TimeoutMS int
+}
+
+// TransformConfig points at the Lua transform script.
+type TransformConfig struct {
+	Script string
+}
+
+// ExportConfig controls the export command's signed bundles.
+type ExportConfig struct {
+	// SigningKeyFile is a PEM-encoded (PKCS #8) Ed25519 private key used
+	// to sign export manifests.
+	SigningKeyFile string
+}
+
+// BackfillConfig controls catch-up backfill of receiver gaps from platform
+// chat replay.
+type BackfillConfig struct {
+	Enabled bool
+	// DelaySecs is the wait before a gap is first fetched and between
+	// retries while the replay is not available yet.
+	DelaySecs int
+	// MaxGapSecs clips longer gaps to their most recent part.
+	MaxGapSecs int
+}
+
+// TriggersConfig points at the chat trigger rules file.
+type TriggersConfig struct {
+	File string
+}
+
+// ViewersConfig controls concurrent-viewer sampling.
+type ViewersConfig struct {
+	Enabled bool
+	// IntervalSecs is how often each live source is sampled.
+	IntervalSecs int
+}
+
+// RedisConfig enables the Redis pub/sub bridge for live broadcasts.
+type RedisConfig struct {
+	URL      string
+	Channel  string
+	Whispers bool
+}
+
+// ClusterConfig controls leader election between harvesters that share a
+// SQLite database.
+type ClusterConfig struct {
+	// LeaderElection makes each receiver ingest only while this instance
+	// holds its lease; every instance still serves the HTTP API.
+	LeaderElection bool
+	// InstanceID identifies this instance as a lease holder.
+	InstanceID   string
+	LeaseTTLSecs int
+}
+
+// AdminConfig controls the /admin endpoints.
+type AdminConfig struct {
+	// Token is the bearer token required by destructive admin endpoints.
+	Token string
+	// ErasureMode is "delete" or "redact" for user erasure requests.
+	ErasureMode string
+}
+
+// SpamConfig controls the spam burst detector.
+type SpamConfig struct {
+	Enabled bool
+	// WindowSecs is how far apart messages of one burst may arrive.
+	WindowSecs int
+	// MinAccounts is how many accounts must send near-identical messages
+	// within the window to flag them.
+	MinAccounts int
+	// NewAccountDays is the account age below which a flagged author's
+	// score is raised.
+	NewAccountDays int
+}
+
+// MomentsConfig controls the chat velocity spike analyzer.
+type MomentsConfig struct {
+	Enabled   bool
+	MinZScore float64
 }
 
 type SinkConfig struct {
 	SQLite     SQLiteConfig
+	MQTT       MQTTConfig
+	OpenSearch OpenSearchConfig
+	// StreamerBot configures the streamerbot sink, which runs a
+	// Streamer.bot action for every message.
+	StreamerBot StreamerBotConfig
+	// Shadow dual-writes to a second database to verify it before a
+	// storage migration.
+	Shadow     ShadowConfig
 	BatchSize  int
 	FlushMaxMS int
 }
 
 type SQLiteConfig struct {
 	Path string
+	// Dir is the data directory of the sqlite-channels sink, which keeps
+	// one database per platform channel plus a catalog.
+	Dir string
+	// MaintenanceSecs is how often WAL checkpoint/optimize/vacuum runs;
+	// zero disables scheduled maintenance.
+	MaintenanceSecs int
+	// WALMaxMB triggers maintenance early once the WAL grows past it;
+	// zero disables the size trigger.
+	WALMaxMB int
+	// HashChain links every stored message into a per-session SHA-256
+	// chain that GET /admin/verify re-validates.
+	HashChain bool
+	// Shared cooperates with other processes writing the same file, such
+	// as elora-chat: writes wait up to BusyTimeoutMS for the write lock and
+	// rows from other writers are detected and logged.
+	Shared        bool
+	BusyTimeoutMS int
+	// WriteToken, when set in shared mode, enables POST /emit so external
+	// tools can write through the harvester instead of into the file.
+	WriteToken string
+}
+
+// ShadowConfig controls shadow-mode ingest into a second SQLite database.
+type ShadowConfig struct {
+	// SQLitePath enables shadow mode when set.
+	SQLitePath string
+	// VerifySecs is how often the last hour of both databases is compared.
+	VerifySecs int
+	// WindowSecs is the width of each compared time window.
+	WindowSecs int
+}
+
+type MQTTConfig struct {
+	URL      string
+	ClientID string
+	Username string
+	Password string
+	Topic    string
+	QoS      int
+	Retain   bool
+}
+
+// StreamerBotConfig configures the Streamer.bot WebSocket sink.
+type StreamerBotConfig struct {
+	URL      string
+	Password string
+	Action   string
+}
+
+// OpenSearchConfig configures the OpenSearch/Elasticsearch indexing sink.
+type OpenSearchConfig struct {
+	URL      string
+	Username string
+	Password string
+	// Index prefixes the daily indices and names their index template.
+	Index string
 }
 
 type TwitchConfig struct {
-	Enabled           bool
-	Channels          []string
-	Nick              string
-	Token             string
-	TokenFile         string
-	ClientID          string
-	ClientSecret      string
-	RefreshToken      string
-	RefreshTokenFile  string
-	TLS               bool
+	Enabled          bool
+	Channels         []string
+	Nick             string
+	Token            string
+	TokenFile        string
+	ClientID         string
+	ClientSecret     string
+	RefreshToken     string
+	RefreshTokenFile string
+	TLS              bool
+	Profiles         bool
+	StreamStatus     bool
+	Polls            bool
+	Raids            bool
+	AutoMod          bool
+	Redemptions      bool
+	Backoff          BackoffConfig
+	ChannelsPerConn  int
+	Presence         bool
+	PresenceSample   float64
+	Whispers         bool
+	// IngestRate caps Twitch chat at this many messages per second; zero
+	// means unlimited.
+	IngestRate        float64
+	Proxy             string
 	LegacyChannelEnv  string
 	LegacyTokenEnv    string
 	LegacyClientIDEnv string
@@ -50,15 +288,106 @@ type YouTubeConfig struct {
 	PollTimeoutSecs int
 	PollIntervalMS  int
 	Debug           bool `json:"debug"`
+	Backoff         BackoffConfig
+	// LiveSelect chooses among simultaneous live streams when LiveURL names
+	// a channel: "first", "all", or a title substring to prefer.
+	LiveSelect string
+	// Proxy overrides Config.Proxy for YouTube.
+	Proxy string
+	// CookieFile is a Netscape cookies.txt for a signed-in account, used
+	// for member-only and unlisted chats.
+	CookieFile string
+	// IngestRate caps YouTube chat at this many messages per second; zero
+	// means unlimited.
+	IngestRate float64
+	// CheckpointMaxAgeSecs is how old a saved poll position may be and still
+	// be resumed after a restart; zero disables checkpointing.
+	CheckpointMaxAgeSecs int
+}
+
+// ReplayConfig configures the development receiver that replays an NDJSON
+// chat log as live chat.
+type ReplayConfig struct {
+	// File is the NDJSON log; empty disables the replay receiver.
+	File string
+	// Speed scales the log's original pacing; zero replays without pauses.
+	Speed float64
+	// Loop restarts the replay when the file ends.
+	Loop bool
+	// MaxGapMS caps the pause between two replayed messages.
+	MaxGapMS int
+}
+
+// BackoffConfig is a receiver's reconnect policy.
+type BackoffConfig struct {
+	InitialMS  int
+	MaxMS      int
+	Multiplier float64
+	// Jitter is the fraction (0-1) by which each delay is randomly
+	// shortened; 0 disables it.
+	Jitter float64
+}
+
+// Policy converts c for the receivers.
+func (c BackoffConfig) Policy() backoff.Policy {
+	p := backoff.Policy{
+		Initial:    time.Duration(c.InitialMS) * time.Millisecond,
+		Max:        time.Duration(c.MaxMS) * time.Millisecond,
+		Multiplier: c.Multiplier,
+		Jitter:     c.Jitter,
+	}
+	if p.Jitter == 0 {
+		p.Jitter = -1
+	}
+	return p
+}
+
+// readBackoff reads the <prefix>_BACKOFF_* variables.
+func readBackoff(prefix string) BackoffConfig {
+	return BackoffConfig{
+		InitialMS:  readInt(prefix+"_BACKOFF_INITIAL_MS", defaultBackoffInitialMS),
+		MaxMS:      readInt(prefix+"_BACKOFF_MAX_MS", defaultBackoffMaxMS),
+		Multiplier: readFloat(prefix+"_BACKOFF_MULTIPLIER", backoff.DefaultMultiplier),
+		Jitter:     readFloat(prefix+"_BACKOFF_JITTER", backoff.DefaultJitter),
+	}
 }
 
 const (
-	defaultSQLitePath          = "chat.db"
-	defaultBatchSize           = 1
-	defaultFlushMS             = 0
-	defaultYouTubeRetrySeconds = 30
-	defaultYouTubePollTimeout  = 15
-	defaultYouTubePollInterval = 10_000
+	defaultSQLitePath            = "chat.db"
+	defaultSQLiteDir             = "chat-data"
+	defaultBatchSize             = 1
+	defaultFlushMS               = 0
+	defaultYouTubeRetrySeconds   = 30
+	defaultYouTubePollTimeout    = 15
+	defaultYouTubePollInterval   = 10_000
+	defaultYouTubeCheckpointSecs = 300
+	defaultHeartbeatSecs         = 60
+	defaultMaintenanceSecs       = 3600
+	defaultWALMaxMB              = 64
+	defaultSQLiteBusyTimeoutMS   = 15000
+	defaultMQTTTopic             = "gnasty/{platform}/messages"
+	defaultStreamerBotURL        = "ws://127.0.0.1:8080/"
+	defaultStreamerBotAction     = "gnasty chat"
+	defaultShadowVerifySecs      = 300
+	defaultShadowWindowSecs      = 60
+	defaultOpenSearchIndex       = "gnasty-chat"
+	defaultMomentsMinZScore      = 3.0
+	defaultSpamWindowSecs        = 30
+	defaultSpamMinAccounts       = 5
+	defaultSpamNewAccountDays    = 7
+	defaultErasureMode           = "delete"
+	defaultLeaseTTLSecs          = 15
+	defaultRedisChannel          = "gnasty:messages"
+	defaultViewerSampleSecs      = 60
+	defaultPluginTimeoutMS       = 2000
+	defaultBackoffInitialMS      = 1000
+	defaultBackoffMaxMS          = 60000
+	defaultTwitchChannelsPerConn = 50
+	defaultReplaySpeed           = 1.0
+	defaultReplayMaxGapMS        = 5000
+	defaultChaosSlowReadMS       = 2000
+	defaultBackfillDelaySecs     = 300
+	defaultBackfillMaxGapSecs    = 6 * 3600
 )
 
 func Load() Config {
@@ -79,10 +408,50 @@ func Load() Config {
 	if cfg.Sink.SQLite.Path == "" {
 		cfg.Sink.SQLite.Path = defaultSQLitePath
 	}
+	cfg.Sink.SQLite.Dir = strings.TrimSpace(os.Getenv("GNASTY_SINK_SQLITE_DIR"))
+	if cfg.Sink.SQLite.Dir == "" {
+		cfg.Sink.SQLite.Dir = defaultSQLiteDir
+	}
 
 	cfg.Sink.BatchSize = readInt("GNASTY_SINK_BATCH_SIZE", defaultBatchSize)
 	cfg.Sink.FlushMaxMS = readInt("GNASTY_SINK_FLUSH_MAX_MS", defaultFlushMS)
 
+	cfg.Sink.MQTT.URL = strings.TrimSpace(os.Getenv("GNASTY_SINK_MQTT_URL"))
+	cfg.Sink.MQTT.ClientID = strings.TrimSpace(os.Getenv("GNASTY_SINK_MQTT_CLIENT_ID"))
+	cfg.Sink.MQTT.Username = strings.TrimSpace(os.Getenv("GNASTY_SINK_MQTT_USERNAME"))
+	cfg.Sink.MQTT.Password = strings.TrimSpace(os.Getenv("GNASTY_SINK_MQTT_PASSWORD"))
+	cfg.Sink.MQTT.Topic = strings.TrimSpace(os.Getenv("GNASTY_SINK_MQTT_TOPIC"))
+	if cfg.Sink.MQTT.Topic == "" {
+		cfg.Sink.MQTT.Topic = defaultMQTTTopic
+	}
+	if raw := strings.TrimSpace(os.Getenv("GNASTY_SINK_MQTT_QOS")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil {
+			cfg.Sink.MQTT.QoS = n
+		}
+	}
+	cfg.Sink.MQTT.Retain = readBool("GNASTY_SINK_MQTT_RETAIN", false)
+
+	cfg.Sink.StreamerBot.URL = strings.TrimSpace(os.Getenv("GNASTY_SINK_STREAMERBOT_URL"))
+	if cfg.Sink.StreamerBot.URL == "" {
+		cfg.Sink.StreamerBot.URL = defaultStreamerBotURL
+	}
+	cfg.Sink.StreamerBot.Password = strings.TrimSpace(os.Getenv("GNASTY_SINK_STREAMERBOT_PASSWORD"))
+	cfg.Sink.Shadow.SQLitePath = strings.TrimSpace(os.Getenv("GNASTY_SHADOW_SQLITE_PATH"))
+	cfg.Sink.Shadow.VerifySecs = readInt("GNASTY_SHADOW_VERIFY_SECS", defaultShadowVerifySecs)
+	cfg.Sink.Shadow.WindowSecs = readInt("GNASTY_SHADOW_WINDOW_SECS", defaultShadowWindowSecs)
+	cfg.Sink.StreamerBot.Action = strings.TrimSpace(os.Getenv("GNASTY_SINK_STREAMERBOT_ACTION"))
+	if cfg.Sink.StreamerBot.Action == "" {
+		cfg.Sink.StreamerBot.Action = defaultStreamerBotAction
+	}
+
+	cfg.Sink.OpenSearch.URL = strings.TrimSpace(os.Getenv("GNASTY_SINK_OPENSEARCH_URL"))
+	cfg.Sink.OpenSearch.Username = strings.TrimSpace(os.Getenv("GNASTY_SINK_OPENSEARCH_USERNAME"))
+	cfg.Sink.OpenSearch.Password = strings.TrimSpace(os.Getenv("GNASTY_SINK_OPENSEARCH_PASSWORD"))
+	cfg.Sink.OpenSearch.Index = strings.TrimSpace(os.Getenv("GNASTY_SINK_OPENSEARCH_INDEX"))
+	if cfg.Sink.OpenSearch.Index == "" {
+		cfg.Sink.OpenSearch.Index = defaultOpenSearchIndex
+	}
+
 	twEnabled := readBool("GNASTY_TWITCH_ENABLED", false)
 	cfg.Twitch.Enabled = twEnabled
 	channels := splitList(os.Getenv("GNASTY_TWITCH_CHANNELS"))
@@ -134,6 +503,20 @@ func Load() Config {
 		cfg.Twitch.TLS = readBoolDefaultTrue("TWITCH_TLS", cfg.Twitch.TLS)
 	}
 
+	cfg.Twitch.Profiles = readBool("GNASTY_TWITCH_PROFILES", false)
+	cfg.Twitch.StreamStatus = readBool("GNASTY_TWITCH_STREAM_STATUS", false)
+	cfg.Twitch.Polls = readBool("GNASTY_TWITCH_POLLS", false)
+	cfg.Twitch.Raids = readBool("GNASTY_TWITCH_RAIDS", false)
+	cfg.Twitch.AutoMod = readBool("GNASTY_TWITCH_AUTOMOD", false)
+	cfg.Twitch.Redemptions = readBool("GNASTY_TWITCH_REDEMPTIONS", false)
+	cfg.Twitch.Backoff = readBackoff("GNASTY_TWITCH")
+	cfg.Twitch.Presence = readBool("GNASTY_TWITCH_PRESENCE", false)
+	cfg.Twitch.PresenceSample = readFloat("GNASTY_TWITCH_PRESENCE_SAMPLE", 1)
+	cfg.Twitch.IngestRate = readFloat("GNASTY_TWITCH_INGEST_RATE", 0)
+	cfg.Twitch.Whispers = readBool("GNASTY_TWITCH_WHISPERS", false)
+	cfg.Twitch.ChannelsPerConn = readInt("GNASTY_TWITCH_CHANNELS_PER_CONN", defaultTwitchChannelsPerConn)
+	cfg.Twitch.Proxy = strings.TrimSpace(os.Getenv("GNASTY_TWITCH_PROXY"))
+
 	ytURL := strings.TrimSpace(os.Getenv("GNASTY_YT_URL"))
 	if ytURL == "" {
 		ytURL = strings.TrimSpace(os.Getenv("YOUTUBE_URL"))
@@ -141,6 +524,14 @@ func Load() Config {
 	cfg.YouTube.LiveURL = ytURL
 	cfg.YouTube.Enabled = ytURL != ""
 	cfg.YouTube.RetrySeconds = readInt("GNASTY_YT_RETRY_SECS", defaultYouTubeRetrySeconds)
+	cfg.YouTube.LiveSelect = strings.TrimSpace(os.Getenv("GNASTY_YT_LIVE_SELECT"))
+	if cfg.YouTube.LiveSelect == "" {
+		cfg.YouTube.LiveSelect = "first"
+	}
+	cfg.YouTube.Proxy = strings.TrimSpace(os.Getenv("GNASTY_YT_PROXY"))
+	cfg.YouTube.CookieFile = strings.TrimSpace(os.Getenv("GNASTY_YT_COOKIES_FILE"))
+	cfg.YouTube.IngestRate = readFloat("GNASTY_YT_INGEST_RATE", 0)
+	cfg.YouTube.CheckpointMaxAgeSecs = readNonNegativeInt("GNASTY_YT_CHECKPOINT_MAX_AGE_SECS", defaultYouTubeCheckpointSecs)
 
 	if v, ok := readBoolOverride("GNASTY_YT_DUMP_UNHANDLED"); ok {
 		cfg.YouTube.DumpUnhandled = v
@@ -173,6 +564,96 @@ func Load() Config {
 	}
 
 	cfg.YouTube.Debug = readDebugEnv("GNASTY_YT_DEBUG")
+	cfg.YouTube.Backoff = readBackoff("GNASTY_YT")
+
+	cfg.Replay.File = strings.TrimSpace(os.Getenv("GNASTY_REPLAY_FILE"))
+	cfg.Replay.Speed = readFloat("GNASTY_REPLAY_SPEED", defaultReplaySpeed)
+	cfg.Replay.Loop = readBool("GNASTY_REPLAY_LOOP", false)
+	cfg.Replay.MaxGapMS = readInt("GNASTY_REPLAY_MAX_GAP_MS", defaultReplayMaxGapMS)
+
+	cfg.HeartbeatSecs = defaultHeartbeatSecs
+	if raw := strings.TrimSpace(os.Getenv("GNASTY_HEARTBEAT_SECS")); raw != "" {
+		if n, err := strconv.Atoi(raw); err == nil && n >= 0 {
+			cfg.HeartbeatSecs = n
+		}
+	}
+
+	cfg.WatchdogIdleSecs = readNonNegativeInt("GNASTY_WATCHDOG_IDLE_SECS", 0)
+
+	cfg.Sink.SQLite.MaintenanceSecs = readNonNegativeInt("GNASTY_SQLITE_MAINTENANCE_SECS", defaultMaintenanceSecs)
+	cfg.Sink.SQLite.WALMaxMB = readNonNegativeInt("GNASTY_SQLITE_WAL_MAX_MB", defaultWALMaxMB)
+	cfg.Sink.SQLite.HashChain = readBool("GNASTY_SQLITE_HASH_CHAIN", false)
+	cfg.Sink.SQLite.Shared = readBool("GNASTY_SQLITE_SHARED", false)
+	cfg.Sink.SQLite.BusyTimeoutMS = readInt("GNASTY_SQLITE_BUSY_TIMEOUT_MS", defaultSQLiteBusyTimeoutMS)
+	cfg.Sink.SQLite.WriteToken = strings.TrimSpace(os.Getenv("GNASTY_SQLITE_WRITE_TOKEN"))
+
+	cfg.UsernameRules = strings.TrimSpace(os.Getenv("GNASTY_USERNAME_NORMALIZATION"))
+	if cfg.UsernameRules == "" {
+		cfg.UsernameRules = core.DefaultUsernameRules
+	}
+	cfg.DefaultColours = readBoolDefaultTrue("GNASTY_DEFAULT_COLOURS", true)
+	cfg.MirrorWindowMS = readNonNegativeInt("GNASTY_MIRROR_WINDOW_MS", 0)
+	cfg.IngestOverflowSample = readFloat("GNASTY_INGEST_OVERFLOW_SAMPLE", 0)
+	cfg.Spam.Enabled = readBool("GNASTY_SPAM_DETECTION", false)
+	cfg.Spam.WindowSecs = readInt("GNASTY_SPAM_WINDOW_SECS", defaultSpamWindowSecs)
+	cfg.Spam.MinAccounts = readInt("GNASTY_SPAM_MIN_ACCOUNTS", defaultSpamMinAccounts)
+	cfg.Spam.NewAccountDays = readInt("GNASTY_SPAM_NEW_ACCOUNT_DAYS", defaultSpamNewAccountDays)
+
+	cfg.CrashDir = strings.TrimSpace(os.Getenv("GNASTY_CRASH_DIR"))
+	cfg.Proxy = strings.TrimSpace(os.Getenv("GNASTY_PROXY"))
+
+	cfg.Chaos.Disconnect = readFloat("GNASTY_CHAOS_DISCONNECT", 0)
+	cfg.Chaos.SlowRead = readFloat("GNASTY_CHAOS_SLOW_READ", 0)
+	cfg.Chaos.SlowReadMS = readInt("GNASTY_CHAOS_SLOW_READ_MS", defaultChaosSlowReadMS)
+	cfg.Chaos.HTTPError = readFloat("GNASTY_CHAOS_HTTP_ERROR", 0)
+	if raw := strings.TrimSpace(os.Getenv("GNASTY_CHAOS_SEED")); raw != "" {
+		if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			cfg.Chaos.Seed = n
+		}
+	}
+
+	cfg.Moments.Enabled = readBool("GNASTY_MOMENTS", false)
+	cfg.Moments.MinZScore = defaultMomentsMinZScore
+	if raw := strings.TrimSpace(os.Getenv("GNASTY_MOMENTS_MIN_ZSCORE")); raw != "" {
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			cfg.Moments.MinZScore = f
+		}
+	}
+
+	cfg.Admin.Token = strings.TrimSpace(os.Getenv("GNASTY_ADMIN_TOKEN"))
+	cfg.Admin.ErasureMode = strings.ToLower(strings.TrimSpace(os.Getenv("GNASTY_ERASURE_MODE")))
+	if cfg.Admin.ErasureMode == "" {
+		cfg.Admin.ErasureMode = defaultErasureMode
+	}
+
+	cfg.Cluster.LeaderElection = readBool("GNASTY_LEADER_ELECTION", false)
+	cfg.Cluster.InstanceID = strings.TrimSpace(os.Getenv("GNASTY_INSTANCE_ID"))
+	if cfg.Cluster.InstanceID == "" {
+		cfg.Cluster.InstanceID = defaultInstanceID()
+	}
+	cfg.Cluster.LeaseTTLSecs = readInt("GNASTY_LEASE_TTL_SECS", defaultLeaseTTLSecs)
+
+	cfg.Redis.URL = strings.TrimSpace(os.Getenv("GNASTY_REDIS_URL"))
+	cfg.Redis.Channel = strings.TrimSpace(os.Getenv("GNASTY_REDIS_CHANNEL"))
+	if cfg.Redis.Channel == "" {
+		cfg.Redis.Channel = defaultRedisChannel
+	}
+	cfg.Redis.Whispers = readBool("GNASTY_REDIS_WHISPERS", false)
+
+	cfg.Triggers.File = strings.TrimSpace(os.Getenv("GNASTY_TRIGGERS_FILE"))
+
+	cfg.Export.SigningKeyFile = strings.TrimSpace(os.Getenv("GNASTY_EXPORT_SIGNING_KEY_FILE"))
+
+	cfg.Plugin.Command = strings.TrimSpace(os.Getenv("GNASTY_EXEC_PLUGIN"))
+	cfg.Plugin.TimeoutMS = readInt("GNASTY_EXEC_PLUGIN_TIMEOUT_MS", defaultPluginTimeoutMS)
+	cfg.Transform.Script = strings.TrimSpace(os.Getenv("GNASTY_TRANSFORM_SCRIPT"))
+
+	cfg.Viewers.Enabled = readBool("GNASTY_VIEWER_SAMPLES", false)
+	cfg.Viewers.IntervalSecs = readInt("GNASTY_VIEWER_SAMPLE_SECS", defaultViewerSampleSecs)
+
+	cfg.Backfill.Enabled = readBool("GNASTY_BACKFILL", false)
+	cfg.Backfill.DelaySecs = readInt("GNASTY_BACKFILL_DELAY_SECS", defaultBackfillDelaySecs)
+	cfg.Backfill.MaxGapSecs = readInt("GNASTY_BACKFILL_MAX_GAP_SECS", defaultBackfillMaxGapSecs)
 
 	if !cfg.Twitch.Enabled {
 		cfg.Twitch.Enabled = len(cfg.Twitch.Channels) > 0
@@ -181,6 +662,16 @@ func Load() Config {
 	return cfg
 }
 
+// defaultInstanceID is hostname-pid, unique enough for several containers
+// or processes on one host.
+func defaultInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "harvester"
+	}
+	return host + "-" + strconv.Itoa(os.Getpid())
+}
+
 func splitList(raw string) []string {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
@@ -240,6 +731,32 @@ func readInt(name string, def int) int {
 	return n
 }
 
+func readFloat(name string, def float64) float64 {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return def
+	}
+	return f
+}
+
+// readNonNegativeInt is like readInt but keeps an explicit zero, which
+// callers use to disable a feature.
+func readNonNegativeInt(name string, def int) int {
+	raw := strings.TrimSpace(os.Getenv(name))
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
 func readBool(name string, def bool) bool {
 	raw := strings.TrimSpace(os.Getenv(name))
 	if raw == "" {
@@ -318,6 +835,7 @@ func (c Config) Summary() Summary {
 			RefreshToken:     redactString(c.Twitch.RefreshToken),
 			RefreshTokenFile: c.Twitch.RefreshTokenFile,
 			RefreshEnabled:   refreshEnabled,
+			Proxy:            redactURLUserinfo(c.TwitchProxy()),
 		},
 		YouTube: YouTubeSummary{
 			Enabled:         c.YouTube.Enabled,
@@ -328,6 +846,9 @@ func (c Config) Summary() Summary {
 			PollTimeoutSecs: c.YouTube.PollTimeoutSecs,
 			PollIntervalMS:  c.YouTube.PollIntervalMS,
 			Debug:           c.YouTube.Debug,
+			LiveSelect:      c.YouTube.LiveSelect,
+			Proxy:           redactURLUserinfo(c.YouTubeProxy()),
+			CookieFile:      c.YouTube.CookieFile,
 		},
 	}
 	return summary
@@ -353,6 +874,7 @@ type TwitchSummary struct {
 	RefreshToken     string `json:"refresh_token,omitempty"`
 	RefreshTokenFile string `json:"refresh_token_file,omitempty"`
 	RefreshEnabled   bool   `json:"refresh_enabled"`
+	Proxy            string `json:"proxy,omitempty"`
 }
 
 type YouTubeSummary struct {
@@ -364,6 +886,9 @@ type YouTubeSummary struct {
 	PollTimeoutSecs int    `json:"poll_timeout_secs,omitempty"`
 	PollIntervalMS  int    `json:"poll_interval_ms,omitempty"`
 	Debug           bool   `json:"debug"`
+	LiveSelect      string `json:"live_select,omitempty"`
+	Proxy           string `json:"proxy,omitempty"`
+	CookieFile      string `json:"cookie_file,omitempty"`
 }
 
 func (c Config) Redacted() map[string]any {
@@ -372,9 +897,41 @@ func (c Config) Redacted() map[string]any {
 	payload := map[string]any{
 		"sinks": append([]string(nil), c.Sinks...),
 		"sink": map[string]any{
-			"sqlite_path": c.Sink.SQLite.Path,
-			"batch_size":  c.Sink.BatchSize,
-			"flush_ms":    c.Sink.FlushMaxMS,
+			"sqlite_path":             c.Sink.SQLite.Path,
+			"sqlite_dir":              c.Sink.SQLite.Dir,
+			"sqlite_maintenance_secs": c.Sink.SQLite.MaintenanceSecs,
+			"sqlite_wal_max_mb":       c.Sink.SQLite.WALMaxMB,
+			"sqlite_hash_chain":       c.Sink.SQLite.HashChain,
+			"sqlite_shared":           c.Sink.SQLite.Shared,
+			"sqlite_busy_timeout_ms":  c.Sink.SQLite.BusyTimeoutMS,
+			"sqlite_write_token":      redactString(c.Sink.SQLite.WriteToken),
+			"batch_size":              c.Sink.BatchSize,
+			"flush_ms":                c.Sink.FlushMaxMS,
+			"mqtt": map[string]any{
+				"url":       redactURLUserinfo(c.Sink.MQTT.URL),
+				"client_id": c.Sink.MQTT.ClientID,
+				"username":  c.Sink.MQTT.Username,
+				"password":  redactString(c.Sink.MQTT.Password),
+				"topic":     c.Sink.MQTT.Topic,
+				"qos":       c.Sink.MQTT.QoS,
+				"retain":    c.Sink.MQTT.Retain,
+			},
+			"opensearch": map[string]any{
+				"url":      redactURLUserinfo(c.Sink.OpenSearch.URL),
+				"username": c.Sink.OpenSearch.Username,
+				"password": redactString(c.Sink.OpenSearch.Password),
+				"index":    c.Sink.OpenSearch.Index,
+			},
+			"streamerbot": map[string]any{
+				"url":      c.Sink.StreamerBot.URL,
+				"password": redactString(c.Sink.StreamerBot.Password),
+				"action":   c.Sink.StreamerBot.Action,
+			},
+			"shadow": map[string]any{
+				"sqlite_path": c.Sink.Shadow.SQLitePath,
+				"verify_secs": c.Sink.Shadow.VerifySecs,
+				"window_secs": c.Sink.Shadow.WindowSecs,
+			},
 		},
 		"twitch": map[string]any{
 			"enabled":            c.Twitch.Enabled,
@@ -387,7 +944,20 @@ func (c Config) Redacted() map[string]any {
 			"refresh_token":      redactString(c.Twitch.RefreshToken),
 			"refresh_token_file": c.Twitch.RefreshTokenFile,
 			"tls":                c.Twitch.TLS,
+			"profiles":           c.Twitch.Profiles,
+			"stream_status":      c.Twitch.StreamStatus,
+			"polls":              c.Twitch.Polls,
+			"raids":              c.Twitch.Raids,
+			"automod":            c.Twitch.AutoMod,
+			"redemptions":        c.Twitch.Redemptions,
+			"backoff":            c.Twitch.Backoff.redacted(),
+			"channels_per_conn":  c.Twitch.ChannelsPerConn,
+			"presence":           c.Twitch.Presence,
+			"presence_sample":    c.Twitch.PresenceSample,
+			"ingest_rate":        c.Twitch.IngestRate,
+			"whispers":           c.Twitch.Whispers,
 			"refresh_enabled":    refreshEnabled,
+			"proxy":              redactURLUserinfo(c.Twitch.Proxy),
 		},
 		"youtube": map[string]any{
 			"enabled": c.YouTube.Enabled,
@@ -397,16 +967,98 @@ func (c Config) Redacted() map[string]any {
 				}
 				return c.YouTube.LiveURL
 			}(),
-			"retry_seconds":     c.YouTube.RetrySeconds,
-			"dump_unhandled":    c.YouTube.DumpUnhandled,
-			"poll_timeout_secs": c.YouTube.PollTimeoutSecs,
-			"poll_interval_ms":  c.YouTube.PollIntervalMS,
-			"debug":             c.YouTube.Debug,
+			"retry_seconds":           c.YouTube.RetrySeconds,
+			"dump_unhandled":          c.YouTube.DumpUnhandled,
+			"poll_timeout_secs":       c.YouTube.PollTimeoutSecs,
+			"poll_interval_ms":        c.YouTube.PollIntervalMS,
+			"debug":                   c.YouTube.Debug,
+			"backoff":                 c.YouTube.Backoff.redacted(),
+			"live_select":             c.YouTube.LiveSelect,
+			"proxy":                   redactURLUserinfo(c.YouTube.Proxy),
+			"cookie_file":             c.YouTube.CookieFile,
+			"ingest_rate":             c.YouTube.IngestRate,
+			"checkpoint_max_age_secs": c.YouTube.CheckpointMaxAgeSecs,
+		},
+		"replay": map[string]any{
+			"file":       c.Replay.File,
+			"speed":      c.Replay.Speed,
+			"loop":       c.Replay.Loop,
+			"max_gap_ms": c.Replay.MaxGapMS,
+		},
+		"heartbeat_secs":         c.HeartbeatSecs,
+		"watchdog_idle_secs":     c.WatchdogIdleSecs,
+		"username_rules":         c.UsernameRules,
+		"default_colours":        c.DefaultColours,
+		"mirror_window_ms":       c.MirrorWindowMS,
+		"ingest_overflow_sample": c.IngestOverflowSample,
+		"crash_dir":              c.CrashDir,
+		"proxy":                  redactURLUserinfo(c.Proxy),
+		"chaos": map[string]any{
+			"disconnect":   c.Chaos.Disconnect,
+			"slow_read":    c.Chaos.SlowRead,
+			"slow_read_ms": c.Chaos.SlowReadMS,
+			"http_error":   c.Chaos.HTTPError,
+			"seed":         c.Chaos.Seed,
+		},
+		"admin": map[string]any{
+			"token":        redactString(c.Admin.Token),
+			"erasure_mode": c.Admin.ErasureMode,
+		},
+		"export": map[string]any{
+			"signing_key_file": c.Export.SigningKeyFile,
+		},
+		"spam": map[string]any{
+			"enabled":          c.Spam.Enabled,
+			"window_secs":      c.Spam.WindowSecs,
+			"min_accounts":     c.Spam.MinAccounts,
+			"new_account_days": c.Spam.NewAccountDays,
+		},
+		"moments": map[string]any{
+			"enabled":    c.Moments.Enabled,
+			"min_zscore": c.Moments.MinZScore,
+		},
+		"cluster": map[string]any{
+			"leader_election": c.Cluster.LeaderElection,
+			"instance_id":     c.Cluster.InstanceID,
+			"lease_ttl_secs":  c.Cluster.LeaseTTLSecs,
+		},
+		"redis": map[string]any{
+			"url":      redactURLUserinfo(c.Redis.URL),
+			"channel":  c.Redis.Channel,
+			"whispers": c.Redis.Whispers,
+		},
+		"triggers": map[string]any{
+			"file": c.Triggers.File,
+		},
+		"exec_plugin": map[string]any{
+			"command":    c.Plugin.Command,
+			"timeout_ms": c.Plugin.TimeoutMS,
+		},
+		"transform": map[string]any{
+			"script": c.Transform.Script,
+		},
+		"viewers": map[string]any{
+			"enabled":       c.Viewers.Enabled,
+			"interval_secs": c.Viewers.IntervalSecs,
+		},
+		"backfill": map[string]any{
+			"enabled":      c.Backfill.Enabled,
+			"delay_secs":   c.Backfill.DelaySecs,
+			"max_gap_secs": c.Backfill.MaxGapSecs,
 		},
 	}
 	return payload
 }
 
+func (c BackoffConfig) redacted() map[string]any {
+	return map[string]any{
+		"initial_ms": c.InitialMS,
+		"max_ms":     c.MaxMS,
+		"multiplier": c.Multiplier,
+		"jitter":     c.Jitter,
+	}
+}
+
 func (c Config) RedactedJSON() []byte {
 	data, _ := json.MarshalIndent(c.Redacted(), "", "  ")
 	return data
@@ -419,6 +1071,16 @@ func redactString(value string) string {
 	return "***REDACTED*** (len=" + strconv.Itoa(len(value)) + ")"
 }
 
+// redactURLUserinfo hides credentials embedded in a URL.
+func redactURLUserinfo(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = url.User("***REDACTED***")
+	return u.String()
+}
+
 func (c Config) HasSink(name string) bool {
 	name = strings.ToLower(strings.TrimSpace(name))
 	for _, s := range c.Sinks {
@@ -436,6 +1098,89 @@ func (c Config) FlushInterval() time.Duration {
 	return time.Duration(c.Sink.FlushMaxMS) * time.Millisecond
 }
 
+// HeartbeatInterval returns the heartbeat log cadence; zero disables it.
+func (c Config) HeartbeatInterval() time.Duration {
+	if c.HeartbeatSecs <= 0 {
+		return 0
+	}
+	return time.Duration(c.HeartbeatSecs) * time.Second
+}
+
+// WatchdogIdle returns how long ingest may stay quiet before the systemd
+// watchdog ping is withheld; zero disables the check.
+func (c Config) WatchdogIdle() time.Duration {
+	return time.Duration(c.WatchdogIdleSecs) * time.Second
+}
+
+// MirrorWindow returns how far apart cross-platform copies of a message may
+// arrive to be tagged as mirrors; zero disables detection.
+func (c Config) MirrorWindow() time.Duration {
+	if c.MirrorWindowMS <= 0 {
+		return 0
+	}
+	return time.Duration(c.MirrorWindowMS) * time.Millisecond
+}
+
+// SpamWindow returns how far apart messages of one spam burst may arrive.
+func (c Config) SpamWindow() time.Duration {
+	return time.Duration(c.Spam.WindowSecs) * time.Second
+}
+
+// MaintenanceInterval returns the SQLite maintenance cadence; zero disables
+// scheduled runs.
+func (c Config) MaintenanceInterval() time.Duration {
+	if c.Sink.SQLite.MaintenanceSecs <= 0 {
+		return 0
+	}
+	return time.Duration(c.Sink.SQLite.MaintenanceSecs) * time.Second
+}
+
+// SQLiteBusyTimeout returns how long shared-mode writes wait for another
+// writer's lock.
+func (c Config) SQLiteBusyTimeout() time.Duration {
+	return time.Duration(c.Sink.SQLite.BusyTimeoutMS) * time.Millisecond
+}
+
+// WALMaxBytes returns the WAL size that triggers early maintenance; zero
+// disables the size trigger.
+func (c Config) WALMaxBytes() int64 {
+	if c.Sink.SQLite.WALMaxMB <= 0 {
+		return 0
+	}
+	return int64(c.Sink.SQLite.WALMaxMB) << 20
+}
+
+// LeaseTTL returns how long a receiver lease stays valid without renewal.
+func (c Config) LeaseTTL() time.Duration {
+	return time.Duration(c.Cluster.LeaseTTLSecs) * time.Second
+}
+
+// ViewerSampleInterval returns how often viewer counts are sampled.
+func (c Config) ViewerSampleInterval() time.Duration {
+	return time.Duration(c.Viewers.IntervalSecs) * time.Second
+}
+
+// BackfillDelay returns the wait before a gap is backfilled.
+func (c Config) BackfillDelay() time.Duration {
+	return time.Duration(c.Backfill.DelaySecs) * time.Second
+}
+
+// BackfillMaxGap returns the longest window backfilled per gap.
+func (c Config) BackfillMaxGap() time.Duration {
+	return time.Duration(c.Backfill.MaxGapSecs) * time.Second
+}
+
+// ShadowVerifyInterval returns how often the shadow database is verified.
+func (c Config) ShadowVerifyInterval() time.Duration {
+	return time.Duration(c.Sink.Shadow.VerifySecs) * time.Second
+}
+
+// ShadowWindow returns the width of each window compared during shadow
+// verification.
+func (c Config) ShadowWindow() time.Duration {
+	return time.Duration(c.Sink.Shadow.WindowSecs) * time.Second
+}
+
 func (c Config) Batch() int {
 	if c.Sink.BatchSize <= 0 {
 		return defaultBatchSize
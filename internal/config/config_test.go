@@ -1,6 +1,7 @@
 package config
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -45,6 +46,24 @@ func TestLoadDefaults(t *testing.T) {
 	if cfg.YouTube.Debug {
 		t.Fatalf("expected youtube debug default false")
 	}
+	if cfg.YouTube.CheckpointMaxAgeSecs != 300 {
+		t.Fatalf("expected youtube checkpoint max age default 300, got %d", cfg.YouTube.CheckpointMaxAgeSecs)
+	}
+	if cfg.Backfill.Enabled || cfg.BackfillDelay() != 5*time.Minute || cfg.BackfillMaxGap() != 6*time.Hour {
+		t.Fatalf("unexpected backfill defaults %+v", cfg.Backfill)
+	}
+	if cfg.Twitch.Presence || cfg.Twitch.PresenceSample != 1 {
+		t.Fatalf("expected presence off with full sampling by default, got %t/%v", cfg.Twitch.Presence, cfg.Twitch.PresenceSample)
+	}
+	if cfg.Twitch.Whispers || cfg.Redis.Whispers {
+		t.Fatalf("expected whisper capture and publishing off by default")
+	}
+	if cfg.Twitch.ChannelsPerConn != 50 {
+		t.Fatalf("expected 50 channels per connection by default, got %d", cfg.Twitch.ChannelsPerConn)
+	}
+	if b := cfg.Twitch.Backoff; b.InitialMS != 1000 || b.MaxMS != 60000 || b.Multiplier != 2 || b.Jitter != 0.2 {
+		t.Fatalf("unexpected default twitch backoff %+v", b)
+	}
 }
 
 func TestLoadEnvOverrides(t *testing.T) {
@@ -63,6 +82,15 @@ func TestLoadEnvOverrides(t *testing.T) {
 	t.Setenv("GNASTY_YT_POLL_TIMEOUT_SECS", "60")
 	t.Setenv("GNASTY_YT_POLL_INTERVAL_MS", "1500")
 	t.Setenv("GNASTY_YT_DEBUG", "yes")
+	t.Setenv("GNASTY_YT_BACKOFF_INITIAL_MS", "2000")
+	t.Setenv("GNASTY_YT_BACKOFF_MAX_MS", "120000")
+	t.Setenv("GNASTY_YT_BACKOFF_MULTIPLIER", "1.5")
+	t.Setenv("GNASTY_YT_BACKOFF_JITTER", "0")
+	t.Setenv("GNASTY_YT_CHECKPOINT_MAX_AGE_SECS", "0")
+	t.Setenv("GNASTY_BACKFILL", "true")
+	t.Setenv("GNASTY_BACKFILL_DELAY_SECS", "600")
+	t.Setenv("GNASTY_BACKFILL_MAX_GAP_SECS", "3600")
+	t.Setenv("GNASTY_TRANSFORM_SCRIPT", " /etc/gnasty/transform.lua ")
 
 	cfg := Load()
 	if cfg.Sink.SQLite.Path != "/data/elora.db" {
@@ -110,6 +138,19 @@ func TestLoadEnvOverrides(t *testing.T) {
 	if !cfg.YouTube.Debug {
 		t.Fatalf("expected youtube debug override")
 	}
+	if cfg.YouTube.CheckpointMaxAgeSecs != 0 {
+		t.Fatalf("expected youtube checkpointing disabled by override, got %d", cfg.YouTube.CheckpointMaxAgeSecs)
+	}
+	if !cfg.Backfill.Enabled || cfg.BackfillDelay() != 10*time.Minute || cfg.BackfillMaxGap() != time.Hour {
+		t.Fatalf("unexpected backfill overrides %+v", cfg.Backfill)
+	}
+	if cfg.Transform.Script != "/etc/gnasty/transform.lua" {
+		t.Fatalf("unexpected transform script %q", cfg.Transform.Script)
+	}
+	p := cfg.YouTube.Backoff.Policy()
+	if p.Initial != 2*time.Second || p.Max != 2*time.Minute || p.Multiplier != 1.5 || p.Jitter >= 0 {
+		t.Fatalf("unexpected youtube backoff policy %+v", p)
+	}
 }
 
 func TestRedactedSnapshot(t *testing.T) {
@@ -252,3 +293,318 @@ func TestTwitchRefreshEnabledDerivation(t *testing.T) {
 		})
 	}
 }
+
+func TestHeartbeatInterval(t *testing.T) {
+	t.Setenv("GNASTY_HEARTBEAT_SECS", "")
+	if got := Load().HeartbeatInterval(); got != time.Minute {
+		t.Fatalf("expected default heartbeat 1m, got %s", got)
+	}
+
+	t.Setenv("GNASTY_HEARTBEAT_SECS", "0")
+	if got := Load().HeartbeatInterval(); got != 0 {
+		t.Fatalf("expected heartbeat disabled, got %s", got)
+	}
+
+	t.Setenv("GNASTY_HEARTBEAT_SECS", "300")
+	if got := Load().HeartbeatInterval(); got != 5*time.Minute {
+		t.Fatalf("expected heartbeat 5m, got %s", got)
+	}
+}
+
+func TestWatchdogIdle(t *testing.T) {
+	t.Setenv("GNASTY_WATCHDOG_IDLE_SECS", "")
+	if got := Load().WatchdogIdle(); got != 0 {
+		t.Fatalf("expected the idle check off by default, got %s", got)
+	}
+
+	t.Setenv("GNASTY_WATCHDOG_IDLE_SECS", "900")
+	if got := Load().WatchdogIdle(); got != 15*time.Minute {
+		t.Fatalf("expected watchdog idle 15m, got %s", got)
+	}
+}
+
+func TestSQLiteMaintenance(t *testing.T) {
+	t.Setenv("GNASTY_SQLITE_MAINTENANCE_SECS", "")
+	t.Setenv("GNASTY_SQLITE_WAL_MAX_MB", "")
+	t.Setenv("GNASTY_SQLITE_SHARED", "")
+	t.Setenv("GNASTY_SQLITE_BUSY_TIMEOUT_MS", "")
+	cfg := Load()
+	if got := cfg.MaintenanceInterval(); got != time.Hour {
+		t.Fatalf("expected default maintenance 1h, got %s", got)
+	}
+	if got := cfg.WALMaxBytes(); got != 64<<20 {
+		t.Fatalf("expected default WAL limit 64MiB, got %d", got)
+	}
+
+	if cfg.Sink.SQLite.Shared || cfg.SQLiteBusyTimeout() != 15*time.Second {
+		t.Fatalf("expected unshared sqlite with a 15s busy timeout, got %v/%s", cfg.Sink.SQLite.Shared, cfg.SQLiteBusyTimeout())
+	}
+
+	t.Setenv("GNASTY_SQLITE_MAINTENANCE_SECS", "0")
+	t.Setenv("GNASTY_SQLITE_WAL_MAX_MB", "0")
+	cfg = Load()
+	if cfg.MaintenanceInterval() != 0 || cfg.WALMaxBytes() != 0 {
+		t.Fatalf("expected maintenance disabled, got %s/%d", cfg.MaintenanceInterval(), cfg.WALMaxBytes())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Config{
+		Sinks: []string{"sqlite"},
+		Sink:  SinkConfig{SQLite: SQLiteConfig{Path: "chat.db"}},
+		Twitch: TwitchConfig{
+			Enabled:  true,
+			Channels: []string{"elora"},
+		},
+	}
+	if err := valid.Validate(); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+
+	noReceivers := valid
+	noReceivers.Twitch = TwitchConfig{}
+	if err := noReceivers.Validate(); err == nil {
+		t.Fatalf("expected error when no receivers configured")
+	}
+
+	replayOnly := noReceivers
+	replayOnly.Replay = ReplayConfig{File: "chat.ndjson", Speed: 1, MaxGapMS: 5000}
+	if err := replayOnly.Validate(); err != nil {
+		t.Fatalf("expected a replay file to count as a receiver, got %v", err)
+	}
+	replayOnly.Replay.Speed = -1
+	if err := replayOnly.Validate(); err == nil || !strings.Contains(err.Error(), "GNASTY_REPLAY_SPEED") {
+		t.Fatalf("expected a negative replay speed error, got %v", err)
+	}
+
+	chaos := valid
+	chaos.Chaos = ChaosConfig{Disconnect: 1.5, SlowRead: 0.1}
+	if err := chaos.Validate(); err == nil || !strings.Contains(err.Error(), "GNASTY_CHAOS_DISCONNECT") ||
+		!strings.Contains(err.Error(), "GNASTY_CHAOS_SLOW_READ_MS") {
+		t.Fatalf("expected chaos probability and delay errors, got %v", err)
+	}
+
+	missingTokenFile := valid
+	missingTokenFile.Twitch.ClientID = "id"
+	missingTokenFile.Twitch.ClientSecret = "secret"
+	missingTokenFile.Twitch.RefreshToken = "refresh"
+	if err := missingTokenFile.Validate(); err == nil {
+		t.Fatalf("expected error when refresh inputs lack a token file")
+	}
+
+	unknownSink := valid
+	unknownSink.Sinks = []string{"sqlite", "kafka"}
+	if err := unknownSink.Validate(); err == nil {
+		t.Fatalf("expected error for unknown sink")
+	}
+
+	bothSQLite := valid
+	bothSQLite.Sinks = []string{"sqlite", "sqlite-channels"}
+	bothSQLite.Sink.SQLite.Dir = "chat-data"
+	if err := bothSQLite.Validate(); err == nil {
+		t.Fatalf("expected error when sqlite and sqlite-channels are combined")
+	}
+
+	channelsNoDir := valid
+	channelsNoDir.Sinks = []string{"sqlite-channels"}
+	if err := channelsNoDir.Validate(); err == nil {
+		t.Fatalf("expected error when sqlite-channels lacks a data dir")
+	}
+
+	openSearchNoURL := valid
+	openSearchNoURL.Sinks = []string{"sqlite", "opensearch"}
+	if err := openSearchNoURL.Validate(); err == nil {
+		t.Fatalf("expected error when opensearch lacks a url")
+	}
+
+	openSearchBadURL := openSearchNoURL
+	openSearchBadURL.Sink.OpenSearch.URL = "search:9200"
+	if err := openSearchBadURL.Validate(); err == nil {
+		t.Fatalf("expected error for non-http opensearch url")
+	}
+
+	profilesNoCreds := valid
+	profilesNoCreds.Twitch.Profiles = true
+	if err := profilesNoCreds.Validate(); err == nil {
+		t.Fatalf("expected error when twitch profiles lack client credentials")
+	}
+
+	badErasure := valid
+	badErasure.Admin.ErasureMode = "shred"
+	if err := badErasure.Validate(); err == nil {
+		t.Fatalf("expected error for unknown erasure mode")
+	}
+
+	badMoments := valid
+	badMoments.Moments = MomentsConfig{Enabled: true, MinZScore: 0}
+	if err := badMoments.Validate(); err == nil {
+		t.Fatalf("expected error for non-positive moments z-score")
+	}
+
+	streamStatusNoCreds := valid
+	streamStatusNoCreds.Twitch.StreamStatus = true
+	if err := streamStatusNoCreds.Validate(); err == nil {
+		t.Fatalf("expected error when twitch stream status lacks client credentials")
+	}
+
+	pollsNoClientID := valid
+	pollsNoClientID.Twitch.Polls = true
+	pollsNoClientID.Twitch.ClientID = ""
+	if err := pollsNoClientID.Validate(); err == nil {
+		t.Fatalf("expected error when twitch polls lack a client id")
+	}
+
+	raidsNoSQLite := valid
+	raidsNoSQLite.Twitch.Raids = true
+	raidsNoSQLite.Twitch.ClientID = "cid"
+	raidsNoSQLite.Sinks = []string{"mqtt"}
+	raidsNoSQLite.Sink.MQTT.URL = "tcp://broker:1883"
+	if err := raidsNoSQLite.Validate(); err == nil {
+		t.Fatalf("expected error when twitch raids lack the sqlite sink")
+	}
+
+	shortLease := valid
+	shortLease.Cluster = ClusterConfig{LeaderElection: true, InstanceID: "a", LeaseTTLSecs: 1}
+	if err := shortLease.Validate(); err == nil {
+		t.Fatalf("expected error for lease ttl below 3s")
+	}
+
+	leaderNoSQLite := valid
+	leaderNoSQLite.Sinks = []string{"mqtt"}
+	leaderNoSQLite.Sink.MQTT.URL = "tcp://broker:1883"
+	leaderNoSQLite.Cluster = ClusterConfig{LeaderElection: true, InstanceID: "a", LeaseTTLSecs: 15}
+	if err := leaderNoSQLite.Validate(); err == nil {
+		t.Fatalf("expected error when leader election lacks the sqlite sink")
+	}
+
+	badRedis := valid
+	badRedis.Redis.URL = "http://cache:6379"
+	if err := badRedis.Validate(); err == nil {
+		t.Fatalf("expected error for non-redis url")
+	}
+
+	viewersNoSQLite := valid
+	viewersNoSQLite.Sinks = []string{"mqtt"}
+	viewersNoSQLite.Sink.MQTT.URL = "tcp://broker:1883"
+	viewersNoSQLite.Viewers = ViewersConfig{Enabled: true, IntervalSecs: 60}
+	if err := viewersNoSQLite.Validate(); err == nil {
+		t.Fatalf("expected error when viewer sampling lacks the sqlite sink")
+	}
+
+	viewersTooFast := valid
+	viewersTooFast.Viewers = ViewersConfig{Enabled: true, IntervalSecs: 5}
+	if err := viewersTooFast.Validate(); err == nil {
+		t.Fatalf("expected error for viewer sample interval below 10s")
+	}
+
+	backfillNoSQLite := valid
+	backfillNoSQLite.Sinks = []string{"mqtt"}
+	backfillNoSQLite.Sink.MQTT.URL = "tcp://broker:1883"
+	backfillNoSQLite.Backfill = BackfillConfig{Enabled: true, DelaySecs: 300, MaxGapSecs: 3600}
+	if err := backfillNoSQLite.Validate(); err == nil {
+		t.Fatalf("expected error when backfill lacks the sqlite sink")
+	}
+
+	backfillTooSoon := valid
+	backfillTooSoon.Backfill = BackfillConfig{Enabled: true, DelaySecs: 5, MaxGapSecs: 3600}
+	if err := backfillTooSoon.Validate(); err == nil || !strings.Contains(err.Error(), "GNASTY_BACKFILL_DELAY_SECS") {
+		t.Fatalf("expected error for a backfill delay below 30s, got %v", err)
+	}
+
+	pluginNoTimeout := valid
+	pluginNoTimeout.Plugin = PluginConfig{Command: "enrich", TimeoutMS: 0}
+	if err := pluginNoTimeout.Validate(); err == nil {
+		t.Fatalf("expected error for exec plugin without a timeout")
+	}
+
+	badPresence := valid
+	badPresence.Twitch.Presence = true
+	badPresence.Twitch.PresenceSample = 0
+	if err := badPresence.Validate(); err == nil {
+		t.Fatalf("expected error for a zero presence sample")
+	}
+	badPresence.Twitch.PresenceSample = 0.25
+	if err := badPresence.Validate(); err != nil {
+		t.Fatalf("expected sampled presence to validate, got %v", err)
+	}
+
+	badIngest := valid
+	badIngest.YouTube.IngestRate = -5
+	if err := badIngest.Validate(); err == nil || !strings.Contains(err.Error(), "GNASTY_YT_INGEST_RATE") {
+		t.Fatalf("expected error for a negative ingest rate, got %v", err)
+	}
+	badIngest.YouTube.IngestRate = 20
+	badIngest.IngestOverflowSample = 1
+	if err := badIngest.Validate(); err == nil || !strings.Contains(err.Error(), "GNASTY_INGEST_OVERFLOW_SAMPLE") {
+		t.Fatalf("expected error for an overflow sample of 1, got %v", err)
+	}
+	badIngest.IngestOverflowSample = 0.1
+	if err := badIngest.Validate(); err != nil {
+		t.Fatalf("expected ingest limits to validate, got %v", err)
+	}
+
+	badSpam := valid
+	badSpam.Spam = SpamConfig{Enabled: true, WindowSecs: 30, MinAccounts: 1}
+	if err := badSpam.Validate(); err == nil || !strings.Contains(err.Error(), "GNASTY_SPAM_MIN_ACCOUNTS") {
+		t.Fatalf("expected error for a single-account spam burst, got %v", err)
+	}
+
+	sharedDB := valid
+	sharedDB.Sink.SQLite.WriteToken = "secret"
+	if err := sharedDB.Validate(); err == nil || !strings.Contains(err.Error(), "GNASTY_SQLITE_SHARED") {
+		t.Fatalf("expected a write token without shared mode to fail, got %v", err)
+	}
+	sharedDB.Sink.SQLite.Shared = true
+	sharedDB.Sink.SQLite.BusyTimeoutMS = 15000
+	if err := sharedDB.Validate(); err != nil {
+		t.Fatalf("expected shared mode to validate, got %v", err)
+	}
+	sharedDB.Sink.SQLite.BusyTimeoutMS = 0
+	if err := sharedDB.Validate(); err == nil || !strings.Contains(err.Error(), "GNASTY_SQLITE_BUSY_TIMEOUT_MS") {
+		t.Fatalf("expected error for a zero busy timeout, got %v", err)
+	}
+
+	badPerConn := valid
+	badPerConn.Twitch.ChannelsPerConn = -1
+	if err := badPerConn.Validate(); err == nil {
+		t.Fatalf("expected error for negative channels per connection")
+	}
+
+	badBackoff := valid
+	badBackoff.Twitch.Backoff = BackoffConfig{InitialMS: 5000, MaxMS: 1000, Multiplier: 2}
+	if err := badBackoff.Validate(); err == nil {
+		t.Fatalf("expected error for backoff max below initial")
+	}
+	badJitter := valid
+	badJitter.YouTube.Backoff = BackoffConfig{InitialMS: 1000, MaxMS: 5000, Multiplier: 0.5, Jitter: 1.5}
+	if err := badJitter.Validate(); err == nil || !strings.Contains(err.Error(), "GNASTY_YT_BACKOFF_JITTER") ||
+		!strings.Contains(err.Error(), "GNASTY_YT_BACKOFF_MULTIPLIER") {
+		t.Fatalf("expected multiplier and jitter errors, got %v", err)
+	}
+
+	badRules := valid
+	badRules.UsernameRules = "youtube:lower,reverse"
+	if err := badRules.Validate(); err == nil {
+		t.Fatalf("expected error for unknown username rule")
+	}
+
+	badProxy := valid
+	badProxy.YouTube.Proxy = "ftp://proxy.local"
+	if err := badProxy.Validate(); err == nil || !strings.Contains(err.Error(), "GNASTY_YT_PROXY") {
+		t.Fatalf("expected proxy scheme error, got %v", err)
+	}
+}
+
+func TestProxyFallback(t *testing.T) {
+	cfg := Config{Proxy: "socks5://user:pw@proxy.local:1080"}
+	cfg.Twitch.Proxy = "http://twitch-proxy.local:3128"
+	if got := cfg.TwitchProxy(); got != "http://twitch-proxy.local:3128" {
+		t.Fatalf("TwitchProxy() = %q", got)
+	}
+	if got := cfg.YouTubeProxy(); got != cfg.Proxy {
+		t.Fatalf("YouTubeProxy() = %q, want the global proxy", got)
+	}
+	if got := cfg.Summary().YouTube.Proxy; strings.Contains(got, "pw") {
+		t.Fatalf("summary leaks proxy credentials: %q", got)
+	}
+}
@@ -0,0 +1,285 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strings"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/proxy"
+)
+
+// supportedSinks lists the sink names the harvester knows how to open.
+var supportedSinks = []string{"sqlite", "sqlite-channels", "mqtt", "opensearch", "streamerbot"}
+
+// Validate checks cross-field constraints that do not require I/O. Every
+// violation is reported; the returned error joins them in order.
+func (c Config) Validate() error {
+	var errs []error
+
+	for _, name := range c.Sinks {
+		if !isSupportedSink(name) {
+			errs = append(errs, fmt.Errorf("unknown sink %q (supported: %s)", name, strings.Join(supportedSinks, ", ")))
+		}
+	}
+	if len(c.Sinks) == 0 {
+		errs = append(errs, errors.New("no sinks configured"))
+	}
+	if c.HasSink("sqlite") && strings.TrimSpace(c.Sink.SQLite.Path) == "" {
+		errs = append(errs, errors.New("sqlite sink enabled but GNASTY_SINK_SQLITE_PATH is empty"))
+	}
+	if c.HasSink("sqlite-channels") {
+		if c.HasSink("sqlite") {
+			errs = append(errs, errors.New("the sqlite and sqlite-channels sinks cannot be combined"))
+		}
+		if strings.TrimSpace(c.Sink.SQLite.Dir) == "" {
+			errs = append(errs, errors.New("sqlite-channels sink enabled but GNASTY_SINK_SQLITE_DIR is empty"))
+		}
+	}
+
+	if c.HasSink("mqtt") {
+		if strings.TrimSpace(c.Sink.MQTT.URL) == "" {
+			errs = append(errs, errors.New("mqtt sink enabled but GNASTY_SINK_MQTT_URL is empty"))
+		}
+		if c.Sink.MQTT.QoS != 0 && c.Sink.MQTT.QoS != 1 {
+			errs = append(errs, errors.New("GNASTY_SINK_MQTT_QOS must be 0 or 1"))
+		}
+	}
+
+	if c.HasSink("opensearch") {
+		raw := strings.TrimSpace(c.Sink.OpenSearch.URL)
+		if raw == "" {
+			errs = append(errs, errors.New("opensearch sink enabled but GNASTY_SINK_OPENSEARCH_URL is empty"))
+		} else if u, err := url.Parse(raw); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("GNASTY_SINK_OPENSEARCH_URL must be an http:// or https:// URL, got %q", redactURLUserinfo(raw)))
+		}
+	}
+
+	if c.HasSink("streamerbot") {
+		if u, err := url.Parse(c.Sink.StreamerBot.URL); err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("GNASTY_SINK_STREAMERBOT_URL must be a ws:// or wss:// URL, got %q", c.Sink.StreamerBot.URL))
+		}
+	}
+
+	twitchOn := c.Twitch.Enabled && len(c.Twitch.Channels) > 0
+	youtubeOn := c.YouTube.Enabled && strings.TrimSpace(c.YouTube.LiveURL) != ""
+	replayOn := c.Replay.File != ""
+	if !twitchOn && !youtubeOn && !replayOn {
+		errs = append(errs, errors.New("no receivers configured (set GNASTY_TWITCH_CHANNELS, GNASTY_YT_URL or GNASTY_REPLAY_FILE)"))
+	}
+	if replayOn {
+		if c.Replay.Speed < 0 {
+			errs = append(errs, errors.New("GNASTY_REPLAY_SPEED must not be negative"))
+		}
+		if c.Replay.MaxGapMS <= 0 {
+			errs = append(errs, errors.New("GNASTY_REPLAY_MAX_GAP_MS must be positive"))
+		}
+	}
+	if c.Twitch.Enabled && len(c.Twitch.Channels) == 0 {
+		errs = append(errs, errors.New("twitch enabled but no channels configured"))
+	}
+
+	if c.Twitch.ChannelsPerConn < 0 {
+		errs = append(errs, errors.New("GNASTY_TWITCH_CHANNELS_PER_CONN must not be negative"))
+	}
+
+	if twitchOn && c.Summary().Twitch.RefreshEnabled && strings.TrimSpace(c.Twitch.TokenFile) == "" {
+		errs = append(errs, errors.New("twitch token file is required when refresh inputs are provided"))
+	}
+
+	if c.Twitch.Profiles && (strings.TrimSpace(c.Twitch.ClientID) == "" || strings.TrimSpace(c.Twitch.ClientSecret) == "") {
+		errs = append(errs, errors.New("GNASTY_TWITCH_PROFILES requires twitch client id and secret"))
+	}
+	if c.Twitch.StreamStatus && (strings.TrimSpace(c.Twitch.ClientID) == "" || strings.TrimSpace(c.Twitch.ClientSecret) == "") {
+		errs = append(errs, errors.New("GNASTY_TWITCH_STREAM_STATUS requires twitch client id and secret"))
+	}
+	for _, eventsub := range []struct {
+		name string
+		on   bool
+	}{{"GNASTY_TWITCH_POLLS", c.Twitch.Polls}, {"GNASTY_TWITCH_RAIDS", c.Twitch.Raids}, {"GNASTY_TWITCH_AUTOMOD", c.Twitch.AutoMod},
+		{"GNASTY_TWITCH_REDEMPTIONS", c.Twitch.Redemptions}} {
+		if !eventsub.on {
+			continue
+		}
+		if strings.TrimSpace(c.Twitch.ClientID) == "" {
+			errs = append(errs, fmt.Errorf("%s requires a twitch client id", eventsub.name))
+		}
+		if !c.HasSink("sqlite") {
+			errs = append(errs, fmt.Errorf("%s requires the sqlite sink", eventsub.name))
+		}
+	}
+
+	if c.Twitch.Presence {
+		if !c.HasSink("sqlite") {
+			errs = append(errs, errors.New("GNASTY_TWITCH_PRESENCE requires the sqlite sink"))
+		}
+		if c.Twitch.PresenceSample <= 0 || c.Twitch.PresenceSample > 1 {
+			errs = append(errs, errors.New("GNASTY_TWITCH_PRESENCE_SAMPLE must be greater than 0 and at most 1"))
+		}
+	}
+
+	for _, b := range []struct {
+		prefix string
+		cfg    BackoffConfig
+	}{{"GNASTY_TWITCH", c.Twitch.Backoff}, {"GNASTY_YT", c.YouTube.Backoff}} {
+		if b.cfg.InitialMS > 0 && b.cfg.MaxMS > 0 && b.cfg.MaxMS < b.cfg.InitialMS {
+			errs = append(errs, fmt.Errorf("%s_BACKOFF_MAX_MS must be at least %s_BACKOFF_INITIAL_MS", b.prefix, b.prefix))
+		}
+		if b.cfg.Multiplier != 0 && b.cfg.Multiplier < 1 {
+			errs = append(errs, fmt.Errorf("%s_BACKOFF_MULTIPLIER must be at least 1", b.prefix))
+		}
+		if b.cfg.Jitter < 0 || b.cfg.Jitter > 1 {
+			errs = append(errs, fmt.Errorf("%s_BACKOFF_JITTER must be between 0 and 1", b.prefix))
+		}
+	}
+
+	for _, r := range []struct {
+		env  string
+		rate float64
+	}{{"GNASTY_TWITCH_INGEST_RATE", c.Twitch.IngestRate}, {"GNASTY_YT_INGEST_RATE", c.YouTube.IngestRate}} {
+		if r.rate < 0 || math.IsInf(r.rate, 0) || math.IsNaN(r.rate) {
+			errs = append(errs, fmt.Errorf("%s must be a non-negative number of messages per second", r.env))
+		}
+	}
+	if c.IngestOverflowSample < 0 || c.IngestOverflowSample >= 1 || math.IsNaN(c.IngestOverflowSample) {
+		errs = append(errs, errors.New("GNASTY_INGEST_OVERFLOW_SAMPLE must be at least 0 and less than 1"))
+	}
+
+	switch c.Admin.ErasureMode {
+	case "", "delete", "redact":
+	default:
+		errs = append(errs, fmt.Errorf("GNASTY_ERASURE_MODE must be delete or redact, got %q", c.Admin.ErasureMode))
+	}
+
+	if shadow := c.Sink.Shadow; shadow.SQLitePath != "" {
+		if !c.HasSink("sqlite") {
+			errs = append(errs, errors.New("GNASTY_SHADOW_SQLITE_PATH requires the sqlite sink"))
+		}
+		if shadow.SQLitePath == c.Sink.SQLite.Path {
+			errs = append(errs, errors.New("GNASTY_SHADOW_SQLITE_PATH must differ from GNASTY_SINK_SQLITE_PATH"))
+		}
+		if shadow.VerifySecs < 10 {
+			errs = append(errs, errors.New("GNASTY_SHADOW_VERIFY_SECS must be at least 10"))
+		}
+		if shadow.WindowSecs < 1 || shadow.WindowSecs > 3600 {
+			errs = append(errs, errors.New("GNASTY_SHADOW_WINDOW_SECS must be between 1 and 3600"))
+		}
+	}
+
+	if sqlite := c.Sink.SQLite; sqlite.Shared {
+		if !c.HasSink("sqlite") {
+			errs = append(errs, errors.New("GNASTY_SQLITE_SHARED requires the sqlite sink"))
+		}
+		if sqlite.BusyTimeoutMS < 100 || sqlite.BusyTimeoutMS > 120000 {
+			errs = append(errs, errors.New("GNASTY_SQLITE_BUSY_TIMEOUT_MS must be between 100 and 120000"))
+		}
+	} else if sqlite.WriteToken != "" {
+		errs = append(errs, errors.New("GNASTY_SQLITE_WRITE_TOKEN requires GNASTY_SQLITE_SHARED"))
+	}
+
+	if c.Cluster.LeaderElection {
+		if !c.HasSink("sqlite") {
+			errs = append(errs, errors.New("GNASTY_LEADER_ELECTION requires the sqlite sink"))
+		}
+		if c.Cluster.LeaseTTLSecs < 3 {
+			errs = append(errs, errors.New("GNASTY_LEASE_TTL_SECS must be at least 3"))
+		}
+	}
+
+	if c.Redis.URL != "" {
+		if u, err := url.Parse(c.Redis.URL); err != nil || (u.Scheme != "redis" && u.Scheme != "rediss") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("GNASTY_REDIS_URL must be a redis:// or rediss:// URL, got %q", redactURLUserinfo(c.Redis.URL)))
+		}
+	}
+
+	for _, p := range []struct {
+		env string
+		raw string
+	}{{"GNASTY_PROXY", c.Proxy}, {"GNASTY_TWITCH_PROXY", c.Twitch.Proxy}, {"GNASTY_YT_PROXY", c.YouTube.Proxy}} {
+		if _, err := proxy.Parse(p.raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", p.env, err))
+		}
+	}
+
+	if c.Viewers.Enabled {
+		if !c.HasSink("sqlite") {
+			errs = append(errs, errors.New("GNASTY_VIEWER_SAMPLES requires the sqlite sink"))
+		}
+		if c.Viewers.IntervalSecs < 10 {
+			errs = append(errs, errors.New("GNASTY_VIEWER_SAMPLE_SECS must be at least 10"))
+		}
+	}
+
+	if c.Backfill.Enabled {
+		if !c.HasSink("sqlite") {
+			errs = append(errs, errors.New("GNASTY_BACKFILL requires the sqlite sink"))
+		}
+		if c.Backfill.DelaySecs < 30 {
+			errs = append(errs, errors.New("GNASTY_BACKFILL_DELAY_SECS must be at least 30"))
+		}
+		if c.Backfill.MaxGapSecs < 60 {
+			errs = append(errs, errors.New("GNASTY_BACKFILL_MAX_GAP_SECS must be at least 60"))
+		}
+	}
+
+	for _, p := range []struct {
+		name  string
+		value float64
+	}{
+		{"GNASTY_CHAOS_DISCONNECT", c.Chaos.Disconnect},
+		{"GNASTY_CHAOS_SLOW_READ", c.Chaos.SlowRead},
+		{"GNASTY_CHAOS_HTTP_ERROR", c.Chaos.HTTPError},
+	} {
+		if p.value < 0 || p.value > 1 {
+			errs = append(errs, fmt.Errorf("%s must be between 0 and 1", p.name))
+		}
+	}
+	if c.Chaos.SlowRead > 0 && c.Chaos.SlowReadMS <= 0 {
+		errs = append(errs, errors.New("GNASTY_CHAOS_SLOW_READ_MS must be positive"))
+	}
+
+	if c.Plugin.Command != "" && c.Plugin.TimeoutMS <= 0 {
+		errs = append(errs, errors.New("GNASTY_EXEC_PLUGIN_TIMEOUT_MS must be positive"))
+	}
+
+	if c.Spam.Enabled {
+		if c.Spam.WindowSecs < 1 {
+			errs = append(errs, errors.New("GNASTY_SPAM_WINDOW_SECS must be at least 1"))
+		}
+		if c.Spam.MinAccounts < 2 {
+			errs = append(errs, errors.New("GNASTY_SPAM_MIN_ACCOUNTS must be at least 2"))
+		}
+		if c.Spam.NewAccountDays < 0 {
+			errs = append(errs, errors.New("GNASTY_SPAM_NEW_ACCOUNT_DAYS must not be negative"))
+		}
+	}
+
+	if c.Moments.Enabled && c.Moments.MinZScore <= 0 {
+		errs = append(errs, errors.New("GNASTY_MOMENTS_MIN_ZSCORE must be positive"))
+	}
+
+	if c.UsernameRules != "" {
+		if _, err := core.ParseUsernameRules(c.UsernameRules); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// SupportedSinks returns the sink names accepted in GNASTY_SINKS.
+func SupportedSinks() []string {
+	return append([]string(nil), supportedSinks...)
+}
+
+func isSupportedSink(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, s := range supportedSinks {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseAmount extracts the number from a displayed amount such as "$5.00",
+// "CA$1,234.56", "10,00 €" or "¥500". A separator followed by exactly one
+// or two digits at the end is taken as the decimal point; others group
+// thousands. The currency is not interpreted.
+func ParseAmount(display string) (float64, bool) {
+	start := strings.IndexFunc(display, isDigit)
+	if start < 0 {
+		return 0, false
+	}
+	end := strings.LastIndexFunc(display, isDigit) + 1
+	number := display[start:end]
+
+	decimal := -1
+	if i := strings.LastIndexAny(number, ".,"); i >= 0 {
+		if frac := len(number) - i - 1; frac == 1 || frac == 2 {
+			decimal = i
+		}
+	}
+	var b strings.Builder
+	for i, r := range number {
+		switch {
+		case isDigit(r):
+			b.WriteRune(r)
+		case i == decimal:
+			b.WriteByte('.')
+		case r == '.' || r == ',' || r == ' ' || r == '\u00a0' || r == '\'':
+		default:
+			return 0, false
+		}
+	}
+	v, err := strconv.ParseFloat(b.String(), 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+func isDigit(r rune) bool { return r >= '0' && r <= '9' }
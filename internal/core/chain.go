@@ -0,0 +1,33 @@
+package core
+
+// ChainReport is the result of re-validating the archive's hash chains.
+type ChainReport struct {
+	// OK is true when every chain verified.
+	OK bool `json:"ok"`
+	// Messages counts the chained messages checked.
+	Messages int64        `json:"messages"`
+	Chains   []ChainHead  `json:"chains"`
+	Failures []ChainBreak `json:"failures,omitempty"`
+	// Truncated is set when more failures were found than are listed.
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ChainHead describes one chain (a broadcast session, or a platform's
+// messages outside sessions). Publishing Head lets third parties detect a
+// chain rewritten from that point back.
+type ChainHead struct {
+	Chain  string `json:"chain"`
+	Length int64  `json:"length"`
+	Head   string `json:"head"`
+}
+
+// ChainBreak is a chain link that failed verification.
+type ChainBreak struct {
+	Chain string `json:"chain"`
+	Seq   int64  `json:"seq"`
+	// MessageID is the platform message ID, when the message still exists.
+	MessageID string `json:"message_id,omitempty"`
+	// Reason is "message missing", "content changed", "link broken" or
+	// "sequence gap".
+	Reason string `json:"reason"`
+}
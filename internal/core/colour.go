@@ -0,0 +1,55 @@
+package core
+
+import (
+	"hash/fnv"
+	"strings"
+)
+
+// DefaultColours is the palette Twitch assigns to chatters who never picked
+// a name colour. DefaultColour chooses from it.
+var DefaultColours = []string{
+	"#FF0000", "#0000FF", "#008000", "#B22222", "#FF7F50",
+	"#9ACD32", "#FF4500", "#2E8B57", "#DAA520", "#D2691E",
+	"#5F9EA0", "#1E90FF", "#FF69B4", "#8A2BE2", "#00FF7F",
+}
+
+// NormalizeColour returns raw as upper-case "#RRGGBB". It accepts a missing
+// '#' and the short "#RGB" form; anything else yields "".
+func NormalizeColour(raw string) string {
+	s := strings.TrimPrefix(strings.TrimSpace(raw), "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return ""
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return ""
+		}
+	}
+	return "#" + strings.ToUpper(s)
+}
+
+// DefaultColour picks a stable palette colour for a chatter, keyed by the
+// platform user ID when known and the lower-cased username otherwise, so the
+// same person keeps the same colour across messages and restarts.
+func DefaultColour(platform, userID, username string) string {
+	key := userID
+	if key == "" {
+		key = strings.ToLower(strings.TrimSpace(username))
+	}
+	h := fnv.New32a()
+	h.Write([]byte(strings.ToLower(platform) + ":" + key))
+	return DefaultColours[h.Sum32()%uint32(len(DefaultColours))]
+}
+
+// NormalizeMessageColour normalizes msg.Colour and, when assignDefault is set
+// and the message carries no usable colour, fills in DefaultColour.
+func NormalizeMessageColour(msg *ChatMessage, assignDefault bool) {
+	msg.Colour = NormalizeColour(msg.Colour)
+	if msg.Colour == "" && assignDefault && (msg.UserID != "" || msg.Username != "") {
+		msg.Colour = DefaultColour(msg.Platform, msg.UserID, msg.Username)
+	}
+}
@@ -0,0 +1,61 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+)
+
+// ErrorKind classifies receiver and sink failures so an expired token can be
+// told apart from a flaky network or a failing disk.
+type ErrorKind string
+
+const (
+	ErrorAuth    ErrorKind = "auth"
+	ErrorNetwork ErrorKind = "network"
+	ErrorParse   ErrorKind = "parse"
+	ErrorStorage ErrorKind = "storage"
+	// ErrorPanic marks a recovered receiver panic.
+	ErrorPanic ErrorKind = "panic"
+	ErrorOther ErrorKind = "other"
+)
+
+// Error is an error tagged with its kind.
+type Error struct {
+	Kind ErrorKind
+	Err  error
+}
+
+func (e *Error) Error() string { return e.Err.Error() }
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// NewError tags err with kind. It returns nil when err is nil.
+func NewError(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Kind: kind, Err: err}
+}
+
+// ErrorKindOf reports the kind of the outermost *Error in err's chain.
+// Untagged network and JSON errors are recognised; anything else is
+// ErrorOther.
+func ErrorKindOf(err error) ErrorKind {
+	var tagged *Error
+	if errors.As(err, &tagged) {
+		return tagged.Kind
+	}
+	var (
+		netErr    net.Error
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &netErr):
+		return ErrorNetwork
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return ErrorParse
+	}
+	return ErrorOther
+}
@@ -0,0 +1,149 @@
+package core
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// IDGenerator creates the globally unique message keys stored alongside
+// platform IDs. IDs for later times must sort after IDs for earlier ones.
+type IDGenerator interface {
+	NewID(t time.Time) string
+}
+
+// IDGeneratorFunc adapts a function to IDGenerator.
+type IDGeneratorFunc func(time.Time) string
+
+func (f IDGeneratorFunc) NewID(t time.Time) string { return f(t) }
+
+var (
+	idMu        sync.RWMutex
+	idGenerator IDGenerator = NewULIDGenerator()
+)
+
+// SetIDGenerator replaces the generator NewID uses; nil restores the default
+// ULID generator. It is meant for tests and tools that need predictable IDs.
+func SetIDGenerator(g IDGenerator) {
+	if g == nil {
+		g = NewULIDGenerator()
+	}
+	idMu.Lock()
+	idGenerator = g
+	idMu.Unlock()
+}
+
+// NewID returns a new message key for time t from the current generator.
+func NewID(t time.Time) string {
+	idMu.RLock()
+	g := idGenerator
+	idMu.RUnlock()
+	return g.NewID(t)
+}
+
+// AssignULID gives msg a key from NewID, based on its platform timestamp, if
+// it has none yet. Keys follow the message when it is copied between sinks.
+func AssignULID(msg *ChatMessage) {
+	if msg.ULID != "" {
+		return
+	}
+	t := msg.Ts
+	if msg.TimestampMS > 0 {
+		t = time.UnixMilli(msg.TimestampMS)
+	}
+	if t.IsZero() {
+		t = time.Now()
+	}
+	msg.ULID = NewID(t)
+}
+
+// crockford is the base32 alphabet ULIDs are written in.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDGenerator creates ULIDs (https://github.com/ulid/spec): a 48-bit
+// millisecond timestamp followed by 80 random bits, as 26 Crockford base32
+// characters. IDs generated within the same millisecond increment the
+// random part of the previous one, so they keep their generation order.
+type ULIDGenerator struct {
+	mu      sync.Mutex
+	lastMS  uint64
+	entropy [10]byte
+}
+
+// NewULIDGenerator returns a generator drawing entropy from crypto/rand.
+func NewULIDGenerator() *ULIDGenerator {
+	return &ULIDGenerator{}
+}
+
+func (g *ULIDGenerator) NewID(t time.Time) string {
+	ms := uint64(t.UnixMilli()) & (1<<48 - 1)
+
+	g.mu.Lock()
+	if ms != g.lastMS || !incrementEntropy(&g.entropy) {
+		_, _ = rand.Read(g.entropy[:])
+		g.lastMS = ms
+	}
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	copy(id[6:], g.entropy[:])
+	g.mu.Unlock()
+
+	return encodeULID(id)
+}
+
+// incrementEntropy adds one to the random part, reporting false when it
+// overflows.
+func incrementEntropy(b *[10]byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+	var out [26]byte
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// ULIDTime returns the timestamp encoded in a ULID, and false if id is not
+// one.
+func ULIDTime(id string) (time.Time, bool) {
+	if len(id) != 26 || id[0] > '7' {
+		return time.Time{}, false
+	}
+	var ms int64
+	for i := 0; i < len(id); i++ {
+		v := indexCrockford(id[i])
+		if v < 0 {
+			return time.Time{}, false
+		}
+		if i < 10 {
+			ms = ms<<5 | int64(v)
+		}
+	}
+	return time.UnixMilli(ms).UTC(), true
+}
+
+func indexCrockford(c byte) int {
+	if c >= 'a' && c <= 'z' {
+		c -= 'a' - 'A'
+	}
+	for i := 0; i < len(crockford); i++ {
+		if crockford[i] == c {
+			return i
+		}
+	}
+	return -1
+}
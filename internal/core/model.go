@@ -1,6 +1,10 @@
 package core
 
-import "time"
+import (
+	"strconv"
+	"strings"
+	"time"
+)
 
 // ChatBadge represents a normalized badge awarded to a chat participant.
 // Platform identifies the source (e.g., Twitch), ID is the badge slug, and
@@ -40,5 +44,160 @@ type ChatMessage struct {
 	BadgesJSON    string      // optional
 	Badges        []ChatBadge `json:"badges,omitempty"`
 	BadgesRaw     BadgesRaw   `json:"badges_raw,omitempty"`
-	Colour        string      // optional (e.g., Twitch)
+	Colour        string      // optional: "#RRGGBB" (see NormalizeColour)
+	// AuthorChannelID is the platform's stable author/channel ID when
+	// reported (YouTube authorExternalChannelId).
+	AuthorChannelID string `json:",omitempty"`
+	AvatarURL       string `json:",omitempty"` // optional: author avatar image
+	// SessionID identifies the broadcast session the message was sent
+	// during, when one was active.
+	SessionID string `json:",omitempty"`
+	// EditedAt is set once the author has edited the message; Text then
+	// holds the latest version.
+	EditedAt *time.Time `json:",omitempty"`
+	// Edits lists every stored version, oldest (the original) first, when
+	// requested with include_edits.
+	Edits []MessageEdit `json:",omitempty"`
+	// DeletedAt is set once a moderator (or the author) has removed the
+	// message; the original text is kept for the archive.
+	DeletedAt *time.Time `json:",omitempty"`
+	// DeletedBy records what removed the message, e.g. "twitch:timeout".
+	DeletedBy string `json:",omitempty"`
+	// MessageType classifies the message (MessageTypeChat, MessageTypeRaid,
+	// ...); empty is treated as MessageTypeChat.
+	MessageType string `json:",omitempty"`
+	// Channel is the platform channel the message was posted in (the
+	// Twitch login without "#"), set when a receiver watches several.
+	Channel string `json:",omitempty"`
+	// UserID is the platform's stable ID for the author (Twitch user-id,
+	// YouTube authorExternalChannelId); unlike Username it survives renames.
+	UserID string `json:",omitempty"`
+	// ChannelID is the platform's stable ID for Channel (Twitch room-id).
+	ChannelID string `json:",omitempty"`
+	// ReceivedAt is when the harvester received the message, by the local
+	// clock. Ts is the platform's timestamp, which can lag (YouTube
+	// timestampUsec) or disagree with a drifting host clock.
+	ReceivedAt *time.Time `json:",omitempty"`
+	// MirrorOf is set when the message repeats one received moments earlier
+	// on another platform, as a simulcast chat bridge does. It holds that
+	// message's "platform:id".
+	MirrorOf string `json:",omitempty"`
+	// PaidAmount is the amount of a paid message as the platform displays
+	// it, e.g. "$5.00" for a YouTube Super Chat. See ParseAmount.
+	PaidAmount string `json:",omitempty"`
+	// RewardID is the channel points reward a Twitch message was sent to
+	// redeem (the custom-reward-id tag); see Redemption.
+	RewardID string `json:",omitempty"`
+	// SpamScore, in [0, 1], is set when the message was part of a burst of
+	// near-identical messages from different accounts (see
+	// sink.SpamDetector); zero means it was not flagged.
+	SpamScore float64 `json:",omitempty"`
+	// ULID is the message's globally unique key, assigned at ingest (see
+	// AssignULID). It sorts by timestamp and, unlike the row ID, is kept
+	// when messages are copied or merged between sinks.
+	ULID string `json:",omitempty"`
+	// FirstMessage is set by receivers when the platform marks this as
+	// the author's first message in the channel (Twitch first-msg). It is
+	// an ingest hint and is not stored.
+	FirstMessage bool `json:"-"`
+}
+
+// ReceivedTime returns ReceivedAt, or Ts for messages stored before receive
+// times were recorded.
+func (m ChatMessage) ReceivedTime() time.Time {
+	if m.ReceivedAt != nil {
+		return *m.ReceivedAt
+	}
+	return m.Ts
+}
+
+// Normalized message types. Receivers set one on every message; stored
+// rows written before types existed read back as MessageTypeChat.
+const (
+	// MessageTypeChat marks an ordinary chat message.
+	MessageTypeChat = "chat"
+	// MessageTypeAction marks a /me (CTCP ACTION) message.
+	MessageTypeAction = "action"
+	// MessageTypeSystem marks other platform notices shown in chat.
+	MessageTypeSystem = "system"
+	// MessageTypeSuperchat marks a paid message (YouTube Super Chat or
+	// Super Sticker).
+	MessageTypeSuperchat = "superchat"
+	// MessageTypeRaid marks the message announcing a raid.
+	MessageTypeRaid = "raid"
+	// MessageTypeSub marks a new (or upgraded) subscription or YouTube
+	// membership.
+	MessageTypeSub = "sub"
+	// MessageTypeResub marks a shared subscription anniversary.
+	MessageTypeResub = "resub"
+	// MessageTypeSubGift marks gifted subscriptions.
+	MessageTypeSubGift = "subgift"
+	// MessageTypeAnnouncement marks a moderator /announce message.
+	MessageTypeAnnouncement = "announcement"
+	// MessageTypeWhisper marks a private message to the harvester's own
+	// account. Whispers are hidden from API reads unless asked for.
+	MessageTypeWhisper = "whisper"
+	// MessageTypeDeleted is not stored; as a filter it selects messages
+	// removed by moderation, whatever their type.
+	MessageTypeDeleted = "deleted"
+)
+
+// MessageTypes lists the values accepted by type filters.
+var MessageTypes = []string{
+	MessageTypeChat,
+	MessageTypeAction,
+	MessageTypeSystem,
+	MessageTypeSuperchat,
+	MessageTypeRaid,
+	MessageTypeSub,
+	MessageTypeResub,
+	MessageTypeSubGift,
+	MessageTypeAnnouncement,
+	MessageTypeWhisper,
+	MessageTypeDeleted,
+}
+
+// NormalizeMessageType returns t, or MessageTypeChat when t is empty.
+func NormalizeMessageType(t string) string {
+	if t = strings.ToLower(strings.TrimSpace(t)); t != "" {
+		return t
+	}
+	return MessageTypeChat
+}
+
+// MessageEdit is one stored version of an edited message.
+type MessageEdit struct {
+	Text       string    `json:"text"`
+	EmotesJSON string    `json:"emotes_json,omitempty"`
+	EditedAt   time.Time `json:"edited_at"`
+}
+
+// MessageUpdate replaces the text of a previously ingested message,
+// identified by its platform message ID.
+type MessageUpdate struct {
+	Platform   string
+	ID         string
+	Text       string
+	EmotesJSON string
+	EditedAt   time.Time
+}
+
+// MessageDeletion marks previously ingested messages as deleted. With ID set
+// it targets a single message; otherwise Author (a login or channel ID)
+// selects that author's earlier messages, and with neither set every earlier
+// message in the current session is affected (a chat clear). Only messages
+// in Channel are touched; YouTube messages have no channel.
+type MessageDeletion struct {
+	Platform  string
+	Channel   string
+	ID        string
+	Author    string
+	DeletedAt time.Time
+	DeletedBy string
+}
+
+// EventID identifies msg in a live stream ("<unix ms>:<id>"). Clients send
+// the last one they saw as Last-Event-ID to resume after a reconnect.
+func (m ChatMessage) EventID() string {
+	return strconv.FormatInt(m.Ts.UnixMilli(), 10) + ":" + m.ID
 }
@@ -0,0 +1,210 @@
+package core
+
+import "time"
+
+// Stream state kinds recorded in the stream_state table.
+const (
+	StreamLive      = "live"
+	StreamEnded     = "ended"
+	StreamRoomState = "roomstate"
+)
+
+// StreamState is a broadcast state transition (live/ended) or a chat room
+// setting change (roomstate) observed by a receiver. Channel identifies the
+// watched source (Twitch channel or configured YouTube URL).
+type StreamState struct {
+	Platform string
+	Channel  string
+	State    string
+	Detail   map[string]any
+	Ts       time.Time
+}
+
+// StreamMetadata is the human-readable description of a live broadcast on a
+// watched source, refreshed while it is live.
+type StreamMetadata struct {
+	Platform     string
+	Channel      string
+	Title        string
+	Category     string
+	ThumbnailURL string
+}
+
+// ViewerSample is a concurrent-viewer count observed for a watched source at
+// Ts. Channel matches StreamState.Channel.
+type ViewerSample struct {
+	Platform string
+	Channel  string
+	Viewers  int
+	Ts       time.Time
+}
+
+// Marker is a point of interest in a broadcast recorded by a chat trigger,
+// e.g. a "!clip" command.
+type Marker struct {
+	Platform string
+	// SessionID is the broadcast session active when the marker was
+	// stored, if any.
+	SessionID string
+	Rule      string
+	Label     string
+	MessageID string
+	Username  string
+	Ts        time.Time
+}
+
+// Redemption is a viewer redeeming a channel points reward.
+type Redemption struct {
+	Platform string
+	Channel  string
+	// ID is the platform's redemption id.
+	ID          string
+	RewardID    string
+	RewardTitle string
+	Cost        int
+	UserID      string
+	Username    string
+	// UserInput is the text the viewer entered, for rewards that ask for
+	// it. Such redemptions also arrive as a chat message tagged with
+	// RewardID.
+	UserInput string
+	// Status is "unfulfilled", "fulfilled" or "canceled" as reported when
+	// the redemption was made.
+	Status     string
+	SessionID  string
+	RedeemedAt time.Time
+}
+
+// TTSItem is a message queued for text-to-speech by a chat trigger.
+type TTSItem struct {
+	Platform  string
+	Channel   string
+	Rule      string
+	MessageID string
+	UserID    string
+	Username  string
+	// Text is what should be spoken.
+	Text string
+	Ts   time.Time
+}
+
+// Poll kinds.
+const (
+	PollKindPoll       = "poll"
+	PollKindPrediction = "prediction"
+)
+
+// Poll is a Twitch poll or prediction, or a YouTube chat poll, as last
+// reported by the platform. Platforms send the whole poll on every update,
+// so each report replaces the stored state.
+type Poll struct {
+	Platform string
+	Channel  string
+	ID       string
+	Kind     string
+	Title    string
+	// Status is "active" while voting is open, "locked" for predictions
+	// awaiting a result, and the platform's final status (e.g. "completed",
+	// "resolved", "canceled") once it ends.
+	Status          string
+	Options         []PollOption
+	WinningOptionID string
+	StartedAt       time.Time
+	// EndedAt is zero until the poll ends.
+	EndedAt time.Time
+}
+
+// PollOption is one choice of a poll or outcome of a prediction.
+type PollOption struct {
+	ID    string `json:"id,omitempty"`
+	Title string `json:"title"`
+	// Votes counts votes, or predicting users for predictions.
+	Votes int `json:"votes"`
+	// ChannelPoints is the total wagered on a prediction outcome.
+	ChannelPoints int `json:"channel_points,omitempty"`
+	// Percent is the share of votes (0-100) for platforms that report
+	// ratios rather than counts (YouTube).
+	Percent float64 `json:"percent,omitempty"`
+}
+
+// Raid directions, relative to the watched channel.
+const (
+	RaidIncoming = "in"
+	RaidOutgoing = "out"
+)
+
+// Raid is one channel sending its viewers to another.
+type Raid struct {
+	Platform string
+	// ID is the platform's id for the raid notice, when known.
+	ID string
+	// Channel is the watched channel the raid was observed on.
+	Channel   string
+	Direction string
+	// FromChannel and ToChannel are logins; FromName and ToName the
+	// display names.
+	FromChannel string
+	FromName    string
+	ToChannel   string
+	ToName      string
+	Viewers     int
+	// Text is the platform's system message, if it sent one.
+	Text string
+	Ts   time.Time
+}
+
+// AutoMod statuses.
+const (
+	AutoModHeld     = "held"
+	AutoModApproved = "approved"
+	AutoModDenied   = "denied"
+	AutoModExpired  = "expired"
+)
+
+// AutoModEvent is a chat message held for review by Twitch AutoMod, or the
+// decision a moderator later made about it. Held messages never reach IRC
+// unless approved, so these are the only record of what AutoMod caught.
+type AutoModEvent struct {
+	Platform string
+	Channel  string
+	// MessageID is the platform message id; an approved message arrives
+	// over IRC with the same id.
+	MessageID string
+	UserID    string
+	Username  string
+	Text      string
+	// Status is AutoModHeld until a moderator approves or denies the
+	// message, or it expires unreviewed.
+	Status string
+	// Reason is the AutoMod category (e.g. "swearing") or "blocked_term".
+	Reason string
+	// Level is the AutoMod level (0-4) of the category, if any.
+	Level int
+	// Moderator is the login of the moderator who resolved the message.
+	Moderator string
+	HeldAt    time.Time
+	// ResolvedAt is zero while the message is held.
+	ResolvedAt time.Time
+}
+
+// Presence is a chatter joining or leaving a channel's chat.
+type Presence struct {
+	Platform string
+	Channel  string
+	Username string
+	// Joined is true for a join and false for a part.
+	Joined bool
+	Ts     time.Time
+}
+
+// YouTubeCheckpoint is where a YouTube live chat poller last was, so a
+// restarted harvester can resume polling the same video without a fresh
+// bootstrap. Continuations replay the chat posted since they were issued,
+// within the window YouTube keeps.
+type YouTubeCheckpoint struct {
+	VideoID       string
+	Continuation  string
+	APIKey        string
+	ClientVersion string
+	UpdatedAt     time.Time
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// UsernameRule names one step of username normalization.
+type UsernameRule string
+
+const (
+	// UsernameLower applies Unicode lower-casing.
+	UsernameLower UsernameRule = "lower"
+	// UsernameStripAt removes a leading '@' (YouTube handles).
+	UsernameStripAt UsernameRule = "strip_at"
+	// UsernameFoldWidth maps fullwidth ASCII variants (common in CJK
+	// handles) to their ASCII equivalents.
+	UsernameFoldWidth UsernameRule = "fold_width"
+)
+
+// DefaultUsernameRules is the rule spec used when none is configured.
+const DefaultUsernameRules = "twitch:lower;youtube:lower,strip_at,fold_width;*:lower"
+
+// UsernameNormalizer derives the lookup key stored alongside each display
+// name. Rules are chosen per platform with "*" as the fallback.
+type UsernameNormalizer struct {
+	rules map[string][]UsernameRule
+}
+
+// DefaultUsernameNormalizer returns the normalizer for DefaultUsernameRules.
+func DefaultUsernameNormalizer() UsernameNormalizer {
+	n, _ := ParseUsernameRules(DefaultUsernameRules)
+	return n
+}
+
+// ParseUsernameRules parses "platform:rule,rule;platform:rule" specs.
+func ParseUsernameRules(spec string) (UsernameNormalizer, error) {
+	n := UsernameNormalizer{rules: make(map[string][]UsernameRule)}
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		platform, list, ok := strings.Cut(entry, ":")
+		if !ok {
+			return UsernameNormalizer{}, fmt.Errorf("username rules: missing ':' in %q", entry)
+		}
+		platform = strings.ToLower(strings.TrimSpace(platform))
+		var rules []UsernameRule
+		for _, raw := range strings.Split(list, ",") {
+			rule := UsernameRule(strings.ToLower(strings.TrimSpace(raw)))
+			switch rule {
+			case "":
+				continue
+			case UsernameLower, UsernameStripAt, UsernameFoldWidth:
+				rules = append(rules, rule)
+			default:
+				return UsernameNormalizer{}, fmt.Errorf("username rules: unknown rule %q", raw)
+			}
+		}
+		n.rules[platform] = rules
+	}
+	return n, nil
+}
+
+// Normalize returns the lookup key for a display name on platform.
+func (n UsernameNormalizer) Normalize(platform, name string) string {
+	rules, ok := n.rules[strings.ToLower(platform)]
+	if !ok {
+		rules, ok = n.rules["*"]
+	}
+	if !ok && n.rules == nil {
+		rules = []UsernameRule{UsernameLower}
+	}
+	return applyUsernameRules(strings.TrimSpace(name), rules)
+}
+
+// NormalizeUsernameQuery normalizes a search term with every rule so it can
+// be compared against keys produced by any platform's rule set.
+func NormalizeUsernameQuery(q string) string {
+	return applyUsernameRules(strings.TrimSpace(q), []UsernameRule{UsernameStripAt, UsernameFoldWidth, UsernameLower})
+}
+
+func applyUsernameRules(name string, rules []UsernameRule) string {
+	for _, rule := range rules {
+		switch rule {
+		case UsernameStripAt:
+			name = strings.TrimPrefix(name, "@")
+		case UsernameFoldWidth:
+			name = strings.Map(foldWidth, name)
+		case UsernameLower:
+			name = strings.Map(unicode.ToLower, name)
+		}
+	}
+	return name
+}
+
+func foldWidth(r rune) rune {
+	switch {
+	case r >= 0xFF01 && r <= 0xFF5E:
+		return r - 0xFF01 + 0x21
+	case r == 0x3000:
+		return ' '
+	}
+	return r
+}
@@ -0,0 +1,183 @@
+// Package duckdb runs read-only analytic queries over the chat archive with
+// the duckdb command-line client. The SQLite database is attached read-only
+// (or exported Parquet files are read in place), so nothing is copied.
+package duckdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+// maxErrorBytes caps the duckdb error text returned to clients.
+const maxErrorBytes = 512
+
+// Options configures an Engine.
+type Options struct {
+	// Binary is the duckdb executable; empty looks up "duckdb" in PATH.
+	Binary string
+	// Source is the SQLite archive, or a Parquet file or glob ending in
+	// .parquet, which is exposed as the messages view.
+	Source string
+}
+
+// Engine implements httpapi.QueryEngine by running one duckdb process per
+// query. Each process attaches the source, then disables access to any
+// other file and locks its configuration before running the query.
+type Engine struct {
+	binary  string
+	source  string
+	parquet bool
+}
+
+// New resolves the binary and checks that duckdb can open the source.
+func New(ctx context.Context, opts Options) (*Engine, error) {
+	binary := strings.TrimSpace(opts.Binary)
+	if binary == "" {
+		binary = "duckdb"
+	}
+	path, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("duckdb: %w", err)
+	}
+	source, err := filepath.Abs(strings.TrimSpace(opts.Source))
+	if err != nil {
+		return nil, fmt.Errorf("duckdb: source: %w", err)
+	}
+	e := &Engine{
+		binary:  path,
+		source:  source,
+		parquet: strings.HasSuffix(strings.ToLower(source), ".parquet"),
+	}
+	if !e.parquet {
+		if _, err := os.Stat(source); err != nil {
+			return nil, fmt.Errorf("duckdb: source: %w", err)
+		}
+	}
+	if _, err := e.Query(ctx, "SELECT 1 AS ok", 1); err != nil {
+		return nil, fmt.Errorf("duckdb: open %s: %w", source, err)
+	}
+	return e, nil
+}
+
+// script builds the statements run by one duckdb process.
+func (e *Engine) script(sql string, limit int) string {
+	var b strings.Builder
+	if e.parquet {
+		// The view reads the files at query time, so their directory stays
+		// readable once external access is disabled.
+		fmt.Fprintf(&b, "CREATE VIEW messages AS SELECT * FROM read_parquet(%s);\n", quote(e.source))
+		fmt.Fprintf(&b, "SET allowed_directories = [%s];\n", quote(filepath.Dir(e.source)+string(filepath.Separator)))
+	} else {
+		fmt.Fprintf(&b, "ATTACH %s AS archive (TYPE sqlite, READ_ONLY);\nUSE archive;\n", quote(e.source))
+	}
+	b.WriteString("SET enable_external_access = false;\n")
+	b.WriteString("SET lock_configuration = true;\n")
+	fmt.Fprintf(&b, "SELECT * FROM (\n%s\n) LIMIT %d;\n", sql, limit)
+	return b.String()
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// Query runs sql and returns at most limit rows. Errors reported by duckdb
+// are returned as *httpapi.QueryError.
+func (e *Engine) Query(ctx context.Context, sql string, limit int) (httpapi.QueryResult, error) {
+	cmd := exec.CommandContext(ctx, e.binary, "-json", "-bail", ":memory:")
+	cmd.Stdin = strings.NewReader(e.script(sql, limit))
+	cmd.WaitDelay = time.Second
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return httpapi.QueryResult{}, ctx.Err()
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			msg := strings.TrimSpace(stderr.String())
+			if len(msg) > maxErrorBytes {
+				msg = msg[:maxErrorBytes]
+			}
+			return httpapi.QueryResult{}, &httpapi.QueryError{Message: msg}
+		}
+		return httpapi.QueryResult{}, fmt.Errorf("duckdb: %w", err)
+	}
+	return decodeRows(stdout.Bytes())
+}
+
+// decodeRows reads duckdb's JSON output, an array of row objects, keeping
+// the column order of the first row. An empty result prints nothing.
+func decodeRows(data []byte) (httpapi.QueryResult, error) {
+	var result httpapi.QueryResult
+	if len(bytes.TrimSpace(data)) == 0 {
+		return result, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := expectDelim(dec, '['); err != nil {
+		return result, err
+	}
+	index := make(map[string]int)
+	for dec.More() {
+		if err := expectDelim(dec, '{'); err != nil {
+			return result, err
+		}
+		row := make([]any, len(result.Columns))
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return result, fmt.Errorf("duckdb: decode: %w", err)
+			}
+			name, _ := tok.(string)
+			var v any
+			if err := dec.Decode(&v); err != nil {
+				return result, fmt.Errorf("duckdb: decode: %w", err)
+			}
+			i, ok := index[name]
+			if !ok {
+				if len(result.Rows) > 0 {
+					return result, fmt.Errorf("duckdb: decode: unexpected column %q", name)
+				}
+				i = len(result.Columns)
+				index[name] = i
+				result.Columns = append(result.Columns, name)
+				row = append(row, nil)
+			}
+			row[i] = v
+		}
+		if err := expectDelim(dec, '}'); err != nil {
+			return result, err
+		}
+		result.Rows = append(result.Rows, row)
+	}
+	if err := expectDelim(dec, ']'); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return fmt.Errorf("duckdb: decode: unexpected end of output")
+	}
+	if err != nil {
+		return fmt.Errorf("duckdb: decode: %w", err)
+	}
+	if d, ok := tok.(json.Delim); !ok || d != want {
+		return fmt.Errorf("duckdb: decode: expected %q, got %v", want, tok)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package duckdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+// fakeDuckDB writes a shell script standing in for the duckdb binary: it
+// saves the script it is fed and prints output, or fails with a binder
+// error when the script mentions "missing".
+func fakeDuckDB(t *testing.T, output string) (binary, stdin string) {
+	t.Helper()
+	dir := t.TempDir()
+	binary = filepath.Join(dir, "duckdb")
+	stdin = filepath.Join(dir, "stdin.sql")
+	script := "#!/bin/sh\ncat > " + stdin + "\n" +
+		"if grep -q missing " + stdin + "; then echo 'Binder Error: column missing not found' >&2; exit 1; fi\n" +
+		"cat <<'EOF'\n" + output + "\nEOF\n"
+	if err := os.WriteFile(binary, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return binary, stdin
+}
+
+func TestEngineQuery(t *testing.T) {
+	binary, stdin := fakeDuckDB(t, `[{"platform":"Twitch","n":3,"ratio":0.5},{"platform":"YouTube","n":12345678901234,"ratio":null}]`)
+	source := filepath.Join(t.TempDir(), "it's.db")
+	if err := os.WriteFile(source, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	engine, err := New(ctx, Options{Binary: binary, Source: source})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	result, err := engine.Query(ctx, "SELECT platform, count(*) AS n FROM messages GROUP BY 1", 11)
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if !reflect.DeepEqual(result.Columns, []string{"platform", "n", "ratio"}) {
+		t.Fatalf("columns = %v", result.Columns)
+	}
+	want := [][]any{
+		{"Twitch", json.Number("3"), json.Number("0.5")},
+		{"YouTube", json.Number("12345678901234"), nil},
+	}
+	if !reflect.DeepEqual(result.Rows, want) {
+		t.Fatalf("rows = %v", result.Rows)
+	}
+
+	data, err := os.ReadFile(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script := string(data)
+	for _, want := range []string{
+		"ATTACH '" + strings.ReplaceAll(source, "'", "''") + "' AS archive (TYPE sqlite, READ_ONLY);",
+		"SET enable_external_access = false;",
+		"SET lock_configuration = true;",
+		") LIMIT 11;",
+	} {
+		if !strings.Contains(script, want) {
+			t.Fatalf("script missing %q:\n%s", want, script)
+		}
+	}
+	if strings.Index(script, "enable_external_access") > strings.Index(script, "SELECT platform") {
+		t.Fatalf("external access disabled after the query:\n%s", script)
+	}
+
+	_, err = engine.Query(ctx, "SELECT missing FROM messages", 1)
+	var qerr *httpapi.QueryError
+	if !errors.As(err, &qerr) || !strings.Contains(qerr.Message, "column missing not found") {
+		t.Fatalf("query error = %v", err)
+	}
+}
+
+func TestEngineParquetAndEmpty(t *testing.T) {
+	binary, stdin := fakeDuckDB(t, "")
+	ctx := context.Background()
+	dir := t.TempDir()
+	engine, err := New(ctx, Options{Binary: binary, Source: filepath.Join(dir, "*.parquet")})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	result, err := engine.Query(ctx, "SELECT * FROM messages WHERE false", 5)
+	if err != nil || len(result.Rows) != 0 || len(result.Columns) != 0 {
+		t.Fatalf("empty result = %+v, %v", result, err)
+	}
+	data, _ := os.ReadFile(stdin)
+	if !strings.Contains(string(data), "CREATE VIEW messages AS SELECT * FROM read_parquet('"+filepath.Join(dir, "*.parquet")+"');") ||
+		!strings.Contains(string(data), "SET allowed_directories = ['"+dir+string(filepath.Separator)+"'];") {
+		t.Fatalf("parquet script:\n%s", data)
+	}
+}
+
+func TestNewRequiresSource(t *testing.T) {
+	binary, _ := fakeDuckDB(t, "[]")
+	if _, err := New(context.Background(), Options{Binary: binary, Source: filepath.Join(t.TempDir(), "absent.db")}); err == nil {
+		t.Fatalf("expected error for a missing archive")
+	}
+	if _, err := New(context.Background(), Options{Binary: filepath.Join(t.TempDir(), "nope")}); err == nil {
+		t.Fatalf("expected error for a missing binary")
+	}
+}
@@ -0,0 +1,121 @@
+// Package errlog keeps a bounded in-memory record of recent receiver and
+// sink errors, served at /admin/errors.
+package errlog
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+const defaultSize = 100
+
+// Entry is one distinct error. Repeats of the same source, kind and message
+// are folded into a single entry.
+type Entry struct {
+	Source    string         `json:"source"`
+	Kind      core.ErrorKind `json:"kind"`
+	Message   string         `json:"message"`
+	Count     int64          `json:"count"`
+	FirstSeen time.Time      `json:"first_seen"`
+	LastSeen  time.Time      `json:"last_seen"`
+}
+
+// Snapshot is the state served by /admin/errors.
+type Snapshot struct {
+	// Errors lists retained entries, most recently seen first.
+	Errors []Entry `json:"errors"`
+	// Counts totals every recorded error by kind since startup, including
+	// entries that have since been evicted.
+	Counts map[core.ErrorKind]int64 `json:"counts"`
+}
+
+type key struct {
+	source  string
+	kind    core.ErrorKind
+	message string
+}
+
+// Log retains up to a fixed number of distinct errors, evicting the one seen
+// least recently. It is safe for concurrent use.
+type Log struct {
+	mu      sync.Mutex
+	size    int
+	entries map[key]*Entry
+	counts  map[core.ErrorKind]int64
+	now     func() time.Time
+}
+
+// New returns a Log retaining up to size distinct errors (100 when size is
+// not positive).
+func New(size int) *Log {
+	if size <= 0 {
+		size = defaultSize
+	}
+	return &Log{
+		size:    size,
+		entries: make(map[key]*Entry),
+		counts:  make(map[core.ErrorKind]int64),
+		now:     time.Now,
+	}
+}
+
+// Record notes err against source (e.g. "twitch-irc"). Nil errors and a nil
+// Log are ignored.
+func (l *Log) Record(source string, err error) {
+	if l == nil || err == nil {
+		return
+	}
+	k := key{source: source, kind: core.ErrorKindOf(err), message: err.Error()}
+	now := l.now().UTC()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[k.kind]++
+	if e, ok := l.entries[k]; ok {
+		e.Count++
+		e.LastSeen = now
+		return
+	}
+	if len(l.entries) >= l.size {
+		var (
+			oldest key
+			seen   time.Time
+		)
+		for ek, e := range l.entries {
+			if seen.IsZero() || e.LastSeen.Before(seen) {
+				oldest, seen = ek, e.LastSeen
+			}
+		}
+		delete(l.entries, oldest)
+	}
+	l.entries[k] = &Entry{Source: source, Kind: k.kind, Message: k.message, Count: 1, FirstSeen: now, LastSeen: now}
+}
+
+// Reporter returns a callback that records errors against source, suitable
+// for receiver OnError hooks.
+func (l *Log) Reporter(source string) func(error) {
+	return func(err error) { l.Record(source, err) }
+}
+
+// Snapshot returns a copy of the retained errors and per-kind totals.
+func (l *Log) Snapshot() Snapshot {
+	snap := Snapshot{Errors: []Entry{}, Counts: map[core.ErrorKind]int64{}}
+	if l == nil {
+		return snap
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.entries {
+		snap.Errors = append(snap.Errors, *e)
+	}
+	for kind, n := range l.counts {
+		snap.Counts[kind] = n
+	}
+	sort.Slice(snap.Errors, func(i, j int) bool {
+		return snap.Errors[i].LastSeen.After(snap.Errors[j].LastSeen)
+	})
+	return snap
+}
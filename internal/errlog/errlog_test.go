@@ -0,0 +1,78 @@
+package errlog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestLogFoldsAndEvicts(t *testing.T) {
+	l := New(2)
+	now := time.Unix(1700000000, 0)
+	l.now = func() time.Time { return now }
+	tick := func() { now = now.Add(time.Second) }
+
+	auth := core.NewError(core.ErrorAuth, errors.New("login failed"))
+	l.Record("twitch-irc", auth)
+	tick()
+	l.Record("twitch-irc", fmt.Errorf("reconnect: %w", core.NewError(core.ErrorNetwork, errors.New("reset"))))
+	tick()
+	l.Record("twitch-irc", auth)
+
+	snap := l.Snapshot()
+	if len(snap.Errors) != 2 {
+		t.Fatalf("entries = %+v", snap.Errors)
+	}
+	first := snap.Errors[0]
+	if first.Kind != core.ErrorAuth || first.Count != 2 || !first.LastSeen.After(first.FirstSeen) {
+		t.Fatalf("folded entry = %+v", first)
+	}
+	if snap.Errors[1].Kind != core.ErrorNetwork || snap.Errors[1].Message != "reconnect: reset" {
+		t.Fatalf("second entry = %+v", snap.Errors[1])
+	}
+
+	// A third distinct error evicts the least recently seen one.
+	tick()
+	l.Record("sqlite", core.NewError(core.ErrorStorage, errors.New("disk full")))
+	snap = l.Snapshot()
+	if len(snap.Errors) != 2 || snap.Errors[0].Source != "sqlite" || snap.Errors[1].Kind != core.ErrorAuth {
+		t.Fatalf("after eviction = %+v", snap.Errors)
+	}
+	want := map[core.ErrorKind]int64{core.ErrorAuth: 2, core.ErrorNetwork: 1, core.ErrorStorage: 1}
+	for kind, n := range want {
+		if snap.Counts[kind] != n {
+			t.Fatalf("counts = %v, want %v", snap.Counts, want)
+		}
+	}
+}
+
+func TestErrorKindOf(t *testing.T) {
+	var syntax map[string]any
+	jsonErr := json.Unmarshal([]byte("{"), &syntax)
+	cases := []struct {
+		err  error
+		want core.ErrorKind
+	}{
+		{core.NewError(core.ErrorParse, errors.New("bad")), core.ErrorParse},
+		{fmt.Errorf("wrapped: %w", jsonErr), core.ErrorParse},
+		{errors.New("plain"), core.ErrorOther},
+	}
+	for _, tc := range cases {
+		if got := core.ErrorKindOf(tc.err); got != tc.want {
+			t.Fatalf("ErrorKindOf(%v) = %s, want %s", tc.err, got, tc.want)
+		}
+	}
+	if core.NewError(core.ErrorAuth, nil) != nil {
+		t.Fatalf("NewError(nil) should be nil")
+	}
+
+	var nilLog *Log
+	nilLog.Record("x", errors.New("ignored"))
+	if snap := nilLog.Snapshot(); len(snap.Errors) != 0 {
+		t.Fatalf("nil log snapshot = %+v", snap)
+	}
+}
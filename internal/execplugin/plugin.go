@@ -0,0 +1,250 @@
+// Package execplugin pipes chat messages through an external process. The
+// process receives one JSON-encoded message per stdin line and must answer
+// each with exactly one stdout line: the (possibly modified) message, or
+// null to drop it. Anything it writes to stderr is passed through to the
+// harvester's stderr.
+package execplugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+const (
+	defaultTimeout = 2 * time.Second
+	minBackoff     = time.Second
+	maxBackoff     = 30 * time.Second
+	maxLineBytes   = 1 << 20
+)
+
+// Call results reported to Reporter.
+const (
+	ResultOK      = "ok"
+	ResultDropped = "dropped"
+	ResultError   = "error"
+	ResultTimeout = "timeout"
+)
+
+// Reporter receives per-message latency and restarts; *httpapi.Server
+// satisfies it.
+type Reporter interface {
+	ReportPluginCall(result string, latency time.Duration)
+	ReportPluginRestart()
+}
+
+// Options configures a Plugin.
+type Options struct {
+	Command string
+	Args    []string
+	// Timeout bounds how long one message may take; the process is killed
+	// and restarted when it is exceeded.
+	Timeout  time.Duration
+	Reporter Reporter
+}
+
+// Plugin is a sink.Transformer backed by a subprocess. Messages are sent one
+// at a time. When the process exits, misbehaves or times out it is killed
+// and restarted on a later message with exponential backoff; messages
+// arriving in the meantime pass through unchanged.
+type Plugin struct {
+	opts Options
+
+	mu        sync.Mutex
+	proc      *process
+	backoff   time.Duration
+	nextStart time.Time
+	closed    bool
+}
+
+type process struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	lines chan []byte
+	done  chan struct{}
+}
+
+// New starts the plugin process.
+func New(opts Options) (*Plugin, error) {
+	if opts.Command == "" {
+		return nil, errors.New("execplugin: command is required")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	p := &Plugin{opts: opts, backoff: minBackoff}
+	proc, err := p.start()
+	if err != nil {
+		return nil, err
+	}
+	p.proc = proc
+	return p, nil
+}
+
+func (p *Plugin) start() (*process, error) {
+	cmd := exec.Command(p.opts.Command, p.opts.Args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("execplugin: stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("execplugin: stdout: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("execplugin: start %s: %w", p.opts.Command, err)
+	}
+	proc := &process{cmd: cmd, stdin: stdin, lines: make(chan []byte, 1), done: make(chan struct{})}
+	go func() {
+		defer close(proc.lines)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+		for scanner.Scan() {
+			line := append([]byte(nil), scanner.Bytes()...)
+			select {
+			case proc.lines <- line:
+			case <-proc.done:
+				return
+			}
+		}
+	}()
+	return proc, nil
+}
+
+func (proc *process) kill() {
+	close(proc.done)
+	_ = proc.stdin.Close()
+	if proc.cmd.Process != nil {
+		_ = proc.cmd.Process.Kill()
+	}
+	go func() { _ = proc.cmd.Wait() }()
+}
+
+// Transform sends msg to the plugin and returns its answer.
+func (p *Plugin) Transform(msg core.ChatMessage) (core.ChatMessage, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return msg, true, errors.New("execplugin: closed")
+	}
+	if p.proc == nil {
+		if time.Now().Before(p.nextStart) {
+			return msg, true, errors.New("execplugin: waiting to restart")
+		}
+		proc, err := p.start()
+		if err != nil {
+			p.scheduleRestart()
+			return msg, true, err
+		}
+		p.proc = proc
+		if p.opts.Reporter != nil {
+			p.opts.Reporter.ReportPluginRestart()
+		}
+		log.Printf("execplugin: restarted %s", p.opts.Command)
+	}
+
+	started := time.Now()
+	out, keep, result, err := p.call(msg)
+	if p.opts.Reporter != nil {
+		p.opts.Reporter.ReportPluginCall(result, time.Since(started))
+	}
+	if err != nil {
+		return msg, true, err
+	}
+	p.backoff = minBackoff
+	return out, keep, nil
+}
+
+func (p *Plugin) call(msg core.ChatMessage) (core.ChatMessage, bool, string, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return msg, true, ResultError, fmt.Errorf("execplugin: encode: %w", err)
+	}
+	if _, err := p.proc.stdin.Write(append(data, '\n')); err != nil {
+		p.fail()
+		return msg, true, ResultError, fmt.Errorf("execplugin: write: %w", err)
+	}
+
+	timer := time.NewTimer(p.opts.Timeout)
+	defer timer.Stop()
+	var line []byte
+	select {
+	case l, ok := <-p.proc.lines:
+		if !ok {
+			p.fail()
+			return msg, true, ResultError, errors.New("execplugin: process exited")
+		}
+		line = bytes.TrimSpace(l)
+	case <-timer.C:
+		p.fail()
+		return msg, true, ResultTimeout, fmt.Errorf("execplugin: no answer within %s", p.opts.Timeout)
+	}
+
+	if bytes.Equal(line, []byte("null")) {
+		return msg, false, ResultDropped, nil
+	}
+	var out core.ChatMessage
+	if err := json.Unmarshal(line, &out); err != nil {
+		return msg, true, ResultError, fmt.Errorf("execplugin: decode answer: %w", err)
+	}
+	// Fields kept out of the JSON encoding are not the plugin's to change.
+	out.FirstMessage = msg.FirstMessage
+	return out, true, ResultOK, nil
+}
+
+// fail kills the current process and schedules a restart. It must be called
+// with p.mu held.
+func (p *Plugin) fail() {
+	p.proc.kill()
+	p.proc = nil
+	p.scheduleRestart()
+}
+
+func (p *Plugin) scheduleRestart() {
+	p.nextStart = time.Now().Add(p.backoff)
+	log.Printf("execplugin: %s unavailable; restarting in %s", p.opts.Command, p.backoff)
+	p.backoff *= 2
+	if p.backoff > maxBackoff {
+		p.backoff = maxBackoff
+	}
+}
+
+// Close stops the process, giving it a moment to exit after stdin closes.
+func (p *Plugin) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.proc == nil {
+		return nil
+	}
+	proc := p.proc
+	p.proc = nil
+	_ = proc.stdin.Close()
+	exited := make(chan struct{})
+	go func() {
+		_ = proc.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(p.opts.Timeout):
+		_ = proc.cmd.Process.Kill()
+		<-exited
+	}
+	close(proc.done)
+	return nil
+}
@@ -0,0 +1,169 @@
+package execplugin
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// TestMain lets the test binary double as the plugin process.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv("GNASTY_EXECPLUGIN_HELPER"); mode != "" {
+		runHelper(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func runHelper(mode string) {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var msg core.ChatMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			os.Exit(2)
+		}
+		switch {
+		case mode == "crash" && msg.Text == "crash":
+			os.Exit(1)
+		case mode == "slow" && msg.Text == "slow":
+			time.Sleep(time.Minute)
+		case msg.Text == "drop":
+			fmt.Println("null")
+			continue
+		case msg.Text == "garbage":
+			fmt.Println("not json")
+			continue
+		}
+		msg.Text = strings.ToUpper(msg.Text)
+		data, _ := json.Marshal(msg)
+		fmt.Println(string(data))
+	}
+}
+
+type recorder struct {
+	mu       sync.Mutex
+	results  []string
+	restarts int
+}
+
+func (r *recorder) ReportPluginCall(result string, _ time.Duration) {
+	r.mu.Lock()
+	r.results = append(r.results, result)
+	r.mu.Unlock()
+}
+
+func (r *recorder) ReportPluginRestart() {
+	r.mu.Lock()
+	r.restarts++
+	r.mu.Unlock()
+}
+
+func newHelper(t *testing.T, mode string, timeout time.Duration, rep Reporter) *Plugin {
+	t.Helper()
+	t.Setenv("GNASTY_EXECPLUGIN_HELPER", mode)
+	p, err := New(Options{Command: os.Args[0], Timeout: timeout, Reporter: rep})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(func() { _ = p.Close() })
+	return p
+}
+
+func TestPluginTransform(t *testing.T) {
+	rep := &recorder{}
+	p := newHelper(t, "echo", time.Second, rep)
+
+	msg := core.ChatMessage{ID: "1", Platform: "Twitch", Username: "alice", Text: "hello", Ts: time.Unix(1700000000, 0).UTC()}
+	out, keep, err := p.Transform(msg)
+	if err != nil || !keep {
+		t.Fatalf("Transform = keep %v, err %v", keep, err)
+	}
+	if out.Text != "HELLO" || out.ID != "1" || !out.Ts.Equal(msg.Ts) {
+		t.Fatalf("unexpected answer %+v", out)
+	}
+
+	if _, keep, err := p.Transform(core.ChatMessage{ID: "2", Text: "drop"}); err != nil || keep {
+		t.Fatalf("drop = keep %v, err %v", keep, err)
+	}
+
+	// A malformed answer is an error but keeps the process running.
+	in := core.ChatMessage{ID: "3", Text: "garbage"}
+	out, keep, err = p.Transform(in)
+	if err == nil || !keep || out.Text != "garbage" {
+		t.Fatalf("garbage = %+v keep %v err %v", out, keep, err)
+	}
+	if out, _, err := p.Transform(core.ChatMessage{ID: "4", Text: "again"}); err != nil || out.Text != "AGAIN" {
+		t.Fatalf("after garbage = %+v err %v", out, err)
+	}
+
+	want := []string{ResultOK, ResultDropped, ResultError, ResultOK}
+	if strings.Join(rep.results, ",") != strings.Join(want, ",") {
+		t.Fatalf("results = %v, want %v", rep.results, want)
+	}
+}
+
+func TestPluginKeepsFieldsOutsideJSON(t *testing.T) {
+	p := newHelper(t, "echo", time.Second, nil)
+
+	out, keep, err := p.Transform(core.ChatMessage{ID: "1", Text: "first", FirstMessage: true})
+	if err != nil || !keep || !out.FirstMessage {
+		t.Fatalf("expected FirstMessage to survive the plugin, got %+v keep %v err %v", out, keep, err)
+	}
+}
+
+func TestPluginRestartsAfterCrash(t *testing.T) {
+	rep := &recorder{}
+	p := newHelper(t, "crash", time.Second, rep)
+	p.backoff = 10 * time.Millisecond
+
+	in := core.ChatMessage{ID: "1", Text: "crash"}
+	if out, keep, err := p.Transform(in); err == nil || !keep || out.Text != "crash" {
+		t.Fatalf("crash = %+v keep %v err %v", out, keep, err)
+	}
+	// Within the backoff window messages pass through untouched.
+	if _, _, err := p.Transform(core.ChatMessage{Text: "early"}); err == nil {
+		t.Fatalf("expected error while waiting to restart")
+	}
+	time.Sleep(20 * time.Millisecond)
+	out, _, err := p.Transform(core.ChatMessage{Text: "back"})
+	if err != nil || out.Text != "BACK" {
+		t.Fatalf("after restart = %+v err %v", out, err)
+	}
+	if rep.restarts != 1 {
+		t.Fatalf("restarts = %d, want 1", rep.restarts)
+	}
+}
+
+func TestPluginTimeout(t *testing.T) {
+	rep := &recorder{}
+	p := newHelper(t, "slow", 100*time.Millisecond, rep)
+	p.backoff = time.Millisecond
+
+	start := time.Now()
+	if _, keep, err := p.Transform(core.ChatMessage{Text: "slow"}); err == nil || !keep {
+		t.Fatalf("slow = keep %v err %v", keep, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("timeout took %s", elapsed)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if out, _, err := p.Transform(core.ChatMessage{Text: "fast"}); err != nil || out.Text != "FAST" {
+		t.Fatalf("after timeout = %+v err %v", out, err)
+	}
+	if rep.results[0] != ResultTimeout {
+		t.Fatalf("results = %v", rep.results)
+	}
+}
+
+func TestNewFailsForMissingCommand(t *testing.T) {
+	if _, err := New(Options{Command: "/nonexistent/gnasty-plugin"}); err == nil {
+		t.Fatalf("expected error for missing command")
+	}
+}
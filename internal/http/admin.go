@@ -1,20 +1,96 @@
 package httpadmin
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"log"
 	"net/http"
+	"strings"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/errlog"
+	"github.com/you/gnasty-chat/internal/twitchirc"
 )
 
 type Reloader interface {
 	ReloadTwitch() (login string, err error)
 }
 
+// SendQueue reports outbound Twitch message queues.
+type SendQueue interface {
+	Status() []twitchirc.SendQueueStatus
+}
+
+// UserEraser removes a chatter's stored data, returning affected message and
+// user row counts.
+type UserEraser interface {
+	EraseUser(ctx context.Context, platform, name string, redact bool) (messages, users int64, err error)
+}
+
+// ChainVerifier re-validates the archive's message hash chains.
+type ChainVerifier interface {
+	VerifyHashChain(ctx context.Context) (core.ChainReport, error)
+}
+
+// ChannelPool reports and changes the Twitch channels spread across IRC
+// connections.
+type ChannelPool interface {
+	Shards() []twitchirc.ShardStatus
+	Add(channels ...string)
+	Remove(channels ...string)
+}
+
+// ErrorLog reports recent receiver and sink errors.
+type ErrorLog interface {
+	Snapshot() errlog.Snapshot
+}
+
 type Server struct {
-	rel Reloader
+	rel    Reloader
+	queue  SendQueue
+	pool   ChannelPool
+	errs   ErrorLog
+	eraser UserEraser
+	redact bool
+	chain  ChainVerifier
+	token  string
 }
 
 func New(rel Reloader) *Server { return &Server{rel: rel} }
 
+// SetSendQueue exposes q under /admin/twitch/send-queue.
+func (s *Server) SetSendQueue(q SendQueue) { s.queue = q }
+
+// SetChannelPool exposes p under /admin/twitch/channels.
+func (s *Server) SetChannelPool(p ChannelPool) { s.pool = p }
+
+// SetErrorLog exposes l under /admin/errors.
+func (s *Server) SetErrorLog(l ErrorLog) { s.errs = l }
+
+// SetUserEraser enables DELETE /admin/users/{platform}/{username}. With
+// redact, messages are anonymized instead of deleted.
+func (s *Server) SetUserEraser(e UserEraser, redact bool) {
+	s.eraser = e
+	s.redact = redact
+}
+
+// SetChainVerifier enables GET /admin/verify.
+func (s *Server) SetChainVerifier(v ChainVerifier) { s.chain = v }
+
+// SetToken sets the bearer token required by destructive endpoints. They
+// are refused while no token is configured.
+func (s *Server) SetToken(token string) { s.token = strings.TrimSpace(token) }
+
+// authorized reports whether r carries the configured bearer token.
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return false
+	}
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(strings.TrimSpace(got)), []byte(s.token)) == 1
+}
+
 func (s *Server) Register(mux *http.ServeMux) {
 	mux.HandleFunc("/admin/healthz", func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusOK)
@@ -41,4 +117,156 @@ func (s *Server) Register(mux *http.ServeMux) {
 			Login:    login,
 		})
 	})
+	mux.HandleFunc("/admin/twitch/send-queue", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if s.queue == nil {
+			http.Error(w, "send queue not configured", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(struct {
+			Channels []twitchirc.SendQueueStatus `json:"channels"`
+		}{
+			Channels: s.queue.Status(),
+		})
+	})
+	mux.HandleFunc("/admin/twitch/channels", s.handleChannels)
+	mux.HandleFunc("/admin/errors", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if s.errs == nil {
+			http.Error(w, "error log not configured", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(s.errs.Snapshot())
+	})
+	mux.HandleFunc("/admin/users/", s.handleEraseUser)
+	mux.HandleFunc("/admin/verify", s.handleVerify)
+	ui := uiHandler()
+	mux.HandleFunc("/admin/ui", ui)
+	mux.HandleFunc("/admin/ui/", ui)
+}
+
+// handleChannels lists the channel shards on GET and joins or parts
+// channels on POST with a {"join": [...], "part": [...]} body.
+func (s *Server) handleChannels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.pool == nil {
+		http.Error(w, "channel pool not configured", http.StatusNotFound)
+		return
+	}
+	if r.Method == http.MethodPost {
+		if !s.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var body struct {
+			Join []string `json:"join"`
+			Part []string `json:"part"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.pool.Remove(body.Part...)
+		s.pool.Add(body.Join...)
+		log.Printf("admin: twitch channels changed join=%v part=%v remote=%s", body.Join, body.Part, r.RemoteAddr)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(struct {
+		Shards []twitchirc.ShardStatus `json:"shards"`
+	}{
+		Shards: s.pool.Shards(),
+	})
+}
+
+func (s *Server) handleEraseUser(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.eraser == nil {
+		http.Error(w, "user erasure not configured", http.StatusNotFound)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	rawPlatform, name, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/admin/users/"), "/")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		http.Error(w, "expected /admin/users/{platform}/{username}", http.StatusNotFound)
+		return
+	}
+	var platform string
+	switch strings.ToLower(rawPlatform) {
+	case "twitch":
+		platform = "Twitch"
+	case "youtube":
+		platform = "YouTube"
+	default:
+		http.Error(w, "unknown platform", http.StatusBadRequest)
+		return
+	}
+
+	mode := "delete"
+	if s.redact {
+		mode = "redact"
+	}
+	messages, users, err := s.eraser.EraseUser(r.Context(), platform, name, s.redact)
+	if err != nil {
+		log.Printf("admin: erase %s/%s failed: %v", platform, name, err)
+		http.Error(w, "erase failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("admin: erased user platform=%s user=%s mode=%s messages=%d users=%d remote=%s",
+		platform, name, mode, messages, users, r.RemoteAddr)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(struct {
+		Platform string `json:"platform"`
+		Username string `json:"username"`
+		Mode     string `json:"mode"`
+		Messages int64  `json:"messages"`
+		Users    int64  `json:"users"`
+	}{
+		Platform: platform,
+		Username: name,
+		Mode:     mode,
+		Messages: messages,
+		Users:    users,
+	})
+}
+
+// handleVerify recomputes the message hash chains and reports each chain's
+// length and head hash along with any link that no longer verifies.
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.chain == nil {
+		http.Error(w, "hash chain not configured", http.StatusNotFound)
+		return
+	}
+	report, err := s.chain.VerifyHashChain(r.Context())
+	if err != nil {
+		log.Printf("admin: verify hash chain failed: %v", err)
+		http.Error(w, "verify failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !report.OK {
+		log.Printf("admin: hash chain verification found %d failures remote=%s", len(report.Failures), r.RemoteAddr)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(report)
 }
@@ -1,11 +1,17 @@
 package httpadmin
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/errlog"
+	"github.com/you/gnasty-chat/internal/twitchirc"
 )
 
 type fakeReloader struct {
@@ -69,3 +75,228 @@ func TestServerReloadError(t *testing.T) {
 		t.Fatalf("unexpected body: %q", body)
 	}
 }
+
+type fakeSendQueue []twitchirc.SendQueueStatus
+
+func (f fakeSendQueue) Status() []twitchirc.SendQueueStatus { return f }
+
+func TestServerSendQueueStatus(t *testing.T) {
+	srv := New(fakeReloader{})
+	mux := http.NewServeMux()
+	srv.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/twitch/send-queue", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a queue, got %d", rec.Code)
+	}
+
+	srv.SetSendQueue(fakeSendQueue{{Channel: "elora", Queued: 2, SlowSecs: 30}})
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/twitch/send-queue", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var payload struct {
+		Channels []twitchirc.SendQueueStatus `json:"channels"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(payload.Channels) != 1 || payload.Channels[0].Queued != 2 || payload.Channels[0].SlowSecs != 30 {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestServerTwitchChannels(t *testing.T) {
+	srv := New(fakeReloader{})
+	srv.SetChannelPool(twitchirc.NewPool([]string{"elora", "gnasty"}, twitchirc.PoolOptions{PerConnection: 2}))
+	srv.SetToken("s3cret")
+	mux := http.NewServeMux()
+	srv.Register(mux)
+
+	body := `{"join":["third"],"part":["gnasty"]}`
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/twitch/channels", strings.NewReader(body)))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/twitch/channels", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var payload struct {
+		Shards []twitchirc.ShardStatus `json:"shards"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(payload.Shards) != 1 || strings.Join(payload.Shards[0].Channels, ",") != "elora,third" {
+		t.Fatalf("unexpected shards: %+v", payload.Shards)
+	}
+}
+
+type fakeEraser struct {
+	platform, name string
+	redact         bool
+}
+
+func (f *fakeEraser) EraseUser(_ context.Context, platform, name string, redact bool) (int64, int64, error) {
+	f.platform, f.name, f.redact = platform, name, redact
+	return 12, 1, nil
+}
+
+func TestServerEraseUser(t *testing.T) {
+	eraser := &fakeEraser{}
+	srv := New(fakeReloader{})
+	srv.SetUserEraser(eraser, true)
+
+	mux := http.NewServeMux()
+	srv.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/admin/users/twitch/elora", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a configured token, got %d", rec.Code)
+	}
+
+	srv.SetToken("s3cret")
+	req := httptest.NewRequest(http.MethodDelete, "/admin/users/twitch/elora", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a wrong token, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/admin/users/twitch/elora", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var payload struct {
+		Mode     string `json:"mode"`
+		Messages int64  `json:"messages"`
+		Users    int64  `json:"users"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if payload.Mode != "redact" || payload.Messages != 12 || payload.Users != 1 {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+	if eraser.platform != "Twitch" || eraser.name != "elora" || !eraser.redact {
+		t.Fatalf("unexpected erase call %+v", eraser)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/users/twitch/elora", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for GET, got %d", rec.Code)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	srv := New(fakeReloader{})
+	mux := http.NewServeMux()
+	srv.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/errors", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without an error log, got %d", rec.Code)
+	}
+
+	errs := errlog.New(10)
+	errs.Record("twitch-irc", core.NewError(core.ErrorAuth, errors.New("login failed")))
+	errs.Record("twitch-irc", core.NewError(core.ErrorAuth, errors.New("login failed")))
+	srv.SetErrorLog(errs)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/errors", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	var payload errlog.Snapshot
+	if err := json.NewDecoder(rec.Body).Decode(&payload); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(payload.Errors) != 1 || payload.Errors[0].Count != 2 || payload.Errors[0].Kind != core.ErrorAuth {
+		t.Fatalf("unexpected errors %+v", payload.Errors)
+	}
+	if payload.Counts[core.ErrorAuth] != 2 {
+		t.Fatalf("unexpected counts %+v", payload.Counts)
+	}
+}
+
+func TestServerDashboard(t *testing.T) {
+	srv := New(fakeReloader{})
+	mux := http.NewServeMux()
+	srv.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/ui", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/admin/ui/" {
+		t.Fatalf("expected a redirect to /admin/ui/, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/ui/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "gnasty-chat admin") {
+		t.Fatalf("expected the dashboard page, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/ui/app.js", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "/admin/twitch/reload") {
+		t.Fatalf("expected the dashboard script, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/ui/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 for POST, got %d", rec.Code)
+	}
+}
+
+type fakeVerifier struct{ report core.ChainReport }
+
+func (f fakeVerifier) VerifyHashChain(context.Context) (core.ChainReport, error) {
+	return f.report, nil
+}
+
+func TestServerVerify(t *testing.T) {
+	srv := New(fakeReloader{})
+	mux := http.NewServeMux()
+	srv.Register(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/verify", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a verifier, got %d", rec.Code)
+	}
+
+	srv.SetChainVerifier(fakeVerifier{report: core.ChainReport{
+		Messages: 3,
+		Chains:   []core.ChainHead{{Chain: "twitch:1", Length: 3, Head: "abc"}},
+		Failures: []core.ChainBreak{{Chain: "twitch:1", Seq: 2, MessageID: "m2", Reason: "content changed"}},
+	}})
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/verify", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var report core.ChainReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.OK || len(report.Failures) != 1 || report.Failures[0].Reason != "content changed" || report.Chains[0].Head != "abc" {
+		t.Fatalf("unexpected report %+v", report)
+	}
+}
@@ -0,0 +1,34 @@
+package httpadmin
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiFiles holds the static admin dashboard served under /admin/ui/. It has
+// no data of its own; the page calls the admin and API endpoints with the
+// credentials the operator enters.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+func uiHandler() http.HandlerFunc {
+	sub, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err)
+	}
+	files := http.StripPrefix("/admin/ui/", http.FileServer(http.FS(sub)))
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.URL.Path == "/admin/ui" {
+			http.Redirect(w, r, "/admin/ui/", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-cache")
+		files.ServeHTTP(w, r)
+	}
+}
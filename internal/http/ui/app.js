@@ -0,0 +1,179 @@
+// Admin dashboard for gnasty-chat. Polls the public /status and /info
+// endpoints and the /admin endpoints every few seconds. The API key and
+// admin token are kept in sessionStorage and sent as bearer tokens.
+(function () {
+  "use strict";
+
+  const refreshMS = 5000;
+  const auth = document.getElementById("auth");
+  const result = document.getElementById("action-result");
+  auth.apiKey.value = sessionStorage.getItem("gnasty.apiKey") || "";
+  auth.token.value = sessionStorage.getItem("gnasty.adminToken") || "";
+
+  auth.addEventListener("submit", function (ev) {
+    ev.preventDefault();
+    sessionStorage.setItem("gnasty.apiKey", auth.apiKey.value.trim());
+    sessionStorage.setItem("gnasty.adminToken", auth.token.value.trim());
+    refresh();
+  });
+
+  // request calls path on the server, authenticating API routes with the
+  // API key and /admin routes with the admin token.
+  function request(path, init) {
+    init = init || {};
+    const secret = path.indexOf("/admin/") === 0 ? auth.token.value.trim() : auth.apiKey.value.trim();
+    init.headers = Object.assign({}, init.headers);
+    if (secret) init.headers.Authorization = "Bearer " + secret;
+    return fetch(path, init).then(function (res) {
+      if (!res.ok) {
+        return res.text().then(function (body) { throw new Error(res.status + " " + body.trim()); });
+      }
+      return res.json();
+    });
+  }
+
+  function cell(row, text, cls) {
+    const td = document.createElement("td");
+    td.textContent = text == null ? "" : String(text);
+    if (cls) td.className = cls;
+    row.appendChild(td);
+  }
+
+  function fill(id, rows, render, emptyText) {
+    const body = document.querySelector("#" + id + " tbody");
+    body.textContent = "";
+    if (!rows || rows.length === 0) {
+      const tr = document.createElement("tr");
+      cell(tr, emptyText, "muted");
+      tr.firstChild.colSpan = document.querySelectorAll("#" + id + " th").length;
+      body.appendChild(tr);
+      return;
+    }
+    for (const item of rows) {
+      const tr = document.createElement("tr");
+      render(tr, item);
+      body.appendChild(tr);
+    }
+  }
+
+  function when(ts) {
+    return ts ? new Date(ts).toLocaleString() : "";
+  }
+
+  function bytes(n) {
+    const units = ["B", "KiB", "MiB", "GiB", "TiB"];
+    let i = 0;
+    while (n >= 1024 && i < units.length - 1) { n /= 1024; i++; }
+    return n.toFixed(i ? 1 : 0) + " " + units[i];
+  }
+
+  function failed(id) {
+    return function (err) {
+      fill(id, [], null, err.message);
+    };
+  }
+
+  function loadStatus() {
+    return request("/status").then(function (st) {
+      fill("receivers", st.receivers, function (tr, r) {
+        cell(tr, r.name);
+        cell(tr, r.healthy ? "running" : "stopped", r.healthy ? "ok" : "bad");
+        cell(tr, when(r.since));
+        cell(tr, r.error);
+      }, "no receivers reported");
+    }).catch(failed("receivers"));
+  }
+
+  function loadInfo() {
+    const dl = document.getElementById("ingest");
+    return request("/info").then(function (info) {
+      document.getElementById("version").textContent = info.version + " (" + info.rev + ")";
+      dl.textContent = "";
+      const add = function (term, value) {
+        const dt = document.createElement("dt");
+        dt.textContent = term;
+        const dd = document.createElement("dd");
+        dd.textContent = value;
+        dl.append(dt, dd);
+      };
+      add("Uptime", Math.round(info.uptime_secs) + "s");
+      if (!info.stats) return;
+      add("Messages stored", info.stats.total_messages);
+      add("Database size", bytes(info.stats.db_size_bytes));
+      for (const platform in info.stats.last_minute || {}) {
+        add(platform + " (last minute)", info.stats.last_minute[platform] + " msgs");
+      }
+    }).catch(function (err) {
+      dl.textContent = err.message;
+    });
+  }
+
+  function loadTwitch() {
+    const shards = request("/admin/twitch/channels").then(function (resp) {
+      fill("shards", resp.shards, function (tr, sh) {
+        cell(tr, "#" + sh.index);
+        cell(tr, sh.connected ? "yes" : "no", sh.connected ? "ok" : "bad");
+        cell(tr, (sh.channels || []).join(", "));
+      }, "no channels");
+    }).catch(failed("shards"));
+    const queues = request("/admin/twitch/send-queue").then(function (resp) {
+      fill("queues", resp.channels, function (tr, q) {
+        cell(tr, q.channel);
+        cell(tr, q.queued);
+        cell(tr, q.sent);
+        cell(tr, q.failed);
+      }, "no send queues");
+    }).catch(failed("queues"));
+    return Promise.all([shards, queues]);
+  }
+
+  function loadErrors() {
+    return request("/admin/errors").then(function (snap) {
+      const counts = [];
+      for (const kind in snap.counts || {}) counts.push(kind + ": " + snap.counts[kind]);
+      document.getElementById("error-counts").textContent = counts.length ? counts.join(" · ") : "no errors since startup";
+      fill("errors", snap.errors, function (tr, e) {
+        cell(tr, when(e.last_seen));
+        cell(tr, e.source);
+        cell(tr, e.kind);
+        cell(tr, e.count);
+        cell(tr, e.message);
+      }, "none");
+    }).catch(failed("errors"));
+  }
+
+  function refresh() {
+    return Promise.all([loadStatus(), loadInfo(), loadTwitch(), loadErrors()]);
+  }
+
+  function report(promise, done) {
+    result.textContent = "…";
+    promise.then(function () {
+      result.textContent = done;
+      loadTwitch();
+    }).catch(function (err) {
+      result.textContent = err.message;
+    });
+  }
+
+  document.getElementById("reload").addEventListener("click", function () {
+    report(request("/admin/twitch/reload", { method: "POST" }), "token reloaded");
+  });
+
+  document.getElementById("channel").addEventListener("submit", function (ev) {
+    ev.preventDefault();
+    const name = ev.target.name.value.trim();
+    const op = ev.submitter ? ev.submitter.value : "join";
+    if (!name) return;
+    const body = {};
+    body[op] = [name];
+    report(request("/admin/twitch/channels", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify(body),
+    }), op === "join" ? "joined " + name : "parted " + name);
+  });
+
+  refresh();
+  setInterval(refresh, refreshMS);
+})();
@@ -0,0 +1,207 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessLogOptions configures where and how HTTP access records are written.
+// An empty Path keeps logging on the standard application logger.
+type AccessLogOptions struct {
+	Path       string
+	Format     string // "text" (default) or "json"
+	MaxSizeMB  int
+	MaxAge     time.Duration
+	MaxBackups int
+}
+
+type accessEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	Remote    string    `json:"remote"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	DurMS     float64   `json:"dur_ms"`
+	Bytes     int64     `json:"bytes"`
+	UserAgent string    `json:"ua"`
+	// Key names the API key that authorized the request.
+	Key string `json:"key,omitempty"`
+}
+
+type accessLogger struct {
+	json bool
+	out  io.Writer
+	file *rotatingFile
+}
+
+func newAccessLogger(opts AccessLogOptions) (*accessLogger, error) {
+	l := &accessLogger{json: strings.EqualFold(strings.TrimSpace(opts.Format), "json")}
+	path := strings.TrimSpace(opts.Path)
+	if path == "" {
+		return l, nil
+	}
+	file, err := openRotatingFile(path, int64(opts.MaxSizeMB)<<20, opts.MaxAge, opts.MaxBackups)
+	if err != nil {
+		return nil, err
+	}
+	l.file = file
+	l.out = file
+	return l, nil
+}
+
+func (l *accessLogger) Log(entry accessEntry) {
+	if l == nil {
+		return
+	}
+	var line string
+	if l.json {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return
+		}
+		line = string(data)
+	} else {
+		line = fmt.Sprintf("http access request_id=%s remote=%s method=%s path=%s status=%d dur=%s bytes=%d ua=%q",
+			entry.RequestID, entry.Remote, entry.Method, entry.Path, entry.Status,
+			time.Duration(entry.DurMS*float64(time.Millisecond)), entry.Bytes, entry.UserAgent)
+		if entry.Key != "" {
+			line += " key=" + entry.Key
+		}
+	}
+	if l.out == nil {
+		if l.json {
+			_, _ = io.WriteString(log.Writer(), line+"\n")
+			return
+		}
+		log.Print(line)
+		return
+	}
+	if !l.json {
+		line = entry.Time.Format(time.RFC3339Nano) + " " + line
+	}
+	_, _ = io.WriteString(l.out, line+"\n")
+}
+
+func (l *accessLogger) Close() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	return l.file.Close()
+}
+
+// rotatingFile is an append-only file that rolls over to a timestamped backup
+// once it exceeds maxSize bytes or has been open longer than maxAge.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+
+	f        *os.File
+	size     int64
+	openedAt time.Time
+}
+
+func openRotatingFile(path string, maxSize int64, maxAge time.Duration, maxBackups int) (*rotatingFile, error) {
+	r := &rotatingFile{path: path, maxSize: maxSize, maxAge: maxAge, maxBackups: maxBackups}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	if dir := filepath.Dir(r.path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("access log: mkdir: %w", err)
+		}
+	}
+	f, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("access log: open: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("access log: stat: %w", err)
+	}
+	r.f = f
+	r.size = info.Size()
+	r.openedAt = time.Now()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return 0, os.ErrClosed
+	}
+	if r.shouldRotate(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			log.Printf("httpapi: access log rotate: %v", err)
+		}
+	}
+	n, err := r.f.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *rotatingFile) shouldRotate(next int64) bool {
+	if r.maxSize > 0 && r.size > 0 && r.size+next > r.maxSize {
+		return true
+	}
+	if r.maxAge > 0 && time.Since(r.openedAt) >= r.maxAge {
+		return true
+	}
+	return false
+}
+
+func (r *rotatingFile) rotate() error {
+	if err := r.f.Close(); err != nil {
+		return err
+	}
+	r.f = nil
+	backup := r.path + "." + time.Now().UTC().Format("20060102T150405.000")
+	if err := os.Rename(r.path, backup); err != nil && !os.IsNotExist(err) {
+		_ = r.open()
+		return err
+	}
+	r.pruneBackups()
+	return r.open()
+}
+
+func (r *rotatingFile) pruneBackups() {
+	if r.maxBackups <= 0 {
+		return
+	}
+	matches, err := filepath.Glob(r.path + ".*")
+	if err != nil || len(matches) <= r.maxBackups {
+		return
+	}
+	sort.Strings(matches)
+	for _, old := range matches[:len(matches)-r.maxBackups] {
+		_ = os.Remove(old)
+	}
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
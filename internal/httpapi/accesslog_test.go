@@ -0,0 +1,47 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessLoggerJSONRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	logger, err := newAccessLogger(AccessLogOptions{Path: path, Format: "json", MaxBackups: 1})
+	if err != nil {
+		t.Fatalf("new access logger: %v", err)
+	}
+	defer logger.Close()
+	// Force rotation after every line.
+	logger.file.maxSize = 1
+
+	for i := 0; i < 3; i++ {
+		logger.Log(accessEntry{Time: time.Now(), Method: "GET", Path: "/messages", Status: 200})
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected a single line in the active file, got %d", len(lines))
+	}
+	var entry accessEntry
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatalf("decode json line: %v", err)
+	}
+	if entry.Path != "/messages" || entry.Status != 200 {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+
+	backups, _ := filepath.Glob(path + ".*")
+	if len(backups) != 1 {
+		t.Fatalf("expected 1 retained backup, got %v", backups)
+	}
+}
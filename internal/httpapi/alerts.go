@@ -0,0 +1,199 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// Alert types other than the message types: AlertTrigger for messages
+// pushed by a trigger rule's alert action and AlertRedemption for channel
+// points redemptions.
+const (
+	AlertTrigger    = "trigger"
+	AlertRedemption = "redemption"
+)
+
+// alertMessageTypes are the message types that are alerts on their own.
+var alertMessageTypes = []string{
+	core.MessageTypeSub,
+	core.MessageTypeResub,
+	core.MessageTypeSubGift,
+	core.MessageTypeRaid,
+	core.MessageTypeSuperchat,
+}
+
+// alertTypes lists the values accepted by the /alerts types parameter.
+var alertTypes = append(slices.Clone(alertMessageTypes), AlertTrigger, AlertRedemption)
+
+// Alert is one event on /alerts.
+type Alert struct {
+	Type string `json:"type"`
+	// Rule and Label are set for trigger alerts.
+	Rule  string `json:"rule,omitempty"`
+	Label string `json:"label,omitempty"`
+	// Redemption is set for redemption alerts.
+	Redemption *Redemption      `json:"redemption,omitempty"`
+	Message    core.ChatMessage `json:"message"`
+	Ts         time.Time        `json:"ts"`
+}
+
+// messageAlert returns the alert msg raises by itself, if any.
+func messageAlert(msg core.ChatMessage) (Alert, bool) {
+	t := core.NormalizeMessageType(msg.MessageType)
+	if !slices.Contains(alertMessageTypes, t) {
+		return Alert{}, false
+	}
+	a := Alert{Type: t, Message: msg, Ts: msg.Ts.UTC()}
+	if msg.Ts.IsZero() {
+		a.Ts = time.Now().UTC()
+	}
+	return a, true
+}
+
+// PublishAlert queues a for every /alerts client whose filters match its
+// message. Like Broadcast it never blocks.
+func (s *Server) PublishAlert(a Alert) {
+	if a.Ts.IsZero() {
+		a.Ts = time.Now().UTC()
+	}
+	view := newMessageView(&a.Message)
+	s.hub.each(func(client *streamClient) {
+		s.queueAlert(client, view, a)
+	})
+}
+
+func (s *Server) queueAlert(client *streamClient, view *messageView, a Alert) {
+	if client.alerts == nil || !client.alertTypes[a.Type] || !client.match.match(view) {
+		return
+	}
+	select {
+	case client.alerts <- a:
+	default:
+		if s.metrics != nil {
+			s.metrics.IncBroadcastDrops(client.transport)
+		}
+	}
+}
+
+// parseAlertTypes reads the comma-separated types parameter; empty selects
+// every alert type.
+func parseAlertTypes(raw string) (map[string]bool, error) {
+	out := map[string]bool{}
+	for _, t := range strings.Split(raw, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == "" {
+			continue
+		}
+		if !slices.Contains(alertTypes, t) {
+			return nil, fmt.Errorf("types must be a comma-separated list of %s", strings.Join(alertTypes, ", "))
+		}
+		out[t] = true
+	}
+	if len(out) == 0 {
+		for _, t := range alertTypes {
+			out[t] = true
+		}
+	}
+	return out, nil
+}
+
+// handleAlerts streams subs, raids, Super Chats, redemptions and trigger
+// alerts as SSE "alert" events. Unlike /stream it sends nothing else and has
+// no backlog.
+func (s *Server) handleAlerts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
+		return
+	}
+	filters = filters.CloneForStream()
+	types, err := parseAlertTypes(r.URL.Query().Get("types"))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	w.Header().Set("Pragma", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "stream unsupported")
+		return
+	}
+
+	client := newStreamClient(filters, "alerts")
+	client.alerts = make(chan Alert, 64)
+	client.alertTypes = types
+
+	if !s.addClient(client) {
+		writeError(w, r, http.StatusServiceUnavailable, ErrCodeUnavailable, "server shutting down")
+		return
+	}
+	defer s.removeClient(client)
+
+	fmt.Fprintf(w, ":ok\n\n")
+	flusher.Flush()
+
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	send := func(a Alert) error {
+		a.Message = s.maskMessage(filters, a.Message)
+		data, err := json.Marshal(a)
+		if err != nil {
+			return nil
+		}
+		if _, err := fmt.Fprintf(w, "event: alert\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		if s.metrics != nil {
+			s.metrics.IncMessagesSent("alerts")
+		}
+		return nil
+	}
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-client.shutdown:
+			// Alerts are rare and worth showing; flush the queued ones.
+			for len(client.alerts) > 0 {
+				if err := send(<-client.alerts); err != nil {
+					return
+				}
+			}
+			fmt.Fprintf(w, "event: shutdown\ndata: {\"reason\":%q}\n\n", shutdownReason)
+			flusher.Flush()
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprintf(w, ":ping %d\n\n", time.Now().Unix()); err != nil {
+				return
+			}
+			flusher.Flush()
+		case a := <-client.alerts:
+			if err := send(a); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestAlertsStream(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+	ts := httptest.NewServer(srv.Mux())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/alerts?types=raid,superchat,trigger&platform=youtube", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open alerts: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+
+	waitForClients(t, srv, 1)
+	srv.Broadcast(core.ChatMessage{ID: "chat", Platform: "YouTube", Username: "a", Text: "hi"})
+	srv.Broadcast(core.ChatMessage{ID: "sub", Platform: "YouTube", Username: "a", MessageType: core.MessageTypeSub})
+	srv.Broadcast(core.ChatMessage{ID: "tw-raid", Platform: "Twitch", Username: "a", MessageType: core.MessageTypeRaid})
+	srv.Broadcast(core.ChatMessage{ID: "sc", Platform: "YouTube", Username: "b", MessageType: core.MessageTypeSuperchat, PaidAmount: "$5.00"})
+	srv.BroadcastStatus(StatusEvent{Type: StatusSessionStarted})
+	srv.PublishAlert(Alert{Type: AlertTrigger, Rule: "hype", Label: "HYPE", Message: core.ChatMessage{ID: "hype", Platform: "YouTube", Text: "hype"}})
+
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	body := readAll(t, resp)
+	for _, want := range []string{`"type":"superchat"`, `"PaidAmount":"$5.00"`, `"type":"trigger","rule":"hype","label":"HYPE"`, "event: shutdown"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("alerts missing %q: %q", want, body)
+		}
+	}
+	for _, unwanted := range []string{`"ID":"chat"`, `"ID":"sub"`, `"ID":"tw-raid"`, "event: status", "event: message"} {
+		if strings.Contains(body, unwanted) {
+			t.Fatalf("alerts should not contain %q: %q", unwanted, body)
+		}
+	}
+}
+
+func TestAlertsRejectsUnknownType(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/alerts?types=cheer", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+}
+
+func TestAlertsDoNotReachChatStreams(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+	client := newStreamClient(Filters{}, "sse")
+	if !srv.addClient(client) {
+		t.Fatal("add client")
+	}
+	defer srv.removeClient(client)
+	srv.PublishAlert(Alert{Type: AlertTrigger, Message: core.ChatMessage{ID: "m1"}})
+	select {
+	case msg := <-client.ch:
+		t.Fatalf("chat stream received alert %+v", msg)
+	default:
+	}
+}
@@ -0,0 +1,53 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ViewerSample is a concurrent-viewer count with the chat volume leading up
+// to it.
+type ViewerSample struct {
+	Platform string    `json:"platform"`
+	Channel  string    `json:"channel"`
+	Ts       time.Time `json:"ts"`
+	Viewers  int       `json:"viewers"`
+	// WindowStart is the previous sample of the same source; Messages
+	// counts the platform's messages between it and Ts. Both are omitted
+	// for a source's first sample.
+	WindowStart *time.Time `json:"window_start,omitempty"`
+	Messages    int64      `json:"messages"`
+}
+
+// ViewerStore is implemented by stores that keep viewer-count samples.
+// Filters select platforms and bound the sample time with since/until.
+type ViewerStore interface {
+	ListViewerSamples(ctx context.Context, filters Filters) ([]ViewerSample, error)
+}
+
+func (s *Server) handleViewerAnalytics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(ViewerStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "viewer analytics unavailable")
+		return
+	}
+	filters, err := ParseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	samples, err := store.ListViewerSamples(r.Context(), filters)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list viewer samples error")
+		return
+	}
+	if samples == nil {
+		samples = []ViewerSample{}
+	}
+	writeJSON(w, samples)
+}
@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type viewerStubStore struct {
+	stubStore
+	samples []ViewerSample
+	filters Filters
+}
+
+func (s *viewerStubStore) ListViewerSamples(ctx context.Context, filters Filters) ([]ViewerSample, error) {
+	s.filters = filters
+	return s.samples, nil
+}
+
+func TestViewerAnalyticsEndpoint(t *testing.T) {
+	store := &viewerStubStore{samples: []ViewerSample{{Platform: "Twitch", Channel: "elora", Ts: time.Now(), Viewers: 250, Messages: 12}}}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/analytics/viewers?platform=tw&since=1h", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list []ViewerSample
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].Viewers != 250 {
+		t.Fatalf("unexpected body %s (%v)", rec.Body.String(), err)
+	}
+	if len(store.filters.Platforms) != 1 || store.filters.Platforms[0] != "Twitch" || store.filters.Since == nil {
+		t.Fatalf("filters not parsed: %+v", store.filters)
+	}
+
+	rec = httptest.NewRecorder()
+	New(&stubStore{}, Options{}).Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/analytics/viewers", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without viewer store, got %d", rec.Code)
+	}
+}
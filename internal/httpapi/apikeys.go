@@ -0,0 +1,180 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// APIKey grants access to the HTTP API. A key with Platforms or Channels
+// only sees messages from those platforms or channels, and may only call
+// routes that honour the scope (messages, counts, streams and presence).
+type APIKey struct {
+	// Name identifies the key in access logs.
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	// Platforms restricts the key to these platforms (e.g. "twitch").
+	Platforms []string `json:"platforms,omitempty"`
+	// Channels restricts the key to messages posted in these channels.
+	Channels []string `json:"channels,omitempty"`
+	// Masked forces masked=true on every request made with the key.
+	Masked bool `json:"masked,omitempty"`
+	// Emit allows POST /emit, within the key's platform and channel scope.
+	Emit bool `json:"emit,omitempty"`
+}
+
+// Scoped reports whether the key is limited to some platforms or channels.
+func (k APIKey) Scoped() bool {
+	return len(k.Platforms) > 0 || len(k.Channels) > 0
+}
+
+// LoadAPIKeys reads a JSON array of APIKey from path and normalizes the
+// platform and channel scopes.
+func LoadAPIKeys(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("api keys: read: %w", err)
+	}
+	var keys []APIKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("api keys: decode: %w", err)
+	}
+	seen := make(map[string]bool, len(keys))
+	for i := range keys {
+		key := &keys[i]
+		key.Key = strings.TrimSpace(key.Key)
+		if key.Key == "" {
+			return nil, fmt.Errorf("api keys: entry %d has no key", i)
+		}
+		if seen[key.Key] {
+			return nil, fmt.Errorf("api keys: entry %d repeats a key", i)
+		}
+		seen[key.Key] = true
+		if key.Name == "" {
+			key.Name = fmt.Sprintf("key-%d", i)
+		}
+		for j, p := range key.Platforms {
+			canonical, ok := normalizePlatform(strings.TrimSpace(p))
+			if !ok || canonical == "" {
+				return nil, fmt.Errorf("api keys: %s: invalid platform %q", key.Name, p)
+			}
+			key.Platforms[j] = canonical
+		}
+		for j, c := range key.Channels {
+			if key.Channels[j] = normalizeChannel(c); key.Channels[j] == "" {
+				return nil, fmt.Errorf("api keys: %s: empty channel", key.Name)
+			}
+		}
+	}
+	return keys, nil
+}
+
+type apiKeyContextKey struct{}
+
+// APIKeyFromContext returns the key that authorized the request, if any.
+func APIKeyFromContext(ctx context.Context) (APIKey, bool) {
+	key, ok := ctx.Value(apiKeyContextKey{}).(APIKey)
+	return key, ok
+}
+
+// requestKey returns the key presented as a bearer token or, for browser
+// EventSource and WebSocket clients that cannot set headers, as api_key.
+func requestKey(r *http.Request) string {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return strings.TrimSpace(r.URL.Query().Get("api_key"))
+}
+
+// authenticate looks up the request's key when keys are configured.
+func (s *Server) authenticate(r *http.Request) (APIKey, bool) {
+	presented := requestKey(r)
+	if presented == "" {
+		return APIKey{}, false
+	}
+	for _, key := range s.opts.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(key.Key)) == 1 {
+			return key, true
+		}
+	}
+	return APIKey{}, false
+}
+
+var (
+	errOutOfScope         = errors.New("api key not permitted for this platform or channel")
+	errWhispersOutOfScope = errors.New("api key not permitted to read whispers")
+)
+
+// scopeFilters narrows filters to what the request's key may see. Asking
+// for platforms or channels outside the scope is an error rather than an
+// empty result. Whispers are addressed to the harvester's account rather
+// than a channel, so scoped keys never see them.
+func scopeFilters(ctx context.Context, filters Filters) (Filters, error) {
+	key, ok := APIKeyFromContext(ctx)
+	if !ok {
+		return filters, nil
+	}
+	if key.Masked {
+		filters.Masked = true
+	}
+	if key.Scoped() {
+		if filters.HasType(core.MessageTypeWhisper) {
+			return Filters{}, errWhispersOutOfScope
+		}
+		filters.IncludeWhispers = false
+	}
+	var err error
+	if filters.Platforms, err = narrowScope(filters.Platforms, key.Platforms); err != nil {
+		return Filters{}, err
+	}
+	if filters.Channels, err = narrowScope(filters.Channels, key.Channels); err != nil {
+		return Filters{}, err
+	}
+	return filters, nil
+}
+
+func narrowScope(requested, allowed []string) ([]string, error) {
+	if len(allowed) == 0 {
+		return requested, nil
+	}
+	if len(requested) == 0 {
+		return append([]string(nil), allowed...), nil
+	}
+	for _, want := range requested {
+		permitted := false
+		for _, a := range allowed {
+			if want == a {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			return nil, errOutOfScope
+		}
+	}
+	return requested, nil
+}
+
+// requestFilters parses the request's filters and applies its key scope,
+// writing the error response itself when either fails.
+func (s *Server) requestFilters(w http.ResponseWriter, r *http.Request) (Filters, bool) {
+	filters, err := FiltersFromRequest(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return Filters{}, false
+	}
+	if filters, err = scopeFilters(r.Context(), filters); err != nil {
+		writeError(w, r, http.StatusForbidden, ErrCodeForbidden, err.Error())
+		return Filters{}, false
+	}
+	return filters, true
+}
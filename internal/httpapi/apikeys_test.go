@@ -0,0 +1,130 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// matchingStore applies filters the way a real store would.
+type matchingStore struct {
+	stubStore
+}
+
+func (s *matchingStore) ListMessages(ctx context.Context, filters Filters) ([]core.ChatMessage, error) {
+	var out []core.ChatMessage
+	for _, msg := range s.messages {
+		if filters.Matches(msg) {
+			out = append(out, msg)
+		}
+	}
+	return out, nil
+}
+
+func TestLoadAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	data := `[{"key": "k1"}, {"name": "alice", "key": "k2", "platforms": ["tw"], "channels": ["#Alice"]}]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("write keys: %v", err)
+	}
+	keys, err := LoadAPIKeys(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if keys[0].Name != "key-0" || keys[0].Scoped() {
+		t.Fatalf("unexpected first key %+v", keys[0])
+	}
+	if !reflect.DeepEqual(keys[1].Platforms, []string{"Twitch"}) || !reflect.DeepEqual(keys[1].Channels, []string{"alice"}) {
+		t.Fatalf("unexpected scope %+v", keys[1])
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"key": "k1", "platforms": ["myspace"]}]`), 0o600); err != nil {
+		t.Fatalf("write keys: %v", err)
+	}
+	if _, err := LoadAPIKeys(path); err == nil {
+		t.Fatalf("expected an error for an unknown platform")
+	}
+}
+
+func TestAPIKeyScoping(t *testing.T) {
+	store := &matchingStore{stubStore{messages: []core.ChatMessage{
+		{ID: "1", Platform: "Twitch", Channel: "alice", Text: "hi from alice"},
+		{ID: "2", Platform: "Twitch", Channel: "bob", Text: "hi from bob"},
+		{ID: "3", Platform: "YouTube", Text: "hi from youtube"},
+		{ID: "4", Platform: "Twitch", Channel: "alice", MessageType: core.MessageTypeWhisper, Text: "psst"},
+	}}}
+	srv := New(store, Options{APIKeys: []APIKey{
+		{Name: "ops", Key: "ops-key"},
+		{Name: "alice", Key: "alice-key", Platforms: []string{"Twitch"}, Channels: []string{"alice"}},
+	}})
+
+	do := func(target, key string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		rec := httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, req)
+		return rec
+	}
+	ids := func(rec *httptest.ResponseRecorder) []string {
+		t.Helper()
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+		}
+		var rows []core.ChatMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &rows); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		var out []string
+		for _, msg := range rows {
+			out = append(out, msg.ID)
+		}
+		return out
+	}
+
+	if rec := do("/messages", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a key, got %d", rec.Code)
+	}
+	if rec := do("/messages", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for an unknown key, got %d", rec.Code)
+	}
+	if rec := do("/healthz", ""); rec.Code != http.StatusOK {
+		t.Fatalf("expected /healthz without a key, got %d", rec.Code)
+	}
+	if got := ids(do("/messages", "ops-key")); len(got) != 3 {
+		t.Fatalf("expected the unscoped key to see everything but whispers, got %v", got)
+	}
+	if got := ids(do("/messages?include_whispers=true", "ops-key")); len(got) != 4 {
+		t.Fatalf("expected the unscoped key to opt into whispers, got %v", got)
+	}
+	if got := ids(do("/messages?include_whispers=true", "alice-key")); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Fatalf("expected the scoped key to never see whispers, got %v", got)
+	}
+	if rec := do("/messages?type=whisper", "alice-key"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for whispers with a scoped key, got %d", rec.Code)
+	}
+	if got := ids(do("/messages", "alice-key")); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Fatalf("expected the scoped key to see only alice, got %v", got)
+	}
+	if got := ids(do("/messages?api_key=alice-key&channel=%23Alice", "")); !reflect.DeepEqual(got, []string{"1"}) {
+		t.Fatalf("expected api_key and channel to work, got %v", got)
+	}
+	if rec := do("/messages?channel=bob", "alice-key"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 outside the scope, got %d", rec.Code)
+	}
+	if rec := do("/messages?platform=youtube", "alice-key"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for another platform, got %d", rec.Code)
+	}
+	if rec := do("/users", "alice-key"); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected scoped keys to be refused on unscoped routes, got %d", rec.Code)
+	}
+}
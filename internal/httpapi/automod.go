@@ -0,0 +1,75 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AutoModEvent is a chat message held by Twitch AutoMod, with the
+// moderator's decision once it was reviewed.
+type AutoModEvent struct {
+	Platform  string `json:"platform"`
+	Channel   string `json:"channel,omitempty"`
+	MessageID string `json:"message_id"`
+	UserID    string `json:"user_id,omitempty"`
+	Username  string `json:"username"`
+	Text      string `json:"text"`
+	// Status is "held", "approved", "denied" or "expired".
+	Status     string     `json:"status"`
+	Reason     string     `json:"reason,omitempty"`
+	Level      int        `json:"level,omitempty"`
+	Moderator  string     `json:"moderator,omitempty"`
+	SessionID  string     `json:"session_id,omitempty"`
+	HeldAt     time.Time  `json:"held_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
+
+// AutoModStore is implemented by stores that record AutoMod events. Filters
+// select platforms, channels and sessions and bound the hold time with
+// since/until; statuses, when non-empty, selects review outcomes.
+type AutoModStore interface {
+	ListAutoMod(ctx context.Context, filters Filters, statuses []string) ([]AutoModEvent, error)
+}
+
+var autoModStatuses = map[string]bool{"held": true, "approved": true, "denied": true, "expired": true}
+
+func (s *Server) handleAutoMod(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(AutoModStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "automod events unavailable")
+		return
+	}
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
+		return
+	}
+	var statuses []string
+	for _, raw := range r.URL.Query()["status"] {
+		for _, part := range strings.Split(raw, ",") {
+			part = strings.ToLower(strings.TrimSpace(part))
+			if part == "" {
+				continue
+			}
+			if !autoModStatuses[part] {
+				writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "status must be held, approved, denied or expired")
+				return
+			}
+			statuses = append(statuses, part)
+		}
+	}
+	events, err := store.ListAutoMod(r.Context(), filters, statuses)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list automod events error")
+		return
+	}
+	if events == nil {
+		events = []AutoModEvent{}
+	}
+	writeJSON(w, events)
+}
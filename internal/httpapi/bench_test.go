@@ -0,0 +1,163 @@
+package httpapi
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// benchFilters is a mix of the filters overlays and dashboards typically
+// stream with.
+func benchFilters(b testing.TB, i int) Filters {
+	values := url.Values{}
+	switch i % 5 {
+	case 0:
+	case 1:
+		values.Set("platform", "twitch")
+	case 2:
+		values.Set("username", fmt.Sprintf("viewer%d,mod", i%50))
+	case 3:
+		values.Set("channel", "#Streamer")
+		values.Set("type", "chat,sub,resub")
+	case 4:
+		values.Set("user_id", fmt.Sprintf("%d,%d", i, i+1))
+		values.Set("platform", "youtube,twitch")
+	}
+	f, err := ParseFilters(values)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return f.CloneForStream()
+}
+
+func BenchmarkBroadcast(b *testing.B) {
+	for _, clients := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("clients=%d", clients), func(b *testing.B) {
+			srv := New(&stubStore{}, Options{})
+			for i := 0; i < clients; i++ {
+				client := newStreamClient(benchFilters(b, i), "sse")
+				client.ch = make(chan core.ChatMessage, 1)
+				srv.addClient(client)
+			}
+			msg := core.ChatMessage{
+				ID:          "bench",
+				Platform:    "Twitch",
+				Username:    "Viewer7",
+				UserID:      "12",
+				Channel:     "streamer",
+				MessageType: core.MessageTypeChat,
+				Ts:          time.Now(),
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				srv.Broadcast(msg)
+			}
+		})
+	}
+}
+
+func TestBroadcastCompiledFilters(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+	var clients []*streamClient
+	for i := 0; i < 5; i++ {
+		client := newStreamClient(benchFilters(t, i), "sse")
+		srv.addClient(client)
+		clients = append(clients, client)
+	}
+	msgs := []core.ChatMessage{
+		{ID: "a", Platform: "Twitch", Username: "Viewer2", Channel: "streamer", UserID: "4"},
+		{ID: "b", Platform: "YouTube", Username: "mod_jane", UserID: "5"},
+		{ID: "c", Platform: "Twitch", Username: "someone", Channel: "other", MessageType: core.MessageTypeSub},
+	}
+	want := [][]string{
+		{"a", "b", "c"},
+		{"a", "c"},
+		{"a", "b"},
+		{"a"},
+		{"a", "b"},
+	}
+	for _, msg := range msgs {
+		srv.Broadcast(msg)
+	}
+	for i, client := range clients {
+		var got []string
+		for len(client.ch) > 0 {
+			got = append(got, (<-client.ch).ID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want[i]) {
+			t.Fatalf("client %d received %v, want %v", i, got, want[i])
+		}
+	}
+}
+
+// BenchmarkBroadcastParallel measures several receivers broadcasting at once
+// while clients connect and disconnect.
+func BenchmarkBroadcastParallel(b *testing.B) {
+	srv := New(&stubStore{}, Options{})
+	for i := 0; i < 5000; i++ {
+		client := newStreamClient(benchFilters(b, i), "sse")
+		client.ch = make(chan core.ChatMessage, 1)
+		srv.addClient(client)
+	}
+	msg := core.ChatMessage{ID: "bench", Platform: "Twitch", Username: "Viewer7", Channel: "streamer", Ts: time.Now()}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if i%100 == 0 {
+				client := newStreamClient(Filters{}, "ws")
+				srv.addClient(client)
+				srv.removeClient(client)
+			}
+			srv.Broadcast(msg)
+			i++
+		}
+	})
+}
+
+func TestHubConcurrentBroadcast(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+	var clients []*streamClient
+	for i := 0; i < 40; i++ {
+		client := newStreamClient(Filters{}, "sse")
+		if !srv.addClient(client) {
+			t.Fatal("addClient refused before shutdown")
+		}
+		clients = append(clients, client)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				srv.Broadcast(core.ChatMessage{ID: "m"})
+			}
+		}()
+	}
+	wg.Wait()
+	for i, client := range clients {
+		if n := len(client.ch); n != 100 {
+			t.Fatalf("client %d received %d messages, want 100", i, n)
+		}
+	}
+	if got := srv.hub.len(); got != len(clients) {
+		t.Fatalf("hub.len() = %d", got)
+	}
+	for _, client := range clients {
+		srv.removeClient(client)
+	}
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if srv.addClient(newStreamClient(Filters{}, "sse")) {
+		t.Fatal("addClient accepted a client after shutdown")
+	}
+}
@@ -0,0 +1,156 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// historicalMaxAge is how long shared caches may reuse a bounded /messages
+// response before revalidating. Late writes into a closed window are rare
+// (backfills, replays) but possible, so this stays short.
+const historicalMaxAge = 60 * time.Second
+
+// MessagesVersion summarises the rows matching a set of filters.
+type MessagesVersion struct {
+	LatestID int64
+	Count    int64
+	LatestTs time.Time
+	// LatestEdit is when the most recently edited matching message was
+	// edited, or zero.
+	LatestEdit time.Time
+	// LatestDelete is when the most recently deleted matching message was
+	// deleted, or zero. It is tracked even when deleted rows are hidden.
+	LatestDelete time.Time
+}
+
+// VersionedStore is implemented by stores that can cheaply describe the rows
+// matching a query, enabling ETag / Last-Modified validators on /messages.
+type VersionedStore interface {
+	MessagesVersion(ctx context.Context, filters Filters) (MessagesVersion, error)
+}
+
+// messagesETag derives a weak validator from the filters and the version of
+// the matching rows.
+func messagesETag(filters Filters, v MessagesVersion) string {
+	h := sha1.New()
+	fmt.Fprintf(h, "p=%s;u=%s;l=%d;o=%s", strings.Join(filters.Platforms, ","), strings.Join(filters.Usernames, ","), filters.Limit, filters.Order)
+	if len(filters.SessionIDs) > 0 {
+		fmt.Fprintf(h, ";sid=%s", strings.Join(filters.SessionIDs, ","))
+	}
+	if len(filters.Channels) > 0 {
+		fmt.Fprintf(h, ";ch=%s", strings.Join(filters.Channels, ","))
+	}
+	if len(filters.UserIDs) > 0 {
+		fmt.Fprintf(h, ";uid=%s", strings.Join(filters.UserIDs, ","))
+	}
+	if len(filters.ChannelIDs) > 0 {
+		fmt.Fprintf(h, ";cid=%s", strings.Join(filters.ChannelIDs, ","))
+	}
+	if filters.Since != nil {
+		fmt.Fprintf(h, ";s=%d", filters.Since.UnixMilli())
+	}
+	if filters.Until != nil {
+		fmt.Fprintf(h, ";t=%d", filters.Until.UnixMilli())
+	}
+	if filters.ByReceived() {
+		fmt.Fprint(h, ";tf=received")
+	}
+	if filters.IncludeEdits || filters.Original {
+		fmt.Fprintf(h, ";ie=%t;orig=%t", filters.IncludeEdits, filters.Original)
+	}
+	if !v.LatestEdit.IsZero() {
+		fmt.Fprintf(h, ";e=%d", v.LatestEdit.UnixMilli())
+	}
+	if filters.IncludeDeleted {
+		fmt.Fprint(h, ";id=true")
+	}
+	if filters.IncludeWhispers {
+		fmt.Fprint(h, ";iw=true")
+	}
+	if filters.CollapseMirrors {
+		fmt.Fprint(h, ";cm=true")
+	}
+	if filters.Masked {
+		fmt.Fprint(h, ";m=true")
+	}
+	if len(filters.Types) > 0 {
+		fmt.Fprintf(h, ";ty=%s", strings.Join(filters.Types, ","))
+	}
+	if !v.LatestDelete.IsZero() {
+		fmt.Fprintf(h, ";d=%d", v.LatestDelete.UnixMilli())
+	}
+	return fmt.Sprintf(`W/"%s-%d-%d"`, hex.EncodeToString(h.Sum(nil))[:16], v.LatestID, v.Count)
+}
+
+// applyHistoricalCaching sets cache validators for bounded queries and
+// reports whether the request can be answered with 304 Not Modified.
+// format is the response encoding; each encoding gets its own ETag.
+func (s *Server) applyHistoricalCaching(w http.ResponseWriter, r *http.Request, filters Filters, format string) bool {
+	if !filters.Bounded(time.Now()) {
+		return false
+	}
+	vs, ok := s.store.(VersionedStore)
+	if !ok {
+		return false
+	}
+	v, err := vs.MessagesVersion(r.Context(), filters)
+	if err != nil {
+		return false
+	}
+
+	etag := messagesETag(filters, v)
+	if format != "json" {
+		etag = strings.TrimSuffix(etag, `"`) + "-" + format + `"`
+	}
+	h := w.Header()
+	h.Set("ETag", etag)
+	// With API keys the response depends on the key's scope and masking, so
+	// shared caches must not hand it to another client.
+	visibility := "public"
+	if len(s.opts.APIKeys) > 0 {
+		visibility = "private"
+		h.Add("Vary", "Authorization")
+	}
+	h.Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", visibility, int(historicalMaxAge/time.Second)))
+	h.Add("Vary", "Accept-Encoding")
+	h.Add("Vary", "Accept")
+	modified := v.LatestTs
+	if v.LatestEdit.After(modified) {
+		modified = v.LatestEdit
+	}
+	if v.LatestDelete.After(modified) {
+		modified = v.LatestDelete
+	}
+	if !modified.IsZero() {
+		h.Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, etag)
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modified.IsZero() {
+		since, err := http.ParseTime(ims)
+		if err != nil {
+			return false
+		}
+		return !modified.Truncate(time.Second).After(since)
+	}
+	return false
+}
+
+// etagMatches applies the weak comparison from RFC 9110 §8.8.3.2.
+func etagMatches(header, etag string) bool {
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
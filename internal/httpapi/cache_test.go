@@ -0,0 +1,268 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+type versionedStubStore struct {
+	stubStore
+	version MessagesVersion
+}
+
+func (s *versionedStubStore) MessagesVersion(ctx context.Context, filters Filters) (MessagesVersion, error) {
+	return s.version, nil
+}
+
+func TestMessagesETagRoundTrip(t *testing.T) {
+	store := &versionedStubStore{version: MessagesVersion{LatestID: 42, Count: 3, LatestTs: time.Unix(1700000000, 0)}}
+	srv := New(store, Options{})
+
+	target := "/messages?since=1699990000&until=1700000100"
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	etag := rec.Header().Get("ETag")
+	if etag == "" || rec.Header().Get("Last-Modified") == "" {
+		t.Fatalf("expected validators, got headers %v", rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected 304 for matching etag, got %d", rec.Code)
+	}
+
+	store.version.LatestID = 43
+	req = httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 after new row, got %d", rec.Code)
+	}
+}
+
+func TestMessagesCacheControlWithAPIKeys(t *testing.T) {
+	store := &versionedStubStore{version: MessagesVersion{LatestID: 42, Count: 3, LatestTs: time.Unix(1700000000, 0)}}
+	target := "/messages?since=1699990000&until=1700000100"
+
+	cases := []struct {
+		opts Options
+		want string
+	}{
+		{Options{}, "public, max-age=60"},
+		{Options{APIKeys: []APIKey{{Name: "ops", Key: "ops-key"}}}, "private, max-age=60"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Header.Set("Authorization", "Bearer ops-key")
+		rec := httptest.NewRecorder()
+		New(store, tc.opts).Mux().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", rec.Code)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != tc.want {
+			t.Fatalf("expected Cache-Control %q, got %q", tc.want, got)
+		}
+		private := len(tc.opts.APIKeys) > 0
+		if varies := strings.Contains(strings.Join(rec.Header().Values("Vary"), ","), "Authorization"); varies != private {
+			t.Fatalf("expected Vary: Authorization only with API keys, got %v", rec.Header().Values("Vary"))
+		}
+	}
+}
+
+func TestMessagesETagTracksDeletions(t *testing.T) {
+	filters, err := ParseFilters(map[string][]string{"include_deleted": {"true"}})
+	if err != nil || !filters.IncludeDeleted {
+		t.Fatalf("expected include_deleted to parse, got %+v err=%v", filters, err)
+	}
+	v := MessagesVersion{LatestID: 42, Count: 3}
+	if messagesETag(Filters{}, v) == messagesETag(filters, v) {
+		t.Fatalf("expected include_deleted to change the etag")
+	}
+	before := messagesETag(Filters{}, v)
+	v.LatestDelete = time.Unix(1700000000, 0)
+	if messagesETag(Filters{}, v) == before {
+		t.Fatalf("expected a deletion to change the etag")
+	}
+
+	deletedAt := time.Unix(1700000000, 0)
+	msg := core.ChatMessage{DeletedAt: &deletedAt}
+	if (Filters{}).Matches(msg) || !filters.Matches(msg) {
+		t.Fatalf("expected deleted messages to match only with include_deleted")
+	}
+}
+
+func TestWhispersHiddenByDefault(t *testing.T) {
+	filters, err := ParseFilters(map[string][]string{"include_whispers": {"1"}})
+	if err != nil || !filters.IncludeWhispers {
+		t.Fatalf("expected include_whispers to parse, got %+v err=%v", filters, err)
+	}
+	if _, err := ParseFilters(map[string][]string{"include_whispers": {"maybe"}}); err == nil {
+		t.Fatalf("expected an error for a non-boolean include_whispers")
+	}
+	v := MessagesVersion{LatestID: 42, Count: 3}
+	if messagesETag(Filters{}, v) == messagesETag(filters, v) {
+		t.Fatalf("expected include_whispers to change the etag")
+	}
+	msg := core.ChatMessage{Platform: "Twitch", MessageType: core.MessageTypeWhisper}
+	if (Filters{}).Matches(msg) || !filters.Matches(msg) {
+		t.Fatalf("expected whispers to match only with include_whispers")
+	}
+}
+
+func TestTypeFilter(t *testing.T) {
+	filters, err := ParseFilters(map[string][]string{"type": {"Superchat,sub", "deleted"}})
+	if err != nil || !reflect.DeepEqual(filters.Types, []string{"superchat", "sub", "deleted"}) {
+		t.Fatalf("expected types to parse, got %+v err=%v", filters, err)
+	}
+	if _, err := ParseFilters(map[string][]string{"type": {"cheer"}}); err == nil {
+		t.Fatalf("expected an error for an unknown type")
+	}
+	v := MessagesVersion{LatestID: 42, Count: 3}
+	if messagesETag(Filters{}, v) == messagesETag(filters, v) {
+		t.Fatalf("expected type to change the etag")
+	}
+
+	deletedAt := time.Now()
+	for _, tt := range []struct {
+		msg  core.ChatMessage
+		want bool
+	}{
+		{core.ChatMessage{Platform: "YouTube", MessageType: core.MessageTypeSuperchat}, true},
+		{core.ChatMessage{Platform: "Twitch"}, false},
+		{core.ChatMessage{Platform: "Twitch", DeletedAt: &deletedAt}, true},
+		{core.ChatMessage{Platform: "Twitch", MessageType: core.MessageTypeRaid}, false},
+	} {
+		if got := filters.Matches(tt.msg); got != tt.want {
+			t.Fatalf("Matches(%+v) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+
+	chat := Filters{Types: []string{core.MessageTypeChat}}
+	if !chat.Matches(core.ChatMessage{Platform: "Twitch"}) {
+		t.Fatalf("expected untyped messages to match type=chat")
+	}
+	whispers := Filters{Types: []string{core.MessageTypeWhisper}}
+	if !whispers.Matches(core.ChatMessage{Platform: "Twitch", MessageType: core.MessageTypeWhisper}) {
+		t.Fatalf("expected type=whisper to include whispers")
+	}
+}
+
+func TestMessagesOpenWindowNotCached(t *testing.T) {
+	srv := New(&versionedStubStore{}, Options{})
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/messages?since=5m", nil))
+	if rec.Header().Get("ETag") != "" {
+		t.Fatalf("expected no etag for open-ended query")
+	}
+}
+
+func TestPlatformIDFilters(t *testing.T) {
+	filters, err := ParseFilters(map[string][]string{"user_id": {"111, 222", "111"}, "channel_id": {"900"}})
+	if err != nil || !reflect.DeepEqual(filters.UserIDs, []string{"111", "222"}) || !reflect.DeepEqual(filters.ChannelIDs, []string{"900"}) {
+		t.Fatalf("expected IDs to parse, got %+v err=%v", filters, err)
+	}
+	v := MessagesVersion{LatestID: 42, Count: 3}
+	if messagesETag(Filters{}, v) == messagesETag(filters, v) {
+		t.Fatalf("expected IDs to change the etag")
+	}
+	if !filters.Matches(core.ChatMessage{Platform: "Twitch", UserID: "222", ChannelID: "900"}) {
+		t.Fatalf("expected a matching message")
+	}
+	if filters.Matches(core.ChatMessage{Platform: "Twitch", UserID: "333", ChannelID: "900"}) {
+		t.Fatalf("expected another user not to match")
+	}
+}
+
+func TestTimeFieldFilter(t *testing.T) {
+	filters, err := ParseFilters(map[string][]string{"time": {"Received"}, "since": {"2026-01-01T00:00:00Z"}})
+	if err != nil || !filters.ByReceived() {
+		t.Fatalf("expected time=received to parse, got %+v err=%v", filters, err)
+	}
+	if _, err := ParseFilters(map[string][]string{"time": {"server"}}); err == nil {
+		t.Fatalf("expected an unknown time field to be rejected")
+	}
+	v := MessagesVersion{LatestID: 42, Count: 3}
+	if messagesETag(filters, v) == messagesETag(Filters{Since: filters.Since}, v) {
+		t.Fatalf("expected the time field to change the etag")
+	}
+
+	received := filters.Since.Add(time.Second)
+	late := core.ChatMessage{Platform: "YouTube", Ts: filters.Since.Add(-time.Second), ReceivedAt: &received}
+	if !filters.Matches(late) {
+		t.Fatalf("expected a message received after since to match")
+	}
+	if (Filters{Since: filters.Since}).Matches(late) {
+		t.Fatalf("expected the platform timestamp to be used by default")
+	}
+}
+
+func TestMessagesCSV(t *testing.T) {
+	ts := time.Unix(1700000000, 0).UTC()
+	store := &versionedStubStore{
+		stubStore: stubStore{messages: []core.ChatMessage{
+			{ID: "m1", Platform: "Twitch", Channel: "elora", Username: "alice", Text: `say "hi", all`, Ts: ts},
+			{ID: "m2", Platform: "YouTube", Username: "bob", Text: "=HYPERLINK(\"x\")\nline two", Ts: ts, MessageType: core.MessageTypeSuperchat},
+		}},
+		version: MessagesVersion{LatestID: 2, Count: 2, LatestTs: ts},
+	}
+	srv := New(store, Options{})
+	target := "/messages?since=1699990000&until=1700000100"
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("csv: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	records, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("parse csv: %v", err)
+	}
+	want := [][]string{
+		messageCSVHeader,
+		{"m1", "2023-11-14T22:13:20Z", "Twitch", "elora", "alice", "", "", "chat", "", `say "hi", all`},
+		{"m2", "2023-11-14T22:13:20Z", "YouTube", "", "bob", "", "", "superchat", "", "'=HYPERLINK(\"x\")\nline two"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Fatalf("csv records = %q", records)
+	}
+
+	// The CSV representation has its own validator, so a cached JSON
+	// response is never revalidated as CSV.
+	csvETag := rec.Header().Get("ETag")
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	jsonETag := rec.Header().Get("ETag")
+	if csvETag == "" || csvETag == jsonETag {
+		t.Fatalf("etags csv=%q json=%q", csvETag, jsonETag)
+	}
+	req = httptest.NewRequest(http.MethodGet, target+"&format=csv", nil)
+	req.Header.Set("If-None-Match", jsonETag)
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("csv revalidated with json etag: %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/messages?format=xlsx", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("unknown format status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,275 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+const (
+	// emitMaxBody bounds a POST /emit request body.
+	emitMaxBody = 64 << 10
+	// emitMaxText and emitMaxUsername bound the emitted fields, in runes.
+	emitMaxText     = 2000
+	emitMaxUsername = 100
+	// emitMaxSkew is how far in the future an emitted timestamp may be.
+	emitMaxSkew = time.Minute
+	// emitRecentIDs is how many emitted message IDs are remembered for
+	// dedupe.
+	emitRecentIDs = 4096
+)
+
+// Emitter stores an emitted message and delivers it to stream clients,
+// normally by running it through the same pipeline as received chat.
+type Emitter func(ctx context.Context, msg core.ChatMessage) error
+
+// EmitRequest is the POST /emit body. Only platform, username and text are
+// required. Without an id, one is derived from the other fields so a
+// retried request with the same ts is deduped.
+type EmitRequest struct {
+	ID         string           `json:"id,omitempty"`
+	Platform   string           `json:"platform"`
+	Channel    string           `json:"channel,omitempty"`
+	Username   string           `json:"username"`
+	Text       string           `json:"text"`
+	Type       string           `json:"type,omitempty"`
+	Ts         time.Time        `json:"ts,omitempty"`
+	EmotesJSON string           `json:"emotes_json,omitempty"`
+	RawJSON    string           `json:"raw_json,omitempty"`
+	BadgesJSON string           `json:"badges_json,omitempty"`
+	Badges     []core.ChatBadge `json:"badges,omitempty"`
+	BadgesRaw  core.BadgesRaw   `json:"badges_raw,omitempty"`
+	Colour     string           `json:"colour,omitempty"`
+}
+
+// EmitResult reports what happened to an emitted message. Duplicate is set
+// when the ID was emitted recently and the message was not written again.
+type EmitResult struct {
+	OK        bool   `json:"ok"`
+	ID        string `json:"id"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+}
+
+// SetEmitter enables POST /emit, which injects messages through fn. Until
+// it is set the route answers 404.
+func (s *Server) SetEmitter(fn Emitter) {
+	s.emitMu.Lock()
+	defer s.emitMu.Unlock()
+	s.emitter = fn
+}
+
+func (s *Server) currentEmitter() Emitter {
+	s.emitMu.RLock()
+	defer s.emitMu.RUnlock()
+	return s.emitter
+}
+
+// emitAuth checks that r may emit and returns the API key that allowed it,
+// if any. Requests need Options.EmitToken or an API key with Emit set.
+func (s *Server) emitAuth(r *http.Request) (APIKey, bool) {
+	presented := requestKey(r)
+	if presented == "" {
+		return APIKey{}, false
+	}
+	if token := s.opts.EmitToken; token != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1 {
+		return APIKey{}, true
+	}
+	key, ok := s.authenticate(r)
+	return key, ok && key.Emit
+}
+
+// handleEmit validates, dedupes and injects one synthetic or system message.
+func (s *Server) handleEmit(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	emit := s.currentEmitter()
+	if emit == nil {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "emit unavailable")
+		return
+	}
+	key, ok := s.emitAuth(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gnasty-chat"`)
+		writeError(w, r, http.StatusUnauthorized, ErrCodeUnauthorized, "emit token or api key with emit required")
+		return
+	}
+	var req EmitRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, emitMaxBody)).Decode(&req); err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "invalid json body")
+		return
+	}
+	result, status, err := s.emitRequest(r.Context(), emit, key, req)
+	if err != nil {
+		writeError(w, r, status, emitErrorCode(status), err.Error())
+		return
+	}
+	writeJSON(w, result)
+}
+
+// emitRequest validates req, checks it against key's scope and emits it.
+// On failure it returns the HTTP status that describes the problem.
+func (s *Server) emitRequest(ctx context.Context, emit Emitter, key APIKey, req EmitRequest) (EmitResult, int, error) {
+	msg, err := emitMessage(req, time.Now().UTC())
+	if err != nil {
+		return EmitResult{}, http.StatusBadRequest, err
+	}
+	if !keyAllows(key, msg) {
+		return EmitResult{}, http.StatusForbidden, errOutOfScope
+	}
+	result, err := s.emit(ctx, emit, msg)
+	if err != nil {
+		return EmitResult{}, http.StatusInternalServerError, errors.New("emit failed")
+	}
+	return result, http.StatusOK, nil
+}
+
+func emitErrorCode(status int) string {
+	switch status {
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusInternalServerError:
+		return ErrCodeInternal
+	default:
+		return ErrCodeBadRequest
+	}
+}
+
+// emit writes msg unless its ID was emitted recently.
+func (s *Server) emit(ctx context.Context, fn Emitter, msg core.ChatMessage) (EmitResult, error) {
+	result := EmitResult{OK: true, ID: msg.ID}
+	if !s.emitted.add(msg.Platform + ":" + msg.ID) {
+		result.Duplicate = true
+		return result, nil
+	}
+	if err := fn(ctx, msg); err != nil {
+		s.emitted.remove(msg.Platform + ":" + msg.ID)
+		if s.metrics != nil {
+			s.metrics.IncDBWriteErrors()
+		}
+		return EmitResult{}, err
+	}
+	return result, nil
+}
+
+// emitMessage validates req and builds the message to inject.
+func emitMessage(req EmitRequest, now time.Time) (core.ChatMessage, error) {
+	platform, ok := normalizePlatform(strings.TrimSpace(req.Platform))
+	if !ok || platform == "" {
+		return core.ChatMessage{}, fmt.Errorf("platform must be twitch or youtube")
+	}
+	username := strings.TrimSpace(req.Username)
+	if username == "" || strings.TrimSpace(req.Text) == "" {
+		return core.ChatMessage{}, errors.New("username and text are required")
+	}
+	if utf8.RuneCountInString(username) > emitMaxUsername {
+		return core.ChatMessage{}, fmt.Errorf("username is longer than %d characters", emitMaxUsername)
+	}
+	if utf8.RuneCountInString(req.Text) > emitMaxText {
+		return core.ChatMessage{}, fmt.Errorf("text is longer than %d characters", emitMaxText)
+	}
+	msgType := core.MessageTypeChat
+	if t := strings.ToLower(strings.TrimSpace(req.Type)); t != "" {
+		if !knownMessageType(t) {
+			return core.ChatMessage{}, fmt.Errorf("unknown type %q", req.Type)
+		}
+		msgType = t
+	}
+	ts := req.Ts.UTC()
+	if req.Ts.IsZero() {
+		ts = now
+	} else if ts.After(now.Add(emitMaxSkew)) {
+		return core.ChatMessage{}, errors.New("ts is in the future")
+	}
+	for name, raw := range map[string]string{"emotes_json": req.EmotesJSON, "raw_json": req.RawJSON, "badges_json": req.BadgesJSON} {
+		if raw != "" && !json.Valid([]byte(raw)) {
+			return core.ChatMessage{}, fmt.Errorf("%s is not valid json", name)
+		}
+	}
+	id := strings.TrimSpace(req.ID)
+	if id == "" {
+		sum := sha256.Sum256([]byte(strings.Join([]string{platform, normalizeChannel(req.Channel), username, req.Text,
+			ts.Format(time.RFC3339Nano)}, "\x00")))
+		id = "emit-" + hex.EncodeToString(sum[:10])
+	}
+	received := now
+	return core.ChatMessage{
+		ID:            id,
+		PlatformMsgID: id,
+		Platform:      platform,
+		Channel:       normalizeChannel(req.Channel),
+		Username:      username,
+		Text:          req.Text,
+		MessageType:   msgType,
+		Ts:            ts,
+		ReceivedAt:    &received,
+		EmotesJSON:    req.EmotesJSON,
+		RawJSON:       req.RawJSON,
+		BadgesJSON:    req.BadgesJSON,
+		Badges:        req.Badges,
+		BadgesRaw:     req.BadgesRaw,
+		Colour:        req.Colour,
+	}, nil
+}
+
+// keyAllows reports whether a scoped key may emit msg.
+func keyAllows(key APIKey, msg core.ChatMessage) bool {
+	if len(key.Platforms) > 0 {
+		if _, err := narrowScope([]string{msg.Platform}, key.Platforms); err != nil {
+			return false
+		}
+	}
+	if len(key.Channels) > 0 {
+		if _, err := narrowScope([]string{msg.Channel}, key.Channels); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// recentIDs remembers the last emitRecentIDs emitted message keys.
+type recentIDs struct {
+	mu    sync.Mutex
+	seen  map[string]bool
+	order []string
+	next  int
+}
+
+func newRecentIDs(size int) *recentIDs {
+	return &recentIDs{seen: make(map[string]bool, size), order: make([]string, size)}
+}
+
+// add records key and reports whether it was new.
+func (c *recentIDs) add(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen[key] {
+		return false
+	}
+	if old := c.order[c.next]; old != "" {
+		delete(c.seen, old)
+	}
+	c.order[c.next] = key
+	c.next = (c.next + 1) % len(c.order)
+	c.seen[key] = true
+	return true
+}
+
+// remove forgets key so a failed emit can be retried.
+func (c *recentIDs) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.seen, key)
+}
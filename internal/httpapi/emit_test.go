@@ -0,0 +1,153 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestEmitEndpoint(t *testing.T) {
+	srv := New(&stubStore{}, Options{
+		EmitToken: "secret",
+		APIKeys: []APIKey{
+			{Name: "bridge", Key: "k-bridge", Emit: true, Platforms: []string{"Twitch"}, Channels: []string{"alice"}},
+			{Name: "reader", Key: "k-reader"},
+		},
+	})
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/emit", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("secret", `{"platform":"twitch","username":"sys","text":"hi"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without an emitter, got %d", rec.Code)
+	}
+
+	var got []core.ChatMessage
+	srv.SetEmitter(func(ctx context.Context, msg core.ChatMessage) error {
+		got = append(got, msg)
+		return nil
+	})
+
+	for _, token := range []string{"", "wrong", "k-reader"} {
+		if rec := post(token, `{"platform":"twitch","username":"sys","text":"hi"}`); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("token %q: expected 401, got %d", token, rec.Code)
+		}
+	}
+
+	rec := post("secret", `{"id":"m1","platform":"twitch","channel":"#Alice","username":"sys","text":"hi","type":"system"}`)
+	var result EmitResult
+	if rec.Code != http.StatusOK || json.Unmarshal(rec.Body.Bytes(), &result) != nil || !result.OK || result.ID != "m1" || result.Duplicate {
+		t.Fatalf("unexpected emit response %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(got) != 1 || got[0].Platform != "Twitch" || got[0].Channel != "alice" || got[0].MessageType != "system" || got[0].ReceivedAt == nil {
+		t.Fatalf("unexpected emitted messages %+v", got)
+	}
+
+	rec = post("secret", `{"id":"m1","platform":"twitch","username":"sys","text":"again"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"duplicate":true`) || len(got) != 1 {
+		t.Fatalf("expected a duplicate, got %d: %s (%d emitted)", rec.Code, rec.Body.String(), len(got))
+	}
+
+	for _, body := range []string{
+		`not json`,
+		`{"platform":"myspace","username":"sys","text":"hi"}`,
+		`{"platform":"twitch","text":"hi"}`,
+		`{"platform":"twitch","username":"sys","text":"hi","type":"nonsense"}`,
+		`{"platform":"twitch","username":"sys","text":"hi","ts":"2999-01-01T00:00:00Z"}`,
+		`{"platform":"twitch","username":"sys","text":"hi","raw_json":"{"}`,
+		`{"platform":"twitch","username":"sys","text":"` + strings.Repeat("x", emitMaxText+1) + `"}`,
+	} {
+		if rec := post("secret", body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %.60s, got %d", body, rec.Code)
+		}
+	}
+
+	if rec := post("k-bridge", `{"platform":"twitch","channel":"bob","username":"sys","text":"hi"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 outside the key's scope, got %d", rec.Code)
+	}
+	rec = post("k-bridge", `{"platform":"twitch","channel":"alice","username":"sys","text":"hi"}`)
+	if rec.Code != http.StatusOK || len(got) != 2 || !strings.HasPrefix(got[1].ID, "emit-") {
+		t.Fatalf("expected a scoped emit with a derived id, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	srv.SetEmitter(func(ctx context.Context, msg core.ChatMessage) error { return context.DeadlineExceeded })
+	if rec := post("secret", `{"id":"m2","platform":"twitch","username":"sys","text":"hi"}`); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 on a failed write, got %d", rec.Code)
+	}
+	srv.SetEmitter(func(ctx context.Context, msg core.ChatMessage) error { return nil })
+	if rec := post("secret", `{"id":"m2","platform":"twitch","username":"sys","text":"hi"}`); strings.Contains(rec.Body.String(), "duplicate") {
+		t.Fatalf("a failed emit should be retryable, got %s", rec.Body.String())
+	}
+}
+
+func TestEmitBatchEndpoint(t *testing.T) {
+	srv := New(&stubStore{}, Options{
+		EmitToken: "secret",
+		APIKeys:   []APIKey{{Name: "bridge", Key: "k-bridge", Emit: true, Channels: []string{"alice"}}},
+	})
+	var got []core.ChatMessage
+	srv.SetEmitter(func(ctx context.Context, msg core.ChatMessage) error {
+		got = append(got, msg)
+		return nil
+	})
+	post := func(token, body string) (*httptest.ResponseRecorder, EmitBatchResult) {
+		req := httptest.NewRequest(http.MethodPost, "/emit/batch", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, req)
+		var result EmitBatchResult
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("decode %s: %v", rec.Body.String(), err)
+			}
+		}
+		return rec, result
+	}
+
+	rec, result := post("secret", ` [{"id":"a1","platform":"twitch","username":"u","text":"one"},
+		{"id":"a2","platform":"youtube","username":"u","text":"two"},
+		{"id":"a1","platform":"twitch","username":"u","text":"again"},
+		{"platform":"twitch","username":"u"}]`)
+	if rec.Code != http.StatusOK || result.OK || result.Accepted != 2 || result.Duplicates != 1 || result.Failed != 1 || len(got) != 2 {
+		t.Fatalf("unexpected array result %d: %s", rec.Code, rec.Body.String())
+	}
+	if r := result.Results[3]; r.Index != 3 || r.OK || r.Error == "" {
+		t.Fatalf("expected the last item to fail, got %+v", r)
+	}
+
+	rec, result = post("secret", "{\"id\":\"n1\",\"platform\":\"twitch\",\"username\":\"u\",\"text\":\"one\"}\n\n{not json\n"+
+		"{\"id\":\"n2\",\"platform\":\"twitch\",\"username\":\"u\",\"text\":\"two\"}\n")
+	if rec.Code != http.StatusOK || result.Accepted != 2 || result.Failed != 1 || result.Results[1].Error != "invalid json" ||
+		result.Results[2].ID != "n2" {
+		t.Fatalf("unexpected ndjson result %d: %s", rec.Code, rec.Body.String())
+	}
+
+	_, result = post("k-bridge", `[{"platform":"twitch","channel":"alice","username":"u","text":"in"},
+		{"platform":"twitch","channel":"bob","username":"u","text":"out"}]`)
+	if result.Accepted != 1 || result.Failed != 1 || result.Results[1].Error != errOutOfScope.Error() {
+		t.Fatalf("expected scope to be enforced per item, got %+v", result)
+	}
+
+	for _, body := range []string{"", "  \n", "[]", `[{"platform":`} {
+		if rec, _ := post("secret", body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %q, got %d", body, rec.Code)
+		}
+	}
+	if rec, _ := post("secret", strings.Repeat("{}\n", emitBatchMaxItems+1)); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an oversized batch, got %d", rec.Code)
+	}
+	if rec, _ := post("wrong", `[]`); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,158 @@
+package httpapi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+const (
+	// emitBatchMaxBody bounds a POST /emit/batch request body.
+	emitBatchMaxBody = 16 << 20
+	// emitBatchMaxItems bounds the messages in one batch.
+	emitBatchMaxItems = 10000
+)
+
+// EmitBatchItem is the outcome for one message of a batch. Index is the
+// message's position in the array, or its line among the non-blank NDJSON
+// lines, counting from zero.
+type EmitBatchItem struct {
+	Index     int    `json:"index"`
+	OK        bool   `json:"ok"`
+	ID        string `json:"id,omitempty"`
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// EmitBatchResult is the POST /emit/batch response. OK is set when every
+// message was accepted or was a duplicate.
+type EmitBatchResult struct {
+	OK         bool            `json:"ok"`
+	Accepted   int             `json:"accepted"`
+	Duplicates int             `json:"duplicates"`
+	Failed     int             `json:"failed"`
+	Results    []EmitBatchItem `json:"results"`
+}
+
+// handleEmitBatch emits a JSON array or NDJSON body of messages, reporting
+// each one's outcome. A bad message does not stop the rest of the batch.
+func (s *Server) handleEmitBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	emit := s.currentEmitter()
+	if emit == nil {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "emit unavailable")
+		return
+	}
+	key, ok := s.emitAuth(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="gnasty-chat"`)
+		writeError(w, r, http.StatusUnauthorized, ErrCodeUnauthorized, "emit token or api key with emit required")
+		return
+	}
+	items, err := readEmitBatch(http.MaxBytesReader(w, r.Body, emitBatchMaxBody))
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	out := EmitBatchResult{Results: make([]EmitBatchItem, 0, len(items))}
+	for i, raw := range items {
+		if err := r.Context().Err(); err != nil {
+			return
+		}
+		item := EmitBatchItem{Index: i}
+		var req EmitRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			item.Error = "invalid json"
+		} else if result, _, err := s.emitRequest(r.Context(), emit, key, req); err != nil {
+			item.Error = err.Error()
+		} else {
+			item.OK, item.ID, item.Duplicate = true, result.ID, result.Duplicate
+		}
+		switch {
+		case !item.OK:
+			out.Failed++
+		case item.Duplicate:
+			out.Duplicates++
+		default:
+			out.Accepted++
+		}
+		out.Results = append(out.Results, item)
+	}
+	out.OK = out.Failed == 0
+	writeJSON(w, out)
+}
+
+// readEmitBatch splits a batch body into its messages. A body starting with
+// '[' is a JSON array; anything else is NDJSON, one message per line, where
+// a malformed line is reported against that message alone.
+func readEmitBatch(body io.Reader) ([]json.RawMessage, error) {
+	br := bufio.NewReader(body)
+	first, err := peekNonSpace(br)
+	if err != nil {
+		return nil, batchReadError(err)
+	}
+	var items []json.RawMessage
+	if first == '[' {
+		if err := json.NewDecoder(br).Decode(&items); err != nil {
+			return nil, batchReadError(err)
+		}
+	} else {
+		scanner := bufio.NewScanner(br)
+		scanner.Buffer(make([]byte, 0, 64<<10), emitMaxBody)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			if len(items) == emitBatchMaxItems {
+				return nil, fmt.Errorf("batch is limited to %d messages", emitBatchMaxItems)
+			}
+			items = append(items, json.RawMessage(bytes.Clone(line)))
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, batchReadError(err)
+		}
+	}
+	if len(items) == 0 {
+		return nil, errors.New("batch is empty")
+	}
+	if len(items) > emitBatchMaxItems {
+		return nil, fmt.Errorf("batch is limited to %d messages", emitBatchMaxItems)
+	}
+	return items, nil
+}
+
+// peekNonSpace skips leading whitespace and returns the next byte without
+// consuming it.
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+func batchReadError(err error) error {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return fmt.Errorf("batch body is larger than %d bytes", emitBatchMaxBody)
+	case errors.Is(err, io.EOF):
+		return errors.New("batch is empty")
+	case errors.Is(err, bufio.ErrTooLong):
+		return fmt.Errorf("an ndjson line is longer than %d bytes", emitMaxBody)
+	default:
+		return errors.New("invalid batch body")
+	}
+}
@@ -0,0 +1,93 @@
+package httpapi
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// RequestIDHeader carries the per-request correlation ID.
+const RequestIDHeader = "X-Request-ID"
+
+// Error codes used in the JSON error envelope.
+const (
+	ErrCodeBadRequest       = "bad_request"
+	ErrCodeUnauthorized     = "unauthorized"
+	ErrCodeForbidden        = "forbidden"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+	ErrCodeRateLimited      = "rate_limited"
+	ErrCodeInternal         = "internal"
+	ErrCodeUnavailable      = "unavailable"
+)
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the request ID assigned by the server
+// middleware, or an empty string when none is present.
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func withRequestID(r *http.Request) (*http.Request, string) {
+	id := sanitizeRequestID(r.Header.Get(RequestIDHeader))
+	if id == "" {
+		id = newRequestID()
+	}
+	return r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)), id
+}
+
+func sanitizeRequestID(raw string) string {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || len(raw) > 128 {
+		return ""
+	}
+	for _, r := range raw {
+		if r < 0x21 || r > 0x7e {
+			return ""
+		}
+	}
+	return raw
+}
+
+func newRequestID() string {
+	var buf [12]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(buf[:])
+}
+
+type errorEnvelope struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// writeError emits the standard JSON error envelope used by every API handler.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	var requestID string
+	if r != nil {
+		requestID = RequestIDFromContext(r.Context())
+	}
+	h := w.Header()
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(errorEnvelope{Error: errorBody{
+		Code:      code,
+		Message:   message,
+		RequestID: requestID,
+	}})
+}
@@ -0,0 +1,60 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+type stubStore struct {
+	messages []core.ChatMessage
+}
+
+func (s *stubStore) CountMessages(ctx context.Context, filters Filters) (int64, error) {
+	return int64(len(s.messages)), nil
+}
+
+func (s *stubStore) ListMessages(ctx context.Context, filters Filters) ([]core.ChatMessage, error) {
+	return s.messages, nil
+}
+
+func TestErrorEnvelopeCarriesRequestID(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+
+	req := httptest.NewRequest(http.MethodGet, "/messages?since=bogus", nil)
+	req.Header.Set(RequestIDHeader, "req-123")
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if got := rec.Header().Get(RequestIDHeader); got != "req-123" {
+		t.Fatalf("expected request id echoed, got %q", got)
+	}
+	var body errorEnvelope
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode envelope: %v", err)
+	}
+	if body.Error.Code != ErrCodeBadRequest || body.Error.RequestID != "req-123" || body.Error.Message == "" {
+		t.Fatalf("unexpected envelope: %+v", body.Error)
+	}
+}
+
+func TestRequestIDGeneratedWhenMissing(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/count", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get(RequestIDHeader); len(got) != 24 {
+		t.Fatalf("expected generated request id, got %q", got)
+	}
+}
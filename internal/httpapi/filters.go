@@ -26,13 +26,59 @@ const (
 	OrderAsc Order = "asc"
 )
 
+// TimeField selects which message timestamp since, until and order apply to.
+type TimeField string
+
+const (
+	// TimeFieldPlatform uses the platform's timestamp (core.ChatMessage.Ts).
+	TimeFieldPlatform TimeField = "platform"
+	// TimeFieldReceived uses the local receive time
+	// (core.ChatMessage.ReceivedTime).
+	TimeFieldReceived TimeField = "received"
+)
+
 // Filters captures the parsed query parameters for message lookups.
 type Filters struct {
 	Platforms []string
 	Usernames []string
-	Since     *time.Time
-	Limit     int
-	Order     Order
+	// SessionIDs restricts messages to the given broadcast sessions.
+	SessionIDs []string
+	Since      *time.Time
+	Until      *time.Time
+	Limit      int
+	Order      Order
+	// TimeField selects the timestamp Since, Until and Order apply to;
+	// empty means TimeFieldPlatform.
+	TimeField TimeField
+	// Channels restricts messages to the given platform channels
+	// (core.ChatMessage.Channel).
+	Channels []string
+	// UserIDs and ChannelIDs restrict messages to the given stable platform
+	// IDs (core.ChatMessage.UserID and ChannelID).
+	UserIDs    []string
+	ChannelIDs []string
+	// IncludeEdits attaches the version history of edited messages.
+	IncludeEdits bool
+	// Original returns edited messages with the text as first sent
+	// (only_latest=false) instead of the latest version.
+	Original bool
+	// IncludeDeleted keeps messages removed by moderation in the results;
+	// by default they are hidden.
+	IncludeDeleted bool
+	// IncludeWhispers keeps private messages to the harvester's account
+	// (core.MessageTypeWhisper) in the results; by default they are hidden.
+	IncludeWhispers bool
+	// Types restricts messages to the given core.MessageTypes. Asking for
+	// core.MessageTypeDeleted selects moderated messages of any type, and
+	// asking for core.MessageTypeWhisper implies IncludeWhispers.
+	Types []string
+	// CollapseMirrors hides messages tagged as copies of one sent on
+	// another platform (core.ChatMessage.MirrorOf), so a simulcast chat
+	// bridge is not counted twice.
+	CollapseMirrors bool
+	// Masked replaces words from the server's mask list in message text.
+	// It changes what is returned, not which messages match.
+	Masked bool
 }
 
 // ParseFilters parses query parameters into a Filters struct.
@@ -64,6 +110,16 @@ func ParseFilters(values url.Values) (Filters, error) {
 		}
 	}
 
+	if raw := values.Get("time"); raw != "" {
+		switch TimeField(strings.ToLower(raw)) {
+		case TimeFieldPlatform:
+		case TimeFieldReceived:
+			f.TimeField = TimeFieldReceived
+		default:
+			return Filters{}, errors.New("time must be platform or received")
+		}
+	}
+
 	if rawSince := values.Get("since"); rawSince != "" {
 		parsed, err := parseSince(rawSince)
 		if err != nil {
@@ -72,6 +128,18 @@ func ParseFilters(values url.Values) (Filters, error) {
 		f.Since = &parsed
 	}
 
+	if rawUntil := values.Get("until"); rawUntil != "" {
+		parsed, err := parseTimeBound(rawUntil)
+		if err != nil {
+			return Filters{}, errors.New("invalid until parameter")
+		}
+		f.Until = &parsed
+	}
+
+	if f.Since != nil && f.Until != nil && !f.Until.After(*f.Since) {
+		return Filters{}, errors.New("until must be after since")
+	}
+
 	if platforms := collect(values, "platform"); len(platforms) > 0 {
 		seen := make(map[string]struct{})
 		var out []string
@@ -111,15 +179,105 @@ func ParseFilters(values url.Values) (Filters, error) {
 				if part == "" {
 					continue
 				}
-				lowered := strings.ToLower(part)
-				if _, exists := seen[lowered]; !exists {
-					f.Usernames = append(f.Usernames, lowered)
-					seen[lowered] = struct{}{}
+				normalized := core.NormalizeUsernameQuery(part)
+				if normalized == "" {
+					continue
+				}
+				if _, exists := seen[normalized]; !exists {
+					f.Usernames = append(f.Usernames, normalized)
+					seen[normalized] = struct{}{}
 				}
 			}
 		}
 	}
 
+	if channels := collect(values, "channel"); len(channels) > 0 {
+		seen := make(map[string]struct{})
+		for _, raw := range channels {
+			for _, part := range strings.Split(raw, ",") {
+				part = normalizeChannel(part)
+				if part == "" {
+					continue
+				}
+				if _, exists := seen[part]; !exists {
+					f.Channels = append(f.Channels, part)
+					seen[part] = struct{}{}
+				}
+			}
+		}
+	}
+
+	f.UserIDs = collectIDs(values, "user_id")
+	f.ChannelIDs = collectIDs(values, "channel_id")
+	f.SessionIDs = collectIDs(values, "session_id")
+
+	if types := collect(values, "type"); len(types) > 0 {
+		seen := make(map[string]struct{})
+		for _, raw := range types {
+			for _, part := range strings.Split(raw, ",") {
+				part = strings.ToLower(strings.TrimSpace(part))
+				if part == "" {
+					continue
+				}
+				if !knownMessageType(part) {
+					return Filters{}, errors.New("invalid type filter")
+				}
+				if _, exists := seen[part]; !exists {
+					f.Types = append(f.Types, part)
+					seen[part] = struct{}{}
+				}
+			}
+		}
+	}
+
+	if raw := values.Get("include_edits"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Filters{}, errors.New("include_edits must be a boolean")
+		}
+		f.IncludeEdits = v
+	}
+
+	if raw := values.Get("only_latest"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Filters{}, errors.New("only_latest must be a boolean")
+		}
+		f.Original = !v
+	}
+
+	if raw := values.Get("include_deleted"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Filters{}, errors.New("include_deleted must be a boolean")
+		}
+		f.IncludeDeleted = v
+	}
+
+	if raw := values.Get("include_whispers"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Filters{}, errors.New("include_whispers must be a boolean")
+		}
+		f.IncludeWhispers = v
+	}
+
+	if raw := values.Get("collapse_mirrors"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Filters{}, errors.New("collapse_mirrors must be a boolean")
+		}
+		f.CollapseMirrors = v
+	}
+
+	if raw := values.Get("masked"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Filters{}, errors.New("masked must be a boolean")
+		}
+		f.Masked = v
+	}
+
 	return f, nil
 }
 
@@ -136,6 +294,40 @@ func collect(values url.Values, key string) []string {
 	return out
 }
 
+// collectIDs gathers the distinct, comma-separated opaque IDs given for key.
+// IDs are matched exactly, so they are only trimmed.
+func collectIDs(values url.Values, key string) []string {
+	var out []string
+	seen := make(map[string]struct{})
+	for _, raw := range collect(values, key) {
+		for _, part := range strings.Split(raw, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			if _, exists := seen[part]; !exists {
+				out = append(out, part)
+				seen[part] = struct{}{}
+			}
+		}
+	}
+	return out
+}
+
+// normalizeChannel lower-cases a channel name and strips a leading "#".
+func normalizeChannel(channel string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(channel), "#"))
+}
+
+func knownMessageType(t string) bool {
+	for _, known := range core.MessageTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
 func normalizePlatform(p string) (string, bool) {
 	switch strings.ToLower(p) {
 	case "twitch", "tw", "t":
@@ -150,6 +342,16 @@ func normalizePlatform(p string) (string, bool) {
 }
 
 func parseSince(raw string) (time.Time, error) {
+	t, err := parseTimeBound(raw)
+	if err != nil {
+		return time.Time{}, errors.New("invalid since parameter")
+	}
+	return t, nil
+}
+
+// parseTimeBound accepts RFC3339(Nano) timestamps, UNIX seconds, or a
+// duration interpreted as "that long ago".
+func parseTimeBound(raw string) (time.Time, error) {
 	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
 		return t.UTC(), nil
 	}
@@ -162,46 +364,45 @@ func parseSince(raw string) (time.Time, error) {
 	if d, err := time.ParseDuration(raw); err == nil {
 		return time.Now().Add(-d).UTC(), nil
 	}
-	return time.Time{}, errors.New("invalid since parameter")
+	return time.Time{}, errors.New("invalid time")
 }
 
 // Matches reports whether the provided message satisfies the filters.
+// Stream clients compile their filters once instead (see matcher).
 func (f Filters) Matches(msg core.ChatMessage) bool {
-	if len(f.Platforms) > 0 {
-		match := false
-		for _, p := range f.Platforms {
-			if p == "" || msg.Platform == p {
-				match = true
-				break
-			}
-		}
-		if !match {
-			return false
-		}
-	}
+	return f.compile().match(newMessageView(&msg))
+}
 
-	if len(f.Usernames) > 0 {
-		username := strings.ToLower(msg.Username)
-		match := false
-		for _, u := range f.Usernames {
-			if strings.Contains(username, u) {
-				match = true
-				break
-			}
-		}
-		if !match {
-			return false
+// HasType reports whether t was asked for with the type filter.
+func (f Filters) HasType(t string) bool {
+	for _, want := range f.Types {
+		if want == t {
+			return true
 		}
 	}
+	return false
+}
 
-	if f.Since != nil {
-		since := f.Since.UTC()
-		if msg.Ts.Before(since) {
-			return false
-		}
-	}
+// ShowsDeleted reports whether messages removed by moderation match.
+func (f Filters) ShowsDeleted() bool {
+	return f.IncludeDeleted || f.HasType(core.MessageTypeDeleted)
+}
+
+// ShowsWhispers reports whether whispers match.
+func (f Filters) ShowsWhispers() bool {
+	return f.IncludeWhispers || f.HasType(core.MessageTypeWhisper)
+}
+
+// ByReceived reports whether since, until and order apply to the receive
+// time rather than the platform timestamp.
+func (f Filters) ByReceived() bool {
+	return f.TimeField == TimeFieldReceived
+}
 
-	return true
+// Bounded reports whether the filters describe a closed historical window,
+// i.e. an until bound that already lies in the past.
+func (f Filters) Bounded(now time.Time) bool {
+	return f.Until != nil && f.Until.Before(now)
 }
 
 // CloneForStream returns a copy of the filters adjusted for streaming transports.
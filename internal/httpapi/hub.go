@@ -0,0 +1,111 @@
+package httpapi
+
+import "sync"
+
+const hubShards = 16
+
+// hub is the registry of stream clients. Clients are spread round-robin over
+// shards that each have their own lock, so a client connecting or leaving
+// only contends with one shard, and Broadcast holds read locks one shard at
+// a time: broadcasts from several receivers fan out concurrently and are
+// never blocked behind a registry that is being rebuilt.
+type hub struct {
+	// mu guards closed and next. add and close hold it so that every
+	// client added before close is seen by close.
+	mu     sync.Mutex
+	closed bool
+	next   uint64
+	// active counts clients between add and remove, so shutdown can wait
+	// for stream handlers to finish.
+	active sync.WaitGroup
+
+	shards [hubShards]hubShard
+}
+
+type hubShard struct {
+	mu      sync.RWMutex
+	clients map[*streamClient]struct{}
+}
+
+func newHub() *hub {
+	h := &hub{}
+	for i := range h.shards {
+		h.shards[i].clients = make(map[*streamClient]struct{})
+	}
+	return h
+}
+
+// add registers client unless the hub is closed.
+func (h *hub) add(client *streamClient) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	h.next++
+	client.shard = int(h.next % hubShards)
+	shard := &h.shards[client.shard]
+	shard.mu.Lock()
+	shard.clients[client] = struct{}{}
+	shard.mu.Unlock()
+	h.active.Add(1)
+	return true
+}
+
+// remove must be called exactly once for every client accepted by add.
+func (h *hub) remove(client *streamClient) {
+	shard := &h.shards[client.shard]
+	shard.mu.Lock()
+	delete(shard.clients, client)
+	shard.mu.Unlock()
+	h.active.Done()
+}
+
+// each calls fn for every registered client. fn must not block; it runs
+// under the shard's read lock.
+func (h *hub) each(fn func(*streamClient)) {
+	for i := range h.shards {
+		shard := &h.shards[i]
+		shard.mu.RLock()
+		for client := range shard.clients {
+			fn(client)
+		}
+		shard.mu.RUnlock()
+	}
+}
+
+// len returns the number of registered clients.
+func (h *hub) len() int {
+	n := 0
+	for i := range h.shards {
+		shard := &h.shards[i]
+		shard.mu.RLock()
+		n += len(shard.clients)
+		shard.mu.RUnlock()
+	}
+	return n
+}
+
+// close marks the hub closed and calls fn for every client registered at
+// that point. It reports false if the hub was already closed.
+func (h *hub) close(fn func(*streamClient)) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	h.closed = true
+	h.each(fn)
+	return true
+}
+
+// wait blocks until every added client has been removed.
+func (h *hub) wait() {
+	h.active.Wait()
+}
+
+func (h *hub) isClosed() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.closed
+}
@@ -1,6 +1,7 @@
 package httpapi
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 	"runtime"
@@ -14,22 +15,56 @@ type BuildInfo struct {
 	BuiltAt  time.Time
 }
 
+// StoreStats summarises the contents of a message store.
+type StoreStats struct {
+	TotalMessages    int64
+	RecentByPlatform map[string]int64
+	DBSizeBytes      int64
+}
+
+// StatsStore is implemented by stores that can report ingest statistics. The
+// window controls how far back RecentByPlatform counts messages.
+type StatsStore interface {
+	Stats(ctx context.Context, window time.Duration) (StoreStats, error)
+}
+
+// StatsWindow is the lookback used for the per-platform message rate.
+const StatsWindow = time.Minute
+
 type infoResponse struct {
-	Version  string `json:"version"`
-	Revision string `json:"rev"`
-	BuiltAt  string `json:"built_at"`
-	Go       string `json:"go"`
+	Version  string        `json:"version"`
+	Revision string        `json:"rev"`
+	BuiltAt  string        `json:"built_at"`
+	Go       string        `json:"go"`
+	Uptime   float64       `json:"uptime_secs"`
+	Stats    *statsPayload `json:"stats,omitempty"`
+}
+
+type statsPayload struct {
+	TotalMessages int64            `json:"total_messages"`
+	LastMinute    map[string]int64 `json:"last_minute"`
+	DBSizeBytes   int64            `json:"db_size_bytes"`
 }
 
-func (s *Server) handleInfo(w http.ResponseWriter, _ *http.Request) {
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
 	resp := infoResponse{
 		Version:  s.opts.Build.Version,
 		Revision: s.opts.Build.Revision,
 		Go:       runtime.Version(),
+		Uptime:   time.Since(s.started).Seconds(),
 	}
 	if !s.opts.Build.BuiltAt.IsZero() {
 		resp.BuiltAt = s.opts.Build.BuiltAt.UTC().Format(time.RFC3339)
 	}
+	if stats, ok := s.store.(StatsStore); ok {
+		if st, err := stats.Stats(r.Context(), StatsWindow); err == nil {
+			resp.Stats = &statsPayload{
+				TotalMessages: st.TotalMessages,
+				LastMinute:    st.RecentByPlatform,
+				DBSizeBytes:   st.DBSizeBytes,
+			}
+		}
+	}
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	_ = json.NewEncoder(w).Encode(resp)
 }
@@ -0,0 +1,62 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Link is a URL shared in a chat message.
+type Link struct {
+	ID        int64  `json:"id"`
+	MessageID string `json:"message_id,omitempty"`
+	Platform  string `json:"platform"`
+	Channel   string `json:"channel,omitempty"`
+	Username  string `json:"username"`
+	URL       string `json:"url"`
+	Domain    string `json:"domain"`
+	// Ts is the message's timestamp.
+	Ts time.Time `json:"ts"`
+	// Deleted is set when the message was removed by moderation.
+	Deleted bool `json:"deleted,omitempty"`
+}
+
+// LinkStore is implemented by stores that extract links from messages.
+// Filters select the messages as for ListMessages; domains, when given,
+// keep links to those domains or their subdomains.
+type LinkStore interface {
+	ListLinks(ctx context.Context, filters Filters, domains []string) ([]Link, error)
+}
+
+// handleLinks lists the links shared in chat, newest first, for moderation
+// review. It accepts the message filters plus domain (comma-separated or
+// repeated).
+func (s *Server) handleLinks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(LinkStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "links unavailable")
+		return
+	}
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
+		return
+	}
+	var domains []string
+	for _, d := range collectIDs(r.URL.Query(), "domain") {
+		domains = append(domains, strings.TrimPrefix(strings.ToLower(d), "www."))
+	}
+	links, err := store.ListLinks(r.Context(), filters, domains)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list links error")
+		return
+	}
+	if links == nil {
+		links = []Link{}
+	}
+	writeJSON(w, links)
+}
@@ -0,0 +1,47 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type linkStubStore struct {
+	stubStore
+	links   []Link
+	filters Filters
+	domains []string
+}
+
+func (s *linkStubStore) ListLinks(ctx context.Context, filters Filters, domains []string) ([]Link, error) {
+	s.filters, s.domains = filters, domains
+	return s.links, nil
+}
+
+func TestLinksEndpoint(t *testing.T) {
+	store := &linkStubStore{links: []Link{{ID: 1, Platform: "Twitch", Username: "alice", URL: "https://clips.twitch.tv/abc", Domain: "clips.twitch.tv", Ts: time.Now()}}}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/links?since=1h&domain=WWW.Twitch.tv,bit.ly", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list []Link
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].Domain != "clips.twitch.tv" {
+		t.Fatalf("unexpected body %s (%v)", rec.Body.String(), err)
+	}
+	if store.filters.Since == nil || !reflect.DeepEqual(store.domains, []string{"twitch.tv", "bit.ly"}) {
+		t.Fatalf("params not parsed: %+v %v", store.filters, store.domains)
+	}
+
+	rec = httptest.NewRecorder()
+	New(&stubStore{}, Options{}).Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/links", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without link store, got %d", rec.Code)
+	}
+}
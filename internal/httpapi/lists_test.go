@@ -0,0 +1,120 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// listStubStore serves the optional list endpoints, recording the filters
+// and the endpoint's own parameter (statuses or rewards) of the last call.
+type listStubStore struct {
+	stubStore
+	filters     Filters
+	params      []string
+	autoMod     []AutoModEvent
+	raids       []Raid
+	redemptions []Redemption
+}
+
+func (s *listStubStore) ListAutoMod(ctx context.Context, filters Filters, statuses []string) ([]AutoModEvent, error) {
+	s.filters, s.params = filters, statuses
+	return s.autoMod, nil
+}
+
+func (s *listStubStore) ListRaids(ctx context.Context, filters Filters) ([]Raid, error) {
+	s.filters = filters
+	return s.raids, nil
+}
+
+func (s *listStubStore) ListRedemptions(ctx context.Context, filters Filters, rewards []string) ([]Redemption, error) {
+	s.filters, s.params = filters, rewards
+	return s.redemptions, nil
+}
+
+func TestListEndpoints(t *testing.T) {
+	store := &listStubStore{
+		autoMod: []AutoModEvent{{Platform: "Twitch", Channel: "elora", MessageID: "m1", Username: "troll", Text: "rude",
+			Status: "held", Reason: "swearing", Level: 3, HeldAt: time.Now()}},
+		raids: []Raid{{ID: 1, Platform: "Twitch", Channel: "elora", Direction: "in", FromChannel: "friend", ToChannel: "elora",
+			Viewers: 42, SessionID: "twitch:1", Ts: time.Now()}},
+		redemptions: []Redemption{{Platform: "Twitch", Channel: "elora", ID: "r1", RewardID: "rw1", RewardTitle: "Song request",
+			Cost: 500, Username: "amy", UserInput: "play it again", RedeemedAt: time.Now()}},
+	}
+	srv := New(store, Options{})
+
+	cases := []struct {
+		path string
+		// bad, when set, is a request rejected with 400.
+		bad    string
+		check  func(body []byte) bool
+		params []string
+		// filters reports whether the query's filters reached the store.
+		filters func(Filters) bool
+	}{
+		{
+			path: "/automod?status=held,Denied&channel=elora",
+			bad:  "/automod?status=pending",
+			check: func(body []byte) bool {
+				var list []AutoModEvent
+				return json.Unmarshal(body, &list) == nil && len(list) == 1 && list[0].Reason == "swearing"
+			},
+			params:  []string{"held", "denied"},
+			filters: func(f Filters) bool { return len(f.Channels) == 1 && f.Channels[0] == "elora" },
+		},
+		{
+			path: "/raids?session_id=twitch:1",
+			check: func(body []byte) bool {
+				var list []Raid
+				return json.Unmarshal(body, &list) == nil && len(list) == 1 && list[0].Viewers == 42
+			},
+			filters: func(f Filters) bool { return len(f.SessionIDs) == 1 && f.SessionIDs[0] == "twitch:1" },
+		},
+		{
+			path: "/redemptions?reward=rw1,Hydrate&channel=elora",
+			check: func(body []byte) bool {
+				var list []Redemption
+				return json.Unmarshal(body, &list) == nil && len(list) == 1 && list[0].RewardTitle == "Song request"
+			},
+			params:  []string{"rw1", "Hydrate"},
+			filters: func(f Filters) bool { return len(f.Channels) == 1 && f.Channels[0] == "elora" },
+		},
+	}
+	for _, tc := range cases {
+		store.filters, store.params = Filters{}, nil
+		rec := httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %s", tc.path, rec.Code, rec.Body.String())
+		}
+		if !tc.check(rec.Body.Bytes()) {
+			t.Fatalf("%s: unexpected body %s", tc.path, rec.Body.String())
+		}
+		if !tc.filters(store.filters) || len(store.params) != len(tc.params) {
+			t.Fatalf("%s: params not parsed: params=%v filters=%+v", tc.path, store.params, store.filters)
+		}
+		for i, want := range tc.params {
+			if store.params[i] != want {
+				t.Fatalf("%s: params = %v, want %v", tc.path, store.params, tc.params)
+			}
+		}
+
+		if tc.bad != "" {
+			rec = httptest.NewRecorder()
+			srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.bad, nil))
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("%s: expected 400, got %d", tc.bad, rec.Code)
+			}
+		}
+
+		// Without a store implementing the list, the endpoint is missing.
+		rec = httptest.NewRecorder()
+		New(&stubStore{}, Options{}).Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Fatalf("%s: expected 404 without a store for it, got %d", tc.path, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Marker is a point of interest recorded by a chat trigger.
+type Marker struct {
+	ID        int64     `json:"id"`
+	Platform  string    `json:"platform"`
+	SessionID string    `json:"session_id,omitempty"`
+	Rule      string    `json:"rule"`
+	Label     string    `json:"label"`
+	MessageID string    `json:"message_id,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Ts        time.Time `json:"ts"`
+}
+
+// MarkerStore is implemented by stores that keep trigger markers. Filters
+// select platforms and sessions and bound the marker time with since/until.
+type MarkerStore interface {
+	ListMarkers(ctx context.Context, filters Filters) ([]Marker, error)
+}
+
+func (s *Server) handleMarkers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(MarkerStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "markers unavailable")
+		return
+	}
+	filters, err := ParseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	markers, err := store.ListMarkers(r.Context(), filters)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list markers error")
+		return
+	}
+	if markers == nil {
+		markers = []Marker{}
+	}
+	writeJSON(w, markers)
+}
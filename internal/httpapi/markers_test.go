@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type markerStubStore struct {
+	stubStore
+	markers []Marker
+	filters Filters
+}
+
+func (s *markerStubStore) ListMarkers(ctx context.Context, filters Filters) ([]Marker, error) {
+	s.filters = filters
+	return s.markers, nil
+}
+
+func TestMarkersEndpoint(t *testing.T) {
+	store := &markerStubStore{markers: []Marker{{ID: 1, Platform: "Twitch", SessionID: "twitch:1", Rule: "clip", Label: "alice: big play", Ts: time.Now()}}}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/markers?session_id=twitch:1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list []Marker
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].Label != "alice: big play" {
+		t.Fatalf("unexpected body %s (%v)", rec.Body.String(), err)
+	}
+	if len(store.filters.SessionIDs) != 1 || store.filters.SessionIDs[0] != "twitch:1" {
+		t.Fatalf("filters not parsed: %+v", store.filters)
+	}
+
+	rec = httptest.NewRecorder()
+	New(&stubStore{}, Options{}).Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/markers", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without marker store, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,138 @@
+package httpapi
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// wordMasker replaces listed words in message text with asterisks. Words
+// match whole, case-insensitively; an entry ending in "*" matches any word
+// starting with it.
+type wordMasker struct {
+	words    map[string]struct{}
+	prefixes []string
+}
+
+// loadWordMasker reads a word list with one entry per line; blank lines and
+// lines starting with "#" are ignored.
+func loadWordMasker(path string) (*wordMasker, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("mask words: open: %w", err)
+	}
+	defer f.Close()
+
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		words = append(words, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("mask words: read: %w", err)
+	}
+	return newWordMasker(words), nil
+}
+
+func newWordMasker(words []string) *wordMasker {
+	m := &wordMasker{words: make(map[string]struct{})}
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word == "" || strings.HasPrefix(word, "#") {
+			continue
+		}
+		if prefix, ok := strings.CutSuffix(word, "*"); ok {
+			if prefix != "" {
+				m.prefixes = append(m.prefixes, prefix)
+			}
+			continue
+		}
+		m.words[word] = struct{}{}
+	}
+	return m
+}
+
+// Mask returns text with every listed word replaced by asterisks of the
+// same length.
+func (m *wordMasker) Mask(text string) string {
+	runes := []rune(text)
+	changed := false
+	for i := 0; i < len(runes); {
+		if !isWordRune(runes[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(runes) && isWordRune(runes[j]) {
+			j++
+		}
+		if m.listed(strings.ToLower(string(runes[i:j]))) {
+			for k := i; k < j; k++ {
+				runes[k] = '*'
+			}
+			changed = true
+		}
+		i = j
+	}
+	if !changed {
+		return text
+	}
+	return string(runes)
+}
+
+func (m *wordMasker) listed(word string) bool {
+	if _, ok := m.words[word]; ok {
+		return true
+	}
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(word, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\''
+}
+
+// maskMessage applies the word list to msg when filters ask for masked
+// output. RawJSON still carries the original text, so it is dropped from
+// messages that were changed.
+func (s *Server) maskMessage(filters Filters, msg core.ChatMessage) core.ChatMessage {
+	if !filters.Masked || s.masker == nil {
+		return msg
+	}
+	text := s.masker.Mask(msg.Text)
+	changed := text != msg.Text
+	msg.Text = text
+	if len(msg.Edits) > 0 {
+		edits := make([]core.MessageEdit, len(msg.Edits))
+		for i, edit := range msg.Edits {
+			edit.Text = s.masker.Mask(edit.Text)
+			changed = changed || edit.Text != msg.Edits[i].Text
+			edits[i] = edit
+		}
+		msg.Edits = edits
+	}
+	if changed {
+		msg.RawJSON = ""
+	}
+	return msg
+}
+
+// maskMessages applies maskMessage to every row.
+func (s *Server) maskMessages(filters Filters, rows []core.ChatMessage) []core.ChatMessage {
+	if !filters.Masked || s.masker == nil {
+		return rows
+	}
+	out := make([]core.ChatMessage, len(rows))
+	for i, msg := range rows {
+		out[i] = s.maskMessage(filters, msg)
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestWordMasker(t *testing.T) {
+	m := newWordMasker([]string{"# comment", "darn", "heck*", ""})
+	for in, want := range map[string]string{
+		"Darn it":            "**** it",
+		"darned socks":       "darned socks",
+		"what the heckity?":  "what the *******?",
+		"no change here":     "no change here",
+		"DARN, darn... darn": "****, ****... ****",
+	} {
+		if got := m.Mask(in); got != want {
+			t.Fatalf("Mask(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMessagesMasked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "words.txt")
+	if err := os.WriteFile(path, []byte("darn\n"), 0o644); err != nil {
+		t.Fatalf("write word list: %v", err)
+	}
+	store := &stubStore{messages: []core.ChatMessage{
+		{ID: "1", Platform: "Twitch", Text: "darn it", RawJSON: `{"line":"darn it"}`},
+		{ID: "2", Platform: "Twitch", Text: "hello", RawJSON: `{"line":"hello"}`},
+	}}
+	srv := New(store, Options{MaskWordsFile: path})
+
+	get := func(target string) []core.ChatMessage {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET %s: status %d", target, rec.Code)
+		}
+		var rows []core.ChatMessage
+		if err := json.Unmarshal(rec.Body.Bytes(), &rows); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return rows
+	}
+
+	if rows := get("/messages"); rows[0].Text != "darn it" {
+		t.Fatalf("expected unmasked text by default, got %q", rows[0].Text)
+	}
+	rows := get("/messages?masked=true")
+	if rows[0].Text != "**** it" || rows[0].RawJSON != "" {
+		t.Fatalf("expected masked text without raw json, got %+v", rows[0])
+	}
+	if rows[1].Text != "hello" || rows[1].RawJSON == "" {
+		t.Fatalf("expected untouched message to keep raw json, got %+v", rows[1])
+	}
+	if store.messages[0].Text != "darn it" {
+		t.Fatalf("masking changed the stored message")
+	}
+}
@@ -0,0 +1,160 @@
+package httpapi
+
+import (
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// matcher is Filters compiled once when a stream client registers. ID and
+// type lists become sets and time bounds are converted to UTC up front, so
+// Broadcast only does map lookups per client.
+type matcher struct {
+	platforms  map[string]struct{}
+	usernames  []string
+	channels   map[string]struct{}
+	userIDs    map[string]struct{}
+	channelIDs map[string]struct{}
+	sessionIDs map[string]struct{}
+	types      map[string]struct{}
+
+	showDeleted     bool
+	showWhispers    bool
+	typeDeleted     bool
+	collapseMirrors bool
+
+	since, until       time.Time
+	hasSince, hasUntil bool
+	byReceived         bool
+}
+
+func (f Filters) compile() *matcher {
+	m := &matcher{
+		platforms:       stringSet(f.Platforms),
+		usernames:       f.Usernames,
+		channels:        stringSet(f.Channels),
+		userIDs:         stringSet(f.UserIDs),
+		channelIDs:      stringSet(f.ChannelIDs),
+		sessionIDs:      stringSet(f.SessionIDs),
+		types:           stringSet(f.Types),
+		showDeleted:     f.ShowsDeleted(),
+		showWhispers:    f.ShowsWhispers(),
+		typeDeleted:     f.HasType(core.MessageTypeDeleted),
+		byReceived:      f.ByReceived(),
+		collapseMirrors: f.CollapseMirrors,
+	}
+	// An empty platform entry means "any platform".
+	if _, ok := m.platforms[""]; ok {
+		m.platforms = nil
+	}
+	if f.Since != nil {
+		m.since, m.hasSince = f.Since.UTC(), true
+	}
+	if f.Until != nil {
+		m.until, m.hasUntil = f.Until.UTC(), true
+	}
+	return m
+}
+
+func stringSet(list []string) map[string]struct{} {
+	if len(list) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(list))
+	for _, s := range list {
+		set[s] = struct{}{}
+	}
+	return set
+}
+
+func inSet(set map[string]struct{}, s string) bool {
+	_, ok := set[s]
+	return ok
+}
+
+// messageView caches the case-folded fields of one broadcast message so they
+// are derived once per message rather than once per client.
+type messageView struct {
+	msg *core.ChatMessage
+
+	folded     bool
+	msgType    string
+	username   string
+	normalized string
+	channel    string
+}
+
+func newMessageView(msg *core.ChatMessage) *messageView {
+	return &messageView{msg: msg}
+}
+
+func (v *messageView) fold() {
+	if v.folded {
+		return
+	}
+	v.folded = true
+	v.msgType = core.NormalizeMessageType(v.msg.MessageType)
+	v.username = strings.ToLower(v.msg.Username)
+	v.normalized = core.NormalizeUsernameQuery(v.msg.Username)
+	v.channel = strings.ToLower(v.msg.Channel)
+}
+
+// match reports whether the message satisfies the compiled filters; it
+// agrees with Filters.Matches.
+func (m *matcher) match(v *messageView) bool {
+	msg := v.msg
+	if msg.DeletedAt != nil && !m.showDeleted {
+		return false
+	}
+	if msg.MessageType == core.MessageTypeWhisper && !m.showWhispers {
+		return false
+	}
+	if msg.MirrorOf != "" && m.collapseMirrors {
+		return false
+	}
+	if m.platforms != nil && !inSet(m.platforms, msg.Platform) {
+		return false
+	}
+	if m.userIDs != nil && !inSet(m.userIDs, msg.UserID) {
+		return false
+	}
+	if m.channelIDs != nil && !inSet(m.channelIDs, msg.ChannelID) {
+		return false
+	}
+	if m.sessionIDs != nil && !inSet(m.sessionIDs, msg.SessionID) {
+		return false
+	}
+	if m.hasSince || m.hasUntil {
+		ts := msg.Ts
+		if m.byReceived {
+			ts = msg.ReceivedTime()
+		}
+		if m.hasSince && ts.Before(m.since) {
+			return false
+		}
+		if m.hasUntil && !ts.Before(m.until) {
+			return false
+		}
+	}
+
+	if m.types == nil && m.channels == nil && len(m.usernames) == 0 {
+		return true
+	}
+	v.fold()
+	if m.types != nil && !inSet(m.types, v.msgType) && !(msg.DeletedAt != nil && m.typeDeleted) {
+		return false
+	}
+	if m.channels != nil && !inSet(m.channels, v.channel) {
+		return false
+	}
+	if len(m.usernames) > 0 {
+		for _, u := range m.usernames {
+			if strings.Contains(v.normalized, u) || strings.Contains(v.username, u) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
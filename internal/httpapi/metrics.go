@@ -17,11 +17,37 @@ type Metrics struct {
 	wsClients       prometheus.Gauge
 	sseClients      prometheus.Gauge
 	broadcastDrops  *prometheus.CounterVec
+	throttledDrops  *prometheus.CounterVec
 	rateLimited     prometheus.Counter
 	messagesSent    *prometheus.CounterVec
 	dbWriteErrors   prometheus.Counter
+	shutdownDrops   *prometheus.CounterVec
+	walBytes        prometheus.Gauge
+	checkpointTime  prometheus.Histogram
+	maintenanceRuns *prometheus.CounterVec
+	pluginCalls     *prometheus.CounterVec
+	pluginLatency   prometheus.Histogram
+	pluginRestarts  prometheus.Counter
+	receiverCrashes *prometheus.CounterVec
+	receiverUp      *prometheus.GaugeVec
+	ingestLatency   *prometheus.HistogramVec
+	writeDelay      *prometheus.HistogramVec
+	ingestOverflow  *prometheus.CounterVec
+	spamFlagged     *prometheus.CounterVec
+	shadowWindows   *prometheus.GaugeVec
+	shadowFailures  prometheus.Counter
+	externalWrites  prometheus.Counter
+	ytPolls         *prometheus.CounterVec
+	ytBootstraps    *prometheus.CounterVec
+	ytResets        *prometheus.CounterVec
+	ytActions       *prometheus.CounterVec
+	ytPollInterval  prometheus.Gauge
 }
 
+// ingestLatencyBuckets span a healthy sub-second pipeline up to an archive
+// running minutes behind live during a spike.
+var ingestLatencyBuckets = []float64{.05, .1, .25, .5, 1, 2, 5, 10, 20, 30, 60, 120, 300, 600}
+
 func newMetrics() *Metrics {
 	registry := prometheus.NewRegistry()
 	m := &Metrics{
@@ -52,6 +78,11 @@ func newMetrics() *Metrics {
 			Name:      "broadcast_drops_total",
 			Help:      "Number of messages dropped due to slow clients",
 		}, []string{"transport"}),
+		throttledDrops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "stream_throttled_total",
+			Help:      "Number of messages withheld from stream clients that asked for max_rate or sample",
+		}, []string{"transport"}),
 		rateLimited: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: "gnasty",
 			Name:      "http_rate_limited_total",
@@ -67,6 +98,115 @@ func newMetrics() *Metrics {
 			Name:      "db_write_errors_total",
 			Help:      "Number of database write errors reported",
 		}),
+		shutdownDrops: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "shutdown_disconnects_total",
+			Help:      "Number of stream clients disconnected by server shutdown",
+		}, []string{"transport"}),
+		walBytes: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "gnasty",
+			Name:      "sqlite_wal_bytes",
+			Help:      "Current size of the SQLite write-ahead log",
+		}),
+		checkpointTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "gnasty",
+			Name:      "sqlite_checkpoint_duration_seconds",
+			Help:      "Histogram of SQLite WAL checkpoint durations",
+			Buckets:   []float64{.001, .005, .01, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		}),
+		maintenanceRuns: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "sqlite_maintenance_runs_total",
+			Help:      "Number of SQLite maintenance passes by result",
+		}, []string{"result"}),
+		pluginCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "exec_plugin_messages_total",
+			Help:      "Number of messages sent to the exec plugin by result",
+		}, []string{"result"}),
+		pluginLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "gnasty",
+			Name:      "exec_plugin_latency_seconds",
+			Help:      "Histogram of exec plugin round-trip times",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+		}),
+		pluginRestarts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "exec_plugin_restarts_total",
+			Help:      "Number of times the exec plugin process was restarted",
+		}),
+		receiverCrashes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "receiver_crashes_total",
+			Help:      "Number of recovered receiver panics",
+		}, []string{"receiver"}),
+		receiverUp: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "gnasty",
+			Name:      "receiver_up",
+			Help:      "Whether each receiver is running (1) or stopped by a fatal error (0)",
+		}, []string{"receiver"}),
+		ingestLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gnasty",
+			Name:      "ingest_latency_seconds",
+			Help:      "Histogram of time from a message's platform timestamp to its database write",
+			Buckets:   ingestLatencyBuckets,
+		}, []string{"platform"}),
+		writeDelay: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "gnasty",
+			Name:      "ingest_write_delay_seconds",
+			Help:      "Histogram of time from receiving a message to its database write",
+			Buckets:   ingestLatencyBuckets,
+		}, []string{"platform"}),
+		ingestOverflow: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "ingest_overflow_total",
+			Help:      "Number of messages over a receiver's ingest rate limit, by whether they were shed or sampled",
+		}, []string{"receiver", "result"}),
+		spamFlagged: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "spam_flagged_total",
+			Help:      "Number of messages flagged by the spam burst detector",
+		}, []string{"platform"}),
+		shadowWindows: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "gnasty",
+			Name:      "shadow_verify_windows",
+			Help:      "Time windows compared by the last shadow sink verification, by whether they matched or diverged",
+		}, []string{"result"}),
+		shadowFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "shadow_write_failures_total",
+			Help:      "Number of messages the shadow sink failed to store or dropped",
+		}),
+		externalWrites: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "sqlite_external_writes_total",
+			Help:      "Number of messages written to the shared SQLite database by other processes",
+		}),
+		ytPolls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "youtube_polls_total",
+			Help:      "Number of YouTube live chat polls by result (ok, timeout or the error kind)",
+		}, []string{"result"}),
+		ytBootstraps: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "youtube_bootstraps_total",
+			Help:      "Number of YouTube watch page bootstraps by result",
+		}, []string{"result"}),
+		ytResets: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "youtube_continuation_resets_total",
+			Help:      "Number of times the YouTube continuation was discarded, by reason",
+		}, []string{"reason"}),
+		ytActions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "gnasty",
+			Name:      "youtube_actions_total",
+			Help:      "Number of YouTube live chat actions by whether they were parsed into messages, dropped or skipped",
+		}, []string{"result"}),
+		ytPollInterval: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "gnasty",
+			Name:      "youtube_poll_interval_seconds",
+			Help:      "Current delay before the next YouTube live chat poll",
+		}),
 	}
 
 	registry.MustRegister(
@@ -75,9 +215,31 @@ func newMetrics() *Metrics {
 		m.wsClients,
 		m.sseClients,
 		m.broadcastDrops,
+		m.throttledDrops,
 		m.rateLimited,
 		m.messagesSent,
 		m.dbWriteErrors,
+		m.shutdownDrops,
+		m.walBytes,
+		m.checkpointTime,
+		m.maintenanceRuns,
+		m.pluginCalls,
+		m.pluginLatency,
+		m.pluginRestarts,
+		m.receiverCrashes,
+		m.receiverUp,
+		m.ingestLatency,
+		m.writeDelay,
+		m.ingestOverflow,
+		m.spamFlagged,
+		m.shadowWindows,
+		m.shadowFailures,
+		m.externalWrites,
+		m.ytPolls,
+		m.ytBootstraps,
+		m.ytResets,
+		m.ytActions,
+		m.ytPollInterval,
 	)
 
 	return m
@@ -121,6 +283,14 @@ func (m *Metrics) IncBroadcastDrops(transport string) {
 	m.broadcastDrops.WithLabelValues(transport).Inc()
 }
 
+// IncThrottled counts a message withheld by a client's max_rate or sample.
+func (m *Metrics) IncThrottled(transport string) {
+	if m == nil {
+		return
+	}
+	m.throttledDrops.WithLabelValues(transport).Inc()
+}
+
 // IncRateLimited increments the rate limit counter.
 func (m *Metrics) IncRateLimited() {
 	if m == nil {
@@ -144,3 +314,183 @@ func (m *Metrics) IncDBWriteErrors() {
 	}
 	m.dbWriteErrors.Inc()
 }
+
+// IncShutdownDisconnects counts a stream client closed by Shutdown.
+func (m *Metrics) IncShutdownDisconnects(transport string) {
+	if m == nil {
+		return
+	}
+	m.shutdownDrops.WithLabelValues(transport).Inc()
+}
+
+// SetWALBytes records the current SQLite WAL size.
+func (m *Metrics) SetWALBytes(n int64) {
+	if m == nil {
+		return
+	}
+	m.walBytes.Set(float64(n))
+}
+
+// ObserveMaintenance records one maintenance pass; result is "ok", "busy"
+// or "error", and checkpoint is zero when the checkpoint did not run.
+func (m *Metrics) ObserveMaintenance(result string, checkpoint time.Duration) {
+	if m == nil {
+		return
+	}
+	m.maintenanceRuns.WithLabelValues(result).Inc()
+	if checkpoint > 0 {
+		m.checkpointTime.Observe(checkpoint.Seconds())
+	}
+}
+
+// ObservePluginCall records one exec plugin round trip; result is "ok",
+// "dropped", "error" or "timeout".
+func (m *Metrics) ObservePluginCall(result string, latency time.Duration) {
+	if m == nil {
+		return
+	}
+	m.pluginCalls.WithLabelValues(result).Inc()
+	m.pluginLatency.Observe(latency.Seconds())
+}
+
+// IncPluginRestarts increments the exec plugin restart counter.
+func (m *Metrics) IncPluginRestarts() {
+	if m == nil {
+		return
+	}
+	m.pluginRestarts.Inc()
+}
+
+// IncReceiverCrashes counts a recovered panic in receiver.
+func (m *Metrics) IncReceiverCrashes(receiver string) {
+	if m == nil {
+		return
+	}
+	m.receiverCrashes.WithLabelValues(receiver).Inc()
+}
+
+// SetReceiverUp records whether receiver is running.
+func (m *Metrics) SetReceiverUp(receiver string, up bool) {
+	if m == nil {
+		return
+	}
+	v := 0.0
+	if up {
+		v = 1
+	}
+	m.receiverUp.WithLabelValues(receiver).Set(v)
+}
+
+// ObserveIngestLatency records the time from a message's platform timestamp
+// to its database write. Negative values from clock skew count as zero.
+func (m *Metrics) ObserveIngestLatency(platform string, latency time.Duration) {
+	if m == nil {
+		return
+	}
+	m.ingestLatency.WithLabelValues(platform).Observe(max(latency, 0).Seconds())
+}
+
+// ObserveWriteDelay records the time from receiving a message to its
+// database write.
+func (m *Metrics) ObserveWriteDelay(platform string, delay time.Duration) {
+	if m == nil {
+		return
+	}
+	m.writeDelay.WithLabelValues(platform).Observe(max(delay, 0).Seconds())
+}
+
+// IncIngestOverflow counts a message over receiver's ingest rate limit,
+// which was stored anyway when sampled.
+func (m *Metrics) IncIngestOverflow(receiver string, sampled bool) {
+	if m == nil {
+		return
+	}
+	result := "shed"
+	if sampled {
+		result = "sampled"
+	}
+	m.ingestOverflow.WithLabelValues(receiver, result).Inc()
+}
+
+// IncSpamFlagged counts a message flagged by the spam detector.
+func (m *Metrics) IncSpamFlagged(platform string) {
+	if m == nil {
+		return
+	}
+	m.spamFlagged.WithLabelValues(platform).Inc()
+}
+
+// SetShadowVerify records the windows compared by the last shadow sink
+// verification.
+func (m *Metrics) SetShadowVerify(windows, divergent int) {
+	if m == nil {
+		return
+	}
+	m.shadowWindows.WithLabelValues("match").Set(float64(windows - divergent))
+	m.shadowWindows.WithLabelValues("diverged").Set(float64(divergent))
+}
+
+// IncShadowWriteFailures counts a message the shadow sink did not store.
+func (m *Metrics) IncShadowWriteFailures() {
+	if m == nil {
+		return
+	}
+	m.shadowFailures.Inc()
+}
+
+// AddExternalWrites counts messages other processes wrote to the shared
+// database.
+func (m *Metrics) AddExternalWrites(n int64) {
+	if m == nil {
+		return
+	}
+	m.externalWrites.Add(float64(n))
+}
+
+// IncYouTubePolls counts one YouTube poll; result is "ok", "timeout" or the
+// failure's error kind.
+func (m *Metrics) IncYouTubePolls(result string) {
+	if m == nil {
+		return
+	}
+	m.ytPolls.WithLabelValues(result).Inc()
+}
+
+// IncYouTubeBootstraps counts one YouTube watch page bootstrap.
+func (m *Metrics) IncYouTubeBootstraps(ok bool) {
+	if m == nil {
+		return
+	}
+	result := "ok"
+	if !ok {
+		result = "error"
+	}
+	m.ytBootstraps.WithLabelValues(result).Inc()
+}
+
+// IncYouTubeContinuationResets counts a discarded YouTube continuation.
+func (m *Metrics) IncYouTubeContinuationResets(reason string) {
+	if m == nil {
+		return
+	}
+	m.ytResets.WithLabelValues(reason).Inc()
+}
+
+// AddYouTubeActions counts one poll's chat actions parsed into messages,
+// chat actions dropped as unparseable and non-chat actions skipped.
+func (m *Metrics) AddYouTubeActions(parsed, dropped, skipped int) {
+	if m == nil {
+		return
+	}
+	m.ytActions.WithLabelValues("parsed").Add(float64(parsed))
+	m.ytActions.WithLabelValues("dropped").Add(float64(dropped))
+	m.ytActions.WithLabelValues("skipped").Add(float64(skipped))
+}
+
+// SetYouTubePollInterval records the delay before the next YouTube poll.
+func (m *Metrics) SetYouTubePollInterval(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.ytPollInterval.Set(d.Seconds())
+}
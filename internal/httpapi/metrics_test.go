@@ -0,0 +1,71 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestIngestLatencyMetrics(t *testing.T) {
+	srv := New(&stubStore{}, Options{EnableMetrics: true})
+	written := time.Now()
+	received := written.Add(-200 * time.Millisecond)
+
+	srv.ReportIngestLatency(core.ChatMessage{Platform: "YouTube", Ts: written.Add(-8 * time.Second), ReceivedAt: &received}, written)
+	// A platform clock running ahead of ours counts as no latency.
+	srv.ReportIngestLatency(core.ChatMessage{Platform: "Twitch", Ts: written.Add(time.Second)}, written)
+	// Messages without a timestamp are skipped.
+	srv.ReportIngestLatency(core.ChatMessage{Platform: "Twitch"}, written)
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`gnasty_ingest_latency_seconds_bucket{platform="YouTube",le="5"} 0`,
+		`gnasty_ingest_latency_seconds_bucket{platform="YouTube",le="10"} 1`,
+		`gnasty_ingest_latency_seconds_bucket{platform="Twitch",le="0.05"} 1`,
+		`gnasty_ingest_latency_seconds_count{platform="Twitch"} 1`,
+		`gnasty_ingest_write_delay_seconds_bucket{platform="YouTube",le="0.25"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `gnasty_ingest_write_delay_seconds_count{platform="Twitch"}`) {
+		t.Fatalf("write delay recorded without a receive time:\n%s", body)
+	}
+}
+
+func TestYouTubePollMetrics(t *testing.T) {
+	srv := New(&stubStore{}, Options{EnableMetrics: true})
+	srv.ReportYouTubePoll("ok")
+	srv.ReportYouTubePoll("network")
+	srv.ReportYouTubeBootstrap(true)
+	srv.ReportYouTubeBootstrap(false)
+	srv.ReportYouTubeContinuationReset("missing")
+	srv.ReportYouTubeActions(3, 1, 2)
+	srv.ReportYouTubeActions(1, 0, 0)
+	srv.ReportYouTubePollInterval(1500 * time.Millisecond)
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		`gnasty_youtube_polls_total{result="ok"} 1`,
+		`gnasty_youtube_polls_total{result="network"} 1`,
+		`gnasty_youtube_bootstraps_total{result="error"} 1`,
+		`gnasty_youtube_continuation_resets_total{reason="missing"} 1`,
+		`gnasty_youtube_actions_total{result="parsed"} 4`,
+		`gnasty_youtube_actions_total{result="dropped"} 1`,
+		`gnasty_youtube_actions_total{result="skipped"} 2`,
+		`gnasty_youtube_poll_interval_seconds 1.5`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bufio"
 	"compress/gzip"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 	"sync"
 	"time"
@@ -157,21 +159,82 @@ func (l *ipRateLimiter) cleanup(now time.Time) {
 	}
 }
 
-func remoteIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		parts := strings.Split(xff, ",")
-		for _, part := range parts {
-			part = strings.TrimSpace(part)
-			if part != "" {
-				return part
+// prefixList is a set of CIDR prefixes used for the trusted-proxy and
+// rate-limit allowlists.
+type prefixList []netip.Prefix
+
+// parsePrefixList parses CIDRs; bare addresses are accepted as single-host
+// prefixes. Blank entries are ignored.
+func parsePrefixList(entries []string) (prefixList, error) {
+	var list prefixList
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			prefix, err := netip.ParsePrefix(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid CIDR %q", entry)
 			}
+			list = append(list, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q", entry)
+		}
+		addr = addr.Unmap()
+		list = append(list, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return list, nil
+}
+
+func (p prefixList) contains(ip string) bool {
+	if len(p) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.WithZone("").Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
 		}
 	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	return false
+}
+
+// clientIP returns the address rate limits and access logs are keyed on.
+// X-Forwarded-For is only honoured when the direct peer is a trusted proxy;
+// the chain is then walked right to left and the first untrusted hop wins,
+// so clients cannot spoof their address by prepending entries.
+func clientIP(r *http.Request, trusted prefixList) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
-		return r.RemoteAddr
+		ip = r.RemoteAddr
+	}
+	if !trusted.contains(ip) {
+		return ip
+	}
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		addr, err := netip.ParseAddr(hop)
+		if err != nil {
+			return ip
+		}
+		ip = addr.WithZone("").Unmap().String()
+		if !trusted.contains(ip) {
+			return ip
+		}
 	}
-	return host
+	return ip
 }
 
 type corsPolicy struct {
@@ -229,7 +292,7 @@ func (c *corsPolicy) handlePreflight(w http.ResponseWriter, r *http.Request) (bo
 		return true, http.StatusForbidden
 	}
 	w.Header().Set("Access-Control-Allow-Origin", origin)
-	w.Header().Set("Access-Control-Allow-Methods", "GET,OPTIONS")
+	w.Header().Set("Access-Control-Allow-Methods", "GET,POST,OPTIONS")
 	if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
 		w.Header().Set("Access-Control-Allow-Headers", reqHeaders)
 	}
@@ -0,0 +1,82 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIPTrustedProxies(t *testing.T) {
+	trusted, err := parsePrefixList([]string{"10.0.0.0/8", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	cases := []struct {
+		name   string
+		remote string
+		xff    string
+		want   string
+	}{
+		{"untrusted peer ignores header", "203.0.113.7:5000", "198.51.100.1", "203.0.113.7"},
+		{"trusted peer uses header", "10.1.2.3:5000", "198.51.100.1", "198.51.100.1"},
+		{"spoofed prefix is skipped", "10.1.2.3:5000", "1.1.1.1, 198.51.100.1", "198.51.100.1"},
+		{"trusted hops are walked", "192.0.2.1:5000", "198.51.100.1, 10.9.9.9", "198.51.100.1"},
+		{"malformed hop stops walk", "10.1.2.3:5000", "198.51.100.1, bogus", "10.1.2.3"},
+		{"no header", "10.1.2.3:5000", "", "10.1.2.3"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/count", nil)
+		req.RemoteAddr = tc.remote
+		if tc.xff != "" {
+			req.Header.Set("X-Forwarded-For", tc.xff)
+		}
+		if got := clientIP(req, trusted); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+
+	if _, err := parsePrefixList([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatalf("expected error for invalid CIDR")
+	}
+}
+
+func TestRateLimitPerRouteAndAllowlist(t *testing.T) {
+	srv := New(&stubStore{}, Options{
+		RateLimitRPS:         1,
+		RateLimitBurst:       1,
+		StreamRateLimitRPS:   1,
+		StreamRateLimitBurst: 2,
+		RateLimitAllowlist:   []string{"127.0.0.0/8"},
+	})
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) }
+	query := srv.wrap("query", ok, handlerOptions{})
+	stream := srv.wrap("stream", ok, handlerOptions{stream: true})
+
+	do := func(h http.Handler, remote string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remote
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := do(query, "203.0.113.7:1"); code != http.StatusNoContent {
+		t.Fatalf("first query: got %d", code)
+	}
+	if code := do(query, "203.0.113.7:1"); code != http.StatusTooManyRequests {
+		t.Fatalf("second query: expected 429, got %d", code)
+	}
+	for i := 0; i < 2; i++ {
+		if code := do(stream, "203.0.113.7:1"); code != http.StatusNoContent {
+			t.Fatalf("stream %d: got %d", i, code)
+		}
+	}
+	if code := do(stream, "203.0.113.7:1"); code != http.StatusTooManyRequests {
+		t.Fatalf("third stream: expected 429, got %d", code)
+	}
+	for i := 0; i < 5; i++ {
+		if code := do(query, "127.0.0.1:1"); code != http.StatusNoContent {
+			t.Fatalf("allowlisted query %d: got %d", i, code)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package httpapi
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxSpamBursts bounds the bursts listed by /moderation.
+const maxSpamBursts = 20
+
+// ModerationSummary counts moderation activity over a time range.
+type ModerationSummary struct {
+	// Deleted counts messages removed by moderators or the platform.
+	Deleted int64       `json:"deleted"`
+	Spam    SpamSummary `json:"spam"`
+}
+
+// SpamSummary counts messages the spam detector flagged at or above
+// MinScore.
+type SpamSummary struct {
+	MinScore float64 `json:"min_score"`
+	Messages int64   `json:"messages"`
+	Accounts int64   `json:"accounts"`
+	// Bursts groups the flagged messages by text, largest first.
+	Bursts []SpamBurst `json:"bursts"`
+}
+
+// SpamBurst is one flagged text and how widely it was sent.
+type SpamBurst struct {
+	Platform  string    `json:"platform"`
+	Text      string    `json:"text"`
+	Messages  int64     `json:"messages"`
+	Accounts  int64     `json:"accounts"`
+	MaxScore  float64   `json:"max_score"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// ModerationStore is implemented by stores that can summarize moderation
+// activity. Filters select platforms, channels and sessions and bound the
+// message time with since/until.
+type ModerationStore interface {
+	ModerationSummary(ctx context.Context, filters Filters, minScore float64, bursts int) (ModerationSummary, error)
+}
+
+// handleModeration summarizes deleted and spam-flagged messages. The
+// min_score parameter, in (0, 1], sets the spam score counted as flagged.
+func (s *Server) handleModeration(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(ModerationStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "moderation summary unavailable")
+		return
+	}
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
+		return
+	}
+	minScore := 0.5
+	if raw := strings.TrimSpace(r.URL.Query().Get("min_score")); raw != "" {
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || v <= 0 || v > 1 || math.IsNaN(v) {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "min_score must be a number in (0, 1]")
+			return
+		}
+		minScore = v
+	}
+	summary, err := store.ModerationSummary(r.Context(), filters, minScore, maxSpamBursts)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "moderation summary error")
+		return
+	}
+	summary.Spam.MinScore = minScore
+	if summary.Spam.Bursts == nil {
+		summary.Spam.Bursts = []SpamBurst{}
+	}
+	writeJSON(w, summary)
+}
@@ -0,0 +1,60 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type moderationStubStore struct {
+	stubStore
+	summary  ModerationSummary
+	filters  Filters
+	minScore float64
+}
+
+func (s *moderationStubStore) ModerationSummary(ctx context.Context, filters Filters, minScore float64, bursts int) (ModerationSummary, error) {
+	s.filters, s.minScore = filters, minScore
+	return s.summary, nil
+}
+
+func TestModerationEndpoint(t *testing.T) {
+	store := &moderationStubStore{summary: ModerationSummary{Deleted: 4, Spam: SpamSummary{Messages: 12, Accounts: 9}}}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/moderation?channel=elora", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var got ModerationSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got.Deleted != 4 || got.Spam.Accounts != 9 || got.Spam.MinScore != 0.5 ||
+		got.Spam.Bursts == nil {
+		t.Fatalf("unexpected body %s (%v)", rec.Body.String(), err)
+	}
+	if store.minScore != 0.5 || len(store.filters.Channels) != 1 {
+		t.Fatalf("params not parsed: min_score=%v filters=%+v", store.minScore, store.filters)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/moderation?min_score=0.8", nil))
+	if rec.Code != http.StatusOK || store.minScore != 0.8 {
+		t.Fatalf("expected min_score 0.8, got %d %v", rec.Code, store.minScore)
+	}
+
+	for _, bad := range []string{"0", "1.5", "high"} {
+		rec = httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/moderation?min_score="+bad, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("min_score=%s: expected 400, got %d", bad, rec.Code)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	New(&stubStore{}, Options{}).Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/moderation", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a moderation store, got %d", rec.Code)
+	}
+}
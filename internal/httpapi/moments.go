@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// MomentTerm is an emote or keyword with its use count during a moment.
+type MomentTerm struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+// Moment is a detected chat velocity spike within a broadcast session.
+type Moment struct {
+	ID        int64        `json:"id"`
+	SessionID string       `json:"session_id"`
+	Platform  string       `json:"platform"`
+	Start     time.Time    `json:"start"`
+	End       time.Time    `json:"end"`
+	Messages  int          `json:"messages"`
+	PeakRate  float64      `json:"peak_rate"`
+	ZScore    float64      `json:"zscore"`
+	Emotes    []MomentTerm `json:"emotes"`
+	Keywords  []MomentTerm `json:"keywords"`
+}
+
+// MomentStore is implemented by stores that keep detected moments. Filters
+// select platforms and sessions and bound the moment start with since/until.
+type MomentStore interface {
+	ListMoments(ctx context.Context, filters Filters) ([]Moment, error)
+}
+
+func (s *Server) handleMoments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(MomentStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "moments unavailable")
+		return
+	}
+	filters, err := ParseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	moments, err := store.ListMoments(r.Context(), filters)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list moments error")
+		return
+	}
+	if moments == nil {
+		moments = []Moment{}
+	}
+	writeJSON(w, moments)
+}
@@ -0,0 +1,39 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type momentStubStore struct {
+	stubStore
+	filters Filters
+}
+
+func (s *momentStubStore) ListMoments(ctx context.Context, filters Filters) ([]Moment, error) {
+	s.filters = filters
+	return []Moment{{ID: 1, SessionID: "twitch:4001", Platform: "Twitch", Start: time.Now(), Messages: 20,
+		Emotes: []MomentTerm{{Text: "Kappa", Count: 20}}, Keywords: []MomentTerm{}}}, nil
+}
+
+func TestMomentsEndpoint(t *testing.T) {
+	store := &momentStubStore{}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/moments?session_id=twitch:4001", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list []Moment
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].Emotes[0].Text != "Kappa" {
+		t.Fatalf("unexpected body %s (%v)", rec.Body.String(), err)
+	}
+	if len(store.filters.SessionIDs) != 1 || store.filters.SessionIDs[0] != "twitch:4001" {
+		t.Fatalf("session filter not parsed: %+v", store.filters)
+	}
+}
@@ -0,0 +1,157 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+// marshalMsgpack encodes v as MessagePack with the same shape and field
+// names as its JSON encoding, so clients can switch encodings without
+// changing how they read messages. Map keys are written in sorted order.
+func marshalMsgpack(v any) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var tree any
+	if err := dec.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return appendMsgpack(make([]byte, 0, len(raw)), tree)
+}
+
+func appendMsgpack(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return append(b, 0xc0), nil
+	case bool:
+		if v {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return appendMsgpackInt(b, n), nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, err
+		}
+		b = append(b, 0xcb)
+		return binary.BigEndian.AppendUint64(b, math.Float64bits(f)), nil
+	case string:
+		return appendMsgpackString(b, v), nil
+	case []any:
+		b = appendMsgpackHeader(b, len(v), 0x90, 0xdc)
+		var err error
+		for _, item := range v {
+			if b, err = appendMsgpack(b, item); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b = appendMsgpackHeader(b, len(v), 0x80, 0xde)
+		var err error
+		for _, k := range keys {
+			b = appendMsgpackString(b, k)
+			if b, err = appendMsgpack(b, v[k]); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("msgpack: unsupported type %T", v)
+	}
+}
+
+func appendMsgpackInt(b []byte, n int64) []byte {
+	switch {
+	case n >= 0 && n <= 0x7f:
+		return append(b, byte(n))
+	case n < 0 && n >= -32:
+		return append(b, byte(n))
+	case n >= math.MinInt32 && n <= math.MaxInt32:
+		b = append(b, 0xd2)
+		return binary.BigEndian.AppendUint32(b, uint32(int32(n)))
+	default:
+		b = append(b, 0xd3)
+		return binary.BigEndian.AppendUint64(b, uint64(n))
+	}
+}
+
+func appendMsgpackString(b []byte, s string) []byte {
+	switch n := len(s); {
+	case n <= 31:
+		b = append(b, 0xa0|byte(n))
+	case n <= math.MaxUint8:
+		b = append(b, 0xd9, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, 0xda)
+		b = binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b = append(b, 0xdb)
+		b = binary.BigEndian.AppendUint32(b, uint32(n))
+	}
+	return append(b, s...)
+}
+
+// appendMsgpackHeader writes an array or map header: the fix form for up to
+// 15 entries, otherwise the 16- or 32-bit form (wide+1).
+func appendMsgpackHeader(b []byte, n int, fix, wide byte) []byte {
+	switch {
+	case n <= 15:
+		return append(b, fix|byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, wide)
+		return binary.BigEndian.AppendUint16(b, uint16(n))
+	default:
+		b = append(b, wide+1)
+		return binary.BigEndian.AppendUint32(b, uint32(n))
+	}
+}
+
+// WebSocket subprotocols selecting the /ws frame encoding. A negotiated
+// subprotocol takes precedence over the encoding query parameter.
+const (
+	wsSubprotocolJSON    = "gnasty.json"
+	wsSubprotocolMsgpack = "gnasty.msgpack"
+)
+
+func parseWSEncoding(raw string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "", "json":
+		return "json", true
+	case "msgpack":
+		return "msgpack", true
+	}
+	return "", false
+}
+
+// writeWSFrame sends v as a JSON text frame or a MessagePack binary frame.
+func writeWSFrame(ctx context.Context, conn *websocket.Conn, encoding string, v any) error {
+	if encoding != "msgpack" {
+		return wsjson.Write(ctx, conn, v)
+	}
+	data, err := marshalMsgpack(v)
+	if err != nil {
+		return err
+	}
+	return conn.Write(ctx, websocket.MessageBinary, data)
+}
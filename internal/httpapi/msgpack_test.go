@@ -0,0 +1,166 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"nhooyr.io/websocket"
+)
+
+// decodeMsgpack decodes the subset of MessagePack that marshalMsgpack emits.
+func decodeMsgpack(b []byte) (any, []byte, error) {
+	if len(b) == 0 {
+		return nil, nil, fmt.Errorf("short input")
+	}
+	c, b := b[0], b[1:]
+	str := func(n int) (any, []byte, error) { return string(b[:n]), b[n:], nil }
+	arr := func(n int) (any, []byte, error) {
+		out := make([]any, n)
+		var err error
+		for i := range out {
+			if out[i], b, err = decodeMsgpack(b); err != nil {
+				return nil, nil, err
+			}
+		}
+		return out, b, nil
+	}
+	obj := func(n int) (any, []byte, error) {
+		out := make(map[string]any, n)
+		for i := 0; i < n; i++ {
+			k, rest, err := decodeMsgpack(b)
+			if err != nil {
+				return nil, nil, err
+			}
+			var v any
+			if v, b, err = decodeMsgpack(rest); err != nil {
+				return nil, nil, err
+			}
+			out[k.(string)] = v
+		}
+		return out, b, nil
+	}
+	switch {
+	case c <= 0x7f:
+		return int64(c), b, nil
+	case c >= 0xe0:
+		return int64(int8(c)), b, nil
+	case c&0xe0 == 0xa0:
+		return str(int(c & 0x1f))
+	case c&0xf0 == 0x90:
+		return arr(int(c & 0x0f))
+	case c&0xf0 == 0x80:
+		return obj(int(c & 0x0f))
+	}
+	switch c {
+	case 0xc0:
+		return nil, b, nil
+	case 0xc2, 0xc3:
+		return c == 0xc3, b, nil
+	case 0xcb:
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), b[8:], nil
+	case 0xd2:
+		return int64(int32(binary.BigEndian.Uint32(b))), b[4:], nil
+	case 0xd3:
+		return int64(binary.BigEndian.Uint64(b)), b[8:], nil
+	case 0xd9:
+		n := int(b[0])
+		b = b[1:]
+		return str(n)
+	case 0xda:
+		n := int(binary.BigEndian.Uint16(b))
+		b = b[2:]
+		return str(n)
+	case 0xdc:
+		n := int(binary.BigEndian.Uint16(b))
+		b = b[2:]
+		return arr(n)
+	case 0xde:
+		n := int(binary.BigEndian.Uint16(b))
+		b = b[2:]
+		return obj(n)
+	}
+	return nil, nil, fmt.Errorf("unexpected byte 0x%x", c)
+}
+
+func TestMarshalMsgpack(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	in := map[string]any{
+		"small": 5, "neg": -3, "big": int64(1) << 40, "wide": -100000,
+		"pi": 3.5, "ok": true, "none": nil, "long": long,
+		"list": []any{"a", 1, false},
+	}
+	data, err := marshalMsgpack(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, rest, err := decodeMsgpack(data)
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("decode: %v (rest %d)", err, len(rest))
+	}
+	want := map[string]any{
+		"small": int64(5), "neg": int64(-3), "big": int64(1) << 40, "wide": int64(-100000),
+		"pi": 3.5, "ok": true, "none": nil, "long": long,
+		"list": []any{"a", int64(1), false},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("round trip = %#v", got)
+	}
+}
+
+func TestWSMsgpackSubprotocol(t *testing.T) {
+	srv := New(&stubStore{}, Options{WSCompression: true})
+	ts := httptest.NewServer(srv.Mux())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	url := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+	conn, _, err := websocket.Dial(ctx, url, &websocket.DialOptions{
+		Subprotocols:    []string{wsSubprotocolMsgpack},
+		CompressionMode: websocket.CompressionContextTakeover,
+	})
+	if err != nil {
+		t.Fatalf("dial ws: %v", err)
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "")
+	if conn.Subprotocol() != wsSubprotocolMsgpack {
+		t.Fatalf("subprotocol = %q", conn.Subprotocol())
+	}
+	waitForClients(t, srv, 1)
+
+	srv.Broadcast(core.ChatMessage{ID: "m1", Platform: "Twitch", Username: "alice", Text: strings.Repeat("hi ", 100)})
+	typ, data, err := conn.Read(ctx)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if typ != websocket.MessageBinary {
+		t.Fatalf("frame type = %v, want binary", typ)
+	}
+	v, _, err := decodeMsgpack(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	msg := v.(map[string]any)
+	if msg["ID"] != "m1" || msg["Username"] != "alice" {
+		t.Fatalf("unexpected message %v", msg)
+	}
+}
+
+func TestWSRejectsUnknownEncoding(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ws?encoding=protobuf", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,163 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// OverlayConfig describes one named variant of the embedded /ui overlay.
+type OverlayConfig struct {
+	Key         string    `json:"key"`
+	FontFamily  string    `json:"font_family,omitempty"`
+	FontSize    int       `json:"font_size,omitempty"`
+	TextColor   string    `json:"text_color,omitempty"`
+	Background  string    `json:"background,omitempty"`
+	ShowBadges  *bool     `json:"show_badges,omitempty"`
+	Platform    string    `json:"platform,omitempty"`
+	Username    string    `json:"username,omitempty"`
+	FadeSecs    int       `json:"fade_secs,omitempty"`
+	MaxLines    int       `json:"max_lines,omitempty"`
+	Transparent bool      `json:"transparent,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// OverlayStore persists overlay configurations. Stores that implement it get
+// the /ui/config endpoints.
+type OverlayStore interface {
+	ListOverlays(ctx context.Context) ([]OverlayConfig, error)
+	GetOverlay(ctx context.Context, key string) (OverlayConfig, bool, error)
+	PutOverlay(ctx context.Context, cfg OverlayConfig) error
+	DeleteOverlay(ctx context.Context, key string) (bool, error)
+}
+
+const maxOverlayBody = 16 << 10
+
+var (
+	overlayKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	cssColorPattern   = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|transparent)$`)
+)
+
+// Validate normalises the config in place and rejects values that could not
+// be applied safely to the overlay stylesheet.
+func (c *OverlayConfig) Validate() error {
+	if !overlayKeyPattern.MatchString(c.Key) {
+		return errors.New("key must be 1-64 letters, digits, '-' or '_'")
+	}
+	c.FontFamily = strings.TrimSpace(c.FontFamily)
+	if len(c.FontFamily) > 200 || strings.ContainsAny(c.FontFamily, ";{}<>\\") {
+		return errors.New("invalid font_family")
+	}
+	if c.FontSize != 0 && (c.FontSize < 8 || c.FontSize > 96) {
+		return errors.New("font_size must be between 8 and 96")
+	}
+	if c.TextColor != "" && !cssColorPattern.MatchString(c.TextColor) {
+		return errors.New("text_color must be a hex colour")
+	}
+	if c.Background != "" && !cssColorPattern.MatchString(c.Background) {
+		return errors.New("background must be a hex colour or transparent")
+	}
+	if c.Platform != "" {
+		canonical, ok := normalizePlatform(c.Platform)
+		if !ok {
+			return errors.New("invalid platform")
+		}
+		c.Platform = canonical
+	}
+	c.Username = strings.TrimSpace(c.Username)
+	if c.FadeSecs < 0 || c.FadeSecs > 3600 {
+		return errors.New("fade_secs must be between 0 and 3600")
+	}
+	if c.MaxLines < 0 || c.MaxLines > maxLimit {
+		return errors.New("max_lines must be between 0 and 1000")
+	}
+	return nil
+}
+
+func (s *Server) handleOverlayConfig(w http.ResponseWriter, r *http.Request) {
+	store, ok := s.store.(OverlayStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "overlay configs unavailable")
+		return
+	}
+	// Overlays are shared across channels, so scoped keys may read them but
+	// not change them.
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		if apiKey, ok := APIKeyFromContext(r.Context()); ok && apiKey.Scoped() {
+			writeError(w, r, http.StatusForbidden, ErrCodeForbidden, "api key not permitted to change overlays")
+			return
+		}
+	}
+
+	key := strings.Trim(strings.TrimPrefix(r.URL.Path, "/ui/config"), "/")
+	if key == "" {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+			return
+		}
+		list, err := store.ListOverlays(r.Context())
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list overlays error")
+			return
+		}
+		if list == nil {
+			list = []OverlayConfig{}
+		}
+		writeJSON(w, list)
+		return
+	}
+	if !overlayKeyPattern.MatchString(key) {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "invalid overlay key")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		cfg, found, err := store.GetOverlay(r.Context(), key)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "get overlay error")
+			return
+		}
+		if !found {
+			writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "overlay not found")
+			return
+		}
+		writeJSON(w, cfg)
+	case http.MethodPut, http.MethodPost:
+		var cfg OverlayConfig
+		dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxOverlayBody))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&cfg); err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "invalid json: "+err.Error())
+			return
+		}
+		cfg.Key = key
+		if err := cfg.Validate(); err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+		cfg.UpdatedAt = time.Now().UTC()
+		if err := store.PutOverlay(r.Context(), cfg); err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "save overlay error")
+			return
+		}
+		writeJSON(w, cfg)
+	case http.MethodDelete:
+		deleted, err := store.DeleteOverlay(r.Context(), key)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "delete overlay error")
+			return
+		}
+		if !deleted {
+			writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "overlay not found")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+	}
+}
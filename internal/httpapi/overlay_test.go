@@ -0,0 +1,91 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type overlayStubStore struct {
+	stubStore
+	overlays map[string]OverlayConfig
+}
+
+func (s *overlayStubStore) ListOverlays(ctx context.Context) ([]OverlayConfig, error) {
+	var out []OverlayConfig
+	for _, cfg := range s.overlays {
+		out = append(out, cfg)
+	}
+	return out, nil
+}
+
+func (s *overlayStubStore) GetOverlay(ctx context.Context, key string) (OverlayConfig, bool, error) {
+	cfg, ok := s.overlays[key]
+	return cfg, ok, nil
+}
+
+func (s *overlayStubStore) PutOverlay(ctx context.Context, cfg OverlayConfig) error {
+	s.overlays[cfg.Key] = cfg
+	return nil
+}
+
+func (s *overlayStubStore) DeleteOverlay(ctx context.Context, key string) (bool, error) {
+	_, ok := s.overlays[key]
+	delete(s.overlays, key)
+	return ok, nil
+}
+
+func TestOverlayConfigValidate(t *testing.T) {
+	cfg := OverlayConfig{Key: "stream-1", Platform: "yt", TextColor: "#ffcc00", Background: "transparent", FontFamily: "Inter, sans-serif"}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("expected valid config, got %v", err)
+	}
+	if cfg.Platform != "YouTube" {
+		t.Fatalf("expected canonical platform, got %q", cfg.Platform)
+	}
+
+	bad := []OverlayConfig{
+		{Key: "has space"},
+		{Key: "ok", TextColor: "red; background:url(x)"},
+		{Key: "ok", FontFamily: "x}body{display:none"},
+		{Key: "ok", FadeSecs: -1},
+		{Key: "ok", Platform: "irc"},
+	}
+	for _, c := range bad {
+		if err := c.Validate(); err == nil {
+			t.Fatalf("expected error for %+v", c)
+		}
+	}
+}
+
+func TestOverlayConfigScopedKeysReadOnly(t *testing.T) {
+	store := &overlayStubStore{overlays: map[string]OverlayConfig{"main": {Key: "main"}}}
+	srv := New(store, Options{EnableUI: true, APIKeys: []APIKey{
+		{Name: "ops", Key: "ops-key"},
+		{Name: "alice", Key: "alice-key", Channels: []string{"alice"}},
+	}})
+
+	cases := []struct {
+		method string
+		key    string
+		body   string
+		want   int
+	}{
+		{http.MethodGet, "alice-key", "", http.StatusOK},
+		{http.MethodPut, "alice-key", `{"max_lines":5}`, http.StatusForbidden},
+		{http.MethodDelete, "alice-key", "", http.StatusForbidden},
+		{http.MethodPut, "ops-key", `{"max_lines":5}`, http.StatusOK},
+		{http.MethodDelete, "ops-key", "", http.StatusNoContent},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, "/ui/config/main", strings.NewReader(tc.body))
+		req.Header.Set("Authorization", "Bearer "+tc.key)
+		rec := httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Fatalf("%s with %s: expected %d, got %d: %s", tc.method, tc.key, tc.want, rec.Code, rec.Body.String())
+		}
+	}
+}
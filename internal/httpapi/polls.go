@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// Poll is a Twitch poll or prediction, or a YouTube chat poll, with its
+// latest (or final) results.
+type Poll struct {
+	Platform        string            `json:"platform"`
+	Channel         string            `json:"channel,omitempty"`
+	ID              string            `json:"id"`
+	Kind            string            `json:"kind"`
+	Title           string            `json:"title"`
+	Status          string            `json:"status"`
+	Options         []core.PollOption `json:"options"`
+	WinningOptionID string            `json:"winning_option_id,omitempty"`
+	SessionID       string            `json:"session_id,omitempty"`
+	StartedAt       time.Time         `json:"started_at"`
+	EndedAt         *time.Time        `json:"ended_at,omitempty"`
+	UpdatedAt       time.Time         `json:"updated_at"`
+}
+
+// PollStore is implemented by stores that keep polls and predictions.
+// Filters select platforms and sessions and bound started_at with
+// since/until.
+type PollStore interface {
+	ListPolls(ctx context.Context, filters Filters) ([]Poll, error)
+}
+
+func (s *Server) handlePolls(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(PollStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "polls unavailable")
+		return
+	}
+	filters, err := ParseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	polls, err := store.ListPolls(r.Context(), filters)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list polls error")
+		return
+	}
+	if polls == nil {
+		polls = []Poll{}
+	}
+	writeJSON(w, polls)
+}
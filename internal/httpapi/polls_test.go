@@ -0,0 +1,48 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+type pollStubStore struct {
+	stubStore
+	polls   []Poll
+	filters Filters
+}
+
+func (s *pollStubStore) ListPolls(ctx context.Context, filters Filters) ([]Poll, error) {
+	s.filters = filters
+	return s.polls, nil
+}
+
+func TestPollsEndpoint(t *testing.T) {
+	store := &pollStubStore{polls: []Poll{{Platform: "Twitch", ID: "p1", Kind: "poll", Title: "Next game?", Status: "active", SessionID: "twitch:1",
+		Options: []core.PollOption{{ID: "c1", Title: "Celeste", Votes: 3}}, StartedAt: time.Now()}}}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/polls?session_id=twitch:1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list []Poll
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].Options[0].Votes != 3 {
+		t.Fatalf("unexpected body %s (%v)", rec.Body.String(), err)
+	}
+	if len(store.filters.SessionIDs) != 1 || store.filters.SessionIDs[0] != "twitch:1" {
+		t.Fatalf("filters not parsed: %+v", store.filters)
+	}
+
+	rec = httptest.NewRecorder()
+	New(&stubStore{}, Options{}).Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/polls", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without poll store, got %d", rec.Code)
+	}
+}
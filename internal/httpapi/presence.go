@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// PresenceInterval is one stretch of a chatter being in a channel's chat.
+// LeftAt is nil while the chatter is still present.
+type PresenceInterval struct {
+	Platform string     `json:"platform"`
+	Channel  string     `json:"channel"`
+	Username string     `json:"username"`
+	JoinedAt time.Time  `json:"joined_at"`
+	LeftAt   *time.Time `json:"left_at,omitempty"`
+}
+
+// PresenceStore is implemented by stores that record chat presence. It
+// returns the intervals of channel that cover at, optionally restricted to
+// platforms.
+type PresenceStore interface {
+	ListPresence(ctx context.Context, platforms []string, channel string, at time.Time) ([]PresenceInterval, error)
+}
+
+func (s *Server) handlePresence(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(PresenceStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "presence unavailable")
+		return
+	}
+	query := r.URL.Query()
+	channel := normalizeChannel(query.Get("channel"))
+	if channel == "" {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "channel is required")
+		return
+	}
+	at := time.Now().UTC()
+	if raw := strings.TrimSpace(query.Get("at")); raw != "" {
+		parsed, err := parseTimeBound(raw)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "invalid at parameter")
+			return
+		}
+		at = parsed
+	}
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
+		return
+	}
+	intervals, err := store.ListPresence(r.Context(), filters.Platforms, channel, at)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list presence error")
+		return
+	}
+	if intervals == nil {
+		intervals = []PresenceInterval{}
+	}
+	writeJSON(w, intervals)
+}
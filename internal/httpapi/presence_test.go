@@ -0,0 +1,56 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type presenceStubStore struct {
+	stubStore
+	intervals []PresenceInterval
+	platforms []string
+	channel   string
+	at        time.Time
+}
+
+func (s *presenceStubStore) ListPresence(ctx context.Context, platforms []string, channel string, at time.Time) ([]PresenceInterval, error) {
+	s.platforms, s.channel, s.at = platforms, channel, at
+	return s.intervals, nil
+}
+
+func TestPresenceEndpoint(t *testing.T) {
+	joined := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := &presenceStubStore{intervals: []PresenceInterval{{Platform: "Twitch", Channel: "elora", Username: "alice", JoinedAt: joined}}}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/presence?channel=%23Elora&at=2024-05-01T12:30:00Z&platform=twitch", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list []PresenceInterval
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].Username != "alice" || list[0].LeftAt != nil {
+		t.Fatalf("unexpected body %s (%v)", rec.Body.String(), err)
+	}
+	if store.channel != "elora" || !store.at.Equal(joined.Add(30*time.Minute)) || len(store.platforms) != 1 || store.platforms[0] != "Twitch" {
+		t.Fatalf("query not parsed: channel=%q at=%s platforms=%v", store.channel, store.at, store.platforms)
+	}
+
+	for _, target := range []string{"/presence", "/presence?channel=elora&at=soon"} {
+		rec = httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected 400, got %d", target, rec.Code)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	New(&stubStore{}, Options{}).Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/presence?channel=elora", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without presence store, got %d", rec.Code)
+	}
+}
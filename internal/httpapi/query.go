@@ -0,0 +1,218 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultQueryTimeout = 10 * time.Second
+	defaultQueryMaxRows = 1000
+	maxQueryBodyBytes   = 64 << 10
+)
+
+// QueryEngine runs read-only analytic SQL for /query. Query must return at
+// most limit rows and give up when ctx is done.
+type QueryEngine interface {
+	Query(ctx context.Context, sql string, limit int) (QueryResult, error)
+}
+
+// QueryResult is the /query response. Rows hold one value per column in
+// Columns order; Truncated is set when the row limit cut the result short.
+type QueryResult struct {
+	Columns   []string `json:"columns"`
+	Rows      [][]any  `json:"rows"`
+	RowCount  int      `json:"row_count"`
+	Truncated bool     `json:"truncated"`
+}
+
+// QueryError is a query the engine refused or failed to run because of the
+// SQL itself; its message is returned to the client.
+type QueryError struct {
+	Message string
+}
+
+func (e *QueryError) Error() string { return e.Message }
+
+// queryDenied lists keywords that never appear in a read-only SELECT and
+// would otherwise let a statement write, reconfigure the engine or reach
+// the filesystem.
+var queryDenied = map[string]bool{
+	"ALTER": true, "ATTACH": true, "CALL": true, "CHECKPOINT": true, "COPY": true,
+	"CREATE": true, "DELETE": true, "DETACH": true, "DROP": true, "EXPORT": true,
+	"IMPORT": true, "INSERT": true, "INSTALL": true, "LOAD": true, "PRAGMA": true,
+	"REPLACE": true, "RESET": true, "SET": true, "TRUNCATE": true, "UPDATE": true,
+	"USE": true, "VACUUM": true,
+}
+
+// checkReadOnlySQL accepts a single SELECT (or WITH ... SELECT) statement
+// and returns it without a trailing semicolon. Keywords are matched outside
+// string literals, quoted identifiers and comments.
+func checkReadOnlySQL(sql string) (string, error) {
+	var (
+		words []string
+		word  strings.Builder
+		end   = -1
+	)
+	flush := func() {
+		if word.Len() > 0 {
+			words = append(words, strings.ToUpper(word.String()))
+			word.Reset()
+		}
+	}
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		if end >= 0 && !isSpace(c) && !strings.HasPrefix(sql[i:], "--") && !strings.HasPrefix(sql[i:], "/*") {
+			return "", errors.New("only a single statement is allowed")
+		}
+		switch {
+		case c == '\'' || c == '"':
+			flush()
+			j := i + 1
+			for ; j < len(sql); j++ {
+				if sql[j] == c {
+					if j+1 < len(sql) && sql[j+1] == c {
+						j++
+						continue
+					}
+					break
+				}
+			}
+			if j >= len(sql) {
+				return "", errors.New("unterminated quoted string")
+			}
+			i = j
+		case strings.HasPrefix(sql[i:], "--"):
+			flush()
+			if j := strings.IndexByte(sql[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(sql)
+			}
+		case strings.HasPrefix(sql[i:], "/*"):
+			flush()
+			j := strings.Index(sql[i+2:], "*/")
+			if j < 0 {
+				return "", errors.New("unterminated comment")
+			}
+			i += j + 3
+		case c == ';':
+			flush()
+			end = i
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9':
+			word.WriteByte(c)
+		default:
+			flush()
+		}
+	}
+	flush()
+	if len(words) == 0 {
+		return "", errors.New("empty query")
+	}
+	if words[0] != "SELECT" && words[0] != "WITH" {
+		return "", errors.New("only SELECT queries are allowed")
+	}
+	for _, w := range words {
+		if queryDenied[w] {
+			return "", fmt.Errorf("%s is not allowed", w)
+		}
+	}
+	if end >= 0 {
+		sql = sql[:end]
+	}
+	return strings.TrimSpace(sql), nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func (s *Server) queryLimits() (time.Duration, int) {
+	timeout, maxRows := s.opts.QueryTimeout, s.opts.QueryMaxRows
+	if timeout <= 0 {
+		timeout = defaultQueryTimeout
+	}
+	if maxRows <= 0 {
+		maxRows = defaultQueryMaxRows
+	}
+	return timeout, maxRows
+}
+
+// handleQuery runs ad-hoc analytic SQL against the archive through the
+// configured QueryEngine. The SQL comes from the sql query parameter or a
+// JSON body {"sql": "...", "max_rows": N}; max_rows may only lower the
+// server's row limit.
+func (s *Server) handleQuery(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SQL     string `json:"sql"`
+		MaxRows int    `json:"max_rows"`
+	}
+	switch r.Method {
+	case http.MethodGet:
+		req.SQL = r.URL.Query().Get("sql")
+		if raw := r.URL.Query().Get("max_rows"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "invalid max_rows")
+				return
+			}
+			req.MaxRows = n
+		}
+	case http.MethodPost:
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxQueryBodyBytes)).Decode(&req); err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "invalid query body")
+			return
+		}
+	default:
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	if req.MaxRows < 0 {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "invalid max_rows")
+		return
+	}
+	sql, err := checkReadOnlySQL(req.SQL)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	timeout, maxRows := s.queryLimits()
+	if req.MaxRows > 0 && req.MaxRows < maxRows {
+		maxRows = req.MaxRows
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	result, err := s.opts.QueryEngine.Query(ctx, sql, maxRows+1)
+	if err != nil {
+		var qerr *QueryError
+		switch {
+		case errors.As(err, &qerr):
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, qerr.Message)
+		case ctx.Err() != nil:
+			writeError(w, r, http.StatusServiceUnavailable, ErrCodeUnavailable, "query timed out")
+		default:
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "query error")
+		}
+		return
+	}
+	if len(result.Rows) > maxRows {
+		result.Rows = result.Rows[:maxRows]
+		result.Truncated = true
+	}
+	if result.Columns == nil {
+		result.Columns = []string{}
+	}
+	if result.Rows == nil {
+		result.Rows = [][]any{}
+	}
+	result.RowCount = len(result.Rows)
+	writeJSON(w, result)
+}
@@ -0,0 +1,139 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubQueryEngine struct {
+	sql   string
+	limit int
+	err   error
+	rows  int
+	delay time.Duration
+}
+
+func (e *stubQueryEngine) Query(ctx context.Context, sql string, limit int) (QueryResult, error) {
+	e.sql, e.limit = sql, limit
+	if e.delay > 0 {
+		select {
+		case <-ctx.Done():
+			return QueryResult{}, ctx.Err()
+		case <-time.After(e.delay):
+		}
+	}
+	if e.err != nil {
+		return QueryResult{}, e.err
+	}
+	result := QueryResult{Columns: []string{"n"}}
+	for i := 0; i < e.rows && i < limit; i++ {
+		result.Rows = append(result.Rows, []any{i})
+	}
+	return result, nil
+}
+
+func TestCheckReadOnlySQL(t *testing.T) {
+	for _, tc := range []struct {
+		sql  string
+		want string
+		ok   bool
+	}{
+		{"SELECT platform, count(*) FROM messages GROUP BY 1", "SELECT platform, count(*) FROM messages GROUP BY 1", true},
+		{"  with t AS (SELECT 1) SELECT * FROM t; -- done", "with t AS (SELECT 1) SELECT * FROM t", true},
+		{"SELECT 'drop; delete' AS \"set\" /* insert */", "SELECT 'drop; delete' AS \"set\" /* insert */", true},
+		{"SELECT 'it''s'", "SELECT 'it''s'", true},
+		{"", "", false},
+		{"-- only a comment", "", false},
+		{"DELETE FROM messages", "", false},
+		{"SELECT 1; DROP TABLE messages", "", false},
+		{"SELECT 1; SELECT 2", "", false},
+		{"WITH x AS (DELETE FROM messages RETURNING *) SELECT * FROM x", "", false},
+		{"SELECT * FROM messages; PRAGMA foo", "", false},
+		{"select 1; set threads = 1", "", false},
+		{"SELECT 'unterminated", "", false},
+		{"SELECT 1 /* open", "", false},
+		{"COPY messages TO 'out.csv'", "", false},
+	} {
+		got, err := checkReadOnlySQL(tc.sql)
+		if tc.ok != (err == nil) || got != tc.want {
+			t.Errorf("checkReadOnlySQL(%q) = %q, %v", tc.sql, got, err)
+		}
+	}
+}
+
+func TestQueryEndpoint(t *testing.T) {
+	engine := &stubQueryEngine{rows: 10}
+	srv := New(&stubStore{}, Options{
+		QueryEngine:  engine,
+		QueryMaxRows: 5,
+		QueryTimeout: 50 * time.Millisecond,
+		APIKeys: []APIKey{
+			{Name: "ops", Key: "ops-key"},
+			{Name: "alice", Key: "alice-key", Channels: []string{"alice"}},
+		},
+	})
+	do := func(method, target, body, key string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/query?sql="+url.QueryEscape("SELECT n FROM t;"), "", "ops-key")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var result QueryResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if engine.sql != "SELECT n FROM t" || engine.limit != 6 {
+		t.Fatalf("engine got sql=%q limit=%d", engine.sql, engine.limit)
+	}
+	if result.RowCount != 5 || len(result.Rows) != 5 || !result.Truncated || result.Columns[0] != "n" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	rec = do(http.MethodPost, "/query", `{"sql":"SELECT n FROM t","max_rows":2}`, "ops-key")
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("post: %d %s", rec.Code, rec.Body.String())
+	}
+	if engine.limit != 3 || result.RowCount != 2 || !result.Truncated {
+		t.Fatalf("max_rows not applied: limit=%d %+v", engine.limit, result)
+	}
+
+	if rec := do(http.MethodPost, "/query", `{"sql":"DROP TABLE t"}`, "ops-key"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("write query status = %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/query?sql=SELECT+1", "", "alice-key"); rec.Code != http.StatusForbidden {
+		t.Fatalf("scoped key status = %d", rec.Code)
+	}
+
+	engine.err = &QueryError{Message: "Binder Error: column x not found"}
+	rec = do(http.MethodGet, "/query?sql=SELECT+x", "", "ops-key")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "column x not found") {
+		t.Fatalf("query error: %d %s", rec.Code, rec.Body.String())
+	}
+
+	engine.err, engine.delay = nil, time.Second
+	if rec := do(http.MethodGet, "/query?sql=SELECT+1", "", "ops-key"); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("timeout status = %d", rec.Code)
+	}
+}
+
+func TestQueryEndpointDisabled(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/query?sql=SELECT+1", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,55 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Raid is a recorded raid into ("in") or out of ("out") a watched channel.
+type Raid struct {
+	ID          int64     `json:"id"`
+	Platform    string    `json:"platform"`
+	Channel     string    `json:"channel"`
+	Direction   string    `json:"direction,omitempty"`
+	FromChannel string    `json:"from_channel"`
+	FromName    string    `json:"from_name,omitempty"`
+	ToChannel   string    `json:"to_channel"`
+	ToName      string    `json:"to_name,omitempty"`
+	Viewers     int       `json:"viewers"`
+	Text        string    `json:"text,omitempty"`
+	SessionID   string    `json:"session_id,omitempty"`
+	Ts          time.Time `json:"ts"`
+}
+
+// RaidStore is implemented by stores that record raids. Filters select
+// platforms and sessions and bound the raid time with since/until.
+type RaidStore interface {
+	ListRaids(ctx context.Context, filters Filters) ([]Raid, error)
+}
+
+func (s *Server) handleRaids(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(RaidStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "raids unavailable")
+		return
+	}
+	filters, err := ParseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	raids, err := store.ListRaids(r.Context(), filters)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list raids error")
+		return
+	}
+	if raids == nil {
+		raids = []Raid{}
+	}
+	writeJSON(w, raids)
+}
@@ -0,0 +1,102 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// Redemption is a viewer redeeming a channel points reward.
+type Redemption struct {
+	Platform    string `json:"platform"`
+	Channel     string `json:"channel,omitempty"`
+	ID          string `json:"id"`
+	RewardID    string `json:"reward_id"`
+	RewardTitle string `json:"reward_title"`
+	Cost        int    `json:"cost"`
+	UserID      string `json:"user_id,omitempty"`
+	Username    string `json:"username"`
+	UserInput   string `json:"user_input,omitempty"`
+	// Status is "unfulfilled", "fulfilled" or "canceled" as reported when
+	// the reward was redeemed.
+	Status     string    `json:"status,omitempty"`
+	SessionID  string    `json:"session_id,omitempty"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// RedemptionStore is implemented by stores that record channel points
+// redemptions. Filters select platforms, channels and sessions and bound the
+// redemption time with since/until; rewards, when non-empty, selects rewards
+// by id or title.
+type RedemptionStore interface {
+	ListRedemptions(ctx context.Context, filters Filters, rewards []string) ([]Redemption, error)
+}
+
+func (s *Server) handleRedemptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(RedemptionStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "redemptions unavailable")
+		return
+	}
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
+		return
+	}
+	var rewards []string
+	for _, raw := range r.URL.Query()["reward"] {
+		for _, part := range strings.Split(raw, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				rewards = append(rewards, part)
+			}
+		}
+	}
+	list, err := store.ListRedemptions(r.Context(), filters, rewards)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list redemptions error")
+		return
+	}
+	if list == nil {
+		list = []Redemption{}
+	}
+	writeJSON(w, list)
+}
+
+// ReportRedemption shows a channel points redemption on /alerts. Its
+// message carries the viewer and their input so the usual filters apply.
+func (s *Server) ReportRedemption(r core.Redemption) {
+	s.PublishAlert(Alert{
+		Type: AlertRedemption,
+		Message: core.ChatMessage{
+			ID:       r.ID,
+			Platform: r.Platform,
+			Channel:  r.Channel,
+			UserID:   r.UserID,
+			Username: r.Username,
+			Text:     r.UserInput,
+			Ts:       r.RedeemedAt,
+			RewardID: r.RewardID,
+		},
+		Redemption: &Redemption{
+			Platform:    r.Platform,
+			Channel:     r.Channel,
+			ID:          r.ID,
+			RewardID:    r.RewardID,
+			RewardTitle: r.RewardTitle,
+			Cost:        r.Cost,
+			UserID:      r.UserID,
+			Username:    r.Username,
+			UserInput:   r.UserInput,
+			Status:      r.Status,
+			SessionID:   r.SessionID,
+			RedeemedAt:  r.RedeemedAt.UTC(),
+		},
+		Ts: r.RedeemedAt.UTC(),
+	})
+}
@@ -0,0 +1,32 @@
+package httpapi
+
+import (
+	"testing"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestReportRedemptionAlerts(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+	client := newStreamClient(Filters{Channels: []string{"elora"}}, "alerts")
+	client.alerts = make(chan Alert, 4)
+	client.alertTypes = map[string]bool{AlertRedemption: true}
+	if !srv.addClient(client) {
+		t.Fatal("add client")
+	}
+	defer srv.removeClient(client)
+
+	srv.ReportRedemption(core.Redemption{Platform: "Twitch", Channel: "other", ID: "r0", RewardTitle: "Hydrate"})
+	srv.ReportRedemption(core.Redemption{Platform: "Twitch", Channel: "elora", ID: "r1", RewardTitle: "Song request", Username: "amy", UserInput: "play it"})
+	select {
+	case a := <-client.alerts:
+		if a.Type != AlertRedemption || a.Redemption == nil || a.Redemption.ID != "r1" || a.Message.Text != "play it" {
+			t.Fatalf("unexpected alert %+v", a)
+		}
+	default:
+		t.Fatal("expected a redemption alert")
+	}
+	if len(client.alerts) != 0 {
+		t.Fatalf("expected the other channel's redemption to be filtered out")
+	}
+}
@@ -0,0 +1,113 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// lastEventID returns the resume point sent by a reconnecting stream client:
+// the Last-Event-ID header (set by EventSource) or, for WebSocket clients
+// that cannot set headers from a browser, the last_event_id parameter.
+func lastEventID(r *http.Request) string {
+	if id := strings.TrimSpace(r.Header.Get("Last-Event-ID")); id != "" {
+		return id
+	}
+	return strings.TrimSpace(r.URL.Query().Get("last_event_id"))
+}
+
+// parseEventID splits an ID produced by core.ChatMessage.EventID.
+func parseEventID(raw string) (time.Time, string, bool) {
+	ms, id, ok := strings.Cut(raw, ":")
+	if !ok {
+		return time.Time{}, "", false
+	}
+	n, err := strconv.ParseInt(ms, 10, 64)
+	if err != nil || n < 0 {
+		return time.Time{}, "", false
+	}
+	return time.UnixMilli(n).UTC(), id, true
+}
+
+// parseBacklog reads the backlog parameter: how many recent matching
+// messages a new stream client wants before live delivery. Values above
+// maxLimit are capped.
+func parseBacklog(r *http.Request) (int, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get("backlog"))
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 0 {
+		return 0, errors.New("backlog must be a non-negative integer")
+	}
+	if n > maxLimit {
+		n = maxLimit
+	}
+	return n, nil
+}
+
+// resume replays stored messages that match filters before live delivery:
+// up to maxLimit messages sent after the client's last event or, for a
+// client without one, the latest backlog messages in chronological order.
+// It returns the replayed IDs so the live loop can skip messages that were
+// also queued by Broadcast.
+func (s *Server) resume(ctx context.Context, r *http.Request, filters Filters, backlog int, send func(context.Context, core.ChatMessage) error) (map[string]struct{}, error) {
+	if s.store == nil {
+		return nil, nil
+	}
+	var rows []core.ChatMessage
+	if raw := lastEventID(r); raw != "" {
+		since, lastID, ok := parseEventID(raw)
+		if !ok {
+			return nil, nil
+		}
+		filters.Since = &since
+		filters.Limit = maxLimit
+		filters.Order = OrderAsc
+		var err error
+		rows, err = s.store.ListMessages(ctx, filters)
+		if err != nil {
+			log.Printf("httpapi: resume request_id=%s: %v", RequestIDFromContext(ctx), err)
+			return nil, nil
+		}
+		// Rows share the resume timestamp with the last event; skip through
+		// it when present, otherwise resend them rather than risk a gap.
+		for i, msg := range rows {
+			if msg.ID == lastID && msg.Ts.UnixMilli() == since.UnixMilli() {
+				rows = rows[i+1:]
+				break
+			}
+		}
+	} else if backlog > 0 {
+		filters.Limit = backlog
+		filters.Order = OrderDesc
+		var err error
+		rows, err = s.store.ListMessages(ctx, filters)
+		if err != nil {
+			log.Printf("httpapi: backlog request_id=%s: %v", RequestIDFromContext(ctx), err)
+			return nil, nil
+		}
+		slices.Reverse(rows)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	replayed := make(map[string]struct{}, len(rows))
+	for _, msg := range rows {
+		if err := send(ctx, msg); err != nil {
+			return nil, err
+		}
+		if msg.ID != "" {
+			replayed[msg.ID] = struct{}{}
+		}
+	}
+	return replayed, nil
+}
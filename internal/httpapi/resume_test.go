@@ -0,0 +1,74 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+type recordingStore struct {
+	stubStore
+	filters Filters
+}
+
+func (s *recordingStore) ListMessages(ctx context.Context, filters Filters) ([]core.ChatMessage, error) {
+	s.filters = filters
+	return append([]core.ChatMessage(nil), s.messages...), nil
+}
+
+func TestResumeBacklog(t *testing.T) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := &recordingStore{stubStore: stubStore{messages: []core.ChatMessage{
+		{ID: "c", Ts: base.Add(2 * time.Second)},
+		{ID: "b", Ts: base.Add(time.Second)},
+	}}}
+	srv := New(store, Options{})
+
+	var sent []string
+	send := func(_ context.Context, msg core.ChatMessage) error {
+		sent = append(sent, msg.ID)
+		return nil
+	}
+	req := httptest.NewRequest(http.MethodGet, "/stream?backlog=2", nil)
+	backlog, err := parseBacklog(req)
+	if err != nil || backlog != 2 {
+		t.Fatalf("parseBacklog() = %d, %v", backlog, err)
+	}
+	replayed, err := srv.resume(context.Background(), req, Filters{Platforms: []string{"Twitch"}}, backlog, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 || sent[0] != "b" || sent[1] != "c" {
+		t.Fatalf("backlog sent %v, want oldest first", sent)
+	}
+	if _, ok := replayed["c"]; !ok {
+		t.Fatalf("replayed IDs missing: %v", replayed)
+	}
+	if store.filters.Limit != 2 || store.filters.Order != OrderDesc || len(store.filters.Platforms) != 1 {
+		t.Fatalf("unexpected backlog query %+v", store.filters)
+	}
+
+	// A resume point takes precedence over the backlog.
+	sent = nil
+	req = httptest.NewRequest(http.MethodGet, "/stream?backlog=2", nil)
+	req.Header.Set("Last-Event-ID", core.ChatMessage{ID: "a", Ts: base}.EventID())
+	if _, err := srv.resume(context.Background(), req, Filters{}, backlog, send); err != nil {
+		t.Fatal(err)
+	}
+	if store.filters.Order != OrderAsc || store.filters.Since == nil {
+		t.Fatalf("expected a resume query, got %+v", store.filters)
+	}
+
+	for _, raw := range []string{"-1", "abc"} {
+		if _, err := parseBacklog(httptest.NewRequest(http.MethodGet, "/ws?backlog="+raw, nil)); err == nil {
+			t.Fatalf("parseBacklog(%q) accepted", raw)
+		}
+	}
+	if n, _ := parseBacklog(httptest.NewRequest(http.MethodGet, "/ws?backlog=5000", nil)); n != maxLimit {
+		t.Fatalf("backlog not capped: %d", n)
+	}
+}
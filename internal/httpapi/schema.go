@@ -0,0 +1,65 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+)
+
+// SchemaInfo describes the database layout so tools that share the
+// database file can adapt to schema changes.
+type SchemaInfo struct {
+	// Version is bumped when tables or columns are added, removed or change
+	// meaning.
+	Version int `json:"version"`
+	// Fingerprint changes with any difference in the live table and index
+	// definitions, including columns added by migrations.
+	Fingerprint string        `json:"fingerprint"`
+	Tables      []SchemaTable `json:"tables"`
+}
+
+// SchemaTable is one table and its columns.
+type SchemaTable struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Columns     []SchemaColumn `json:"columns"`
+	Indexes     []string       `json:"indexes,omitempty"`
+	// SQL is the table's CREATE statement as stored by the database.
+	SQL string `json:"sql"`
+}
+
+// SchemaColumn is one column of a table.
+type SchemaColumn struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	NotNull     bool   `json:"not_null"`
+	Default     string `json:"default,omitempty"`
+	PrimaryKey  bool   `json:"primary_key,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// SchemaStore is implemented by stores that can describe their schema.
+type SchemaStore interface {
+	Schema(ctx context.Context) (SchemaInfo, error)
+}
+
+// handleSchema reports the schema version, tables and column meanings.
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(SchemaStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "schema unavailable")
+		return
+	}
+	info, err := store.Schema(r.Context())
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "schema error")
+		return
+	}
+	if info.Tables == nil {
+		info.Tables = []SchemaTable{}
+	}
+	writeJSON(w, info)
+}
@@ -0,0 +1,49 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type schemaStubStore struct {
+	stubStore
+	info SchemaInfo
+}
+
+func (s *schemaStubStore) Schema(ctx context.Context) (SchemaInfo, error) {
+	return s.info, nil
+}
+
+func TestSchemaEndpoint(t *testing.T) {
+	store := &schemaStubStore{info: SchemaInfo{Version: 3, Fingerprint: "abc", Tables: []SchemaTable{{
+		Name:    "messages",
+		Columns: []SchemaColumn{{Name: "ts", Type: "INTEGER", NotNull: true, Description: "Platform timestamp"}},
+	}}}}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/schema", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var got SchemaInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || got.Version != 3 || len(got.Tables) != 1 ||
+		got.Tables[0].Columns[0].Description != "Platform timestamp" {
+		t.Fatalf("unexpected body %s (%v)", rec.Body.String(), err)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/schema", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	New(&stubStore{}, Options{}).Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/schema", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a schema store, got %d", rec.Code)
+	}
+}
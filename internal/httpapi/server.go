@@ -2,18 +2,19 @@ package httpapi
 
 import (
 	"context"
+	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"net/http/pprof"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/you/gnasty-chat/internal/core"
 	"nhooyr.io/websocket"
-	"nhooyr.io/websocket/wsjson"
 )
 
 type Store interface {
@@ -22,50 +23,156 @@ type Store interface {
 }
 
 type Options struct {
-	Addr            string
-	CORSOrigins     []string
-	RateLimitRPS    int
-	RateLimitBurst  int
+	Addr           string
+	CORSOrigins    []string
+	RateLimitRPS   int
+	RateLimitBurst int
+	// StreamRateLimitRPS and StreamRateLimitBurst limit /stream and /ws
+	// connection attempts separately from queries; zero uses the query
+	// limits.
+	StreamRateLimitRPS   int
+	StreamRateLimitBurst int
+	// TrustedProxies lists CIDRs whose X-Forwarded-For header is honoured
+	// when resolving the client address. Empty ignores the header.
+	TrustedProxies []string
+	// RateLimitAllowlist lists CIDRs that are never rate limited.
+	RateLimitAllowlist []string
+	// TLSCertFile and TLSKeyFile serve HTTPS (with HTTP/2) instead of plain
+	// HTTP. The files are re-read when they change on disk.
+	TLSCertFile     string
+	TLSKeyFile      string
 	EnableMetrics   bool
 	EnableAccessLog bool
+	AccessLog       AccessLogOptions
 	EnablePprof     bool
-	Build           BuildInfo
-	ConfigSnapshot  map[string]any
+	EnableUI        bool
+	// TLSAutocertDomains serves HTTPS with certificates obtained from Let's
+	// Encrypt for these host names instead of TLSCertFile, cached in
+	// TLSAutocertCacheDir. The listener must be reachable on port 443 for
+	// the challenges.
+	TLSAutocertDomains  []string
+	TLSAutocertCacheDir string
+	// TLSAutocertEmail is the optional ACME account contact.
+	TLSAutocertEmail string
+	// APIKeys, when non-empty, are required on every route except /healthz
+	// and the /ui/ assets.
+	APIKeys []APIKey
+	// EmitToken is a bearer token accepted by POST /emit in addition to
+	// API keys with Emit set.
+	EmitToken string
+	// MaskWordsFile is the word list applied to message text for requests
+	// with masked=true; empty leaves masked requests unchanged.
+	MaskWordsFile string
+	// WSCompression negotiates permessage-deflate on /ws with clients
+	// that offer it.
+	WSCompression bool
+	// QueryEngine serves ad-hoc SELECT queries on /query; nil leaves the
+	// route unregistered. QueryTimeout and QueryMaxRows bound each query
+	// (zero uses 10s and 1000 rows).
+	QueryEngine    QueryEngine
+	QueryTimeout   time.Duration
+	QueryMaxRows   int
+	Build          BuildInfo
+	ConfigSnapshot map[string]any
+	// ShutdownDrain is how long stream clients may keep receiving already
+	// queued messages after Shutdown before they are sent a close frame.
+	ShutdownDrain time.Duration
 }
 
 type streamClient struct {
-	ch        chan core.ChatMessage
-	filters   Filters
-	transport string
+	ch chan core.ChatMessage
+	// status receives StatusEvents separately from chat so they are not
+	// dropped behind a full message queue.
+	status chan StatusEvent
+	// alerts is set for /alerts clients, which receive only Alerts of
+	// alertTypes and never chat or status events.
+	alerts     chan Alert
+	alertTypes map[string]bool
+	filters    Filters
+	match      *matcher
+	throttle   *streamThrottle
+	transport  string
+	// shard is the hub shard the client is registered in.
+	shard int
+	// shutdown is closed when the server starts shutting down.
+	shutdown chan struct{}
 }
 
+// wsStatusFrame wraps a StatusEvent on /ws so clients can tell it apart
+// from chat messages.
+type wsStatusFrame struct {
+	Event string      `json:"event"`
+	Data  StatusEvent `json:"data"`
+}
+
+// shutdownReason is sent to stream clients disconnected by Shutdown.
+const shutdownReason = "server restarting"
+
 type Server struct {
 	httpServer *http.Server
 	store      Store
 	opts       Options
+	started    time.Time
 
 	mux *http.ServeMux
 
-	mu      sync.Mutex
-	clients map[*streamClient]struct{}
-	closed  bool
+	hub *hub
+
+	mu        sync.Mutex
+	receivers map[string]ReceiverStatus
 
-	rateLimiter *ipRateLimiter
-	cors        *corsPolicy
-	metrics     *Metrics
+	rateLimiter   *ipRateLimiter
+	streamLimiter *ipRateLimiter
+	trusted       prefixList
+	allowlist     prefixList
+	cors          *corsPolicy
+	metrics       *Metrics
+	accessLog     *accessLogger
+	masker        *wordMasker
+
+	emitMu  sync.RWMutex
+	emitter Emitter
+	emitted *recentIDs
 }
 
 func New(store Store, opts Options) *Server {
 	srv := &Server{
 		store:       store,
 		opts:        opts,
-		clients:     make(map[*streamClient]struct{}),
+		started:     time.Now(),
+		hub:         newHub(),
 		rateLimiter: newIPRateLimiter(opts.RateLimitRPS, opts.RateLimitBurst),
 		cors:        newCORSPolicy(opts.CORSOrigins),
+		emitted:     newRecentIDs(emitRecentIDs),
+	}
+	streamRPS, streamBurst := opts.StreamRateLimitRPS, opts.StreamRateLimitBurst
+	if streamRPS <= 0 || streamBurst <= 0 {
+		streamRPS, streamBurst = opts.RateLimitRPS, opts.RateLimitBurst
+	}
+	srv.streamLimiter = newIPRateLimiter(streamRPS, streamBurst)
+	var err error
+	if srv.trusted, err = parsePrefixList(opts.TrustedProxies); err != nil {
+		log.Printf("httpapi: trusted proxies: %v; ignoring X-Forwarded-For", err)
+	}
+	if srv.allowlist, err = parsePrefixList(opts.RateLimitAllowlist); err != nil {
+		log.Printf("httpapi: rate limit allowlist: %v; allowlist disabled", err)
 	}
 	if opts.EnableMetrics {
 		srv.metrics = newMetrics()
 	}
+	if opts.EnableAccessLog {
+		accessLog, err := newAccessLogger(opts.AccessLog)
+		if err != nil {
+			log.Printf("httpapi: %v; falling back to application log", err)
+			accessLog = &accessLogger{}
+		}
+		srv.accessLog = accessLog
+	}
+	if opts.MaskWordsFile != "" {
+		if srv.masker, err = loadWordMasker(opts.MaskWordsFile); err != nil {
+			log.Printf("httpapi: %v; masking disabled", err)
+		}
+	}
 
 	srv.mux = http.NewServeMux()
 	srv.registerRoutes()
@@ -83,13 +190,48 @@ func New(store Store, opts Options) *Server {
 }
 
 func (s *Server) registerRoutes() {
-	s.mux.Handle("/healthz", s.wrap("healthz", s.handleHealthz, handlerOptions{}))
+	s.mux.Handle("/healthz", s.wrap("healthz", s.handleHealthz, handlerOptions{public: true}))
 	s.mux.Handle("/configz", s.wrap("configz", s.handleConfigz, handlerOptions{}))
-	s.mux.Handle("/count", s.wrap("count", s.handleCount, handlerOptions{gzip: true}))
-	s.mux.Handle("/messages", s.wrap("messages", s.handleMessages, handlerOptions{gzip: true}))
-	s.mux.Handle("/stream", s.wrap("stream", s.handleStream, handlerOptions{}))
-	s.mux.Handle("/ws", s.wrap("ws", s.handleWS, handlerOptions{}))
+	s.mux.Handle("/count", s.wrap("count", s.handleCount, handlerOptions{gzip: true, scoped: true}))
+	s.mux.Handle("/messages", s.wrap("messages", s.handleMessages, handlerOptions{gzip: true, scoped: true}))
+	s.mux.Handle("/stream", s.wrap("stream", s.handleStream, handlerOptions{stream: true, scoped: true}))
+	s.mux.Handle("/ws", s.wrap("ws", s.handleWS, handlerOptions{stream: true, scoped: true}))
+	s.mux.Handle("/alerts", s.wrap("alerts", s.handleAlerts, handlerOptions{stream: true, scoped: true}))
 	s.mux.Handle("/info", s.wrap("info", s.handleInfo, handlerOptions{}))
+	s.mux.Handle("/status", s.wrap("status", s.handleStatus, handlerOptions{}))
+	s.mux.Handle("/streams", s.wrap("streams", s.handleStreams, handlerOptions{gzip: true}))
+	s.mux.Handle("/sessions", s.wrap("sessions", s.handleSessions, handlerOptions{gzip: true}))
+	s.mux.Handle("/sessions/", s.wrap("sessions", s.handleSessions, handlerOptions{gzip: true}))
+	s.mux.Handle("/moments", s.wrap("moments", s.handleMoments, handlerOptions{gzip: true}))
+	s.mux.Handle("/markers", s.wrap("markers", s.handleMarkers, handlerOptions{gzip: true}))
+	s.mux.Handle("/polls", s.wrap("polls", s.handlePolls, handlerOptions{gzip: true}))
+	s.mux.Handle("/raids", s.wrap("raids", s.handleRaids, handlerOptions{gzip: true}))
+	s.mux.Handle("/presence", s.wrap("presence", s.handlePresence, handlerOptions{gzip: true, scoped: true}))
+	s.mux.Handle("/stats", s.wrap("stats", s.handleStats, handlerOptions{gzip: true, scoped: true}))
+	s.mux.Handle("/links", s.wrap("links", s.handleLinks, handlerOptions{gzip: true, scoped: true}))
+	s.mux.Handle("/automod", s.wrap("automod", s.handleAutoMod, handlerOptions{gzip: true, scoped: true}))
+	s.mux.Handle("/redemptions", s.wrap("redemptions", s.handleRedemptions, handlerOptions{gzip: true, scoped: true}))
+	s.mux.Handle("/moderation", s.wrap("moderation", s.handleModeration, handlerOptions{gzip: true, scoped: true}))
+	s.mux.Handle("/tts/queue", s.wrap("tts_queue", s.handleTTSQueue, handlerOptions{gzip: true, scoped: true}))
+	s.mux.Handle("/tts/ack", s.wrap("tts_ack", s.handleTTSAck, handlerOptions{scoped: true}))
+	s.mux.Handle("/analytics/viewers", s.wrap("analytics_viewers", s.handleViewerAnalytics, handlerOptions{gzip: true}))
+	s.mux.Handle("/users", s.wrap("users", s.handleUsers, handlerOptions{gzip: true}))
+	s.mux.Handle("/users/", s.wrap("users", s.handleUsers, handlerOptions{gzip: true}))
+	s.mux.Handle("/schema", s.wrap("schema", s.handleSchema, handlerOptions{gzip: true}))
+	// /emit and /emit/batch authenticate themselves so the emit token works
+	// alongside API keys.
+	s.mux.Handle("/emit", s.wrap("emit", s.handleEmit, handlerOptions{public: true}))
+	s.mux.Handle("/emit/batch", s.wrap("emit_batch", s.handleEmitBatch, handlerOptions{gzip: true, public: true}))
+	if s.opts.QueryEngine != nil {
+		s.mux.Handle("/query", s.wrap("query", s.handleQuery, handlerOptions{gzip: true}))
+	}
+	if s.opts.EnableUI {
+		ui := s.uiHandler()
+		s.mux.Handle("/ui", s.wrap("ui", ui, handlerOptions{public: true}))
+		s.mux.Handle("/ui/", s.wrap("ui", ui, handlerOptions{public: true}))
+		s.mux.Handle("/ui/config", s.wrap("ui_config", s.handleOverlayConfig, handlerOptions{scoped: true}))
+		s.mux.Handle("/ui/config/", s.wrap("ui_config", s.handleOverlayConfig, handlerOptions{scoped: true}))
+	}
 	if s.metrics != nil {
 		s.mux.Handle("/metrics", s.wrap("metrics", s.handleMetrics, handlerOptions{}))
 	}
@@ -108,10 +250,19 @@ func (s *Server) Mux() *http.ServeMux {
 
 type handlerOptions struct {
 	gzip bool
+	// stream routes are rate limited by the stream limiter.
+	stream bool
+	// public routes need no API key.
+	public bool
+	// scoped routes apply an API key's platform and channel scope, so
+	// scoped keys may call them.
+	scoped bool
 }
 
 func (s *Server) wrap(route string, fn http.HandlerFunc, opts handlerOptions) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r, requestID := withRequestID(r)
+		w.Header().Set(RequestIDHeader, requestID)
 		rec := newResponseRecorder(w)
 		start := time.Now()
 		var gz *gzipResponseWriter
@@ -122,14 +273,14 @@ func (s *Server) wrap(route string, fn http.HandlerFunc, opts handlerOptions) ht
 				_ = gz.Close()
 			}
 			if panicErr != nil {
-				log.Printf("httpapi: panic recovered: %v", panicErr)
+				log.Printf("httpapi: panic recovered request_id=%s: %v", requestID, panicErr)
 			}
 			status := rec.Status()
 			duration := time.Since(start)
 			if s.metrics != nil {
 				s.metrics.ObserveRequest(route, r.Method, status, duration, rec.Bytes())
 			}
-			if s.opts.EnableAccessLog {
+			if s.accessLog != nil {
 				s.logAccess(r, status, duration, rec.Bytes())
 			}
 		}()
@@ -137,7 +288,7 @@ func (s *Server) wrap(route string, fn http.HandlerFunc, opts handlerOptions) ht
 		defer func() {
 			if err := recover(); err != nil {
 				panicErr = err
-				http.Error(rec, "internal server error", http.StatusInternalServerError)
+				writeError(rec, r, http.StatusInternalServerError, ErrCodeInternal, "internal server error")
 			}
 		}()
 
@@ -150,23 +301,43 @@ func (s *Server) wrap(route string, fn http.HandlerFunc, opts handlerOptions) ht
 
 		if s.cors != nil && r.Method != http.MethodOptions {
 			if !s.cors.applyHeaders(rec, r) {
-				http.Error(rec, "origin not allowed", http.StatusForbidden)
+				writeError(rec, r, http.StatusForbidden, ErrCodeForbidden, "origin not allowed")
 				rec.status = http.StatusForbidden
 				return
 			}
 		}
 
-		if s.rateLimiter != nil {
-			if !s.rateLimiter.Allow(remoteIP(r)) {
+		limiter := s.rateLimiter
+		if opts.stream {
+			limiter = s.streamLimiter
+		}
+		if ip := s.clientIP(r); limiter != nil && !s.allowlist.contains(ip) {
+			if !limiter.Allow(ip) {
 				if s.metrics != nil {
 					s.metrics.IncRateLimited()
 				}
-				http.Error(rec, "rate limit exceeded", http.StatusTooManyRequests)
+				writeError(rec, r, http.StatusTooManyRequests, ErrCodeRateLimited, "rate limit exceeded")
 				rec.status = http.StatusTooManyRequests
 				return
 			}
 		}
 
+		if len(s.opts.APIKeys) > 0 && !opts.public {
+			key, ok := s.authenticate(r)
+			if !ok {
+				rec.Header().Set("WWW-Authenticate", `Bearer realm="gnasty-chat"`)
+				writeError(rec, r, http.StatusUnauthorized, ErrCodeUnauthorized, "api key required")
+				rec.status = http.StatusUnauthorized
+				return
+			}
+			if key.Scoped() && !opts.scoped {
+				writeError(rec, r, http.StatusForbidden, ErrCodeForbidden, "api key not permitted for this route")
+				rec.status = http.StatusForbidden
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), apiKeyContextKey{}, key))
+		}
+
 		if opts.gzip {
 			if gzWriter, ok := maybeGzip(rec, r); ok {
 				gz = gzWriter
@@ -178,11 +349,33 @@ func (s *Server) wrap(route string, fn http.HandlerFunc, opts handlerOptions) ht
 	})
 }
 
+func (s *Server) clientIP(r *http.Request) string {
+	return clientIP(r, s.trusted)
+}
+
 func (s *Server) logAccess(r *http.Request, status int, dur time.Duration, bytes int64) {
-	remote := remoteIP(r)
 	path := r.URL.RequestURI()
-	ua := r.Header.Get("User-Agent")
-	log.Printf("http access remote=%s method=%s path=%s status=%d dur=%s bytes=%d ua=%q", remote, r.Method, path, status, dur, bytes, ua)
+	if r.URL.Query().Has("api_key") {
+		// never log the key itself
+		u := *r.URL
+		q := u.Query()
+		q.Set("api_key", "REDACTED")
+		u.RawQuery = q.Encode()
+		path = u.RequestURI()
+	}
+	key, _ := APIKeyFromContext(r.Context())
+	s.accessLog.Log(accessEntry{
+		Time:      time.Now().UTC(),
+		RequestID: RequestIDFromContext(r.Context()),
+		Remote:    s.clientIP(r),
+		Method:    r.Method,
+		Path:      path,
+		Status:    status,
+		DurMS:     float64(dur) / float64(time.Millisecond),
+		Bytes:     bytes,
+		UserAgent: r.Header.Get("User-Agent"),
+		Key:       key.Name,
+	})
 }
 
 func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
@@ -211,15 +404,20 @@ func (s *Server) handleConfigz(w http.ResponseWriter, _ *http.Request) {
 	_ = json.NewEncoder(w).Encode(payload)
 }
 
+// writeJSON encodes v as a 200 OK JSON response.
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
 func (s *Server) handleCount(w http.ResponseWriter, r *http.Request) {
-	filters, err := FiltersFromRequest(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
 		return
 	}
 	count, err := s.store.CountMessages(r.Context(), filters)
 	if err != nil {
-		http.Error(w, "count error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "count error")
 		return
 	}
 
@@ -228,34 +426,94 @@ func (s *Server) handleCount(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleMessages(w http.ResponseWriter, r *http.Request) {
-	filters, err := FiltersFromRequest(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	format, ok := exportFormat(r)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "format must be json, csv or ndjson")
+		return
+	}
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
+		return
+	}
+
+	if s.applyHistoricalCaching(w, r, filters, format) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	rows, err := s.store.ListMessages(r.Context(), filters)
 	if err != nil {
-		http.Error(w, "list error", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list error")
 		return
 	}
+	rows = s.maskMessages(filters, rows)
 
-	w.Header().Set("Content-Type", "application/json; charset=utf-8")
-	_ = json.NewEncoder(w).Encode(rows)
+	switch format {
+	case "csv":
+		writeMessagesCSV(w, rows)
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		for _, m := range rows {
+			if err := enc.Encode(m); err != nil {
+				return
+			}
+		}
+	default:
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_ = json.NewEncoder(w).Encode(rows)
+	}
+}
+
+// csvFlushRows is how many CSV rows are buffered before they are flushed to
+// the client.
+const csvFlushRows = 200
+
+// writeMessagesCSV streams rows as CSV with a header line, flushing every
+// csvFlushRows rows so large downloads start arriving immediately.
+func writeMessagesCSV(w http.ResponseWriter, rows []core.ChatMessage) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	flusher, _ := w.(http.Flusher)
+	cw := csv.NewWriter(w)
+	_ = cw.Write(messageCSVHeader)
+	for i, m := range rows {
+		if err := cw.Write(messageCSVRecord(m)); err != nil {
+			return
+		}
+		if (i+1)%csvFlushRows == 0 {
+			cw.Flush()
+			if cw.Error() != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	cw.Flush()
 }
 
 func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet && r.Method != http.MethodHead {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
 		return
 	}
 
-	filters, err := FiltersFromRequest(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
 		return
 	}
 	filters = filters.CloneForStream()
+	backlog, err := parseBacklog(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	throttle, err := parseThrottle(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
 
 	w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
@@ -269,18 +527,15 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 
 	flusher, ok := w.(http.Flusher)
 	if !ok {
-		http.Error(w, "stream unsupported", http.StatusInternalServerError)
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "stream unsupported")
 		return
 	}
 
-	client := &streamClient{
-		ch:        make(chan core.ChatMessage, 256),
-		filters:   filters,
-		transport: "sse",
-	}
+	client := newStreamClient(filters, "sse")
+	client.throttle = throttle
 
 	if !s.addClient(client) {
-		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		writeError(w, r, http.StatusServiceUnavailable, ErrCodeUnavailable, "server shutting down")
 		return
 	}
 	defer s.removeClient(client)
@@ -297,65 +552,139 @@ func (s *Server) handleStream(w http.ResponseWriter, r *http.Request) {
 	defer ticker.Stop()
 
 	ctx := r.Context()
+	send := func(_ context.Context, msg core.ChatMessage) error {
+		data, err := json.Marshal(s.maskMessage(filters, msg))
+		if err != nil {
+			return nil
+		}
+		if _, err := fmt.Fprintf(w, "id: %s\nevent: message\ndata: %s\n\n", msg.EventID(), data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		if s.metrics != nil {
+			s.metrics.IncMessagesSent("sse")
+		}
+		return nil
+	}
+	sendStatus := func(ev StatusEvent) error {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			return nil
+		}
+		if _, err := fmt.Fprintf(w, "event: status\ndata: %s\n\n", data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	for _, ev := range s.receiverEvents() {
+		if err := sendStatus(ev); err != nil {
+			return
+		}
+	}
+
+	replayed, err := s.resume(ctx, r, filters, backlog, send)
+	if err != nil {
+		return
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			if _, err := fmt.Fprintf(w, ":ping %d\n\n", time.Now().Unix()); err != nil {
+		case <-client.shutdown:
+			if err := s.drain(ctx, client, send); err != nil {
 				return
 			}
+			_ = sendStatus(StatusEvent{Type: StatusServerShutdown, Detail: shutdownReason, Ts: time.Now().UTC()})
+			fmt.Fprintf(w, "event: shutdown\ndata: {\"reason\":%q}\n\n", shutdownReason)
 			flusher.Flush()
-		case msg, ok := <-client.ch:
-			if !ok {
+			return
+		case ev := <-client.status:
+			if err := sendStatus(ev); err != nil {
 				return
 			}
-			data, err := json.Marshal(msg)
-			if err != nil {
-				continue
-			}
-			if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", data); err != nil {
+		case <-ticker.C:
+			if _, err := fmt.Fprintf(w, ":ping %d\n\n", time.Now().Unix()); err != nil {
 				return
 			}
 			flusher.Flush()
-			if s.metrics != nil {
-				s.metrics.IncMessagesSent("sse")
+		case msg := <-client.ch:
+			if _, dup := replayed[msg.ID]; dup {
+				delete(replayed, msg.ID)
+				continue
+			}
+			if err := send(ctx, msg); err != nil {
+				return
 			}
 		}
 	}
 }
 
 func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
-	filters, err := FiltersFromRequest(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
 		return
 	}
 	filters = filters.CloneForStream()
+	backlog, err := parseBacklog(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	throttle, err := parseThrottle(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	encoding, ok := parseWSEncoding(r.URL.Query().Get("encoding"))
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "encoding must be json or msgpack")
+		return
+	}
+	// Status frames are opt-in: existing clients treat every frame as a
+	// message.
+	var withStatus bool
+	if raw := r.URL.Query().Get("status_events"); raw != "" {
+		if withStatus, err = strconv.ParseBool(raw); err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "status_events must be a boolean")
+			return
+		}
+	}
 
 	if s.isClosed() {
-		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		writeError(w, r, http.StatusServiceUnavailable, ErrCodeUnavailable, "server shutting down")
 		return
 	}
 
-	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	opts := &websocket.AcceptOptions{
+		InsecureSkipVerify: true,
+		Subprotocols:       []string{wsSubprotocolMsgpack, wsSubprotocolJSON},
+	}
+	if s.opts.WSCompression {
+		opts.CompressionMode = websocket.CompressionContextTakeover
+	}
+	conn, err := websocket.Accept(w, r, opts)
 	if err != nil {
-		log.Printf("websocket accept error: %v", err)
+		log.Printf("websocket accept error request_id=%s: %v", RequestIDFromContext(r.Context()), err)
 		return
 	}
 	defer conn.Close(websocket.StatusNormalClosure, "")
+	switch conn.Subprotocol() {
+	case wsSubprotocolMsgpack:
+		encoding = "msgpack"
+	case wsSubprotocolJSON:
+		encoding = "json"
+	}
 
 	ctx := conn.CloseRead(r.Context())
 
-	client := &streamClient{
-		ch:        make(chan core.ChatMessage, 256),
-		filters:   filters,
-		transport: "ws",
-	}
+	client := newStreamClient(filters, "ws")
+	client.throttle = throttle
 
 	if !s.addClient(client) {
-		_ = conn.Close(websocket.StatusPolicyViolation, "server shutting down")
+		_ = conn.Close(websocket.StatusServiceRestart, shutdownReason)
 		return
 	}
 	defer s.removeClient(client)
@@ -368,10 +697,55 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 	ticker := time.NewTicker(30 * time.Second)
 	defer ticker.Stop()
 
+	send := func(ctx context.Context, msg core.ChatMessage) error {
+		writeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := writeWSFrame(writeCtx, conn, encoding, s.maskMessage(filters, msg)); err != nil {
+			return err
+		}
+		if s.metrics != nil {
+			s.metrics.IncMessagesSent("ws")
+		}
+		return nil
+	}
+	var statusCh <-chan StatusEvent
+	sendStatus := func(ctx context.Context, ev StatusEvent) error {
+		if !withStatus {
+			return nil
+		}
+		writeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		return writeWSFrame(writeCtx, conn, encoding, wsStatusFrame{Event: "status", Data: ev})
+	}
+	if withStatus {
+		statusCh = client.status
+		for _, ev := range s.receiverEvents() {
+			if err := sendStatus(ctx, ev); err != nil {
+				return
+			}
+		}
+	}
+
+	replayed, err := s.resume(ctx, r, filters, backlog, send)
+	if err != nil {
+		return
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-client.shutdown:
+			if err := s.drain(ctx, client, send); err != nil {
+				return
+			}
+			_ = sendStatus(ctx, StatusEvent{Type: StatusServerShutdown, Detail: shutdownReason, Ts: time.Now().UTC()})
+			_ = conn.Close(websocket.StatusServiceRestart, shutdownReason)
+			return
+		case ev := <-statusCh:
+			if err := sendStatus(ctx, ev); err != nil {
+				return
+			}
 		case <-ticker.C:
 			pingCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 			if err := conn.Ping(pingCtx); err != nil {
@@ -379,64 +753,94 @@ func (s *Server) handleWS(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			cancel()
-		case msg, ok := <-client.ch:
-			if !ok {
-				_ = conn.Close(websocket.StatusNormalClosure, "server shutting down")
-				return
+		case msg := <-client.ch:
+			if _, dup := replayed[msg.ID]; dup {
+				delete(replayed, msg.ID)
+				continue
 			}
-			writeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-			if err := wsjson.Write(writeCtx, conn, msg); err != nil {
-				cancel()
+			if err := send(ctx, msg); err != nil {
 				return
 			}
-			cancel()
-			if s.metrics != nil {
-				s.metrics.IncMessagesSent("ws")
-			}
 		}
 	}
 }
 
 func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
 	if s.metrics == nil {
-		http.NotFound(w, r)
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "metrics disabled")
 		return
 	}
 	s.metrics.Handler().ServeHTTP(w, r)
 }
 
-func (s *Server) addClient(client *streamClient) bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	if s.closed {
-		return false
+func newStreamClient(filters Filters, transport string) *streamClient {
+	return &streamClient{
+		ch:        make(chan core.ChatMessage, 256),
+		status:    make(chan StatusEvent, 16),
+		filters:   filters,
+		match:     filters.compile(),
+		transport: transport,
+		shutdown:  make(chan struct{}),
 	}
-	s.clients[client] = struct{}{}
-	return true
 }
 
+func (s *Server) addClient(client *streamClient) bool {
+	return s.hub.add(client)
+}
+
+// removeClient must be called exactly once for every client accepted by
+// addClient.
 func (s *Server) removeClient(client *streamClient) {
-	s.mu.Lock()
-	if _, ok := s.clients[client]; ok {
-		delete(s.clients, client)
-		close(client.ch)
+	s.hub.remove(client)
+}
+
+// drain delivers messages already queued for client until the queue is empty
+// or the ShutdownDrain window elapses.
+func (s *Server) drain(ctx context.Context, client *streamClient, send func(context.Context, core.ChatMessage) error) error {
+	if s.opts.ShutdownDrain <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.opts.ShutdownDrain)
+	defer cancel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg := <-client.ch:
+			if err := send(ctx, msg); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
 	}
-	s.mu.Unlock()
 }
 
 func (s *Server) isClosed() bool {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return s.closed
+	return s.hub.isClosed()
 }
 
+// Broadcast queues msg for every stream client whose filters match. It never
+// blocks on a client: a full queue drops the message for that client. Calls
+// from several goroutines fan out concurrently.
 func (s *Server) Broadcast(msg core.ChatMessage) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for client := range s.clients {
-		if !client.filters.Matches(msg) {
-			continue
+	view := newMessageView(&msg)
+	alert, isAlert := messageAlert(msg)
+	s.hub.each(func(client *streamClient) {
+		if client.alerts != nil {
+			if isAlert {
+				s.queueAlert(client, view, alert)
+			}
+			return
+		}
+		if !client.match.match(view) {
+			return
+		}
+		if !client.throttle.admit(&msg) {
+			if s.metrics != nil {
+				s.metrics.IncThrottled(client.transport)
+			}
+			return
 		}
 		select {
 		case client.ch <- msg:
@@ -445,10 +849,22 @@ func (s *Server) Broadcast(msg core.ChatMessage) {
 				s.metrics.IncBroadcastDrops(client.transport)
 			}
 		}
-	}
+	})
 }
 
 func (s *Server) Start() error {
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		s.httpServer.TLSConfig = tlsConfig
+		log.Printf("http api listening on %s (https)", s.httpServer.Addr)
+		if err := s.httpServer.ListenAndServeTLS("", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
 	log.Printf("http api listening on %s", s.httpServer.Addr)
 	if err := s.httpServer.ListenAndServe(); err != nil {
 		if errors.Is(err, http.ErrServerClosed) {
@@ -460,18 +876,34 @@ func (s *Server) Start() error {
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
-	s.mu.Lock()
-	if s.closed {
-		s.mu.Unlock()
+	closing := s.hub.close(func(client *streamClient) {
+		close(client.shutdown)
+		if s.metrics != nil {
+			s.metrics.IncShutdownDisconnects(client.transport)
+		}
+	})
+	if !closing {
 		return nil
 	}
-	s.closed = true
-	for client := range s.clients {
-		close(client.ch)
+
+	// Stream handlers drain and send close frames before the listener is
+	// torn down; hijacked WebSocket connections are not tracked by
+	// http.Server.Shutdown.
+	done := make(chan struct{})
+	go func() {
+		s.hub.wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	err := s.httpServer.Shutdown(ctx)
+	if closeErr := s.accessLog.Close(); closeErr != nil && err == nil {
+		err = closeErr
 	}
-	s.clients = make(map[*streamClient]struct{})
-	s.mu.Unlock()
-	return s.httpServer.Shutdown(ctx)
+	return err
 }
 
 // ReportDBWriteError increments the DB write error metric if enabled.
@@ -481,6 +913,135 @@ func (s *Server) ReportDBWriteError() {
 	}
 }
 
+// ReportWALSize updates the SQLite WAL size gauge if metrics are enabled.
+func (s *Server) ReportWALSize(n int64) {
+	if s.metrics != nil {
+		s.metrics.SetWALBytes(n)
+	}
+}
+
+// ReportMaintenance records a database maintenance pass if metrics are
+// enabled.
+func (s *Server) ReportMaintenance(result string, checkpoint time.Duration) {
+	if s.metrics != nil {
+		s.metrics.ObserveMaintenance(result, checkpoint)
+	}
+}
+
+// ReportPluginCall records an exec plugin round trip if metrics are enabled.
+func (s *Server) ReportPluginCall(result string, latency time.Duration) {
+	if s.metrics != nil {
+		s.metrics.ObservePluginCall(result, latency)
+	}
+}
+
+// ReportIngestLatency records the delay from a message's platform
+// timestamp, and from its receive time when known, to its database write if
+// metrics are enabled.
+func (s *Server) ReportIngestLatency(msg core.ChatMessage, written time.Time) {
+	if s.metrics == nil || msg.Ts.IsZero() {
+		return
+	}
+	s.metrics.ObserveIngestLatency(msg.Platform, written.Sub(msg.Ts))
+	if msg.ReceivedAt != nil {
+		s.metrics.ObserveWriteDelay(msg.Platform, written.Sub(*msg.ReceivedAt))
+	}
+}
+
+// ReportPluginRestart counts an exec plugin restart if metrics are enabled.
+func (s *Server) ReportPluginRestart() {
+	if s.metrics != nil {
+		s.metrics.IncPluginRestarts()
+	}
+}
+
+// ReportReceiverCrash counts a recovered receiver panic if metrics are
+// enabled.
+func (s *Server) ReportReceiverCrash(receiver string) {
+	if s.metrics != nil {
+		s.metrics.IncReceiverCrashes(receiver)
+	}
+}
+
+// ReportIngestOverflow counts a message over a receiver's ingest rate limit
+// if metrics are enabled.
+func (s *Server) ReportIngestOverflow(receiver string, sampled bool) {
+	if s.metrics != nil {
+		s.metrics.IncIngestOverflow(receiver, sampled)
+	}
+}
+
+// ReportSpamFlagged counts a message flagged by the spam detector if
+// metrics are enabled.
+func (s *Server) ReportSpamFlagged(platform string) {
+	if s.metrics != nil {
+		s.metrics.IncSpamFlagged(platform)
+	}
+}
+
+// ReportShadowVerify records a shadow sink verification pass if metrics are
+// enabled.
+func (s *Server) ReportShadowVerify(windows, divergent int) {
+	if s.metrics != nil {
+		s.metrics.SetShadowVerify(windows, divergent)
+	}
+}
+
+// ReportShadowWriteFailure counts a message the shadow sink did not store if
+// metrics are enabled.
+func (s *Server) ReportShadowWriteFailure() {
+	if s.metrics != nil {
+		s.metrics.IncShadowWriteFailures()
+	}
+}
+
+// ReportExternalWrites counts messages other processes wrote to the shared
+// database if metrics are enabled.
+func (s *Server) ReportExternalWrites(n int64) {
+	if s.metrics != nil {
+		s.metrics.AddExternalWrites(n)
+	}
+}
+
+// ReportYouTubePoll counts a YouTube live chat poll if metrics are enabled.
+func (s *Server) ReportYouTubePoll(result string) {
+	if s.metrics != nil {
+		s.metrics.IncYouTubePolls(result)
+	}
+}
+
+// ReportYouTubeBootstrap counts a YouTube watch page bootstrap if metrics
+// are enabled.
+func (s *Server) ReportYouTubeBootstrap(ok bool) {
+	if s.metrics != nil {
+		s.metrics.IncYouTubeBootstraps(ok)
+	}
+}
+
+// ReportYouTubeContinuationReset counts a discarded YouTube continuation if
+// metrics are enabled.
+func (s *Server) ReportYouTubeContinuationReset(reason string) {
+	if s.metrics != nil {
+		s.metrics.IncYouTubeContinuationResets(reason)
+	}
+}
+
+// ReportYouTubeActions counts one YouTube poll's actions if metrics are
+// enabled.
+func (s *Server) ReportYouTubeActions(parsed, dropped, skipped int) {
+	if s.metrics != nil {
+		s.metrics.AddYouTubeActions(parsed, dropped, skipped)
+	}
+}
+
+// ReportYouTubePollInterval records the delay before the next YouTube poll
+// if metrics are enabled.
+func (s *Server) ReportYouTubePollInterval(d time.Duration) {
+	if s.metrics != nil {
+		s.metrics.SetYouTubePollInterval(d)
+	}
+}
+
 // MetricsEnabled reports whether metrics are enabled for this server.
 func (s *Server) MetricsEnabled() bool {
 	return s.metrics != nil
@@ -0,0 +1,74 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Session is a broadcast session that messages are grouped into.
+type Session struct {
+	ID           string     `json:"id"`
+	Platform     string     `json:"platform"`
+	Channel      string     `json:"channel"`
+	VideoID      string     `json:"video_id,omitempty"`
+	Title        string     `json:"title,omitempty"`
+	Category     string     `json:"category,omitempty"`
+	ThumbnailURL string     `json:"thumbnail_url,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	EndedAt      *time.Time `json:"ended_at,omitempty"`
+	Live         bool       `json:"live"`
+	Messages     int64      `json:"messages"`
+}
+
+// SessionStore is implemented by stores that segment messages into broadcast
+// sessions. Filters select platforms and bound started_at with since/until.
+type SessionStore interface {
+	ListSessions(ctx context.Context, filters Filters) ([]Session, error)
+	GetSession(ctx context.Context, id string) (Session, bool, error)
+}
+
+// handleSessions serves GET /sessions and GET /sessions/{id}. Messages for a
+// session are available from /messages?session_id={id}.
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(SessionStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "sessions unavailable")
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/sessions"), "/")
+	if id == "" {
+		filters, err := ParseFilters(r.URL.Query())
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+		sessions, err := store.ListSessions(r.Context(), filters)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list sessions error")
+			return
+		}
+		if sessions == nil {
+			sessions = []Session{}
+		}
+		writeJSON(w, sessions)
+		return
+	}
+
+	session, found, err := store.GetSession(r.Context(), id)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "get session error")
+		return
+	}
+	if !found {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "session not found")
+		return
+	}
+	writeJSON(w, session)
+}
@@ -0,0 +1,70 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+type sessionStubStore struct {
+	stubStore
+	sessions []Session
+}
+
+func (s *sessionStubStore) ListSessions(ctx context.Context, filters Filters) ([]Session, error) {
+	return s.sessions, nil
+}
+
+func (s *sessionStubStore) GetSession(ctx context.Context, id string) (Session, bool, error) {
+	for _, session := range s.sessions {
+		if session.ID == id {
+			return session, true, nil
+		}
+	}
+	return Session{}, false, nil
+}
+
+func TestSessionsEndpoints(t *testing.T) {
+	store := &sessionStubStore{sessions: []Session{{ID: "youtube:abc", Platform: "YouTube", VideoID: "abc", StartedAt: time.Now(), Live: true}}}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sessions", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list []Session
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 {
+		t.Fatalf("unexpected list body %s (%v)", rec.Body.String(), err)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sessions/youtube:abc", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get: expected 200, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/sessions/youtube:nope", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing: expected 404, got %d", rec.Code)
+	}
+}
+
+func TestSessionFilter(t *testing.T) {
+	f, err := ParseFilters(map[string][]string{"session_id": {"twitch:1, youtube:abc"}})
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(f.SessionIDs) != 2 || f.SessionIDs[1] != "youtube:abc" {
+		t.Fatalf("unexpected session ids %v", f.SessionIDs)
+	}
+	if !f.Matches(core.ChatMessage{SessionID: "twitch:1"}) || f.Matches(core.ChatMessage{SessionID: "twitch:2"}) {
+		t.Fatalf("session filter did not match as expected")
+	}
+}
@@ -0,0 +1,104 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+func waitForClients(t *testing.T, srv *Server, n int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		got := srv.hub.len()
+		if got == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d stream clients, have %d", n, got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestShutdownDrainsAndClosesStreams(t *testing.T) {
+	srv := New(&stubStore{}, Options{EnableMetrics: true, ShutdownDrain: time.Second})
+	ts := httptest.NewServer(srv.Mux())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dial ws: %v", err)
+	}
+	defer conn.CloseNow()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"/stream", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open sse: %v", err)
+	}
+	defer resp.Body.Close()
+
+	waitForClients(t, srv, 2)
+	srv.Broadcast(core.ChatMessage{ID: "m1", Platform: "Twitch", Username: "a", Text: "bye"})
+	// The server waits for the close handshake, so shut down concurrently
+	// with reading like a real client would.
+	shutdownErr := make(chan error, 1)
+	go func() { shutdownErr <- srv.Shutdown(ctx) }()
+
+	var msg core.ChatMessage
+	if err := wsjson.Read(ctx, conn, &msg); err != nil || msg.ID != "m1" {
+		t.Fatalf("expected queued message before close, got %+v err=%v", msg, err)
+	}
+	_, _, err = conn.Read(ctx)
+	var closeErr websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.StatusServiceRestart || closeErr.Reason != shutdownReason {
+		t.Fatalf("expected service restart close frame, got %v", err)
+	}
+
+	if err := <-shutdownErr; err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+
+	body := readAll(t, resp)
+	if !strings.Contains(body, `"ID":"m1"`) || !strings.Contains(body, "event: shutdown") {
+		t.Fatalf("expected drained message and shutdown event, got %q", body)
+	}
+	if strings.Index(body, "event: shutdown") < strings.Index(body, "event: message") {
+		t.Fatalf("shutdown event sent before drained message: %q", body)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.metrics.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, want := range []string{
+		`gnasty_shutdown_disconnects_total{transport="sse"} 1`,
+		`gnasty_shutdown_disconnects_total{transport="ws"} 1`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Fatalf("metrics missing %q", want)
+		}
+	}
+}
+
+func readAll(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	var b strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		b.WriteString(scanner.Text())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
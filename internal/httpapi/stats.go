@@ -0,0 +1,163 @@
+package httpapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	// Embed the IANA database so tz= works on hosts and containers
+	// without /usr/share/zoneinfo.
+	_ "time/tzdata"
+)
+
+// ActivitySlot counts a platform's messages in one fixed-size UTC slot.
+type ActivitySlot struct {
+	Start    time.Time
+	Platform string
+	Messages int64
+}
+
+// ActivityStore is implemented by stores that can count messages per slot.
+// Filters select messages as for CountMessages; Since and Until are aligned
+// to slot boundaries.
+type ActivityStore interface {
+	MessageActivity(ctx context.Context, filters Filters, slot time.Duration) ([]ActivitySlot, error)
+}
+
+// activitySlot is the granularity counts are read at. Every UTC offset in
+// use is a multiple of 15 minutes, so local hours and days are made of
+// whole slots.
+const activitySlot = 15 * time.Minute
+
+// maxStatsBuckets bounds the buckets in one /stats response.
+const maxStatsBuckets = 1000
+
+// statsBucket is one local hour or day of /stats.
+type statsBucket struct {
+	// Start is the bucket's local start time, with the zone's offset.
+	Start     time.Time        `json:"start"`
+	Messages  int64            `json:"messages"`
+	Platforms map[string]int64 `json:"platforms,omitempty"`
+}
+
+type statsResponse struct {
+	TZ      string        `json:"tz"`
+	Bucket  string        `json:"bucket"`
+	Total   int64         `json:"total"`
+	Buckets []statsBucket `json:"buckets"`
+}
+
+// statsBuckets returns the local start times of the buckets covering
+// [since, until), plus the end of the last bucket. Days follow the zone's
+// calendar, so they are 23 or 25 hours long across DST changes.
+func statsBuckets(since, until time.Time, bucket string, loc *time.Location) ([]time.Time, time.Time, error) {
+	local := since.In(loc)
+	var (
+		start time.Time
+		next  func(time.Time) time.Time
+	)
+	if bucket == "hour" {
+		start = time.Date(local.Year(), local.Month(), local.Day(), local.Hour(), 0, 0, 0, loc)
+		next = func(t time.Time) time.Time { return t.Add(time.Hour) }
+	} else {
+		start = time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+		next = func(t time.Time) time.Time {
+			return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		}
+	}
+	var starts []time.Time
+	t := start
+	for t.Before(until) {
+		if len(starts) == maxStatsBuckets {
+			return nil, time.Time{}, errors.New("range too large for bucket; narrow since/until or use bucket=day")
+		}
+		starts = append(starts, t)
+		t = next(t)
+	}
+	return starts, t, nil
+}
+
+// handleStats counts messages per local hour or day. tz names the IANA zone
+// buckets are computed in (default UTC); since defaults to 7 days (day
+// buckets) or 48 hours (hour buckets) before until, which defaults to now.
+// Buckets cover whole hours or days, widening since/until as needed, and
+// empty buckets are included.
+func (s *Server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(ActivityStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "stats unavailable")
+		return
+	}
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	bucket := strings.ToLower(strings.TrimSpace(query.Get("bucket")))
+	switch bucket {
+	case "":
+		bucket = "day"
+	case "hour", "day":
+	default:
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "bucket must be hour or day")
+		return
+	}
+	loc := time.UTC
+	if raw := strings.TrimSpace(query.Get("tz")); raw != "" {
+		var err error
+		if loc, err = time.LoadLocation(raw); err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "invalid tz")
+			return
+		}
+	}
+
+	until := time.Now()
+	if filters.Until != nil {
+		until = *filters.Until
+	}
+	since := until.Add(-7 * 24 * time.Hour)
+	if bucket == "hour" {
+		since = until.Add(-48 * time.Hour)
+	}
+	if filters.Since != nil {
+		since = *filters.Since
+	}
+	starts, end, err := statsBuckets(since, until, bucket, loc)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+
+	resp := statsResponse{TZ: loc.String(), Bucket: bucket, Buckets: make([]statsBucket, len(starts))}
+	for i, start := range starts {
+		resp.Buckets[i].Start = start
+	}
+	if len(starts) > 0 {
+		filters.Since, filters.Until = &starts[0], &end
+		slots, err := store.MessageActivity(r.Context(), filters, activitySlot)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "stats error")
+			return
+		}
+		for _, slot := range slots {
+			i := sort.Search(len(starts), func(i int) bool { return starts[i].After(slot.Start) }) - 1
+			if i < 0 || !slot.Start.Before(end) {
+				continue
+			}
+			b := &resp.Buckets[i]
+			b.Messages += slot.Messages
+			if b.Platforms == nil {
+				b.Platforms = make(map[string]int64)
+			}
+			b.Platforms[slot.Platform] += slot.Messages
+			resp.Total += slot.Messages
+		}
+	}
+	writeJSON(w, resp)
+}
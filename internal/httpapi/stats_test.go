@@ -0,0 +1,117 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type activityStubStore struct {
+	stubStore
+	slots   []ActivitySlot
+	filters Filters
+}
+
+func (s *activityStubStore) MessageActivity(ctx context.Context, filters Filters, slot time.Duration) ([]ActivitySlot, error) {
+	s.filters = filters
+	var out []ActivitySlot
+	for _, a := range s.slots {
+		if !a.Start.Before(*filters.Since) && a.Start.Before(*filters.Until) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func getStats(t *testing.T, srv *Server, target string) (statsResponse, int) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	var resp statsResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+	}
+	return resp, rec.Code
+}
+
+func TestStatsDayBucketsInTimezone(t *testing.T) {
+	utc := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	store := &activityStubStore{slots: []ActivitySlot{
+		// 23:45 on Oct 31 in New York (EDT, -4), but Nov 1 in UTC.
+		{Start: utc("2026-11-01T03:45:00Z"), Platform: "Twitch", Messages: 5},
+		// Nov 1 has 25 hours: 23:30 EST is already Nov 2 in UTC.
+		{Start: utc("2026-11-02T04:30:00Z"), Platform: "YouTube", Messages: 2},
+		{Start: utc("2026-11-02T05:15:00Z"), Platform: "Twitch", Messages: 1},
+	}}
+	srv := New(store, Options{})
+
+	resp, code := getStats(t, srv, "/stats?tz=America/New_York&since=2026-10-31T12:00:00Z&until=2026-11-02T12:00:00Z")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	if resp.TZ != "America/New_York" || resp.Bucket != "day" || resp.Total != 8 || len(resp.Buckets) != 3 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	wantStarts := []string{"2026-10-31T00:00:00-04:00", "2026-11-01T00:00:00-04:00", "2026-11-02T00:00:00-05:00"}
+	wantCounts := []int64{5, 2, 1}
+	for i, b := range resp.Buckets {
+		if got := b.Start.Format(time.RFC3339); got != wantStarts[i] || b.Messages != wantCounts[i] {
+			t.Fatalf("bucket %d = %s/%d, want %s/%d", i, got, b.Messages, wantStarts[i], wantCounts[i])
+		}
+	}
+	if resp.Buckets[0].Platforms["Twitch"] != 5 {
+		t.Fatalf("platform counts = %v", resp.Buckets[0].Platforms)
+	}
+	if got := store.filters.Since.UTC().Format(time.RFC3339); got != "2026-10-31T04:00:00Z" {
+		t.Fatalf("store since = %s", got)
+	}
+
+	// The same slots in UTC land on different days.
+	resp, _ = getStats(t, srv, "/stats?since=2026-10-31T12:00:00Z&until=2026-11-02T12:00:00Z")
+	if resp.TZ != "UTC" || len(resp.Buckets) != 3 || resp.Buckets[1].Messages != 5 || resp.Buckets[2].Messages != 3 {
+		t.Fatalf("utc buckets = %+v", resp.Buckets)
+	}
+}
+
+func TestStatsHourBucketsHalfHourOffset(t *testing.T) {
+	start := time.Date(2026, 10, 15, 6, 0, 0, 0, time.UTC)
+	store := &activityStubStore{slots: []ActivitySlot{
+		{Start: start.Add(15 * time.Minute), Platform: "Twitch", Messages: 1}, // 11:45 IST
+		{Start: start.Add(30 * time.Minute), Platform: "Twitch", Messages: 4}, // 12:00 IST
+	}}
+	srv := New(store, Options{})
+	resp, code := getStats(t, srv, "/stats?bucket=hour&tz=Asia/Kolkata&since=2026-10-15T06:00:00Z&until=2026-10-15T07:00:00Z")
+	if code != http.StatusOK || len(resp.Buckets) != 2 {
+		t.Fatalf("status %d buckets %+v", code, resp.Buckets)
+	}
+	if resp.Buckets[0].Start.Format("15:04") != "11:00" || resp.Buckets[0].Messages != 1 || resp.Buckets[1].Messages != 4 {
+		t.Fatalf("hour buckets = %+v", resp.Buckets)
+	}
+}
+
+func TestStatsRejectsBadParams(t *testing.T) {
+	srv := New(&activityStubStore{}, Options{})
+	for _, target := range []string{
+		"/stats?tz=Mars/Olympus",
+		"/stats?bucket=week",
+		"/stats?bucket=hour&since=2020-01-01T00:00:00Z&until=2026-01-01T00:00:00Z",
+	} {
+		if _, code := getStats(t, srv, target); code != http.StatusBadRequest {
+			t.Fatalf("%s: status = %d, want 400", target, code)
+		}
+	}
+	if _, code := getStats(t, New(&stubStore{}, Options{}), "/stats"); code != http.StatusNotFound {
+		t.Fatalf("store without activity: status = %d, want 404", code)
+	}
+}
@@ -0,0 +1,138 @@
+package httpapi
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ReceiverStatus is the health of one ingest receiver.
+type ReceiverStatus struct {
+	Name    string `json:"name"`
+	Healthy bool   `json:"healthy"`
+	// Error is the fatal error that stopped an unhealthy receiver.
+	Error string    `json:"error,omitempty"`
+	Since time.Time `json:"since"`
+}
+
+// Status event types pushed to stream clients.
+const (
+	StatusReceiverUp     = "receiver_up"
+	StatusReceiverDown   = "receiver_down"
+	StatusSessionStarted = "session_started"
+	StatusSessionEnded   = "session_ended"
+	StatusServerShutdown = "server_restarting"
+)
+
+// StatusEvent is a change in the harvester's state delivered on /stream (as
+// "event: status") and, for clients that ask for it, /ws. Clients use it to
+// show "chat source offline" states.
+type StatusEvent struct {
+	Type string `json:"type"`
+	// Receiver names the ingest receiver for receiver_* events.
+	Receiver string `json:"receiver,omitempty"`
+	// Platform, Channel and SessionID describe session_* events.
+	Platform  string `json:"platform,omitempty"`
+	Channel   string `json:"channel,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	// Detail explains a receiver_down event or a shutdown.
+	Detail string    `json:"detail,omitempty"`
+	Ts     time.Time `json:"ts"`
+}
+
+type statusResponse struct {
+	// Status is "ok" while every receiver is healthy and "degraded"
+	// otherwise.
+	Status    string           `json:"status"`
+	Receivers []ReceiverStatus `json:"receivers"`
+}
+
+// ReportReceiverHealth records whether receiver is running, for /status and
+// the gnasty_receiver_up gauge. detail explains an unhealthy receiver.
+func (s *Server) ReportReceiverHealth(receiver string, healthy bool, detail string) {
+	s.mu.Lock()
+	if s.receivers == nil {
+		s.receivers = make(map[string]ReceiverStatus)
+	}
+	prev, seen := s.receivers[receiver]
+	since := time.Now().UTC()
+	changed := !seen || prev.Healthy != healthy
+	if !changed {
+		since = prev.Since
+	}
+	st := ReceiverStatus{Name: receiver, Healthy: healthy, Error: detail, Since: since}
+	s.receivers[receiver] = st
+	s.mu.Unlock()
+	s.metrics.SetReceiverUp(receiver, healthy)
+	if changed {
+		s.BroadcastStatus(st.event())
+	}
+}
+
+// event describes the receiver's current state as a status event.
+func (st ReceiverStatus) event() StatusEvent {
+	ev := StatusEvent{Type: StatusReceiverUp, Receiver: st.Name, Ts: st.Since}
+	if !st.Healthy {
+		ev.Type, ev.Detail = StatusReceiverDown, st.Error
+	}
+	return ev
+}
+
+// ReportSession announces that a broadcast session started (live=true) or
+// ended to stream clients.
+func (s *Server) ReportSession(platform, channel, sessionID string, live bool, ts time.Time) {
+	ev := StatusEvent{Type: StatusSessionEnded, Platform: platform, Channel: channel, SessionID: sessionID, Ts: ts.UTC()}
+	if live {
+		ev.Type = StatusSessionStarted
+	}
+	s.BroadcastStatus(ev)
+}
+
+// BroadcastStatus queues ev for every stream client. Status events have
+// their own small queue per client, so they are not lost behind a backlog of
+// chat messages.
+func (s *Server) BroadcastStatus(ev StatusEvent) {
+	if ev.Ts.IsZero() {
+		ev.Ts = time.Now().UTC()
+	}
+	s.hub.each(func(client *streamClient) {
+		if client.alerts != nil {
+			return
+		}
+		select {
+		case client.status <- ev:
+		default:
+		}
+	})
+}
+
+// receiverEvents returns the current state of every receiver, sent to a
+// stream client when it connects.
+func (s *Server) receiverEvents() []StatusEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]StatusEvent, 0, len(s.receivers))
+	for _, st := range s.receivers {
+		out = append(out, st.event())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Receiver < out[j].Receiver })
+	return out
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	resp := statusResponse{Status: "ok", Receivers: []ReceiverStatus{}}
+	s.mu.Lock()
+	for _, st := range s.receivers {
+		resp.Receivers = append(resp.Receivers, st)
+		if !st.Healthy {
+			resp.Status = "degraded"
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(resp.Receivers, func(i, j int) bool { return resp.Receivers[i].Name < resp.Receivers[j].Name })
+	writeJSON(w, resp)
+}
@@ -0,0 +1,113 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+func TestStatusEndpoint(t *testing.T) {
+	srv := New(&stubStore{}, Options{EnableMetrics: true})
+
+	get := func() statusResponse {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp statusResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+
+	if resp := get(); resp.Status != "ok" || len(resp.Receivers) != 0 {
+		t.Fatalf("unexpected empty status %+v", resp)
+	}
+
+	srv.ReportReceiverHealth("youtube", true, "")
+	srv.ReportReceiverHealth("twitch-irc", true, "")
+	srv.ReportReceiverHealth("youtube", false, "ytlive: invalid LiveURL")
+	resp := get()
+	if resp.Status != "degraded" || len(resp.Receivers) != 2 {
+		t.Fatalf("unexpected status %+v", resp)
+	}
+	if resp.Receivers[0].Name != "twitch-irc" || !resp.Receivers[0].Healthy {
+		t.Fatalf("unexpected first receiver %+v", resp.Receivers[0])
+	}
+	if yt := resp.Receivers[1]; yt.Healthy || yt.Error != "ytlive: invalid LiveURL" || yt.Since.IsZero() {
+		t.Fatalf("unexpected youtube receiver %+v", yt)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if body := rec.Body.String(); !strings.Contains(body, `gnasty_receiver_up{receiver="youtube"} 0`) ||
+		!strings.Contains(body, `gnasty_receiver_up{receiver="twitch-irc"} 1`) {
+		t.Fatalf("receiver gauge missing from metrics:\n%s", body)
+	}
+}
+
+func TestStatusEventsBroadcast(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+	client := newStreamClient(Filters{}, "sse")
+	srv.addClient(client)
+	defer srv.removeClient(client)
+
+	srv.ReportReceiverHealth("youtube", true, "")
+	srv.ReportReceiverHealth("youtube", true, "")
+	srv.ReportReceiverHealth("youtube", false, "poll failed")
+	srv.ReportSession("Twitch", "elora", "twitch:1", true, time.Now())
+
+	var got []string
+	for len(client.status) > 0 {
+		ev := <-client.status
+		got = append(got, ev.Type+":"+ev.Receiver+ev.SessionID+":"+ev.Detail)
+	}
+	want := []string{"receiver_up:youtube:", "receiver_down:youtube:poll failed", "session_started:twitch:1:"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("status events = %v, want %v", got, want)
+	}
+	if evs := srv.receiverEvents(); len(evs) != 1 || evs[0].Type != StatusReceiverDown {
+		t.Fatalf("receiver snapshot = %+v", evs)
+	}
+}
+
+func TestWSStatusEvents(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+	srv.ReportReceiverHealth("twitch-irc", true, "")
+	ts := httptest.NewServer(srv.Mux())
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(ts.URL, "http")+"/ws?status_events=true", nil)
+	if err != nil {
+		t.Fatalf("dial ws: %v", err)
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "")
+
+	var frame wsStatusFrame
+	if err := wsjson.Read(ctx, conn, &frame); err != nil {
+		t.Fatalf("read snapshot: %v", err)
+	}
+	if frame.Event != "status" || frame.Data.Type != StatusReceiverUp || frame.Data.Receiver != "twitch-irc" {
+		t.Fatalf("unexpected snapshot frame %+v", frame)
+	}
+	waitForClients(t, srv, 1)
+	srv.ReportSession("YouTube", "@chan", "youtube:abc", false, time.Now())
+	if err := wsjson.Read(ctx, conn, &frame); err != nil {
+		t.Fatalf("read session event: %v", err)
+	}
+	if frame.Data.Type != StatusSessionEnded || frame.Data.SessionID != "youtube:abc" {
+		t.Fatalf("unexpected session frame %+v", frame)
+	}
+}
@@ -0,0 +1,51 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// StreamSession is a broadcast detected from live/ended transitions.
+type StreamSession struct {
+	Platform  string     `json:"platform"`
+	Channel   string     `json:"channel"`
+	VideoID   string     `json:"video_id,omitempty"`
+	WatchURL  string     `json:"watch_url,omitempty"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+	Live      bool       `json:"live"`
+	Messages  int64      `json:"messages"`
+}
+
+// StreamStore is implemented by stores that record stream state. Filters
+// select platforms and bound started_at with since/until.
+type StreamStore interface {
+	ListStreams(ctx context.Context, filters Filters) ([]StreamSession, error)
+}
+
+func (s *Server) handleStreams(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(StreamStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "streams unavailable")
+		return
+	}
+	filters, err := ParseFilters(r.URL.Query())
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	sessions, err := store.ListStreams(r.Context(), filters)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list streams error")
+		return
+	}
+	if sessions == nil {
+		sessions = []StreamSession{}
+	}
+	writeJSON(w, sessions)
+}
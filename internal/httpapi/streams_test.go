@@ -0,0 +1,45 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type streamStubStore struct {
+	stubStore
+	sessions []StreamSession
+	filters  Filters
+}
+
+func (s *streamStubStore) ListStreams(ctx context.Context, filters Filters) ([]StreamSession, error) {
+	s.filters = filters
+	return s.sessions, nil
+}
+
+func TestStreamsEndpoint(t *testing.T) {
+	store := &streamStubStore{sessions: []StreamSession{{Platform: "YouTube", Channel: "@chan", VideoID: "abc", StartedAt: time.Now(), Live: true, Messages: 3}}}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/streams?platform=yt", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list []StreamSession
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].VideoID != "abc" {
+		t.Fatalf("unexpected body %s (%v)", rec.Body.String(), err)
+	}
+	if len(store.filters.Platforms) != 1 || store.filters.Platforms[0] != "YouTube" {
+		t.Fatalf("filters not parsed: %+v", store.filters)
+	}
+
+	rec = httptest.NewRecorder()
+	New(&stubStore{}, Options{}).Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/streams", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without stream store, got %d", rec.Code)
+	}
+}
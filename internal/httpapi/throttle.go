@@ -0,0 +1,69 @@
+package httpapi
+
+import (
+	"errors"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang.org/x/time/rate"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// streamThrottle thins the live feed of a stream client that asked for it
+// with max_rate and/or sample, so low-power widgets are not flooded during
+// raids. Messages it withholds are dropped for that client only.
+type streamThrottle struct {
+	// sample is the fraction of messages kept, in (0, 1); 0 disables it.
+	sample  float64
+	limiter *rate.Limiter
+}
+
+// parseThrottle reads the max_rate (messages per second) and sample
+// (fraction of messages) parameters. It returns nil when neither thins the
+// feed.
+func parseThrottle(r *http.Request) (*streamThrottle, error) {
+	q := r.URL.Query()
+	t := &streamThrottle{}
+	if raw := strings.TrimSpace(q.Get("max_rate")); raw != "" {
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil || n <= 0 || math.IsInf(n, 0) || math.IsNaN(n) {
+			return nil, errors.New("max_rate must be a positive number of messages per second")
+		}
+		t.limiter = rate.NewLimiter(rate.Limit(n), int(math.Max(1, math.Ceil(n))))
+	}
+	if raw := strings.TrimSpace(q.Get("sample")); raw != "" {
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil || f <= 0 || f > 1 || math.IsNaN(f) {
+			return nil, errors.New("sample must be a number in (0, 1]")
+		}
+		if f < 1 {
+			t.sample = f
+		}
+	}
+	if t.limiter == nil && t.sample == 0 {
+		return nil, nil
+	}
+	return t, nil
+}
+
+// admit reports whether msg should be delivered. Sampling is keyed on the
+// message ID, so every client with the same sample rate sees the same
+// messages, and runs before the rate limit so withheld messages do not use
+// up its budget.
+func (t *streamThrottle) admit(msg *core.ChatMessage) bool {
+	if t == nil {
+		return true
+	}
+	if t.sample > 0 {
+		h := fnv.New32a()
+		h.Write([]byte(msg.Platform + ":" + msg.ID))
+		if float64(h.Sum32())/float64(math.MaxUint32) >= t.sample {
+			return false
+		}
+	}
+	return t.limiter == nil || t.limiter.Allow()
+}
@@ -0,0 +1,64 @@
+package httpapi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestParseThrottle(t *testing.T) {
+	tests := []struct {
+		query   string
+		wantNil bool
+		wantErr bool
+	}{
+		{"", true, false},
+		{"sample=1", true, false},
+		{"max_rate=20", false, false},
+		{"sample=0.1", false, false},
+		{"max_rate=0.5&sample=0.5", false, false},
+		{"max_rate=0", false, true},
+		{"max_rate=fast", false, true},
+		{"sample=0", false, true},
+		{"sample=1.5", false, true},
+	}
+	for _, tc := range tests {
+		th, err := parseThrottle(httptest.NewRequest(http.MethodGet, "/stream?"+tc.query, nil))
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("parseThrottle(%q) error = %v", tc.query, err)
+		}
+		if err == nil && (th == nil) != tc.wantNil {
+			t.Fatalf("parseThrottle(%q) = %+v, want nil %t", tc.query, th, tc.wantNil)
+		}
+	}
+}
+
+func TestBroadcastThrottlesPerClient(t *testing.T) {
+	srv := New(&stubStore{}, Options{EnableMetrics: true})
+	full := newStreamClient(Filters{}, "ws")
+	limited := newStreamClient(Filters{}, "ws")
+	limited.throttle, _ = parseThrottle(httptest.NewRequest(http.MethodGet, "/ws?max_rate=5", nil))
+	sampled := newStreamClient(Filters{}, "sse")
+	sampled.throttle, _ = parseThrottle(httptest.NewRequest(http.MethodGet, "/stream?sample=0.25", nil))
+	for _, c := range []*streamClient{full, limited, sampled} {
+		c.ch = make(chan core.ChatMessage, 1000)
+		srv.addClient(c)
+	}
+
+	for i := 0; i < 400; i++ {
+		srv.Broadcast(core.ChatMessage{ID: fmt.Sprintf("m%d", i), Platform: "Twitch"})
+	}
+	if len(full.ch) != 400 {
+		t.Fatalf("unthrottled client got %d messages", len(full.ch))
+	}
+	// A burst of up to max_rate messages passes, the rest is withheld.
+	if n := len(limited.ch); n < 5 || n > 6 {
+		t.Fatalf("max_rate=5 client got %d messages", n)
+	}
+	if n := len(sampled.ch); n < 60 || n > 140 {
+		t.Fatalf("sample=0.25 client got %d of 400 messages", n)
+	}
+}
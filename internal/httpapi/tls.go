@@ -0,0 +1,154 @@
+package httpapi
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certCheckInterval bounds how often the certificate files are stat'ed.
+const certCheckInterval = time.Minute
+
+// certReloader serves a certificate pair from disk and reloads it when either
+// file changes, so renewals by an external ACME client (certbot, lego, ...)
+// take effect without restarting the harvester.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errors.New("tls: certificate and key files must both be set")
+	}
+	c := &certReloader{certFile: certFile, keyFile: keyFile}
+	modTime, err := c.latestModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.load(modTime); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *certReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, path := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("tls: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+func (c *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("tls: load key pair: %w", err)
+	}
+	c.cert = &cert
+	c.modTime = modTime
+	c.checked = time.Now()
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate. A failed reload keeps
+// serving the previous certificate.
+func (c *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if time.Since(c.checked) < certCheckInterval {
+		return c.cert, nil
+	}
+	c.checked = time.Now()
+	modTime, err := c.latestModTime()
+	if err != nil {
+		log.Printf("httpapi: %v; keeping current certificate", err)
+		return c.cert, nil
+	}
+	if modTime.After(c.modTime) {
+		if err := c.load(modTime); err != nil {
+			log.Printf("httpapi: %v; keeping current certificate", err)
+		} else {
+			log.Printf("httpapi: reloaded TLS certificate from %s", c.certFile)
+		}
+	}
+	return c.cert, nil
+}
+
+// newAutocert returns a manager that obtains and renews certificates for
+// domains from Let's Encrypt, answering TLS-ALPN-01 challenges on the HTTPS
+// listener itself, and caches them in cacheDir. Requests for other host
+// names are refused.
+func newAutocert(domains []string, cacheDir, email string) (*autocert.Manager, error) {
+	var hosts []string
+	for _, d := range domains {
+		if d = strings.ToLower(strings.TrimSpace(d)); d != "" {
+			hosts = append(hosts, d)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, errors.New("tls: autocert needs at least one domain")
+	}
+	if strings.TrimSpace(cacheDir) == "" {
+		return nil, errors.New("tls: autocert needs a cache directory")
+	}
+	if err := os.MkdirAll(cacheDir, 0o700); err != nil {
+		return nil, fmt.Errorf("tls: autocert cache: %w", err)
+	}
+	return &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(cacheDir),
+		HostPolicy: autocert.HostWhitelist(hosts...),
+		Email:      strings.TrimSpace(email),
+	}, nil
+}
+
+// tlsConfig returns the listener's TLS settings: certificates from files
+// or from autocert, or nil to serve plain HTTP.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	files := s.opts.TLSCertFile != "" || s.opts.TLSKeyFile != ""
+	auto := len(s.opts.TLSAutocertDomains) > 0
+	switch {
+	case files && auto:
+		return nil, errors.New("tls: certificate files and autocert domains are mutually exclusive")
+	case files:
+		certs, err := newCertReloader(s.opts.TLSCertFile, s.opts.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: certs.GetCertificate,
+		}, nil
+	case auto:
+		m, err := newAutocert(s.opts.TLSAutocertDomains, s.opts.TLSAutocertCacheDir, s.opts.TLSAutocertEmail)
+		if err != nil {
+			return nil, err
+		}
+		return &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: m.GetCertificate,
+			NextProtos:     []string{"h2", "http/1.1", acme.ALPNProto},
+		}, nil
+	}
+	return nil, nil
+}
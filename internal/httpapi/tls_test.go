@@ -0,0 +1,128 @@
+package httpapi
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
+)
+
+func writeTestCert(t *testing.T, certFile, keyFile, name string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create cert: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("write cert: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+}
+
+func TestCertReloaderPicksUpRenewal(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	writeTestCert(t, certFile, keyFile, "old.example")
+
+	certs, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("new reloader: %v", err)
+	}
+	commonName := func() string {
+		cert, err := certs.GetCertificate(nil)
+		if err != nil {
+			t.Fatalf("get certificate: %v", err)
+		}
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatalf("parse leaf: %v", err)
+		}
+		return leaf.Subject.CommonName
+	}
+	if got := commonName(); got != "old.example" {
+		t.Fatalf("expected old.example, got %q", got)
+	}
+
+	writeTestCert(t, certFile, keyFile, "new.example")
+	future := time.Now().Add(time.Minute)
+	_ = os.Chtimes(certFile, future, future)
+	if got := commonName(); got != "old.example" {
+		t.Fatalf("expected cached certificate before check interval, got %q", got)
+	}
+	certs.checked = time.Time{}
+	if got := commonName(); got != "new.example" {
+		t.Fatalf("expected renewed certificate, got %q", got)
+	}
+
+	if err := os.WriteFile(certFile, []byte("garbage"), 0o600); err != nil {
+		t.Fatalf("corrupt cert: %v", err)
+	}
+	later := future.Add(time.Minute)
+	_ = os.Chtimes(certFile, later, later)
+	certs.checked = time.Time{}
+	if got := commonName(); got != "new.example" {
+		t.Fatalf("expected previous certificate kept after failed reload, got %q", got)
+	}
+
+	if _, err := newCertReloader(certFile, ""); err == nil {
+		t.Fatalf("expected error when key file is missing")
+	}
+}
+
+func TestTLSConfigAutocert(t *testing.T) {
+	cache := filepath.Join(t.TempDir(), "autocert")
+	s := &Server{opts: Options{TLSAutocertDomains: []string{" Chat.Example.com ", ""}, TLSAutocertCacheDir: cache}}
+	cfg, err := s.tlsConfig()
+	if err != nil {
+		t.Fatalf("tlsConfig: %v", err)
+	}
+	if cfg == nil || cfg.GetCertificate == nil || cfg.NextProtos[len(cfg.NextProtos)-1] != acme.ALPNProto {
+		t.Fatalf("unexpected autocert config %+v", cfg)
+	}
+	if info, err := os.Stat(cache); err != nil || !info.IsDir() {
+		t.Fatalf("cache dir not created: %v", err)
+	}
+	// Host names outside the list are refused before contacting the CA.
+	if _, err := cfg.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.example.com"}); err == nil {
+		t.Fatalf("expected certificate for unlisted host refused")
+	}
+
+	if cfg, err := (&Server{}).tlsConfig(); cfg != nil || err != nil {
+		t.Fatalf("plain HTTP config = %+v, %v", cfg, err)
+	}
+	both := &Server{opts: Options{TLSCertFile: "c.pem", TLSKeyFile: "k.pem", TLSAutocertDomains: []string{"chat.example.com"}, TLSAutocertCacheDir: cache}}
+	if _, err := both.tlsConfig(); err == nil {
+		t.Fatalf("expected files and autocert rejected together")
+	}
+	if _, err := (&Server{opts: Options{TLSAutocertDomains: []string{"chat.example.com"}}}).tlsConfig(); err == nil {
+		t.Fatalf("expected missing cache directory rejected")
+	}
+}
@@ -0,0 +1,95 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// maxTTSAck bounds the ids accepted by one /tts/ack request.
+const maxTTSAck = 1000
+
+// TTSItem is a message waiting to be spoken.
+type TTSItem struct {
+	ID        int64     `json:"id"`
+	Platform  string    `json:"platform"`
+	Channel   string    `json:"channel,omitempty"`
+	Rule      string    `json:"rule"`
+	MessageID string    `json:"message_id,omitempty"`
+	Username  string    `json:"username,omitempty"`
+	Text      string    `json:"text"`
+	Ts        time.Time `json:"ts"`
+	QueuedAt  time.Time `json:"queued_at"`
+}
+
+// TTSStore is implemented by stores that keep the text-to-speech queue.
+// Filters limit both calls to platforms and channels.
+type TTSStore interface {
+	ListTTS(ctx context.Context, filters Filters) ([]TTSItem, error)
+	AckTTS(ctx context.Context, ids []int64, filters Filters) (int64, error)
+}
+
+// handleTTSQueue lists pending TTS items oldest first. Items stay pending,
+// and are listed again, until they are acknowledged on /tts/ack.
+func (s *Server) handleTTSQueue(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(TTSStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "tts queue unavailable")
+		return
+	}
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
+		return
+	}
+	items, err := store.ListTTS(r.Context(), filters)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list tts queue error")
+		return
+	}
+	if items == nil {
+		items = []TTSItem{}
+	}
+	writeJSON(w, items)
+}
+
+// handleTTSAck marks items played. The body is {"ids": [...]}; ids that are
+// unknown, already acknowledged or outside the caller's scope are ignored.
+func (s *Server) handleTTSAck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(TTSStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "tts queue unavailable")
+		return
+	}
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
+		return
+	}
+	var body struct {
+		IDs []int64 `json:"ids"`
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "invalid json: "+err.Error())
+		return
+	}
+	if len(body.IDs) == 0 || len(body.IDs) > maxTTSAck {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "ids must list 1 to 1000 items")
+		return
+	}
+	acked, err := store.AckTTS(r.Context(), body.IDs, filters)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "ack tts error")
+		return
+	}
+	writeJSON(w, map[string]int64{"acked": acked})
+}
@@ -0,0 +1,75 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ttsStubStore struct {
+	stubStore
+	items   []TTSItem
+	acked   []int64
+	filters Filters
+}
+
+func (s *ttsStubStore) ListTTS(ctx context.Context, filters Filters) ([]TTSItem, error) {
+	s.filters = filters
+	return s.items, nil
+}
+
+func (s *ttsStubStore) AckTTS(ctx context.Context, ids []int64, filters Filters) (int64, error) {
+	s.acked, s.filters = ids, filters
+	return int64(len(ids)), nil
+}
+
+func TestTTSEndpoints(t *testing.T) {
+	store := &ttsStubStore{items: []TTSItem{{ID: 7, Platform: "YouTube", Rule: "superchat", Username: "bob", Text: "hi", Ts: time.Now()}}}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tts/queue?platform=youtube&limit=5", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list []TTSItem
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].ID != 7 {
+		t.Fatalf("unexpected body %s (%v)", rec.Body.String(), err)
+	}
+	if store.filters.Limit != 5 || len(store.filters.Platforms) != 1 {
+		t.Fatalf("filters not parsed: %+v", store.filters)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tts/ack?channel=elora", strings.NewReader(`{"ids":[7,8]}`)))
+	if rec.Code != http.StatusOK || strings.TrimSpace(rec.Body.String()) != `{"acked":2}` {
+		t.Fatalf("unexpected ack response %d %s", rec.Code, rec.Body.String())
+	}
+	if len(store.acked) != 2 || len(store.filters.Channels) != 1 {
+		t.Fatalf("ack not passed through: ids=%v filters=%+v", store.acked, store.filters)
+	}
+
+	for _, body := range []string{`{"ids":[]}`, `{"id":7}`, `nope`} {
+		rec = httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/tts/ack", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for %s, got %d", body, rec.Code)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tts/ack", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	New(&stubStore{}, Options{}).Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/tts/queue", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without a tts store, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,76 @@
+// Alert overlay for gnasty-chat: shows subs, raids, Super Chats and trigger
+// alerts from /alerts one at a time. Query parameters:
+//   platform, channel   forwarded to /alerts as filters
+//   types=a,b           alert types to show (sub, resub, subgift, raid,
+//                       superchat, redemption, trigger; default all)
+//   duration=S          seconds each alert stays on screen (default 6)
+//   api_key=KEY         forwarded to the API when it requires keys
+(function () {
+  "use strict";
+
+  const params = new URLSearchParams(location.search);
+  const box = document.getElementById("alert");
+  const title = box.querySelector(".title");
+  const text = box.querySelector(".text");
+  const duration = clampInt(params.get("duration"), 6, 1, 600) * 1000;
+  const queue = [];
+  let showing = false;
+
+  function clampInt(raw, def, min, max) {
+    const n = parseInt(raw, 10);
+    if (isNaN(n)) return def;
+    return Math.min(max, Math.max(min, n));
+  }
+
+  function headline(alert) {
+    const msg = alert.message || {};
+    const user = msg.Username || "Someone";
+    switch (alert.type) {
+      case "sub": return user + " subscribed!";
+      case "resub": return user + " resubscribed!";
+      case "subgift": return user + " gifted a sub!";
+      case "raid": return user + " is raiding!";
+      case "superchat": return user + " sent " + (msg.PaidAmount || "a Super Chat") + "!";
+      case "redemption": return user + " redeemed " + ((alert.redemption && alert.redemption.reward_title) || "a reward") + "!";
+      default: return alert.label || user;
+    }
+  }
+
+  function show(alert) {
+    showing = true;
+    box.className = "alert " + (alert.type || "");
+    title.textContent = headline(alert);
+    text.textContent = (alert.message && alert.message.Text) || "";
+    box.hidden = false;
+    setTimeout(function () {
+      box.classList.add("leaving");
+      setTimeout(next, 600);
+    }, duration);
+  }
+
+  function next() {
+    box.hidden = true;
+    showing = false;
+    if (queue.length) show(queue.shift());
+  }
+
+  function query() {
+    const q = new URLSearchParams();
+    for (const key of ["platform", "channel", "types", "api_key"]) {
+      if (params.get(key)) q.set(key, params.get(key));
+    }
+    return q;
+  }
+
+  function connect() {
+    const base = location.pathname.replace(/\/ui\/.*$/, "/");
+    const source = new EventSource(base + "alerts?" + query().toString());
+    source.addEventListener("alert", function (ev) {
+      let alert;
+      try { alert = JSON.parse(ev.data); } catch (e) { return; }
+      if (showing) queue.push(alert); else show(alert);
+    });
+  }
+
+  connect();
+})();
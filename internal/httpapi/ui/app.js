@@ -0,0 +1,213 @@
+// Minimal live chat viewer for gnasty-chat. Query parameters:
+//   overlay=KEY         load stored settings from /ui/config/KEY (other
+//                       parameters override individual fields)
+//   platform, username  forwarded to /ws and /messages as filters
+//   transparent=1       OBS overlay mode (no controls, transparent background)
+//   max=N               keep at most N lines (default 100)
+//   fade=S              fade lines out after S seconds (overlay mode only)
+//   backlog=N           render the N most recent stored messages first (default 20)
+//   api_key=KEY         forwarded to the API when it requires keys
+(function () {
+  "use strict";
+
+  const params = new URLSearchParams(location.search);
+  const chat = document.getElementById("chat");
+  const status = document.getElementById("status");
+  const form = document.getElementById("controls");
+  let maxLines = 100;
+  let fadeSecs = 0;
+  let showBadges = true;
+  const backlog = clampInt(params.get("backlog"), 20, 0, 1000);
+
+  // applyOverlay folds a stored overlay config into params (without
+  // overriding explicit query parameters) and the stylesheet variables.
+  function applyOverlay(cfg) {
+    const fill = { platform: cfg.platform, username: cfg.username, max: cfg.max_lines, fade: cfg.fade_secs, transparent: cfg.transparent ? "1" : "" };
+    for (const key in fill) {
+      if (!params.has(key) && fill[key]) params.set(key, String(fill[key]));
+    }
+    const root = document.documentElement.style;
+    if (cfg.font_family) root.setProperty("--font-family", cfg.font_family);
+    if (cfg.font_size) root.setProperty("--font-size", cfg.font_size + "px");
+    if (cfg.text_color) root.setProperty("--fg", cfg.text_color);
+    if (cfg.background) root.setProperty("--overlay-bg", cfg.background);
+    if (cfg.show_badges === false) showBadges = false;
+  }
+
+  function loadOverlay() {
+    const key = params.get("overlay");
+    if (!key) return Promise.resolve();
+    const auth = params.get("api_key") ? "?api_key=" + encodeURIComponent(params.get("api_key")) : "";
+    return fetch("config/" + encodeURIComponent(key) + auth)
+      .then(function (res) { return res.ok ? res.json() : null; })
+      .then(function (cfg) { if (cfg) applyOverlay(cfg); })
+      .catch(function () {});
+  }
+
+  function applySettings() {
+    maxLines = clampInt(params.get("max"), 100, 1, 1000);
+    fadeSecs = clampInt(params.get("fade"), 0, 0, 3600);
+    form.platform.value = (params.get("platform") || "").toLowerCase();
+    form.username.value = params.get("username") || "";
+    form.transparent.checked = params.get("transparent") === "1";
+    document.body.classList.toggle("transparent", form.transparent.checked);
+  }
+
+  form.addEventListener("submit", function (ev) {
+    ev.preventDefault();
+    const next = new URLSearchParams(location.search);
+    setOrDelete(next, "platform", form.platform.value);
+    setOrDelete(next, "username", form.username.value.trim());
+    setOrDelete(next, "transparent", form.transparent.checked ? "1" : "");
+    location.search = next.toString();
+  });
+
+  function setOrDelete(p, key, value) {
+    if (value) p.set(key, value); else p.delete(key);
+  }
+
+  function clampInt(raw, def, min, max) {
+    const n = parseInt(raw, 10);
+    if (isNaN(n)) return def;
+    return Math.min(max, Math.max(min, n));
+  }
+
+  function filterQuery() {
+    const q = new URLSearchParams();
+    if (params.get("platform")) q.set("platform", params.get("platform"));
+    if (params.get("username")) q.set("username", params.get("username"));
+    if (params.get("api_key")) q.set("api_key", params.get("api_key"));
+    return q;
+  }
+
+  function setStatus(text, cls) {
+    status.textContent = text;
+    status.className = "status " + (cls || "");
+  }
+
+  // emoteRanges converts the platform emote payload into [{start,end,url,name}]
+  // sorted by start, with offsets counted in code points.
+  function emoteRanges(msg) {
+    let list;
+    try {
+      list = msg.EmotesJSON ? JSON.parse(msg.EmotesJSON) : [];
+    } catch (e) {
+      return [];
+    }
+    const out = [];
+    for (const item of list || []) {
+      if (typeof item === "string") {
+        // Twitch: "emoteID:start-end,start-end"
+        const [id, spans] = item.split(":");
+        for (const span of (spans || "").split(",")) {
+          const [s, e] = span.split("-").map(Number);
+          if (!isNaN(s) && !isNaN(e)) {
+            out.push({ start: s, end: e, url: "https://static-cdn.jtvnw.net/emoticons/v2/" + encodeURIComponent(id) + "/default/dark/1.0" });
+          }
+        }
+      } else if (item && Array.isArray(item.locations)) {
+        const img = (item.images || [])[0];
+        for (const loc of item.locations) {
+          out.push({ start: loc.start, end: loc.end, url: img && img.url, name: item.name });
+        }
+      }
+    }
+    return out.filter(function (r) { return r.url; }).sort(function (a, b) { return a.start - b.start; });
+  }
+
+  function renderText(el, msg) {
+    const chars = Array.from(msg.Text || "");
+    let pos = 0;
+    for (const r of emoteRanges(msg)) {
+      if (r.start < pos || r.end >= chars.length) continue;
+      if (r.start > pos) el.appendChild(document.createTextNode(chars.slice(pos, r.start).join("")));
+      const img = document.createElement("img");
+      img.className = "emote";
+      img.src = r.url;
+      img.alt = r.name || chars.slice(r.start, r.end + 1).join("");
+      el.appendChild(img);
+      pos = r.end + 1;
+    }
+    if (pos < chars.length) el.appendChild(document.createTextNode(chars.slice(pos).join("")));
+  }
+
+  function render(msg) {
+    const li = document.createElement("li");
+    li.className = "msg";
+
+    const dot = document.createElement("span");
+    dot.className = "platform " + (msg.Platform || "");
+    dot.title = msg.Platform || "";
+    li.appendChild(dot);
+
+    for (const badge of showBadges ? msg.badges || [] : []) {
+      const img = (badge.images || [])[0];
+      if (!img || !img.url) continue;
+      const el = document.createElement("img");
+      el.className = "badge";
+      el.src = img.url;
+      el.alt = badge.id || "";
+      li.appendChild(el);
+    }
+
+    if (/^https:\/\//.test(msg.AvatarURL || "")) {
+      const avatar = document.createElement("img");
+      avatar.className = "avatar";
+      avatar.src = msg.AvatarURL;
+      avatar.alt = "";
+      li.appendChild(avatar);
+    }
+
+    const channelLink = msg.Platform === "YouTube" && msg.AuthorChannelID;
+    const user = document.createElement(channelLink ? "a" : "span");
+    user.className = "user";
+    if (channelLink) {
+      user.href = "https://www.youtube.com/channel/" + encodeURIComponent(msg.AuthorChannelID);
+      user.target = "_blank";
+      user.rel = "noopener";
+    }
+    user.textContent = msg.Username || "";
+    if (/^#[0-9a-fA-F]{3,8}$/.test(msg.Colour || "")) user.style.color = msg.Colour;
+    li.appendChild(user);
+
+    const text = document.createElement("span");
+    text.className = "text";
+    renderText(text, msg);
+    li.appendChild(text);
+
+    chat.appendChild(li);
+    while (chat.children.length > maxLines) chat.removeChild(chat.firstChild);
+    if (fadeSecs > 0 && document.body.classList.contains("transparent")) {
+      setTimeout(function () { li.classList.add("fade"); }, fadeSecs * 1000);
+    }
+    if (!document.body.classList.contains("transparent")) window.scrollTo(0, document.body.scrollHeight);
+  }
+
+  function loadBacklog() {
+    if (backlog === 0) return Promise.resolve();
+    const q = filterQuery();
+    q.set("limit", String(backlog));
+    return fetch("../messages?" + q.toString())
+      .then(function (res) { return res.ok ? res.json() : []; })
+      .then(function (rows) { (rows || []).slice().reverse().forEach(render); })
+      .catch(function () {});
+  }
+
+  let retry = 1000;
+  function connect() {
+    const proto = location.protocol === "https:" ? "wss:" : "ws:";
+    const base = location.pathname.replace(/\/ui\/.*$/, "/");
+    const ws = new WebSocket(proto + "//" + location.host + base + "ws?" + filterQuery().toString());
+    ws.onopen = function () { retry = 1000; setStatus("live", "ok"); };
+    ws.onmessage = function (ev) {
+      try { render(JSON.parse(ev.data)); } catch (e) { /* ignore malformed frames */ }
+    };
+    ws.onclose = function () {
+      setStatus("reconnecting…", "err");
+      setTimeout(connect, retry);
+      retry = Math.min(retry * 2, 30000);
+    };
+  }
+
+  loadOverlay().then(applySettings).then(loadBacklog).then(connect);
+})();
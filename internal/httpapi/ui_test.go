@@ -0,0 +1,36 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUIServesEmbeddedViewer(t *testing.T) {
+	srv := New(&stubStore{}, Options{EnableUI: true})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui?transparent=1", nil))
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/ui/?transparent=1" {
+		t.Fatalf("expected redirect preserving query, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "app.js") {
+		t.Fatalf("expected index page, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/app.js", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected app.js, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ui/alerts.html", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "alerts.js") {
+		t.Fatalf("expected alert overlay page, got %d", rec.Code)
+	}
+}
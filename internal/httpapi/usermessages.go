@@ -0,0 +1,178 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// exportPageSize is the page size used while streaming CSV/NDJSON exports.
+const exportPageSize = 1000
+
+// ErrInvalidCursor is returned by stores for malformed pagination cursors.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// UserMessageStore is implemented by stores that can page through one user's
+// messages. key is the user's key as reported by UserStore.
+type UserMessageStore interface {
+	ListUserMessages(ctx context.Context, platform, key string, filters Filters, cursor string) ([]core.ChatMessage, string, error)
+}
+
+// UserMessagesPage is the JSON response of /users/{platform}/{key}/messages.
+type UserMessagesPage struct {
+	Messages   []core.ChatMessage `json:"messages"`
+	NextCursor string             `json:"next_cursor,omitempty"`
+}
+
+// exportFormat picks json, csv or ndjson from the format parameter or the
+// Accept header.
+func exportFormat(r *http.Request) (string, bool) {
+	if raw := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("format"))); raw != "" {
+		switch raw {
+		case "json", "csv", "ndjson":
+			return raw, true
+		}
+		return "", false
+	}
+	accept := strings.ToLower(r.Header.Get("Accept"))
+	switch {
+	case strings.Contains(accept, "text/csv"):
+		return "csv", true
+	case strings.Contains(accept, "application/x-ndjson"), strings.Contains(accept, "application/ndjson"):
+		return "ndjson", true
+	}
+	return "json", true
+}
+
+// messageCSVHeader names the columns of messageCSVRecord.
+var messageCSVHeader = []string{"id", "ts", "platform", "channel", "username", "user_id", "author_channel_id", "type", "session_id", "text"}
+
+// messageCSVRecord is the CSV row of a message in CSV downloads.
+func messageCSVRecord(m core.ChatMessage) []string {
+	msgType := m.MessageType
+	if msgType == "" {
+		msgType = core.MessageTypeChat
+	}
+	return []string{
+		m.ID, m.Ts.UTC().Format(time.RFC3339Nano), m.Platform, m.Channel,
+		csvCell(m.Username), m.UserID, m.AuthorChannelID, msgType, m.SessionID, csvCell(m.Text),
+	}
+}
+
+// csvCell keeps chat-supplied text from being evaluated as a formula when
+// a CSV download is opened in a spreadsheet, by prefixing cells that start
+// with a formula character with an apostrophe.
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// handleUserMessages serves one user's messages: a page of JSON with a
+// next_cursor, or the complete history as a CSV/NDJSON download.
+func (s *Server) handleUserMessages(w http.ResponseWriter, r *http.Request, user User) {
+	store, ok := s.store.(UserMessageStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "user messages unavailable")
+		return
+	}
+	format, ok := exportFormat(r)
+	if !ok {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "format must be json, csv or ndjson")
+		return
+	}
+	filters, ok := s.requestFilters(w, r)
+	if !ok {
+		return
+	}
+	cursor := strings.TrimSpace(r.URL.Query().Get("cursor"))
+
+	if format == "json" {
+		rows, next, err := store.ListUserMessages(r.Context(), user.Platform, user.Key, filters, cursor)
+		if errors.Is(err, ErrInvalidCursor) {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list user messages error")
+			return
+		}
+		if rows == nil {
+			rows = []core.ChatMessage{}
+		}
+		writeJSON(w, UserMessagesPage{Messages: s.maskMessages(filters, rows), NextCursor: next})
+		return
+	}
+
+	// Downloads cover everything from the cursor on, ignoring limit.
+	filters.Limit = exportPageSize
+	rows, next, err := store.ListUserMessages(r.Context(), user.Platform, user.Key, filters, cursor)
+	if errors.Is(err, ErrInvalidCursor) {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list user messages error")
+		return
+	}
+
+	filename := fmt.Sprintf("%s-%s-messages.%s", strings.ToLower(user.Platform), exportFilenamePart(user.Key), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	var write func(core.ChatMessage) error
+	var flush func() error
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		cw := csv.NewWriter(w)
+		_ = cw.Write(messageCSVHeader)
+		write = func(m core.ChatMessage) error { return cw.Write(messageCSVRecord(m)) }
+		flush = func() error {
+			cw.Flush()
+			return cw.Error()
+		}
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		write = func(m core.ChatMessage) error { return enc.Encode(m) }
+		flush = func() error { return nil }
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	for {
+		for _, m := range rows {
+			if err := write(s.maskMessage(filters, m)); err != nil {
+				return
+			}
+		}
+		if err := flush(); err != nil || next == "" {
+			return
+		}
+		rows, next, err = store.ListUserMessages(r.Context(), user.Platform, user.Key, filters, next)
+		if err != nil {
+			// Headers are already sent; a truncated download is all we can
+			// signal.
+			log.Printf("httpapi: export %s/%s: %v", user.Platform, user.Key, err)
+			return
+		}
+	}
+}
+
+func exportFilenamePart(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, s)
+}
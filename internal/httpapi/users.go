@@ -0,0 +1,89 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// User is a chatter's profile as recorded in the users table.
+type User struct {
+	Platform         string     `json:"platform"`
+	Key              string     `json:"key"`
+	Username         string     `json:"username"`
+	AuthorChannelID  string     `json:"author_channel_id,omitempty"`
+	AvatarURL        string     `json:"avatar_url,omitempty"`
+	BroadcasterType  string     `json:"broadcaster_type,omitempty"`
+	AccountCreatedAt *time.Time `json:"account_created_at,omitempty"`
+	FirstSeen        time.Time  `json:"first_seen"`
+	LastSeen         time.Time  `json:"last_seen"`
+}
+
+// UserStore is implemented by stores that track chatter profiles. Filters
+// apply to users the same way they do to messages, with since/until bounding
+// last_seen.
+type UserStore interface {
+	ListUsers(ctx context.Context, filters Filters) ([]User, error)
+	GetUser(ctx context.Context, platform, key string) (User, bool, error)
+}
+
+// handleUsers serves GET /users, GET /users/{platform}/{key} and
+// GET /users/{platform}/{key}/messages, where key is the user's channel ID or
+// normalized username.
+func (s *Server) handleUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, r, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, "method not allowed")
+		return
+	}
+	store, ok := s.store.(UserStore)
+	if !ok {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "users unavailable")
+		return
+	}
+
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/users"), "/")
+	if rest == "" {
+		filters, err := ParseFilters(r.URL.Query())
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, err.Error())
+			return
+		}
+		users, err := store.ListUsers(r.Context(), filters)
+		if err != nil {
+			writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "list users error")
+			return
+		}
+		if users == nil {
+			users = []User{}
+		}
+		writeJSON(w, users)
+		return
+	}
+
+	parts := strings.Split(rest, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] == "" || (len(parts) == 3 && parts[2] != "messages") {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "not found")
+		return
+	}
+	rawPlatform, rawKey := parts[0], parts[1]
+	platform, ok := normalizePlatform(rawPlatform)
+	if !ok || platform == "" {
+		writeError(w, r, http.StatusBadRequest, ErrCodeBadRequest, "invalid platform")
+		return
+	}
+	user, found, err := store.GetUser(r.Context(), platform, rawKey)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, ErrCodeInternal, "get user error")
+		return
+	}
+	if !found {
+		writeError(w, r, http.StatusNotFound, ErrCodeNotFound, "user not found")
+		return
+	}
+	if len(parts) == 3 {
+		s.handleUserMessages(w, r, user)
+		return
+	}
+	writeJSON(w, user)
+}
@@ -0,0 +1,159 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+type userStubStore struct {
+	stubStore
+	users    []User
+	filters  Filters
+	messages []core.ChatMessage
+}
+
+func (s *userStubStore) ListUsers(ctx context.Context, filters Filters) ([]User, error) {
+	s.filters = filters
+	return s.users, nil
+}
+
+func (s *userStubStore) GetUser(ctx context.Context, platform, key string) (User, bool, error) {
+	for _, u := range s.users {
+		if u.Platform == platform && u.Key == key {
+			return u, true, nil
+		}
+	}
+	return User{}, false, nil
+}
+
+// ListUserMessages pages through s.messages one row at a time, using the
+// index of the next row as the cursor.
+func (s *userStubStore) ListUserMessages(ctx context.Context, platform, key string, filters Filters, cursor string) ([]core.ChatMessage, string, error) {
+	start := 0
+	if cursor != "" {
+		n, err := strconv.Atoi(cursor)
+		if err != nil || n < 0 || n >= len(s.messages) {
+			return nil, "", ErrInvalidCursor
+		}
+		start = n
+	}
+	if start >= len(s.messages) {
+		return nil, "", nil
+	}
+	next := ""
+	if start+1 < len(s.messages) {
+		next = strconv.Itoa(start + 1)
+	}
+	return s.messages[start : start+1], next, nil
+}
+
+func TestUsersEndpoints(t *testing.T) {
+	store := &userStubStore{users: []User{{Platform: "Twitch", Key: "elora", Username: "Elora", BroadcasterType: "partner"}}}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users?platform=tw&username=ELO", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list []User
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 {
+		t.Fatalf("unexpected list body %s (%v)", rec.Body.String(), err)
+	}
+	if len(store.filters.Platforms) != 1 || store.filters.Platforms[0] != "Twitch" || store.filters.Usernames[0] != "elo" {
+		t.Fatalf("filters not parsed: %+v", store.filters)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/twitch/elora", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get: expected 200, got %d", rec.Code)
+	}
+	var user User
+	if err := json.Unmarshal(rec.Body.Bytes(), &user); err != nil || user.BroadcasterType != "partner" {
+		t.Fatalf("unexpected user body %s (%v)", rec.Body.String(), err)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/twitch/nobody", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing: expected 404, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/mixer/elora", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("bad platform: expected 400, got %d", rec.Code)
+	}
+}
+
+func TestUsersUnavailableWithoutUserStore(t *testing.T) {
+	srv := New(&stubStore{}, Options{})
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rec.Code)
+	}
+}
+
+func TestUserMessagesPagesAndExports(t *testing.T) {
+	store := &userStubStore{
+		users: []User{{Platform: "Twitch", Key: "elora", Username: "Elora"}},
+		messages: []core.ChatMessage{
+			{ID: "m1", Platform: "Twitch", Username: "Elora", Text: "hello"},
+			{ID: "m2", Platform: "Twitch", Username: "Elora", Text: "with, comma"},
+		},
+	}
+	srv := New(store, Options{})
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/twitch/elora/messages", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("page: expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var page UserMessagesPage
+	if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil || len(page.Messages) != 1 || page.NextCursor != "1" {
+		t.Fatalf("unexpected page %s (%v)", rec.Body.String(), err)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users/twitch/elora/messages?format=csv", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/csv") {
+		t.Fatalf("csv: unexpected response %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "twitch-elora-messages.csv") {
+		t.Fatalf("csv: unexpected disposition %q", rec.Header().Get("Content-Disposition"))
+	}
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "id,ts,platform") || !strings.HasSuffix(lines[2], `"with, comma"`) {
+		t.Fatalf("csv: unexpected body %q", rec.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/users/twitch/elora/messages", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, req)
+	if got := strings.Count(rec.Body.String(), "\n"); rec.Code != http.StatusOK || got != 2 {
+		t.Fatalf("ndjson: expected 2 lines, got %d (%d)", got, rec.Code)
+	}
+
+	for path, want := range map[string]int{
+		"/users/twitch/elora/messages?format=xml":   http.StatusBadRequest,
+		"/users/twitch/elora/messages?cursor=bogus": http.StatusBadRequest,
+		"/users/twitch/nobody/messages":             http.StatusNotFound,
+		"/users/twitch/elora/other":                 http.StatusNotFound,
+	} {
+		rec = httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+}
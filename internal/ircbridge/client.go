@@ -0,0 +1,433 @@
+package ircbridge
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+type client struct {
+	srv  *Server
+	conn net.Conn
+	host string
+	send chan string
+
+	closeOnce sync.Once
+	done      chan struct{}
+
+	// Guarded by mu; written by the read loop, read by deliver.
+	mu         sync.RWMutex
+	nick       string
+	user       string
+	registered bool
+	rooms      map[string]room // lowercased room name -> what it carries
+	serverTime bool
+	msgTags    bool
+
+	// Read-loop only.
+	passOK     bool
+	capPending bool
+}
+
+func newClient(srv *Server, conn net.Conn) *client {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		host = conn.RemoteAddr().String()
+	}
+	return &client{
+		srv:    srv,
+		conn:   conn,
+		host:   host,
+		send:   make(chan string, sendQueueSize),
+		done:   make(chan struct{}),
+		rooms:  make(map[string]room),
+		passOK: srv.opts.Password == "",
+	}
+}
+
+func (c *client) serve() {
+	go c.writeLoop()
+	defer c.close("")
+
+	reader := bufio.NewReaderSize(c.conn, 4096)
+	_ = c.conn.SetReadDeadline(time.Now().Add(registerTimeout))
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line != "" && !c.handle(parseLine(line)) {
+			return
+		}
+		if c.isRegistered() {
+			_ = c.conn.SetReadDeadline(time.Now().Add(2 * pingInterval))
+		}
+	}
+}
+
+func (c *client) writeLoop() {
+	w := bufio.NewWriter(c.conn)
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			_ = w.Flush()
+			return
+		case <-ticker.C:
+			if err := writeLine(w, "PING :"+c.srv.opts.ServerName); err != nil {
+				c.close("")
+				return
+			}
+		case line := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			if err := writeLine(w, line); err != nil {
+				c.close("")
+				return
+			}
+			// Drain whatever is queued before flushing.
+			for more := true; more; {
+				select {
+				case next := <-c.send:
+					if err := writeLine(w, next); err != nil {
+						c.close("")
+						return
+					}
+				default:
+					more = false
+				}
+			}
+		}
+		if err := w.Flush(); err != nil {
+			c.close("")
+			return
+		}
+	}
+}
+
+// close disconnects the client, optionally sending an ERROR line first.
+func (c *client) close(reason string) {
+	c.closeOnce.Do(func() {
+		if reason != "" {
+			_ = c.conn.SetWriteDeadline(time.Now().Add(time.Second))
+			_, _ = fmt.Fprintf(c.conn, "ERROR :%s\r\n", reason)
+		}
+		close(c.done)
+		_ = c.conn.Close()
+	})
+}
+
+// enqueue queues a raw line, disconnecting the client if it cannot keep up.
+func (c *client) enqueue(line string) {
+	select {
+	case <-c.done:
+	case c.send <- line:
+	default:
+		go c.close("SendQ exceeded")
+	}
+}
+
+func (c *client) reply(code, format string, args ...any) {
+	c.mu.RLock()
+	nick := c.nick
+	c.mu.RUnlock()
+	if nick == "" {
+		nick = "*"
+	}
+	c.enqueue(fmt.Sprintf(":%s %s %s %s", c.srv.opts.ServerName, code, nick, fmt.Sprintf(format, args...)))
+}
+
+func (c *client) isRegistered() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.registered
+}
+
+func (c *client) prefix() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return fmt.Sprintf("%s!%s@%s", c.nick, c.user, c.host)
+}
+
+// deliver forwards msg to every joined room that carries it.
+func (c *client) deliver(msg core.ChatMessage) {
+	c.mu.RLock()
+	if !c.registered || len(c.rooms) == 0 {
+		c.mu.RUnlock()
+		return
+	}
+	var targets []string
+	for name, r := range c.rooms {
+		if r.matches(msg) {
+			targets = append(targets, name)
+		}
+	}
+	serverTime, msgTags := c.serverTime, c.msgTags
+	c.mu.RUnlock()
+
+	for _, room := range targets {
+		c.enqueue(formatPrivmsg(room, msg, serverTime, msgTags))
+	}
+}
+
+func formatPrivmsg(room string, msg core.ChatMessage, serverTime, msgTags bool) string {
+	var tags []string
+	if serverTime {
+		tags = append(tags, "time="+formatTime(msg.Ts))
+	}
+	if msgTags {
+		if msg.ID != "" {
+			tags = append(tags, "msgid="+escapeTagValue(msg.ID))
+		}
+		tags = append(tags, "+gnasty/platform="+escapeTagValue(msg.Platform))
+		if msg.Colour != "" {
+			tags = append(tags, "+gnasty/colour="+escapeTagValue(msg.Colour))
+		}
+	}
+	nick := ircNick(msg.Username)
+	line := fmt.Sprintf(":%s!%s@%s.gnasty PRIVMSG %s :%s", nick, nick, strings.ToLower(msg.Platform), room, ircText(msg.Text))
+	if len(tags) > 0 {
+		line = "@" + strings.Join(tags, ";") + " " + line
+	}
+	return line
+}
+
+type message struct {
+	command string
+	params  []string
+}
+
+func parseLine(line string) message {
+	if strings.HasPrefix(line, "@") {
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			line = strings.TrimLeft(line[i+1:], " ")
+		}
+	}
+	if strings.HasPrefix(line, ":") {
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			line = strings.TrimLeft(line[i+1:], " ")
+		} else {
+			line = ""
+		}
+	}
+	var m message
+	for line != "" {
+		if strings.HasPrefix(line, ":") && m.command != "" {
+			m.params = append(m.params, line[1:])
+			break
+		}
+		part, rest, _ := strings.Cut(line, " ")
+		if m.command == "" {
+			m.command = strings.ToUpper(part)
+		} else if part != "" {
+			m.params = append(m.params, part)
+		}
+		line = strings.TrimLeft(rest, " ")
+	}
+	return m
+}
+
+// handle processes one client command; false closes the connection.
+func (c *client) handle(m message) bool {
+	switch m.command {
+	case "CAP":
+		c.handleCap(m.params)
+	case "PASS":
+		if len(m.params) > 0 && m.params[0] == c.srv.opts.Password {
+			c.passOK = true
+		}
+	case "NICK":
+		if len(m.params) == 0 {
+			c.reply("431", ":No nickname given")
+			return true
+		}
+		c.mu.Lock()
+		c.nick = ircNick(m.params[0])
+		c.mu.Unlock()
+		return c.maybeRegister()
+	case "USER":
+		if len(m.params) == 0 {
+			c.reply("461", "USER :Not enough parameters")
+			return true
+		}
+		c.mu.Lock()
+		c.user = ircNick(m.params[0])
+		c.mu.Unlock()
+		return c.maybeRegister()
+	case "PING":
+		token := c.srv.opts.ServerName
+		if len(m.params) > 0 {
+			token = m.params[0]
+		}
+		c.enqueue(fmt.Sprintf(":%s PONG %s :%s", c.srv.opts.ServerName, c.srv.opts.ServerName, token))
+	case "PONG":
+	case "QUIT":
+		c.close("Closing link")
+		return false
+	default:
+		if !c.isRegistered() {
+			c.reply("451", ":You have not registered")
+			return true
+		}
+		c.handleRegistered(m)
+	}
+	return true
+}
+
+func (c *client) handleCap(params []string) {
+	if len(params) == 0 {
+		return
+	}
+	switch strings.ToUpper(params[0]) {
+	case "LS":
+		c.capPending = true
+		c.enqueue(fmt.Sprintf(":%s CAP * LS :server-time message-tags", c.srv.opts.ServerName))
+	case "REQ":
+		c.capPending = true
+		if len(params) < 2 {
+			return
+		}
+		requested := strings.Fields(params[1])
+		for _, capName := range requested {
+			if capName != "server-time" && capName != "message-tags" {
+				c.enqueue(fmt.Sprintf(":%s CAP * NAK :%s", c.srv.opts.ServerName, params[1]))
+				return
+			}
+		}
+		c.mu.Lock()
+		for _, capName := range requested {
+			switch capName {
+			case "server-time":
+				c.serverTime = true
+			case "message-tags":
+				c.msgTags = true
+			}
+		}
+		c.mu.Unlock()
+		c.enqueue(fmt.Sprintf(":%s CAP * ACK :%s", c.srv.opts.ServerName, params[1]))
+	case "END":
+		c.capPending = false
+		c.maybeRegister()
+	}
+}
+
+func (c *client) maybeRegister() bool {
+	c.mu.Lock()
+	if c.registered || c.nick == "" || c.user == "" || c.capPending {
+		c.mu.Unlock()
+		return true
+	}
+	if !c.passOK {
+		c.mu.Unlock()
+		c.reply("464", ":Password incorrect")
+		c.close("Bad password")
+		return false
+	}
+	c.registered = true
+	c.mu.Unlock()
+
+	name := c.srv.opts.ServerName
+	c.reply("001", ":Welcome to the gnasty-chat IRC bridge %s", c.prefix())
+	c.reply("002", ":Your host is %s", name)
+	c.reply("003", ":This server relays Twitch and YouTube chat read-only")
+	c.reply("004", "%s gnasty-chat o nt", name)
+	c.reply("005", "CHANTYPES=# NETWORK=gnasty CASEMAPPING=ascii :are supported by this server")
+	c.reply("422", ":Join #all, #twitch, #youtube, #twitch-<channel> or #youtube-<video id>")
+	return true
+}
+
+func (c *client) handleRegistered(m message) {
+	switch m.command {
+	case "JOIN":
+		if len(m.params) == 0 {
+			c.reply("461", "JOIN :Not enough parameters")
+			return
+		}
+		for _, room := range strings.Split(m.params[0], ",") {
+			c.join(room)
+		}
+	case "PART":
+		if len(m.params) == 0 {
+			c.reply("461", "PART :Not enough parameters")
+			return
+		}
+		for _, room := range strings.Split(m.params[0], ",") {
+			key := strings.ToLower(room)
+			c.mu.Lock()
+			_, joined := c.rooms[key]
+			delete(c.rooms, key)
+			c.mu.Unlock()
+			if !joined {
+				c.reply("442", "%s :You're not on that channel", room)
+				continue
+			}
+			c.enqueue(fmt.Sprintf(":%s PART %s", c.prefix(), room))
+		}
+	case "PRIVMSG", "NOTICE":
+		if m.command == "PRIVMSG" && len(m.params) > 0 {
+			c.reply("404", "%s :This bridge is read-only", m.params[0])
+		}
+	case "LIST":
+		c.reply("321", "Channel :Users  Name")
+		c.reply("322", "#all 0 :Every platform")
+		c.reply("322", "#twitch 0 :Twitch chat")
+		c.reply("322", "#youtube 0 :YouTube chat")
+		c.reply("323", ":End of /LIST")
+	case "MODE":
+		if len(m.params) > 0 && strings.HasPrefix(m.params[0], "#") {
+			c.reply("324", "%s +nt", m.params[0])
+		}
+	case "WHO":
+		target := "*"
+		if len(m.params) > 0 {
+			target = m.params[0]
+		}
+		c.reply("315", "%s :End of /WHO list", target)
+	case "TOPIC":
+		if len(m.params) > 0 {
+			c.reply("331", "%s :No topic is set", m.params[0])
+		}
+	default:
+		c.reply("421", "%s :Unknown command", m.command)
+	}
+}
+
+func (c *client) join(name string) {
+	r, ok := parseRoom(name)
+	if !ok {
+		c.reply("403", "%s :No such channel (use #all, #twitch or #youtube)", name)
+		return
+	}
+	key := strings.ToLower(name)
+	c.mu.Lock()
+	_, already := c.rooms[key]
+	serverTime, msgTags := c.serverTime, c.msgTags
+	nick := c.nick
+	c.mu.Unlock()
+	if already {
+		return
+	}
+
+	c.enqueue(fmt.Sprintf(":%s JOIN %s", c.prefix(), key))
+	c.reply("331", "%s :No topic is set", key)
+	c.reply("353", "= %s :%s", key, nick)
+	c.reply("366", "%s :End of /NAMES list", key)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	for _, msg := range c.srv.history(ctx, r) {
+		c.enqueue(formatPrivmsg(key, msg, serverTime, msgTags))
+	}
+	cancel()
+
+	// Start live delivery only after history so replayed lines stay ordered.
+	c.mu.Lock()
+	c.rooms[key] = r
+	c.mu.Unlock()
+}
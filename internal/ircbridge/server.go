@@ -0,0 +1,300 @@
+// Package ircbridge exposes the unified chat stream over a minimal read-only
+// IRC server so classic IRC clients can follow Twitch and YouTube chat.
+package ircbridge
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const (
+	defaultServerName = "gnasty.local"
+	sendQueueSize     = 512
+	registerTimeout   = 30 * time.Second
+	pingInterval      = 90 * time.Second
+)
+
+// Options configures the bridge listener.
+type Options struct {
+	Addr       string
+	ServerName string
+	// Password, when set, must be supplied via PASS before registration.
+	Password string
+	// HistoryLines replays up to this many stored messages on JOIN (0 disables).
+	HistoryLines int
+	// Whispers relays captured Twitch whispers to clients; by default they
+	// are dropped because the bridge has no per-user access control.
+	Whispers bool
+}
+
+// Server is a read-only IRC server fed by Broadcast.
+type Server struct {
+	opts  Options
+	store httpapi.Store
+
+	mu       sync.RWMutex
+	listener net.Listener
+	clients  map[*client]struct{}
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// New constructs a bridge. store may be nil, which disables history playback.
+func New(store httpapi.Store, opts Options) *Server {
+	if opts.ServerName == "" {
+		opts.ServerName = defaultServerName
+	}
+	return &Server{
+		opts:    opts,
+		store:   store,
+		clients: make(map[*client]struct{}),
+	}
+}
+
+// Start listens on opts.Addr and serves clients until Shutdown is called.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.opts.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(ln)
+}
+
+// Serve accepts clients from ln until Shutdown is called.
+func (s *Server) Serve(ln net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		_ = ln.Close()
+		return nil
+	}
+	s.listener = ln
+	s.mu.Unlock()
+
+	log.Printf("ircbridge: listening on %s", ln.Addr())
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if s.isClosed() || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		c := newClient(s, conn)
+		if !s.addClient(c) {
+			_ = conn.Close()
+			return nil
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer s.removeClient(c)
+			c.serve()
+		}()
+	}
+}
+
+// Shutdown closes the listener and disconnects every client.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	ln := s.listener
+	clients := make([]*client, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.Unlock()
+
+	var err error
+	if ln != nil {
+		err = ln.Close()
+	}
+	for _, c := range clients {
+		c.close("Server shutting down")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return err
+}
+
+// Broadcast delivers msg to every client joined to a matching room.
+// Whispers are dropped unless Options.Whispers is set.
+func (s *Server) Broadcast(msg core.ChatMessage) {
+	if msg.MessageType == core.MessageTypeWhisper && !s.opts.Whispers {
+		return
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+	for c := range s.clients {
+		c.deliver(msg)
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.closed
+}
+
+func (s *Server) addClient(c *client) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.clients[c] = struct{}{}
+	return true
+}
+
+func (s *Server) removeClient(c *client) {
+	s.mu.Lock()
+	delete(s.clients, c)
+	s.mu.Unlock()
+}
+
+// history returns up to HistoryLines stored messages for r, oldest first.
+func (s *Server) history(ctx context.Context, r room) []core.ChatMessage {
+	if s.store == nil || s.opts.HistoryLines <= 0 {
+		return nil
+	}
+	filters := httpapi.Filters{Limit: s.opts.HistoryLines, Order: httpapi.OrderDesc}
+	if r.platform != "" {
+		filters.Platforms = []string{r.platform}
+	}
+	switch {
+	case r.label == "":
+	case r.platform == "YouTube":
+		filters.SessionIDs = []string{ytSessionPrefix + r.label}
+	default:
+		filters.Channels = []string{strings.ToLower(r.label)}
+	}
+	rows, err := s.store.ListMessages(ctx, filters)
+	if err != nil {
+		log.Printf("ircbridge: history: %v", err)
+		return nil
+	}
+	for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+		rows[i], rows[j] = rows[j], rows[i]
+	}
+	return rows
+}
+
+// ytSessionPrefix starts the session ID YouTube messages carry for their
+// video (see ytlive.SessionID).
+const ytSessionPrefix = "youtube:"
+
+// room is what a joined channel carries: messages from platform ("" for
+// all), and with a label only those from that Twitch channel or YouTube
+// video.
+type room struct {
+	platform string
+	label    string
+}
+
+// matches reports whether msg belongs in r.
+func (r room) matches(msg core.ChatMessage) bool {
+	if r.platform != "" && r.platform != msg.Platform {
+		return false
+	}
+	switch {
+	case r.label == "":
+		return true
+	case r.platform == "YouTube":
+		video, ok := strings.CutPrefix(msg.SessionID, ytSessionPrefix)
+		return ok && strings.EqualFold(video, r.label)
+	default:
+		return strings.EqualFold(strings.TrimPrefix(msg.Channel, "#"), r.label)
+	}
+}
+
+// parseRoom maps a channel name to the room it carries. "#all" carries
+// everything; "#twitch" and "#youtube" carry a single platform, and
+// "#twitch-<channel>" and "#youtube-<video id>" one channel or video of it.
+// The label keeps its case, as YouTube video IDs are case-sensitive.
+func parseRoom(name string) (room, bool) {
+	if !strings.HasPrefix(name, "#") || len(name) > 64 || strings.ContainsAny(name, " ,\x07") {
+		return room{}, false
+	}
+	base, label, _ := strings.Cut(name[1:], "-")
+	switch strings.ToLower(base) {
+	case "all", "gnasty":
+		return room{}, true
+	case "twitch":
+		return room{platform: "Twitch", label: label}, true
+	case "youtube":
+		return room{platform: "YouTube", label: label}, true
+	default:
+		return room{}, false
+	}
+}
+
+// ircNick turns an arbitrary platform display name into a usable nick.
+func ircNick(name string) string {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+	var b strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case strings.ContainsRune("-_[]{}\\|^`", r):
+			b.WriteRune(r)
+		case r == ' ' || r == '.':
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "anonymous"
+	}
+	return b.String()
+}
+
+// ircText strips characters that would break IRC framing.
+func ircText(text string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ", "\x00", "").Replace(text)
+}
+
+func escapeTagValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\:`, " ", `\s`, "\r", `\r`, "\n", `\n`).Replace(v)
+}
+
+func writeLine(w *bufio.Writer, line string) error {
+	if _, err := w.WriteString(line); err != nil {
+		return err
+	}
+	if _, err := w.WriteString("\r\n"); err != nil {
+		return err
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
@@ -0,0 +1,145 @@
+package ircbridge
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+type historyStore struct {
+	rows []core.ChatMessage
+}
+
+func (h *historyStore) CountMessages(ctx context.Context, f httpapi.Filters) (int64, error) {
+	return int64(len(h.rows)), nil
+}
+
+func (h *historyStore) ListMessages(ctx context.Context, f httpapi.Filters) ([]core.ChatMessage, error) {
+	var out []core.ChatMessage
+	for _, row := range h.rows {
+		if f.Matches(row) {
+			out = append(out, row)
+		}
+	}
+	return out, nil
+}
+
+func TestBridgeJoinHistoryAndLive(t *testing.T) {
+	store := &historyStore{rows: []core.ChatMessage{
+		{ID: "h0", Platform: "Twitch", Channel: "other", Username: "elsewhere", Text: "other channel", Ts: time.Unix(1699999999, 0)},
+		{ID: "h1", Platform: "Twitch", Channel: "elora", Username: "old_timer", Text: "from history", Ts: time.Unix(1700000000, 0)},
+		{ID: "h2", Platform: "YouTube", SessionID: "youtube:AbC123", Username: "viewer", Text: "from video", Ts: time.Unix(1700000001, 0)},
+	}}
+	srv := New(store, Options{HistoryLines: 10})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Shutdown(context.Background())
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(conn)
+
+	fmt.Fprintf(conn, "CAP LS 302\r\nNICK viewer\r\nUSER viewer 0 * :Viewer\r\nCAP REQ :server-time\r\nCAP END\r\n")
+	waitFor(t, r, " 001 viewer ")
+
+	fmt.Fprintf(conn, "JOIN #twitch-elora\r\n")
+	waitFor(t, r, " 366 viewer #twitch-elora ")
+	line := waitFor(t, r, "PRIVMSG #twitch-elora ")
+	if !strings.HasPrefix(line, "@time=2023-11-14T22:13:20.000Z :old_timer!") || !strings.HasSuffix(line, ":from history") {
+		t.Fatalf("unexpected history line %q", line)
+	}
+
+	srv.Broadcast(core.ChatMessage{Platform: "YouTube", Username: "Some One", Text: "skip me"})
+	srv.Broadcast(core.ChatMessage{Platform: "Twitch", Channel: "other", Username: "Some One", Text: "other channel"})
+	srv.Broadcast(core.ChatMessage{Platform: "Twitch", Channel: "Elora", Username: "Some One", Text: "hello\r\nworld"})
+	line = waitFor(t, r, "PRIVMSG #twitch-elora")
+	if !strings.Contains(line, ":Some_One!Some_One@twitch.gnasty PRIVMSG #twitch-elora :hello  world") {
+		t.Fatalf("unexpected live line %q", line)
+	}
+
+	fmt.Fprintf(conn, "JOIN #youtube-AbC123\r\n")
+	line = waitFor(t, r, "PRIVMSG #youtube-abc123 ")
+	if !strings.HasSuffix(line, ":from video") {
+		t.Fatalf("unexpected video history line %q", line)
+	}
+	srv.Broadcast(core.ChatMessage{Platform: "YouTube", SessionID: "youtube:other", Username: "Some One", Text: "other video"})
+	srv.Broadcast(core.ChatMessage{Platform: "YouTube", SessionID: "youtube:AbC123", Username: "Some One", Text: "this video"})
+	line = waitFor(t, r, "PRIVMSG #youtube-abc123 ")
+	if !strings.HasSuffix(line, ":this video") {
+		t.Fatalf("unexpected video line %q", line)
+	}
+}
+
+func TestBridgeDropsWhispers(t *testing.T) {
+	for _, relay := range []bool{false, true} {
+		srv := New(nil, Options{Whispers: relay})
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("listen: %v", err)
+		}
+		go func() { _ = srv.Serve(ln) }()
+
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+		r := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "NICK viewer\r\nUSER viewer 0 * :Viewer\r\nJOIN #twitch\r\n")
+		waitFor(t, r, " 366 viewer #twitch ")
+
+		srv.Broadcast(core.ChatMessage{Platform: "Twitch", Username: "friend", Text: "psst", MessageType: core.MessageTypeWhisper})
+		srv.Broadcast(core.ChatMessage{Platform: "Twitch", Channel: "elora", Username: "viewer2", Text: "public"})
+		line := waitFor(t, r, "PRIVMSG #twitch ")
+		if got := strings.HasSuffix(line, ":psst"); got != relay {
+			t.Fatalf("Whispers=%v: first line %q", relay, line)
+		}
+		_ = conn.Close()
+		_ = srv.Shutdown(context.Background())
+	}
+}
+
+func TestParseRoom(t *testing.T) {
+	cases := map[string]room{
+		"#all":            {},
+		"#Twitch":         {platform: "Twitch"},
+		"#twitch-Elora":   {platform: "Twitch", label: "Elora"},
+		"#youtube-abC123": {platform: "YouTube", label: "abC123"},
+	}
+	for name, want := range cases {
+		got, ok := parseRoom(name)
+		if !ok || got != want {
+			t.Fatalf("parseRoom(%q) = %+v,%v; want %+v", name, got, ok, want)
+		}
+	}
+	if _, ok := parseRoom("#kick"); ok {
+		t.Fatalf("expected unknown room rejected")
+	}
+}
+
+func waitFor(t *testing.T, r *bufio.Reader, substr string) string {
+	t.Helper()
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("waiting for %q: %v", substr, err)
+		}
+		if strings.Contains(line, substr) {
+			return strings.TrimRight(line, "\r\n")
+		}
+	}
+}
@@ -0,0 +1,234 @@
+// Package luahook runs chat messages through a user-supplied Lua script.
+// The script must define a global function transform(msg) that receives the
+// message as a table with the same fields as its JSON encoding (msg.Text,
+// msg.Username, msg.Platform, ...) and returns the message to keep, with any
+// fields changed, or nil to drop it:
+//
+//	function transform(msg)
+//	  if msg.Text:find("buy followers", 1, true) then
+//	    return nil
+//	  end
+//	  msg.Text = msg.Text:gsub("%s+", " ")
+//	  return msg
+//	end
+//
+// Only the base, string, table and math libraries are available, so
+// scripts cannot touch files, the network or other processes.
+package luahook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+const (
+	defaultTimeout = 100 * time.Millisecond
+	// maxDepth bounds how deeply nested a returned table may be, which
+	// also stops self-referencing tables.
+	maxDepth = 32
+)
+
+// Options configures a Hook.
+type Options struct {
+	// Timeout bounds how long transform may run for one message; the
+	// message then passes through unchanged.
+	Timeout time.Duration
+}
+
+// Hook is a sink.Transformer backed by a Lua script. Messages are
+// transformed one at a time in a single interpreter, so globals set by the
+// script persist between messages.
+type Hook struct {
+	opts Options
+
+	mu     sync.Mutex
+	state  *lua.LState
+	fn     *lua.LFunction
+	closed bool
+}
+
+// Load runs the script at path and looks up its transform function.
+func Load(path string, opts Options) (*Hook, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	// The base library can still read files.
+	for _, name := range []string{"dofile", "loadfile"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+
+	if err := L.DoFile(path); err != nil {
+		L.Close()
+		return nil, fmt.Errorf("luahook: load %s: %w", path, err)
+	}
+	fn, ok := L.GetGlobal("transform").(*lua.LFunction)
+	if !ok {
+		L.Close()
+		return nil, fmt.Errorf("luahook: %s does not define a transform function", path)
+	}
+	return &Hook{opts: opts, state: L, fn: fn}, nil
+}
+
+// Transform calls the script's transform function with msg.
+func (h *Hook) Transform(msg core.ChatMessage) (core.ChatMessage, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return msg, true, errors.New("luahook: closed")
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return msg, true, fmt.Errorf("luahook: encode: %w", err)
+	}
+	var fields any
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return msg, true, fmt.Errorf("luahook: encode: %w", err)
+	}
+
+	L := h.state
+	ctx, cancel := context.WithTimeout(context.Background(), h.opts.Timeout)
+	defer cancel()
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+	defer L.SetTop(0)
+	if err := L.CallByParam(lua.P{Fn: h.fn, NRet: 1, Protect: true}, toLua(L, fields)); err != nil {
+		if ctx.Err() != nil {
+			return msg, true, fmt.Errorf("luahook: transform took longer than %s", h.opts.Timeout)
+		}
+		return msg, true, fmt.Errorf("luahook: transform: %w", err)
+	}
+
+	switch ret := L.Get(-1).(type) {
+	case *lua.LNilType:
+		return msg, false, nil
+	case *lua.LTable:
+		value, err := fromLua(ret, 0)
+		if err != nil {
+			return msg, true, err
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return msg, true, fmt.Errorf("luahook: decode result: %w", err)
+		}
+		var out core.ChatMessage
+		if err := json.Unmarshal(data, &out); err != nil {
+			return msg, true, fmt.Errorf("luahook: decode result: %w", err)
+		}
+		// Fields kept out of the JSON encoding are not the script's to change.
+		out.FirstMessage = msg.FirstMessage
+		return out, true, nil
+	default:
+		return msg, true, fmt.Errorf("luahook: transform returned a %s, want a table or nil", ret.Type())
+	}
+}
+
+// Close releases the interpreter.
+func (h *Hook) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.closed {
+		h.closed = true
+		h.state.Close()
+	}
+	return nil
+}
+
+// toLua converts a decoded JSON value into a Lua value.
+func toLua(L *lua.LState, value any) lua.LValue {
+	switch v := value.(type) {
+	case string:
+		return lua.LString(v)
+	case float64:
+		return lua.LNumber(v)
+	case bool:
+		return lua.LBool(v)
+	case []any:
+		t := L.CreateTable(len(v), 0)
+		for _, item := range v {
+			t.Append(toLua(L, item))
+		}
+		return t
+	case map[string]any:
+		t := L.CreateTable(0, len(v))
+		for key, item := range v {
+			t.RawSetString(key, toLua(L, item))
+		}
+		return t
+	default:
+		return lua.LNil
+	}
+}
+
+// fromLua converts a Lua value back into a JSON value. Tables with only
+// the keys 1..n become arrays; empty tables become null, which decodes into
+// an empty slice, map or struct alike.
+func fromLua(value lua.LValue, depth int) (any, error) {
+	switch v := value.(type) {
+	case lua.LString:
+		return string(v), nil
+	case lua.LNumber:
+		return float64(v), nil
+	case lua.LBool:
+		return bool(v), nil
+	case *lua.LTable:
+		if depth >= maxDepth {
+			return nil, errors.New("luahook: result nested too deeply")
+		}
+		n := v.MaxN()
+		keys := 0
+		v.ForEach(func(lua.LValue, lua.LValue) { keys++ })
+		if keys == 0 {
+			return nil, nil
+		}
+		if n == keys {
+			out := make([]any, 0, n)
+			for i := 1; i <= n; i++ {
+				item, err := fromLua(v.RawGetInt(i), depth+1)
+				if err != nil {
+					return nil, err
+				}
+				out = append(out, item)
+			}
+			return out, nil
+		}
+		out := make(map[string]any, keys)
+		var err error
+		v.ForEach(func(key, item lua.LValue) {
+			if err != nil {
+				return
+			}
+			name, ok := key.(lua.LString)
+			if !ok {
+				err = fmt.Errorf("luahook: result has a %s key, want string keys", key.Type())
+				return
+			}
+			out[string(name)], err = fromLua(item, depth+1)
+		})
+		return out, err
+	default:
+		return nil, nil
+	}
+}
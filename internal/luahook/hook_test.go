@@ -0,0 +1,118 @@
+package luahook
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func writeScript(t *testing.T, src string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "transform.lua")
+	if err := os.WriteFile(path, []byte(src), 0o600); err != nil {
+		t.Fatalf("write script: %v", err)
+	}
+	return path
+}
+
+func TestHookTransformsAndDrops(t *testing.T) {
+	path := writeScript(t, `
+local seen = 0
+
+function transform(msg)
+  seen = seen + 1
+  if msg.Text == "drop" then
+    return nil
+  end
+  if msg.Text == "loop" then
+    while true do end
+  end
+  if msg.Text == "fail" then
+    error("bad message")
+  end
+  if msg.Text == "number" then
+    return 42
+  end
+  msg.Text = string.upper(msg.Text) .. " #" .. seen
+  for _, badge in ipairs(msg.badges) do
+    badge.version = "2"
+  end
+  table.insert(msg.badges, {platform = msg.Platform, id = "lua", version = "1"})
+  msg.Colour = ""
+  return msg
+end
+`)
+	hook, err := Load(path, Options{Timeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	defer hook.Close()
+
+	ts := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	in := core.ChatMessage{ID: "m1", Platform: "Twitch", Username: "amy", Text: "hello", Colour: "#FF0000", Ts: ts, TimestampMS: ts.UnixMilli(),
+		Badges: []core.ChatBadge{{Platform: "Twitch", ID: "vip", Version: "1"}}}
+	out, keep, err := hook.Transform(in)
+	if err != nil || !keep {
+		t.Fatalf("transform: keep=%v err=%v", keep, err)
+	}
+	if out.Text != "HELLO #1" || out.Colour != "" || out.ID != "m1" || !out.Ts.Equal(ts) || out.TimestampMS != ts.UnixMilli() {
+		t.Fatalf("unexpected message %+v", out)
+	}
+	if len(out.Badges) != 2 || out.Badges[0].Version != "2" || out.Badges[1].ID != "lua" {
+		t.Fatalf("unexpected badges %+v", out.Badges)
+	}
+
+	in.Text = "drop"
+	if _, keep, err := hook.Transform(in); err != nil || keep {
+		t.Fatalf("drop: keep=%v err=%v", keep, err)
+	}
+
+	for _, text := range []string{"loop", "fail", "number"} {
+		in.Text = text
+		out, keep, err := hook.Transform(in)
+		if err == nil || !keep || out.Text != text {
+			t.Fatalf("%s: expected the message back with an error, got %+v keep=%v err=%v", text, out, keep, err)
+		}
+	}
+
+	// The interpreter keeps working, and keeps its globals, after errors.
+	in.Text = "again"
+	if out, _, err := hook.Transform(in); err != nil || out.Text != "AGAIN #6" {
+		t.Fatalf("after errors: %+v err=%v", out, err)
+	}
+}
+
+func TestHookKeepsFieldsOutsideJSON(t *testing.T) {
+	hook, err := Load(writeScript(t, `function transform(msg) return msg end`), Options{})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	defer hook.Close()
+	out, keep, err := hook.Transform(core.ChatMessage{ID: "m1", Platform: "Twitch", Text: "hi", FirstMessage: true})
+	if err != nil || !keep || !out.FirstMessage {
+		t.Fatalf("expected FirstMessage to survive the script, got %+v keep=%v err=%v", out, keep, err)
+	}
+}
+
+func TestLoadRejectsBadScripts(t *testing.T) {
+	for src, want := range map[string]string{
+		`x = 1`:                        "does not define a transform function",
+		`function transform(msg`:       "load",
+		`dofile("/etc/passwd")`:        "load",
+		`io.open("/etc/passwd", "r")`:  "load",
+		`os.execute("true")`:           "load",
+		`require("os")`:                "load",
+		`transform = "not a function"`: "does not define a transform function",
+	} {
+		if _, err := Load(writeScript(t, src), Options{}); err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("Load(%q) error = %v, want %q", src, err, want)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.lua"), Options{}); err == nil {
+		t.Fatal("expected a missing script to fail")
+	}
+}
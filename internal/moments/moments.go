@@ -0,0 +1,246 @@
+// Package moments detects chat velocity spikes ("moments") worth revisiting
+// when editing highlights.
+package moments
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// Options tunes spike detection.
+type Options struct {
+	// Bucket is the width of each rate sample.
+	Bucket time.Duration
+	// Baseline is how many preceding buckets form the trailing baseline.
+	Baseline int
+	// MinHistory is how many buckets must precede a spike candidate.
+	MinHistory int
+	// MinZScore is the z-score a bucket must reach to count as a spike.
+	MinZScore float64
+	// MinMessages ignores buckets with fewer messages, so a quiet chat going
+	// from zero to two messages is not a moment.
+	MinMessages int
+}
+
+// DefaultOptions returns the detection settings used by the harvester.
+func DefaultOptions() Options {
+	return Options{
+		Bucket:      10 * time.Second,
+		Baseline:    30,
+		MinHistory:  6,
+		MinZScore:   3,
+		MinMessages: 5,
+	}
+}
+
+func (o Options) withDefaults() Options {
+	def := DefaultOptions()
+	if o.Bucket <= 0 {
+		o.Bucket = def.Bucket
+	}
+	if o.Baseline <= 0 {
+		o.Baseline = def.Baseline
+	}
+	if o.MinHistory <= 0 {
+		o.MinHistory = def.MinHistory
+	}
+	if o.MinZScore <= 0 {
+		o.MinZScore = def.MinZScore
+	}
+	if o.MinMessages <= 0 {
+		o.MinMessages = def.MinMessages
+	}
+	return o
+}
+
+// Spike is a run of consecutive buckets whose message rate stood out from
+// the trailing baseline.
+type Spike struct {
+	Start    time.Time
+	End      time.Time
+	Messages int
+	// PeakRate is the highest bucket rate in messages per second.
+	PeakRate float64
+	// ZScore is the highest bucket z-score within the spike.
+	ZScore float64
+}
+
+// Detect scans per-bucket message counts starting at origin. Consecutive
+// qualifying buckets are merged and scored against the baseline that
+// preceded the first of them, so a long spike does not drown itself out.
+func Detect(counts []int, origin time.Time, opts Options) []Spike {
+	opts = opts.withDefaults()
+	secs := opts.Bucket.Seconds()
+
+	var (
+		out     []Spike
+		current *Spike
+		mean    float64
+		stddev  float64
+	)
+	for i, c := range counts {
+		if current == nil {
+			if i < opts.MinHistory {
+				continue
+			}
+			mean, stddev = baseline(counts[max(0, i-opts.Baseline):i])
+		}
+		z := (float64(c) - mean) / stddev
+		if c < opts.MinMessages || z < opts.MinZScore {
+			if current != nil {
+				out = append(out, *current)
+				current = nil
+			}
+			continue
+		}
+		start := origin.Add(time.Duration(i) * opts.Bucket)
+		if current == nil {
+			current = &Spike{Start: start}
+		}
+		current.End = start.Add(opts.Bucket)
+		current.Messages += c
+		current.PeakRate = math.Max(current.PeakRate, float64(c)/secs)
+		current.ZScore = math.Max(current.ZScore, z)
+	}
+	if current != nil {
+		out = append(out, *current)
+	}
+	return out
+}
+
+// baseline returns the mean and standard deviation of counts. The deviation
+// is floored at one message so near-silent baselines do not yield huge
+// z-scores.
+func baseline(counts []int) (float64, float64) {
+	if len(counts) == 0 {
+		return 0, 1
+	}
+	var sum float64
+	for _, c := range counts {
+		sum += float64(c)
+	}
+	mean := sum / float64(len(counts))
+	var variance float64
+	for _, c := range counts {
+		d := float64(c) - mean
+		variance += d * d
+	}
+	return mean, math.Max(1, math.Sqrt(variance/float64(len(counts))))
+}
+
+// Term is a frequently used emote or keyword.
+type Term struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+// TopTerms returns the n most used emotes and keywords in msgs. Emotes come
+// from the stored emote metadata; keywords are the remaining words of three
+// or more letters, case-folded, excluding common filler words.
+func TopTerms(msgs []core.ChatMessage, n int) ([]Term, []Term) {
+	emotes := map[string]int{}
+	keywords := map[string]int{}
+	for _, msg := range msgs {
+		names := emoteNames(msg)
+		isEmote := make(map[string]bool, len(names))
+		for _, name := range names {
+			emotes[name]++
+			isEmote[name] = true
+		}
+		seen := map[string]bool{}
+		for _, word := range strings.FieldsFunc(msg.Text, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != ':' && r != '_' && r != '-'
+		}) {
+			if isEmote[word] {
+				continue
+			}
+			word = strings.ToLower(strings.Trim(word, ":-_"))
+			if len([]rune(word)) < 3 || stopWords[word] || seen[word] {
+				continue
+			}
+			// Count each keyword once per message so one spammer does not
+			// dominate.
+			seen[word] = true
+			keywords[word]++
+		}
+	}
+	return top(emotes, n), top(keywords, n)
+}
+
+func top(counts map[string]int, n int) []Term {
+	out := make([]Term, 0, len(counts))
+	for text, count := range counts {
+		out = append(out, Term{Text: text, Count: count})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Text < out[j].Text
+	})
+	if n > 0 && len(out) > n {
+		out = out[:n]
+	}
+	return out
+}
+
+// emoteNames extracts emote names from a message's stored emote payload:
+// Twitch "id:start-end,..." position lists or YouTube emote objects.
+func emoteNames(msg core.ChatMessage) []string {
+	raw := strings.TrimSpace(msg.EmotesJSON)
+	if raw == "" || raw == "[]" {
+		return nil
+	}
+	var positions []string
+	if err := json.Unmarshal([]byte(raw), &positions); err == nil {
+		text := []rune(msg.Text)
+		var names []string
+		for _, entry := range positions {
+			_, ranges, ok := strings.Cut(entry, ":")
+			if !ok {
+				continue
+			}
+			for _, r := range strings.Split(ranges, ",") {
+				from, to, ok := strings.Cut(r, "-")
+				if !ok {
+					continue
+				}
+				start, err1 := strconv.Atoi(from)
+				end, err2 := strconv.Atoi(to)
+				if err1 != nil || err2 != nil || start < 0 || end < start || end >= len(text) {
+					continue
+				}
+				names = append(names, string(text[start:end+1]))
+			}
+		}
+		return names
+	}
+	var objects []struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(raw), &objects); err != nil {
+		return nil
+	}
+	var names []string
+	for _, o := range objects {
+		if o.Name != "" {
+			names = append(names, o.Name)
+		}
+	}
+	return names
+}
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "you": true, "that": true, "this": true, "for": true,
+	"with": true, "are": true, "was": true, "but": true, "not": true, "have": true,
+	"what": true, "just": true, "its": true, "his": true, "her": true, "they": true,
+	"she": true, "him": true, "all": true, "can": true, "get": true, "got": true,
+	"from": true, "there": true, "too": true, "how": true, "out": true, "now": true,
+}
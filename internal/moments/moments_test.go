@@ -0,0 +1,47 @@
+package moments
+
+import (
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestDetectMergesSpikeBuckets(t *testing.T) {
+	origin := time.Date(2026, 10, 13, 18, 0, 0, 0, time.UTC)
+	counts := []int{2, 3, 2, 2, 3, 2, 2, 3, 2, 20, 25, 4, 2, 3, 2, 2}
+
+	spikes := Detect(counts, origin, Options{})
+	if len(spikes) != 1 {
+		t.Fatalf("expected one spike, got %+v", spikes)
+	}
+	s := spikes[0]
+	if !s.Start.Equal(origin.Add(90*time.Second)) || !s.End.Equal(origin.Add(110*time.Second)) {
+		t.Fatalf("unexpected spike window %v - %v", s.Start, s.End)
+	}
+	if s.Messages != 45 || s.PeakRate != 2.5 || s.ZScore < 3 {
+		t.Fatalf("unexpected spike %+v", s)
+	}
+}
+
+func TestDetectIgnoresQuietChat(t *testing.T) {
+	counts := []int{0, 0, 0, 0, 0, 0, 0, 0, 3, 0}
+	if spikes := Detect(counts, time.Now(), Options{}); len(spikes) != 0 {
+		t.Fatalf("expected no spikes below the message floor, got %+v", spikes)
+	}
+}
+
+func TestTopTerms(t *testing.T) {
+	msgs := []core.ChatMessage{
+		{Platform: "Twitch", Text: "Kappa what a clutch", EmotesJSON: `["25:0-4"]`},
+		{Platform: "Twitch", Text: "CLUTCH clutch Kappa", EmotesJSON: `["25:14-18"]`},
+		{Platform: "YouTube", Text: "insane clutch :fire:", EmotesJSON: `[{"id":"x","name":":fire:"}]`},
+	}
+	emotes, keywords := TopTerms(msgs, 2)
+	if len(emotes) != 2 || emotes[0] != (Term{Text: "Kappa", Count: 2}) || emotes[1].Text != ":fire:" {
+		t.Fatalf("unexpected emotes %+v", emotes)
+	}
+	if len(keywords) != 2 || keywords[0] != (Term{Text: "clutch", Count: 3}) || keywords[1].Text != "insane" {
+		t.Fatalf("unexpected keywords %+v", keywords)
+	}
+}
@@ -0,0 +1,385 @@
+// Package mqtt implements the subset of MQTT 3.1.1 needed to publish
+// messages: CONNECT, PUBLISH (QoS 0/1), PINGREQ and DISCONNECT.
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	packetConnect    = 0x10
+	packetConnack    = 0x20
+	packetPublish    = 0x30
+	packetPuback     = 0x40
+	packetPingreq    = 0xC0
+	packetPingresp   = 0xD0
+	packetDisconnect = 0xE0
+
+	defaultKeepAlive = 30 * time.Second
+	ackTimeout       = 10 * time.Second
+)
+
+// ErrClosed is returned when publishing on a closed or failed connection.
+var ErrClosed = errors.New("mqtt: connection closed")
+
+// Options describes how to reach a broker. URL accepts tcp://, mqtt://,
+// ssl://, tls:// and mqtts:// schemes; credentials may be embedded in the URL
+// or supplied separately.
+type Options struct {
+	URL       string
+	ClientID  string
+	Username  string
+	Password  string
+	KeepAlive time.Duration
+	TLSConfig *tls.Config
+}
+
+// Client is a single broker connection. It is safe for concurrent use.
+type Client struct {
+	conn      net.Conn
+	keepAlive time.Duration
+
+	writeMu sync.Mutex
+	w       *bufio.Writer
+
+	mu       sync.Mutex
+	nextID   uint16
+	inflight map[uint16]chan struct{}
+	err      error
+	done     chan struct{}
+	lastSend time.Time
+}
+
+// Dial connects and performs the CONNECT handshake.
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("mqtt: parse url: %w", err)
+	}
+	useTLS := false
+	defaultPort := "1883"
+	switch strings.ToLower(u.Scheme) {
+	case "tcp", "mqtt", "":
+	case "ssl", "tls", "mqtts":
+		useTLS = true
+		defaultPort = "8883"
+	default:
+		return nil, fmt.Errorf("mqtt: unsupported scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	username, password := opts.Username, opts.Password
+	if u.User != nil && username == "" {
+		username = u.User.Username()
+		password, _ = u.User.Password()
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if useTLS {
+		cfg := opts.TLSConfig
+		if cfg == nil {
+			cfg = &tls.Config{ServerName: u.Hostname()}
+		}
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: cfg}).DialContext(ctx, "tcp", host)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mqtt: dial %s: %w", host, err)
+	}
+
+	keepAlive := opts.KeepAlive
+	if keepAlive <= 0 {
+		keepAlive = defaultKeepAlive
+	}
+	c := &Client{
+		conn:      conn,
+		keepAlive: keepAlive,
+		w:         bufio.NewWriter(conn),
+		inflight:  make(map[uint16]chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	} else {
+		_ = conn.SetDeadline(time.Now().Add(ackTimeout))
+	}
+	r := bufio.NewReader(conn)
+	if err := c.writePacket(encodeConnect(opts.ClientID, username, password, keepAlive)); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("mqtt: send connect: %w", err)
+	}
+	header, body, err := readPacket(r)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("mqtt: read connack: %w", err)
+	}
+	if header&0xF0 != packetConnack || len(body) != 2 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("mqtt: unexpected packet 0x%02x awaiting connack", header)
+	}
+	if code := body[1]; code != 0 {
+		_ = conn.Close()
+		return nil, fmt.Errorf("mqtt: connection refused (code %d)", code)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	go c.readLoop(r)
+	go c.pingLoop()
+	return c, nil
+}
+
+// Publish sends payload to topic. With qos 1 it waits for the broker's PUBACK.
+func (c *Client) Publish(ctx context.Context, topic string, payload []byte, qos byte, retain bool) error {
+	if qos > 1 {
+		return errors.New("mqtt: qos 2 not supported")
+	}
+	if err := c.Err(); err != nil {
+		return err
+	}
+
+	var (
+		id  uint16
+		ack chan struct{}
+	)
+	if qos == 1 {
+		c.mu.Lock()
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID = 1
+		}
+		id = c.nextID
+		ack = make(chan struct{})
+		c.inflight[id] = ack
+		c.mu.Unlock()
+	}
+
+	if err := c.writePacket(encodePublish(topic, payload, qos, retain, id)); err != nil {
+		c.fail(err)
+		return err
+	}
+	if ack == nil {
+		return nil
+	}
+
+	timer := time.NewTimer(ackTimeout)
+	defer timer.Stop()
+	select {
+	case <-ack:
+		return nil
+	case <-c.done:
+		return c.Err()
+	case <-ctx.Done():
+		c.dropInflight(id)
+		return ctx.Err()
+	case <-timer.C:
+		c.dropInflight(id)
+		return errors.New("mqtt: puback timeout")
+	}
+}
+
+// Err reports why the connection failed, or nil while it is healthy.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Done is closed once the connection has failed or been closed.
+func (c *Client) Done() <-chan struct{} { return c.done }
+
+// Close sends DISCONNECT and closes the connection.
+func (c *Client) Close() error {
+	if c.Err() == nil {
+		_ = c.writePacket([]byte{packetDisconnect, 0})
+	}
+	c.fail(ErrClosed)
+	return nil
+}
+
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	close(c.done)
+	c.mu.Unlock()
+	_ = c.conn.Close()
+}
+
+func (c *Client) dropInflight(id uint16) {
+	c.mu.Lock()
+	delete(c.inflight, id)
+	c.mu.Unlock()
+}
+
+func (c *Client) writePacket(pkt []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(ackTimeout))
+	if _, err := c.w.Write(pkt); err != nil {
+		return err
+	}
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.lastSend = time.Now()
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Client) readLoop(r *bufio.Reader) {
+	for {
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.keepAlive * 2))
+		header, body, err := readPacket(r)
+		if err != nil {
+			c.fail(fmt.Errorf("mqtt: read: %w", err))
+			return
+		}
+		switch header & 0xF0 {
+		case packetPuback:
+			if len(body) >= 2 {
+				id := binary.BigEndian.Uint16(body)
+				c.mu.Lock()
+				if ack, ok := c.inflight[id]; ok {
+					close(ack)
+					delete(c.inflight, id)
+				}
+				c.mu.Unlock()
+			}
+		case packetPingresp:
+		}
+	}
+}
+
+func (c *Client) pingLoop() {
+	ticker := time.NewTicker(c.keepAlive / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			idle := time.Since(c.lastSend)
+			c.mu.Unlock()
+			if idle < c.keepAlive/2 {
+				continue
+			}
+			if err := c.writePacket([]byte{packetPingreq, 0}); err != nil {
+				c.fail(err)
+				return
+			}
+		}
+	}
+}
+
+func encodeConnect(clientID, username, password string, keepAlive time.Duration) []byte {
+	var body []byte
+	body = appendString(body, "MQTT")
+	body = append(body, 4) // protocol level 3.1.1
+	flags := byte(0x02)    // clean session
+	if username != "" {
+		flags |= 0x80
+		if password != "" {
+			flags |= 0x40
+		}
+	}
+	body = append(body, flags)
+	secs := int(keepAlive / time.Second)
+	if secs > 0xFFFF {
+		secs = 0xFFFF
+	}
+	body = binary.BigEndian.AppendUint16(body, uint16(secs))
+	body = appendString(body, clientID)
+	if username != "" {
+		body = appendString(body, username)
+		if password != "" {
+			body = appendString(body, password)
+		}
+	}
+	return framePacket(packetConnect, body)
+}
+
+func encodePublish(topic string, payload []byte, qos byte, retain bool, id uint16) []byte {
+	header := byte(packetPublish) | qos<<1
+	if retain {
+		header |= 0x01
+	}
+	body := appendString(nil, topic)
+	if qos > 0 {
+		body = binary.BigEndian.AppendUint16(body, id)
+	}
+	body = append(body, payload...)
+	return framePacket(header, body)
+}
+
+func framePacket(header byte, body []byte) []byte {
+	pkt := []byte{header}
+	pkt = appendRemainingLength(pkt, len(body))
+	return append(pkt, body...)
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func appendRemainingLength(b []byte, n int) []byte {
+	for {
+		digit := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			digit |= 0x80
+		}
+		b = append(b, digit)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+func readPacket(r *bufio.Reader) (byte, []byte, error) {
+	header, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	length, multiplier := 0, 1
+	for i := 0; ; i++ {
+		if i == 4 {
+			return 0, nil, errors.New("malformed remaining length")
+		}
+		digit, err := r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		length += int(digit&0x7F) * multiplier
+		if digit&0x80 == 0 {
+			break
+		}
+		multiplier *= 128
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+	return header, body, nil
+}
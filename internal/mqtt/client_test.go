@@ -0,0 +1,85 @@
+package mqtt
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestPublishQoS1(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	type published struct {
+		topic   string
+		payload string
+	}
+	got := make(chan published, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		header, body, err := readPacket(r)
+		if err != nil || header != packetConnect {
+			return
+		}
+		// Username and password flags must be set.
+		if body[7]&0xC0 != 0xC0 {
+			return
+		}
+		_, _ = conn.Write([]byte{packetConnack, 2, 0, 0})
+
+		header, body, err = readPacket(r)
+		if err != nil || header&0xF0 != packetPublish || (header>>1)&0x03 != 1 {
+			return
+		}
+		n := int(binary.BigEndian.Uint16(body))
+		topic := string(body[2 : 2+n])
+		id := body[2+n : 4+n]
+		got <- published{topic: topic, payload: string(body[4+n:])}
+		_, _ = conn.Write([]byte{packetPuback, 2, id[0], id[1]})
+		_, _, _ = readPacket(r)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, Options{URL: "tcp://user:pass@" + ln.Addr().String(), ClientID: "test"})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	if err := c.Publish(ctx, "gnasty/twitch/messages", []byte(`{"Text":"hi"}`), 1, false); err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	select {
+	case p := <-got:
+		if p.topic != "gnasty/twitch/messages" || p.payload != `{"Text":"hi"}` {
+			t.Fatalf("unexpected publish %+v", p)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("broker did not receive publish")
+	}
+}
+
+func TestRemainingLengthEncoding(t *testing.T) {
+	for _, n := range []int{0, 127, 128, 16383, 16384, 2097151} {
+		enc := appendRemainingLength(nil, n)
+		pkt := append([]byte{packetPublish}, enc...)
+		pkt = append(pkt, make([]byte, n)...)
+		_, body, err := readPacket(bufio.NewReader(bytes.NewReader(pkt)))
+		if err != nil || len(body) != n {
+			t.Fatalf("length %d round trip failed: %v (got %d)", n, err, len(body))
+		}
+	}
+}
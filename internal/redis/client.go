@@ -0,0 +1,268 @@
+// Package redis implements the subset of the Redis protocol (RESP2) needed to
+// fan out broadcasts: AUTH, SELECT, PUBLISH and SUBSCRIBE.
+package redis
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPort  = "6379"
+	replyTimeout = 10 * time.Second
+)
+
+// Error is an error reply sent by the server.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// Options describes how to reach a server. URL accepts redis:// and
+// rediss:// (TLS) with optional user:password and a /db path.
+type Options struct {
+	URL       string
+	TLSConfig *tls.Config
+}
+
+// Conn is a single server connection. Commands are serialized; a Conn
+// that has subscribed must only be used with Receive.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+// Dial connects, authenticates and selects the database named in the URL.
+func Dial(ctx context.Context, opts Options) (*Conn, error) {
+	u, err := url.Parse(opts.URL)
+	if err != nil {
+		return nil, fmt.Errorf("redis: parse url: %w", err)
+	}
+	useTLS := false
+	switch strings.ToLower(u.Scheme) {
+	case "redis", "tcp", "":
+	case "rediss":
+		useTLS = true
+	default:
+		return nil, fmt.Errorf("redis: unsupported scheme %q", u.Scheme)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	db := 0
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		db, err = strconv.Atoi(path)
+		if err != nil || db < 0 {
+			return nil, fmt.Errorf("redis: invalid database %q", path)
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if useTLS {
+		cfg := opts.TLSConfig
+		if cfg == nil {
+			cfg = &tls.Config{ServerName: u.Hostname()}
+		}
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: cfg}).DialContext(ctx, "tcp", host)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis: dial %s: %w", host, err)
+	}
+	c := &Conn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+
+	if u.User != nil {
+		// redis://user:pass@ uses ACL auth; redis://:pass@ and
+		// redis://pass@ send a bare password.
+		args := []string{"AUTH"}
+		if password, ok := u.User.Password(); ok {
+			if name := u.User.Username(); name != "" {
+				args = append(args, name)
+			}
+			args = append(args, password)
+		} else {
+			args = append(args, u.User.Username())
+		}
+		if _, err := c.Do(ctx, args...); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("redis: auth: %w", err)
+		}
+	}
+	if db != 0 {
+		if _, err := c.Do(ctx, "SELECT", strconv.Itoa(db)); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("redis: select %d: %w", db, err)
+		}
+	}
+	return c, nil
+}
+
+// Do sends a command and returns its reply: string, int64, []byte, []any or
+// nil. Server error replies are returned as Error.
+func (c *Conn) Do(ctx context.Context, args ...string) (any, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	deadline := time.Now().Add(replyTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = c.conn.SetDeadline(deadline)
+	defer c.conn.SetDeadline(time.Time{})
+
+	if err := c.write(args); err != nil {
+		return nil, err
+	}
+	return readReply(c.r)
+}
+
+// Publish posts payload to channel and returns the number of subscribers
+// that received it.
+func (c *Conn) Publish(ctx context.Context, channel string, payload []byte) (int64, error) {
+	reply, err := c.Do(ctx, "PUBLISH", channel, string(payload))
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected PUBLISH reply %T", reply)
+	}
+	return n, nil
+}
+
+// Subscribe puts the connection into subscriber mode for channels and
+// waits for every confirmation.
+func (c *Conn) Subscribe(ctx context.Context, channels ...string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if d, ok := ctx.Deadline(); ok {
+		_ = c.conn.SetDeadline(d)
+	} else {
+		_ = c.conn.SetDeadline(time.Now().Add(replyTimeout))
+	}
+	defer c.conn.SetDeadline(time.Time{})
+
+	if err := c.write(append([]string{"SUBSCRIBE"}, channels...)); err != nil {
+		return err
+	}
+	for range channels {
+		reply, err := readReply(c.r)
+		if err != nil {
+			return err
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 3 || bulkString(parts[0]) != "subscribe" {
+			return fmt.Errorf("redis: unexpected SUBSCRIBE reply %v", reply)
+		}
+	}
+	return nil
+}
+
+// Receive blocks until a message arrives on a subscribed channel.
+func (c *Conn) Receive() (channel string, payload []byte, err error) {
+	for {
+		reply, err := readReply(c.r)
+		if err != nil {
+			return "", nil, err
+		}
+		parts, ok := reply.([]any)
+		if !ok || len(parts) != 3 || bulkString(parts[0]) != "message" {
+			// Ignore subscription confirmations and pongs.
+			continue
+		}
+		data, _ := parts[2].([]byte)
+		return bulkString(parts[1]), data, nil
+	}
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+func (c *Conn) write(args []string) error {
+	c.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		c.w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n")
+		c.w.WriteString(arg)
+		c.w.WriteString("\r\n")
+	}
+	return c.w.Flush()
+}
+
+func bulkString(v any) string {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+// readReply decodes one RESP2 value.
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("redis: malformed reply")
+	}
+	kind, body := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, Error(body)
+	case ':':
+		n, err := strconv.ParseInt(body, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad integer %q", body)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad array length %q", body)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		out := make([]any, n)
+		for i := range out {
+			if out[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
@@ -0,0 +1,140 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeServer is a single-channel pub/sub broker speaking just enough RESP
+// for the client.
+type fakeServer struct {
+	ln net.Listener
+
+	mu    sync.Mutex
+	auth  []string
+	subs  []*bufio.Writer
+	subMu sync.Mutex
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := &fakeServer{ln: ln}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		parts, _ := reply.([]any)
+		if len(parts) == 0 {
+			return
+		}
+		args := make([]string, len(parts))
+		for i, p := range parts {
+			args[i] = bulkString(p)
+		}
+		s.mu.Lock()
+		switch args[0] {
+		case "AUTH":
+			s.auth = args[1:]
+			w.WriteString("+OK\r\n")
+		case "SELECT":
+			w.WriteString("+OK\r\n")
+		case "SUBSCRIBE":
+			s.subs = append(s.subs, w)
+			for i, ch := range args[1:] {
+				w.WriteString("*3\r\n$9\r\nsubscribe\r\n$" + strconv.Itoa(len(ch)) + "\r\n" + ch + "\r\n:" + strconv.Itoa(i+1) + "\r\n")
+			}
+		case "PUBLISH":
+			ch, payload := args[1], args[2]
+			s.subMu.Lock()
+			for _, sub := range s.subs {
+				sub.WriteString("*3\r\n$7\r\nmessage\r\n$" + strconv.Itoa(len(ch)) + "\r\n" + ch + "\r\n$" + strconv.Itoa(len(payload)) + "\r\n" + payload + "\r\n")
+				sub.Flush()
+			}
+			s.subMu.Unlock()
+			w.WriteString(":" + strconv.Itoa(len(s.subs)) + "\r\n")
+		default:
+			w.WriteString("-ERR unknown command\r\n")
+		}
+		s.mu.Unlock()
+		s.subMu.Lock()
+		w.Flush()
+		s.subMu.Unlock()
+	}
+}
+
+func TestPublishSubscribe(t *testing.T) {
+	srv := newFakeServer(t)
+	ctx := context.Background()
+	url := "redis://user:secret@" + srv.ln.Addr().String() + "/2"
+
+	sub, err := Dial(ctx, Options{URL: url})
+	if err != nil {
+		t.Fatalf("dial subscriber: %v", err)
+	}
+	defer sub.Close()
+	if err := sub.Subscribe(ctx, "gnasty:messages"); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+
+	pub, err := Dial(ctx, Options{URL: url})
+	if err != nil {
+		t.Fatalf("dial publisher: %v", err)
+	}
+	defer pub.Close()
+	n, err := pub.Publish(ctx, "gnasty:messages", []byte(`{"ID":"m1"}`))
+	if err != nil {
+		t.Fatalf("publish: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 receiver, got %d", n)
+	}
+
+	_ = sub.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	channel, payload, err := sub.Receive()
+	if err != nil {
+		t.Fatalf("receive: %v", err)
+	}
+	if channel != "gnasty:messages" || string(payload) != `{"ID":"m1"}` {
+		t.Fatalf("unexpected message %q %q", channel, payload)
+	}
+
+	srv.mu.Lock()
+	auth := srv.auth
+	srv.mu.Unlock()
+	if len(auth) != 2 || auth[0] != "user" || auth[1] != "secret" {
+		t.Fatalf("unexpected AUTH args %v", auth)
+	}
+
+	if _, err := pub.Do(ctx, "FLUSHALL"); err == nil {
+		t.Fatalf("expected server error reply")
+	} else if _, ok := err.(Error); !ok {
+		t.Fatalf("expected redis.Error, got %T", err)
+	}
+}
@@ -0,0 +1,180 @@
+// Package replay is a development receiver that plays back an NDJSON chat
+// log (such as the output of harvester export) as if it were arriving
+// live, so overlays, sinks and analytics can be exercised without a
+// platform connection.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// DefaultMaxGap caps the pause between two replayed messages, so quiet
+// stretches and the gaps between streams in a long log are skipped.
+const DefaultMaxGap = 5 * time.Second
+
+// maxLine bounds one NDJSON line.
+const maxLine = 1 << 20
+
+type Config struct {
+	// Path is the NDJSON file, one core.ChatMessage per line.
+	Path string
+	// Speed scales the original pacing: 1 replays in real time, 2 twice as
+	// fast. Zero or less delivers messages as fast as the handler takes
+	// them.
+	Speed float64
+	// Loop starts again from the top of the file when it ends.
+	Loop bool
+	// MaxGap caps the pause between messages; zero uses DefaultMaxGap.
+	MaxGap time.Duration
+}
+
+type Handler func(core.ChatMessage)
+
+type Source struct {
+	cfg     Config
+	handler Handler
+	now     func() time.Time
+	sleep   func(context.Context, time.Duration) error
+}
+
+func New(cfg Config, handler Handler) *Source {
+	if cfg.MaxGap <= 0 {
+		cfg.MaxGap = DefaultMaxGap
+	}
+	return &Source{cfg: cfg, handler: handler, now: time.Now, sleep: sleep}
+}
+
+// Run replays the file until it ends (or, with Loop, until ctx is
+// cancelled). Lines that are not messages are logged and skipped.
+func (s *Source) Run(ctx context.Context) error {
+	for pass := 0; ; pass++ {
+		n, err := s.play(ctx, pass)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("replay: %s holds no messages", s.cfg.Path)
+		}
+		log.Printf("replay: finished pass %d of %s (%d messages)", pass+1, s.cfg.Path, n)
+		if !s.cfg.Loop {
+			return nil
+		}
+	}
+}
+
+// play delivers every message in the file once and returns how many it
+// delivered.
+func (s *Source) play(ctx context.Context, pass int) (int, error) {
+	f, err := os.Open(s.cfg.Path)
+	if err != nil {
+		return 0, fmt.Errorf("replay: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLine)
+	var (
+		prev      time.Time
+		delivered int
+	)
+	for line := 1; scanner.Scan(); line++ {
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		var msg core.ChatMessage
+		if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+			log.Printf("replay: %s line %d: %v", s.cfg.Path, line, err)
+			continue
+		}
+		if msg.ID == "" || msg.Platform == "" {
+			log.Printf("replay: %s line %d: id and platform are required", s.cfg.Path, line)
+			continue
+		}
+		ts := originalTs(msg)
+		if delivered > 0 {
+			if err := s.sleep(ctx, s.delay(prev, ts)); err != nil {
+				return delivered, err
+			}
+		} else if err := ctx.Err(); err != nil {
+			return delivered, err
+		}
+		prev = ts
+		s.handler(s.relive(msg, pass))
+		delivered++
+	}
+	if err := scanner.Err(); err != nil {
+		return delivered, fmt.Errorf("replay: %s: %w", s.cfg.Path, err)
+	}
+	return delivered, nil
+}
+
+// delay is the pause before a message sent at ts when the previous one was
+// sent at prev. Out-of-order timestamps replay without a pause.
+func (s *Source) delay(prev, ts time.Time) time.Duration {
+	if s.cfg.Speed <= 0 || prev.IsZero() || ts.IsZero() || !ts.After(prev) {
+		return 0
+	}
+	d := time.Duration(float64(ts.Sub(prev)) / s.cfg.Speed)
+	if d > s.cfg.MaxGap {
+		d = s.cfg.MaxGap
+	}
+	return d
+}
+
+// relive turns a logged message into a fresh one: it is stamped with the
+// current time, loses the session and moderation state and the ULID it was
+// stored with, and on later passes gets a distinct ID so sinks store it
+// again.
+func (s *Source) relive(msg core.ChatMessage, pass int) core.ChatMessage {
+	now := s.now().UTC()
+	msg.Ts = now
+	msg.TimestampMS = now.UnixMilli()
+	msg.ReceivedAt = &now
+	msg.SessionID = ""
+	msg.ULID = ""
+	msg.EditedAt, msg.Edits = nil, nil
+	msg.DeletedAt, msg.DeletedBy = nil, ""
+	if pass > 0 {
+		suffix := "#" + strconv.Itoa(pass)
+		msg.ID += suffix
+		if msg.PlatformMsgID != "" {
+			msg.PlatformMsgID += suffix
+		}
+	}
+	return msg
+}
+
+func originalTs(msg core.ChatMessage) time.Time {
+	if !msg.Ts.IsZero() {
+		return msg.Ts
+	}
+	if msg.TimestampMS > 0 {
+		return time.UnixMilli(msg.TimestampMS)
+	}
+	return time.Time{}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
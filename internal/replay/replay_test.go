@@ -0,0 +1,80 @@
+package replay
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func writeLog(t *testing.T, lines ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "chat.ndjson")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+	return path
+}
+
+func TestReplayPacing(t *testing.T) {
+	path := writeLog(t,
+		`{"ID":"a","Platform":"Twitch","Channel":"alice","Username":"u","Text":"one","Ts":"2024-01-01T00:00:00Z","SessionID":"old"}`,
+		``,
+		`not json`,
+		`{"ID":"b","Platform":"Twitch","Username":"u","Text":"two","Ts":"2024-01-01T00:00:02Z"}`,
+		`{"ID":"c","Platform":"YouTube","Username":"u","Text":"three","Ts":"2024-01-01T01:00:00Z"}`,
+	)
+	var (
+		got    []core.ChatMessage
+		pauses []time.Duration
+	)
+	src := New(Config{Path: path, Speed: 2, MaxGap: 10 * time.Second}, func(msg core.ChatMessage) {
+		got = append(got, msg)
+	})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	src.now = func() time.Time { return now }
+	src.sleep = func(ctx context.Context, d time.Duration) error {
+		pauses = append(pauses, d)
+		return nil
+	}
+	if err := src.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if len(got) != 3 || got[0].ID != "a" || got[2].ID != "c" {
+		t.Fatalf("unexpected messages %+v", got)
+	}
+	if !got[0].Ts.Equal(now) || got[0].ReceivedAt == nil || got[0].SessionID != "" || got[0].Channel != "alice" {
+		t.Fatalf("expected a fresh live message, got %+v", got[0])
+	}
+	if len(pauses) != 2 || pauses[0] != time.Second || pauses[1] != 10*time.Second {
+		t.Fatalf("unexpected pauses %v", pauses)
+	}
+}
+
+func TestReplayLoop(t *testing.T) {
+	path := writeLog(t, `{"ID":"a","PlatformMsgID":"a","Platform":"Twitch","Username":"u","Text":"one"}`)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var ids []string
+	src := New(Config{Path: path, Loop: true}, func(msg core.ChatMessage) {
+		ids = append(ids, msg.ID+"/"+msg.PlatformMsgID)
+		if len(ids) == 3 {
+			cancel()
+		}
+	})
+	if err := src.Run(ctx); err != context.Canceled {
+		t.Fatalf("expected the loop to run until cancelled, got %v", err)
+	}
+	if strings.Join(ids, " ") != "a/a a#1/a#1 a#2/a#2" {
+		t.Fatalf("unexpected ids %v", ids)
+	}
+
+	empty := writeLog(t, `not json`)
+	if err := New(Config{Path: empty, Loop: true}, func(core.ChatMessage) {}).Run(context.Background()); err == nil {
+		t.Fatalf("expected an error for a log without messages")
+	}
+}
@@ -0,0 +1,61 @@
+// Package sdnotify implements the systemd service notification protocol
+// (sd_notify) and watchdog helpers without linking libsystemd.
+package sdnotify
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Well-known notification states.
+const (
+	Ready    = "READY=1"
+	Stopping = "STOPPING=1"
+	Watchdog = "WATCHDOG=1"
+)
+
+// Notify sends state to the socket named by $NOTIFY_SOCKET. It reports false
+// (and no error) when the process is not running under a notify-aware
+// service manager.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// A leading '@' denotes a Linux abstract socket.
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns the watchdog timeout configured by the service
+// manager via $WATCHDOG_USEC, or zero when the watchdog is disabled or
+// addressed to another process.
+func WatchdogInterval() time.Duration {
+	raw := os.Getenv("WATCHDOG_USEC")
+	if raw == "" {
+		return 0
+	}
+	usec, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" {
+		if n, err := strconv.Atoi(pid); err != nil || n != os.Getpid() {
+			return 0
+		}
+	}
+	return time.Duration(usec) * time.Microsecond
+}
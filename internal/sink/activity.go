@@ -0,0 +1,39 @@
+package sink
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+// MessageActivity counts matching messages per platform in fixed UTC slots
+// of the given size, skipping empty slots.
+func (s *SQLiteSink) MessageActivity(ctx context.Context, filters httpapi.Filters, slot time.Duration) ([]httpapi.ActivitySlot, error) {
+	slotMS := slot.Milliseconds()
+	if slotMS <= 0 {
+		return nil, errors.New("slot must be positive")
+	}
+	where, whereArgs := buildMessageWhere(filters)
+	args := append([]any{slotMS, slotMS}, whereArgs...)
+	rows, err := s.db.QueryContext(ctx, `SELECT (ts / ?) * ? AS slot, platform, COUNT(*) FROM messages`+where+` GROUP BY 1, 2 ORDER BY 1, 2;`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "query activity")
+	}
+	defer rows.Close()
+	var out []httpapi.ActivitySlot
+	for rows.Next() {
+		var (
+			startMS int64
+			entry   httpapi.ActivitySlot
+		)
+		if err := rows.Scan(&startMS, &entry.Platform, &entry.Messages); err != nil {
+			return nil, errors.Wrap(err, "scan activity")
+		}
+		entry.Start = time.UnixMilli(startMS).UTC()
+		out = append(out, entry)
+	}
+	return out, errors.Wrap(rows.Err(), "iterate activity")
+}
@@ -0,0 +1,140 @@
+package sink
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const autoModSchema = `CREATE TABLE IF NOT EXISTS automod_events (
+  platform TEXT NOT NULL,
+  message_id TEXT NOT NULL,
+  channel TEXT NOT NULL DEFAULT '',
+  user_id TEXT NOT NULL DEFAULT '',
+  username TEXT NOT NULL DEFAULT '',
+  text TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL,
+  reason TEXT NOT NULL DEFAULT '',
+  level INTEGER NOT NULL DEFAULT 0,
+  moderator TEXT NOT NULL DEFAULT '',
+  session_id TEXT NOT NULL DEFAULT '',
+  held_at INTEGER NOT NULL,
+  resolved_at INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (platform, message_id)
+);
+CREATE INDEX IF NOT EXISTS automod_events_held ON automod_events(platform, held_at);`
+
+// RecordAutoMod stores a held message or its resolution. The hold fixes the
+// session and hold time; a resolution only fills in the status, moderator
+// and resolution time, and a late hold never reopens a resolved message.
+func (s *SQLiteSink) RecordAutoMod(ctx context.Context, ev core.AutoModEvent) error {
+	platform := strings.TrimSpace(ev.Platform)
+	if platform == "" || ev.MessageID == "" {
+		return errors.New("automod event requires platform and message id")
+	}
+	status := ev.Status
+	if status == "" {
+		status = core.AutoModHeld
+	}
+	held := ev.HeldAt
+	if held.IsZero() {
+		held = time.Now()
+	}
+	var resolved int64
+	if !ev.ResolvedAt.IsZero() {
+		resolved = ev.ResolvedAt.UTC().UnixMilli()
+	}
+	err := withRetry(func() error {
+		_, err := s.db.ExecContext(ctx, `INSERT INTO automod_events (platform, message_id, channel, user_id, username, text, status, reason, level, moderator, session_id, held_at, resolved_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(platform, message_id) DO UPDATE SET
+  channel = CASE WHEN excluded.channel != '' THEN excluded.channel ELSE automod_events.channel END,
+  user_id = CASE WHEN excluded.user_id != '' THEN excluded.user_id ELSE automod_events.user_id END,
+  username = CASE WHEN excluded.username != '' THEN excluded.username ELSE automod_events.username END,
+  text = CASE WHEN excluded.text != '' THEN excluded.text ELSE automod_events.text END,
+  status = CASE WHEN excluded.resolved_at = 0 AND automod_events.resolved_at > 0 THEN automod_events.status ELSE excluded.status END,
+  reason = CASE WHEN excluded.reason != '' THEN excluded.reason ELSE automod_events.reason END,
+  level = CASE WHEN excluded.level > 0 THEN excluded.level ELSE automod_events.level END,
+  moderator = CASE WHEN excluded.moderator != '' THEN excluded.moderator ELSE automod_events.moderator END,
+  held_at = MIN(automod_events.held_at, excluded.held_at),
+  resolved_at = CASE WHEN excluded.resolved_at > 0 THEN excluded.resolved_at ELSE automod_events.resolved_at END;`,
+			platform, ev.MessageID, ev.Channel, ev.UserID, ev.Username, ev.Text, status, ev.Reason, ev.Level, ev.Moderator,
+			s.activeSession(platform), held.UTC().UnixMilli(), resolved)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "record automod event")
+	}
+	return nil
+}
+
+// ListAutoMod returns AutoMod events matching filters: platforms, sessions,
+// channels and a since/until bound on the hold time. statuses, when
+// non-empty, keeps only events in those states.
+func (s *SQLiteSink) ListAutoMod(ctx context.Context, filters httpapi.Filters, statuses []string) ([]httpapi.AutoModEvent, error) {
+	where, args := buildFilterWhere(httpapi.Filters{Platforms: filters.Platforms, Since: filters.Since, Until: filters.Until}, "held_at")
+	var clauses []string
+	in := func(column string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		placeholders := make([]string, 0, len(values))
+		for _, v := range values {
+			placeholders = append(placeholders, "?")
+			args = append(args, v)
+		}
+		clauses = append(clauses, column+" IN ("+strings.Join(placeholders, ",")+")")
+	}
+	in("session_id", filters.SessionIDs)
+	in("channel", filters.Channels)
+	in("status", statuses)
+	if len(clauses) > 0 {
+		if where == "" {
+			where = " WHERE " + strings.Join(clauses, " AND ")
+		} else {
+			where += " AND " + strings.Join(clauses, " AND ")
+		}
+	}
+	order := "DESC"
+	if filters.Order == httpapi.OrderAsc {
+		order = "ASC"
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, `SELECT platform, message_id, channel, user_id, username, text, status, reason, level, moderator, session_id, held_at, resolved_at
+FROM automod_events`+where+` ORDER BY held_at `+order+`, message_id `+order+` LIMIT ?;`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list automod events")
+	}
+	defer rows.Close()
+
+	var out []httpapi.AutoModEvent
+	for rows.Next() {
+		var (
+			ev                 httpapi.AutoModEvent
+			heldMS, resolvedMS int64
+		)
+		if err := rows.Scan(&ev.Platform, &ev.MessageID, &ev.Channel, &ev.UserID, &ev.Username, &ev.Text, &ev.Status, &ev.Reason, &ev.Level,
+			&ev.Moderator, &ev.SessionID, &heldMS, &resolvedMS); err != nil {
+			return nil, errors.Wrap(err, "scan automod event")
+		}
+		ev.HeldAt = time.UnixMilli(heldMS).UTC()
+		if resolvedMS > 0 {
+			resolved := time.UnixMilli(resolvedMS).UTC()
+			ev.ResolvedAt = &resolved
+		}
+		out = append(out, ev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate automod events")
+	}
+	return out, nil
+}
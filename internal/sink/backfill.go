@@ -0,0 +1,67 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// knownIDsBatch bounds the IDs looked up per query, well under SQLite's
+// variable limit.
+const knownIDsBatch = 500
+
+// KnownMessageIDs reports which of ids are already stored as platform
+// message IDs on platform, so backfilled chat can skip them rather than
+// overwrite what was captured live.
+func (s *SQLiteSink) KnownMessageIDs(ctx context.Context, platform string, ids []string) (map[string]bool, error) {
+	known := make(map[string]bool, len(ids))
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > knownIDsBatch {
+			batch = batch[:knownIDsBatch]
+		}
+		ids = ids[len(batch):]
+		args := make([]any, 0, len(batch)+1)
+		args = append(args, strings.TrimSpace(platform))
+		for _, id := range batch {
+			args = append(args, id)
+		}
+		rows, err := s.db.QueryContext(ctx, `SELECT platform_msg_id FROM messages WHERE platform = ? AND platform_msg_id IN (?`+
+			strings.Repeat(", ?", len(batch)-1)+`);`, args...)
+		if err != nil {
+			return nil, errors.Wrap(err, "known message ids")
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, errors.Wrap(err, "scan message id")
+			}
+			known[id] = true
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, errors.Wrap(err, "iterate message ids")
+		}
+	}
+	return known, nil
+}
+
+// LastMessageTime returns the platform timestamp of the newest message
+// stored for platform and channel, reporting false when there is none.
+func (s *SQLiteSink) LastMessageTime(ctx context.Context, platform, channel string) (time.Time, bool, error) {
+	var tsMS int64
+	// Walking messages_upsert_key backwards stops at the first match.
+	err := s.db.QueryRowContext(ctx, `SELECT ts FROM messages WHERE platform = ? AND channel = ? ORDER BY ts DESC LIMIT 1;`,
+		strings.TrimSpace(platform), strings.ToLower(strings.TrimSpace(channel))).Scan(&tsMS)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, errors.Wrap(err, "last message time")
+	}
+	return time.UnixMilli(tsMS).UTC(), true, nil
+}
@@ -0,0 +1,139 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+func openBenchSQLite(b *testing.B) *SQLiteSink {
+	b.Helper()
+	db, err := OpenSQLite(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("open sqlite: %v", err)
+	}
+	b.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+var benchBase = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// benchMessage returns a deterministic message spread over 500 authors and
+// both platforms, roughly matching the shape of a busy channel.
+func benchMessage(i int) core.ChatMessage {
+	platform := "Twitch"
+	if i%4 == 0 {
+		platform = "YouTube"
+	}
+	return core.ChatMessage{
+		ID:       fmt.Sprintf("bench-%d", i),
+		Ts:       benchBase.Add(time.Duration(i) * 100 * time.Millisecond),
+		Platform: platform,
+		Username: fmt.Sprintf("viewer_%03d", i%500),
+		Text:     "gg wp that was a clean play Kappa",
+		Colour:   "#1E90FF",
+	}
+}
+
+// seedBench writes n messages in batches so large fixtures load quickly.
+func seedBench(b *testing.B, db *SQLiteSink, n int) {
+	b.Helper()
+	const chunk = 1000
+	for start := 0; start < n; start += chunk {
+		end := min(start+chunk, n)
+		batch := make([]core.ChatMessage, 0, end-start)
+		for i := start; i < end; i++ {
+			batch = append(batch, benchMessage(i))
+		}
+		if err := db.WriteBatch(batch, nil); err != nil {
+			b.Fatalf("seed: %v", err)
+		}
+	}
+}
+
+func BenchmarkSQLiteWrite(b *testing.B) {
+	db := openBenchSQLite(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := db.Write(benchMessage(i), nil); err != nil {
+			b.Fatalf("write: %v", err)
+		}
+	}
+}
+
+func BenchmarkSQLiteWriteBatch(b *testing.B) {
+	for _, size := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("size=%d", size), func(b *testing.B) {
+			db := openBenchSQLite(b)
+			batch := make([]core.ChatMessage, size)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for j := range batch {
+					batch[j] = benchMessage(i*size + j)
+				}
+				if err := db.WriteBatch(batch, nil); err != nil {
+					b.Fatalf("write batch: %v", err)
+				}
+			}
+			b.ReportMetric(float64(b.N*size)/b.Elapsed().Seconds(), "msgs/s")
+		})
+	}
+}
+
+func BenchmarkSQLiteListMessages(b *testing.B) {
+	const rows = 100_000
+	db := openBenchSQLite(b)
+	seedBench(b, db, rows)
+
+	since := benchBase.Add(rows / 2 * 100 * time.Millisecond)
+	cases := []struct {
+		name    string
+		filters httpapi.Filters
+	}{
+		{"latest", httpapi.Filters{Limit: 100, Order: httpapi.OrderDesc}},
+		{"platform", httpapi.Filters{Platforms: []string{"YouTube"}, Limit: 100, Order: httpapi.OrderDesc}},
+		{"username", httpapi.Filters{Usernames: []string{"viewer_042"}, Limit: 100, Order: httpapi.OrderDesc}},
+		{"since_asc", httpapi.Filters{Since: &since, Limit: 1000, Order: httpapi.OrderAsc}},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := db.ListMessages(context.Background(), tc.filters); err != nil {
+					b.Fatalf("list: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBufferedWriterConcurrent(b *testing.B) {
+	for _, size := range []int{1, 50, 200} {
+		b.Run(fmt.Sprintf("batch=%d", size), func(b *testing.B) {
+			db := openBenchSQLite(b)
+			bw := NewBufferedWriter(db, BufferedOptions{BatchSize: size, FlushInterval: 50 * time.Millisecond})
+			var seq atomic.Int64
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := bw.Write(benchMessage(int(seq.Add(1))), nil); err != nil {
+						b.Errorf("write: %v", err)
+						return
+					}
+				}
+			})
+			if err := bw.Close(); err != nil {
+				b.Fatalf("close: %v", err)
+			}
+		})
+	}
+}
@@ -13,6 +13,12 @@ type Writer interface {
 	Write(core.ChatMessage, *ingesttrace.MessageTrace) error
 }
 
+// BatchWriter is implemented by writers that can store several messages in
+// one round trip; BufferedWriter flushes through it when available.
+type BatchWriter interface {
+	WriteBatch([]core.ChatMessage, []*ingesttrace.MessageTrace) error
+}
+
 type BufferedWriter struct {
 	base          Writer
 	batchSize     int
@@ -141,6 +147,15 @@ func (b *BufferedWriter) stopTimerLocked() {
 }
 
 func (b *BufferedWriter) writeAll(msgs []tracedMessage) error {
+	if batcher, ok := b.base.(BatchWriter); ok && len(msgs) > 1 {
+		batch := make([]core.ChatMessage, len(msgs))
+		traces := make([]*ingesttrace.MessageTrace, len(msgs))
+		for i, entry := range msgs {
+			batch[i] = entry.msg
+			traces[i] = entry.trace
+		}
+		return batcher.WriteBatch(batch, traces)
+	}
 	for _, entry := range msgs {
 		if err := b.base.Write(entry.msg, entry.trace); err != nil {
 			return err
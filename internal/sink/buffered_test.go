@@ -2,6 +2,7 @@ package sink
 
 import (
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -86,3 +87,62 @@ func TestBufferedWriterErrorPropagation(t *testing.T) {
 		t.Fatalf("expected error from underlying writer")
 	}
 }
+
+func TestTransformWriter(t *testing.T) {
+	base := &recordingWriter{}
+	upper := TransformFunc(func(msg core.ChatMessage) (core.ChatMessage, bool, error) {
+		msg.Text = strings.ToUpper(msg.Text)
+		return msg, true, nil
+	})
+	dropSpam := TransformFunc(func(msg core.ChatMessage) (core.ChatMessage, bool, error) {
+		return msg, !strings.Contains(msg.Text, "SPAM"), nil
+	})
+	broken := TransformFunc(func(msg core.ChatMessage) (core.ChatMessage, bool, error) {
+		return core.ChatMessage{}, false, fmt.Errorf("script error")
+	})
+	w := NewTransformWriter(base, upper, broken, dropSpam)
+
+	for _, text := range []string{"hello", "buy spam now"} {
+		if err := w.Write(core.ChatMessage{ID: text, Text: text}, nil); err != nil {
+			t.Fatalf("write %q: %v", text, err)
+		}
+	}
+	if base.Count() != 1 || base.messages[0].Text != "HELLO" {
+		t.Fatalf("expected only the transformed message, got %+v", base.messages)
+	}
+}
+
+func TestColourTransformer(t *testing.T) {
+	base := &recordingWriter{}
+	w := NewTransformWriter(base, ColourTransformer(true))
+	msgs := []core.ChatMessage{
+		{ID: "1", Platform: "Twitch", Username: "alice", Colour: "#1e90ff"},
+		{ID: "2", Platform: "Twitch", Username: "bob", Colour: "f0a"},
+		{ID: "3", Platform: "YouTube", Username: "Carol", UserID: "UC123"},
+		{ID: "4", Platform: "YouTube", Username: "carol", UserID: "UC123", Colour: "not-a-colour"},
+	}
+	for _, msg := range msgs {
+		if err := w.Write(msg, nil); err != nil {
+			t.Fatalf("write %s: %v", msg.ID, err)
+		}
+	}
+	got := base.messages
+	if got[0].Colour != "#1E90FF" || got[1].Colour != "#FF00AA" {
+		t.Fatalf("colours not normalized: %q %q", got[0].Colour, got[1].Colour)
+	}
+	if got[2].Colour == "" || got[2].Colour != got[3].Colour {
+		t.Fatalf("default colours should be assigned and stable: %q %q", got[2].Colour, got[3].Colour)
+	}
+	if got[2].Colour != core.DefaultColour("YouTube", "UC123", "") {
+		t.Fatalf("default colour = %q", got[2].Colour)
+	}
+
+	base = &recordingWriter{}
+	w = NewTransformWriter(base, ColourTransformer(false))
+	if err := w.Write(core.ChatMessage{ID: "5", Platform: "YouTube", Username: "dave"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if base.messages[0].Colour != "" {
+		t.Fatalf("defaults disabled, got colour %q", base.messages[0].Colour)
+	}
+}
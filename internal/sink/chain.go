@@ -0,0 +1,153 @@
+package sink
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// message_chain links every message written while the hash chain is enabled
+// to the one before it in the same chain: hash covers the message as first
+// stored plus prev_hash, so changing, removing or reordering rows is
+// detected by VerifyHashChain.
+const chainSchema = `CREATE TABLE IF NOT EXISTS message_chain (
+  message_id INTEGER PRIMARY KEY,
+  chain TEXT NOT NULL,
+  seq INTEGER NOT NULL,
+  prev_hash TEXT NOT NULL,
+  hash TEXT NOT NULL,
+  UNIQUE(chain, seq)
+);`
+
+// maxChainFailures bounds the failures listed by VerifyHashChain.
+const maxChainFailures = 100
+
+// EnableHashChain makes every newly stored message extend its session's hash
+// chain. Writes are then serialized. It must be called before messages are
+// written.
+func (s *SQLiteSink) EnableHashChain() {
+	s.hashChain = true
+}
+
+// chainName is the chain a message joins: its broadcast session, or the
+// platform when no session is active.
+func chainName(platform, sessionID string) string {
+	if sessionID != "" {
+		return sessionID
+	}
+	return "platform:" + platform
+}
+
+// chainedFields are the stored message fields covered by the hash.
+type chainedFields struct {
+	Platform        string
+	PlatformMsgID   string
+	TsMS            int64
+	Username        string
+	Text            string
+	MessageType     string
+	Channel         string
+	UserID          string
+	ChannelID       string
+	AuthorChannelID string
+}
+
+// chainHash returns the hex SHA-256 of prev and the message fields.
+func chainHash(prev string, f chainedFields) string {
+	data, _ := json.Marshal([]any{prev, f.Platform, f.PlatformMsgID, strconv.FormatInt(f.TsMS, 10), f.Username, f.Text,
+		f.MessageType, f.Channel, f.UserID, f.ChannelID, f.AuthorChannelID})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// appendChain links message rowID to the end of its chain, unless it is
+// already chained (a redelivered message). The caller serializes writes.
+func appendChain(db execer, rowID int64, chain string, f chainedFields) error {
+	var exists int
+	err := db.QueryRow(`SELECT 1 FROM message_chain WHERE message_id = ?;`, rowID).Scan(&exists)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return errors.Wrap(err, "check chain")
+	}
+	var (
+		seq  int64
+		prev string
+	)
+	err = db.QueryRow(`SELECT seq, hash FROM message_chain WHERE chain = ? ORDER BY seq DESC LIMIT 1;`, chain).Scan(&seq, &prev)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return errors.Wrap(err, "read chain head")
+	}
+	_, err = db.Exec(`INSERT INTO message_chain (message_id, chain, seq, prev_hash, hash) VALUES (?, ?, ?, ?, ?);`,
+		rowID, chain, seq+1, prev, chainHash(prev, f))
+	return errors.Wrap(err, "append chain")
+}
+
+// VerifyHashChain recomputes every chain from the stored messages. Edited
+// messages are checked against their original text. Erasing a user or
+// otherwise rewriting stored rows shows up as a failure.
+func (s *SQLiteSink) VerifyHashChain(ctx context.Context) (core.ChainReport, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT c.chain, c.seq, c.prev_hash, c.hash, m.id IS NOT NULL,
+  COALESCE(m.platform, ''), COALESCE(m.platform_msg_id, ''), COALESCE(m.ts, 0), COALESCE(m.username, ''),
+  CASE WHEN m.edited_at != 0 THEN COALESCE((SELECT e.text FROM message_edits e WHERE e.message_id = m.id ORDER BY e.id LIMIT 1), m.text)
+    ELSE COALESCE(m.text, '') END,
+  COALESCE(m.message_type, ''), COALESCE(m.channel, ''), COALESCE(m.user_id, ''), COALESCE(m.channel_id, ''), COALESCE(m.author_channel_id, '')
+FROM message_chain c LEFT JOIN messages m ON m.id = c.message_id
+ORDER BY c.chain, c.seq;`)
+	if err != nil {
+		return core.ChainReport{}, errors.Wrap(err, "verify chain")
+	}
+	defer rows.Close()
+
+	report := core.ChainReport{Chains: []core.ChainHead{}}
+	fail := func(b core.ChainBreak) {
+		if len(report.Failures) == maxChainFailures {
+			report.Truncated = true
+			return
+		}
+		report.Failures = append(report.Failures, b)
+	}
+	var head *core.ChainHead
+	for rows.Next() {
+		var (
+			chain, prev, hash string
+			seq               int64
+			found             bool
+			f                 chainedFields
+		)
+		if err := rows.Scan(&chain, &seq, &prev, &hash, &found, &f.Platform, &f.PlatformMsgID, &f.TsMS, &f.Username, &f.Text,
+			&f.MessageType, &f.Channel, &f.UserID, &f.ChannelID, &f.AuthorChannelID); err != nil {
+			return core.ChainReport{}, errors.Wrap(err, "scan chain")
+		}
+		report.Messages++
+		if head == nil || head.Chain != chain {
+			report.Chains = append(report.Chains, core.ChainHead{Chain: chain})
+			head = &report.Chains[len(report.Chains)-1]
+		}
+		switch {
+		case seq != head.Length+1:
+			fail(core.ChainBreak{Chain: chain, Seq: seq, MessageID: f.PlatformMsgID, Reason: "sequence gap"})
+		case prev != head.Head:
+			fail(core.ChainBreak{Chain: chain, Seq: seq, MessageID: f.PlatformMsgID, Reason: "link broken"})
+		case !found:
+			fail(core.ChainBreak{Chain: chain, Seq: seq, Reason: "message missing"})
+		case chainHash(prev, f) != hash:
+			fail(core.ChainBreak{Chain: chain, Seq: seq, MessageID: f.PlatformMsgID, Reason: "content changed"})
+		}
+		head.Length = seq
+		head.Head = hash
+	}
+	if err := rows.Err(); err != nil {
+		return core.ChainReport{}, errors.Wrap(err, "iterate chain")
+	}
+	report.OK = len(report.Failures) == 0
+	return report, nil
+}
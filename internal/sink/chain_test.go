@@ -0,0 +1,127 @@
+package sink
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+func TestSQLiteHashChain(t *testing.T) {
+	db := openTestSQLite(t)
+	db.EnableHashChain()
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+
+	if err := db.RecordStreamState(ctx, core.StreamState{Platform: "Twitch", Channel: "#elora", State: core.StreamLive, Ts: ts}); err != nil {
+		t.Fatalf("record live: %v", err)
+	}
+	for i, msg := range []core.ChatMessage{
+		{ID: "tw-1", Platform: "Twitch", Username: "alice", Text: "one", Ts: ts.Add(time.Second)},
+		{ID: "tw-2", Platform: "Twitch", Username: "bob", Text: "two", Ts: ts.Add(2 * time.Second)},
+		{ID: "tw-3", Platform: "Twitch", Username: "carol", Text: "three", Ts: ts.Add(3 * time.Second)},
+		// Redelivery does not extend the chain.
+		{ID: "tw-3", Platform: "Twitch", Username: "carol", Text: "three", Ts: ts.Add(3 * time.Second)},
+		{ID: "yt-1", Platform: "YouTube", Username: "dave", Text: "hi", Ts: ts.Add(4 * time.Second)},
+	} {
+		var err error
+		if i%2 == 0 {
+			err = db.Write(msg, nil)
+		} else {
+			err = db.WriteBatch([]core.ChatMessage{msg}, nil)
+		}
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	report, err := db.VerifyHashChain(ctx)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if !report.OK || report.Messages != 4 || len(report.Chains) != 2 {
+		t.Fatalf("unexpected report %+v", report)
+	}
+	if c := report.Chains[1]; c.Chain == "platform:YouTube" || report.Chains[0].Chain != "platform:YouTube" || c.Length != 3 || len(c.Head) != 64 {
+		t.Fatalf("unexpected chains %+v", report.Chains)
+	}
+
+	// Edits keep the original text, which still verifies.
+	if _, err := db.EditMessage(ctx, core.MessageUpdate{Platform: "Twitch", ID: "tw-1", Text: "one (edited)"}); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if report, err = db.VerifyHashChain(ctx); err != nil || !report.OK {
+		t.Fatalf("edited message failed verification: %+v err=%v", report, err)
+	}
+
+	if _, err := db.RawDB().Exec(`UPDATE messages SET text = 'TWO' WHERE platform_msg_id = 'tw-2';`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.RawDB().Exec(`DELETE FROM messages WHERE platform_msg_id = 'tw-3';`); err != nil {
+		t.Fatal(err)
+	}
+	report, err = db.VerifyHashChain(ctx)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if report.OK || len(report.Failures) != 2 {
+		t.Fatalf("expected two failures, got %+v", report)
+	}
+	if f := report.Failures[0]; f.MessageID != "tw-2" || f.Reason != "content changed" || f.Seq != 2 {
+		t.Fatalf("unexpected failure %+v", f)
+	}
+	if f := report.Failures[1]; f.Reason != "message missing" || f.Seq != 3 {
+		t.Fatalf("unexpected failure %+v", f)
+	}
+
+	if _, err := db.RawDB().Exec(`DELETE FROM message_chain WHERE seq = 2 AND chain != 'platform:YouTube';`); err != nil {
+		t.Fatal(err)
+	}
+	report, _ = db.VerifyHashChain(ctx)
+	if len(report.Failures) == 0 || report.Failures[0].Reason != "sequence gap" {
+		t.Fatalf("expected a sequence gap, got %+v", report.Failures)
+	}
+}
+
+func TestSQLiteReplayKeepsStoredFields(t *testing.T) {
+	db := openTestSQLite(t)
+	db.EnableHashChain()
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+
+	live := core.ChatMessage{ID: "tw-1", Platform: "Twitch", Channel: "elora", UserID: "42", ChannelID: "7", Username: "alice",
+		Text: "hello", Colour: "#FF0000", BadgesJSON: `{"badges":[{"platform":"Twitch","id":"vip","version":"1"}]}`, Ts: ts}
+	if err := db.Write(live, nil); err != nil {
+		t.Fatalf("write live: %v", err)
+	}
+	// A VOD replay of the same message carries fewer fields, a later
+	// timestamp and the colour the ColourTransformer generated.
+	replay := core.ChatMessage{ID: "tw-1", Platform: "Twitch", Username: "alice", Text: "hello", Ts: ts.Add(3 * time.Second)}
+	core.NormalizeMessageColour(&replay, true)
+	if err := db.WriteBatch([]core.ChatMessage{replay}, nil); err != nil {
+		t.Fatalf("write replay: %v", err)
+	}
+
+	got, err := db.ListMessages(ctx, httpapi.Filters{})
+	if err != nil || len(got) != 1 {
+		t.Fatalf("list: %+v (%v)", got, err)
+	}
+	if m := got[0]; m.Channel != "elora" || m.UserID != "42" || m.ChannelID != "7" || m.Colour != "#FF0000" || len(m.Badges) != 1 ||
+		!m.Ts.Equal(ts) {
+		t.Fatalf("replay overwrote stored fields: %+v", m)
+	}
+
+	// A colour the platform sent still replaces the stored one.
+	live.Colour = "#00FF00"
+	if err := db.Write(live, nil); err != nil {
+		t.Fatalf("write recoloured: %v", err)
+	}
+	if got, err = db.ListMessages(ctx, httpapi.Filters{}); err != nil || len(got) != 1 || got[0].Colour != "#00FF00" {
+		t.Fatalf("expected the platform colour to win: %+v (%v)", got, err)
+	}
+	if report, err := db.VerifyHashChain(ctx); err != nil || !report.OK {
+		t.Fatalf("replay broke the chain: %+v err=%v", report, err)
+	}
+}
@@ -0,0 +1,416 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+)
+
+// catalogFile is the catalog database inside a ChannelSQLite directory.
+const catalogFile = "catalog.db"
+
+const catalogSchema = `
+CREATE TABLE IF NOT EXISTS shards (
+  platform   TEXT NOT NULL,
+  channel    TEXT NOT NULL,
+  path       TEXT NOT NULL,
+  created_at INTEGER NOT NULL,
+  PRIMARY KEY (platform, channel)
+);`
+
+// defaultShardChannel names the shard for messages without a channel
+// (YouTube chat, which is not tied to a channel login).
+const defaultShardChannel = "_default"
+
+// ChannelShard is one per-channel database listed in the catalog.
+type ChannelShard struct {
+	Platform  string
+	Channel   string
+	Path      string
+	CreatedAt time.Time
+}
+
+// ChannelSQLite stores each platform channel's messages in its own SQLite
+// file under a data directory, listed in a catalog database. Files stay
+// small, and archiving or deleting a channel is a matter of moving or
+// removing its file: shards whose file is gone are dropped from the catalog
+// on the next start. Queries fan out to the matching shards and merge.
+type ChannelSQLite struct {
+	dir       string
+	catalog   *sql.DB
+	usernames core.UsernameNormalizer
+	onWrite   func(msg core.ChatMessage, written time.Time)
+
+	mu     sync.Mutex
+	shards map[shardKey]*SQLiteSink
+	info   map[shardKey]ChannelShard
+}
+
+type shardKey struct{ platform, channel string }
+
+// OpenChannelSQLite opens (creating if needed) the per-channel layout in dir.
+func OpenChannelSQLite(dir string) (*ChannelSQLite, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "create data dir")
+	}
+	catalog, err := sql.Open("sqlite", filepath.Join(dir, catalogFile)+"?_busy_timeout=5000&_journal_mode=wal")
+	if err != nil {
+		return nil, errors.Wrap(err, "open catalog")
+	}
+	if _, err := catalog.Exec(catalogSchema); err != nil {
+		_ = catalog.Close()
+		return nil, errors.Wrap(err, "apply catalog schema")
+	}
+	c := &ChannelSQLite{
+		dir:       dir,
+		catalog:   catalog,
+		usernames: core.DefaultUsernameNormalizer(),
+		shards:    make(map[shardKey]*SQLiteSink),
+		info:      make(map[shardKey]ChannelShard),
+	}
+	if err := c.loadCatalog(context.Background()); err != nil {
+		_ = catalog.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *ChannelSQLite) loadCatalog(ctx context.Context) error {
+	rows, err := c.catalog.QueryContext(ctx, `SELECT platform, channel, path, created_at FROM shards;`)
+	if err != nil {
+		return errors.Wrap(err, "query catalog")
+	}
+	var missing []ChannelShard
+	for rows.Next() {
+		var (
+			shard     ChannelShard
+			createdMS int64
+		)
+		if err := rows.Scan(&shard.Platform, &shard.Channel, &shard.Path, &createdMS); err != nil {
+			rows.Close()
+			return errors.Wrap(err, "scan catalog")
+		}
+		shard.CreatedAt = time.UnixMilli(createdMS).UTC()
+		if _, err := os.Stat(filepath.Join(c.dir, shard.Path)); err != nil {
+			missing = append(missing, shard)
+			continue
+		}
+		c.info[shardKey{shard.Platform, shard.Channel}] = shard
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return errors.Wrap(err, "iterate catalog")
+	}
+	rows.Close()
+	for _, shard := range missing {
+		if _, err := c.catalog.ExecContext(ctx, `DELETE FROM shards WHERE platform = ? AND channel = ?;`, shard.Platform, shard.Channel); err != nil {
+			return errors.Wrap(err, "prune catalog")
+		}
+	}
+	return nil
+}
+
+// SetUsernameNormalizer replaces the username rules of every shard,
+// including ones opened later.
+func (c *ChannelSQLite) SetUsernameNormalizer(n core.UsernameNormalizer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.usernames = n
+	for _, shard := range c.shards {
+		shard.SetUsernameNormalizer(n)
+	}
+}
+
+// SetWriteHook registers fn with every shard, including ones opened later
+// (see SQLiteSink.SetWriteHook).
+func (c *ChannelSQLite) SetWriteHook(fn func(msg core.ChatMessage, written time.Time)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onWrite = fn
+	for _, shard := range c.shards {
+		shard.SetWriteHook(fn)
+	}
+}
+
+// shardKeyFor picks the shard a message is stored in.
+func shardKeyFor(msg core.ChatMessage) shardKey {
+	channel := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(msg.Channel), "#"))
+	if channel == "" {
+		channel = defaultShardChannel
+	}
+	return shardKey{platform: strings.TrimSpace(msg.Platform), channel: channel}
+}
+
+// shardFileName maps a channel to a safe file name.
+func shardFileName(channel string) string {
+	var b strings.Builder
+	for _, r := range channel {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_', r == '.', r == '@':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return strings.TrimLeft(b.String(), ".") + ".db"
+}
+
+// shard returns the open database for key, creating and cataloguing it when
+// create is set. It returns nil when the shard does not exist.
+func (c *ChannelSQLite) shard(key shardKey, create bool) (*SQLiteSink, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if db, ok := c.shards[key]; ok {
+		return db, nil
+	}
+	info, known := c.info[key]
+	if !known {
+		if !create {
+			return nil, nil
+		}
+		platform := strings.ToLower(key.platform)
+		if platform == "" {
+			platform = "unknown"
+		}
+		info = ChannelShard{
+			Platform:  key.platform,
+			Channel:   key.channel,
+			Path:      filepath.Join(strings.TrimSuffix(shardFileName(platform), ".db"), shardFileName(key.channel)),
+			CreatedAt: time.Now().UTC(),
+		}
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(c.dir, info.Path)), 0o755); err != nil {
+			return nil, errors.Wrap(err, "create shard dir")
+		}
+	}
+	db, err := OpenSQLite(filepath.Join(c.dir, info.Path))
+	if err != nil {
+		return nil, err
+	}
+	db.SetUsernameNormalizer(c.usernames)
+	db.SetWriteHook(c.onWrite)
+	if !known {
+		if _, err := c.catalog.Exec(`INSERT OR REPLACE INTO shards (platform, channel, path, created_at) VALUES (?, ?, ?, ?);`,
+			info.Platform, info.Channel, info.Path, info.CreatedAt.UnixMilli()); err != nil {
+			_ = db.Close()
+			return nil, errors.Wrap(err, "catalog shard")
+		}
+		c.info[key] = info
+	}
+	c.shards[key] = db
+	return db, nil
+}
+
+// Shards lists the catalogued per-channel databases by platform and channel.
+func (c *ChannelSQLite) Shards() []ChannelShard {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]ChannelShard, 0, len(c.info))
+	for _, info := range c.info {
+		out = append(out, info)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Platform != out[j].Platform {
+			return out[i].Platform < out[j].Platform
+		}
+		return out[i].Channel < out[j].Channel
+	})
+	return out
+}
+
+func (c *ChannelSQLite) Write(msg core.ChatMessage, trace *ingesttrace.MessageTrace) error {
+	db, err := c.shard(shardKeyFor(msg), true)
+	if err != nil {
+		return err
+	}
+	return db.Write(msg, trace)
+}
+
+// WriteBatch groups msgs by shard and writes each group in one transaction.
+func (c *ChannelSQLite) WriteBatch(msgs []core.ChatMessage, traces []*ingesttrace.MessageTrace) error {
+	type group struct {
+		msgs   []core.ChatMessage
+		traces []*ingesttrace.MessageTrace
+	}
+	groups := make(map[shardKey]*group)
+	var order []shardKey
+	for i, msg := range msgs {
+		key := shardKeyFor(msg)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.msgs = append(g.msgs, msg)
+		if i < len(traces) {
+			g.traces = append(g.traces, traces[i])
+		} else {
+			g.traces = append(g.traces, nil)
+		}
+	}
+	for _, key := range order {
+		db, err := c.shard(key, true)
+		if err != nil {
+			return err
+		}
+		if err := db.WriteBatch(groups[key].msgs, groups[key].traces); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchingShards opens the shards that can hold messages matching filters.
+func (c *ChannelSQLite) matchingShards(filters httpapi.Filters) ([]*SQLiteSink, error) {
+	var out []*SQLiteSink
+	for _, info := range c.Shards() {
+		if len(filters.Platforms) > 0 && !containsFold(filters.Platforms, info.Platform) {
+			continue
+		}
+		if len(filters.Channels) > 0 && !containsFold(filters.Channels, info.Channel) {
+			continue
+		}
+		db, err := c.shard(shardKey{info.Platform, info.Channel}, false)
+		if err != nil {
+			return nil, err
+		}
+		if db != nil {
+			out = append(out, db)
+		}
+	}
+	return out, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// CountMessages sums the matching messages of every shard.
+func (c *ChannelSQLite) CountMessages(ctx context.Context, filters httpapi.Filters) (int64, error) {
+	shards, err := c.matchingShards(filters)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, db := range shards {
+		n, err := db.CountMessages(ctx, filters)
+		if err != nil {
+			return 0, err
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// MessageActivity concatenates the slot counts of every matching shard;
+// callers sum slots that appear more than once.
+func (c *ChannelSQLite) MessageActivity(ctx context.Context, filters httpapi.Filters, slot time.Duration) ([]httpapi.ActivitySlot, error) {
+	shards, err := c.matchingShards(filters)
+	if err != nil {
+		return nil, err
+	}
+	var out []httpapi.ActivitySlot
+	for _, db := range shards {
+		slots, err := db.MessageActivity(ctx, filters, slot)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, slots...)
+	}
+	return out, nil
+}
+
+// ListLinks asks every matching shard for up to filters.Limit links and
+// merges them by message time.
+func (c *ChannelSQLite) ListLinks(ctx context.Context, filters httpapi.Filters, domains []string) ([]httpapi.Link, error) {
+	shards, err := c.matchingShards(filters)
+	if err != nil {
+		return nil, err
+	}
+	var out []httpapi.Link
+	for _, db := range shards {
+		links, err := db.ListLinks(ctx, filters, domains)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, links...)
+	}
+	asc := filters.Order == httpapi.OrderAsc
+	sort.SliceStable(out, func(i, j int) bool {
+		return out[i].Ts.Before(out[j].Ts) == asc && !out[i].Ts.Equal(out[j].Ts)
+	})
+	if filters.Limit > 0 && len(out) > filters.Limit {
+		out = out[:filters.Limit]
+	}
+	return out, nil
+}
+
+// ListMessages asks every matching shard for up to filters.Limit messages
+// and merges them in the requested order.
+func (c *ChannelSQLite) ListMessages(ctx context.Context, filters httpapi.Filters) ([]core.ChatMessage, error) {
+	shards, err := c.matchingShards(filters)
+	if err != nil {
+		return nil, err
+	}
+	var out []core.ChatMessage
+	for _, db := range shards {
+		rows, err := db.ListMessages(ctx, filters)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rows...)
+	}
+	asc := filters.Order == httpapi.OrderAsc
+	at := func(m core.ChatMessage) time.Time {
+		if filters.ByReceived() {
+			return m.ReceivedTime()
+		}
+		return m.Ts
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if ti, tj := at(out[i]), at(out[j]); !ti.Equal(tj) {
+			return ti.Before(tj) == asc
+		}
+		return (out[i].ID < out[j].ID) == asc
+	})
+	if filters.Limit > 0 && len(out) > filters.Limit {
+		out = out[:filters.Limit]
+	}
+	return out, nil
+}
+
+func (c *ChannelSQLite) Ping() error {
+	return c.catalog.Ping()
+}
+
+func (c *ChannelSQLite) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var first error
+	for key, db := range c.shards {
+		if err := db.Close(); err != nil && first == nil {
+			first = err
+		}
+		delete(c.shards, key)
+	}
+	if err := c.catalog.Close(); err != nil && first == nil {
+		first = err
+	}
+	return first
+}
@@ -0,0 +1,93 @@
+package sink
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+func TestChannelSQLite(t *testing.T) {
+	dir := t.TempDir()
+	db, err := OpenChannelSQLite(dir)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+
+	if err := db.Write(core.ChatMessage{ID: "tw-1", Platform: "Twitch", Username: "alice", Text: "hi", Ts: ts, Channel: "Alice"}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	batch := []core.ChatMessage{
+		{ID: "tw-2", Platform: "Twitch", Username: "bob", Text: "hi", Ts: ts.Add(time.Second), Channel: "bob"},
+		{ID: "yt-1", Platform: "YouTube", Username: "carol", Text: "hi", Ts: ts.Add(2 * time.Second)},
+		{ID: "tw-3", Platform: "Twitch", Username: "dave", Text: "hi", Ts: ts.Add(3 * time.Second), Channel: "alice"},
+	}
+	if err := db.WriteBatch(batch, nil); err != nil {
+		t.Fatalf("write batch: %v", err)
+	}
+
+	shards := db.Shards()
+	if len(shards) != 3 {
+		t.Fatalf("shards = %+v, want 3", shards)
+	}
+	for _, shard := range shards {
+		if _, err := os.Stat(filepath.Join(dir, shard.Path)); err != nil {
+			t.Fatalf("shard file %s: %v", shard.Path, err)
+		}
+	}
+	if shards[0].Channel != "alice" || shards[0].Path != filepath.Join("twitch", "alice.db") {
+		t.Fatalf("first shard = %+v", shards[0])
+	}
+	if shards[2].Platform != "YouTube" || shards[2].Channel != defaultShardChannel {
+		t.Fatalf("youtube shard = %+v", shards[2])
+	}
+
+	msgs, err := db.ListMessages(ctx, httpapi.Filters{Limit: 3, Order: httpapi.OrderAsc})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(msgs) != 3 || msgs[0].ID != "tw-1" || msgs[1].ID != "tw-2" || msgs[2].ID != "yt-1" {
+		t.Fatalf("asc merge = %+v", msgs)
+	}
+	msgs, err = db.ListMessages(ctx, httpapi.Filters{Limit: 2, Order: httpapi.OrderDesc})
+	if err != nil {
+		t.Fatalf("list desc: %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].ID != "tw-3" || msgs[1].ID != "yt-1" {
+		t.Fatalf("desc merge = %+v", msgs)
+	}
+	msgs, err = db.ListMessages(ctx, httpapi.Filters{Limit: 10, Channels: []string{"alice"}})
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("channel filter = %+v err=%v", msgs, err)
+	}
+	count, err := db.CountMessages(ctx, httpapi.Filters{Platforms: []string{"Twitch"}})
+	if err != nil || count != 3 {
+		t.Fatalf("count = %d err=%v, want 3", count, err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	// Deleting a channel's file drops it from the catalog on reopen.
+	if err := os.Remove(filepath.Join(dir, "twitch", "bob.db")); err != nil {
+		t.Fatalf("remove shard: %v", err)
+	}
+	db, err = OpenChannelSQLite(dir)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if shards := db.Shards(); len(shards) != 2 {
+		t.Fatalf("shards after removal = %+v, want 2", shards)
+	}
+	count, err = db.CountMessages(ctx, httpapi.Filters{})
+	if err != nil || count != 3 {
+		t.Fatalf("count after removal = %d err=%v, want 3", count, err)
+	}
+}
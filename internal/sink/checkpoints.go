@@ -0,0 +1,62 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+const youtubeCheckpointsSchema = `CREATE TABLE IF NOT EXISTS youtube_checkpoints (
+  video_id TEXT PRIMARY KEY,
+  continuation TEXT NOT NULL,
+  api_key TEXT NOT NULL DEFAULT '',
+  client_version TEXT NOT NULL DEFAULT '',
+  updated_at INTEGER NOT NULL
+);`
+
+// SaveYouTubeCheckpoint stores cp as the latest position for its video,
+// replacing the previous one.
+func (s *SQLiteSink) SaveYouTubeCheckpoint(ctx context.Context, cp core.YouTubeCheckpoint) error {
+	videoID := strings.TrimSpace(cp.VideoID)
+	if videoID == "" || cp.Continuation == "" {
+		return errors.New("youtube checkpoint requires video id and continuation")
+	}
+	updated := cp.UpdatedAt
+	if updated.IsZero() {
+		updated = time.Now()
+	}
+	err := withRetry(func() error {
+		_, err := s.db.ExecContext(ctx, `INSERT INTO youtube_checkpoints (video_id, continuation, api_key, client_version, updated_at)
+VALUES (?, ?, ?, ?, ?)
+ON CONFLICT(video_id) DO UPDATE SET continuation = excluded.continuation, api_key = excluded.api_key,
+  client_version = excluded.client_version, updated_at = excluded.updated_at;`,
+			videoID, cp.Continuation, cp.APIKey, cp.ClientVersion, updated.UTC().UnixMilli())
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "save youtube checkpoint")
+	}
+	return nil
+}
+
+// YouTubeCheckpoint returns the stored position for videoID, reporting
+// false when there is none.
+func (s *SQLiteSink) YouTubeCheckpoint(ctx context.Context, videoID string) (core.YouTubeCheckpoint, bool, error) {
+	cp := core.YouTubeCheckpoint{VideoID: strings.TrimSpace(videoID)}
+	var updatedMS int64
+	err := s.db.QueryRowContext(ctx, `SELECT continuation, api_key, client_version, updated_at FROM youtube_checkpoints WHERE video_id = ?;`,
+		cp.VideoID).Scan(&cp.Continuation, &cp.APIKey, &cp.ClientVersion, &updatedMS)
+	if errors.Is(err, sql.ErrNoRows) {
+		return core.YouTubeCheckpoint{}, false, nil
+	}
+	if err != nil {
+		return core.YouTubeCheckpoint{}, false, errors.Wrap(err, "load youtube checkpoint")
+	}
+	cp.UpdatedAt = time.UnixMilli(updatedMS).UTC()
+	return cp, true, nil
+}
@@ -0,0 +1,61 @@
+package sink
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// DeleteMessages soft-deletes the messages del targets by stamping
+// deleted_at and deleted_by; text and metadata are kept so the archive stays
+// complete. Only messages in del.Channel are touched, and a chat clear only
+// reaches back to the start of the platform's current session. Messages
+// that are already deleted keep their first deletion. It returns the number
+// of newly deleted rows.
+func (s *SQLiteSink) DeleteMessages(ctx context.Context, del core.MessageDeletion) (int64, error) {
+	if del.Platform == "" {
+		return 0, errors.New("message deletion requires a platform")
+	}
+	deletedAt := del.DeletedAt
+	if deletedAt.IsZero() {
+		deletedAt = time.Now()
+	}
+	deletedBy := strings.TrimSpace(del.DeletedBy)
+	if deletedBy == "" {
+		deletedBy = "moderation"
+	}
+
+	conditions := []string{"platform = ?", "channel = ?", "deleted_at = 0"}
+	args := []any{deletedAt.UTC().UnixMilli(), deletedBy, del.Platform, strings.ToLower(strings.TrimSpace(del.Channel))}
+	switch {
+	case del.ID != "":
+		conditions = append(conditions, "platform_msg_id = ?")
+		args = append(args, del.ID)
+	case del.Author != "":
+		// Twitch names the author by login, YouTube by channel ID.
+		conditions = append(conditions, "(author_channel_id = ? OR username_norm = ?)", "ts <= ?")
+		args = append(args, del.Author, s.usernames.Normalize(del.Platform, del.Author), deletedAt.UTC().UnixMilli())
+	default:
+		conditions = append(conditions, "session_id = ?", "ts <= ?")
+		args = append(args, s.activeSession(del.Platform), deletedAt.UTC().UnixMilli())
+	}
+
+	query := "UPDATE messages SET deleted_at = ?, deleted_by = ? WHERE " + strings.Join(conditions, " AND ") + ";"
+	var affected int64
+	err := withRetry(func() error {
+		res, err := s.db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		affected, _ = res.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "delete messages")
+	}
+	return affected, nil
+}
@@ -0,0 +1,144 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+const editsSchema = `CREATE TABLE IF NOT EXISTS message_edits (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  message_id INTEGER NOT NULL,
+  text TEXT NOT NULL,
+  emotes_json TEXT NOT NULL DEFAULT '[]',
+  edited_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS message_edits_message ON message_edits(message_id, id);`
+
+// EditMessage stores upd as the latest version of the message it targets,
+// keeping every earlier version (including the original) in message_edits.
+// It reports false when no such message has been stored; repeating the
+// current text is a no-op.
+func (s *SQLiteSink) EditMessage(ctx context.Context, upd core.MessageUpdate) (bool, error) {
+	if upd.Platform == "" || upd.ID == "" {
+		return false, errors.New("message update requires platform and id")
+	}
+	editedAt := upd.EditedAt
+	if editedAt.IsZero() {
+		editedAt = time.Now()
+	}
+	emotes := upd.EmotesJSON
+	if emotes == "" {
+		emotes = "[]"
+	}
+
+	found := false
+	err := withRetry(func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var (
+			rowID      int64
+			text       string
+			oldEmotes  string
+			tsMS       int64
+			editedAtMS int64
+		)
+		err = tx.QueryRowContext(ctx, `SELECT id, text, emotes_json, ts, edited_at FROM messages
+WHERE platform = ? AND platform_msg_id = ?;`, upd.Platform, upd.ID).Scan(&rowID, &text, &oldEmotes, &tsMS, &editedAtMS)
+		if errors.Is(err, sql.ErrNoRows) {
+			found = false
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		found = true
+		if text == upd.Text && oldEmotes == emotes {
+			return nil
+		}
+		if editedAtMS == 0 {
+			// First edit: keep the original as version one.
+			if _, err := tx.ExecContext(ctx, `INSERT INTO message_edits (message_id, text, emotes_json, edited_at) VALUES (?, ?, ?, ?);`,
+				rowID, text, oldEmotes, tsMS); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO message_edits (message_id, text, emotes_json, edited_at) VALUES (?, ?, ?, ?);`,
+			rowID, upd.Text, emotes, editedAt.UTC().UnixMilli()); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE messages SET text = ?, emotes_json = ?, edited_at = ? WHERE id = ?;`,
+			upd.Text, emotes, editedAt.UTC().UnixMilli(), rowID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "edit message")
+	}
+	return found, nil
+}
+
+// applyEdits attaches version history to edited messages (includeEdits) or
+// swaps their text back to the original (original).
+func (s *SQLiteSink) applyEdits(ctx context.Context, msgs []core.ChatMessage, rowIDs []int64, includeEdits, original bool) error {
+	var (
+		ids   []any
+		index = map[int64]int{}
+	)
+	for i, msg := range msgs {
+		if msg.EditedAt != nil {
+			ids = append(ids, rowIDs[i])
+			index[rowIDs[i]] = i
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	query := fmt.Sprintf(`SELECT message_id, text, emotes_json, edited_at FROM message_edits
+WHERE message_id IN (%s) ORDER BY message_id, id;`, strings.TrimSuffix(strings.Repeat("?,", len(ids)), ","))
+	rows, err := s.db.QueryContext(ctx, query, ids...)
+	if err != nil {
+		return errors.Wrap(err, "list message edits")
+	}
+	defer rows.Close()
+
+	history := map[int64][]core.MessageEdit{}
+	for rows.Next() {
+		var (
+			rowID  int64
+			edit   core.MessageEdit
+			editMS int64
+		)
+		if err := rows.Scan(&rowID, &edit.Text, &edit.EmotesJSON, &editMS); err != nil {
+			return errors.Wrap(err, "scan message edit")
+		}
+		edit.EditedAt = time.UnixMilli(editMS).UTC()
+		history[rowID] = append(history[rowID], edit)
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "iterate message edits")
+	}
+
+	for rowID, edits := range history {
+		msg := &msgs[index[rowID]]
+		if includeEdits {
+			msg.Edits = edits
+		}
+		if original && len(edits) > 0 {
+			msg.Text = edits[0].Text
+			msg.EmotesJSON = edits[0].EmotesJSON
+		}
+	}
+	return nil
+}
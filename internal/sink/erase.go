@@ -0,0 +1,127 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Replacement values used when erasing by redaction.
+const (
+	redactedUsername = "deleted"
+	redactedText     = "[deleted]"
+)
+
+// EraseUser removes a chatter's data. name is matched like GetUser (user key
+// or normalized username). With redact, messages are kept for statistics but
+// their author, text and raw payloads are blanked; otherwise they are
+// deleted. The user's profile row is always deleted.
+func (s *SQLiteSink) EraseUser(ctx context.Context, platform, name string, redact bool) (int64, int64, error) {
+	platform = strings.TrimSpace(platform)
+	name = strings.TrimSpace(name)
+	if platform == "" || name == "" {
+		return 0, 0, errors.New("erase user requires platform and name")
+	}
+	norm := s.usernames.Normalize(platform, name)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "begin erase")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	keys := map[string]bool{name: true, norm: true}
+	rows, err := tx.QueryContext(ctx, `SELECT user_key FROM users WHERE platform = ? AND (user_key = ? OR username_norm = ?);`,
+		platform, name, norm)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "find users")
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return 0, 0, errors.Wrap(err, "scan user key")
+		}
+		keys[key] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, 0, errors.Wrap(err, "iterate users")
+	}
+
+	var messages, users int64
+	for key := range keys {
+		if key == "" {
+			continue
+		}
+		// Same matching as ListUserMessages.
+		match := `platform = ? AND (author_channel_id = ? OR (author_channel_id = '' AND username_norm = ?))`
+		if _, err := tx.ExecContext(ctx, `DELETE FROM links WHERE message_id IN (SELECT id FROM messages WHERE `+match+`);`,
+			platform, key, key); err != nil {
+			return 0, 0, errors.Wrap(err, "erase links")
+		}
+		// Earlier versions of edited messages carry the same text.
+		if _, err := tx.ExecContext(ctx, `DELETE FROM message_edits WHERE message_id IN (SELECT id FROM messages WHERE `+match+`);`,
+			platform, key, key); err != nil {
+			return 0, 0, errors.Wrap(err, "erase message edits")
+		}
+		// Messages held by AutoMod carry the chatter's text too.
+		if _, err := tx.ExecContext(ctx, `DELETE FROM automod_events WHERE platform = ? AND user_id = ?;`,
+			platform, key); err != nil {
+			return 0, 0, errors.Wrap(err, "erase automod events")
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM tts_queue WHERE platform = ? AND user_id = ?;`,
+			platform, key); err != nil {
+			return 0, 0, errors.Wrap(err, "erase tts queue")
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM redemptions WHERE platform = ? AND user_id = ?;`,
+			platform, key); err != nil {
+			return 0, 0, errors.Wrap(err, "erase redemptions")
+		}
+		var res sql.Result
+		if redact {
+			// Usernames get the row id appended so redacted rows stay unique
+			// under messages_upsert_key without linking them to each other.
+			res, err = tx.ExecContext(ctx, `UPDATE messages SET username = ? || '-' || id, username_norm = '', text = ?,
+author_channel_id = '', avatar_url = '', raw_json = '', emotes_json = '[]', badges_json = '[]', colour = '', edited_at = 0
+WHERE `+match+`;`, redactedUsername, redactedText, platform, key, key)
+		} else {
+			res, err = tx.ExecContext(ctx, `DELETE FROM messages WHERE `+match+`;`, platform, key, key)
+		}
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "erase messages")
+		}
+		n, _ := res.RowsAffected()
+		messages += n
+
+		res, err = tx.ExecContext(ctx, `DELETE FROM users WHERE platform = ? AND user_key = ?;`, platform, key)
+		if err != nil {
+			return 0, 0, errors.Wrap(err, "delete user")
+		}
+		n, _ = res.RowsAffected()
+		users += n
+	}
+	// Presence, AutoMod, TTS and redemption rows are keyed by login.
+	if _, err := tx.ExecContext(ctx, `DELETE FROM presence WHERE platform = ? AND username = ?;`,
+		platform, strings.ToLower(name)); err != nil {
+		return 0, 0, errors.Wrap(err, "erase presence")
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM automod_events WHERE platform = ? AND username = ?;`,
+		platform, strings.ToLower(name)); err != nil {
+		return 0, 0, errors.Wrap(err, "erase automod events")
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM tts_queue WHERE platform = ? AND username = ?;`,
+		platform, strings.ToLower(name)); err != nil {
+		return 0, 0, errors.Wrap(err, "erase tts queue")
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM redemptions WHERE platform = ? AND username = ?;`,
+		platform, strings.ToLower(name)); err != nil {
+		return 0, 0, errors.Wrap(err, "erase redemptions")
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, errors.Wrap(err, "commit erase")
+	}
+	return messages, users, nil
+}
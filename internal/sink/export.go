@@ -0,0 +1,68 @@
+package sink
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const exportBatch = 1000
+
+// ExportMessages calls fn for every message matching filters, oldest row
+// first. Limit and order are ignored; rows are read in batches so the whole
+// archive can be streamed without holding it in memory.
+func (s *SQLiteSink) ExportMessages(ctx context.Context, filters httpapi.Filters, fn func(core.ChatMessage) error) error {
+	where, args := buildMessageWhere(filters)
+	if where == "" {
+		where = " WHERE id > ?"
+	} else {
+		where += " AND id > ?"
+	}
+	query := messageSelect + where + " ORDER BY id ASC LIMIT ?;"
+
+	var after int64
+	for {
+		rows, err := s.db.QueryContext(ctx, query, append(args, after, exportBatch)...)
+		if err != nil {
+			return errors.Wrap(err, "export messages")
+		}
+		msgs, rowIDs, err := scanMessageRows(rows)
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		if filters.IncludeEdits || filters.Original {
+			if err := s.applyEdits(ctx, msgs, rowIDs, filters.IncludeEdits, filters.Original); err != nil {
+				return err
+			}
+		}
+		for _, msg := range msgs {
+			if err := fn(msg); err != nil {
+				return err
+			}
+		}
+		if len(msgs) < exportBatch {
+			return nil
+		}
+		after = rowIDs[len(rowIDs)-1]
+	}
+}
+
+// MessagesAfter returns up to limit messages stored after row id after,
+// oldest row first, with their row ids. Passing the last row id back in
+// pages through the whole archive, edits and deletions included, which
+// lets a copy resume where it stopped.
+func (s *SQLiteSink) MessagesAfter(ctx context.Context, after int64, limit int) ([]core.ChatMessage, []int64, error) {
+	if limit <= 0 {
+		limit = exportBatch
+	}
+	rows, err := s.db.QueryContext(ctx, messageSelect+" WHERE id > ? ORDER BY id ASC LIMIT ?;", after, limit)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "messages after")
+	}
+	defer rows.Close()
+	return scanMessageRows(rows)
+}
@@ -0,0 +1,54 @@
+package sink
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const leasesSchema = `CREATE TABLE IF NOT EXISTS leases (
+  name TEXT PRIMARY KEY,
+  holder TEXT NOT NULL,
+  expires_at INTEGER NOT NULL
+);`
+
+// AcquireLease claims or renews the named lease for holder until now+ttl.
+// It succeeds when the lease is free, expired, or already held by holder,
+// so several harvesters sharing a database can elect one ingester per
+// source.
+func (s *SQLiteSink) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (bool, error) {
+	if name == "" || holder == "" {
+		return false, errors.New("lease requires name and holder")
+	}
+	now := time.Now().UTC()
+	var acquired bool
+	err := withRetry(func() error {
+		res, err := s.db.ExecContext(ctx, `INSERT INTO leases (name, holder, expires_at) VALUES (?, ?, ?)
+ON CONFLICT(name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+WHERE leases.holder = excluded.holder OR leases.expires_at <= ?;`,
+			name, holder, now.Add(ttl).UnixMilli(), now.UnixMilli())
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		acquired = n > 0
+		return nil
+	})
+	if err != nil {
+		return false, errors.Wrapf(err, "acquire lease %s", name)
+	}
+	return acquired, nil
+}
+
+// ReleaseLease gives up the named lease if holder still owns it so a
+// standby can take over without waiting for expiry.
+func (s *SQLiteSink) ReleaseLease(ctx context.Context, name, holder string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM leases WHERE name = ? AND holder = ?;`, name, holder); err != nil {
+		return errors.Wrapf(err, "release lease %s", name)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+// links records every URL shared in chat, keyed to the message (messages.id)
+// it appeared in; author, channel and time are read from the message.
+const linksSchema = `CREATE TABLE IF NOT EXISTS links (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  message_id INTEGER NOT NULL,
+  url TEXT NOT NULL,
+  domain TEXT NOT NULL DEFAULT '',
+  UNIQUE(message_id, url)
+);
+CREATE INDEX IF NOT EXISTS links_domain ON links(domain);`
+
+// linkPattern finds URLs with a scheme or a "www." prefix. Bare domains are
+// skipped: chat is full of "lol.jk"-style text.
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"]+`)
+
+// extractedLink is a URL found in message text.
+type extractedLink struct {
+	url    string
+	domain string
+}
+
+// extractLinks returns the distinct URLs in text, with trailing punctuation
+// trimmed and the host lower-cased (without "www.") as the domain.
+func extractLinks(text string) []extractedLink {
+	var out []extractedLink
+	seen := make(map[string]bool)
+	for _, raw := range linkPattern.FindAllString(text, -1) {
+		raw = strings.TrimRight(raw, ".,!?;:'")
+		// Keep a closing bracket only when the URL opened one, as in
+		// Wikipedia links.
+		for _, pair := range []string{"()", "[]"} {
+			for strings.HasSuffix(raw, pair[1:]) && strings.Count(raw, pair[1:]) > strings.Count(raw, pair[:1]) {
+				raw = strings.TrimRight(raw[:len(raw)-1], ".,!?;:'")
+			}
+		}
+		target := raw
+		if strings.HasPrefix(strings.ToLower(raw), "www.") {
+			target = "http://" + raw
+		}
+		u, err := url.Parse(target)
+		if err != nil || u.Hostname() == "" || !strings.Contains(u.Hostname(), ".") {
+			continue
+		}
+		if seen[raw] {
+			continue
+		}
+		seen[raw] = true
+		out = append(out, extractedLink{url: raw, domain: normalizeDomain(u.Hostname())})
+	}
+	return out
+}
+
+// normalizeDomain lower-cases host and strips a leading "www.".
+func normalizeDomain(host string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSuffix(host, ".")), "www.")
+}
+
+// insertLinks stores links found in message rowID.
+func insertLinks(db execer, rowID int64, links []extractedLink) error {
+	for _, l := range links {
+		if _, err := db.Exec(`INSERT OR IGNORE INTO links (message_id, url, domain) VALUES (?, ?, ?);`, rowID, l.url, l.domain); err != nil {
+			return errors.Wrap(err, "insert link")
+		}
+	}
+	return nil
+}
+
+// ListLinks returns links shared in messages matching filters, which apply
+// as for ListMessages. domains, when given, keep links to those domains or
+// their subdomains.
+func (s *SQLiteSink) ListLinks(ctx context.Context, filters httpapi.Filters, domains []string) ([]httpapi.Link, error) {
+	// links shares no column names with messages besides id, so the
+	// messages filters apply unqualified.
+	where, args := buildMessageWhere(filters)
+	if len(domains) > 0 {
+		ors := make([]string, 0, len(domains))
+		for _, d := range domains {
+			ors = append(ors, "l.domain = ? OR l.domain LIKE '%.' || ?")
+			args = append(args, d, d)
+		}
+		clause := "(" + strings.Join(ors, " OR ") + ")"
+		if where == "" {
+			where = " WHERE " + clause
+		} else {
+			where += " AND " + clause
+		}
+	}
+	order := "DESC"
+	if filters.Order == httpapi.OrderAsc {
+		order = "ASC"
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	args = append(args, limit)
+	query := fmt.Sprintf(`SELECT l.id, m.platform_msg_id, m.platform, m.channel, m.username, l.url, l.domain, m.ts, m.deleted_at
+FROM links l JOIN messages m ON m.id = l.message_id%s ORDER BY %s %s, l.id %s LIMIT ?;`,
+		where, messageTimeColumn(filters), order, order)
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list links")
+	}
+	defer rows.Close()
+
+	var out []httpapi.Link
+	for rows.Next() {
+		var (
+			l             httpapi.Link
+			platformMsgID sql.NullString
+			tsMS          int64
+			deletedAtMS   int64
+		)
+		if err := rows.Scan(&l.ID, &platformMsgID, &l.Platform, &l.Channel, &l.Username, &l.URL, &l.Domain, &tsMS, &deletedAtMS); err != nil {
+			return nil, errors.Wrap(err, "scan link")
+		}
+		l.MessageID = platformMsgID.String
+		l.Ts = time.UnixMilli(tsMS).UTC()
+		l.Deleted = deletedAtMS > 0
+		out = append(out, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate links")
+	}
+	return out, nil
+}
@@ -0,0 +1,81 @@
+package sink
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+func TestExtractLinks(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want []extractedLink
+	}{
+		{"no links here lol.jk", nil},
+		{"see https://Example.com/a?b=1, and HTTP://www.Foo.org.", []extractedLink{
+			{"https://Example.com/a?b=1", "example.com"},
+			{"HTTP://www.Foo.org", "foo.org"},
+		}},
+		{"(check www.twitch.tv/elora)", []extractedLink{{"www.twitch.tv/elora", "twitch.tv"}}},
+		{"https://en.wikipedia.org/wiki/Go_(language)!", []extractedLink{{"https://en.wikipedia.org/wiki/Go_(language)", "en.wikipedia.org"}}},
+		{"dup https://a.io https://a.io", []extractedLink{{"https://a.io", "a.io"}}},
+		{"http://localhost:8080", nil},
+	} {
+		if got := extractLinks(tc.text); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("extractLinks(%q) = %+v, want %+v", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestSQLiteLinks(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+	for _, msg := range []core.ChatMessage{
+		{ID: "tw-1", Platform: "Twitch", Username: "alice", Text: "clip https://clips.twitch.tv/abc", Ts: ts, Channel: "elora"},
+		// Redelivery of the same message must not duplicate its links.
+		{ID: "tw-1", Platform: "Twitch", Username: "alice", Text: "clip https://clips.twitch.tv/abc", Ts: ts, Channel: "elora"},
+		{Platform: "YouTube", Username: "bob", Text: "buy at www.scam.example/x", Ts: ts.Add(time.Second)},
+		{ID: "tw-2", Platform: "Twitch", Username: "carol", Text: "no link", Ts: ts.Add(2 * time.Second)},
+	} {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	links, err := db.ListLinks(ctx, httpapi.Filters{Order: httpapi.OrderAsc}, nil)
+	if err != nil {
+		t.Fatalf("list links: %v", err)
+	}
+	if len(links) != 2 {
+		t.Fatalf("links = %+v", links)
+	}
+	if l := links[0]; l.MessageID != "tw-1" || l.Username != "alice" || l.Channel != "elora" || l.Domain != "clips.twitch.tv" || !l.Ts.Equal(ts) {
+		t.Fatalf("first link = %+v", l)
+	}
+	if l := links[1]; l.URL != "www.scam.example/x" || l.Domain != "scam.example" || l.Platform != "YouTube" {
+		t.Fatalf("second link = %+v", l)
+	}
+
+	links, err = db.ListLinks(ctx, httpapi.Filters{}, []string{"twitch.tv"})
+	if err != nil || len(links) != 1 || links[0].MessageID != "tw-1" {
+		t.Fatalf("subdomain filter = %+v err=%v", links, err)
+	}
+	since := ts.Add(500 * time.Millisecond)
+	links, err = db.ListLinks(ctx, httpapi.Filters{Since: &since, Platforms: []string{"YouTube"}}, nil)
+	if err != nil || len(links) != 1 || links[0].Username != "bob" {
+		t.Fatalf("since/platform filter = %+v err=%v", links, err)
+	}
+
+	if _, _, err := db.EraseUser(ctx, "YouTube", "bob", true); err != nil {
+		t.Fatalf("erase: %v", err)
+	}
+	links, err = db.ListLinks(ctx, httpapi.Filters{}, nil)
+	if err != nil || len(links) != 1 {
+		t.Fatalf("links after erase = %+v err=%v", links, err)
+	}
+}
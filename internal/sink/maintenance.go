@@ -0,0 +1,98 @@
+package sink
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// MaintenanceResult describes one Maintain pass.
+type MaintenanceResult struct {
+	WALBytesBefore int64
+	WALBytesAfter  int64
+	// Checkpoint is how long wal_checkpoint(TRUNCATE) took.
+	Checkpoint time.Duration
+	// Busy reports that readers or writers kept the checkpoint from
+	// copying every frame; the WAL is truncated on a later pass.
+	Busy bool
+	// VacuumedPages is the number of free pages returned to the
+	// filesystem by incremental_vacuum.
+	VacuumedPages int64
+}
+
+// WALSize returns the size of the write-ahead log in bytes, or zero when
+// it does not exist or the database is not file-backed.
+func (s *SQLiteSink) WALSize() int64 {
+	path := s.walPath()
+	if path == "" {
+		return 0
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+func (s *SQLiteSink) walPath() string {
+	path := strings.TrimPrefix(s.path, "file:")
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	if path == "" || path == ":memory:" {
+		return ""
+	}
+	return path + "-wal"
+}
+
+// Maintain checkpoints and truncates the WAL, refreshes planner statistics
+// with PRAGMA optimize and, when the database uses auto_vacuum=INCREMENTAL,
+// releases free pages. It is safe to call while writes continue.
+func (s *SQLiteSink) Maintain(ctx context.Context) (MaintenanceResult, error) {
+	res := MaintenanceResult{WALBytesBefore: s.WALSize()}
+
+	start := time.Now()
+	var busy, logFrames, checkpointed int64
+	if err := s.db.QueryRowContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE);`).Scan(&busy, &logFrames, &checkpointed); err != nil {
+		return res, errors.Wrap(err, "wal checkpoint")
+	}
+	res.Checkpoint = time.Since(start)
+	res.Busy = busy != 0
+	res.WALBytesAfter = s.WALSize()
+
+	if _, err := s.db.ExecContext(ctx, `PRAGMA optimize;`); err != nil {
+		return res, errors.Wrap(err, "optimize")
+	}
+
+	var autoVacuum int
+	if err := s.db.QueryRowContext(ctx, `PRAGMA auto_vacuum;`).Scan(&autoVacuum); err != nil {
+		return res, errors.Wrap(err, "read auto_vacuum")
+	}
+	if autoVacuum != 2 {
+		return res, nil
+	}
+	before, err := s.freelistCount(ctx)
+	if err != nil {
+		return res, err
+	}
+	if _, err := s.db.ExecContext(ctx, `PRAGMA incremental_vacuum;`); err != nil {
+		return res, errors.Wrap(err, "incremental vacuum")
+	}
+	after, err := s.freelistCount(ctx)
+	if err != nil {
+		return res, err
+	}
+	res.VacuumedPages = before - after
+	return res, nil
+}
+
+func (s *SQLiteSink) freelistCount(ctx context.Context) (int64, error) {
+	var n int64
+	if err := s.db.QueryRowContext(ctx, `PRAGMA freelist_count;`).Scan(&n); err != nil {
+		return 0, errors.Wrap(err, "read freelist_count")
+	}
+	return n, nil
+}
@@ -0,0 +1,106 @@
+package sink
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const markersSchema = `CREATE TABLE IF NOT EXISTS markers (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  platform TEXT NOT NULL,
+  session_id TEXT NOT NULL DEFAULT '',
+  rule TEXT NOT NULL DEFAULT '',
+  label TEXT NOT NULL DEFAULT '',
+  message_id TEXT NOT NULL DEFAULT '',
+  username TEXT NOT NULL DEFAULT '',
+  ts INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS markers_platform_ts ON markers(platform, ts);`
+
+// AddMarker stores m, tagging it with the platform's active session when m
+// does not name one, and returns its row id.
+func (s *SQLiteSink) AddMarker(ctx context.Context, m core.Marker) (int64, error) {
+	platform := strings.TrimSpace(m.Platform)
+	if platform == "" {
+		return 0, errors.New("marker requires platform")
+	}
+	ts := m.Ts
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	sessionID := m.SessionID
+	if sessionID == "" {
+		sessionID = s.activeSession(platform)
+	}
+	var id int64
+	err := withRetry(func() error {
+		res, err := s.db.ExecContext(ctx, `INSERT INTO markers (platform, session_id, rule, label, message_id, username, ts)
+VALUES (?, ?, ?, ?, ?, ?, ?);`, platform, sessionID, m.Rule, m.Label, m.MessageID, m.Username, ts.UTC().UnixMilli())
+		if err != nil {
+			return err
+		}
+		id, err = res.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "insert marker")
+	}
+	return id, nil
+}
+
+// ListMarkers returns markers matching filters: platforms, sessions and a
+// since/until bound on the marker time.
+func (s *SQLiteSink) ListMarkers(ctx context.Context, filters httpapi.Filters) ([]httpapi.Marker, error) {
+	where, args := buildFilterWhere(httpapi.Filters{Platforms: filters.Platforms, Since: filters.Since, Until: filters.Until}, "ts")
+	if len(filters.SessionIDs) > 0 {
+		placeholders := make([]string, 0, len(filters.SessionIDs))
+		for _, id := range filters.SessionIDs {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		clause := "session_id IN (" + strings.Join(placeholders, ",") + ")"
+		if where == "" {
+			where = " WHERE " + clause
+		} else {
+			where += " AND " + clause
+		}
+	}
+	order := "DESC"
+	if filters.Order == httpapi.OrderAsc {
+		order = "ASC"
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, `SELECT id, platform, session_id, rule, label, message_id, username, ts FROM markers`+where+
+		` ORDER BY ts `+order+`, id `+order+` LIMIT ?;`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list markers")
+	}
+	defer rows.Close()
+
+	var out []httpapi.Marker
+	for rows.Next() {
+		var (
+			m    httpapi.Marker
+			tsMS int64
+		)
+		if err := rows.Scan(&m.ID, &m.Platform, &m.SessionID, &m.Rule, &m.Label, &m.MessageID, &m.Username, &tsMS); err != nil {
+			return nil, errors.Wrap(err, "scan marker")
+		}
+		m.Ts = time.UnixMilli(tsMS).UTC()
+		out = append(out, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate markers")
+	}
+	return out, nil
+}
@@ -0,0 +1,91 @@
+package sink
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// MirrorDetector tags messages mirrored across platforms by a simulcast chat
+// bridge: when the same user sends the same text on another platform within
+// window of the first copy, the later copy gets MirrorOf set to the first.
+// Queries can then collapse mirrors so a simulcast is not counted twice.
+type MirrorDetector struct {
+	window time.Duration
+
+	mu     sync.Mutex
+	recent map[string]mirrorEntry
+	order  []mirrorKey
+}
+
+type mirrorEntry struct {
+	platform string
+	id       string
+	at       time.Time
+}
+
+type mirrorKey struct {
+	key string
+	at  time.Time
+}
+
+// NewMirrorDetector returns a detector matching copies up to window apart.
+func NewMirrorDetector(window time.Duration) *MirrorDetector {
+	return &MirrorDetector{window: window, recent: make(map[string]mirrorEntry)}
+}
+
+// Transform implements Transformer. Messages are never dropped.
+func (d *MirrorDetector) Transform(msg core.ChatMessage) (core.ChatMessage, bool, error) {
+	switch core.NormalizeMessageType(msg.MessageType) {
+	case core.MessageTypeChat, core.MessageTypeAction:
+	default:
+		return msg, true, nil
+	}
+	key := mirrorKeyFor(msg)
+	if key == "" || msg.MirrorOf != "" {
+		return msg, true, nil
+	}
+	at := msg.ReceivedTime()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.prune(at)
+	if prev, ok := d.recent[key]; ok && prev.platform != msg.Platform {
+		msg.MirrorOf = prev.platform + ":" + prev.id
+		return msg, true, nil
+	}
+	id := msg.PlatformMsgID
+	if id == "" {
+		id = msg.ID
+	}
+	d.recent[key] = mirrorEntry{platform: msg.Platform, id: id, at: at}
+	d.order = append(d.order, mirrorKey{key: key, at: at})
+	return msg, true, nil
+}
+
+// prune forgets originals older than the window.
+func (d *MirrorDetector) prune(now time.Time) {
+	cutoff := now.Add(-d.window)
+	n := 0
+	for n < len(d.order) && d.order[n].at.Before(cutoff) {
+		k := d.order[n]
+		if e, ok := d.recent[k.key]; ok && e.at.Equal(k.at) {
+			delete(d.recent, k.key)
+		}
+		n++
+	}
+	d.order = d.order[n:]
+}
+
+// mirrorKeyFor identifies a message by author and text, ignoring case and
+// whitespace, so copies match across platforms.
+func mirrorKeyFor(msg core.ChatMessage) string {
+	user := core.NormalizeUsernameQuery(msg.Username)
+	text := strings.ToLower(strings.Join(strings.Fields(msg.Text), " "))
+	if user == "" || text == "" {
+		return ""
+	}
+	return user + "\x00" + text
+}
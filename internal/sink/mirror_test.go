@@ -0,0 +1,77 @@
+package sink
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+func TestMirrorDetector(t *testing.T) {
+	d := NewMirrorDetector(5 * time.Second)
+	base := time.Date(2026, 10, 15, 20, 0, 0, 0, time.UTC)
+	at := func(s int) *time.Time {
+		ts := base.Add(time.Duration(s) * time.Second)
+		return &ts
+	}
+	tag := func(msg core.ChatMessage) string {
+		t.Helper()
+		out, keep, err := d.Transform(msg)
+		if err != nil || !keep {
+			t.Fatalf("transform dropped %+v: %v", msg, err)
+		}
+		return out.MirrorOf
+	}
+
+	if got := tag(core.ChatMessage{ID: "tw-1", Platform: "Twitch", Username: "Alice", Text: "gg  wp", ReceivedAt: at(0)}); got != "" {
+		t.Fatalf("first copy tagged %q", got)
+	}
+	if got := tag(core.ChatMessage{ID: "yt-1", Platform: "YouTube", Username: "alice", Text: "GG wp", ReceivedAt: at(2)}); got != "Twitch:tw-1" {
+		t.Fatalf("mirror tagged %q, want Twitch:tw-1", got)
+	}
+	// Repeats on the same platform are new messages, not mirrors.
+	if got := tag(core.ChatMessage{ID: "tw-2", Platform: "Twitch", Username: "alice", Text: "gg wp", ReceivedAt: at(3)}); got != "" {
+		t.Fatalf("same-platform repeat tagged %q", got)
+	}
+	// Other users and other text do not match.
+	if got := tag(core.ChatMessage{ID: "yt-2", Platform: "YouTube", Username: "bob", Text: "gg wp", ReceivedAt: at(4)}); got != "" {
+		t.Fatalf("other user tagged %q", got)
+	}
+	// Outside the window the copy counts on its own.
+	if got := tag(core.ChatMessage{ID: "yt-3", Platform: "YouTube", Username: "alice", Text: "gg wp", ReceivedAt: at(20)}); got != "" {
+		t.Fatalf("late copy tagged %q", got)
+	}
+	if got := tag(core.ChatMessage{ID: "tw-3", Platform: "Twitch", Username: "alice", Text: "gg wp", MessageType: core.MessageTypeRaid, ReceivedAt: at(21)}); got != "" {
+		t.Fatalf("raid tagged %q", got)
+	}
+}
+
+func TestSQLiteCollapseMirrors(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+	for _, msg := range []core.ChatMessage{
+		{ID: "tw-1", Platform: "Twitch", Username: "alice", Text: "hi", Ts: ts},
+		{ID: "yt-1", Platform: "YouTube", Username: "alice", Text: "hi", Ts: ts.Add(time.Second), MirrorOf: "Twitch:tw-1"},
+		{ID: "yt-2", Platform: "YouTube", Username: "bob", Text: "hello", Ts: ts.Add(2 * time.Second)},
+	} {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	count, err := db.CountMessages(ctx, httpapi.Filters{})
+	if err != nil || count != 3 {
+		t.Fatalf("count = %d err=%v, want 3", count, err)
+	}
+	count, err = db.CountMessages(ctx, httpapi.Filters{CollapseMirrors: true})
+	if err != nil || count != 2 {
+		t.Fatalf("collapsed count = %d err=%v, want 2", count, err)
+	}
+	msgs, err := db.ListMessages(ctx, httpapi.Filters{Limit: 10, Order: httpapi.OrderAsc})
+	if err != nil || len(msgs) != 3 || msgs[1].MirrorOf != "Twitch:tw-1" {
+		t.Fatalf("mirror tag not read back: %+v err=%v", msgs, err)
+	}
+}
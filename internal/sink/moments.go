@@ -0,0 +1,172 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const momentsSchema = `CREATE TABLE IF NOT EXISTS moments (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  session_id TEXT NOT NULL,
+  platform TEXT NOT NULL,
+  start_ts INTEGER NOT NULL,
+  end_ts INTEGER NOT NULL,
+  messages INTEGER NOT NULL,
+  peak_rate REAL NOT NULL,
+  zscore REAL NOT NULL,
+  emotes_json TEXT NOT NULL DEFAULT '[]',
+  keywords_json TEXT NOT NULL DEFAULT '[]',
+  UNIQUE (session_id, start_ts)
+);
+CREATE INDEX IF NOT EXISTS moments_start ON moments(start_ts);`
+
+// SessionMessageCounts returns per-bucket message counts for a session,
+// starting at the session start. Empty buckets are included as zero.
+func (s *SQLiteSink) SessionMessageCounts(ctx context.Context, session httpapi.Session, bucket time.Duration, until time.Time) ([]int, error) {
+	bucketMS := bucket.Milliseconds()
+	if bucketMS <= 0 {
+		return nil, errors.New("bucket must be positive")
+	}
+	startMS := session.StartedAt.UTC().UnixMilli()
+	endMS := until.UTC().UnixMilli()
+	if session.EndedAt != nil && session.EndedAt.UnixMilli() < endMS {
+		endMS = session.EndedAt.UnixMilli()
+	}
+	if endMS <= startMS {
+		return nil, nil
+	}
+	counts := make([]int, (endMS-startMS+bucketMS-1)/bucketMS)
+
+	rows, err := s.db.QueryContext(ctx, `SELECT (ts - ?) / ?, COUNT(*) FROM messages
+WHERE session_id = ? AND ts >= ? AND ts < ? GROUP BY 1;`, startMS, bucketMS, session.ID, startMS, endMS)
+	if err != nil {
+		return nil, errors.Wrap(err, "count session messages")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var idx int64
+		var n int
+		if err := rows.Scan(&idx, &n); err != nil {
+			return nil, errors.Wrap(err, "scan message counts")
+		}
+		if idx >= 0 && idx < int64(len(counts)) {
+			counts[idx] = n
+		}
+	}
+	return counts, errors.Wrap(rows.Err(), "iterate message counts")
+}
+
+// SaveMoment inserts m, or refreshes the stored moment with the same session
+// and start while a spike is still growing.
+func (s *SQLiteSink) SaveMoment(ctx context.Context, m httpapi.Moment) error {
+	emotes, err := json.Marshal(nonNilTerms(m.Emotes))
+	if err != nil {
+		return errors.Wrap(err, "encode moment emotes")
+	}
+	keywords, err := json.Marshal(nonNilTerms(m.Keywords))
+	if err != nil {
+		return errors.Wrap(err, "encode moment keywords")
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO moments (
+session_id, platform, start_ts, end_ts, messages, peak_rate, zscore, emotes_json, keywords_json
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(session_id, start_ts) DO UPDATE SET
+  end_ts = excluded.end_ts,
+  messages = excluded.messages,
+  peak_rate = excluded.peak_rate,
+  zscore = excluded.zscore,
+  emotes_json = excluded.emotes_json,
+  keywords_json = excluded.keywords_json;`,
+		m.SessionID, m.Platform, m.Start.UTC().UnixMilli(), m.End.UTC().UnixMilli(), m.Messages, m.PeakRate, m.ZScore,
+		string(emotes), string(keywords))
+	return errors.Wrap(err, "save moment")
+}
+
+// ListMoments returns detected moments, newest first unless filters ask for
+// ascending order. Since/until bound the moment start.
+func (s *SQLiteSink) ListMoments(ctx context.Context, filters httpapi.Filters) ([]httpapi.Moment, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if len(filters.Platforms) > 0 {
+		conditions = append(conditions, "platform IN ("+placeholders(len(filters.Platforms))+")")
+		for _, p := range filters.Platforms {
+			args = append(args, p)
+		}
+	}
+	if len(filters.SessionIDs) > 0 {
+		conditions = append(conditions, "session_id IN ("+placeholders(len(filters.SessionIDs))+")")
+		for _, id := range filters.SessionIDs {
+			args = append(args, id)
+		}
+	}
+	if filters.Since != nil {
+		conditions = append(conditions, "start_ts >= ?")
+		args = append(args, filters.Since.UTC().UnixMilli())
+	}
+	if filters.Until != nil {
+		conditions = append(conditions, "start_ts < ?")
+		args = append(args, filters.Until.UTC().UnixMilli())
+	}
+	query := `SELECT id, session_id, platform, start_ts, end_ts, messages, peak_rate, zscore, emotes_json, keywords_json FROM moments`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	order := "DESC"
+	if filters.Order == httpapi.OrderAsc {
+		order = "ASC"
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	query += " ORDER BY start_ts " + order + " LIMIT ?;"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list moments")
+	}
+	defer rows.Close()
+	var out []httpapi.Moment
+	for rows.Next() {
+		var (
+			m                        httpapi.Moment
+			startMS, endMS           int64
+			emotesJSON, keywordsJSON string
+		)
+		if err := rows.Scan(&m.ID, &m.SessionID, &m.Platform, &startMS, &endMS, &m.Messages, &m.PeakRate, &m.ZScore,
+			&emotesJSON, &keywordsJSON); err != nil {
+			return nil, errors.Wrap(err, "scan moment")
+		}
+		m.Start = time.UnixMilli(startMS).UTC()
+		m.End = time.UnixMilli(endMS).UTC()
+		_ = json.Unmarshal([]byte(emotesJSON), &m.Emotes)
+		_ = json.Unmarshal([]byte(keywordsJSON), &m.Keywords)
+		m.Emotes = nonNilTerms(m.Emotes)
+		m.Keywords = nonNilTerms(m.Keywords)
+		out = append(out, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate moments")
+	}
+	return out, nil
+}
+
+func nonNilTerms(terms []httpapi.MomentTerm) []httpapi.MomentTerm {
+	if terms == nil {
+		return []httpapi.MomentTerm{}
+	}
+	return terms
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
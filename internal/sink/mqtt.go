@@ -0,0 +1,228 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+	"github.com/you/gnasty-chat/internal/mqtt"
+)
+
+const (
+	defaultMQTTTopic = "gnasty/{platform}/messages"
+	mqttQueueSize    = 1024
+	mqttMaxBackoff   = 30 * time.Second
+)
+
+// MQTTOptions configures the MQTT publisher sink.
+type MQTTOptions struct {
+	URL      string
+	ClientID string
+	Username string
+	Password string
+	// Topic may contain {platform}, {username} and {id} placeholders.
+	Topic  string
+	QoS    byte
+	Retain bool
+}
+
+// MQTTPublisher publishes each written message to an MQTT broker. Writes are
+// queued and never block ingest; messages are dropped while the queue is full.
+type MQTTPublisher struct {
+	opts  MQTTOptions
+	queue chan core.ChatMessage
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	dropped int64
+}
+
+// NewMQTTPublisher starts the background publisher. The broker connection is
+// established (and re-established) asynchronously.
+func NewMQTTPublisher(opts MQTTOptions) *MQTTPublisher {
+	if strings.TrimSpace(opts.Topic) == "" {
+		opts.Topic = defaultMQTTTopic
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = "gnasty-chat"
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &MQTTPublisher{
+		opts:   opts,
+		queue:  make(chan core.ChatMessage, mqttQueueSize),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go p.run(ctx)
+	return p
+}
+
+func (p *MQTTPublisher) Write(msg core.ChatMessage, _ *ingesttrace.MessageTrace) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errors.New("mqtt publisher closed")
+	}
+	select {
+	case p.queue <- msg:
+	default:
+		p.dropped++
+		if p.dropped == 1 || p.dropped%1000 == 0 {
+			log.Printf("sink: mqtt: queue full, dropped=%d", p.dropped)
+		}
+	}
+	return nil
+}
+
+// Close stops the publisher after a best-effort flush of queued messages.
+func (p *MQTTPublisher) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		p.cancel()
+		<-p.done
+	}
+	p.cancel()
+	return nil
+}
+
+func (p *MQTTPublisher) run(ctx context.Context) {
+	defer close(p.done)
+
+	var (
+		client  *mqtt.Client
+		backoff = time.Second
+		pending *core.ChatMessage
+	)
+	defer func() {
+		if client != nil {
+			_ = client.Close()
+		}
+	}()
+
+	for {
+		if client == nil || client.Err() != nil {
+			if client != nil {
+				log.Printf("sink: mqtt: connection lost: %v", client.Err())
+			}
+			dialCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+			c, err := mqtt.Dial(dialCtx, mqtt.Options{
+				URL:      p.opts.URL,
+				ClientID: p.opts.ClientID,
+				Username: p.opts.Username,
+				Password: p.opts.Password,
+			})
+			cancel()
+			if err != nil {
+				log.Printf("sink: mqtt: %v; retrying in %s", err, backoff)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+				if backoff > mqttMaxBackoff {
+					backoff = mqttMaxBackoff
+				}
+				continue
+			}
+			client = c
+			backoff = time.Second
+			log.Printf("sink: mqtt: connected to %s", redactURL(p.opts.URL))
+		}
+
+		msg := pending
+		pending = nil
+		if msg == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-client.Done():
+				continue
+			case next, ok := <-p.queue:
+				if !ok {
+					return
+				}
+				msg = &next
+			}
+		}
+
+		payload, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("sink: mqtt: encode message: %v", err)
+			continue
+		}
+		if err := client.Publish(ctx, p.topicFor(*msg), payload, p.opts.QoS, p.opts.Retain); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("sink: mqtt: publish: %v", err)
+			pending = msg
+			_ = client.Close()
+		}
+	}
+}
+
+func (p *MQTTPublisher) topicFor(msg core.ChatMessage) string {
+	return strings.NewReplacer(
+		"{platform}", topicSegment(strings.ToLower(msg.Platform)),
+		"{username}", topicSegment(strings.ToLower(msg.Username)),
+		"{id}", topicSegment(msg.ID),
+	).Replace(p.opts.Topic)
+}
+
+// topicSegment removes characters with special meaning in MQTT topics.
+func topicSegment(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '+', '#', 0:
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+func redactURL(raw string) string {
+	if i := strings.Index(raw, "@"); i >= 0 {
+		if j := strings.Index(raw, "://"); j >= 0 && j < i {
+			return raw[:j+3] + "***@" + raw[i+1:]
+		}
+	}
+	return raw
+}
+
+// MultiWriter writes each message to every writer in order, reporting all
+// failures.
+type MultiWriter []Writer
+
+func (m MultiWriter) Write(msg core.ChatMessage, trace *ingesttrace.MessageTrace) error {
+	var errs []error
+	for _, w := range m {
+		if err := w.Write(msg, trace); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
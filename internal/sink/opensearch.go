@@ -0,0 +1,391 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+)
+
+const (
+	defaultOpenSearchIndex = "gnasty-chat"
+	openSearchQueueSize    = 4096
+	openSearchBulkSize     = 500
+	openSearchFlushEvery   = time.Second
+	openSearchMaxBackoff   = 30 * time.Second
+	// openSearchMaxAttempts bounds how often a document rejected with a
+	// retryable item status (429, 5xx) is resent before it is dropped.
+	openSearchMaxAttempts = 5
+)
+
+// OpenSearchOptions configures the OpenSearch/Elasticsearch indexing sink.
+type OpenSearchOptions struct {
+	// URL is the cluster base URL, e.g. https://search:9200.
+	URL      string
+	Username string
+	Password string
+	// Index prefixes the daily indices (<index>-YYYY.MM.DD) and names the
+	// index template installed for them.
+	Index string
+	// BulkSize caps the documents per _bulk request; FlushInterval bounds
+	// how long a partial batch waits.
+	BulkSize      int
+	FlushInterval time.Duration
+	Client        *http.Client
+}
+
+// OpenSearchIndexer indexes every written message into OpenSearch (or
+// Elasticsearch) through the _bulk API, after installing an index template
+// that maps text for full-text search and the remaining fields as keywords
+// for aggregations. Writes are queued and never block ingest; messages are
+// dropped while the queue is full. Failed bulk requests are retried with
+// backoff, and documents rejected with a retryable status are resent.
+type OpenSearchIndexer struct {
+	opts   OpenSearchOptions
+	client *http.Client
+	queue  chan core.ChatMessage
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	dropped int64
+}
+
+// openSearchDoc is the indexed form of a message. Documents use the message
+// ID as _id, so re-indexing a message (an edit or a retry) overwrites it.
+type openSearchDoc struct {
+	ID        string     `json:"id"`
+	ULID      string     `json:"ulid,omitempty"`
+	Platform  string     `json:"platform"`
+	Channel   string     `json:"channel,omitempty"`
+	ChannelID string     `json:"channel_id,omitempty"`
+	Username  string     `json:"username"`
+	UserID    string     `json:"user_id,omitempty"`
+	Text      string     `json:"text"`
+	Type      string     `json:"type"`
+	Colour    string     `json:"colour,omitempty"`
+	Badges    []string   `json:"badges,omitempty"`
+	SessionID string     `json:"session_id,omitempty"`
+	Ts        time.Time  `json:"ts"`
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	index     string
+	attempts  int
+}
+
+// NewOpenSearchIndexer starts the background indexer. The template is
+// installed before the first bulk request and retried until it succeeds.
+func NewOpenSearchIndexer(opts OpenSearchOptions) *OpenSearchIndexer {
+	opts.URL = strings.TrimRight(strings.TrimSpace(opts.URL), "/")
+	if strings.TrimSpace(opts.Index) == "" {
+		opts.Index = defaultOpenSearchIndex
+	}
+	if opts.BulkSize <= 0 {
+		opts.BulkSize = openSearchBulkSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = openSearchFlushEvery
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &OpenSearchIndexer{
+		opts:   opts,
+		client: client,
+		queue:  make(chan core.ChatMessage, openSearchQueueSize),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go s.run(ctx)
+	return s
+}
+
+func (s *OpenSearchIndexer) Write(msg core.ChatMessage, _ *ingesttrace.MessageTrace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errors.New("opensearch indexer closed")
+	}
+	select {
+	case s.queue <- msg:
+	default:
+		s.dropped++
+		if s.dropped == 1 || s.dropped%1000 == 0 {
+			log.Printf("sink: opensearch: queue full, dropped=%d", s.dropped)
+		}
+	}
+	return nil
+}
+
+// Close stops the indexer after a best-effort flush of queued messages.
+func (s *OpenSearchIndexer) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+	case <-time.After(10 * time.Second):
+		s.cancel()
+		<-s.done
+	}
+	s.cancel()
+	return nil
+}
+
+func (s *OpenSearchIndexer) run(ctx context.Context) {
+	defer close(s.done)
+
+	var (
+		batch    []openSearchDoc
+		template bool
+		backoff  = time.Second
+		draining bool
+	)
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		full := len(batch) >= s.opts.BulkSize
+		if !full && !draining {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-s.queue:
+				if !ok {
+					draining = true
+					break
+				}
+				batch = append(batch, s.document(msg))
+				continue
+			case <-ticker.C:
+			}
+		}
+		if len(batch) == 0 {
+			if draining {
+				return
+			}
+			continue
+		}
+
+		var err error
+		if !template {
+			if err = s.putTemplate(ctx); err == nil {
+				template = true
+			}
+		}
+		if err == nil {
+			n := min(len(batch), s.opts.BulkSize)
+			var retry []openSearchDoc
+			retry, err = s.bulk(ctx, batch[:n])
+			batch = append(retry, batch[n:]...)
+		}
+		if err == nil {
+			backoff = time.Second
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("sink: opensearch: %v; retrying in %s", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > openSearchMaxBackoff {
+			backoff = openSearchMaxBackoff
+		}
+	}
+}
+
+func (s *OpenSearchIndexer) document(msg core.ChatMessage) openSearchDoc {
+	ts := msg.Ts.UTC()
+	doc := openSearchDoc{
+		ID:        msg.ID,
+		ULID:      msg.ULID,
+		Platform:  msg.Platform,
+		Channel:   msg.Channel,
+		ChannelID: msg.ChannelID,
+		Username:  msg.Username,
+		UserID:    msg.UserID,
+		Text:      msg.Text,
+		Type:      msg.MessageType,
+		Colour:    msg.Colour,
+		SessionID: msg.SessionID,
+		Ts:        ts,
+		EditedAt:  msg.EditedAt,
+		DeletedAt: msg.DeletedAt,
+		index:     fmt.Sprintf("%s-%s", s.opts.Index, ts.Format("2006.01.02")),
+	}
+	if doc.Type == "" {
+		doc.Type = core.MessageTypeChat
+	}
+	for _, badge := range msg.Badges {
+		if badge.ID != "" {
+			doc.Badges = append(doc.Badges, badge.ID)
+		}
+	}
+	return doc
+}
+
+// openSearchTemplate maps the daily indices: text is analysed for
+// full-text search and everything else is a keyword or date so it can be
+// filtered and aggregated on.
+func (s *OpenSearchIndexer) openSearchTemplate() map[string]any {
+	keyword := map[string]any{"type": "keyword"}
+	date := map[string]any{"type": "date"}
+	return map[string]any{
+		"index_patterns": []string{s.opts.Index + "-*"},
+		"template": map[string]any{
+			"mappings": map[string]any{
+				"dynamic": false,
+				"properties": map[string]any{
+					"id":         keyword,
+					"ulid":       keyword,
+					"platform":   keyword,
+					"channel":    keyword,
+					"channel_id": keyword,
+					"username":   keyword,
+					"user_id":    keyword,
+					"text":       map[string]any{"type": "text"},
+					"type":       keyword,
+					"colour":     keyword,
+					"badges":     keyword,
+					"session_id": keyword,
+					"ts":         date,
+					"edited_at":  date,
+					"deleted_at": date,
+				},
+			},
+		},
+	}
+}
+
+// putTemplate installs (or updates) the composable index template.
+func (s *OpenSearchIndexer) putTemplate(ctx context.Context) error {
+	body, err := json.Marshal(s.openSearchTemplate())
+	if err != nil {
+		return errors.Wrap(err, "encode index template")
+	}
+	resp, err := s.do(ctx, http.MethodPut, "/_index_template/"+s.opts.Index, "application/json", body)
+	if err != nil {
+		return errors.Wrap(err, "put index template")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("put index template: %s", responseError(resp))
+	}
+	return nil
+}
+
+// bulk indexes docs and returns the ones to send again: all of them when
+// the request itself failed retryably, otherwise those rejected with a
+// retryable item status that have attempts left. Other rejections (mapping
+// errors and the like) are logged and dropped.
+func (s *OpenSearchIndexer) bulk(ctx context.Context, docs []openSearchDoc) ([]openSearchDoc, error) {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, doc := range docs {
+		action := map[string]any{"index": map[string]string{"_index": doc.index, "_id": doc.ID}}
+		if err := enc.Encode(action); err != nil {
+			return nil, errors.Wrap(err, "encode bulk action")
+		}
+		if err := enc.Encode(doc); err != nil {
+			return nil, errors.Wrap(err, "encode document")
+		}
+	}
+	resp, err := s.do(ctx, http.MethodPost, "/_bulk", "application/x-ndjson", body.Bytes())
+	if err != nil {
+		return docs, errors.Wrap(err, "bulk")
+	}
+	defer resp.Body.Close()
+	if retryableStatus(resp.StatusCode) {
+		return docs, errors.Errorf("bulk: %s", responseError(resp))
+	}
+	if resp.StatusCode/100 != 2 {
+		log.Printf("sink: opensearch: bulk rejected, dropped=%d: %s", len(docs), responseError(resp))
+		return nil, nil
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return docs, errors.Wrap(err, "decode bulk response")
+	}
+	if !result.Errors {
+		return nil, nil
+	}
+	var retry []openSearchDoc
+	for i, item := range result.Items {
+		if i >= len(docs) {
+			break
+		}
+		for _, res := range item {
+			if res.Status/100 == 2 {
+				continue
+			}
+			doc := docs[i]
+			doc.attempts++
+			if retryableStatus(res.Status) && doc.attempts < openSearchMaxAttempts {
+				retry = append(retry, doc)
+				continue
+			}
+			log.Printf("sink: opensearch: dropped message %s: %d %s: %s", doc.ID, res.Status, res.Error.Type, res.Error.Reason)
+		}
+	}
+	if len(retry) > 0 {
+		return retry, errors.Errorf("bulk: %d documents rejected with a retryable status", len(retry))
+	}
+	return nil, nil
+}
+
+func (s *OpenSearchIndexer) do(ctx context.Context, method, path, contentType string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.opts.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if s.opts.Username != "" || s.opts.Password != "" {
+		req.SetBasicAuth(s.opts.Username, s.opts.Password)
+	}
+	return s.client.Do(req)
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+func responseError(resp *http.Response) string {
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return strings.TrimSpace(fmt.Sprintf("%s %s", resp.Status, snippet))
+}
@@ -0,0 +1,139 @@
+package sink
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// fakeOpenSearch accepts an index template and _bulk requests, rejecting
+// the first attempt at every document whose text contains "busy" with 429.
+type fakeOpenSearch struct {
+	mu       sync.Mutex
+	template map[string]any
+	indexed  map[string]map[string]any
+	indices  map[string]string
+	busy     map[string]bool
+	auth     string
+}
+
+func (f *fakeOpenSearch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	user, pass, _ := r.BasicAuth()
+	f.auth = user + ":" + pass
+	switch {
+	case r.Method == http.MethodPut && r.URL.Path == "/_index_template/chat":
+		_ = json.NewDecoder(r.Body).Decode(&f.template)
+		fmt.Fprint(w, `{"acknowledged":true}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/_bulk":
+		if f.template == nil {
+			http.Error(w, "no template", http.StatusBadRequest)
+			return
+		}
+		var items []string
+		errs := false
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			var action struct {
+				Index struct {
+					Index string `json:"_index"`
+					ID    string `json:"_id"`
+				} `json:"index"`
+			}
+			_ = json.Unmarshal(sc.Bytes(), &action)
+			sc.Scan()
+			var doc map[string]any
+			_ = json.Unmarshal(sc.Bytes(), &doc)
+			id := action.Index.ID
+			if strings.Contains(doc["text"].(string), "busy") && !f.busy[id] {
+				f.busy[id] = true
+				errs = true
+				items = append(items, `{"index":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"busy"}}}`)
+				continue
+			}
+			f.indexed[id] = doc
+			f.indices[id] = action.Index.Index
+			items = append(items, `{"index":{"status":201}}`)
+		}
+		fmt.Fprintf(w, `{"errors":%t,"items":[%s]}`, errs, strings.Join(items, ","))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestOpenSearchIndexer(t *testing.T) {
+	fake := &fakeOpenSearch{indexed: map[string]map[string]any{}, indices: map[string]string{}, busy: map[string]bool{}}
+	ts := httptest.NewServer(fake)
+	defer ts.Close()
+
+	idx := NewOpenSearchIndexer(OpenSearchOptions{
+		URL:           ts.URL + "/",
+		Username:      "gnasty",
+		Password:      "secret",
+		Index:         "chat",
+		BulkSize:      2,
+		FlushInterval: 10 * time.Millisecond,
+	})
+	day := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	msgs := []core.ChatMessage{
+		{ID: "m1", Platform: "Twitch", Channel: "elora", Username: "alice", Text: "hello", Ts: day, Badges: []core.ChatBadge{{ID: "subscriber"}}},
+		{ID: "m2", Platform: "YouTube", Username: "bob", Text: "busy cluster", Ts: day.Add(time.Hour), MessageType: core.MessageTypeSuperchat},
+		{ID: "m3", Platform: "Twitch", Username: "carol", Text: "late", Ts: day.Add(13 * time.Hour)},
+	}
+	for _, msg := range msgs {
+		if err := idx.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		fake.mu.Lock()
+		n := len(fake.indexed)
+		fake.mu.Unlock()
+		if n == len(msgs) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("indexed %d documents, want %d", n, len(msgs))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := idx.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if err := idx.Write(msgs[0], nil); err == nil {
+		t.Fatalf("expected write after close to fail")
+	}
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.auth != "gnasty:secret" {
+		t.Fatalf("auth = %q", fake.auth)
+	}
+	patterns, _ := fake.template["index_patterns"].([]any)
+	if len(patterns) != 1 || patterns[0] != "chat-*" {
+		t.Fatalf("template patterns = %v", fake.template["index_patterns"])
+	}
+	if !fake.busy["m2"] || fake.indexed["m2"]["type"] != core.MessageTypeSuperchat {
+		t.Fatalf("m2 not retried: %v", fake.indexed["m2"])
+	}
+	if fake.indexed["m1"]["type"] != core.MessageTypeChat || fake.indexed["m1"]["channel"] != "elora" {
+		t.Fatalf("m1 = %v", fake.indexed["m1"])
+	}
+	if badges, _ := fake.indexed["m1"]["badges"].([]any); len(badges) != 1 || badges[0] != "subscriber" {
+		t.Fatalf("m1 badges = %v", fake.indexed["m1"]["badges"])
+	}
+	if fake.indices["m1"] != "chat-2026.10.15" || fake.indices["m3"] != "chat-2026.10.16" {
+		t.Fatalf("indices = %v", fake.indices)
+	}
+}
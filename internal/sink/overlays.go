@@ -0,0 +1,85 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const overlaySchema = `CREATE TABLE IF NOT EXISTS overlay_configs (
+  key TEXT PRIMARY KEY,
+  config_json TEXT NOT NULL,
+  updated_at INTEGER NOT NULL
+);`
+
+func (s *SQLiteSink) ListOverlays(ctx context.Context) ([]httpapi.OverlayConfig, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT config_json FROM overlay_configs ORDER BY key;`)
+	if err != nil {
+		return nil, errors.Wrap(err, "list overlays")
+	}
+	defer rows.Close()
+
+	var out []httpapi.OverlayConfig
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, errors.Wrap(err, "scan overlay")
+		}
+		var cfg httpapi.OverlayConfig
+		if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+			return nil, errors.Wrap(err, "decode overlay")
+		}
+		out = append(out, cfg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate overlays")
+	}
+	return out, nil
+}
+
+func (s *SQLiteSink) GetOverlay(ctx context.Context, key string) (httpapi.OverlayConfig, bool, error) {
+	var raw string
+	err := s.db.QueryRowContext(ctx, `SELECT config_json FROM overlay_configs WHERE key = ?;`, key).Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return httpapi.OverlayConfig{}, false, nil
+	}
+	if err != nil {
+		return httpapi.OverlayConfig{}, false, errors.Wrap(err, "get overlay")
+	}
+	var cfg httpapi.OverlayConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return httpapi.OverlayConfig{}, false, errors.Wrap(err, "decode overlay")
+	}
+	return cfg, true, nil
+}
+
+func (s *SQLiteSink) PutOverlay(ctx context.Context, cfg httpapi.OverlayConfig) error {
+	if cfg.UpdatedAt.IsZero() {
+		cfg.UpdatedAt = time.Now().UTC()
+	}
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return errors.Wrap(err, "encode overlay")
+	}
+	_, err = s.db.ExecContext(ctx, `INSERT INTO overlay_configs (key, config_json, updated_at) VALUES (?, ?, ?)
+ON CONFLICT(key) DO UPDATE SET config_json = excluded.config_json, updated_at = excluded.updated_at;`,
+		cfg.Key, string(data), cfg.UpdatedAt.UnixMilli())
+	return errors.Wrap(err, "put overlay")
+}
+
+func (s *SQLiteSink) DeleteOverlay(ctx context.Context, key string) (bool, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM overlay_configs WHERE key = ?;`, key)
+	if err != nil {
+		return false, errors.Wrap(err, "delete overlay")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "delete overlay")
+	}
+	return n > 0, nil
+}
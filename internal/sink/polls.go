@@ -0,0 +1,149 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const pollsSchema = `CREATE TABLE IF NOT EXISTS polls (
+  platform TEXT NOT NULL,
+  poll_id TEXT NOT NULL,
+  channel TEXT NOT NULL DEFAULT '',
+  kind TEXT NOT NULL DEFAULT 'poll',
+  title TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT '',
+  options_json TEXT NOT NULL DEFAULT '[]',
+  winning_option_id TEXT NOT NULL DEFAULT '',
+  session_id TEXT NOT NULL DEFAULT '',
+  started_at INTEGER NOT NULL,
+  ended_at INTEGER NOT NULL DEFAULT 0,
+  updated_at INTEGER NOT NULL,
+  PRIMARY KEY (platform, poll_id)
+);
+CREATE INDEX IF NOT EXISTS polls_platform_started ON polls(platform, started_at);`
+
+// RecordPoll stores the latest state of p. The first report fixes the
+// session and start time; later reports replace status and results, except
+// that a late progress update never reopens an ended poll.
+func (s *SQLiteSink) RecordPoll(ctx context.Context, p core.Poll) error {
+	platform := strings.TrimSpace(p.Platform)
+	if platform == "" || p.ID == "" {
+		return errors.New("poll requires platform and id")
+	}
+	now := time.Now().UTC()
+	started := p.StartedAt
+	if started.IsZero() {
+		started = now
+	}
+	var ended int64
+	switch {
+	case !p.EndedAt.IsZero():
+		ended = p.EndedAt.UTC().UnixMilli()
+	case p.Status != "" && p.Status != "active" && p.Status != "locked":
+		ended = now.UnixMilli()
+	}
+	options := p.Options
+	if options == nil {
+		options = []core.PollOption{}
+	}
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return errors.Wrap(err, "encode poll options")
+	}
+	kind := p.Kind
+	if kind == "" {
+		kind = core.PollKindPoll
+	}
+	err = withRetry(func() error {
+		_, err := s.db.ExecContext(ctx, `INSERT INTO polls (platform, poll_id, channel, kind, title, status, options_json, winning_option_id, session_id, started_at, ended_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(platform, poll_id) DO UPDATE SET
+  channel = CASE WHEN excluded.channel != '' THEN excluded.channel ELSE polls.channel END,
+  title = CASE WHEN excluded.title != '' THEN excluded.title ELSE polls.title END,
+  status = CASE WHEN polls.ended_at > 0 AND excluded.ended_at = 0 THEN polls.status ELSE excluded.status END,
+  options_json = CASE WHEN polls.ended_at > 0 AND excluded.ended_at = 0 THEN polls.options_json
+    WHEN excluded.options_json != '[]' THEN excluded.options_json ELSE polls.options_json END,
+  winning_option_id = CASE WHEN excluded.winning_option_id != '' THEN excluded.winning_option_id ELSE polls.winning_option_id END,
+  started_at = MIN(polls.started_at, excluded.started_at),
+  ended_at = CASE WHEN excluded.ended_at > 0 THEN excluded.ended_at ELSE polls.ended_at END,
+  updated_at = excluded.updated_at;`,
+			platform, p.ID, p.Channel, kind, p.Title, p.Status, string(optionsJSON), p.WinningOptionID,
+			s.activeSession(platform), started.UTC().UnixMilli(), ended, now.UnixMilli())
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "upsert poll")
+	}
+	return nil
+}
+
+// ListPolls returns polls matching filters: platforms, sessions and a
+// since/until bound on the start time.
+func (s *SQLiteSink) ListPolls(ctx context.Context, filters httpapi.Filters) ([]httpapi.Poll, error) {
+	where, args := buildFilterWhere(httpapi.Filters{Platforms: filters.Platforms, Since: filters.Since, Until: filters.Until}, "started_at")
+	if len(filters.SessionIDs) > 0 {
+		placeholders := make([]string, 0, len(filters.SessionIDs))
+		for _, id := range filters.SessionIDs {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		clause := "session_id IN (" + strings.Join(placeholders, ",") + ")"
+		if where == "" {
+			where = " WHERE " + clause
+		} else {
+			where += " AND " + clause
+		}
+	}
+	order := "DESC"
+	if filters.Order == httpapi.OrderAsc {
+		order = "ASC"
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, `SELECT platform, poll_id, channel, kind, title, status, options_json, winning_option_id, session_id, started_at, ended_at, updated_at
+FROM polls`+where+` ORDER BY started_at `+order+`, poll_id `+order+` LIMIT ?;`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list polls")
+	}
+	defer rows.Close()
+
+	var out []httpapi.Poll
+	for rows.Next() {
+		var (
+			p                        httpapi.Poll
+			optionsJSON              string
+			startedMS, endedMS, upMS int64
+		)
+		if err := rows.Scan(&p.Platform, &p.ID, &p.Channel, &p.Kind, &p.Title, &p.Status, &optionsJSON, &p.WinningOptionID, &p.SessionID,
+			&startedMS, &endedMS, &upMS); err != nil {
+			return nil, errors.Wrap(err, "scan poll")
+		}
+		if err := json.Unmarshal([]byte(optionsJSON), &p.Options); err != nil {
+			return nil, errors.Wrapf(err, "decode options of poll %s", p.ID)
+		}
+		if p.Options == nil {
+			p.Options = []core.PollOption{}
+		}
+		p.StartedAt = time.UnixMilli(startedMS).UTC()
+		if endedMS > 0 {
+			ended := time.UnixMilli(endedMS).UTC()
+			p.EndedAt = &ended
+		}
+		p.UpdatedAt = time.UnixMilli(upMS).UTC()
+		out = append(out, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate polls")
+	}
+	return out, nil
+}
@@ -0,0 +1,116 @@
+package sink
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+// presenceSchema stores one row per stretch of a chatter being in a
+// channel; left_at stays 0 while the chatter is present.
+const presenceSchema = `CREATE TABLE IF NOT EXISTS presence (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  platform TEXT NOT NULL,
+  channel TEXT NOT NULL,
+  username TEXT NOT NULL,
+  joined_at INTEGER NOT NULL,
+  left_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS presence_channel_joined ON presence(platform, channel, joined_at);
+CREATE INDEX IF NOT EXISTS presence_open ON presence(platform, channel, username, left_at);`
+
+// RecordPresence opens an interval when p is a join and closes the open one
+// when it is a part. Repeated joins and parts without a matching open
+// interval are ignored.
+func (s *SQLiteSink) RecordPresence(ctx context.Context, p core.Presence) error {
+	platform := strings.TrimSpace(p.Platform)
+	channel := strings.ToLower(strings.TrimSpace(p.Channel))
+	username := strings.ToLower(strings.TrimSpace(p.Username))
+	if platform == "" || channel == "" || username == "" {
+		return errors.New("presence requires platform, channel and username")
+	}
+	ts := p.Ts
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	tsMS := ts.UTC().UnixMilli()
+	err := withRetry(func() error {
+		if p.Joined {
+			_, err := s.db.ExecContext(ctx, `INSERT INTO presence (platform, channel, username, joined_at)
+SELECT ?, ?, ?, ? WHERE NOT EXISTS (
+  SELECT 1 FROM presence WHERE platform = ? AND channel = ? AND username = ? AND left_at = 0);`,
+				platform, channel, username, tsMS, platform, channel, username)
+			return err
+		}
+		_, err := s.db.ExecContext(ctx, `UPDATE presence SET left_at = MAX(?, joined_at)
+WHERE platform = ? AND channel = ? AND username = ? AND left_at = 0;`, tsMS, platform, channel, username)
+		return err
+	})
+	return errors.Wrap(err, "record presence")
+}
+
+// ClosePresence ends every open interval on platform at the given time. It
+// is called when the harvester starts, since nobody is known to be present
+// until the platform reports joins again.
+func (s *SQLiteSink) ClosePresence(ctx context.Context, platform string, at time.Time) (int64, error) {
+	var n int64
+	err := withRetry(func() error {
+		res, err := s.db.ExecContext(ctx, `UPDATE presence SET left_at = MAX(?, joined_at) WHERE platform = ? AND left_at = 0;`,
+			at.UTC().UnixMilli(), platform)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "close presence")
+	}
+	return n, nil
+}
+
+// ListPresence returns the intervals of channel that cover at, i.e. who was
+// in chat at that moment, ordered by username.
+func (s *SQLiteSink) ListPresence(ctx context.Context, platforms []string, channel string, at time.Time) ([]httpapi.PresenceInterval, error) {
+	where, args := buildFilterWhere(httpapi.Filters{Platforms: platforms}, "joined_at")
+	atMS := at.UTC().UnixMilli()
+	clause := "channel = ? AND joined_at <= ? AND (left_at = 0 OR left_at > ?)"
+	args = append(args, strings.ToLower(strings.TrimSpace(channel)), atMS, atMS)
+	if where == "" {
+		where = " WHERE " + clause
+	} else {
+		where += " AND " + clause
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT platform, channel, username, joined_at, left_at FROM presence`+where+
+		` ORDER BY username ASC;`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list presence")
+	}
+	defer rows.Close()
+
+	var out []httpapi.PresenceInterval
+	for rows.Next() {
+		var (
+			p                httpapi.PresenceInterval
+			joinedMS, leftMS int64
+		)
+		if err := rows.Scan(&p.Platform, &p.Channel, &p.Username, &joinedMS, &leftMS); err != nil {
+			return nil, errors.Wrap(err, "scan presence")
+		}
+		p.JoinedAt = time.UnixMilli(joinedMS).UTC()
+		if leftMS > 0 {
+			left := time.UnixMilli(leftMS).UTC()
+			p.LeftAt = &left
+		}
+		out = append(out, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate presence")
+	}
+	return out, nil
+}
@@ -0,0 +1,136 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const eventsSchema = `CREATE TABLE IF NOT EXISTS events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  platform TEXT NOT NULL,
+  type TEXT NOT NULL,
+  channel TEXT NOT NULL DEFAULT '',
+  direction TEXT NOT NULL DEFAULT '',
+  from_channel TEXT NOT NULL DEFAULT '',
+  from_name TEXT NOT NULL DEFAULT '',
+  to_channel TEXT NOT NULL DEFAULT '',
+  to_name TEXT NOT NULL DEFAULT '',
+  viewers INTEGER NOT NULL DEFAULT 0,
+  text TEXT NOT NULL DEFAULT '',
+  session_id TEXT NOT NULL DEFAULT '',
+  ts INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS events_type_ts ON events(type, platform, ts);`
+
+// raidDedupeWindow is how far apart two reports of the same raid (IRC
+// USERNOTICE and EventSub) may be and still be treated as one.
+const raidDedupeWindow = 2 * time.Minute
+
+// RecordRaid stores r in the events table unless the same raid (same
+// channels, within raidDedupeWindow) was already recorded from another
+// source. It reports whether a row was added.
+func (s *SQLiteSink) RecordRaid(ctx context.Context, r core.Raid) (bool, error) {
+	platform := strings.TrimSpace(r.Platform)
+	from := strings.ToLower(strings.TrimSpace(r.FromChannel))
+	to := strings.ToLower(strings.TrimSpace(r.ToChannel))
+	if platform == "" || from == "" || to == "" {
+		return false, errors.New("raid requires platform and both channels")
+	}
+	ts := r.Ts
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	tsMS := ts.UTC().UnixMilli()
+	window := raidDedupeWindow.Milliseconds()
+
+	inserted := false
+	err := withRetry(func() error {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		var existing int64
+		err = tx.QueryRowContext(ctx, `SELECT id FROM events WHERE type = ? AND platform = ? AND from_channel = ? AND to_channel = ?
+AND ts BETWEEN ? AND ? LIMIT 1;`, core.MessageTypeRaid, platform, from, to, tsMS-window, tsMS+window).Scan(&existing)
+		switch {
+		case err == nil:
+			inserted = false
+			return nil
+		case !errors.Is(err, sql.ErrNoRows):
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO events (platform, type, channel, direction, from_channel, from_name, to_channel, to_name, viewers, text, session_id, ts)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?);`, platform, core.MessageTypeRaid, r.Channel, r.Direction, from, r.FromName, to, r.ToName,
+			r.Viewers, r.Text, s.activeSession(platform), tsMS); err != nil {
+			return err
+		}
+		inserted = true
+		return tx.Commit()
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "record raid")
+	}
+	return inserted, nil
+}
+
+// ListRaids returns recorded raids matching filters: platforms, sessions and
+// a since/until bound on the raid time.
+func (s *SQLiteSink) ListRaids(ctx context.Context, filters httpapi.Filters) ([]httpapi.Raid, error) {
+	where, args := buildFilterWhere(httpapi.Filters{Platforms: filters.Platforms, Since: filters.Since, Until: filters.Until}, "ts")
+	clauses := []string{"type = ?"}
+	args = append(args, core.MessageTypeRaid)
+	if len(filters.SessionIDs) > 0 {
+		placeholders := make([]string, 0, len(filters.SessionIDs))
+		for _, id := range filters.SessionIDs {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		clauses = append(clauses, "session_id IN ("+strings.Join(placeholders, ",")+")")
+	}
+	if where == "" {
+		where = " WHERE " + strings.Join(clauses, " AND ")
+	} else {
+		where += " AND " + strings.Join(clauses, " AND ")
+	}
+	order := "DESC"
+	if filters.Order == httpapi.OrderAsc {
+		order = "ASC"
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, `SELECT id, platform, channel, direction, from_channel, from_name, to_channel, to_name, viewers, text, session_id, ts
+FROM events`+where+` ORDER BY ts `+order+`, id `+order+` LIMIT ?;`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list raids")
+	}
+	defer rows.Close()
+
+	var out []httpapi.Raid
+	for rows.Next() {
+		var (
+			r    httpapi.Raid
+			tsMS int64
+		)
+		if err := rows.Scan(&r.ID, &r.Platform, &r.Channel, &r.Direction, &r.FromChannel, &r.FromName, &r.ToChannel, &r.ToName,
+			&r.Viewers, &r.Text, &r.SessionID, &tsMS); err != nil {
+			return nil, errors.Wrap(err, "scan raid")
+		}
+		r.Ts = time.UnixMilli(tsMS).UTC()
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate raids")
+	}
+	return out, nil
+}
@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"hash/fnv"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// ingestLimitLogInterval spaces the limiter's overflow log lines.
+const ingestLimitLogInterval = 10 * time.Second
+
+// IngestLimiterOptions configures an IngestLimiter.
+type IngestLimiterOptions struct {
+	// Receiver names the source in logs and OnOverflow, e.g. "twitch".
+	Receiver string
+	// Rate is the sustained number of messages per second let through.
+	Rate float64
+	// Burst is how many messages may arrive at once; it defaults to Rate
+	// rounded up.
+	Burst int
+	// Sample is the fraction, in [0, 1], of over-limit messages that are
+	// stored anyway so the shed traffic stays visible in the archive.
+	Sample float64
+	// OnOverflow is called for every over-limit message with sampled=true
+	// when it was kept.
+	OnOverflow func(receiver string, sampled bool)
+}
+
+// IngestLimiter caps the rate of chat one receiver feeds the pipeline, so a
+// bot raid cannot flood the sinks and every downstream consumer. Only chat
+// and action messages count against the cap and only they are shed; subs,
+// raids, Super Chats and other events always pass.
+type IngestLimiter struct {
+	receiver   string
+	limiter    *rate.Limiter
+	sample     float64
+	onOverflow func(string, bool)
+
+	mu       sync.Mutex
+	shed     int64
+	sampled  int64
+	lastLog  time.Time
+	reported int64
+}
+
+// NewIngestLimiter returns a limiter for opts.Receiver.
+func NewIngestLimiter(opts IngestLimiterOptions) *IngestLimiter {
+	burst := opts.Burst
+	if burst <= 0 {
+		burst = int(math.Max(1, math.Ceil(opts.Rate)))
+	}
+	return &IngestLimiter{
+		receiver:   opts.Receiver,
+		limiter:    rate.NewLimiter(rate.Limit(opts.Rate), burst),
+		sample:     opts.Sample,
+		onOverflow: opts.OnOverflow,
+	}
+}
+
+// Transform implements Transformer, dropping chat over the rate limit.
+func (l *IngestLimiter) Transform(msg core.ChatMessage) (core.ChatMessage, bool, error) {
+	switch core.NormalizeMessageType(msg.MessageType) {
+	case core.MessageTypeChat, core.MessageTypeAction:
+	default:
+		return msg, true, nil
+	}
+	if l.limiter.Allow() {
+		return msg, true, nil
+	}
+	keep := l.sampleKeeps(msg)
+	if l.onOverflow != nil {
+		l.onOverflow(l.receiver, keep)
+	}
+
+	l.mu.Lock()
+	if keep {
+		l.sampled++
+	} else {
+		l.shed++
+	}
+	now := time.Now()
+	if now.Sub(l.lastLog) >= ingestLimitLogInterval {
+		log.Printf("sink: %s over ingest rate limit: shed %d messages (%d since last report), sampled %d",
+			l.receiver, l.shed, l.shed-l.reported, l.sampled)
+		l.lastLog = now
+		l.reported = l.shed
+	}
+	l.mu.Unlock()
+	return msg, keep, nil
+}
+
+// Overflow returns how many over-limit messages were shed and how many were
+// kept by sampling.
+func (l *IngestLimiter) Overflow() (shed, sampled int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.shed, l.sampled
+}
+
+// sampleKeeps reports whether an over-limit msg falls in the sample. It is
+// keyed on the message ID so a replayed message gets the same answer.
+func (l *IngestLimiter) sampleKeeps(msg core.ChatMessage) bool {
+	if l.sample <= 0 {
+		return false
+	}
+	if l.sample >= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write([]byte(msg.Platform + ":" + msg.ID))
+	return float64(h.Sum32())/float64(math.MaxUint32) < l.sample
+}
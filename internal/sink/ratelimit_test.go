@@ -0,0 +1,58 @@
+package sink
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestIngestLimiter(t *testing.T) {
+	var overflows int
+	l := NewIngestLimiter(IngestLimiterOptions{
+		Receiver:   "twitch",
+		Rate:       0.001,
+		Burst:      2,
+		OnOverflow: func(receiver string, sampled bool) { overflows++ },
+	})
+	kept := 0
+	for i := 0; i < 5; i++ {
+		_, keep, err := l.Transform(core.ChatMessage{ID: fmt.Sprint(i), Platform: "Twitch", Text: "spam"})
+		if err != nil {
+			t.Fatalf("transform: %v", err)
+		}
+		if keep {
+			kept++
+		}
+	}
+	if kept != 2 {
+		t.Fatalf("kept %d messages, want the burst of 2", kept)
+	}
+	// Events are never shed, and do not count against the cap.
+	if _, keep, _ := l.Transform(core.ChatMessage{ID: "sub", Platform: "Twitch", MessageType: core.MessageTypeSub}); !keep {
+		t.Fatal("sub shed")
+	}
+	if shed, sampled := l.Overflow(); shed != 3 || sampled != 0 || overflows != 3 {
+		t.Fatalf("shed=%d sampled=%d overflows=%d, want 3/0/3", shed, sampled, overflows)
+	}
+}
+
+func TestIngestLimiterSample(t *testing.T) {
+	l := NewIngestLimiter(IngestLimiterOptions{Receiver: "youtube", Rate: 0.001, Burst: 1, Sample: 0.5})
+	l.Transform(core.ChatMessage{ID: "first", Platform: "YouTube"})
+	for i := 0; i < 1000; i++ {
+		l.Transform(core.ChatMessage{ID: fmt.Sprint("m", i), Platform: "YouTube"})
+	}
+	shed, sampled := l.Overflow()
+	if shed+sampled != 1000 {
+		t.Fatalf("overflow %d+%d, want 1000", shed, sampled)
+	}
+	if sampled < 400 || sampled > 600 {
+		t.Fatalf("sampled %d of 1000 at 0.5", sampled)
+	}
+	// Sampling is keyed on the message, so a repeat gets the same answer.
+	msg := core.ChatMessage{ID: "m1", Platform: "YouTube"}
+	if l.sampleKeeps(msg) != l.sampleKeeps(msg) {
+		t.Fatal("sampling not deterministic")
+	}
+}
@@ -0,0 +1,131 @@
+package sink
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const redemptionsSchema = `CREATE TABLE IF NOT EXISTS redemptions (
+  platform TEXT NOT NULL,
+  id TEXT NOT NULL,
+  channel TEXT NOT NULL DEFAULT '',
+  reward_id TEXT NOT NULL DEFAULT '',
+  reward_title TEXT NOT NULL DEFAULT '',
+  cost INTEGER NOT NULL DEFAULT 0,
+  user_id TEXT NOT NULL DEFAULT '',
+  username TEXT NOT NULL DEFAULT '',
+  user_input TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT '',
+  session_id TEXT NOT NULL DEFAULT '',
+  redeemed_at INTEGER NOT NULL,
+  PRIMARY KEY (platform, id)
+);
+CREATE INDEX IF NOT EXISTS redemptions_redeemed ON redemptions(platform, redeemed_at);`
+
+// RecordRedemption stores r, tagging it with the platform's active session
+// when r does not name one. A redelivered redemption is stored once.
+func (s *SQLiteSink) RecordRedemption(ctx context.Context, r core.Redemption) error {
+	platform := strings.TrimSpace(r.Platform)
+	if platform == "" || r.ID == "" {
+		return errors.New("redemption requires platform and id")
+	}
+	redeemed := r.RedeemedAt
+	if redeemed.IsZero() {
+		redeemed = time.Now()
+	}
+	sessionID := r.SessionID
+	if sessionID == "" {
+		sessionID = s.activeSession(platform)
+	}
+	err := withRetry(func() error {
+		_, err := s.db.ExecContext(ctx, `INSERT INTO redemptions (platform, id, channel, reward_id, reward_title, cost, user_id, username, user_input, status, session_id, redeemed_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(platform, id) DO NOTHING;`,
+			platform, r.ID, r.Channel, r.RewardID, r.RewardTitle, r.Cost, r.UserID, strings.ToLower(r.Username), r.UserInput, r.Status,
+			sessionID, redeemed.UTC().UnixMilli())
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "record redemption")
+	}
+	return nil
+}
+
+// ListRedemptions returns redemptions matching filters: platforms, channels,
+// sessions and a since/until bound on the redemption time. rewards, when
+// non-empty, selects rewards by id or case-insensitive title.
+func (s *SQLiteSink) ListRedemptions(ctx context.Context, filters httpapi.Filters, rewards []string) ([]httpapi.Redemption, error) {
+	where, args := buildFilterWhere(httpapi.Filters{Platforms: filters.Platforms, Since: filters.Since, Until: filters.Until}, "redeemed_at")
+	var clauses []string
+	in := func(column string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		placeholders := make([]string, 0, len(values))
+		for _, v := range values {
+			placeholders = append(placeholders, "?")
+			args = append(args, v)
+		}
+		clauses = append(clauses, column+" IN ("+strings.Join(placeholders, ",")+")")
+	}
+	in("session_id", filters.SessionIDs)
+	in("channel", filters.Channels)
+	if len(rewards) > 0 {
+		placeholders := make([]string, 0, len(rewards))
+		for _, reward := range rewards {
+			placeholders = append(placeholders, "?")
+			args = append(args, strings.ToLower(reward))
+		}
+		list := strings.Join(placeholders, ",")
+		clauses = append(clauses, "(reward_id IN ("+list+") OR LOWER(reward_title) IN ("+list+"))")
+		for _, reward := range rewards {
+			args = append(args, strings.ToLower(reward))
+		}
+	}
+	if len(clauses) > 0 {
+		if where == "" {
+			where = " WHERE " + strings.Join(clauses, " AND ")
+		} else {
+			where += " AND " + strings.Join(clauses, " AND ")
+		}
+	}
+	order := "DESC"
+	if filters.Order == httpapi.OrderAsc {
+		order = "ASC"
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, `SELECT platform, id, channel, reward_id, reward_title, cost, user_id, username, user_input, status, session_id, redeemed_at
+FROM redemptions`+where+` ORDER BY redeemed_at `+order+`, id `+order+` LIMIT ?;`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list redemptions")
+	}
+	defer rows.Close()
+
+	var out []httpapi.Redemption
+	for rows.Next() {
+		var (
+			r          httpapi.Redemption
+			redeemedMS int64
+		)
+		if err := rows.Scan(&r.Platform, &r.ID, &r.Channel, &r.RewardID, &r.RewardTitle, &r.Cost, &r.UserID, &r.Username, &r.UserInput,
+			&r.Status, &r.SessionID, &redeemedMS); err != nil {
+			return nil, errors.Wrap(err, "scan redemption")
+		}
+		r.RedeemedAt = time.UnixMilli(redeemedMS).UTC()
+		out = append(out, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate redemptions")
+	}
+	return out, nil
+}
@@ -0,0 +1,207 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/redis"
+)
+
+const (
+	defaultRedisChannel = "gnasty:messages"
+	redisQueueSize      = 1024
+	redisMaxBackoff     = 30 * time.Second
+)
+
+// RedisOptions configures the Redis broadcast bridge.
+type RedisOptions struct {
+	URL     string
+	Channel string
+	// Whispers publishes captured Twitch whispers too; by default they are
+	// only delivered to the local broadcasters.
+	Whispers bool
+}
+
+// RedisBridge relays broadcasts through Redis pub/sub so every API replica
+// subscribed to the channel, including this one, delivers the same live
+// stream. Broadcast publishes instead of delivering locally; the subscriber
+// hands each received message to the local broadcasters. While Redis is
+// unreachable, messages are delivered locally only.
+type RedisBridge struct {
+	opts  RedisOptions
+	local broadcaster
+	queue chan core.ChatMessage
+
+	cancel    context.CancelFunc
+	published chan struct{}
+	received  chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	dropped int64
+}
+
+// NewRedisBridge starts publishing and subscribing in the background; local
+// receives every message seen on the channel.
+func NewRedisBridge(opts RedisOptions, local broadcaster) *RedisBridge {
+	if opts.Channel == "" {
+		opts.Channel = defaultRedisChannel
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &RedisBridge{
+		opts:      opts,
+		local:     local,
+		queue:     make(chan core.ChatMessage, redisQueueSize),
+		cancel:    cancel,
+		published: make(chan struct{}),
+		received:  make(chan struct{}),
+	}
+	go b.publish(ctx)
+	go b.subscribe(ctx)
+	return b
+}
+
+// Broadcast queues msg for publishing. It never blocks ingest; messages are
+// dropped while the queue is full. Whispers stay local unless
+// RedisOptions.Whispers is set.
+func (b *RedisBridge) Broadcast(msg core.ChatMessage) {
+	if msg.MessageType == core.MessageTypeWhisper && !b.opts.Whispers {
+		b.local.Broadcast(msg)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	select {
+	case b.queue <- msg:
+	default:
+		b.dropped++
+		if b.dropped == 1 || b.dropped%1000 == 0 {
+			log.Printf("sink: redis: queue full, dropped=%d", b.dropped)
+		}
+	}
+}
+
+// Close stops both directions after a best-effort flush of queued messages.
+func (b *RedisBridge) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.queue)
+	b.mu.Unlock()
+
+	select {
+	case <-b.published:
+	case <-time.After(5 * time.Second):
+	}
+	b.cancel()
+	<-b.published
+	<-b.received
+	return nil
+}
+
+func (b *RedisBridge) dial(ctx context.Context) (*redis.Conn, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	return redis.Dial(dialCtx, redis.Options{URL: b.opts.URL})
+}
+
+func (b *RedisBridge) publish(ctx context.Context) {
+	defer close(b.published)
+	var conn *redis.Conn
+	defer func() {
+		if conn != nil {
+			_ = conn.Close()
+		}
+	}()
+
+	var retryAt time.Time
+	for msg := range b.queue {
+		if conn == nil && time.Now().After(retryAt) {
+			c, err := b.dial(ctx)
+			if err != nil {
+				log.Printf("sink: redis: publisher: %v; delivering locally for %s", err, redisMaxBackoff)
+				retryAt = time.Now().Add(redisMaxBackoff)
+			} else {
+				conn = c
+				log.Printf("sink: redis: publishing to %s on %s", b.opts.Channel, redactURL(b.opts.URL))
+			}
+		}
+		if conn == nil {
+			b.local.Broadcast(msg)
+			continue
+		}
+		payload, err := json.Marshal(msg)
+		if err != nil {
+			log.Printf("sink: redis: encode message: %v", err)
+			continue
+		}
+		n, err := conn.Publish(ctx, b.opts.Channel, payload)
+		if err != nil {
+			log.Printf("sink: redis: publish: %v", err)
+			_ = conn.Close()
+			conn = nil
+		}
+		// Nobody received it (our own subscriber may be reconnecting), so
+		// local clients still get the message without risk of duplicates.
+		if err != nil || n == 0 {
+			b.local.Broadcast(msg)
+		}
+	}
+}
+
+func (b *RedisBridge) subscribe(ctx context.Context) {
+	defer close(b.received)
+	backoff := time.Second
+	for ctx.Err() == nil {
+		conn, err := b.dial(ctx)
+		if err == nil {
+			err = conn.Subscribe(ctx, b.opts.Channel)
+			if err == nil {
+				backoff = time.Second
+				log.Printf("sink: redis: subscribed to %s", b.opts.Channel)
+				err = b.receive(ctx, conn)
+			}
+			_ = conn.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("sink: redis: subscriber: %v; retrying in %s", err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > redisMaxBackoff {
+			backoff = redisMaxBackoff
+		}
+	}
+}
+
+func (b *RedisBridge) receive(ctx context.Context, conn *redis.Conn) error {
+	stop := context.AfterFunc(ctx, func() { _ = conn.Close() })
+	defer stop()
+	for {
+		_, payload, err := conn.Receive()
+		if err != nil {
+			return err
+		}
+		var msg core.ChatMessage
+		if err := json.Unmarshal(payload, &msg); err != nil {
+			log.Printf("sink: redis: decode message: %v", err)
+			continue
+		}
+		b.local.Broadcast(msg)
+	}
+}
@@ -0,0 +1,63 @@
+package sink
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+type lockedBroadcaster struct {
+	mu   sync.Mutex
+	msgs []core.ChatMessage
+}
+
+func (l *lockedBroadcaster) Broadcast(msg core.ChatMessage) {
+	l.mu.Lock()
+	l.msgs = append(l.msgs, msg)
+	l.mu.Unlock()
+}
+
+func (l *lockedBroadcaster) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.msgs)
+}
+
+func TestRedisBridgeDeliversLocallyWhileUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	local := &lockedBroadcaster{}
+	bridge := NewRedisBridge(RedisOptions{URL: "redis://" + addr}, local)
+	bridge.Broadcast(core.ChatMessage{ID: "m1"})
+	bridge.Broadcast(core.ChatMessage{ID: "m2"})
+	if err := bridge.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for local.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := local.count(); got != 2 {
+		t.Fatalf("expected local fallback delivery of 2 messages, got %d", got)
+	}
+}
+
+func TestRedisBridgeKeepsWhispersLocal(t *testing.T) {
+	local := &lockedBroadcaster{}
+	bridge := NewRedisBridge(RedisOptions{URL: "redis://127.0.0.1:1"}, local)
+	defer bridge.Close()
+
+	bridge.Broadcast(core.ChatMessage{ID: "w1", MessageType: core.MessageTypeWhisper})
+	if got := local.count(); got != 1 || len(bridge.queue) != 0 {
+		t.Fatalf("expected the whisper delivered locally without publishing, got local=%d queued=%d", got, len(bridge.queue))
+	}
+}
@@ -0,0 +1,129 @@
+package sink
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+const reenrichBatch = 1000
+
+// ReenrichFunc recomputes the badges, emotes and platform IDs of a stored
+// message. The message carries Platform, RawJSON, BadgesJSON, EmotesJSON,
+// UserID and ChannelID as stored; returning ok=false leaves the row untouched.
+type ReenrichFunc func(ctx context.Context, msg core.ChatMessage) (core.ChatMessage, bool)
+
+// ReenrichStats summarises a Reenrich run.
+type ReenrichStats struct {
+	Scanned int64
+	Updated int64
+	Skipped int64
+}
+
+// Reenrich walks every stored message in id order, passes it to fn and
+// rewrites badges_json, emotes_json, user_id and channel_id when the result
+// differs from what is stored.
+func (s *SQLiteSink) Reenrich(ctx context.Context, fn ReenrichFunc) (ReenrichStats, error) {
+	var (
+		stats  ReenrichStats
+		lastID int64
+	)
+	for {
+		n, next, err := s.reenrichBatch(ctx, fn, lastID, &stats)
+		if err != nil {
+			return stats, err
+		}
+		if n < reenrichBatch {
+			return stats, nil
+		}
+		lastID = next
+	}
+}
+
+func (s *SQLiteSink) reenrichBatch(ctx context.Context, fn ReenrichFunc, after int64, stats *ReenrichStats) (int, int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, platform, raw_json, badges_json, emotes_json, user_id, channel_id FROM messages
+WHERE id > ? ORDER BY id LIMIT ?;`, after, reenrichBatch)
+	if err != nil {
+		return 0, after, errors.Wrap(err, "select reenrich")
+	}
+	type pending struct {
+		id                int64
+		badges, emotes    string
+		userID, channelID string
+	}
+	var (
+		batch []pending
+		n     int
+		last  = after
+	)
+	for rows.Next() {
+		var (
+			id                          int64
+			platform                    string
+			rawJSON, badgesJSON, emotes []byte
+			userID, channelID           string
+		)
+		if err := rows.Scan(&id, &platform, &rawJSON, &badgesJSON, &emotes, &userID, &channelID); err != nil {
+			rows.Close()
+			return 0, after, errors.Wrap(err, "scan reenrich")
+		}
+		n++
+		last = id
+		stats.Scanned++
+
+		stored := core.ChatMessage{
+			Platform:   platform,
+			RawJSON:    string(rawJSON),
+			BadgesJSON: string(badgesJSON),
+			EmotesJSON: string(emotes),
+			UserID:     userID,
+			ChannelID:  channelID,
+		}
+		updated, ok := fn(ctx, stored)
+		if !ok {
+			stats.Skipped++
+			continue
+		}
+		newBadges := encodeBadgesJSON(updated)
+		newEmotes := jsonText(updated.EmotesJSON, updated.Emotes, "[]")
+		newUserID := strings.TrimSpace(updated.UserID)
+		newChannelID := strings.TrimSpace(updated.ChannelID)
+		if newBadges == stored.BadgesJSON && newEmotes == stored.EmotesJSON &&
+			newUserID == userID && newChannelID == channelID {
+			continue
+		}
+		batch = append(batch, pending{id: id, badges: newBadges, emotes: newEmotes, userID: newUserID, channelID: newChannelID})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, after, errors.Wrap(err, "iterate reenrich")
+	}
+	if len(batch) == 0 {
+		return n, last, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, after, errors.Wrap(err, "begin reenrich")
+	}
+	stmt, err := tx.PrepareContext(ctx, `UPDATE messages SET badges_json = ?, emotes_json = ?, user_id = ?, channel_id = ? WHERE id = ?;`)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, after, errors.Wrap(err, "prepare reenrich")
+	}
+	defer stmt.Close()
+	for _, p := range batch {
+		if _, err := stmt.ExecContext(ctx, p.badges, p.emotes, p.userID, p.channelID, p.id); err != nil {
+			_ = tx.Rollback()
+			return 0, after, errors.Wrap(err, "update reenrich")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, after, errors.Wrap(err, "commit reenrich")
+	}
+	stats.Updated += int64(len(batch))
+	return n, last, nil
+}
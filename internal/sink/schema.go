@@ -0,0 +1,147 @@
+package sink
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+// SchemaVersion is bumped whenever a table or column is added or removed or
+// a column changes meaning, so tools sharing the database file can tell
+// which layout they are reading. Schema also reports a fingerprint of the
+// live definitions, which changes with any DDL difference.
+const SchemaVersion = 3
+
+// tableDocs describes each table Schema reports.
+var tableDocs = map[string]string{
+	"messages":            "One row per chat message, system notice or event line, keyed by platform and platform message ID.",
+	"message_edits":       "Earlier versions of edited messages; message_id is messages.id.",
+	"message_chain":       "Per-session hash chain over stored messages, used to detect tampering.",
+	"links":               "URLs shared in chat; message_id is messages.id.",
+	"users":               "Every chatter seen, with first and last appearance.",
+	"sessions":            "Broadcast sessions; messages.session_id refers to sessions.id.",
+	"stream_state":        "Stream online/offline transitions and their details.",
+	"viewer_samples":      "Periodic concurrent viewer counts.",
+	"moments":             "Chat activity spikes detected per session.",
+	"markers":             "Highlight markers created by trigger rules.",
+	"polls":               "Platform polls and predictions with their outcomes.",
+	"events":              "Raids, hosts and other channel events.",
+	"presence":            "Stretches of chatters being joined to a channel; left_at is 0 while present.",
+	"automod_events":      "Messages held by platform automod and how they were resolved.",
+	"tts_queue":           "Messages queued for text-to-speech until a player acknowledges them.",
+	"redemptions":         "Channel points redemptions.",
+	"overlay_configs":     "Saved overlay configurations by key.",
+	"leases":              "Named leases used to elect a single leader among harvesters.",
+	"youtube_checkpoints": "Last YouTube live chat continuation per video, used to resume polling after a restart.",
+}
+
+// columnDocs describes the columns of the tables other tools read most.
+// Timestamps are Unix milliseconds; 0 means unset.
+var columnDocs = map[string]map[string]string{
+	"messages": {
+		"id":                "Row ID, increasing in insertion order; local to this database (see ulid).",
+		"platform":          "Source platform: Twitch or YouTube.",
+		"platform_msg_id":   "The platform's message ID; unique per platform. NULL when the platform gave none.",
+		"ts":                "Platform timestamp, Unix milliseconds.",
+		"username":          "Author display name.",
+		"text":              "Message text as currently shown (after edits).",
+		"emotes_json":       "JSON array of emotes with their positions in text.",
+		"raw_json":          "The platform payload as received, when kept.",
+		"badges_json":       "JSON array of author badges.",
+		"colour":            "Author name colour, #RRGGBB or empty.",
+		"username_norm":     "Normalized username used for lookups.",
+		"author_channel_id": "YouTube author channel ID.",
+		"avatar_url":        "Author avatar URL.",
+		"session_id":        "Broadcast session the message belongs to (sessions.id).",
+		"edited_at":         "When the text was last edited, Unix milliseconds; 0 if never.",
+		"deleted_at":        "When moderation removed the message, Unix milliseconds; 0 if not deleted.",
+		"deleted_by":        "Who removed the message.",
+		"message_type":      "chat, action, system or another core message type.",
+		"channel":           "Lowercase platform channel the message was sent in.",
+		"user_id":           "The platform's stable author ID.",
+		"channel_id":        "The platform's stable channel ID.",
+		"received_at":       "When the harvester received the message, Unix milliseconds.",
+		"mirror_of":         "platform:id of the message this one repeats from another platform, if any.",
+		"paid_amount":       "Display amount of a paid message (Super Chat or bits), if any.",
+		"reward_id":         "Channel points reward that sent the message, if any.",
+		"spam_score":        "Spam detector score in [0, 1]; 0 when not flagged.",
+		"ulid":              "Globally unique ULID assigned at ingest; sorts by ts and is kept when messages are copied between databases.",
+	},
+}
+
+// Schema implements httpapi.SchemaStore by reading the live table
+// definitions from the database.
+func (s *SQLiteSink) Schema(ctx context.Context) (httpapi.SchemaInfo, error) {
+	info := httpapi.SchemaInfo{Version: SchemaVersion}
+	rows, err := s.db.QueryContext(ctx, `SELECT type, name, tbl_name, COALESCE(sql, '') FROM sqlite_master
+WHERE name NOT LIKE 'sqlite_%' ORDER BY tbl_name, type DESC, name;`)
+	if err != nil {
+		return info, errors.Wrap(err, "read schema")
+	}
+	h := sha256.New()
+	byName := map[string]int{}
+	for rows.Next() {
+		var kind, name, table, ddl string
+		if err := rows.Scan(&kind, &name, &table, &ddl); err != nil {
+			rows.Close()
+			return info, errors.Wrap(err, "scan schema")
+		}
+		fmt.Fprintf(h, "%s\x00%s\x00%s\x00", kind, name, ddl)
+		switch kind {
+		case "table":
+			byName[name] = len(info.Tables)
+			info.Tables = append(info.Tables, httpapi.SchemaTable{Name: name, Description: tableDocs[name], SQL: ddl})
+		case "index":
+			if i, ok := byName[table]; ok && ddl != "" {
+				info.Tables[i].Indexes = append(info.Tables[i].Indexes, name)
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return info, errors.Wrap(err, "iterate schema")
+	}
+	info.Fingerprint = hex.EncodeToString(h.Sum(nil))[:16]
+
+	for i := range info.Tables {
+		cols, err := tableColumns(ctx, s.db, info.Tables[i].Name)
+		if err != nil {
+			return info, err
+		}
+		docs := columnDocs[info.Tables[i].Name]
+		for j := range cols {
+			cols[j].Description = docs[cols[j].Name]
+		}
+		info.Tables[i].Columns = cols
+	}
+	return info, nil
+}
+
+func tableColumns(ctx context.Context, db *sql.DB, table string) ([]httpapi.SchemaColumn, error) {
+	rows, err := db.QueryContext(ctx, `SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?);`, table)
+	if err != nil {
+		return nil, errors.Wrapf(err, "describe %s", table)
+	}
+	defer rows.Close()
+	var out []httpapi.SchemaColumn
+	for rows.Next() {
+		var (
+			col      httpapi.SchemaColumn
+			notNull  int
+			pk       int
+			defaultV sql.NullString
+		)
+		if err := rows.Scan(&col.Name, &col.Type, &notNull, &defaultV, &pk); err != nil {
+			return nil, errors.Wrapf(err, "scan %s column", table)
+		}
+		col.NotNull, col.PrimaryKey, col.Default = notNull == 1, pk > 0, defaultV.String
+		out = append(out, col)
+	}
+	return out, errors.Wrapf(rows.Err(), "iterate %s columns", table)
+}
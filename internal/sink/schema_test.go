@@ -0,0 +1,53 @@
+package sink
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSQLiteSchema(t *testing.T) {
+	s := openTestSQLite(t)
+	ctx := context.Background()
+
+	info, err := s.Schema(ctx)
+	if err != nil {
+		t.Fatalf("schema: %v", err)
+	}
+	if info.Version != SchemaVersion || len(info.Fingerprint) != 16 {
+		t.Fatalf("unexpected version/fingerprint %d %q", info.Version, info.Fingerprint)
+	}
+	tables := map[string]int{}
+	for i, table := range info.Tables {
+		tables[table.Name] = i
+		if table.Description == "" {
+			t.Errorf("table %s has no description", table.Name)
+		}
+	}
+	i, ok := tables["messages"]
+	if !ok {
+		t.Fatalf("messages table missing from %+v", tables)
+	}
+	messages := info.Tables[i]
+	if len(messages.Indexes) == 0 || messages.SQL == "" {
+		t.Fatalf("expected messages indexes and DDL, got %+v", messages)
+	}
+	for _, col := range messages.Columns {
+		if col.Description == "" {
+			t.Errorf("messages.%s has no description", col.Name)
+		}
+		if col.Name == "id" && !col.PrimaryKey {
+			t.Errorf("messages.id should be the primary key")
+		}
+	}
+
+	if _, err := s.db.Exec(`ALTER TABLE messages ADD COLUMN extra TEXT NOT NULL DEFAULT '';`); err != nil {
+		t.Fatalf("alter: %v", err)
+	}
+	changed, err := s.Schema(ctx)
+	if err != nil {
+		t.Fatalf("schema after alter: %v", err)
+	}
+	if changed.Fingerprint == info.Fingerprint {
+		t.Fatal("expected the fingerprint to change with the schema")
+	}
+}
@@ -0,0 +1,291 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const sessionsSchema = `CREATE TABLE IF NOT EXISTS sessions (
+  id TEXT PRIMARY KEY,
+  platform TEXT NOT NULL,
+  channel TEXT NOT NULL DEFAULT '',
+  video_id TEXT NOT NULL DEFAULT '',
+  title TEXT NOT NULL DEFAULT '',
+  started_at INTEGER NOT NULL,
+  ended_at INTEGER,
+  category TEXT NOT NULL DEFAULT '',
+  thumbnail_url TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS sessions_platform_started ON sessions(platform, started_at);`
+
+// sessionColumns lists columns added to sessions after the original schema.
+var sessionColumns = []addedColumn{
+	{"category", `ALTER TABLE sessions ADD COLUMN category TEXT NOT NULL DEFAULT '';`},
+	{"thumbnail_url", `ALTER TABLE sessions ADD COLUMN thumbnail_url TEXT NOT NULL DEFAULT '';`},
+}
+
+const sessionSelect = `SELECT id, platform, channel, video_id, title, category, thumbnail_url, started_at, ended_at,
+(SELECT COUNT(*) FROM messages WHERE messages.session_id = sessions.id) FROM sessions`
+
+// sessionID derives a stable identifier for a broadcast so that restarts
+// during the same stream resume the existing session: the YouTube video ID
+// or Twitch stream ID when known, otherwise the start time.
+func sessionID(platform string, detail map[string]any, ts time.Time) string {
+	prefix := strings.ToLower(platform)
+	for _, key := range []string{"video_id", "stream_id"} {
+		if v, _ := detail[key].(string); strings.TrimSpace(v) != "" {
+			return prefix + ":" + strings.TrimSpace(v)
+		}
+	}
+	return prefix + ":" + strconv.FormatInt(ts.UnixMilli(), 10)
+}
+
+// activeSession returns the session new messages on platform belong to.
+func (s *SQLiteSink) activeSession(platform string) string {
+	s.sessionMu.RLock()
+	defer s.sessionMu.RUnlock()
+	return s.sessions[strings.TrimSpace(platform)]
+}
+
+func (s *SQLiteSink) loadActiveSessions(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, platform FROM sessions WHERE ended_at IS NULL ORDER BY started_at ASC;`)
+	if err != nil {
+		return errors.Wrap(err, "query open sessions")
+	}
+	defer rows.Close()
+	active := make(map[string]string)
+	for rows.Next() {
+		var id, platform string
+		if err := rows.Scan(&id, &platform); err != nil {
+			return errors.Wrap(err, "scan open session")
+		}
+		active[platform] = id
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "iterate open sessions")
+	}
+	s.sessionMu.Lock()
+	s.sessions = active
+	s.sessionMu.Unlock()
+	return nil
+}
+
+// openSession starts (or resumes) the session for a live event, closing any
+// other session still open on the same channel. Messages already written for
+// the platform since the start time without a session are attached to it.
+func (s *SQLiteSink) openSession(ctx context.Context, platform, channel string, detail map[string]any, ts time.Time) (string, error) {
+	id := sessionID(platform, detail, ts)
+	videoID, _ := detail["video_id"].(string)
+	title, _ := detail["title"].(string)
+	category, _ := detail["category"].(string)
+	thumbnail, _ := detail["thumbnail_url"].(string)
+	tsMS := ts.UTC().UnixMilli()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "begin session")
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.ExecContext(ctx, `UPDATE sessions SET ended_at = ? WHERE platform = ? AND channel = ? AND ended_at IS NULL AND id != ?;`,
+		tsMS, platform, channel, id); err != nil {
+		return "", errors.Wrap(err, "close previous session")
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO sessions (id, platform, channel, video_id, title, category, thumbnail_url, started_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(id) DO UPDATE SET
+  ended_at = NULL,
+  title = CASE WHEN excluded.title != '' THEN excluded.title ELSE sessions.title END,
+  category = CASE WHEN excluded.category != '' THEN excluded.category ELSE sessions.category END,
+  thumbnail_url = CASE WHEN excluded.thumbnail_url != '' THEN excluded.thumbnail_url ELSE sessions.thumbnail_url END;`,
+		id, platform, channel, videoID, title, category, thumbnail, tsMS); err != nil {
+		return "", errors.Wrap(err, "insert session")
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE messages SET session_id = ? WHERE platform = ? AND ts >= ? AND session_id = '';`,
+		id, platform, tsMS); err != nil {
+		return "", errors.Wrap(err, "assign session messages")
+	}
+	if err := tx.Commit(); err != nil {
+		return "", errors.Wrap(err, "commit session")
+	}
+
+	s.sessionMu.Lock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]string)
+	}
+	s.sessions[platform] = id
+	s.sessionMu.Unlock()
+	return id, nil
+}
+
+// UpdateSessionMetadata refreshes the title, category and thumbnail of the
+// open session on meta's platform/channel; empty fields keep their stored
+// value. It reports false when no session is open.
+func (s *SQLiteSink) UpdateSessionMetadata(ctx context.Context, meta core.StreamMetadata) (bool, error) {
+	platform := strings.TrimSpace(meta.Platform)
+	if platform == "" {
+		return false, errors.New("session metadata requires platform")
+	}
+	var affected int64
+	err := withRetry(func() error {
+		res, err := s.db.ExecContext(ctx, `UPDATE sessions SET
+  title = CASE WHEN ? != '' THEN ? ELSE title END,
+  category = CASE WHEN ? != '' THEN ? ELSE category END,
+  thumbnail_url = CASE WHEN ? != '' THEN ? ELSE thumbnail_url END
+WHERE platform = ? AND channel = ? AND ended_at IS NULL;`,
+			meta.Title, meta.Title, meta.Category, meta.Category, meta.ThumbnailURL, meta.ThumbnailURL, platform, meta.Channel)
+		if err != nil {
+			return err
+		}
+		affected, _ = res.RowsAffected()
+		return nil
+	})
+	if err != nil {
+		return false, errors.Wrap(err, "update session metadata")
+	}
+	return affected > 0, nil
+}
+
+// closeSession ends the open session on channel, if any, and returns its ID.
+func (s *SQLiteSink) closeSession(ctx context.Context, platform, channel string, ts time.Time) (string, error) {
+	var id string
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM sessions WHERE platform = ? AND channel = ? AND ended_at IS NULL
+ORDER BY started_at DESC LIMIT 1;`, platform, channel).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "find open session")
+	}
+	if _, err := s.db.ExecContext(ctx, `UPDATE sessions SET ended_at = ? WHERE platform = ? AND channel = ? AND ended_at IS NULL;`,
+		ts.UTC().UnixMilli(), platform, channel); err != nil {
+		return "", errors.Wrap(err, "close session")
+	}
+	s.sessionMu.Lock()
+	if s.sessions[platform] == id {
+		delete(s.sessions, platform)
+	}
+	s.sessionMu.Unlock()
+	return id, nil
+}
+
+// ListSessions returns broadcast sessions, newest first unless filters ask
+// for ascending order. Since/until bound started_at.
+func (s *SQLiteSink) ListSessions(ctx context.Context, filters httpapi.Filters) ([]httpapi.Session, error) {
+	var (
+		conditions []string
+		args       []any
+	)
+	if len(filters.Platforms) > 0 {
+		placeholders := make([]string, 0, len(filters.Platforms))
+		for _, p := range filters.Platforms {
+			placeholders = append(placeholders, "?")
+			args = append(args, p)
+		}
+		conditions = append(conditions, "platform IN ("+strings.Join(placeholders, ",")+")")
+	}
+	if filters.Since != nil {
+		conditions = append(conditions, "started_at >= ?")
+		args = append(args, filters.Since.UTC().UnixMilli())
+	}
+	if filters.Until != nil {
+		conditions = append(conditions, "started_at < ?")
+		args = append(args, filters.Until.UTC().UnixMilli())
+	}
+	query := sessionSelect
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	order := "DESC"
+	if filters.Order == httpapi.OrderAsc {
+		order = "ASC"
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	query += " ORDER BY started_at " + order + " LIMIT ?;"
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list sessions")
+	}
+	defer rows.Close()
+	var out []httpapi.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate sessions")
+	}
+	return out, nil
+}
+
+// GetSession returns one session by ID.
+func (s *SQLiteSink) GetSession(ctx context.Context, id string) (httpapi.Session, bool, error) {
+	session, err := scanSession(s.db.QueryRowContext(ctx, sessionSelect+` WHERE id = ?;`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return httpapi.Session{}, false, nil
+	}
+	if err != nil {
+		return httpapi.Session{}, false, err
+	}
+	return session, true, nil
+}
+
+func scanSession(row rowScanner) (httpapi.Session, error) {
+	var (
+		session   httpapi.Session
+		startedMS int64
+		endedMS   sql.NullInt64
+	)
+	err := row.Scan(&session.ID, &session.Platform, &session.Channel, &session.VideoID, &session.Title,
+		&session.Category, &session.ThumbnailURL, &startedMS, &endedMS, &session.Messages)
+	if errors.Is(err, sql.ErrNoRows) {
+		return httpapi.Session{}, err
+	}
+	if err != nil {
+		return httpapi.Session{}, errors.Wrap(err, "scan session")
+	}
+	session.StartedAt = time.UnixMilli(startedMS).UTC()
+	if endedMS.Valid {
+		ended := time.UnixMilli(endedMS.Int64).UTC()
+		session.EndedAt = &ended
+	}
+	session.Live = session.EndedAt == nil
+	return session, nil
+}
+
+// recordSession applies a live/ended stream state to the sessions table.
+func (s *SQLiteSink) recordSession(ctx context.Context, st core.StreamState, ts time.Time) error {
+	var (
+		id  string
+		err error
+	)
+	switch st.State {
+	case core.StreamLive:
+		id, err = s.openSession(ctx, strings.TrimSpace(st.Platform), st.Channel, st.Detail, ts)
+	case core.StreamEnded:
+		id, err = s.closeSession(ctx, strings.TrimSpace(st.Platform), st.Channel, ts)
+	}
+	if err != nil || id == "" {
+		return err
+	}
+	if s.onSession != nil {
+		st.Ts = ts
+		s.onSession(st, id)
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package sink
+
+import (
+	"context"
+	"hash/fnv"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+)
+
+const shadowQueueSize = 1024
+
+// WindowDigest summarizes the messages stored in one time window.
+type WindowDigest struct {
+	Start time.Time
+	Count int64
+	// Checksum combines a hash of each message's platform, message ID,
+	// timestamp, author and type, independent of row order. Text is left
+	// out because edits only reach the primary database.
+	Checksum uint64
+}
+
+// Digester is implemented by sinks that can be verified against each other.
+type Digester interface {
+	// WindowDigests returns a digest for each width-long window in
+	// [since, until) that holds messages, oldest first.
+	WindowDigests(ctx context.Context, since, until time.Time, width time.Duration) ([]WindowDigest, error)
+}
+
+// ShadowWriter dual-writes messages to a secondary sink, such as a new
+// backend being evaluated before a migration. Writes are queued so the
+// secondary never slows or fails ingest; failures and drops are only
+// logged and counted, and show up as divergence in VerifyShadow.
+type ShadowWriter struct {
+	base   Writer
+	queue  chan core.ChatMessage
+	onFail func()
+	done   chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	dropped int64
+	failed  int64
+}
+
+// NewShadowWriter starts writing to base in the background. onFail, when
+// set, is called for every message the secondary did not store.
+func NewShadowWriter(base Writer, onFail func()) *ShadowWriter {
+	w := &ShadowWriter{
+		base:   base,
+		queue:  make(chan core.ChatMessage, shadowQueueSize),
+		onFail: onFail,
+		done:   make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+func (w *ShadowWriter) Write(msg core.ChatMessage, _ *ingesttrace.MessageTrace) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil
+	}
+	select {
+	case w.queue <- msg:
+	default:
+		w.dropped++
+		if w.dropped == 1 || w.dropped%1000 == 0 {
+			log.Printf("sink: shadow: queue full, dropped=%d", w.dropped)
+		}
+		if w.onFail != nil {
+			w.onFail()
+		}
+	}
+	return nil
+}
+
+// Close writes the queued messages and stops the writer.
+func (w *ShadowWriter) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.queue)
+	w.mu.Unlock()
+	<-w.done
+	return nil
+}
+
+func (w *ShadowWriter) run() {
+	defer close(w.done)
+	for msg := range w.queue {
+		if err := w.base.Write(msg, nil); err != nil {
+			w.mu.Lock()
+			w.failed++
+			failed := w.failed
+			w.mu.Unlock()
+			if failed == 1 || failed%1000 == 0 {
+				log.Printf("sink: shadow: write: %v (failed=%d)", err, failed)
+			}
+			if w.onFail != nil {
+				w.onFail()
+			}
+		}
+	}
+}
+
+// ShadowDivergence is a window whose digests differ between the primary and
+// the secondary sink.
+type ShadowDivergence struct {
+	Start           time.Time
+	PrimaryCount    int64
+	SecondaryCount  int64
+	ChecksumsDiffer bool
+}
+
+// VerifyShadow compares primary and secondary window by window over
+// [since, until) and returns the windows that differ, oldest first, and how
+// many windows held messages in either sink.
+func VerifyShadow(ctx context.Context, primary, secondary Digester, since, until time.Time, width time.Duration) ([]ShadowDivergence, int, error) {
+	want, err := primary.WindowDigests(ctx, since, until, width)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "primary digests")
+	}
+	got, err := secondary.WindowDigests(ctx, since, until, width)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "secondary digests")
+	}
+	byStart := make(map[int64]WindowDigest, len(got))
+	for _, d := range got {
+		byStart[d.Start.UnixMilli()] = d
+	}
+	var out []ShadowDivergence
+	for _, p := range want {
+		s, ok := byStart[p.Start.UnixMilli()]
+		delete(byStart, p.Start.UnixMilli())
+		if ok && s.Count == p.Count && s.Checksum == p.Checksum {
+			continue
+		}
+		out = append(out, ShadowDivergence{Start: p.Start, PrimaryCount: p.Count, SecondaryCount: s.Count,
+			ChecksumsDiffer: s.Checksum != p.Checksum})
+	}
+	windows := len(want) + len(byStart)
+	for _, s := range got {
+		if _, extra := byStart[s.Start.UnixMilli()]; extra {
+			out = append(out, ShadowDivergence{Start: s.Start, SecondaryCount: s.Count, ChecksumsDiffer: true})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out, windows, nil
+}
+
+// WindowDigests implements Digester.
+func (s *SQLiteSink) WindowDigests(ctx context.Context, since, until time.Time, width time.Duration) ([]WindowDigest, error) {
+	if width <= 0 {
+		return nil, errors.New("window width must be positive")
+	}
+	sinceMS, widthMS := since.UTC().UnixMilli(), width.Milliseconds()
+	rows, err := s.db.QueryContext(ctx, `SELECT platform, COALESCE(platform_msg_id, ''), ts, username, message_type
+FROM messages WHERE ts >= ? AND ts < ? ORDER BY ts;`, sinceMS, until.UTC().UnixMilli())
+	if err != nil {
+		return nil, errors.Wrap(err, "window digests")
+	}
+	defer rows.Close()
+
+	var out []WindowDigest
+	h := fnv.New64a()
+	for rows.Next() {
+		var (
+			platform, id, username, msgType string
+			tsMS                            int64
+		)
+		if err := rows.Scan(&platform, &id, &tsMS, &username, &msgType); err != nil {
+			return nil, errors.Wrap(err, "scan window digest")
+		}
+		start := since.UTC().Add(time.Duration((tsMS-sinceMS)/widthMS) * width)
+		if n := len(out); n == 0 || !out[n-1].Start.Equal(start) {
+			out = append(out, WindowDigest{Start: start})
+		}
+		h.Reset()
+		h.Write([]byte(platform + "\x00" + id + "\x00" + strconv.FormatInt(tsMS, 10) + "\x00" + username + "\x00" +
+			core.NormalizeMessageType(msgType)))
+		d := &out[len(out)-1]
+		d.Count++
+		d.Checksum += h.Sum64()
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate window digests")
+	}
+	return out, nil
+}
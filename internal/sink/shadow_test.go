@@ -0,0 +1,76 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+)
+
+func TestShadowVerify(t *testing.T) {
+	primary, secondary := openTestSQLite(t), openTestSQLite(t)
+	ctx := context.Background()
+	base := time.Date(2026, 10, 15, 20, 0, 0, 0, time.UTC)
+
+	shadow := NewShadowWriter(secondary, nil)
+	writer := MultiWriter{primary, shadow}
+	for i := 0; i < 6; i++ {
+		msg := core.ChatMessage{ID: fmt.Sprint("m", i), Platform: "Twitch", Username: "amy", Text: "hi",
+			Ts: base.Add(time.Duration(i*20) * time.Second)}
+		if err := writer.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := shadow.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	diffs, windows, err := VerifyShadow(ctx, primary, secondary, base, base.Add(2*time.Minute), time.Minute)
+	if err != nil || len(diffs) != 0 || windows != 2 {
+		t.Fatalf("expected two matching windows, got %d %+v (%v)", windows, diffs, err)
+	}
+
+	// A message missing from the secondary, and one it alone has, diverge.
+	if err := primary.Write(core.ChatMessage{ID: "lost", Platform: "Twitch", Username: "bob", Text: "hey", Ts: base.Add(70 * time.Second)}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := secondary.Write(core.ChatMessage{ID: "extra", Platform: "Twitch", Username: "bob", Text: "hey", Ts: base.Add(130 * time.Second)}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	diffs, windows, err = VerifyShadow(ctx, primary, secondary, base, base.Add(3*time.Minute), time.Minute)
+	if err != nil || windows != 3 || len(diffs) != 2 {
+		t.Fatalf("expected two of three windows to diverge, got %d %+v (%v)", windows, diffs, err)
+	}
+	if d := diffs[0]; !d.Start.Equal(base.Add(time.Minute)) || d.PrimaryCount != 4 || d.SecondaryCount != 3 || !d.ChecksumsDiffer {
+		t.Fatalf("unexpected divergence %+v", d)
+	}
+	if d := diffs[1]; !d.Start.Equal(base.Add(2*time.Minute)) || d.PrimaryCount != 0 || d.SecondaryCount != 1 {
+		t.Fatalf("unexpected divergence %+v", d)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(core.ChatMessage, *ingesttrace.MessageTrace) error {
+	return errors.New("backend down")
+}
+
+func TestShadowWriterNeverFailsIngest(t *testing.T) {
+	failures := make(chan struct{}, 1)
+	shadow := NewShadowWriter(failingWriter{}, func() { failures <- struct{}{} })
+	if err := shadow.Write(core.ChatMessage{ID: "m1", Platform: "Twitch"}, nil); err != nil {
+		t.Fatalf("shadow write returned %v", err)
+	}
+	if err := shadow.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	select {
+	case <-failures:
+	default:
+		t.Fatal("failure not reported")
+	}
+}
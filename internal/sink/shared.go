@@ -0,0 +1,22 @@
+package sink
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// ExternalWrites counts the messages stored after row id after that were
+// written by another process, and returns the newest row id seen so the
+// next call picks up where this one stopped. The harvester stamps every row
+// it writes with received_at; rows without one come from other writers
+// sharing the file.
+func (s *SQLiteSink) ExternalWrites(ctx context.Context, after int64) (int64, int64, error) {
+	var external, last int64
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(SUM(received_at = 0), 0), COALESCE(MAX(id), ?)
+FROM messages WHERE id > ?;`, after, after).Scan(&external, &last)
+	if err != nil {
+		return 0, after, errors.Wrap(err, "external writes")
+	}
+	return external, last, nil
+}
@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestSharedSQLiteExternalWrites(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "shared.db")
+	db, err := OpenSharedSQLite(path, 2*time.Second)
+	if err != nil {
+		t.Fatalf("open shared sqlite: %v", err)
+	}
+	defer db.Close()
+	var timeout int
+	if err := db.db.QueryRowContext(ctx, `PRAGMA busy_timeout;`).Scan(&timeout); err != nil || timeout != 2000 {
+		t.Fatalf("expected busy_timeout 2000 on every connection, got %d (%v)", timeout, err)
+	}
+
+	if err := db.Write(core.ChatMessage{ID: "own", Platform: "Twitch", Username: "a", Text: "hi", Ts: time.Now()}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	// Another process writing the legacy columns leaves received_at unset.
+	if _, err := db.db.Exec(`INSERT INTO messages (platform, platform_msg_id, ts, username, text) VALUES
+('YouTube', 'x1', 1, 'b', 'yo'), ('YouTube', 'x2', 2, 'c', 'hey');`); err != nil {
+		t.Fatalf("external insert: %v", err)
+	}
+
+	n, last, err := db.ExternalWrites(ctx, 0)
+	if err != nil || n != 2 || last != 3 {
+		t.Fatalf("expected 2 external rows up to id 3, got %d %d (%v)", n, last, err)
+	}
+	n, last, err = db.ExternalWrites(ctx, last)
+	if err != nil || n != 0 || last != 3 {
+		t.Fatalf("expected nothing new, got %d %d (%v)", n, last, err)
+	}
+}
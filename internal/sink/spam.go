@@ -0,0 +1,342 @@
+package sink
+
+import (
+	"context"
+	"hash/fnv"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const (
+	// spamMinLetters skips short messages ("gg", "W", "LUL"), which a
+	// whole chat legitimately sends at once.
+	spamMinLetters = 8
+	// spamMaxDistance is how many of the 64 simhash bits two messages may
+	// differ in and still count as near-identical.
+	spamMaxDistance = 4
+	// spamMaxRecent bounds the messages compared against during a flood.
+	spamMaxRecent = 2048
+)
+
+// SpamDetectorOptions configures a SpamDetector. Zero values take the
+// defaults noted on each field.
+type SpamDetectorOptions struct {
+	// Window is how far apart messages of one burst may arrive; 30s.
+	Window time.Duration
+	// MinAccounts is how many different accounts must send near-identical
+	// messages within Window to flag them; 5.
+	MinAccounts int
+	// NewAccountAge is the age below which an account counts as new; 7
+	// days.
+	NewAccountAge time.Duration
+	// AccountCreated looks up when a flagged message's author created
+	// their account, when known. It is only called for messages in a
+	// burst.
+	AccountCreated func(msg core.ChatMessage) (time.Time, bool)
+	// OnFlag is called for every flagged message, with earlier=true for
+	// messages of a burst that were already passed on before it reached
+	// MinAccounts and need their stored score updated.
+	OnFlag func(msg core.ChatMessage, earlier bool)
+}
+
+// SpamDetector scores bursts of near-identical chat from different
+// accounts, as follow-bot and spam raids send, by setting SpamScore.
+// Messages are compared by a simhash of their letters, so raids that vary
+// digits, punctuation, spacing or case still match. A burst reaching
+// MinAccounts scores 0.5, rising to 0.8 at twice as many accounts; authors
+// with new accounts (or on their first message in the channel) add 0.2.
+// Messages are never dropped.
+type SpamDetector struct {
+	opts SpamDetectorOptions
+
+	mu     sync.Mutex
+	recent []spamEntry
+}
+
+type spamEntry struct {
+	hash    uint64
+	account string
+	at      time.Time
+	msg     core.ChatMessage
+	flagged bool
+}
+
+// NewSpamDetector returns a detector configured by opts.
+func NewSpamDetector(opts SpamDetectorOptions) *SpamDetector {
+	if opts.Window <= 0 {
+		opts.Window = 30 * time.Second
+	}
+	if opts.MinAccounts <= 1 {
+		opts.MinAccounts = 5
+	}
+	if opts.NewAccountAge <= 0 {
+		opts.NewAccountAge = 7 * 24 * time.Hour
+	}
+	return &SpamDetector{opts: opts}
+}
+
+// Transform implements Transformer.
+func (d *SpamDetector) Transform(msg core.ChatMessage) (core.ChatMessage, bool, error) {
+	switch core.NormalizeMessageType(msg.MessageType) {
+	case core.MessageTypeChat, core.MessageTypeAction:
+	default:
+		return msg, true, nil
+	}
+	account := spamAccount(msg)
+	hash, ok := spamHash(msg.Text)
+	if account == "" || !ok {
+		return msg, true, nil
+	}
+	at := msg.ReceivedTime()
+
+	d.mu.Lock()
+	d.prune(at)
+	accounts := map[string]bool{account: true}
+	var matches []int
+	for i, e := range d.recent {
+		if e.msg.Platform != msg.Platform || bits.OnesCount64(e.hash^hash) > spamMaxDistance {
+			continue
+		}
+		accounts[e.account] = true
+		matches = append(matches, i)
+	}
+	var earlier []core.ChatMessage
+	if len(accounts) >= d.opts.MinAccounts {
+		msg.SpamScore = d.burstScore(len(accounts))
+		for _, i := range matches {
+			if e := &d.recent[i]; !e.flagged {
+				e.flagged = true
+				e.msg.SpamScore = spamBurstBase
+				earlier = append(earlier, e.msg)
+			}
+		}
+	}
+	d.recent = append(d.recent, spamEntry{hash: hash, account: account, at: at, msg: spamKey(msg), flagged: msg.SpamScore > 0})
+	if len(d.recent) > spamMaxRecent {
+		d.recent = d.recent[len(d.recent)-spamMaxRecent:]
+	}
+	d.mu.Unlock()
+
+	if msg.SpamScore > 0 {
+		if d.newAccount(msg) {
+			msg.SpamScore += spamNewAccountBoost
+		}
+		if d.opts.OnFlag != nil {
+			d.opts.OnFlag(msg, false)
+		}
+	}
+	if d.opts.OnFlag != nil {
+		for _, e := range earlier {
+			d.opts.OnFlag(e, true)
+		}
+	}
+	return msg, true, nil
+}
+
+const (
+	spamBurstBase       = 0.5
+	spamBurstRange      = 0.3
+	spamNewAccountBoost = 0.2
+)
+
+// burstScore scores a burst of n accounts: spamBurstBase at MinAccounts,
+// rising linearly by spamBurstRange at twice that.
+func (d *SpamDetector) burstScore(n int) float64 {
+	extra := float64(n-d.opts.MinAccounts) / float64(d.opts.MinAccounts)
+	return spamBurstBase + spamBurstRange*min(extra, 1)
+}
+
+func (d *SpamDetector) newAccount(msg core.ChatMessage) bool {
+	if msg.FirstMessage {
+		return true
+	}
+	if d.opts.AccountCreated == nil {
+		return false
+	}
+	created, ok := d.opts.AccountCreated(msg)
+	return ok && msg.ReceivedTime().Sub(created) < d.opts.NewAccountAge
+}
+
+func (d *SpamDetector) prune(now time.Time) {
+	cutoff := now.Add(-d.opts.Window)
+	i := 0
+	for i < len(d.recent) && d.recent[i].at.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		d.recent = append(d.recent[:0], d.recent[i:]...)
+	}
+}
+
+// spamAccount identifies the author, preferring the stable platform ID.
+func spamAccount(msg core.ChatMessage) string {
+	if msg.UserID != "" {
+		return msg.UserID
+	}
+	if msg.AuthorChannelID != "" {
+		return msg.AuthorChannelID
+	}
+	return strings.ToLower(strings.TrimSpace(msg.Username))
+}
+
+// spamKey keeps the fields SetSpamScore needs to find a stored message.
+func spamKey(msg core.ChatMessage) core.ChatMessage {
+	return core.ChatMessage{
+		ID:            msg.ID,
+		PlatformMsgID: msg.PlatformMsgID,
+		Platform:      msg.Platform,
+		Channel:       msg.Channel,
+		Username:      msg.Username,
+		Text:          msg.Text,
+	}
+}
+
+// spamHash returns a 64-bit simhash of the lower-cased letters of text,
+// over 3-letter shingles with repeated letters collapsed. ok is false for
+// text with too few letters to compare.
+func spamHash(text string) (uint64, bool) {
+	var letters []rune
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		r = unicode.ToLower(r)
+		if n := len(letters); n > 0 && letters[n-1] == r {
+			continue
+		}
+		letters = append(letters, r)
+	}
+	if len(letters) < spamMinLetters {
+		return 0, false
+	}
+	var weights [64]int
+	h := fnv.New64a()
+	for i := 0; i+3 <= len(letters); i++ {
+		h.Reset()
+		h.Write([]byte(string(letters[i : i+3])))
+		sum := h.Sum64()
+		for b := 0; b < 64; b++ {
+			if sum&(1<<b) != 0 {
+				weights[b]++
+			} else {
+				weights[b]--
+			}
+		}
+	}
+	var out uint64
+	for b, w := range weights {
+		if w > 0 {
+			out |= 1 << b
+		}
+	}
+	return out, true
+}
+
+// SetSpamScore raises the stored spam score of msg, found by its platform
+// message ID, to msg.SpamScore.
+func (s *SQLiteSink) SetSpamScore(ctx context.Context, msg core.ChatMessage) error {
+	id := strings.TrimSpace(msg.PlatformMsgID)
+	if id == "" {
+		id = strings.TrimSpace(msg.ID)
+	}
+	if msg.Platform == "" || id == "" {
+		return errors.New("spam score requires a platform and message id")
+	}
+	err := withRetry(func() error {
+		_, err := s.db.ExecContext(ctx, `UPDATE messages SET spam_score = MAX(spam_score, ?) WHERE platform = ? AND platform_msg_id = ?;`,
+			msg.SpamScore, msg.Platform, id)
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "set spam score")
+	}
+	return nil
+}
+
+// AccountCreated returns when msg's author created their account, when a
+// profile lookup has recorded it in users.
+func (s *SQLiteSink) AccountCreated(ctx context.Context, msg core.ChatMessage) (time.Time, bool) {
+	key := strings.TrimSpace(msg.AuthorChannelID)
+	if key == "" {
+		key = s.usernames.Normalize(msg.Platform, msg.Username)
+	}
+	var createdMS int64
+	err := s.db.QueryRowContext(ctx, `SELECT account_created_at FROM users WHERE platform = ? AND user_key = ?;`,
+		msg.Platform, key).Scan(&createdMS)
+	if err != nil || createdMS <= 0 {
+		return time.Time{}, false
+	}
+	return time.UnixMilli(createdMS).UTC(), true
+}
+
+// ModerationSummary counts deleted messages and messages scored at least
+// minScore by the spam detector, listing up to bursts flagged texts with
+// the most messages.
+func (s *SQLiteSink) ModerationSummary(ctx context.Context, filters httpapi.Filters, minScore float64, bursts int) (httpapi.ModerationSummary, error) {
+	where, args := buildFilterWhere(httpapi.Filters{Platforms: filters.Platforms, Since: filters.Since, Until: filters.Until}, "ts")
+	clauses := []string{"1=1"}
+	in := func(column string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		placeholders := make([]string, 0, len(values))
+		for _, v := range values {
+			placeholders = append(placeholders, "?")
+			args = append(args, v)
+		}
+		clauses = append(clauses, column+" IN ("+strings.Join(placeholders, ",")+")")
+	}
+	in("session_id", filters.SessionIDs)
+	in("channel", filters.Channels)
+	if where == "" {
+		where = " WHERE " + strings.Join(clauses, " AND ")
+	} else {
+		where += " AND " + strings.Join(clauses, " AND ")
+	}
+	const account = "CASE WHEN user_id != '' THEN user_id ELSE username_norm END"
+
+	var out httpapi.ModerationSummary
+	err := s.db.QueryRowContext(ctx, `SELECT
+  COUNT(CASE WHEN deleted_at > 0 THEN 1 END),
+  COUNT(CASE WHEN spam_score >= ? THEN 1 END),
+  COUNT(DISTINCT CASE WHEN spam_score >= ? THEN platform || ':' || `+account+` END)
+FROM messages`+where+`;`, append([]any{minScore, minScore}, args...)...).Scan(&out.Deleted, &out.Spam.Messages, &out.Spam.Accounts)
+	if err != nil {
+		return httpapi.ModerationSummary{}, errors.Wrap(err, "moderation summary")
+	}
+	if out.Spam.Messages == 0 || bursts <= 0 {
+		return out, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT platform, MIN(text), COUNT(*), COUNT(DISTINCT `+account+`), MAX(spam_score), MIN(ts), MAX(ts)
+FROM messages`+where+` AND spam_score >= ?
+GROUP BY platform, LOWER(TRIM(text)) ORDER BY COUNT(*) DESC, MIN(ts) LIMIT ?;`, append(args, minScore, bursts)...)
+	if err != nil {
+		return httpapi.ModerationSummary{}, errors.Wrap(err, "list spam bursts")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			b               httpapi.SpamBurst
+			firstMS, lastMS int64
+		)
+		if err := rows.Scan(&b.Platform, &b.Text, &b.Messages, &b.Accounts, &b.MaxScore, &firstMS, &lastMS); err != nil {
+			return httpapi.ModerationSummary{}, errors.Wrap(err, "scan spam burst")
+		}
+		b.FirstSeen = time.UnixMilli(firstMS).UTC()
+		b.LastSeen = time.UnixMilli(lastMS).UTC()
+		out.Spam.Bursts = append(out.Spam.Bursts, b)
+	}
+	if err := rows.Err(); err != nil {
+		return httpapi.ModerationSummary{}, errors.Wrap(err, "iterate spam bursts")
+	}
+	return out, nil
+}
@@ -0,0 +1,80 @@
+package sink
+
+import (
+	"fmt"
+	"math/bits"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestSpamDetector(t *testing.T) {
+	type flag struct {
+		id      string
+		earlier bool
+	}
+	var flags []flag
+	base := time.Date(2026, 10, 15, 20, 0, 0, 0, time.UTC)
+	d := NewSpamDetector(SpamDetectorOptions{
+		Window:      10 * time.Second,
+		MinAccounts: 3,
+		AccountCreated: func(msg core.ChatMessage) (time.Time, bool) {
+			return base.Add(-time.Hour), msg.Username == "fresh"
+		},
+		OnFlag: func(msg core.ChatMessage, earlier bool) { flags = append(flags, flag{msg.ID, earlier}) },
+	})
+	send := func(id, user, text string, s int) core.ChatMessage {
+		t.Helper()
+		at := base.Add(time.Duration(s) * time.Second)
+		out, keep, err := d.Transform(core.ChatMessage{ID: id, Platform: "Twitch", Username: user, Text: text, ReceivedAt: &at})
+		if err != nil || !keep {
+			t.Fatalf("transform dropped %s: %v", id, err)
+		}
+		return out
+	}
+
+	// Short messages and one account repeating itself are not bursts.
+	for i := 0; i < 5; i++ {
+		if got := send(fmt.Sprint("gg", i), fmt.Sprint("user", i), "gg wp", 0); got.SpamScore != 0 {
+			t.Fatalf("short message scored %v", got.SpamScore)
+		}
+		if got := send(fmt.Sprint("rep", i), "looper", "follow my channel for free gifts", 0); got.SpamScore != 0 {
+			t.Fatalf("single account scored %v", got.SpamScore)
+		}
+	}
+	// Variations in digits, spacing, case and punctuation still match.
+	send("a", "bot_a", "Get FREE viewers at bigfollows dot com 123", 1)
+	if got := send("b", "bot_b", "get free viewers at  bigfollows dot com!!! 987", 2); got.SpamScore != 0 {
+		t.Fatalf("burst below MinAccounts scored %v", got.SpamScore)
+	}
+	flags = nil
+	got := send("c", "fresh", "GET FREE VIEWERS AT BIGFOLLOWS DOT COM 55", 3)
+	if got.SpamScore != spamBurstBase+spamNewAccountBoost {
+		t.Fatalf("new account in burst scored %v, want %v", got.SpamScore, spamBurstBase+spamNewAccountBoost)
+	}
+	// The current message and the burst's earlier ones, except "looper"'s
+	// unrelated text, are flagged.
+	if len(flags) != 3 || flags[0] != (flag{"c", false}) || flags[1] != (flag{"a", true}) || flags[2] != (flag{"b", true}) {
+		t.Fatalf("unexpected flags %+v", flags)
+	}
+	if got := send("d", "bot_d", "get free viewers at bigfollows dot com", 4); got.SpamScore <= spamBurstBase {
+		t.Fatalf("growing burst scored %v", got.SpamScore)
+	}
+	// Outside the window the burst has aged out.
+	if got := send("e", "bot_e", "get free viewers at bigfollows dot com", 30); got.SpamScore != 0 {
+		t.Fatalf("late message scored %v", got.SpamScore)
+	}
+}
+
+func TestSpamHashSimilarity(t *testing.T) {
+	a, _ := spamHash("Wow this stream is amazing, check out my channel")
+	b, _ := spamHash("wow this stream is amazing check out my channel 4821")
+	c, _ := spamHash("does anyone know what keyboard the streamer uses?")
+	if d := bits.OnesCount64(a ^ b); d > spamMaxDistance {
+		t.Fatalf("variants differ by %d bits", d)
+	}
+	if d := bits.OnesCount64(a ^ c); d <= spamMaxDistance {
+		t.Fatalf("unrelated messages differ by only %d bits", d)
+	}
+}
@@ -6,8 +6,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -29,21 +32,116 @@ const schema = `CREATE TABLE IF NOT EXISTS messages (
   emotes_json TEXT NOT NULL DEFAULT '[]',
   raw_json TEXT NOT NULL DEFAULT '',
   badges_json TEXT NOT NULL DEFAULT '[]',
-  colour TEXT NOT NULL DEFAULT ''
+  colour TEXT NOT NULL DEFAULT '',
+  username_norm TEXT NOT NULL DEFAULT '',
+  author_channel_id TEXT NOT NULL DEFAULT '',
+  avatar_url TEXT NOT NULL DEFAULT '',
+  session_id TEXT NOT NULL DEFAULT '',
+  edited_at INTEGER NOT NULL DEFAULT 0,
+  deleted_at INTEGER NOT NULL DEFAULT 0,
+  deleted_by TEXT NOT NULL DEFAULT '',
+  message_type TEXT NOT NULL DEFAULT '',
+  channel TEXT NOT NULL DEFAULT '',
+  user_id TEXT NOT NULL DEFAULT '',
+  channel_id TEXT NOT NULL DEFAULT '',
+  received_at INTEGER NOT NULL DEFAULT 0,
+  mirror_of TEXT NOT NULL DEFAULT '',
+  paid_amount TEXT NOT NULL DEFAULT '',
+  reward_id TEXT NOT NULL DEFAULT '',
+  spam_score REAL NOT NULL DEFAULT 0,
+  ulid TEXT NOT NULL DEFAULT ''
 );`
 
+// auxiliarySchemas creates the tables that sit alongside messages.
+var auxiliarySchemas = []string{
+	overlaySchema,
+	usersSchema,
+	streamStateSchema,
+	sessionsSchema,
+	momentsSchema,
+	leasesSchema,
+	editsSchema,
+	viewerSamplesSchema,
+	markersSchema,
+	pollsSchema,
+	eventsSchema,
+	presenceSchema,
+	linksSchema,
+	chainSchema,
+	autoModSchema,
+	ttsSchema,
+	redemptionsSchema,
+	youtubeCheckpointsSchema,
+}
+
+type addedColumn struct {
+	name string
+	ddl  string
+}
+
+// messageColumns lists columns added to messages after the original schema.
+// OpenSQLite adds any that are missing so older databases keep working.
+var messageColumns = []addedColumn{
+	{"username_norm", `ALTER TABLE messages ADD COLUMN username_norm TEXT NOT NULL DEFAULT '';`},
+	{"author_channel_id", `ALTER TABLE messages ADD COLUMN author_channel_id TEXT NOT NULL DEFAULT '';`},
+	{"avatar_url", `ALTER TABLE messages ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';`},
+	{"session_id", `ALTER TABLE messages ADD COLUMN session_id TEXT NOT NULL DEFAULT '';`},
+	{"edited_at", `ALTER TABLE messages ADD COLUMN edited_at INTEGER NOT NULL DEFAULT 0;`},
+	{"deleted_at", `ALTER TABLE messages ADD COLUMN deleted_at INTEGER NOT NULL DEFAULT 0;`},
+	{"deleted_by", `ALTER TABLE messages ADD COLUMN deleted_by TEXT NOT NULL DEFAULT '';`},
+	{"message_type", `ALTER TABLE messages ADD COLUMN message_type TEXT NOT NULL DEFAULT '';`},
+	{"channel", `ALTER TABLE messages ADD COLUMN channel TEXT NOT NULL DEFAULT '';`},
+	{"user_id", `ALTER TABLE messages ADD COLUMN user_id TEXT NOT NULL DEFAULT '';`},
+	{"channel_id", `ALTER TABLE messages ADD COLUMN channel_id TEXT NOT NULL DEFAULT '';`},
+	{"received_at", `ALTER TABLE messages ADD COLUMN received_at INTEGER NOT NULL DEFAULT 0;`},
+	{"mirror_of", `ALTER TABLE messages ADD COLUMN mirror_of TEXT NOT NULL DEFAULT '';`},
+	{"paid_amount", `ALTER TABLE messages ADD COLUMN paid_amount TEXT NOT NULL DEFAULT '';`},
+	{"reward_id", `ALTER TABLE messages ADD COLUMN reward_id TEXT NOT NULL DEFAULT '';`},
+	{"spam_score", `ALTER TABLE messages ADD COLUMN spam_score REAL NOT NULL DEFAULT 0;`},
+	{"ulid", `ALTER TABLE messages ADD COLUMN ulid TEXT NOT NULL DEFAULT '';`},
+}
+
+// receivedAtExpr is a message's receive time in epoch ms. Rows stored before
+// receive times were recorded fall back to the platform timestamp.
+const receivedAtExpr = "COALESCE(NULLIF(received_at, 0), ts)"
+
 type SQLiteSink struct {
-	db *sql.DB
+	db        *sql.DB
+	path      string
+	usernames core.UsernameNormalizer
+
+	sessionMu sync.RWMutex
+	sessions  map[string]string // platform -> active session id
+	// onSession is called after RecordStreamState opens or closes a session.
+	onSession func(st core.StreamState, sessionID string)
+	// onWrite is called once a message is stored.
+	onWrite func(msg core.ChatMessage, written time.Time)
+
+	// hashChain links new messages into per-session hash chains; chainMu
+	// serializes writes while it is set.
+	hashChain bool
+	chainMu   sync.Mutex
 }
 
 const defaultListLimit = 100
 
 func OpenSQLite(path string) (*SQLiteSink, error) {
-	dsn := path
+	return openSQLite(path, "_busy_timeout=5000&_journal_mode=wal")
+}
+
+// OpenSharedSQLite opens path for sharing with other processes that write to
+// the same file, such as elora-chat. Every pooled connection waits up to
+// busyTimeout for another writer's lock, and write transactions take the
+// write lock when they begin, so a busy file delays writes instead of
+// failing them partway through a batch.
+func OpenSharedSQLite(path string, busyTimeout time.Duration) (*SQLiteSink, error) {
+	return openSQLite(path, fmt.Sprintf("_pragma=busy_timeout(%d)&_txlock=immediate&_journal_mode=wal", busyTimeout.Milliseconds()))
+}
+
+func openSQLite(path, params string) (*SQLiteSink, error) {
+	dsn := path + "?" + params
 	if strings.Contains(path, "?") {
-		dsn = path + "&_busy_timeout=5000&_journal_mode=wal"
-	} else {
-		dsn = path + "?_busy_timeout=5000&_journal_mode=wal"
+		dsn = path + "&" + params
 	}
 
 	db, err := sql.Open("sqlite", dsn)
@@ -58,16 +156,93 @@ func OpenSQLite(path string) (*SQLiteSink, error) {
 		_ = db.Close()
 		return nil, errors.Wrapf(err, "migrate legacy schema (%s)", path)
 	}
+	if err := ensureMessageColumns(context.Background(), db); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrapf(err, "ensure columns (%s)", path)
+	}
 	if err := ensureIndices(context.Background(), db); err != nil {
 		_ = db.Close()
 		return nil, errors.Wrapf(err, "ensure indices (%s)", path)
 	}
+	if err := assignMissingULIDs(context.Background(), db); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrapf(err, "assign ulids (%s)", path)
+	}
+	for _, stmt := range auxiliarySchemas {
+		if _, err := db.Exec(stmt); err != nil {
+			_ = db.Close()
+			return nil, errors.Wrapf(err, "apply auxiliary schema (%s)", path)
+		}
+	}
+	if err := ensureColumns(context.Background(), db, "users", userColumns); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrapf(err, "ensure user columns (%s)", path)
+	}
+	if err := ensureColumns(context.Background(), db, "sessions", sessionColumns); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrapf(err, "ensure session columns (%s)", path)
+	}
 	if _, err := db.Exec(`PRAGMA journal_mode=wal;`); err != nil {
 		_ = db.Close()
 		return nil, errors.Wrapf(err, "set WAL (%s)", path)
 	}
 	ApplySQLitePragmas(context.Background(), db)
-	return &SQLiteSink{db: db}, nil
+	s := &SQLiteSink{db: db, path: path, usernames: core.DefaultUsernameNormalizer()}
+	if err := s.loadActiveSessions(context.Background()); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrapf(err, "load sessions (%s)", path)
+	}
+	return s, nil
+}
+
+// SetUsernameNormalizer replaces the rules used to derive username_norm for
+// newly written rows.
+func (s *SQLiteSink) SetUsernameNormalizer(n core.UsernameNormalizer) {
+	s.usernames = n
+}
+
+// SetSessionHook registers fn to be called with the live/ended state and
+// session ID whenever RecordStreamState opens or closes a broadcast session.
+// It must be set before states are recorded.
+func (s *SQLiteSink) SetSessionHook(fn func(st core.StreamState, sessionID string)) {
+	s.onSession = fn
+}
+
+// SetWriteHook registers fn to be called with each message once it is
+// stored (after the transaction commits for WriteBatch) and the time it was
+// written. It must be set before messages are written.
+func (s *SQLiteSink) SetWriteHook(fn func(msg core.ChatMessage, written time.Time)) {
+	s.onWrite = fn
+}
+
+// CheckSQLite verifies that path can be used as a SQLite sink without
+// modifying it: an existing database must open read-only and answer a query,
+// while a missing one must live in a writable directory.
+func CheckSQLite(path string) error {
+	if _, err := os.Stat(path); err != nil {
+		if !os.IsNotExist(err) {
+			return errors.Wrap(err, "stat sqlite")
+		}
+		probe, err := os.CreateTemp(filepath.Dir(path), ".gnasty-check-*")
+		if err != nil {
+			return errors.Wrap(err, "sqlite directory not writable")
+		}
+		name := probe.Name()
+		_ = probe.Close()
+		_ = os.Remove(name)
+		return nil
+	}
+
+	db, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return errors.Wrap(err, "open sqlite")
+	}
+	defer db.Close()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master;`).Scan(&n); err != nil {
+		return errors.Wrap(err, "query sqlite")
+	}
+	return nil
 }
 
 func (s *SQLiteSink) RawDB() *sql.DB { return s.db }
@@ -79,6 +254,20 @@ func ensureIndices(ctx context.Context, db *sql.DB) error {
            ON messages(platform, platform_msg_id);`,
 		`CREATE UNIQUE INDEX IF NOT EXISTS messages_upsert_key
            ON messages(platform, ts, username, text);`,
+		`CREATE INDEX IF NOT EXISTS messages_username_norm
+           ON messages(username_norm);`,
+		`CREATE INDEX IF NOT EXISTS messages_session_id
+           ON messages(session_id);`,
+		`CREATE INDEX IF NOT EXISTS messages_author_channel_id
+           ON messages(platform, author_channel_id);`,
+		`CREATE INDEX IF NOT EXISTS messages_user_id
+           ON messages(platform, user_id);`,
+		`CREATE INDEX IF NOT EXISTS messages_channel_id
+           ON messages(platform, channel_id);`,
+		`CREATE INDEX IF NOT EXISTS messages_received_at
+           ON messages(` + receivedAtExpr + `);`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS messages_ulid
+           ON messages(ulid) WHERE ulid != '';`,
 	}
 	for _, stmt := range stmts {
 		if _, err := db.ExecContext(ctx, stmt); err != nil {
@@ -88,6 +277,79 @@ func ensureIndices(ctx context.Context, db *sql.DB) error {
 	return nil
 }
 
+func ensureMessageColumns(ctx context.Context, db *sql.DB) error {
+	return ensureColumns(ctx, db, "messages", messageColumns)
+}
+
+// ensureColumns adds any of cols missing from table.
+func ensureColumns(ctx context.Context, db *sql.DB, table string, cols []addedColumn) error {
+	columns, err := inspectTableColumns(ctx, db, table)
+	if err != nil {
+		return err
+	}
+	for _, col := range cols {
+		if _, ok := columns[col.name]; ok {
+			continue
+		}
+		if _, err := db.ExecContext(ctx, col.ddl); err != nil {
+			return errors.Wrapf(err, "add column %s.%s", table, col.name)
+		}
+	}
+	return nil
+}
+
+// assignMissingULIDs gives rows stored before messages carried ULIDs, or by
+// tools that do not set them, one based on their timestamp. Rows are updated
+// in batches so a large archive is not held in one transaction.
+func assignMissingULIDs(ctx context.Context, db *sql.DB) error {
+	const batch = 1000
+	var lastID int64
+	for {
+		rows, err := db.QueryContext(ctx, `SELECT id, ts FROM messages WHERE ulid = '' AND id > ? ORDER BY id LIMIT ?;`, lastID, batch)
+		if err != nil {
+			return errors.Wrap(err, "find rows without ulid")
+		}
+		var (
+			ids []int64
+			tss []int64
+		)
+		for rows.Next() {
+			var id, ts int64
+			if err := rows.Scan(&id, &ts); err != nil {
+				rows.Close()
+				return errors.Wrap(err, "scan row without ulid")
+			}
+			ids = append(ids, id)
+			tss = append(tss, ts)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return errors.Wrap(err, "iterate rows without ulid")
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		lastID = ids[len(ids)-1]
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return errors.Wrap(err, "begin ulid batch")
+		}
+		for i, id := range ids {
+			if _, err := tx.ExecContext(ctx, `UPDATE messages SET ulid = ? WHERE id = ?;`, core.NewID(time.UnixMilli(tss[i])), id); err != nil {
+				_ = tx.Rollback()
+				return errors.Wrapf(err, "assign ulid to row %d", id)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return errors.Wrap(err, "commit ulid batch")
+		}
+		if len(ids) < batch {
+			return nil
+		}
+	}
+}
+
 func (s *SQLiteSink) Close() error { return s.db.Close() }
 
 func migrateLegacyMessagesTable(ctx context.Context, db *sql.DB) error {
@@ -221,9 +483,13 @@ func migrateLegacyMessagesTable(ctx context.Context, db *sql.DB) error {
 }
 
 func inspectMessagesColumns(ctx context.Context, db *sql.DB) (map[string]string, error) {
-	rows, err := db.QueryContext(ctx, `PRAGMA table_info(messages);`)
+	return inspectTableColumns(ctx, db, "messages")
+}
+
+func inspectTableColumns(ctx context.Context, db *sql.DB, table string) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, `PRAGMA table_info(`+table+`);`)
 	if err != nil {
-		return nil, errors.Wrap(err, "inspect messages table info")
+		return nil, errors.Wrapf(err, "inspect %s table info", table)
 	}
 	defer rows.Close()
 
@@ -238,12 +504,12 @@ func inspectMessagesColumns(ctx context.Context, db *sql.DB) (map[string]string,
 			pk         int
 		)
 		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
-			return nil, errors.Wrap(err, "scan messages table info")
+			return nil, errors.Wrapf(err, "scan %s table info", table)
 		}
 		columns[strings.ToLower(strings.TrimSpace(name))] = strings.TrimSpace(colType)
 	}
 	if err := rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "iterate messages table info")
+		return nil, errors.Wrapf(err, "iterate %s table info", table)
 	}
 	return columns, nil
 }
@@ -281,7 +547,100 @@ func legacyTimestampToMillis(raw string) (int64, error) {
 	return 0, fmt.Errorf("unrecognised legacy timestamp %q", raw)
 }
 
+// execer is satisfied by *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
 func (s *SQLiteSink) Write(msg core.ChatMessage, trace *ingesttrace.MessageTrace) error {
+	if s.hashChain {
+		// The message and its chain link are written together.
+		return s.WriteBatch([]core.ChatMessage{msg}, []*ingesttrace.MessageTrace{trace})
+	}
+	var ins messageInsert
+	err := withRetry(func() error {
+		var execErr error
+		ins, execErr = s.insertMessage(s.db, msg, trace)
+		return execErr
+	})
+	if err != nil {
+		return errors.Wrap(err, "insert message")
+	}
+	if s.onWrite != nil {
+		s.onWrite(msg, time.Now())
+	}
+	if ins.username == "" {
+		return nil
+	}
+	err = withRetry(func() error {
+		return ins.upsertUser(s.db)
+	})
+	return errors.Wrap(err, "upsert user")
+}
+
+// WriteBatch stores msgs in a single transaction, which is much cheaper than
+// one implicit transaction per Write. traces may be nil or parallel to msgs.
+func (s *SQLiteSink) WriteBatch(msgs []core.ChatMessage, traces []*ingesttrace.MessageTrace) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	if s.hashChain {
+		s.chainMu.Lock()
+		defer s.chainMu.Unlock()
+	}
+	err := withRetry(func() error {
+		tx, err := s.db.Begin()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = tx.Rollback() }()
+		for i, msg := range msgs {
+			var trace *ingesttrace.MessageTrace
+			if i < len(traces) {
+				trace = traces[i]
+			}
+			ins, err := s.insertMessage(tx, msg, trace)
+			if err != nil {
+				return errors.Wrap(err, "insert message")
+			}
+			if ins.username == "" {
+				continue
+			}
+			if err := ins.upsertUser(tx); err != nil {
+				return errors.Wrap(err, "upsert user")
+			}
+		}
+		return tx.Commit()
+	})
+	if err != nil {
+		return errors.Wrap(err, "write batch")
+	}
+	if s.onWrite != nil {
+		written := time.Now()
+		for _, msg := range msgs {
+			s.onWrite(msg, written)
+		}
+	}
+	return nil
+}
+
+// messageInsert carries the normalized fields needed for the users upsert
+// that follows a message insert.
+type messageInsert struct {
+	platform        string
+	username        string
+	usernameNorm    string
+	authorChannelID string
+	avatarURL       string
+	tsMS            int64
+}
+
+func (m messageInsert) upsertUser(db execer) error {
+	return upsertUser(db, m.platform, m.username, m.usernameNorm, m.authorChannelID, m.avatarURL, m.tsMS)
+}
+
+func (s *SQLiteSink) insertMessage(db execer, msg core.ChatMessage, trace *ingesttrace.MessageTrace) (messageInsert, error) {
 	tsMS := msg.TimestampMS
 	if tsMS == 0 {
 		if !msg.Ts.IsZero() {
@@ -290,9 +649,14 @@ func (s *SQLiteSink) Write(msg core.ChatMessage, trace *ingesttrace.MessageTrace
 			tsMS = time.Now().UTC().UnixMilli()
 		}
 	}
+	receivedMS := time.Now().UTC().UnixMilli()
+	if msg.ReceivedAt != nil && !msg.ReceivedAt.IsZero() {
+		receivedMS = msg.ReceivedAt.UTC().UnixMilli()
+	}
 
 	platform := strings.TrimSpace(msg.Platform)
 	username := strings.TrimSpace(msg.Username)
+	usernameNorm := s.usernames.Normalize(platform, username)
 	text := msg.Text
 
 	platformMsgID := strings.TrimSpace(msg.PlatformMsgID)
@@ -303,53 +667,137 @@ func (s *SQLiteSink) Write(msg core.ChatMessage, trace *ingesttrace.MessageTrace
 	emotesJSON := jsonText(msg.EmotesJSON, msg.Emotes, "[]")
 	badgesJSON := encodeBadgesJSON(msg)
 	rawJSON := jsonText(msg.RawJSON, msg.Raw, "")
+	core.AssignULID(&msg)
 
 	conflict := `ON CONFLICT(platform, ts, username, text) DO NOTHING`
 	var (
 		platformMsgArg any
+		conflictArgs   []any
 	)
 	if platformMsgID != "" {
+		// Re-deliveries and replays often lack fields the live copy had, so
+		// empty values never replace stored ones. The stored timestamp is the
+		// live one, and a colour the ColourTransformer generated never
+		// replaces one the platform sent.
 		conflict = `ON CONFLICT(platform, platform_msg_id) DO UPDATE SET
-            ts=excluded.ts,
-            username=excluded.username,
-            text=excluded.text,
-            emotes_json=excluded.emotes_json,
-            raw_json=excluded.raw_json,
-            badges_json=excluded.badges_json,
-            colour=excluded.colour`
+            username=COALESCE(NULLIF(excluded.username, ''), messages.username),
+            text=COALESCE(NULLIF(excluded.text, ''), messages.text),
+            emotes_json=COALESCE(NULLIF(excluded.emotes_json, '[]'), messages.emotes_json),
+            raw_json=COALESCE(NULLIF(excluded.raw_json, ''), messages.raw_json),
+            badges_json=COALESCE(NULLIF(excluded.badges_json, '[]'), messages.badges_json),
+            colour=CASE WHEN excluded.colour IN ('', ?)
+                THEN COALESCE(NULLIF(messages.colour, ''), excluded.colour)
+                ELSE excluded.colour END,
+            username_norm=COALESCE(NULLIF(excluded.username_norm, ''), messages.username_norm),
+            author_channel_id=COALESCE(NULLIF(excluded.author_channel_id, ''), messages.author_channel_id),
+            avatar_url=COALESCE(NULLIF(excluded.avatar_url, ''), messages.avatar_url),
+            message_type=COALESCE(NULLIF(excluded.message_type, ''), messages.message_type),
+            channel=COALESCE(NULLIF(excluded.channel, ''), messages.channel),
+            user_id=COALESCE(NULLIF(excluded.user_id, ''), messages.user_id),
+            channel_id=COALESCE(NULLIF(excluded.channel_id, ''), messages.channel_id),
+            paid_amount=COALESCE(NULLIF(excluded.paid_amount, ''), messages.paid_amount),
+            reward_id=COALESCE(NULLIF(excluded.reward_id, ''), messages.reward_id),
+            spam_score=MAX(messages.spam_score, excluded.spam_score),
+            session_id=COALESCE(NULLIF(excluded.session_id, ''), messages.session_id)`
 		platformMsgArg = platformMsgID
+		conflictArgs = []any{core.DefaultColour(msg.Platform, msg.UserID, msg.Username)}
 	} else {
 		platformMsgArg = nil
 	}
 
 	query := fmt.Sprintf(`INSERT INTO messages (
-platform, platform_msg_id, ts, username, text, emotes_json, raw_json, badges_json, colour
-) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) %s;`, conflict)
+platform, platform_msg_id, ts, username, text, emotes_json, raw_json, badges_json, colour, username_norm,
+author_channel_id, avatar_url, session_id, message_type, channel, user_id, channel_id, received_at, mirror_of, paid_amount, reward_id, spam_score, ulid
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) %s;`, conflict)
 
-	err := withRetry(func() error {
-		res, execErr := s.db.Exec(query,
-			platform,
-			platformMsgArg,
-			tsMS,
-			username,
-			text,
-			emotesJSON,
-			rawJSON,
-			badgesJSON,
-			msg.Colour,
-		)
-		if execErr != nil {
-			return execErr
+	authorChannelID := strings.TrimSpace(msg.AuthorChannelID)
+	avatarURL := strings.TrimSpace(msg.AvatarURL)
+	sessionID := strings.TrimSpace(msg.SessionID)
+	if sessionID == "" {
+		sessionID = s.activeSession(platform)
+	}
+
+	args := []any{
+		platform,
+		platformMsgArg,
+		tsMS,
+		username,
+		text,
+		emotesJSON,
+		rawJSON,
+		badgesJSON,
+		msg.Colour,
+		usernameNorm,
+		authorChannelID,
+		avatarURL,
+		sessionID,
+		core.NormalizeMessageType(msg.MessageType),
+		strings.ToLower(strings.TrimSpace(msg.Channel)),
+		strings.TrimSpace(msg.UserID),
+		strings.TrimSpace(msg.ChannelID),
+		receivedMS,
+		msg.MirrorOf,
+		strings.TrimSpace(msg.PaidAmount),
+		strings.TrimSpace(msg.RewardID),
+		msg.SpamScore,
+		msg.ULID,
+	}
+	res, err := db.Exec(query, append(args, conflictArgs...)...)
+	if err != nil {
+		return messageInsert{}, err
+	}
+	links := extractLinks(text)
+	if n, _ := res.RowsAffected(); n > 0 && (len(links) > 0 || s.hashChain) {
+		rowID, err := storedRowID(db, res, platform, platformMsgID)
+		if err != nil {
+			return messageInsert{}, errors.Wrap(err, "stored row id")
+		}
+		if err := insertLinks(db, rowID, links); err != nil {
+			return messageInsert{}, err
+		}
+		if s.hashChain {
+			err := appendChain(db, rowID, chainName(platform, sessionID), chainedFields{
+				Platform:        platform,
+				PlatformMsgID:   platformMsgID,
+				TsMS:            tsMS,
+				Username:        username,
+				Text:            text,
+				MessageType:     core.NormalizeMessageType(msg.MessageType),
+				Channel:         strings.ToLower(strings.TrimSpace(msg.Channel)),
+				UserID:          strings.TrimSpace(msg.UserID),
+				ChannelID:       strings.TrimSpace(msg.ChannelID),
+				AuthorChannelID: authorChannelID,
+			})
+			if err != nil {
+				return messageInsert{}, err
+			}
 		}
+	}
+	if trace != nil {
 		rowID, _ := res.LastInsertId()
 		rows, _ := res.RowsAffected()
-		if trace != nil {
-			trace.IncCounter(ingesttrace.StageWrittenToDB)
-			slog.Info("sqlite: wrote message", "trace_id", trace.TraceID, "row_id", rowID, "rows_affected", rows, "platform", platform)
-		}
-		return nil
-	})
-	return errors.Wrap(err, "insert message")
+		trace.IncCounter(ingesttrace.StageWrittenToDB)
+		slog.Info("sqlite: wrote message", "trace_id", trace.TraceID, "row_id", rowID, "rows_affected", rows, "platform", platform)
+	}
+	return messageInsert{
+		platform:        platform,
+		username:        username,
+		usernameNorm:    usernameNorm,
+		authorChannelID: authorChannelID,
+		avatarURL:       avatarURL,
+		tsMS:            tsMS,
+	}, nil
+}
+
+// storedRowID returns the id of the row res inserted or, for messages with a
+// platform ID, upserted: an upsert does not report the row it updated.
+func storedRowID(db execer, res sql.Result, platform, platformMsgID string) (int64, error) {
+	if platformMsgID == "" {
+		return res.LastInsertId()
+	}
+	var id int64
+	err := db.QueryRow(`SELECT id FROM messages WHERE platform = ? AND platform_msg_id = ?;`, platform, platformMsgID).Scan(&id)
+	return id, err
 }
 
 func jsonText(encoded string, value any, empty string) string {
@@ -370,6 +818,8 @@ func (s *SQLiteSink) Ping() error {
 	return s.db.Ping()
 }
 
+// withRetry runs fn, retrying while SQLite reports SQLITE_BUSY. Failures are
+// tagged core.ErrorStorage.
 func withRetry(fn func() error) error {
 	const max = 5
 	for i := 0; i < max; i++ {
@@ -378,11 +828,11 @@ func withRetry(fn func() error) error {
 				time.Sleep(time.Duration(100*(i+1)) * time.Millisecond)
 				continue
 			}
-			return err
+			return core.NewError(core.ErrorStorage, err)
 		}
 		return nil
 	}
-	return fmt.Errorf("exhausted retries (SQLITE_BUSY)")
+	return core.NewError(core.ErrorStorage, fmt.Errorf("exhausted retries (SQLITE_BUSY)"))
 }
 
 func (s *SQLiteSink) String() string {
@@ -487,6 +937,74 @@ func (s *SQLiteSink) CountMessages(ctx context.Context, filters httpapi.Filters)
 	return n, nil
 }
 
+// Stats reports the total stored message count, per-platform counts for
+// messages newer than window, and the on-disk database size.
+func (s *SQLiteSink) Stats(ctx context.Context, window time.Duration) (httpapi.StoreStats, error) {
+	stats := httpapi.StoreStats{RecentByPlatform: map[string]int64{}}
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages;`).Scan(&stats.TotalMessages); err != nil {
+		return httpapi.StoreStats{}, errors.Wrap(err, "stats total")
+	}
+
+	since := time.Now().Add(-window).UTC().UnixMilli()
+	rows, err := s.db.QueryContext(ctx, `SELECT platform, COUNT(*) FROM messages WHERE ts >= ? GROUP BY platform;`, since)
+	if err != nil {
+		return httpapi.StoreStats{}, errors.Wrap(err, "stats recent")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			platform string
+			n        int64
+		)
+		if err := rows.Scan(&platform, &n); err != nil {
+			return httpapi.StoreStats{}, errors.Wrap(err, "scan stats recent")
+		}
+		stats.RecentByPlatform[platform] = n
+	}
+	if err := rows.Err(); err != nil {
+		return httpapi.StoreStats{}, errors.Wrap(err, "iterate stats recent")
+	}
+
+	var pageCount, pageSize int64
+	if err := s.db.QueryRowContext(ctx, `PRAGMA page_count;`).Scan(&pageCount); err == nil {
+		if err := s.db.QueryRowContext(ctx, `PRAGMA page_size;`).Scan(&pageSize); err == nil {
+			stats.DBSizeBytes = pageCount * pageSize
+		}
+	}
+	return stats, nil
+}
+
+// MessagesVersion summarises the rows matching filters (ignoring limit and
+// order) so callers can derive cache validators. Deleted rows are always
+// scanned so that a deletion changes the version even when the response
+// hides them.
+func (s *SQLiteSink) MessagesVersion(ctx context.Context, filters httpapi.Filters) (httpapi.MessagesVersion, error) {
+	count := "COUNT(*)"
+	if !filters.ShowsDeleted() {
+		count = "COALESCE(SUM(deleted_at = 0), 0)"
+		filters.IncludeDeleted = true
+	}
+	where, args := buildMessageWhere(filters)
+	query := "SELECT COALESCE(MAX(id), 0), " + count + ", COALESCE(MAX(ts), 0), COALESCE(MAX(edited_at), 0), COALESCE(MAX(deleted_at), 0) FROM messages" + where + ";"
+	var (
+		v                      httpapi.MessagesVersion
+		tsMS, editMS, deleteMS int64
+	)
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(&v.LatestID, &v.Count, &tsMS, &editMS, &deleteMS); err != nil {
+		return httpapi.MessagesVersion{}, errors.Wrap(err, "messages version")
+	}
+	if tsMS > 0 {
+		v.LatestTs = time.UnixMilli(tsMS).UTC()
+	}
+	if editMS > 0 {
+		v.LatestEdit = time.UnixMilli(editMS).UTC()
+	}
+	if deleteMS > 0 {
+		v.LatestDelete = time.UnixMilli(deleteMS).UTC()
+	}
+	return v, nil
+}
+
 func (s *SQLiteSink) ListMessages(ctx context.Context, filters httpapi.Filters) ([]core.ChatMessage, error) {
 	query, args := buildMessageQuery(filters, false)
 	rows, err := s.db.QueryContext(ctx, query, args...)
@@ -495,7 +1013,27 @@ func (s *SQLiteSink) ListMessages(ctx context.Context, filters httpapi.Filters)
 	}
 	defer rows.Close()
 
-	var out []core.ChatMessage
+	out, rowIDs, err := scanMessageRows(rows)
+	if err != nil {
+		return nil, err
+	}
+	if filters.IncludeEdits || filters.Original {
+		if err := s.applyEdits(ctx, out, rowIDs, filters.IncludeEdits, filters.Original); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// messageSelect lists the columns scanMessageRows expects.
+const messageSelect = "SELECT id, platform_msg_id, ts, username, platform, text, emotes_json, raw_json, badges_json, colour, author_channel_id, avatar_url, session_id, edited_at, deleted_at, deleted_by, message_type, channel, user_id, channel_id, received_at, mirror_of, paid_amount, reward_id, spam_score, ulid FROM messages"
+
+// scanMessageRows decodes messageSelect rows, also returning each row's id.
+func scanMessageRows(rows *sql.Rows) ([]core.ChatMessage, []int64, error) {
+	var (
+		out    []core.ChatMessage
+		rowIDs []int64
+	)
 	for rows.Next() {
 		var (
 			msg           core.ChatMessage
@@ -506,6 +1044,9 @@ func (s *SQLiteSink) ListMessages(ctx context.Context, filters httpapi.Filters)
 			rawJSON       string
 			badgesJSON    string
 			colour        string
+			editedAtMS    int64
+			deletedAtMS   int64
+			receivedAtMS  int64
 		)
 		if err := rows.Scan(
 			&rowID,
@@ -518,8 +1059,36 @@ func (s *SQLiteSink) ListMessages(ctx context.Context, filters httpapi.Filters)
 			&rawJSON,
 			&badgesJSON,
 			&colour,
+			&msg.AuthorChannelID,
+			&msg.AvatarURL,
+			&msg.SessionID,
+			&editedAtMS,
+			&deletedAtMS,
+			&msg.DeletedBy,
+			&msg.MessageType,
+			&msg.Channel,
+			&msg.UserID,
+			&msg.ChannelID,
+			&receivedAtMS,
+			&msg.MirrorOf,
+			&msg.PaidAmount,
+			&msg.RewardID,
+			&msg.SpamScore,
+			&msg.ULID,
 		); err != nil {
-			return nil, errors.Wrap(err, "scan message")
+			return nil, nil, errors.Wrap(err, "scan message")
+		}
+		if editedAtMS > 0 {
+			editedAt := time.UnixMilli(editedAtMS).UTC()
+			msg.EditedAt = &editedAt
+		}
+		if deletedAtMS > 0 {
+			deletedAt := time.UnixMilli(deletedAtMS).UTC()
+			msg.DeletedAt = &deletedAt
+		}
+		if receivedAtMS > 0 {
+			receivedAt := time.UnixMilli(receivedAtMS).UTC()
+			msg.ReceivedAt = &receivedAt
 		}
 		msg.TimestampMS = tsMS
 		if tsMS > 0 {
@@ -538,13 +1107,15 @@ func (s *SQLiteSink) ListMessages(ctx context.Context, filters httpapi.Filters)
 		msg.BadgesJSON = badgesJSON
 		msg.Badges, msg.BadgesRaw = decodeBadgesJSON(badgesJSON, msg.Platform)
 		msg.Colour = colour
+		msg.MessageType = core.NormalizeMessageType(msg.MessageType)
 		out = append(out, msg)
+		rowIDs = append(rowIDs, rowID)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, errors.Wrap(err, "iterate messages")
+		return nil, nil, errors.Wrap(err, "iterate messages")
 	}
-	return out, nil
+	return out, rowIDs, nil
 }
 
 func buildMessageQuery(filters httpapi.Filters, count bool) (string, []any) {
@@ -552,9 +1123,122 @@ func buildMessageQuery(filters httpapi.Filters, count bool) (string, []any) {
 	if count {
 		builder.WriteString("SELECT COUNT(*) FROM messages")
 	} else {
-		builder.WriteString("SELECT id, platform_msg_id, ts, username, platform, text, emotes_json, raw_json, badges_json, colour FROM messages")
+		builder.WriteString(messageSelect)
+	}
+
+	where, args := buildMessageWhere(filters)
+	builder.WriteString(where)
+
+	if !count {
+		order := "DESC"
+		if filters.Order == httpapi.OrderAsc {
+			order = "ASC"
+		}
+		builder.WriteString(" ORDER BY ")
+		builder.WriteString(messageTimeColumn(filters))
+		builder.WriteString(" ")
+		builder.WriteString(order)
+		limit := filters.Limit
+		if limit <= 0 {
+			limit = defaultListLimit
+		}
+		builder.WriteString(" LIMIT ?")
+		args = append(args, limit)
 	}
 
+	builder.WriteString(";")
+	return builder.String(), args
+}
+
+// buildMessageWhere renders the WHERE clause (with leading space) shared by
+// every filtered messages query. Soft-deleted rows and whispers are excluded
+// unless the filters ask for them. Rows stored without a message type count
+// as chat.
+func buildMessageWhere(filters httpapi.Filters) (string, []any) {
+	where, args := buildFilterWhere(filters, messageTimeColumn(filters))
+	var clauses []string
+	if len(filters.SessionIDs) > 0 {
+		placeholders := make([]string, 0, len(filters.SessionIDs))
+		for _, id := range filters.SessionIDs {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		clauses = append(clauses, fmt.Sprintf("session_id IN (%s)", strings.Join(placeholders, ",")))
+	}
+	if len(filters.Channels) > 0 {
+		placeholders := make([]string, 0, len(filters.Channels))
+		for _, channel := range filters.Channels {
+			placeholders = append(placeholders, "?")
+			args = append(args, channel)
+		}
+		clauses = append(clauses, fmt.Sprintf("channel IN (%s)", strings.Join(placeholders, ",")))
+	}
+	if len(filters.UserIDs) > 0 {
+		placeholders := make([]string, 0, len(filters.UserIDs))
+		for _, id := range filters.UserIDs {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		clauses = append(clauses, fmt.Sprintf("user_id IN (%s)", strings.Join(placeholders, ",")))
+	}
+	if len(filters.ChannelIDs) > 0 {
+		placeholders := make([]string, 0, len(filters.ChannelIDs))
+		for _, id := range filters.ChannelIDs {
+			placeholders = append(placeholders, "?")
+			args = append(args, id)
+		}
+		clauses = append(clauses, fmt.Sprintf("channel_id IN (%s)", strings.Join(placeholders, ",")))
+	}
+	if !filters.ShowsDeleted() {
+		clauses = append(clauses, "deleted_at = 0")
+	}
+	if !filters.ShowsWhispers() {
+		clauses = append(clauses, "message_type != ?")
+		args = append(args, core.MessageTypeWhisper)
+	}
+	if filters.CollapseMirrors {
+		clauses = append(clauses, "mirror_of = ''")
+	}
+	if len(filters.Types) > 0 {
+		var placeholders []string
+		for _, t := range filters.Types {
+			if t == core.MessageTypeDeleted {
+				continue
+			}
+			placeholders = append(placeholders, "?")
+			args = append(args, t)
+		}
+		var match []string
+		if len(placeholders) > 0 {
+			match = append(match, fmt.Sprintf("COALESCE(NULLIF(message_type, ''), '%s') IN (%s)", core.MessageTypeChat, strings.Join(placeholders, ",")))
+		}
+		if filters.HasType(core.MessageTypeDeleted) {
+			match = append(match, "deleted_at != 0")
+		}
+		clauses = append(clauses, "("+strings.Join(match, " OR ")+")")
+	}
+	if len(clauses) == 0 {
+		return where, args
+	}
+	clause := strings.Join(clauses, " AND ")
+	if where == "" {
+		return " WHERE " + clause, args
+	}
+	return where + " AND " + clause, args
+}
+
+// messageTimeColumn is the messages expression since, until and order apply
+// to under filters.
+func messageTimeColumn(filters httpapi.Filters) string {
+	if filters.ByReceived() {
+		return receivedAtExpr
+	}
+	return "ts"
+}
+
+// buildFilterWhere renders filters against any table with platform,
+// username and username_norm columns, bounding tsColumn by since/until.
+func buildFilterWhere(filters httpapi.Filters, tsColumn string) (string, []any) {
 	var (
 		conditions []string
 		args       []any
@@ -572,37 +1256,24 @@ func buildMessageQuery(filters httpapi.Filters, count bool) (string, []any) {
 	if len(filters.Usernames) > 0 {
 		ors := make([]string, 0, len(filters.Usernames))
 		for _, u := range filters.Usernames {
-			ors = append(ors, "LOWER(username) LIKE '%' || ? || '%'")
-			args = append(args, u)
+			ors = append(ors, "username_norm LIKE '%' || ? || '%' OR LOWER(username) LIKE '%' || ? || '%'")
+			args = append(args, u, u)
 		}
 		conditions = append(conditions, fmt.Sprintf("(%s)", strings.Join(ors, " OR ")))
 	}
 
 	if filters.Since != nil {
-		conditions = append(conditions, "ts >= ?")
+		conditions = append(conditions, tsColumn+" >= ?")
 		args = append(args, filters.Since.UTC().UnixMilli())
 	}
 
-	if len(conditions) > 0 {
-		builder.WriteString(" WHERE ")
-		builder.WriteString(strings.Join(conditions, " AND "))
+	if filters.Until != nil {
+		conditions = append(conditions, tsColumn+" < ?")
+		args = append(args, filters.Until.UTC().UnixMilli())
 	}
 
-	if !count {
-		order := "DESC"
-		if filters.Order == httpapi.OrderAsc {
-			order = "ASC"
-		}
-		builder.WriteString(" ORDER BY ts ")
-		builder.WriteString(order)
-		limit := filters.Limit
-		if limit <= 0 {
-			limit = defaultListLimit
-		}
-		builder.WriteString(" LIMIT ?")
-		args = append(args, limit)
+	if len(conditions) == 0 {
+		return "", args
 	}
-
-	builder.WriteString(";")
-	return builder.String(), args
+	return " WHERE " + strings.Join(conditions, " AND "), args
 }
@@ -0,0 +1,1587 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+func openTestSQLite(t *testing.T) *SQLiteSink {
+	t.Helper()
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestSQLiteStats(t *testing.T) {
+	db := openTestSQLite(t)
+	now := time.Now().UTC()
+
+	msgs := []core.ChatMessage{
+		{ID: "a", Platform: "Twitch", Username: "alice", Text: "hi", Ts: now},
+		{ID: "b", Platform: "Twitch", Username: "bob", Text: "yo", Ts: now},
+		{ID: "c", Platform: "YouTube", Username: "carol", Text: "hey", Ts: now},
+		{ID: "d", Platform: "YouTube", Username: "dave", Text: "old", Ts: now.Add(-time.Hour)},
+	}
+	for _, msg := range msgs {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write %s: %v", msg.ID, err)
+		}
+	}
+
+	stats, err := db.Stats(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.TotalMessages != 4 {
+		t.Fatalf("expected 4 total messages, got %d", stats.TotalMessages)
+	}
+	if stats.RecentByPlatform["Twitch"] != 2 || stats.RecentByPlatform["YouTube"] != 1 {
+		t.Fatalf("unexpected recent counts: %v", stats.RecentByPlatform)
+	}
+	if stats.DBSizeBytes <= 0 {
+		t.Fatalf("expected positive db size, got %d", stats.DBSizeBytes)
+	}
+}
+
+func TestSQLiteMessagesVersionRespectsUntil(t *testing.T) {
+	db := openTestSQLite(t)
+	base := time.Now().UTC().Add(-2 * time.Hour).Truncate(time.Millisecond)
+
+	for i, id := range []string{"a", "b", "c"} {
+		msg := core.ChatMessage{ID: id, Platform: "Twitch", Username: "alice", Text: id, Ts: base.Add(time.Duration(i) * time.Minute)}
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write %s: %v", id, err)
+		}
+	}
+
+	until := base.Add(2 * time.Minute)
+	filters := httpapi.Filters{Until: &until, Limit: 10}
+	rows, err := db.ListMessages(context.Background(), filters)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows before until, got %d", len(rows))
+	}
+
+	v, err := db.MessagesVersion(context.Background(), filters)
+	if err != nil {
+		t.Fatalf("version: %v", err)
+	}
+	if v.Count != 2 || v.LatestID == 0 || !v.LatestTs.Equal(base.Add(time.Minute)) {
+		t.Fatalf("unexpected version: %+v", v)
+	}
+}
+
+func TestSQLiteOverlayConfigs(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+
+	show := false
+	cfg := httpapi.OverlayConfig{Key: "main", FontSize: 24, TextColor: "#fff", ShowBadges: &show, FadeSecs: 30}
+	if err := db.PutOverlay(ctx, cfg); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	cfg.FontSize = 28
+	if err := db.PutOverlay(ctx, cfg); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	got, found, err := db.GetOverlay(ctx, "main")
+	if err != nil || !found {
+		t.Fatalf("get: found=%v err=%v", found, err)
+	}
+	if got.FontSize != 28 || got.ShowBadges == nil || *got.ShowBadges {
+		t.Fatalf("unexpected overlay: %+v", got)
+	}
+
+	list, err := db.ListOverlays(ctx)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("list: %v %v", list, err)
+	}
+
+	if deleted, err := db.DeleteOverlay(ctx, "main"); err != nil || !deleted {
+		t.Fatalf("delete: deleted=%v err=%v", deleted, err)
+	}
+	if _, found, _ := db.GetOverlay(ctx, "main"); found {
+		t.Fatalf("expected overlay removed")
+	}
+}
+
+func TestSQLiteUsernameNormalization(t *testing.T) {
+	db := openTestSQLite(t)
+	now := time.Now().UTC()
+
+	msgs := []core.ChatMessage{
+		{ID: "a", Platform: "YouTube", Username: "@ＥＬＯＲＡ", Text: "hi", Ts: now},
+		{ID: "b", Platform: "Twitch", Username: "Gnasty", Text: "yo", Ts: now},
+	}
+	for _, msg := range msgs {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write %s: %v", msg.ID, err)
+		}
+	}
+
+	rows, err := db.ListMessages(context.Background(), httpapi.Filters{Usernames: []string{"elora"}, Limit: 10})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(rows) != 1 || rows[0].ID != "a" {
+		t.Fatalf("expected fullwidth handle to match, got %+v", rows)
+	}
+
+	if _, err := db.RawDB().Exec(`UPDATE messages SET username_norm = ''`); err != nil {
+		t.Fatalf("reset username_norm: %v", err)
+	}
+	n, err := db.BackfillUsernameNorm(context.Background())
+	if err != nil {
+		t.Fatalf("backfill: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 backfilled rows, got %d", n)
+	}
+	var norm string
+	if err := db.RawDB().QueryRow(`SELECT username_norm FROM messages WHERE platform_msg_id = 'a'`).Scan(&norm); err != nil {
+		t.Fatalf("read username_norm: %v", err)
+	}
+	if norm != "elora" {
+		t.Fatalf("expected backfilled key %q, got %q", "elora", norm)
+	}
+}
+
+func TestSQLiteUsersTable(t *testing.T) {
+	db := openTestSQLite(t)
+	base := time.Now().UTC().Truncate(time.Millisecond)
+
+	msgs := []core.ChatMessage{
+		{ID: "a", Platform: "YouTube", Username: "Old Name", Text: "one", Ts: base, AuthorChannelID: "UC1", AvatarURL: "https://example.test/a.png"},
+		{ID: "b", Platform: "YouTube", Username: "New Name", Text: "two", Ts: base.Add(time.Minute), AuthorChannelID: "UC1"},
+		{ID: "c", Platform: "Twitch", Username: "Gnasty", Text: "three", Ts: base},
+	}
+	for _, msg := range msgs {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write %s: %v", msg.ID, err)
+		}
+	}
+
+	var (
+		username, avatar string
+		first, last      int64
+	)
+	err := db.RawDB().QueryRow(`SELECT username, avatar_url, first_seen, last_seen FROM users WHERE platform = 'YouTube' AND user_key = 'UC1'`).
+		Scan(&username, &avatar, &first, &last)
+	if err != nil {
+		t.Fatalf("read youtube user: %v", err)
+	}
+	if username != "New Name" || avatar != "https://example.test/a.png" {
+		t.Fatalf("unexpected youtube user: %q %q", username, avatar)
+	}
+	if first != base.UnixMilli() || last != base.Add(time.Minute).UnixMilli() {
+		t.Fatalf("unexpected seen range %d-%d", first, last)
+	}
+	if err := db.RawDB().QueryRow(`SELECT username FROM users WHERE platform = 'Twitch' AND user_key = 'gnasty'`).Scan(&username); err != nil {
+		t.Fatalf("read twitch user: %v", err)
+	}
+
+	rows, err := db.ListMessages(context.Background(), httpapi.Filters{Platforms: []string{"YouTube"}, Order: httpapi.OrderAsc, Limit: 10})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(rows) != 2 || rows[0].AuthorChannelID != "UC1" || rows[0].AvatarURL != "https://example.test/a.png" {
+		t.Fatalf("expected author metadata on listed messages, got %+v", rows)
+	}
+}
+
+func TestSQLiteStreamState(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	start := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+
+	record := func(state string, detail map[string]any, ts time.Time) {
+		t.Helper()
+		if err := db.RecordStreamState(ctx, core.StreamState{Platform: "YouTube", Channel: "@chan", State: state, Detail: detail, Ts: ts}); err != nil {
+			t.Fatalf("record %s: %v", state, err)
+		}
+	}
+	live := map[string]any{"video_id": "abc", "watch_url": "https://www.youtube.com/watch?v=abc"}
+	record(core.StreamLive, live, start)
+	record(core.StreamLive, live, start.Add(time.Minute)) // restart, deduped
+	record(core.StreamEnded, nil, start.Add(30*time.Minute))
+	record(core.StreamEnded, nil, start.Add(31*time.Minute)) // deduped
+	record(core.StreamLive, map[string]any{"video_id": "def"}, start.Add(40*time.Minute))
+	if err := db.RecordStreamState(ctx, core.StreamState{Platform: "Twitch", Channel: "#elora", State: core.StreamRoomState, Detail: map[string]any{"slow_secs": 0}, Ts: start}); err != nil {
+		t.Fatalf("record roomstate: %v", err)
+	}
+
+	msgs := []core.ChatMessage{
+		{ID: "a", Platform: "YouTube", Username: "alice", Text: "first", Ts: start.Add(5 * time.Minute)},
+		{ID: "b", Platform: "YouTube", Username: "bob", Text: "between", Ts: start.Add(35 * time.Minute)},
+		{ID: "c", Platform: "YouTube", Username: "carol", Text: "second", Ts: start.Add(45 * time.Minute)},
+		{ID: "d", Platform: "Twitch", Username: "dave", Text: "other", Ts: start.Add(5 * time.Minute)},
+	}
+	for _, m := range msgs {
+		if err := db.Write(m, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	var count int
+	if err := db.db.QueryRow(`SELECT COUNT(*) FROM stream_state`).Scan(&count); err != nil || count != 4 {
+		t.Fatalf("expected 4 stream_state rows, got %d (%v)", count, err)
+	}
+
+	sessions, err := db.ListStreams(ctx, httpapi.Filters{Order: httpapi.OrderAsc})
+	if err != nil {
+		t.Fatalf("list streams: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %+v", sessions)
+	}
+	first, second := sessions[0], sessions[1]
+	if first.VideoID != "abc" || first.Live || first.EndedAt == nil || first.Messages != 1 {
+		t.Fatalf("unexpected first session %+v", first)
+	}
+	if second.VideoID != "def" || !second.Live || second.EndedAt != nil || second.Messages != 1 {
+		t.Fatalf("unexpected second session %+v", second)
+	}
+
+	since := start.Add(35 * time.Minute)
+	sessions, err = db.ListStreams(ctx, httpapi.Filters{Platforms: []string{"YouTube"}, Since: &since})
+	if err != nil || len(sessions) != 1 || sessions[0].VideoID != "def" {
+		t.Fatalf("expected only the later session, got %+v (%v)", sessions, err)
+	}
+}
+
+func TestSQLiteSessions(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	db, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	ctx := context.Background()
+	start := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+
+	// Written before the live event is seen but after the broadcast started.
+	if err := db.Write(core.ChatMessage{ID: "early", Platform: "YouTube", Username: "alice", Text: "first", Ts: start.Add(time.Second)}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := db.RecordStreamState(ctx, core.StreamState{Platform: "YouTube", Channel: "@chan", State: core.StreamLive,
+		Detail: map[string]any{"video_id": "abc"}, Ts: start}); err != nil {
+		t.Fatalf("record live: %v", err)
+	}
+	if err := db.Write(core.ChatMessage{ID: "during", Platform: "YouTube", Username: "bob", Text: "hi", Ts: start.Add(time.Minute)}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := db.Write(core.ChatMessage{ID: "tw", Platform: "Twitch", Username: "carol", Text: "yo", Ts: start.Add(time.Minute)}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_ = db.Close()
+
+	// The open session survives a restart.
+	db, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("reopen sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Write(core.ChatMessage{ID: "resumed", Platform: "YouTube", Username: "bob", Text: "back", Ts: start.Add(2 * time.Minute)}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := db.RecordStreamState(ctx, core.StreamState{Platform: "YouTube", Channel: "@chan", State: core.StreamEnded, Ts: start.Add(time.Hour)}); err != nil {
+		t.Fatalf("record ended: %v", err)
+	}
+	if err := db.Write(core.ChatMessage{ID: "after", Platform: "YouTube", Username: "bob", Text: "bye", Ts: start.Add(61 * time.Minute)}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	rows, err := db.ListMessages(ctx, httpapi.Filters{SessionIDs: []string{"youtube:abc"}, Order: httpapi.OrderAsc})
+	if err != nil {
+		t.Fatalf("list messages: %v", err)
+	}
+	if len(rows) != 3 || rows[0].ID != "early" || rows[2].ID != "resumed" || rows[0].SessionID != "youtube:abc" {
+		t.Fatalf("unexpected session messages %+v", rows)
+	}
+
+	session, ok, err := db.GetSession(ctx, "youtube:abc")
+	if err != nil || !ok {
+		t.Fatalf("get session: ok=%t err=%v", ok, err)
+	}
+	if session.VideoID != "abc" || session.Live || session.EndedAt == nil || session.Messages != 3 {
+		t.Fatalf("unexpected session %+v", session)
+	}
+	if _, ok, err := db.GetSession(ctx, "youtube:missing"); err != nil || ok {
+		t.Fatalf("expected missing session, ok=%t err=%v", ok, err)
+	}
+}
+
+func TestSQLiteListUserMessages(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	base := time.Now().UTC().Truncate(time.Millisecond)
+
+	msgs := []core.ChatMessage{
+		{ID: "y1", Platform: "YouTube", Username: "Sam", Text: "one", Ts: base, AuthorChannelID: "UC1"},
+		{ID: "y2", Platform: "YouTube", Username: "Sam", Text: "imposter", Ts: base, AuthorChannelID: "UC2"},
+		{ID: "y3", Platform: "YouTube", Username: "Samuel", Text: "two", Ts: base, AuthorChannelID: "UC1"},
+		{ID: "y4", Platform: "YouTube", Username: "Sam", Text: "three", Ts: base.Add(time.Second), AuthorChannelID: "UC1"},
+		{ID: "t1", Platform: "Twitch", Username: "Elora", Text: "hi", Ts: base},
+	}
+	for _, msg := range msgs {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write %s: %v", msg.ID, err)
+		}
+	}
+
+	var got []string
+	cursor := ""
+	for page := 0; ; page++ {
+		rows, next, err := db.ListUserMessages(ctx, "YouTube", "UC1", httpapi.Filters{Limit: 2, Order: httpapi.OrderAsc}, cursor)
+		if err != nil {
+			t.Fatalf("page %d: %v", page, err)
+		}
+		for _, m := range rows {
+			got = append(got, m.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if strings.Join(got, ",") != "y1,y3,y4" {
+		t.Fatalf("unexpected pages %v", got)
+	}
+
+	rows, _, err := db.ListUserMessages(ctx, "Twitch", "elora", httpapi.Filters{}, "")
+	if err != nil || len(rows) != 1 || rows[0].ID != "t1" {
+		t.Fatalf("expected twitch user message, got %+v (%v)", rows, err)
+	}
+	if _, _, err := db.ListUserMessages(ctx, "Twitch", "elora", httpapi.Filters{}, "bogus"); !errors.Is(err, httpapi.ErrInvalidCursor) {
+		t.Fatalf("expected invalid cursor error, got %v", err)
+	}
+}
+
+func TestSQLiteULIDs(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "ulid.db")
+	db, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	base := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	given := core.NewID(base.Add(time.Second))
+	msgs := []core.ChatMessage{
+		{ID: "a", Platform: "Twitch", Username: "alice", Text: "hi", Ts: base},
+		{ID: "a2", Platform: "Twitch", Username: "alice", Text: "again", Ts: base},
+		{ID: "b", Platform: "Twitch", Username: "bob", Text: "yo", Ts: base.Add(time.Second), ULID: given},
+		{Platform: "YouTube", Username: "carol", Text: "no id", Ts: base.Add(2 * time.Second)},
+	}
+	if err := db.WriteBatch(msgs, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	// An upsert of the same platform message keeps the stored ULID.
+	if err := db.Write(core.ChatMessage{ID: "a", Platform: "Twitch", Username: "alice", Text: "edited", Ts: base}, nil); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+
+	stored, err := db.ListMessages(ctx, httpapi.Filters{Order: httpapi.OrderAsc})
+	if err != nil || len(stored) != 4 {
+		t.Fatalf("list: %d rows, %v", len(stored), err)
+	}
+	first := stored[0].ULID
+	if stored[2].ULID != given {
+		t.Fatalf("given ULID %q stored as %q", given, stored[2].ULID)
+	}
+	for i, m := range stored {
+		ts, ok := core.ULIDTime(m.ULID)
+		if !ok || !ts.Equal(m.Ts) {
+			t.Fatalf("row %d ULID %q decodes to %s, want %s", i, m.ULID, ts, m.Ts)
+		}
+		if i > 0 && m.ULID <= stored[i-1].ULID {
+			t.Fatalf("ULIDs out of order: %q after %q", m.ULID, stored[i-1].ULID)
+		}
+	}
+
+	// Rows written without a ULID get one when the database is opened.
+	if _, err := db.RawDB().Exec(`UPDATE messages SET ulid = '' WHERE platform = 'YouTube';`); err != nil {
+		t.Fatalf("clear ulid: %v", err)
+	}
+	_ = db.Close()
+	db, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("reopen sqlite: %v", err)
+	}
+	defer db.Close()
+	stored, err = db.ListMessages(ctx, httpapi.Filters{Order: httpapi.OrderAsc})
+	if err != nil || len(stored) != 4 || stored[0].ULID != first || stored[3].ULID == "" {
+		t.Fatalf("after reopen: %+v (%v)", stored, err)
+	}
+
+	// A cursor from one copy of the archive continues in another, whose
+	// row IDs run the other way.
+	copyPath := filepath.Join(t.TempDir(), "copy.db")
+	other, err := OpenSQLite(copyPath)
+	if err != nil {
+		t.Fatalf("open copy: %v", err)
+	}
+	defer other.Close()
+	if err := other.WriteBatch([]core.ChatMessage{stored[1], stored[0]}, nil); err != nil {
+		t.Fatalf("copy rows: %v", err)
+	}
+	page, cursor, err := db.ListUserMessages(ctx, "Twitch", "alice", httpapi.Filters{Limit: 1, Order: httpapi.OrderAsc}, "")
+	if err != nil || len(page) != 1 || !strings.HasSuffix(cursor, "."+first) {
+		t.Fatalf("first page %+v cursor %q (%v)", page, cursor, err)
+	}
+	rest, _, err := other.ListUserMessages(ctx, "Twitch", "alice", httpapi.Filters{Order: httpapi.OrderAsc}, cursor)
+	if err != nil || len(rest) != 1 || rest[0].ID != "a2" {
+		t.Fatalf("copy after cursor = %+v (%v), want a2", rest, err)
+	}
+
+	// Legacy row-id cursors continue in ULID order too, even where the
+	// row IDs disagree with it.
+	var rowID int64
+	if err := other.RawDB().QueryRow(`SELECT id FROM messages WHERE platform_msg_id = 'a';`).Scan(&rowID); err != nil {
+		t.Fatalf("row id: %v", err)
+	}
+	legacy := fmt.Sprintf("%d.%d", base.UnixMilli(), rowID)
+	rest, _, err = other.ListUserMessages(ctx, "Twitch", "alice", httpapi.Filters{Order: httpapi.OrderAsc}, legacy)
+	if err != nil || len(rest) != 1 || rest[0].ID != "a2" {
+		t.Fatalf("copy after legacy cursor = %+v (%v), want a2", rest, err)
+	}
+}
+
+func TestSQLiteEraseUser(t *testing.T) {
+	ctx := context.Background()
+	base := time.Now().UTC().Truncate(time.Millisecond)
+	msgs := []core.ChatMessage{
+		{ID: "1", Platform: "Twitch", Username: "Elora", Text: "same", Ts: base},
+		// Same timestamp: redacted rows must stay unique.
+		{ID: "2", Platform: "Twitch", Username: "Elora", Text: "different", Ts: base},
+		{ID: "3", Platform: "Twitch", Username: "Other", Text: "keep", Ts: base},
+		{ID: "4", Platform: "YouTube", Username: "Elora", Text: "different person", Ts: base, AuthorChannelID: "UC9"},
+	}
+	for _, redact := range []bool{false, true} {
+		db := openTestSQLite(t)
+		for _, msg := range msgs {
+			if err := db.Write(msg, nil); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+		}
+		messages, users, err := db.EraseUser(ctx, "Twitch", "ELORA", redact)
+		if err != nil {
+			t.Fatalf("erase (redact=%t): %v", redact, err)
+		}
+		if messages != 2 || users != 1 {
+			t.Fatalf("redact=%t: expected 2 messages and 1 user, got %d/%d", redact, messages, users)
+		}
+		var remaining, named int
+		if err := db.RawDB().QueryRow(`SELECT COUNT(*), SUM(username = 'Elora') FROM messages`).Scan(&remaining, &named); err != nil {
+			t.Fatalf("count: %v", err)
+		}
+		wantRemaining := 2
+		if redact {
+			wantRemaining = 4
+		}
+		if remaining != wantRemaining || named != 1 {
+			t.Fatalf("redact=%t: expected %d rows with only the YouTube Elora left, got %d/%d", redact, wantRemaining, remaining, named)
+		}
+	}
+}
+
+type recordingBroadcaster struct {
+	msgs []core.ChatMessage
+}
+
+func (r *recordingBroadcaster) Broadcast(msg core.ChatMessage) {
+	r.msgs = append(r.msgs, msg)
+}
+
+func TestSQLiteWriteBatch(t *testing.T) {
+	db := openTestSQLite(t)
+	rec := &recordingBroadcaster{}
+	w := WithAPI(db, rec)
+	now := time.Now().UTC().Truncate(time.Millisecond)
+
+	batch := []core.ChatMessage{
+		{ID: "b1", Platform: "Twitch", Username: "Alice", Text: "one", Ts: now},
+		{ID: "b2", Platform: "Twitch", Username: "bob", Text: "two", Ts: now.Add(time.Millisecond)},
+		{ID: "b1", Platform: "Twitch", Username: "Alice", Text: "dup", Ts: now},
+	}
+	if err := w.WriteBatch(batch, nil); err != nil {
+		t.Fatalf("write batch: %v", err)
+	}
+	if len(rec.msgs) != len(batch) {
+		t.Fatalf("expected %d broadcasts, got %d", len(batch), len(rec.msgs))
+	}
+
+	var messages, users int
+	if err := db.RawDB().QueryRow(`SELECT COUNT(*) FROM messages`).Scan(&messages); err != nil {
+		t.Fatalf("count messages: %v", err)
+	}
+	if err := db.RawDB().QueryRow(`SELECT COUNT(*) FROM users`).Scan(&users); err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	if messages != 2 || users != 2 {
+		t.Fatalf("expected 2 messages and 2 users, got %d/%d", messages, users)
+	}
+}
+
+func TestSQLiteWriteHook(t *testing.T) {
+	db := openTestSQLite(t)
+	var written []string
+	db.SetWriteHook(func(msg core.ChatMessage, at time.Time) {
+		if at.IsZero() {
+			t.Fatalf("hook called without a write time")
+		}
+		written = append(written, msg.ID)
+	})
+	now := time.Now().UTC()
+	if err := db.Write(core.ChatMessage{ID: "w1", Platform: "Twitch", Username: "alice", Text: "one", Ts: now}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	batch := []core.ChatMessage{
+		{ID: "w2", Platform: "Twitch", Username: "bob", Text: "two", Ts: now},
+		{ID: "w3", Platform: "YouTube", Username: "carol", Text: "three", Ts: now},
+	}
+	if err := db.WriteBatch(batch, nil); err != nil {
+		t.Fatalf("write batch: %v", err)
+	}
+	if got := strings.Join(written, ","); got != "w1,w2,w3" {
+		t.Fatalf("hook saw %s", got)
+	}
+}
+
+func TestSQLiteMaintain(t *testing.T) {
+	db := openTestSQLite(t)
+	now := time.Now().UTC()
+	for i := 0; i < 200; i++ {
+		msg := core.ChatMessage{ID: fmt.Sprintf("m%d", i), Platform: "Twitch", Username: "alice", Text: strings.Repeat("x", 200), Ts: now.Add(time.Duration(i) * time.Millisecond)}
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if db.WALSize() == 0 {
+		t.Fatalf("expected a non-empty WAL after writes")
+	}
+
+	res, err := db.Maintain(context.Background())
+	if err != nil {
+		t.Fatalf("maintain: %v", err)
+	}
+	if res.WALBytesBefore == 0 {
+		t.Fatalf("expected WAL size before checkpoint, got %+v", res)
+	}
+	if !res.Busy && res.WALBytesAfter != 0 {
+		t.Fatalf("expected truncated WAL, got %d bytes", res.WALBytesAfter)
+	}
+}
+
+func TestSQLiteLeases(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+
+	ok, err := db.AcquireLease(ctx, "twitch:elora", "a", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("expected a to acquire lease, got %v %v", ok, err)
+	}
+	if ok, err := db.AcquireLease(ctx, "twitch:elora", "b", time.Minute); err != nil || ok {
+		t.Fatalf("expected b to be refused while a holds the lease, got %v %v", ok, err)
+	}
+	if ok, err := db.AcquireLease(ctx, "twitch:elora", "a", time.Minute); err != nil || !ok {
+		t.Fatalf("expected a to renew, got %v %v", ok, err)
+	}
+	if err := db.ReleaseLease(ctx, "twitch:elora", "a"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if ok, err := db.AcquireLease(ctx, "twitch:elora", "b", -time.Second); err != nil || !ok {
+		t.Fatalf("expected b to acquire released lease, got %v %v", ok, err)
+	}
+	// b's lease is already expired, so a can take it over.
+	if ok, err := db.AcquireLease(ctx, "twitch:elora", "a", time.Minute); err != nil || !ok {
+		t.Fatalf("expected a to take over expired lease, got %v %v", ok, err)
+	}
+}
+
+func TestSQLiteYouTubeCheckpoints(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+
+	if _, found, err := db.YouTubeCheckpoint(ctx, "vid1"); err != nil || found {
+		t.Fatalf("expected no checkpoint yet, got %v %v", found, err)
+	}
+	saved := time.Now().UTC().Truncate(time.Millisecond)
+	for _, cont := range []string{"cont-1", "cont-2"} {
+		if err := db.SaveYouTubeCheckpoint(ctx, core.YouTubeCheckpoint{VideoID: "vid1", Continuation: cont, APIKey: "key", ClientVersion: "2.0", UpdatedAt: saved}); err != nil {
+			t.Fatalf("save %s: %v", cont, err)
+		}
+	}
+	cp, found, err := db.YouTubeCheckpoint(ctx, "vid1")
+	if err != nil || !found {
+		t.Fatalf("load: %v %v", found, err)
+	}
+	want := core.YouTubeCheckpoint{VideoID: "vid1", Continuation: "cont-2", APIKey: "key", ClientVersion: "2.0", UpdatedAt: saved}
+	if cp != want {
+		t.Fatalf("checkpoint = %+v, want %+v", cp, want)
+	}
+	if err := db.SaveYouTubeCheckpoint(ctx, core.YouTubeCheckpoint{VideoID: "vid1"}); err == nil {
+		t.Fatal("expected an error for a checkpoint without a continuation")
+	}
+}
+
+func TestSQLiteBackfillLookups(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+
+	if _, found, err := db.LastMessageTime(ctx, "Twitch", "elora"); err != nil || found {
+		t.Fatalf("expected no message yet, got %v %v", found, err)
+	}
+	for i, id := range []string{"tw-1", "tw-2"} {
+		msg := core.ChatMessage{ID: id, PlatformMsgID: id, Platform: "Twitch", Channel: "elora", Username: "ann", Text: "hi", Ts: ts.Add(time.Duration(i) * time.Second)}
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := db.Write(core.ChatMessage{ID: "tw-3", PlatformMsgID: "tw-3", Platform: "Twitch", Channel: "other", Username: "ann", Text: "hi", Ts: ts.Add(time.Hour)}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	last, found, err := db.LastMessageTime(ctx, "Twitch", "Elora")
+	if err != nil || !found || !last.Equal(ts.Add(time.Second)) {
+		t.Fatalf("last = %s %v %v, want %s", last, found, err, ts.Add(time.Second))
+	}
+	ids := []string{"tw-2", "tw-9"}
+	for i := 0; i < knownIDsBatch; i++ {
+		ids = append(ids, fmt.Sprintf("missing-%d", i))
+	}
+	ids = append(ids, "tw-1")
+	known, err := db.KnownMessageIDs(ctx, "Twitch", ids)
+	if err != nil {
+		t.Fatalf("known ids: %v", err)
+	}
+	if len(known) != 2 || !known["tw-1"] || !known["tw-2"] {
+		t.Fatalf("known = %v", known)
+	}
+	if known, err := db.KnownMessageIDs(ctx, "YouTube", []string{"tw-1"}); err != nil || len(known) != 0 {
+		t.Fatalf("known on another platform = %v %v", known, err)
+	}
+}
+
+func TestSQLiteEditMessage(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+
+	if err := db.Write(core.ChatMessage{ID: "yt-1", Platform: "YouTube", Username: "carol", Text: "helo", Ts: ts}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	for i, text := range []string{"hello", "hello!", "hello!"} {
+		found, err := db.EditMessage(ctx, core.MessageUpdate{Platform: "YouTube", ID: "yt-1", Text: text, EditedAt: ts.Add(time.Duration(i+1) * time.Second)})
+		if err != nil || !found {
+			t.Fatalf("edit %d: found=%v err=%v", i, found, err)
+		}
+	}
+	if found, err := db.EditMessage(ctx, core.MessageUpdate{Platform: "YouTube", ID: "missing", Text: "x"}); err != nil || found {
+		t.Fatalf("expected missing message to be reported, got found=%v err=%v", found, err)
+	}
+
+	latest, err := db.ListMessages(ctx, httpapi.Filters{Limit: 10})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(latest) != 1 || latest[0].Text != "hello!" || latest[0].EditedAt == nil || len(latest[0].Edits) != 0 {
+		t.Fatalf("unexpected latest view: %+v", latest)
+	}
+
+	history, err := db.ListMessages(ctx, httpapi.Filters{Limit: 10, IncludeEdits: true, Original: true})
+	if err != nil {
+		t.Fatalf("list with edits: %v", err)
+	}
+	if got := history[0]; got.Text != "helo" || len(got.Edits) != 3 || got.Edits[0].Text != "helo" || got.Edits[2].Text != "hello!" {
+		t.Fatalf("unexpected history view: text=%q edits=%+v", got.Text, got.Edits)
+	}
+}
+
+func TestSQLiteDeleteMessages(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+
+	msgs := []core.ChatMessage{
+		{ID: "tw-1", Platform: "Twitch", Channel: "elora", Username: "Spammer", Text: "buy followers", Ts: ts},
+		{ID: "tw-2", Platform: "Twitch", Channel: "elora", Username: "Spammer", Text: "cheap followers", Ts: ts.Add(time.Second)},
+		{ID: "tw-3", Platform: "Twitch", Channel: "elora", Username: "alice", Text: "hi", Ts: ts.Add(2 * time.Second)},
+		{ID: "yt-1", Platform: "YouTube", Username: "bob", AuthorChannelID: "UC1", Text: "oops", Ts: ts.Add(3 * time.Second)},
+		{ID: "tw-9", Platform: "Twitch", Channel: "rival", Username: "Spammer", Text: "not banned here", Ts: ts.Add(4 * time.Second)},
+	}
+	for _, msg := range msgs {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	deletedAt := ts.Add(time.Minute)
+	for _, tc := range []struct {
+		del  core.MessageDeletion
+		want int64
+	}{
+		{core.MessageDeletion{Platform: "Twitch", Channel: "elora", Author: "spammer", DeletedAt: deletedAt, DeletedBy: "twitch:timeout"}, 2},
+		{core.MessageDeletion{Platform: "Twitch", Channel: "elora", ID: "tw-1", DeletedAt: deletedAt, DeletedBy: "twitch:clearmsg"}, 0},
+		{core.MessageDeletion{Platform: "YouTube", ID: "yt-1", DeletedAt: deletedAt, DeletedBy: "youtube:delete"}, 1},
+	} {
+		n, err := db.DeleteMessages(ctx, tc.del)
+		if err != nil || n != tc.want {
+			t.Fatalf("delete %+v: n=%d err=%v, want %d", tc.del, n, err, tc.want)
+		}
+	}
+
+	visible, err := db.ListMessages(ctx, httpapi.Filters{Limit: 10})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(visible) != 2 || visible[0].ID != "tw-9" || visible[1].ID != "tw-3" {
+		t.Fatalf("expected only tw-3 and the other channel's tw-9 to remain visible, got %+v", visible)
+	}
+
+	all, err := db.ListMessages(ctx, httpapi.Filters{Limit: 10, Order: httpapi.OrderAsc, IncludeDeleted: true})
+	if err != nil {
+		t.Fatalf("list with deleted: %v", err)
+	}
+	if len(all) != 5 {
+		t.Fatalf("expected 5 messages with include_deleted, got %d", len(all))
+	}
+	if got := all[0]; got.Text != "buy followers" || got.DeletedAt == nil || !got.DeletedAt.Equal(deletedAt) || got.DeletedBy != "twitch:timeout" {
+		t.Fatalf("expected the first deletion to be kept with the original text, got %+v", got)
+	}
+	if all[2].DeletedAt != nil {
+		t.Fatalf("expected tw-3 to be untouched, got %+v", all[2])
+	}
+
+	v, err := db.MessagesVersion(ctx, httpapi.Filters{})
+	if err != nil {
+		t.Fatalf("version: %v", err)
+	}
+	if v.Count != 2 || !v.LatestDelete.Equal(deletedAt) {
+		t.Fatalf("unexpected version %+v", v)
+	}
+
+	// A chat clear removes what the channel's current session sent before
+	// it, leaving earlier streams and other channels alone.
+	if err := db.RecordStreamState(ctx, core.StreamState{Platform: "Twitch", Channel: "elora", State: core.StreamLive, Ts: ts.Add(5 * time.Second)}); err != nil {
+		t.Fatalf("record live: %v", err)
+	}
+	if err := db.Write(core.ChatMessage{ID: "tw-4", Platform: "Twitch", Channel: "elora", Username: "alice", Text: "live now", Ts: ts.Add(6 * time.Second)}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if n, err := db.DeleteMessages(ctx, core.MessageDeletion{Platform: "Twitch", Channel: "elora", DeletedAt: deletedAt, DeletedBy: "twitch:clearchat"}); err != nil || n != 1 {
+		t.Fatalf("clear chat: n=%d err=%v", n, err)
+	}
+	visible, err = db.ListMessages(ctx, httpapi.Filters{Limit: 10})
+	if err != nil || len(visible) != 2 || visible[0].ID != "tw-9" || visible[1].ID != "tw-3" {
+		t.Fatalf("after clear chat: %+v (%v)", visible, err)
+	}
+}
+
+func TestSQLiteViewerSamples(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+
+	for i, viewers := range []int{100, 180, 150} {
+		sample := core.ViewerSample{Platform: "Twitch", Channel: "elora", Viewers: viewers, Ts: base.Add(time.Duration(i) * time.Minute)}
+		if err := db.RecordViewerSample(ctx, sample); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	if err := db.RecordViewerSample(ctx, core.ViewerSample{Platform: "YouTube", Channel: "@elora", Viewers: 40, Ts: base}); err != nil {
+		t.Fatalf("record youtube: %v", err)
+	}
+	// Three messages land between the first and second Twitch samples.
+	for i := 0; i < 3; i++ {
+		msg := core.ChatMessage{ID: fmt.Sprintf("tw-%d", i), Platform: "Twitch", Username: "alice", Text: "hype", Ts: base.Add(time.Duration(10+i) * time.Second)}
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	samples, err := db.ListViewerSamples(ctx, httpapi.Filters{Platforms: []string{"Twitch"}, Order: httpapi.OrderAsc})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("expected 3 twitch samples, got %d", len(samples))
+	}
+	if samples[0].WindowStart != nil || samples[0].Messages != 0 {
+		t.Fatalf("expected the first sample to have no window, got %+v", samples[0])
+	}
+	if got := samples[1]; got.Viewers != 180 || got.WindowStart == nil || !got.WindowStart.Equal(base) || got.Messages != 3 {
+		t.Fatalf("unexpected second sample %+v", got)
+	}
+
+	latest, err := db.ListViewerSamples(ctx, httpapi.Filters{Limit: 1})
+	if err != nil {
+		t.Fatalf("list latest: %v", err)
+	}
+	if len(latest) != 1 || latest[0].Viewers != 150 {
+		t.Fatalf("expected newest sample first, got %+v", latest)
+	}
+}
+
+func TestSQLiteMarkers(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	start := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+
+	if err := db.RecordStreamState(ctx, core.StreamState{Platform: "Twitch", Channel: "elora", State: core.StreamLive, Ts: start}); err != nil {
+		t.Fatalf("record live: %v", err)
+	}
+	session := db.activeSession("Twitch")
+	if session == "" {
+		t.Fatalf("expected an active twitch session")
+	}
+	if _, err := db.AddMarker(ctx, core.Marker{Platform: "Twitch", Rule: "clip", Label: "clip by alice", MessageID: "m1", Username: "alice", Ts: start.Add(time.Minute)}); err != nil {
+		t.Fatalf("add marker: %v", err)
+	}
+	if _, err := db.AddMarker(ctx, core.Marker{Platform: "YouTube", Rule: "clip", Label: "clip by bob", Ts: start.Add(2 * time.Minute)}); err != nil {
+		t.Fatalf("add youtube marker: %v", err)
+	}
+	if _, err := db.AddMarker(ctx, core.Marker{Rule: "clip"}); err == nil {
+		t.Fatalf("expected an error for a marker without platform")
+	}
+
+	all, err := db.ListMarkers(ctx, httpapi.Filters{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(all) != 2 || all[0].Platform != "YouTube" || all[0].SessionID != "" {
+		t.Fatalf("expected newest marker first without a session, got %+v", all)
+	}
+
+	bySession, err := db.ListMarkers(ctx, httpapi.Filters{SessionIDs: []string{session}})
+	if err != nil {
+		t.Fatalf("list by session: %v", err)
+	}
+	if len(bySession) != 1 {
+		t.Fatalf("expected one marker in session, got %+v", bySession)
+	}
+	if got := bySession[0]; got.Label != "clip by alice" || got.MessageID != "m1" || got.Username != "alice" || !got.Ts.Equal(start.Add(time.Minute)) {
+		t.Fatalf("unexpected marker %+v", got)
+	}
+}
+
+func TestSQLitePolls(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	start := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+
+	if err := db.RecordStreamState(ctx, core.StreamState{Platform: "Twitch", Channel: "elora", State: core.StreamLive, Ts: start}); err != nil {
+		t.Fatalf("record live: %v", err)
+	}
+	begin := core.Poll{Platform: "Twitch", Channel: "elora", ID: "r1", Kind: core.PollKindPrediction, Title: "Win?", Status: "active",
+		Options: []core.PollOption{{ID: "o1", Title: "Yes"}, {ID: "o2", Title: "No"}}, StartedAt: start.Add(time.Minute)}
+	if err := db.RecordPoll(ctx, begin); err != nil {
+		t.Fatalf("record begin: %v", err)
+	}
+	end := begin
+	end.Status = "resolved"
+	end.WinningOptionID = "o1"
+	end.Options = []core.PollOption{{ID: "o1", Title: "Yes", Votes: 10, ChannelPoints: 5000}, {ID: "o2", Title: "No", Votes: 4, ChannelPoints: 800}}
+	end.EndedAt = start.Add(5 * time.Minute)
+	if err := db.RecordPoll(ctx, end); err != nil {
+		t.Fatalf("record end: %v", err)
+	}
+	// A progress update delivered late must not reopen the prediction.
+	late := begin
+	late.Options = []core.PollOption{{ID: "o1", Title: "Yes", Votes: 9}}
+	if err := db.RecordPoll(ctx, late); err != nil {
+		t.Fatalf("record late progress: %v", err)
+	}
+	if err := db.RecordPoll(ctx, core.Poll{Platform: "YouTube", ID: "yt-1", Title: "Ship it?", Status: "active", StartedAt: start.Add(2 * time.Minute)}); err != nil {
+		t.Fatalf("record youtube poll: %v", err)
+	}
+
+	polls, err := db.ListPolls(ctx, httpapi.Filters{Platforms: []string{"Twitch"}})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(polls) != 1 {
+		t.Fatalf("expected one twitch poll, got %+v", polls)
+	}
+	got := polls[0]
+	if got.Status != "resolved" || got.WinningOptionID != "o1" || got.EndedAt == nil || !got.EndedAt.Equal(end.EndedAt) {
+		t.Fatalf("unexpected final state %+v", got)
+	}
+	if len(got.Options) != 2 || got.Options[0].Votes != 10 || got.Options[0].ChannelPoints != 5000 {
+		t.Fatalf("unexpected final results %+v", got.Options)
+	}
+	if got.SessionID == "" || got.Kind != core.PollKindPrediction || !got.StartedAt.Equal(begin.StartedAt) {
+		t.Fatalf("expected session and start from the first report, got %+v", got)
+	}
+
+	all, err := db.ListPolls(ctx, httpapi.Filters{Order: httpapi.OrderAsc})
+	if err != nil {
+		t.Fatalf("list all: %v", err)
+	}
+	if len(all) != 2 || all[1].ID != "yt-1" || all[1].EndedAt != nil || all[1].Options == nil {
+		t.Fatalf("unexpected polls %+v", all)
+	}
+}
+
+func TestSQLiteRaids(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+
+	raid := core.Raid{Platform: "Twitch", Channel: "elora", Direction: core.RaidIncoming, FromChannel: "Friend", FromName: "Friend",
+		ToChannel: "elora", Viewers: 42, Text: "42 raiders from Friend have joined!", Ts: ts}
+	added, err := db.RecordRaid(ctx, raid)
+	if err != nil || !added {
+		t.Fatalf("record raid: added=%v err=%v", added, err)
+	}
+	// EventSub reports the same raid a few seconds later.
+	dup := raid
+	dup.Text = ""
+	dup.Ts = ts.Add(3 * time.Second)
+	if added, err := db.RecordRaid(ctx, dup); err != nil || added {
+		t.Fatalf("expected duplicate raid to be skipped: added=%v err=%v", added, err)
+	}
+	out := core.Raid{Platform: "Twitch", Channel: "elora", Direction: core.RaidOutgoing, FromChannel: "elora", ToChannel: "pal", Viewers: 300, Ts: ts.Add(time.Hour / 2)}
+	if added, err := db.RecordRaid(ctx, out); err != nil || !added {
+		t.Fatalf("record outgoing raid: added=%v err=%v", added, err)
+	}
+
+	raids, err := db.ListRaids(ctx, httpapi.Filters{Order: httpapi.OrderAsc})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(raids) != 2 {
+		t.Fatalf("expected 2 raids, got %+v", raids)
+	}
+	if got := raids[0]; got.FromChannel != "friend" || got.Viewers != 42 || got.Direction != core.RaidIncoming || !got.Ts.Equal(ts) {
+		t.Fatalf("unexpected incoming raid %+v", got)
+	}
+	if raids[1].Direction != core.RaidOutgoing || raids[1].ToChannel != "pal" {
+		t.Fatalf("unexpected outgoing raid %+v", raids[1])
+	}
+
+	if err := db.Write(core.ChatMessage{ID: "raid-1", Platform: "Twitch", Username: "Friend", Text: raid.Text, Ts: ts, MessageType: core.MessageTypeRaid}, nil); err != nil {
+		t.Fatalf("write raid message: %v", err)
+	}
+	msgs, err := db.ListMessages(ctx, httpapi.Filters{})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].MessageType != core.MessageTypeRaid {
+		t.Fatalf("expected the raid message type to round-trip, got %+v", msgs)
+	}
+}
+
+func TestSQLiteAutoMod(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	held := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+
+	hold := core.AutoModEvent{Platform: "Twitch", Channel: "elora", MessageID: "m1", UserID: "42", Username: "troll",
+		Text: "rude words", Status: core.AutoModHeld, Reason: "swearing", Level: 3, HeldAt: held}
+	if err := db.RecordAutoMod(ctx, hold); err != nil {
+		t.Fatalf("record hold: %v", err)
+	}
+	if err := db.RecordAutoMod(ctx, core.AutoModEvent{Platform: "Twitch", MessageID: "m2", Username: "pal", Text: "borderline",
+		Status: core.AutoModHeld, Reason: "blocked_term", HeldAt: held.Add(time.Minute)}); err != nil {
+		t.Fatalf("record hold: %v", err)
+	}
+	resolved := held.Add(30 * time.Second)
+	if err := db.RecordAutoMod(ctx, core.AutoModEvent{Platform: "Twitch", MessageID: "m1", Status: core.AutoModDenied, Moderator: "mod",
+		HeldAt: held, ResolvedAt: resolved}); err != nil {
+		t.Fatalf("record denial: %v", err)
+	}
+	// A redelivered hold does not reopen the denied message.
+	if err := db.RecordAutoMod(ctx, hold); err != nil {
+		t.Fatalf("record hold again: %v", err)
+	}
+
+	events, err := db.ListAutoMod(ctx, httpapi.Filters{Order: httpapi.OrderAsc}, nil)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", events)
+	}
+	got := events[0]
+	if got.Status != core.AutoModDenied || got.Moderator != "mod" || got.Text != "rude words" || got.Reason != "swearing" || got.Level != 3 ||
+		got.ResolvedAt == nil || !got.ResolvedAt.Equal(resolved) || !got.HeldAt.Equal(held) {
+		t.Fatalf("unexpected resolved event %+v", got)
+	}
+	if events[1].Status != core.AutoModHeld || events[1].ResolvedAt != nil {
+		t.Fatalf("unexpected held event %+v", events[1])
+	}
+
+	pending, err := db.ListAutoMod(ctx, httpapi.Filters{}, []string{core.AutoModHeld})
+	if err != nil || len(pending) != 1 || pending[0].MessageID != "m2" {
+		t.Fatalf("status filter: %+v err=%v", pending, err)
+	}
+
+	if _, _, err := db.EraseUser(ctx, "Twitch", "troll", false); err != nil {
+		t.Fatalf("erase: %v", err)
+	}
+	if events, _ := db.ListAutoMod(ctx, httpapi.Filters{}, nil); len(events) != 1 || events[0].MessageID != "m2" {
+		t.Fatalf("expected erasure to remove the held message, got %+v", events)
+	}
+}
+
+func TestSQLiteListFilters(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	t0 := time.Now().UTC().Add(-2 * time.Hour).Truncate(time.Millisecond)
+	at := func(d time.Duration) *time.Time { ts := t0.Add(d); return &ts }
+
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	raid := func(r core.Raid) error {
+		_, err := db.RecordRaid(ctx, r)
+		return err
+	}
+	// One of each before the stream goes live, the rest during it.
+	must(db.RecordAutoMod(ctx, core.AutoModEvent{Platform: "Twitch", Channel: "elora", MessageID: "a1", Username: "troll", Status: core.AutoModHeld, HeldAt: t0}))
+	must(db.RecordRedemption(ctx, core.Redemption{Platform: "Twitch", Channel: "elora", ID: "d1", RewardID: "rw1", RewardTitle: "Song request", Username: "amy", RedeemedAt: t0}))
+	must(raid(core.Raid{Platform: "Twitch", Channel: "elora", Direction: core.RaidIncoming, FromChannel: "friend", ToChannel: "elora", Ts: t0}))
+	must(db.RecordStreamState(ctx, core.StreamState{Platform: "Twitch", Channel: "elora", State: core.StreamLive, Ts: t0.Add(time.Hour)}))
+	session := db.activeSession("Twitch")
+	live := t0.Add(time.Hour + 5*time.Minute)
+	must(db.RecordAutoMod(ctx, core.AutoModEvent{Platform: "Twitch", Channel: "rival", MessageID: "a2", Username: "troll", Status: core.AutoModHeld, HeldAt: live}))
+	must(db.RecordAutoMod(ctx, core.AutoModEvent{Platform: "YouTube", MessageID: "a3", Username: "fan", Status: core.AutoModDenied, HeldAt: live.Add(time.Minute)}))
+	must(db.RecordRedemption(ctx, core.Redemption{Platform: "Twitch", Channel: "rival", ID: "d2", RewardID: "rw2", RewardTitle: "Hydrate", Username: "bob", RedeemedAt: live}))
+	must(raid(core.Raid{Platform: "Twitch", Channel: "elora", Direction: core.RaidOutgoing, FromChannel: "elora", ToChannel: "pal", Ts: live}))
+
+	autoMod := func(f httpapi.Filters, statuses ...string) func() ([]string, error) {
+		return func() ([]string, error) {
+			events, err := db.ListAutoMod(ctx, f, statuses)
+			var ids []string
+			for _, ev := range events {
+				ids = append(ids, ev.MessageID)
+			}
+			return ids, err
+		}
+	}
+	raids := func(f httpapi.Filters) func() ([]string, error) {
+		return func() ([]string, error) {
+			list, err := db.ListRaids(ctx, f)
+			var ids []string
+			for _, r := range list {
+				ids = append(ids, r.FromChannel+">"+r.ToChannel)
+			}
+			return ids, err
+		}
+	}
+	redemptions := func(f httpapi.Filters, rewards ...string) func() ([]string, error) {
+		return func() ([]string, error) {
+			list, err := db.ListRedemptions(ctx, f, rewards)
+			var ids []string
+			for _, r := range list {
+				ids = append(ids, r.ID)
+			}
+			return ids, err
+		}
+	}
+
+	cases := []struct {
+		name string
+		list func() ([]string, error)
+		want []string
+	}{
+		{"automod by channel", autoMod(httpapi.Filters{Channels: []string{"elora"}}), []string{"a1"}},
+		{"automod by session", autoMod(httpapi.Filters{SessionIDs: []string{session}}), []string{"a2"}},
+		{"automod by platform", autoMod(httpapi.Filters{Platforms: []string{"YouTube"}}), []string{"a3"}},
+		{"automod since, oldest first", autoMod(httpapi.Filters{Since: at(30 * time.Minute), Order: httpapi.OrderAsc}), []string{"a2", "a3"}},
+		{"automod by status and limit", autoMod(httpapi.Filters{Limit: 1}, core.AutoModHeld), []string{"a2"}},
+		{"automod by channel and status", autoMod(httpapi.Filters{Channels: []string{"rival"}}, core.AutoModDenied), nil},
+		{"raids by session", raids(httpapi.Filters{SessionIDs: []string{session}}), []string{"elora>pal"}},
+		{"raids until", raids(httpapi.Filters{Until: at(30 * time.Minute)}), []string{"friend>elora"}},
+		{"raids by platform", raids(httpapi.Filters{Platforms: []string{"YouTube"}}), nil},
+		{"redemptions by channel", redemptions(httpapi.Filters{Channels: []string{"rival"}}), []string{"d2"}},
+		{"redemptions by session and reward", redemptions(httpapi.Filters{SessionIDs: []string{session}}, "Song request"), nil},
+		{"redemptions until by reward title", redemptions(httpapi.Filters{Until: at(30 * time.Minute)}, "song REQUEST"), []string{"d1"}},
+		{"redemptions newest first", redemptions(httpapi.Filters{}), []string{"d2", "d1"}},
+	}
+	for _, tc := range cases {
+		got, err := tc.list()
+		if err != nil || !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%s: got %v (%v), want %v", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestSQLiteRedemptions(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	at := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+
+	song := core.Redemption{Platform: "Twitch", Channel: "elora", ID: "r1", RewardID: "rw1", RewardTitle: "Song request", Cost: 500,
+		UserID: "42", Username: "Amy", UserInput: "play it again", Status: "unfulfilled", RedeemedAt: at}
+	for i := 0; i < 2; i++ {
+		if err := db.RecordRedemption(ctx, song); err != nil {
+			t.Fatalf("record: %v", err)
+		}
+	}
+	if err := db.RecordRedemption(ctx, core.Redemption{Platform: "Twitch", Channel: "elora", ID: "r2", RewardID: "rw2", RewardTitle: "Hydrate",
+		Cost: 100, UserID: "43", Username: "bob", RedeemedAt: at.Add(time.Minute)}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	list, err := db.ListRedemptions(ctx, httpapi.Filters{Order: httpapi.OrderAsc}, nil)
+	if err != nil || len(list) != 2 || list[0].ID != "r1" || list[0].Username != "amy" || list[0].Cost != 500 || !list[0].RedeemedAt.Equal(at) {
+		t.Fatalf("unexpected redemptions %+v (%v)", list, err)
+	}
+	for _, reward := range []string{"rw2", "HYDRATE"} {
+		list, err = db.ListRedemptions(ctx, httpapi.Filters{}, []string{reward})
+		if err != nil || len(list) != 1 || list[0].ID != "r2" {
+			t.Fatalf("reward %q: unexpected redemptions %+v (%v)", reward, list, err)
+		}
+	}
+
+	msg := core.ChatMessage{ID: "m1", Platform: "Twitch", Username: "amy", Text: "play it again", Ts: at, RewardID: "rw1"}
+	if err := db.Write(msg, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	rows, err := db.ListMessages(ctx, httpapi.Filters{})
+	if err != nil || len(rows) != 1 || rows[0].RewardID != "rw1" {
+		t.Fatalf("expected the message to keep its reward id, got %+v (%v)", rows, err)
+	}
+}
+
+func TestSQLiteTTSQueue(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+
+	item := core.TTSItem{Platform: "YouTube", Channel: "UC1", Rule: "superchat", MessageID: "m1", UserID: "UCbob", Username: "bob", Text: "hello there"}
+	id, err := db.EnqueueTTS(ctx, item)
+	if err != nil || id == 0 {
+		t.Fatalf("enqueue: id=%d err=%v", id, err)
+	}
+	// The same message is queued once per rule.
+	if dup, err := db.EnqueueTTS(ctx, item); err != nil || dup != 0 {
+		t.Fatalf("expected duplicate to be ignored, got id=%d err=%v", dup, err)
+	}
+	if _, err := db.EnqueueTTS(ctx, core.TTSItem{Platform: "Twitch", Channel: "elora", Rule: "redeem", MessageID: "m2", Username: "amy", Text: "read me"}); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	items, err := db.ListTTS(ctx, httpapi.Filters{})
+	if err != nil || len(items) != 2 || items[0].ID != id || items[0].Text != "hello there" {
+		t.Fatalf("unexpected queue %+v (%v)", items, err)
+	}
+	// Acks outside the caller's scope are ignored.
+	if n, err := db.AckTTS(ctx, []int64{id}, httpapi.Filters{Channels: []string{"elora"}}); err != nil || n != 0 {
+		t.Fatalf("expected scoped ack to skip, got %d (%v)", n, err)
+	}
+	if n, err := db.AckTTS(ctx, []int64{id, id, 999}, httpapi.Filters{}); err != nil || n != 1 {
+		t.Fatalf("expected one ack, got %d (%v)", n, err)
+	}
+	items, err = db.ListTTS(ctx, httpapi.Filters{Platforms: []string{"Twitch"}})
+	if err != nil || len(items) != 1 || items[0].Username != "amy" {
+		t.Fatalf("unexpected queue after ack %+v (%v)", items, err)
+	}
+
+	if _, _, err := db.EraseUser(ctx, "Twitch", "amy", false); err != nil {
+		t.Fatalf("erase: %v", err)
+	}
+	if items, err := db.ListTTS(ctx, httpapi.Filters{}); err != nil || len(items) != 0 {
+		t.Fatalf("expected erasure to clear the queue, got %+v (%v)", items, err)
+	}
+}
+
+func TestSQLitePresence(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	start := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+
+	record := func(user string, joined bool, offset time.Duration) {
+		t.Helper()
+		if err := db.RecordPresence(ctx, core.Presence{Platform: "Twitch", Channel: "Elora", Username: user, Joined: joined, Ts: start.Add(offset)}); err != nil {
+			t.Fatalf("record presence: %v", err)
+		}
+	}
+	record("Alice", true, 0)
+	record("alice", true, 5*time.Minute) // already present
+	record("bob", true, 10*time.Minute)
+	record("alice", false, 20*time.Minute)
+	record("alice", true, 30*time.Minute)
+
+	names := func(at time.Duration) string {
+		t.Helper()
+		list, err := db.ListPresence(ctx, []string{"Twitch"}, "elora", start.Add(at))
+		if err != nil {
+			t.Fatalf("list presence: %v", err)
+		}
+		var out []string
+		for _, p := range list {
+			out = append(out, p.Username)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := names(15 * time.Minute); got != "alice,bob" {
+		t.Fatalf("at 15m: %q", got)
+	}
+	if got := names(25 * time.Minute); got != "bob" {
+		t.Fatalf("at 25m: %q", got)
+	}
+	if got := names(-time.Minute); got != "" {
+		t.Fatalf("before any join: %q", got)
+	}
+
+	closed, err := db.ClosePresence(ctx, "Twitch", start.Add(40*time.Minute))
+	if err != nil || closed != 2 {
+		t.Fatalf("close presence = %d, %v", closed, err)
+	}
+	if got := names(45 * time.Minute); got != "" {
+		t.Fatalf("after close: %q", got)
+	}
+	if got := names(35 * time.Minute); got != "alice,bob" {
+		t.Fatalf("at 35m: %q", got)
+	}
+
+	if err := db.RecordPresence(ctx, core.Presence{Platform: "Twitch", Username: "carol", Joined: true}); err == nil {
+		t.Fatalf("expected an error for presence without channel")
+	}
+}
+
+func TestSQLiteWhispersHidden(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+
+	for _, msg := range []core.ChatMessage{
+		{ID: "tw-1", Platform: "Twitch", Username: "alice", Text: "hi chat", Ts: ts},
+		{ID: "whisper-1_2-7", Platform: "Twitch", Username: "alice", Text: "psst", Ts: ts.Add(time.Second), MessageType: core.MessageTypeWhisper},
+	} {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	public, err := db.ListMessages(ctx, httpapi.Filters{Limit: 10})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(public) != 1 || public[0].ID != "tw-1" {
+		t.Fatalf("expected whispers hidden by default, got %+v", public)
+	}
+	all, err := db.ListMessages(ctx, httpapi.Filters{Limit: 10, IncludeWhispers: true})
+	if err != nil {
+		t.Fatalf("list with whispers: %v", err)
+	}
+	if len(all) != 2 || all[0].MessageType != core.MessageTypeWhisper {
+		t.Fatalf("expected the whisper with include_whispers, got %+v", all)
+	}
+}
+
+func TestSQLiteTypeFilter(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+
+	for _, msg := range []core.ChatMessage{
+		{ID: "tw-1", Platform: "Twitch", Username: "alice", Text: "hi chat", Ts: ts},
+		{ID: "tw-2", Platform: "Twitch", Username: "bob", Text: "bob subscribed", Ts: ts.Add(time.Second), MessageType: core.MessageTypeSub},
+		{ID: "yt-1", Platform: "YouTube", Username: "carol", Text: "$5.00", Ts: ts.Add(2 * time.Second), MessageType: core.MessageTypeSuperchat},
+		{ID: "tw-3", Platform: "Twitch", Username: "spammer", Text: "spam", Ts: ts.Add(3 * time.Second)},
+	} {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if _, err := db.DeleteMessages(ctx, core.MessageDeletion{Platform: "Twitch", ID: "tw-3", DeletedAt: ts.Add(time.Minute), DeletedBy: "twitch:clearmsg"}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	ids := func(filters httpapi.Filters) []string {
+		t.Helper()
+		filters.Limit = 10
+		filters.Order = httpapi.OrderAsc
+		msgs, err := db.ListMessages(ctx, filters)
+		if err != nil {
+			t.Fatalf("list %+v: %v", filters, err)
+		}
+		var out []string
+		for _, msg := range msgs {
+			out = append(out, msg.ID)
+		}
+		return out
+	}
+
+	all := ids(httpapi.Filters{})
+	if len(all) != 3 {
+		t.Fatalf("expected 3 visible messages, got %v", all)
+	}
+	msgs, err := db.ListMessages(ctx, httpapi.Filters{Limit: 10, Order: httpapi.OrderAsc})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if msgs[0].MessageType != core.MessageTypeChat {
+		t.Fatalf("expected untyped chat to read back as chat, got %q", msgs[0].MessageType)
+	}
+	if got := ids(httpapi.Filters{Types: []string{core.MessageTypeSuperchat, core.MessageTypeSub}}); !reflect.DeepEqual(got, []string{"tw-2", "yt-1"}) {
+		t.Fatalf("superchat,sub = %v", got)
+	}
+	if got := ids(httpapi.Filters{Types: []string{core.MessageTypeChat}}); !reflect.DeepEqual(got, []string{"tw-1"}) {
+		t.Fatalf("chat = %v", got)
+	}
+	if got := ids(httpapi.Filters{Types: []string{core.MessageTypeDeleted}}); !reflect.DeepEqual(got, []string{"tw-3"}) {
+		t.Fatalf("deleted = %v", got)
+	}
+	if got := ids(httpapi.Filters{Types: []string{core.MessageTypeChat, core.MessageTypeDeleted}}); !reflect.DeepEqual(got, []string{"tw-1", "tw-3"}) {
+		t.Fatalf("chat,deleted = %v", got)
+	}
+}
+
+func TestSQLiteChannelFilter(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+
+	for _, msg := range []core.ChatMessage{
+		{ID: "tw-1", Platform: "Twitch", Username: "alice", Text: "hi", Ts: ts, Channel: "Alice"},
+		{ID: "tw-2", Platform: "Twitch", Username: "bob", Text: "hi", Ts: ts.Add(time.Second), Channel: "bob"},
+		{ID: "yt-1", Platform: "YouTube", Username: "carol", Text: "hi", Ts: ts.Add(2 * time.Second)},
+	} {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	msgs, err := db.ListMessages(ctx, httpapi.Filters{Limit: 10, Channels: []string{"alice"}})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].ID != "tw-1" {
+		t.Fatalf("expected only alice's channel, got %+v", msgs)
+	}
+	count, err := db.CountMessages(ctx, httpapi.Filters{Channels: []string{"alice", "bob"}})
+	if err != nil || count != 2 {
+		t.Fatalf("count = %d err=%v, want 2", count, err)
+	}
+}
+
+func TestSQLitePlatformIDFilters(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	ts := time.Now().UTC().Add(-time.Minute).Truncate(time.Millisecond)
+
+	for _, msg := range []core.ChatMessage{
+		{ID: "tw-1", Platform: "Twitch", Username: "alice", Text: "hi", Ts: ts, Channel: "elora", UserID: "111", ChannelID: "900"},
+		// Same account after a rename.
+		{ID: "tw-2", Platform: "Twitch", Username: "alice_renamed", Text: "hi", Ts: ts.Add(time.Second), Channel: "elora", UserID: "111", ChannelID: "900"},
+		{ID: "tw-3", Platform: "Twitch", Username: "bob", Text: "hi", Ts: ts.Add(2 * time.Second), Channel: "other", UserID: "222", ChannelID: "901"},
+	} {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	msgs, err := db.ListMessages(ctx, httpapi.Filters{Limit: 10, Order: httpapi.OrderAsc, UserIDs: []string{"111"}})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].Username != "alice" || msgs[1].Username != "alice_renamed" {
+		t.Fatalf("expected both of user 111's messages, got %+v", msgs)
+	}
+	if msgs[0].UserID != "111" || msgs[0].ChannelID != "900" {
+		t.Fatalf("IDs not read back: %+v", msgs[0])
+	}
+	count, err := db.CountMessages(ctx, httpapi.Filters{ChannelIDs: []string{"901"}})
+	if err != nil || count != 1 {
+		t.Fatalf("count = %d err=%v, want 1", count, err)
+	}
+}
+
+func TestSQLiteReceivedAt(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	base := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+	at := func(d time.Duration) *time.Time {
+		ts := base.Add(d)
+		return &ts
+	}
+
+	// yt-1's platform timestamp lags: it was sent before tw-1 by the
+	// platform's clock but arrived after it.
+	for _, msg := range []core.ChatMessage{
+		{ID: "tw-1", Platform: "Twitch", Username: "alice", Text: "first", Ts: base.Add(10 * time.Second), ReceivedAt: at(10 * time.Second)},
+		{ID: "yt-1", Platform: "YouTube", Username: "bob", Text: "second", Ts: base, ReceivedAt: at(20 * time.Second)},
+		{ID: "tw-2", Platform: "Twitch", Username: "carol", Text: "third", Ts: base.Add(30 * time.Second), ReceivedAt: at(30 * time.Second)},
+	} {
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	ids := func(msgs []core.ChatMessage) string {
+		var out []string
+		for _, m := range msgs {
+			out = append(out, m.ID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	msgs, err := db.ListMessages(ctx, httpapi.Filters{Limit: 10, Order: httpapi.OrderAsc})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if got := ids(msgs); got != "yt-1,tw-1,tw-2" {
+		t.Fatalf("platform order = %s", got)
+	}
+	if msgs[0].ReceivedAt == nil || !msgs[0].ReceivedAt.Equal(base.Add(20*time.Second)) {
+		t.Fatalf("received_at not read back: %+v", msgs[0].ReceivedAt)
+	}
+
+	msgs, err = db.ListMessages(ctx, httpapi.Filters{Limit: 10, Order: httpapi.OrderAsc, TimeField: httpapi.TimeFieldReceived})
+	if err != nil {
+		t.Fatalf("list by received: %v", err)
+	}
+	if got := ids(msgs); got != "tw-1,yt-1,tw-2" {
+		t.Fatalf("received order = %s", got)
+	}
+
+	since := base.Add(15 * time.Second)
+	count, err := db.CountMessages(ctx, httpapi.Filters{Since: &since, TimeField: httpapi.TimeFieldReceived})
+	if err != nil || count != 2 {
+		t.Fatalf("count since by received = %d err=%v, want 2", count, err)
+	}
+	count, err = db.CountMessages(ctx, httpapi.Filters{Since: &since})
+	if err != nil || count != 1 {
+		t.Fatalf("count since by platform = %d err=%v, want 1", count, err)
+	}
+
+	// Rows written before receive times were recorded sort by ts.
+	if _, err := db.RawDB().Exec(`UPDATE messages SET received_at = 0 WHERE platform_msg_id = 'tw-2';`); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err = db.ListMessages(ctx, httpapi.Filters{Limit: 1, TimeField: httpapi.TimeFieldReceived})
+	if err != nil || len(msgs) != 1 || msgs[0].ID != "tw-2" || msgs[0].ReceivedAt != nil {
+		t.Fatalf("legacy row = %+v err=%v", msgs, err)
+	}
+}
+
+func TestSQLiteSessionHook(t *testing.T) {
+	db, err := OpenSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	var got []string
+	db.SetSessionHook(func(st core.StreamState, sessionID string) {
+		got = append(got, st.State+" "+sessionID)
+	})
+	ctx := context.Background()
+	start := time.Now().UTC().Truncate(time.Millisecond)
+	live := core.StreamState{Platform: "YouTube", Channel: "@chan", State: core.StreamLive, Detail: map[string]any{"video_id": "abc"}, Ts: start}
+	for i := 0; i < 2; i++ {
+		if err := db.RecordStreamState(ctx, live); err != nil {
+			t.Fatalf("record live: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if err := db.RecordStreamState(ctx, core.StreamState{Platform: "YouTube", Channel: "@chan", State: core.StreamEnded, Ts: start.Add(time.Hour)}); err != nil {
+			t.Fatalf("record ended: %v", err)
+		}
+	}
+	want := []string{core.StreamLive + " youtube:abc", core.StreamEnded + " youtube:abc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("session hook calls = %v, want %v", got, want)
+	}
+}
+
+func TestSQLiteMessageActivity(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	base := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	for i, msg := range []core.ChatMessage{
+		{Platform: "Twitch", Username: "alice", Text: "a", Ts: base.Add(time.Minute)},
+		{Platform: "Twitch", Username: "bob", Text: "b", Ts: base.Add(14 * time.Minute)},
+		{Platform: "YouTube", Username: "carol", Text: "c", Ts: base.Add(5 * time.Minute)},
+		{Platform: "Twitch", Username: "dave", Text: "d", Ts: base.Add(20 * time.Minute)},
+		{Platform: "Twitch", Username: "erin", Text: "e", Ts: base.Add(-time.Hour)},
+	} {
+		msg.ID = fmt.Sprintf("m%d", i)
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	since, until := base, base.Add(time.Hour)
+	slots, err := db.MessageActivity(ctx, httpapi.Filters{Since: &since, Until: &until}, 15*time.Minute)
+	if err != nil {
+		t.Fatalf("activity: %v", err)
+	}
+	want := []httpapi.ActivitySlot{
+		{Start: base, Platform: "Twitch", Messages: 2},
+		{Start: base, Platform: "YouTube", Messages: 1},
+		{Start: base.Add(15 * time.Minute), Platform: "Twitch", Messages: 1},
+	}
+	if !reflect.DeepEqual(slots, want) {
+		t.Fatalf("slots = %+v", slots)
+	}
+	slots, err = db.MessageActivity(ctx, httpapi.Filters{Platforms: []string{"YouTube"}, Since: &since, Until: &until}, 15*time.Minute)
+	if err != nil || len(slots) != 1 || slots[0].Messages != 1 {
+		t.Fatalf("platform filter: %+v err=%v", slots, err)
+	}
+}
+
+func TestSQLiteSpamScores(t *testing.T) {
+	db := openTestSQLite(t)
+	ctx := context.Background()
+	at := time.Now().UTC().Add(-time.Hour).Truncate(time.Millisecond)
+
+	for i, user := range []string{"bot_a", "bot_b", "bot_c"} {
+		msg := core.ChatMessage{ID: fmt.Sprint("s", i), Platform: "Twitch", Username: user, Text: "Get free viewers",
+			Ts: at.Add(time.Duration(i) * time.Second)}
+		if i > 0 {
+			msg.SpamScore = 0.6
+		}
+		if err := db.Write(msg, nil); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if err := db.Write(core.ChatMessage{ID: "ok", Platform: "Twitch", Username: "amy", Text: "hello", Ts: at}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	// An earlier message of the burst is flagged after it was stored.
+	if err := db.SetSpamScore(ctx, core.ChatMessage{ID: "s0", Platform: "Twitch", SpamScore: 0.5}); err != nil {
+		t.Fatalf("set spam score: %v", err)
+	}
+	if _, err := db.DeleteMessages(ctx, core.MessageDeletion{Platform: "Twitch", ID: "s2", DeletedAt: at.Add(time.Minute)}); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	msgs, err := db.ListMessages(ctx, httpapi.Filters{Order: httpapi.OrderAsc, Usernames: []string{"bot_a"}})
+	if err != nil || len(msgs) != 1 || msgs[0].SpamScore != 0.5 {
+		t.Fatalf("unexpected messages %+v (%v)", msgs, err)
+	}
+
+	summary, err := db.ModerationSummary(ctx, httpapi.Filters{}, 0.5, 10)
+	if err != nil {
+		t.Fatalf("summary: %v", err)
+	}
+	if summary.Deleted != 1 || summary.Spam.Messages != 3 || summary.Spam.Accounts != 3 || len(summary.Spam.Bursts) != 1 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if b := summary.Spam.Bursts[0]; b.Text != "Get free viewers" || b.Messages != 3 || b.MaxScore != 0.6 || !b.FirstSeen.Equal(at) {
+		t.Fatalf("unexpected burst %+v", b)
+	}
+	if summary, err = db.ModerationSummary(ctx, httpapi.Filters{}, 0.55, 10); err != nil || summary.Spam.Messages != 2 {
+		t.Fatalf("unexpected summary at 0.55 %+v (%v)", summary, err)
+	}
+}
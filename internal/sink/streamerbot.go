@@ -0,0 +1,195 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+	"github.com/you/gnasty-chat/internal/streamerbot"
+)
+
+const (
+	defaultStreamerBotAction = "gnasty chat"
+	streamerBotQueueSize     = 1024
+	streamerBotMaxBackoff    = 30 * time.Second
+)
+
+// StreamerBotOptions configures the Streamer.bot sink.
+type StreamerBotOptions struct {
+	URL      string
+	Password string
+	// Action is the name of the Streamer.bot action run for each message.
+	Action string
+}
+
+// StreamerBotPublisher runs a Streamer.bot action for each written message,
+// passing the message as action arguments. Writes are queued and never block
+// ingest; messages are dropped while the queue is full.
+type StreamerBotPublisher struct {
+	opts  StreamerBotOptions
+	queue chan core.ChatMessage
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	dropped int64
+}
+
+// NewStreamerBotPublisher starts the background publisher. The connection
+// is established (and re-established) asynchronously.
+func NewStreamerBotPublisher(opts StreamerBotOptions) *StreamerBotPublisher {
+	if strings.TrimSpace(opts.URL) == "" {
+		opts.URL = streamerbot.DefaultURL
+	}
+	if strings.TrimSpace(opts.Action) == "" {
+		opts.Action = defaultStreamerBotAction
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &StreamerBotPublisher{
+		opts:   opts,
+		queue:  make(chan core.ChatMessage, streamerBotQueueSize),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	go p.run(ctx)
+	return p
+}
+
+func (p *StreamerBotPublisher) Write(msg core.ChatMessage, _ *ingesttrace.MessageTrace) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return errors.New("streamerbot publisher closed")
+	}
+	select {
+	case p.queue <- msg:
+	default:
+		p.dropped++
+		if p.dropped == 1 || p.dropped%1000 == 0 {
+			log.Printf("sink: streamerbot: queue full, dropped=%d", p.dropped)
+		}
+	}
+	return nil
+}
+
+// Close stops the publisher after a best-effort flush of queued messages.
+func (p *StreamerBotPublisher) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.queue)
+	p.mu.Unlock()
+
+	select {
+	case <-p.done:
+	case <-time.After(5 * time.Second):
+		p.cancel()
+		<-p.done
+	}
+	p.cancel()
+	return nil
+}
+
+func (p *StreamerBotPublisher) run(ctx context.Context) {
+	defer close(p.done)
+
+	var (
+		client  *streamerbot.Client
+		backoff = time.Second
+		pending *core.ChatMessage
+	)
+	defer func() {
+		if client != nil {
+			_ = client.Close()
+		}
+	}()
+
+	for {
+		if client == nil || client.Err() != nil {
+			if client != nil {
+				log.Printf("sink: streamerbot: connection lost: %v", client.Err())
+			}
+			dialCtx, cancel := context.WithTimeout(ctx, 15*time.Second)
+			c, err := streamerbot.Dial(dialCtx, streamerbot.Options{URL: p.opts.URL, Password: p.opts.Password})
+			cancel()
+			if err != nil {
+				log.Printf("sink: streamerbot: %v; retrying in %s", err, backoff)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(backoff):
+				}
+				backoff *= 2
+				if backoff > streamerBotMaxBackoff {
+					backoff = streamerBotMaxBackoff
+				}
+				continue
+			}
+			client = c
+			backoff = time.Second
+			log.Printf("sink: streamerbot: connected to %s", p.opts.URL)
+		}
+
+		msg := pending
+		pending = nil
+		if msg == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-client.Done():
+				continue
+			case next, ok := <-p.queue:
+				if !ok {
+					return
+				}
+				msg = &next
+			}
+		}
+
+		if err := client.DoAction(ctx, p.opts.Action, streamerBotArgs(*msg)); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("sink: streamerbot: do action: %v", err)
+			pending = msg
+			_ = client.Close()
+		}
+	}
+}
+
+// streamerBotArgs flattens msg into action arguments, which Streamer.bot
+// exposes to sub-actions as %name% variables. "json" carries the whole
+// message for C# code sub-actions.
+func streamerBotArgs(msg core.ChatMessage) map[string]any {
+	messageType := msg.MessageType
+	if messageType == "" {
+		messageType = core.MessageTypeChat
+	}
+	args := map[string]any{
+		"platform":    msg.Platform,
+		"channel":     msg.Channel,
+		"sessionId":   msg.SessionID,
+		"user":        msg.Username,
+		"userId":      msg.UserID,
+		"message":     msg.Text,
+		"messageId":   msg.ID,
+		"messageType": messageType,
+		"color":       msg.Colour,
+		"timestamp":   msg.Ts.UTC().Format(time.RFC3339Nano),
+	}
+	if data, err := json.Marshal(msg); err == nil {
+		args["json"] = string(data)
+	}
+	return args
+}
@@ -0,0 +1,169 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const streamStateSchema = `CREATE TABLE IF NOT EXISTS stream_state (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  platform TEXT NOT NULL,
+  channel TEXT NOT NULL DEFAULT '',
+  state TEXT NOT NULL,
+  detail_json TEXT NOT NULL DEFAULT '{}',
+  ts INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS stream_state_platform_ts ON stream_state(platform, channel, ts);`
+
+// RecordStreamState appends st to stream_state. A state identical to the
+// latest one of the same family for the platform/channel (e.g. a repeated
+// ROOMSTATE after reconnecting, or "live" for the same video after a restart)
+// is not recorded again. Live and ended states also open and close broadcast
+// sessions.
+func (s *SQLiteSink) RecordStreamState(ctx context.Context, st core.StreamState) error {
+	platform := strings.TrimSpace(st.Platform)
+	state := strings.TrimSpace(st.State)
+	if platform == "" || state == "" {
+		return errors.New("stream state requires platform and state")
+	}
+	detail := "{}"
+	if len(st.Detail) > 0 {
+		data, err := json.Marshal(st.Detail)
+		if err != nil {
+			return errors.Wrap(err, "encode stream state detail")
+		}
+		detail = string(data)
+	}
+	ts := st.Ts
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	family := []any{core.StreamLive, core.StreamEnded}
+	if state == core.StreamRoomState {
+		family = []any{core.StreamRoomState, core.StreamRoomState}
+	}
+	var lastState, lastDetail string
+	err := s.db.QueryRowContext(ctx, `SELECT state, detail_json FROM stream_state
+WHERE platform = ? AND channel = ? AND state IN (?, ?) ORDER BY ts DESC, id DESC LIMIT 1;`,
+		append([]any{platform, st.Channel}, family...)...).Scan(&lastState, &lastDetail)
+	if err == nil && lastState == state && (lastDetail == detail || state == core.StreamEnded) {
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) && state == core.StreamEnded {
+		// Nothing was ever live on this channel.
+		return nil
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO stream_state (platform, channel, state, detail_json, ts) VALUES (?, ?, ?, ?, ?);`,
+		platform, st.Channel, state, detail, ts.UTC().UnixMilli())
+	if err != nil {
+		return errors.Wrap(err, "insert stream state")
+	}
+	return s.recordSession(ctx, st, ts)
+}
+
+// ListStreams derives broadcast sessions from live/ended transitions: each
+// "live" opens a session that the next live/ended event for the same
+// platform/channel closes. Filters select platforms and bound started_at.
+func (s *SQLiteSink) ListStreams(ctx context.Context, filters httpapi.Filters) ([]httpapi.StreamSession, error) {
+	where, args := buildStreamWhere(filters)
+	rows, err := s.db.QueryContext(ctx, `SELECT platform, channel, state, detail_json, ts FROM stream_state
+WHERE state IN ('live', 'ended')`+where+` ORDER BY ts ASC, id ASC;`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list stream state")
+	}
+	defer rows.Close()
+
+	var (
+		sessions []httpapi.StreamSession
+		open     = map[string]int{}
+	)
+	for rows.Next() {
+		var (
+			platform, channel, state, detail string
+			tsMS                             int64
+		)
+		if err := rows.Scan(&platform, &channel, &state, &detail, &tsMS); err != nil {
+			return nil, errors.Wrap(err, "scan stream state")
+		}
+		ts := time.UnixMilli(tsMS).UTC()
+		key := platform + "\x00" + channel
+		if idx, ok := open[key]; ok {
+			end := ts
+			sessions[idx].EndedAt = &end
+			delete(open, key)
+		}
+		if state != core.StreamLive {
+			continue
+		}
+		session := httpapi.StreamSession{Platform: platform, Channel: channel, StartedAt: ts}
+		var meta map[string]any
+		if json.Unmarshal([]byte(detail), &meta) == nil {
+			session.VideoID, _ = meta["video_id"].(string)
+			session.WatchURL, _ = meta["watch_url"].(string)
+		}
+		open[key] = len(sessions)
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate stream state")
+	}
+
+	var out []httpapi.StreamSession
+	for _, session := range sessions {
+		if filters.Since != nil && session.StartedAt.Before(*filters.Since) {
+			continue
+		}
+		if filters.Until != nil && !session.StartedAt.Before(*filters.Until) {
+			continue
+		}
+		session.Live = session.EndedAt == nil
+		out = append(out, session)
+	}
+	if filters.Order != httpapi.OrderAsc {
+		for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+			out[i], out[j] = out[j], out[i]
+		}
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	if len(out) > limit {
+		out = out[:limit]
+	}
+
+	for i := range out {
+		end := time.Now().UTC()
+		if out[i].EndedAt != nil {
+			end = *out[i].EndedAt
+		}
+		if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages WHERE platform = ? AND ts >= ? AND ts < ?;`,
+			out[i].Platform, out[i].StartedAt.UnixMilli(), end.UnixMilli()).Scan(&out[i].Messages); err != nil {
+			return nil, errors.Wrap(err, "count stream messages")
+		}
+	}
+	return out, nil
+}
+
+func buildStreamWhere(filters httpapi.Filters) (string, []any) {
+	if len(filters.Platforms) == 0 {
+		return "", nil
+	}
+	placeholders := make([]string, 0, len(filters.Platforms))
+	args := make([]any, 0, len(filters.Platforms))
+	for _, p := range filters.Platforms {
+		placeholders = append(placeholders, "?")
+		args = append(args, p)
+	}
+	return " AND platform IN (" + strings.Join(placeholders, ",") + ")", args
+}
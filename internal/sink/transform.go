@@ -0,0 +1,68 @@
+package sink
+
+import (
+	"log"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+)
+
+// Transformer inspects a message before it reaches the sinks. It returns the
+// message to store, which may be modified, and keep=false to drop it.
+type Transformer interface {
+	Transform(msg core.ChatMessage) (out core.ChatMessage, keep bool, err error)
+}
+
+// TransformFunc adapts a function to Transformer.
+type TransformFunc func(core.ChatMessage) (core.ChatMessage, bool, error)
+
+func (f TransformFunc) Transform(msg core.ChatMessage) (core.ChatMessage, bool, error) {
+	return f(msg)
+}
+
+// TransformWriter runs every message through its transformers in order and
+// writes the result to base. A transformer that fails is logged and skipped,
+// so a broken hook never stops ingest; a dropped message is not written and
+// is not an error.
+type TransformWriter struct {
+	base         Writer
+	transformers []Transformer
+}
+
+func NewTransformWriter(base Writer, transformers ...Transformer) *TransformWriter {
+	return &TransformWriter{base: base, transformers: transformers}
+}
+
+func (t *TransformWriter) Write(msg core.ChatMessage, trace *ingesttrace.MessageTrace) error {
+	for i, tr := range t.transformers {
+		out, keep, err := tr.Transform(msg)
+		if err != nil {
+			log.Printf("sink: transform %d: message %s passed through unchanged: %v", i, msg.ID, err)
+			continue
+		}
+		if !keep {
+			return nil
+		}
+		msg = out
+	}
+	return t.base.Write(msg, trace)
+}
+
+// ULIDTransformer gives every message its ULID (see core.AssignULID) before
+// it reaches the sinks, so all of them store the same key for it.
+func ULIDTransformer() Transformer {
+	return TransformFunc(func(msg core.ChatMessage) (core.ChatMessage, bool, error) {
+		core.AssignULID(&msg)
+		return msg, true, nil
+	})
+}
+
+// ColourTransformer normalizes every message's colour to "#RRGGBB" and, when
+// assignDefaults is set, gives chatters without one a stable palette colour
+// (see core.DefaultColour) so overlays render every name consistently.
+func ColourTransformer(assignDefaults bool) Transformer {
+	return TransformFunc(func(msg core.ChatMessage) (core.ChatMessage, bool, error) {
+		core.NormalizeMessageColour(&msg, assignDefaults)
+		return msg, true, nil
+	})
+}
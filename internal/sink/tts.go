@@ -0,0 +1,166 @@
+package sink
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+// ttsPlayedRetention is how long acknowledged items are kept before AckTTS
+// prunes them.
+const ttsPlayedRetention = 24 * time.Hour
+
+// tts_queue holds messages selected by tts trigger actions until a player
+// acknowledges them. A message is queued at most once per rule.
+const ttsSchema = `CREATE TABLE IF NOT EXISTS tts_queue (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  platform TEXT NOT NULL,
+  channel TEXT NOT NULL DEFAULT '',
+  rule TEXT NOT NULL DEFAULT '',
+  message_id TEXT NOT NULL DEFAULT '',
+  user_id TEXT NOT NULL DEFAULT '',
+  username TEXT NOT NULL DEFAULT '',
+  text TEXT NOT NULL,
+  ts INTEGER NOT NULL,
+  queued_at INTEGER NOT NULL,
+  played_at INTEGER NOT NULL DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS tts_queue_pending ON tts_queue(played_at, id);
+CREATE UNIQUE INDEX IF NOT EXISTS tts_queue_message ON tts_queue(platform, message_id, rule) WHERE message_id != '';`
+
+// EnqueueTTS adds item to the TTS queue and returns its id, or 0 when the
+// rule already queued the same message.
+func (s *SQLiteSink) EnqueueTTS(ctx context.Context, item core.TTSItem) (int64, error) {
+	platform := strings.TrimSpace(item.Platform)
+	if platform == "" {
+		return 0, errors.New("tts item requires platform")
+	}
+	text := strings.TrimSpace(item.Text)
+	if text == "" {
+		return 0, errors.New("tts item requires text")
+	}
+	now := time.Now().UTC()
+	ts := item.Ts
+	if ts.IsZero() {
+		ts = now
+	}
+	var id int64
+	err := withRetry(func() error {
+		res, err := s.db.ExecContext(ctx, `INSERT OR IGNORE INTO tts_queue (platform, channel, rule, message_id, user_id, username, text, ts, queued_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?);`, platform, item.Channel, item.Rule, item.MessageID, item.UserID, item.Username, text,
+			ts.UTC().UnixMilli(), now.UnixMilli())
+		if err != nil {
+			return err
+		}
+		if n, err := res.RowsAffected(); err != nil || n == 0 {
+			id = 0
+			return err
+		}
+		id, err = res.LastInsertId()
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "enqueue tts")
+	}
+	return id, nil
+}
+
+// ListTTS returns unacknowledged items oldest first, limited to the
+// platforms and channels in filters.
+func (s *SQLiteSink) ListTTS(ctx context.Context, filters httpapi.Filters) ([]httpapi.TTSItem, error) {
+	where, args := ttsScope(filters)
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, `SELECT id, platform, channel, rule, message_id, username, text, ts, queued_at
+FROM tts_queue WHERE played_at = 0`+where+` ORDER BY id LIMIT ?;`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list tts queue")
+	}
+	defer rows.Close()
+
+	var out []httpapi.TTSItem
+	for rows.Next() {
+		var (
+			item           httpapi.TTSItem
+			tsMS, queuedMS int64
+		)
+		if err := rows.Scan(&item.ID, &item.Platform, &item.Channel, &item.Rule, &item.MessageID, &item.Username, &item.Text,
+			&tsMS, &queuedMS); err != nil {
+			return nil, errors.Wrap(err, "scan tts item")
+		}
+		item.Ts = time.UnixMilli(tsMS).UTC()
+		item.QueuedAt = time.UnixMilli(queuedMS).UTC()
+		out = append(out, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate tts queue")
+	}
+	return out, nil
+}
+
+// AckTTS marks the pending items in ids as played, within the platforms and
+// channels in filters, and returns how many it marked. Items played more
+// than a day ago are pruned.
+func (s *SQLiteSink) AckTTS(ctx context.Context, ids []int64, filters httpapi.Filters) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	where, scopeArgs := ttsScope(filters)
+	now := time.Now().UTC()
+	args := []any{now.UnixMilli()}
+	placeholders := make([]string, 0, len(ids))
+	for _, id := range ids {
+		placeholders = append(placeholders, "?")
+		args = append(args, id)
+	}
+	args = append(args, scopeArgs...)
+	var acked int64
+	err := withRetry(func() error {
+		res, err := s.db.ExecContext(ctx, `UPDATE tts_queue SET played_at = ?
+WHERE played_at = 0 AND id IN (`+strings.Join(placeholders, ",")+`)`+where+`;`, args...)
+		if err != nil {
+			return err
+		}
+		if acked, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		_, err = s.db.ExecContext(ctx, `DELETE FROM tts_queue WHERE played_at > 0 AND played_at < ?;`,
+			now.Add(-ttsPlayedRetention).UnixMilli())
+		return err
+	})
+	if err != nil {
+		return 0, errors.Wrap(err, "ack tts")
+	}
+	return acked, nil
+}
+
+// ttsScope returns " AND ..." conditions for the platforms and channels in
+// filters.
+func ttsScope(filters httpapi.Filters) (string, []any) {
+	var (
+		where string
+		args  []any
+	)
+	in := func(column string, values []string) {
+		if len(values) == 0 {
+			return
+		}
+		placeholders := make([]string, 0, len(values))
+		for _, v := range values {
+			placeholders = append(placeholders, "?")
+			args = append(args, v)
+		}
+		where += " AND " + column + " IN (" + strings.Join(placeholders, ",") + ")"
+	}
+	in("platform", filters.Platforms)
+	in("channel", filters.Channels)
+	return where, args
+}
@@ -0,0 +1,84 @@
+package sink
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const usernameBackfillBatch = 5000
+
+// BackfillUsernameNorm fills username_norm for rows written before the
+// column existed. It works in batches so it can run alongside ingest and
+// returns the number of rows updated.
+func (s *SQLiteSink) BackfillUsernameNorm(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		n, err := s.backfillUsernameBatch(ctx)
+		total += n
+		if err != nil {
+			return total, err
+		}
+		if n < usernameBackfillBatch {
+			return total, nil
+		}
+	}
+}
+
+func (s *SQLiteSink) backfillUsernameBatch(ctx context.Context) (int64, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, platform, username FROM messages
+WHERE username_norm = '' AND username != '' LIMIT ?;`, usernameBackfillBatch)
+	if err != nil {
+		return 0, errors.Wrap(err, "select username backfill")
+	}
+	type pending struct {
+		id   int64
+		norm string
+	}
+	var batch []pending
+	for rows.Next() {
+		var (
+			id                 int64
+			platform, username string
+		)
+		if err := rows.Scan(&id, &platform, &username); err != nil {
+			rows.Close()
+			return 0, errors.Wrap(err, "scan username backfill")
+		}
+		norm := s.usernames.Normalize(platform, username)
+		if strings.TrimSpace(norm) == "" {
+			// Keep the row out of the next batch.
+			norm = strings.ToLower(username)
+		}
+		batch = append(batch, pending{id: id, norm: norm})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, errors.Wrap(err, "iterate username backfill")
+	}
+	if len(batch) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "begin username backfill")
+	}
+	stmt, err := tx.PrepareContext(ctx, `UPDATE messages SET username_norm = ? WHERE id = ?;`)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, errors.Wrap(err, "prepare username backfill")
+	}
+	defer stmt.Close()
+	for _, p := range batch {
+		if _, err := stmt.ExecContext(ctx, p.norm, p.id); err != nil {
+			_ = tx.Rollback()
+			return 0, errors.Wrap(err, "update username backfill")
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit username backfill")
+	}
+	return int64(len(batch)), nil
+}
@@ -0,0 +1,286 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const usersSchema = `CREATE TABLE IF NOT EXISTS users (
+  platform TEXT NOT NULL,
+  user_key TEXT NOT NULL,
+  username TEXT NOT NULL,
+  username_norm TEXT NOT NULL DEFAULT '',
+  author_channel_id TEXT NOT NULL DEFAULT '',
+  avatar_url TEXT NOT NULL DEFAULT '',
+  first_seen INTEGER NOT NULL,
+  last_seen INTEGER NOT NULL,
+  PRIMARY KEY (platform, user_key)
+);
+CREATE INDEX IF NOT EXISTS users_username_norm ON users(username_norm);`
+
+// userColumns lists columns added to users after the original schema.
+var userColumns = []addedColumn{
+	{"broadcaster_type", `ALTER TABLE users ADD COLUMN broadcaster_type TEXT NOT NULL DEFAULT '';`},
+	{"account_created_at", `ALTER TABLE users ADD COLUMN account_created_at INTEGER NOT NULL DEFAULT 0;`},
+	{"profile_checked_at", `ALTER TABLE users ADD COLUMN profile_checked_at INTEGER NOT NULL DEFAULT 0;`},
+}
+
+const userSelect = `SELECT platform, user_key, username, author_channel_id, avatar_url,
+broadcaster_type, account_created_at, first_seen, last_seen FROM users`
+
+// UserProfile carries platform profile data fetched for a user.
+type UserProfile struct {
+	Key              string
+	UserID           string
+	AvatarURL        string
+	BroadcasterType  string
+	AccountCreatedAt time.Time
+}
+
+// upsertUser records the latest display name and profile metadata for a
+// chatter. Users are keyed by author channel ID when the platform reports one
+// (YouTube display names are not unique) and by normalized username otherwise.
+func upsertUser(db execer, platform, username, usernameNorm, authorChannelID, avatarURL string, tsMS int64) error {
+	key := authorChannelID
+	if key == "" {
+		key = usernameNorm
+	}
+	if key == "" {
+		return nil
+	}
+	_, err := db.Exec(`INSERT INTO users (
+platform, user_key, username, username_norm, author_channel_id, avatar_url, first_seen, last_seen
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT(platform, user_key) DO UPDATE SET
+  username = CASE WHEN excluded.last_seen >= users.last_seen THEN excluded.username ELSE users.username END,
+  username_norm = CASE WHEN excluded.last_seen >= users.last_seen THEN excluded.username_norm ELSE users.username_norm END,
+  author_channel_id = CASE WHEN excluded.author_channel_id != '' THEN excluded.author_channel_id ELSE users.author_channel_id END,
+  avatar_url = CASE WHEN excluded.avatar_url != '' THEN excluded.avatar_url ELSE users.avatar_url END,
+  first_seen = MIN(users.first_seen, excluded.first_seen),
+  last_seen = MAX(users.last_seen, excluded.last_seen);`,
+		platform, key, username, usernameNorm, authorChannelID, avatarURL, tsMS, tsMS)
+	return err
+}
+
+// ListUsers returns users matching filters, most recently seen first.
+func (s *SQLiteSink) ListUsers(ctx context.Context, filters httpapi.Filters) ([]httpapi.User, error) {
+	where, args := buildFilterWhere(filters, "last_seen")
+	order := "DESC"
+	if filters.Order == httpapi.OrderAsc {
+		order = "ASC"
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, userSelect+where+" ORDER BY last_seen "+order+" LIMIT ?;", args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list users")
+	}
+	defer rows.Close()
+
+	var out []httpapi.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate users")
+	}
+	return out, nil
+}
+
+// GetUser looks up a user by key (channel ID or normalized username), falling
+// back to the most recently seen user whose normalized name matches.
+func (s *SQLiteSink) GetUser(ctx context.Context, platform, key string) (httpapi.User, bool, error) {
+	norm := s.usernames.Normalize(platform, key)
+	row := s.db.QueryRowContext(ctx, userSelect+` WHERE platform = ? AND (user_key = ? OR username_norm = ?)
+ORDER BY user_key = ? DESC, last_seen DESC LIMIT 1;`, platform, key, norm, key)
+	u, err := scanUser(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return httpapi.User{}, false, nil
+	}
+	if err != nil {
+		return httpapi.User{}, false, err
+	}
+	return u, true, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (httpapi.User, error) {
+	var (
+		u                        httpapi.User
+		createdMS, first, lastMS int64
+	)
+	err := row.Scan(&u.Platform, &u.Key, &u.Username, &u.AuthorChannelID, &u.AvatarURL,
+		&u.BroadcasterType, &createdMS, &first, &lastMS)
+	if errors.Is(err, sql.ErrNoRows) {
+		return httpapi.User{}, err
+	}
+	if err != nil {
+		return httpapi.User{}, errors.Wrap(err, "scan user")
+	}
+	if createdMS > 0 {
+		t := time.UnixMilli(createdMS).UTC()
+		u.AccountCreatedAt = &t
+	}
+	u.FirstSeen = time.UnixMilli(first).UTC()
+	u.LastSeen = time.UnixMilli(lastMS).UTC()
+	return u, nil
+}
+
+// PendingProfiles returns up to limit user keys on platform whose profile has
+// never been fetched or was last checked before staleBefore, most recently
+// seen first.
+func (s *SQLiteSink) PendingProfiles(ctx context.Context, platform string, staleBefore time.Time, limit int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT user_key FROM users
+WHERE platform = ? AND profile_checked_at < ?
+ORDER BY last_seen DESC LIMIT ?;`, platform, staleBefore.UTC().UnixMilli(), limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "select pending profiles")
+	}
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, errors.Wrap(err, "scan pending profile")
+		}
+		out = append(out, key)
+	}
+	return out, errors.Wrap(rows.Err(), "iterate pending profiles")
+}
+
+// UpdateProfiles stores fetched profiles and marks every key in checked as
+// checked at now, so users without a profile are not retried until stale.
+func (s *SQLiteSink) UpdateProfiles(ctx context.Context, platform string, checked []string, profiles []UserProfile, now time.Time) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "begin profile update")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	nowMS := now.UTC().UnixMilli()
+	for _, key := range checked {
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET profile_checked_at = ? WHERE platform = ? AND user_key = ?;`,
+			nowMS, platform, key); err != nil {
+			return errors.Wrap(err, "mark profile checked")
+		}
+	}
+	for _, p := range profiles {
+		var createdMS int64
+		if !p.AccountCreatedAt.IsZero() {
+			createdMS = p.AccountCreatedAt.UTC().UnixMilli()
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET
+  avatar_url = CASE WHEN ? != '' THEN ? ELSE avatar_url END,
+  author_channel_id = CASE WHEN author_channel_id = '' THEN ? ELSE author_channel_id END,
+  broadcaster_type = ?,
+  account_created_at = ?,
+  profile_checked_at = ?
+WHERE platform = ? AND user_key = ?;`,
+			p.AvatarURL, p.AvatarURL, strings.TrimSpace(p.UserID), p.BroadcasterType, createdMS, nowMS, platform, p.Key); err != nil {
+			return errors.Wrap(err, "update profile")
+		}
+	}
+	return errors.Wrap(tx.Commit(), "commit profile update")
+}
+
+// ListUserMessages pages through the messages sent by the user stored under
+// key, honouring the time, session and order filters. cursor continues a
+// previous page; the returned cursor is empty once no rows remain. Rows are
+// ordered by timestamp and then ULID, so a cursor stays valid against a
+// copy of the database.
+func (s *SQLiteSink) ListUserMessages(ctx context.Context, platform, key string, filters httpapi.Filters, cursor string) ([]core.ChatMessage, string, error) {
+	filters.Platforms = []string{platform}
+	filters.Usernames = nil
+	where, args := buildMessageWhere(filters)
+	// Users are keyed by author channel ID when messages carry one and by
+	// normalized username otherwise (see upsertUser).
+	where += " AND (author_channel_id = ? OR (author_channel_id = '' AND username_norm = ?))"
+	args = append(args, key, key)
+
+	desc := filters.Order != httpapi.OrderAsc
+	if cursor != "" {
+		tsMS, ulid, rowID, err := parseMessageCursor(cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		if ulid != "" {
+			where += fmt.Sprintf(" AND (ts %s ? OR (ts = ? AND ulid %s ?))", op, op)
+			args = append(args, tsMS, tsMS, ulid)
+		} else {
+			// Legacy cursors name the last row seen; continue after its
+			// position in the ts, ulid, id order.
+			err := s.db.QueryRowContext(ctx, `SELECT ulid FROM messages WHERE id = ?;`, rowID).Scan(&ulid)
+			if err != nil && err != sql.ErrNoRows {
+				return nil, "", errors.Wrap(err, "resolve message cursor")
+			}
+			where += fmt.Sprintf(" AND (ts %s ? OR (ts = ? AND (ulid %s ? OR (ulid = ? AND id %s ?))))", op, op, op)
+			args = append(args, tsMS, tsMS, ulid, ulid, rowID)
+		}
+	}
+	order := "ASC"
+	if desc {
+		order = "DESC"
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, messageSelect+where+" ORDER BY ts "+order+", ulid "+order+", id "+order+" LIMIT ?;", args...)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "list user messages")
+	}
+	defer rows.Close()
+	out, rowIDs, err := scanMessageRows(rows)
+	if err != nil || len(out) < limit {
+		return out, "", err
+	}
+	last := out[len(out)-1]
+	if last.ULID == "" {
+		return out, fmt.Sprintf("%d.%d", last.TimestampMS, rowIDs[len(out)-1]), nil
+	}
+	return out, fmt.Sprintf("%d.%s", last.TimestampMS, last.ULID), nil
+}
+
+// parseMessageCursor splits a "ts.ulid" cursor. Cursors handed out before
+// rows carried ULIDs end in the row id instead, which is returned with an
+// empty ulid.
+func parseMessageCursor(cursor string) (tsMS int64, ulid string, rowID int64, err error) {
+	rawTs, rest, ok := strings.Cut(cursor, ".")
+	tsMS, err = strconv.ParseInt(rawTs, 10, 64)
+	if !ok || err != nil {
+		return 0, "", 0, httpapi.ErrInvalidCursor
+	}
+	if _, isULID := core.ULIDTime(rest); isULID {
+		return tsMS, strings.ToUpper(rest), 0, nil
+	}
+	rowID, err = strconv.ParseInt(rest, 10, 64)
+	if err != nil {
+		return 0, "", 0, httpapi.ErrInvalidCursor
+	}
+	return tsMS, "", rowID, nil
+}
@@ -0,0 +1,104 @@
+package sink
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+const viewerSamplesSchema = `CREATE TABLE IF NOT EXISTS viewer_samples (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  platform TEXT NOT NULL,
+  channel TEXT NOT NULL DEFAULT '',
+  viewers INTEGER NOT NULL,
+  ts INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS viewer_samples_platform_ts ON viewer_samples(platform, channel, ts);`
+
+// RecordViewerSample appends sample to viewer_samples.
+func (s *SQLiteSink) RecordViewerSample(ctx context.Context, sample core.ViewerSample) error {
+	platform := strings.TrimSpace(sample.Platform)
+	if platform == "" {
+		return errors.New("viewer sample requires platform")
+	}
+	if sample.Viewers < 0 {
+		return errors.New("viewer sample requires a non-negative count")
+	}
+	ts := sample.Ts
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	err := withRetry(func() error {
+		_, err := s.db.ExecContext(ctx, `INSERT INTO viewer_samples (platform, channel, viewers, ts) VALUES (?, ?, ?, ?);`,
+			platform, sample.Channel, sample.Viewers, ts.UTC().UnixMilli())
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "insert viewer sample")
+	}
+	return nil
+}
+
+// ListViewerSamples returns stored samples matching filters (platforms and a
+// since/until bound on the sample time), each with the number of messages
+// sent on its platform since the previous sample of the same source.
+func (s *SQLiteSink) ListViewerSamples(ctx context.Context, filters httpapi.Filters) ([]httpapi.ViewerSample, error) {
+	where, args := buildFilterWhere(httpapi.Filters{Platforms: filters.Platforms, Since: filters.Since, Until: filters.Until}, "ts")
+	order := "DESC"
+	if filters.Order == httpapi.OrderAsc {
+		order = "ASC"
+	}
+	limit := filters.Limit
+	if limit <= 0 {
+		limit = defaultListLimit
+	}
+	args = append(args, limit)
+	rows, err := s.db.QueryContext(ctx, `SELECT platform, channel, viewers, ts FROM viewer_samples`+where+
+		` ORDER BY ts `+order+`, id `+order+` LIMIT ?;`, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "list viewer samples")
+	}
+	defer rows.Close()
+
+	var out []httpapi.ViewerSample
+	for rows.Next() {
+		var (
+			sample httpapi.ViewerSample
+			tsMS   int64
+		)
+		if err := rows.Scan(&sample.Platform, &sample.Channel, &sample.Viewers, &tsMS); err != nil {
+			return nil, errors.Wrap(err, "scan viewer sample")
+		}
+		sample.Ts = time.UnixMilli(tsMS).UTC()
+		out = append(out, sample)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "iterate viewer samples")
+	}
+	rows.Close()
+
+	for i := range out {
+		sample := &out[i]
+		var prevMS int64
+		err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(ts), 0) FROM viewer_samples
+WHERE platform = ? AND channel = ? AND ts < ?;`, sample.Platform, sample.Channel, sample.Ts.UnixMilli()).Scan(&prevMS)
+		if err != nil {
+			return nil, errors.Wrap(err, "previous viewer sample")
+		}
+		if prevMS == 0 {
+			continue
+		}
+		prev := time.UnixMilli(prevMS).UTC()
+		sample.WindowStart = &prev
+		if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM messages WHERE platform = ? AND ts >= ? AND ts < ?;`,
+			sample.Platform, prevMS, sample.Ts.UnixMilli()).Scan(&sample.Messages); err != nil {
+			return nil, errors.Wrap(err, "count viewer sample messages")
+		}
+	}
+	return out, nil
+}
@@ -19,6 +19,9 @@ func WithAPI(base *SQLiteSink, api broadcaster) *WithBroadcast {
 }
 
 func (w *WithBroadcast) Write(msg core.ChatMessage, trace *ingesttrace.MessageTrace) error {
+	if msg.SessionID == "" {
+		msg.SessionID = w.SQLiteSink.activeSession(msg.Platform)
+	}
 	if err := w.SQLiteSink.Write(msg, trace); err != nil {
 		return err
 	}
@@ -27,3 +30,79 @@ func (w *WithBroadcast) Write(msg core.ChatMessage, trace *ingesttrace.MessageTr
 	}
 	return nil
 }
+
+// WriteBatch stores msgs in one transaction and broadcasts them once
+// committed. It overrides the promoted SQLiteSink method so batched writes
+// still reach stream clients.
+func (w *WithBroadcast) WriteBatch(msgs []core.ChatMessage, traces []*ingesttrace.MessageTrace) error {
+	for i := range msgs {
+		if msgs[i].SessionID == "" {
+			msgs[i].SessionID = w.SQLiteSink.activeSession(msgs[i].Platform)
+		}
+	}
+	if err := w.SQLiteSink.WriteBatch(msgs, traces); err != nil {
+		return err
+	}
+	if w.api != nil {
+		for _, msg := range msgs {
+			w.api.Broadcast(msg)
+		}
+	}
+	return nil
+}
+
+// Fanout forwards each broadcast to every listener in order.
+type Fanout []broadcaster
+
+func (f Fanout) Broadcast(msg core.ChatMessage) {
+	for _, b := range f {
+		b.Broadcast(msg)
+	}
+}
+
+// BroadcastWriter writes to base and then broadcasts each stored message.
+// It serves stores without broadcast sessions, such as ChannelSQLite; the
+// single-file SQLite sink uses WithAPI.
+type BroadcastWriter struct {
+	base Writer
+	api  broadcaster
+}
+
+func NewBroadcastWriter(base Writer, api broadcaster) *BroadcastWriter {
+	return &BroadcastWriter{base: base, api: api}
+}
+
+func (w *BroadcastWriter) Write(msg core.ChatMessage, trace *ingesttrace.MessageTrace) error {
+	if err := w.base.Write(msg, trace); err != nil {
+		return err
+	}
+	if w.api != nil {
+		w.api.Broadcast(msg)
+	}
+	return nil
+}
+
+// WriteBatch uses base's batch write when it has one.
+func (w *BroadcastWriter) WriteBatch(msgs []core.ChatMessage, traces []*ingesttrace.MessageTrace) error {
+	if bw, ok := w.base.(BatchWriter); ok {
+		if err := bw.WriteBatch(msgs, traces); err != nil {
+			return err
+		}
+		if w.api != nil {
+			for _, msg := range msgs {
+				w.api.Broadcast(msg)
+			}
+		}
+		return nil
+	}
+	for i, msg := range msgs {
+		var trace *ingesttrace.MessageTrace
+		if i < len(traces) {
+			trace = traces[i]
+		}
+		if err := w.Write(msg, trace); err != nil {
+			return err
+		}
+	}
+	return nil
+}
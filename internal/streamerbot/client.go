@@ -0,0 +1,188 @@
+// Package streamerbot is a client for Streamer.bot's WebSocket server: the
+// Hello/Authenticate handshake and DoAction requests.
+package streamerbot
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"nhooyr.io/websocket"
+)
+
+// DefaultURL is Streamer.bot's default WebSocket server address.
+const DefaultURL = "ws://127.0.0.1:8080/"
+
+// ErrClosed is returned when sending on a closed or failed connection.
+var ErrClosed = errors.New("streamerbot: connection closed")
+
+// Options describes how to reach Streamer.bot.
+type Options struct {
+	URL string
+	// Password is required when the server has authentication enabled.
+	Password string
+}
+
+// Client is a single Streamer.bot connection. It is safe for concurrent
+// use.
+type Client struct {
+	conn *websocket.Conn
+	ids  atomic.Uint64
+
+	writeMu sync.Mutex
+
+	mu   sync.Mutex
+	err  error
+	done chan struct{}
+}
+
+type hello struct {
+	Request        string `json:"request"`
+	Authentication *struct {
+		Challenge string `json:"challenge"`
+		Salt      string `json:"salt"`
+	} `json:"authentication"`
+}
+
+type response struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+// Dial connects and, when the server asks for it, authenticates.
+func Dial(ctx context.Context, opts Options) (*Client, error) {
+	url := opts.URL
+	if url == "" {
+		url = DefaultURL
+	}
+	conn, _, err := websocket.Dial(ctx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("streamerbot: dial: %w", err)
+	}
+	conn.SetReadLimit(1 << 20)
+
+	var h hello
+	if err := readJSON(ctx, conn, &h); err != nil {
+		conn.Close(websocket.StatusProtocolError, "")
+		return nil, fmt.Errorf("streamerbot: read hello: %w", err)
+	}
+	if h.Authentication != nil {
+		if opts.Password == "" {
+			conn.Close(websocket.StatusNormalClosure, "")
+			return nil, errors.New("streamerbot: server requires a password")
+		}
+		auth := authenticationString(opts.Password, h.Authentication.Salt, h.Authentication.Challenge)
+		if err := writeJSON(ctx, conn, map[string]string{"request": "Authenticate", "id": "auth", "authentication": auth}); err != nil {
+			conn.Close(websocket.StatusInternalError, "")
+			return nil, fmt.Errorf("streamerbot: authenticate: %w", err)
+		}
+		var resp response
+		if err := readJSON(ctx, conn, &resp); err != nil {
+			conn.Close(websocket.StatusProtocolError, "")
+			return nil, fmt.Errorf("streamerbot: authenticate: %w", err)
+		}
+		if resp.Status != "ok" {
+			conn.Close(websocket.StatusNormalClosure, "")
+			return nil, fmt.Errorf("streamerbot: authentication failed: %s", resp.Error)
+		}
+	}
+
+	c := &Client{conn: conn, done: make(chan struct{})}
+	go c.readLoop()
+	return c, nil
+}
+
+// authenticationString computes the handshake response:
+// base64(sha256(base64(sha256(password + salt)) + challenge)).
+func authenticationString(password, salt, challenge string) string {
+	secret := sha256.Sum256([]byte(password + salt))
+	auth := sha256.Sum256([]byte(base64.StdEncoding.EncodeToString(secret[:]) + challenge))
+	return base64.StdEncoding.EncodeToString(auth[:])
+}
+
+// DoAction runs the action named action with args. It does not wait for
+// Streamer.bot to reply; failures it reports (e.g. an unknown action) are
+// logged.
+func (c *Client) DoAction(ctx context.Context, action string, args map[string]any) error {
+	if err := c.Err(); err != nil {
+		return err
+	}
+	req := map[string]any{
+		"request": "DoAction",
+		"id":      strconv.FormatUint(c.ids.Add(1), 10),
+		"action":  map[string]string{"name": action},
+		"args":    args,
+	}
+	c.writeMu.Lock()
+	err := writeJSON(ctx, c.conn, req)
+	c.writeMu.Unlock()
+	if err != nil {
+		c.fail(err)
+		return err
+	}
+	return nil
+}
+
+// Err reports why the connection failed, or nil while it is healthy.
+func (c *Client) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Done is closed once the connection has failed or been closed.
+func (c *Client) Done() <-chan struct{} { return c.done }
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	c.fail(ErrClosed)
+	return c.conn.Close(websocket.StatusNormalClosure, "")
+}
+
+func (c *Client) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.err = err
+	close(c.done)
+}
+
+func (c *Client) readLoop() {
+	for {
+		_, data, err := c.conn.Read(context.Background())
+		if err != nil {
+			c.fail(fmt.Errorf("streamerbot: read: %w", err))
+			return
+		}
+		var resp response
+		if json.Unmarshal(data, &resp) == nil && resp.Status == "error" {
+			log.Printf("streamerbot: request %s failed: %s", resp.ID, resp.Error)
+		}
+	}
+}
+
+func readJSON(ctx context.Context, conn *websocket.Conn, v any) error {
+	_, data, err := conn.Read(ctx)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJSON(ctx context.Context, conn *websocket.Conn, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return conn.Write(ctx, websocket.MessageText, data)
+}
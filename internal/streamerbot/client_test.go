@@ -0,0 +1,79 @@
+package streamerbot
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+)
+
+// fakeServer speaks the Streamer.bot handshake with password "secret" and
+// forwards each DoAction request to got.
+func fakeServer(got chan<- map[string]any) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close(websocket.StatusNormalClosure, "")
+		ctx := r.Context()
+		_ = conn.Write(ctx, websocket.MessageText, []byte(`{"request":"Hello","info":{"name":"Streamer.bot"},
+			"authentication":{"challenge":"chal","salt":"salt"}}`))
+		var auth map[string]string
+		if err := readJSON(ctx, conn, &auth); err != nil {
+			return
+		}
+		if auth["request"] != "Authenticate" || auth["authentication"] != authenticationString("secret", "salt", "chal") {
+			_ = conn.Write(ctx, websocket.MessageText, []byte(`{"id":"auth","status":"error","error":"Authentication failed"}`))
+			return
+		}
+		_ = conn.Write(ctx, websocket.MessageText, []byte(`{"id":"auth","status":"ok"}`))
+		for {
+			var req map[string]any
+			if err := readJSON(ctx, conn, &req); err != nil {
+				return
+			}
+			got <- req
+			_ = conn.Write(ctx, websocket.MessageText, []byte(`{"id":"`+req["id"].(string)+`","status":"ok"}`))
+		}
+	}))
+}
+
+func TestDialAuthenticatesAndRunsActions(t *testing.T) {
+	got := make(chan map[string]any, 1)
+	srv := fakeServer(got)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, Options{URL: url, Password: "secret"})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	if err := c.DoAction(ctx, "gnasty chat", map[string]any{"user": "elora", "message": "hi"}); err != nil {
+		t.Fatalf("do action: %v", err)
+	}
+	select {
+	case req := <-got:
+		data, _ := json.Marshal(req)
+		if req["request"] != "DoAction" || req["action"].(map[string]any)["name"] != "gnasty chat" || req["args"].(map[string]any)["message"] != "hi" {
+			t.Fatalf("unexpected request %s", data)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for the action")
+	}
+
+	if _, err := Dial(ctx, Options{URL: url, Password: "wrong"}); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Fatalf("expected an authentication error, got %v", err)
+	}
+	if _, err := Dial(ctx, Options{URL: url}); err == nil || !strings.Contains(err.Error(), "requires a password") {
+		t.Fatalf("expected a missing password error, got %v", err)
+	}
+}
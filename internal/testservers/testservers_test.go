@@ -0,0 +1,125 @@
+package testservers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/backoff"
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+	"github.com/you/gnasty-chat/internal/twitchirc"
+	"github.com/you/gnasty-chat/internal/ytlive"
+)
+
+var fastRetry = backoff.Policy{Initial: 10 * time.Millisecond, Max: 50 * time.Millisecond, Multiplier: 2}
+
+// collector gathers delivered messages for assertions.
+type collector struct {
+	mu   sync.Mutex
+	msgs []core.ChatMessage
+}
+
+func (c *collector) add(msg core.ChatMessage) {
+	c.mu.Lock()
+	c.msgs = append(c.msgs, msg)
+	c.mu.Unlock()
+}
+
+func (c *collector) waitFor(t *testing.T, n int) []core.ChatMessage {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		c.mu.Lock()
+		got := append([]core.ChatMessage(nil), c.msgs...)
+		c.mu.Unlock()
+		if len(got) >= n {
+			return got
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d messages, got %d", n, len(got))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTwitchIRCClientReconnects(t *testing.T) {
+	irc, err := NewTwitchIRC()
+	if err != nil {
+		t.Fatalf("start irc: %v", err)
+	}
+	defer irc.Close()
+
+	var got collector
+	client := twitchirc.New(twitchirc.Config{
+		Channel: "elora",
+		Nick:    "bot",
+		Token:   "oauth:fake",
+		Dial:    irc.Dial,
+		Backoff: fastRetry,
+	}, func(msg core.ChatMessage, _ *ingesttrace.MessageTrace) { got.add(msg) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- client.Run(ctx) }()
+
+	if err := irc.WaitJoin(ctx, "elora"); err != nil {
+		t.Fatalf("join: %v", err)
+	}
+	irc.Privmsg("elora", TwitchMessage{ID: "m1", User: "alice", UserID: "10", Text: "hello", Color: "#FF0000", Badges: "moderator/1"})
+	msgs := got.waitFor(t, 1)
+	if msgs[0].ID != "m1" || msgs[0].Username != "alice" || msgs[0].Text != "hello" || msgs[0].Channel != "elora" {
+		t.Fatalf("unexpected message %+v", msgs[0])
+	}
+
+	irc.DropClients()
+	if err := irc.WaitConnections(ctx, 2); err != nil {
+		t.Fatalf("reconnect: %v", err)
+	}
+	if err := irc.WaitJoin(ctx, "elora"); err != nil {
+		t.Fatalf("rejoin: %v", err)
+	}
+	irc.Privmsg("elora", TwitchMessage{ID: "m2", User: "bob", Text: "back again"})
+	if msgs := got.waitFor(t, 2); msgs[1].ID != "m2" {
+		t.Fatalf("unexpected message after reconnect %+v", msgs[1])
+	}
+
+	cancel()
+	<-done
+}
+
+func TestYouTubeClientPollsScriptedBatches(t *testing.T) {
+	yt := NewYouTube("fakevideo01")
+	defer yt.Close()
+	yt.SetPollInterval(10 * time.Millisecond)
+	yt.Push(YouTubeMessage{ID: "y1", Author: "Carol", Text: "first"})
+	yt.FailPolls(http.StatusServiceUnavailable, 1)
+	yt.Push(YouTubeMessage{ID: "y2", Author: "Dave", Text: "second"}, YouTubeMessage{ID: "y3", Author: "Erin", Text: "third"})
+
+	res, err := ytlive.NewResolver(&http.Client{Transport: yt.Transport()}).Resolve(context.Background(), yt.WatchURL())
+	if err != nil || !res.Live || res.WatchURL != yt.WatchURL() || res.Title != "Fake stream" {
+		t.Fatalf("unexpected resolve %+v (%v)", res, err)
+	}
+
+	var got collector
+	client := ytlive.New(ytlive.Config{
+		LiveURL:        yt.WatchURL(),
+		PollIntervalMS: 10,
+		Backoff:        fastRetry,
+		Transport:      yt.Transport(),
+	}, got.add)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- client.Run(ctx) }()
+
+	msgs := got.waitFor(t, 3)
+	if msgs[0].ID != "y1" || msgs[2].ID != "y3" || msgs[1].Username != "Dave" || msgs[1].Text != "second" {
+		t.Fatalf("unexpected messages %+v", msgs)
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,258 @@
+// Package testservers provides in-process fakes of the chat platforms for
+// end-to-end tests: a Twitch IRC server and a YouTube watch page plus
+// Innertube live chat endpoint, both driven by scripted payloads.
+package testservers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TwitchIRC is a fake Twitch IRC server. It completes the login handshake,
+// answers CAP, JOIN and PING, records every line clients send, and
+// broadcasts scripted lines to every connected client.
+type TwitchIRC struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu         sync.Mutex
+	conns      map[net.Conn]bool
+	accepted   int
+	received   []string
+	joined     map[string]bool
+	rejectAuth bool
+	changed    chan struct{}
+}
+
+// TwitchMessage is a chat message sent by the fake server as a tagged
+// PRIVMSG.
+type TwitchMessage struct {
+	ID          string
+	User        string
+	DisplayName string
+	UserID      string
+	Text        string
+	Color       string
+	// Badges is the raw badges tag, such as "moderator/1,subscriber/12".
+	Badges string
+	// Ts defaults to the current time.
+	Ts time.Time
+}
+
+// NewTwitchIRC starts a fake server on a loopback port.
+func NewTwitchIRC() (*TwitchIRC, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &TwitchIRC{ln: ln, conns: map[net.Conn]bool{}, joined: map[string]bool{}, changed: make(chan struct{})}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr is the server's host:port.
+func (s *TwitchIRC) Addr() string { return s.ln.Addr().String() }
+
+// Dial connects to the fake server whatever addr is asked for, so it can
+// stand in for a client's dialer without changing its configured address.
+func (s *TwitchIRC) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "tcp", s.Addr())
+}
+
+// SetRejectAuth makes later logins fail with Twitch's authentication
+// NOTICE.
+func (s *TwitchIRC) SetRejectAuth(reject bool) {
+	s.mu.Lock()
+	s.rejectAuth = reject
+	s.mu.Unlock()
+}
+
+// Connections is how many connections the server has accepted.
+func (s *TwitchIRC) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accepted
+}
+
+// Received returns the lines clients have sent, oldest first.
+func (s *TwitchIRC) Received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.received...)
+}
+
+// WaitJoin blocks until a client has joined channel on a live connection.
+func (s *TwitchIRC) WaitJoin(ctx context.Context, channel string) error {
+	channel = "#" + strings.ToLower(strings.TrimPrefix(channel, "#"))
+	return s.wait(ctx, func() bool { return s.joined[channel] })
+}
+
+// WaitConnections blocks until the server has accepted n connections.
+func (s *TwitchIRC) WaitConnections(ctx context.Context, n int) error {
+	return s.wait(ctx, func() bool { return s.accepted >= n })
+}
+
+// wait blocks until cond, called with s.mu held, is true.
+func (s *TwitchIRC) wait(ctx context.Context, cond func() bool) error {
+	for {
+		s.mu.Lock()
+		ok, changed := cond(), s.changed
+		s.mu.Unlock()
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// notify wakes waiters; s.mu must be held.
+func (s *TwitchIRC) notify() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Send writes a raw IRC line to every connected client.
+func (s *TwitchIRC) Send(line string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		fmt.Fprintf(conn, "%s\r\n", line)
+	}
+}
+
+// Privmsg sends msg to channel as Twitch would deliver it.
+func (s *TwitchIRC) Privmsg(channel string, msg TwitchMessage) {
+	s.Send(PrivmsgLine(channel, msg))
+}
+
+// PrivmsgLine formats msg as a tagged Twitch PRIVMSG.
+func PrivmsgLine(channel string, msg TwitchMessage) string {
+	ts := msg.Ts
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	user := strings.ToLower(msg.User)
+	display := msg.DisplayName
+	if display == "" {
+		display = msg.User
+	}
+	tags := []string{
+		"badge-info=",
+		"badges=" + msg.Badges,
+		"color=" + msg.Color,
+		"display-name=" + display,
+		"emotes=",
+		"id=" + msg.ID,
+		"room-id=1",
+		"tmi-sent-ts=" + fmt.Sprint(ts.UnixMilli()),
+		"user-id=" + msg.UserID,
+	}
+	return fmt.Sprintf("@%s :%s!%s@%s.tmi.twitch.tv PRIVMSG #%s :%s",
+		strings.Join(tags, ";"), user, user, user, strings.TrimPrefix(strings.ToLower(channel), "#"), msg.Text)
+}
+
+// DropClients closes every client connection, as a network failure or
+// server restart would.
+func (s *TwitchIRC) DropClients() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Close stops the server and drops its clients.
+func (s *TwitchIRC) Close() error {
+	err := s.ln.Close()
+	s.DropClients()
+	s.wg.Wait()
+	return err
+}
+
+func (s *TwitchIRC) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.accepted++
+		s.notify()
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *TwitchIRC) handle(conn net.Conn) {
+	defer s.wg.Done()
+	var channels []string
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		for _, ch := range channels {
+			delete(s.joined, ch)
+		}
+		s.notify()
+		s.mu.Unlock()
+	}()
+
+	reply := func(format string, args ...any) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		fmt.Fprintf(conn, format+"\r\n", args...)
+	}
+	var nick string
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		s.mu.Lock()
+		s.received = append(s.received, line)
+		reject := s.rejectAuth
+		s.notify()
+		s.mu.Unlock()
+
+		command, rest, _ := strings.Cut(line, " ")
+		switch command {
+		case "NICK":
+			nick = rest
+			if reject {
+				reply(":tmi.twitch.tv NOTICE * :Login authentication failed")
+				return
+			}
+			reply(":tmi.twitch.tv 001 %s :Welcome, GLHF!", nick)
+		case "CAP":
+			reply(":tmi.twitch.tv CAP * ACK %s", strings.TrimPrefix(rest, "REQ "))
+		case "PING":
+			reply(":tmi.twitch.tv PONG tmi.twitch.tv %s", rest)
+		case "JOIN":
+			for _, ch := range strings.Split(rest, ",") {
+				ch = strings.ToLower(strings.TrimSpace(ch))
+				reply(":%s!%s@%s.tmi.twitch.tv JOIN %s", nick, nick, nick, ch)
+				s.mu.Lock()
+				s.joined[ch] = true
+				s.notify()
+				s.mu.Unlock()
+				channels = append(channels, ch)
+			}
+		}
+	}
+}
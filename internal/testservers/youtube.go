@@ -0,0 +1,226 @@
+package testservers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// YouTubeAPIKey and YouTubeClientVersion are what the fake watch page
+	// advertises; polls with another key are rejected.
+	YouTubeAPIKey        = "fake-innertube-key"
+	YouTubeClientVersion = "2.20240101.00.00"
+)
+
+// YouTube is a fake YouTube serving a live watch page and the Innertube
+// get_live_chat endpoint. Each poll returns the next batch queued with
+// Push, or an empty batch when none is queued.
+type YouTube struct {
+	VideoID string
+	// Title is shown on the watch page.
+	Title string
+
+	srv *httptest.Server
+
+	mu       sync.Mutex
+	batches  [][]YouTubeMessage
+	failures []int
+	polls    int
+	token    int
+	pollMS   int
+}
+
+// YouTubeMessage is one live chat text message.
+type YouTubeMessage struct {
+	ID        string
+	Author    string
+	ChannelID string
+	Text      string
+	// Ts defaults to the current time.
+	Ts time.Time
+}
+
+// NewYouTube starts a fake YouTube whose watch page is live with videoID.
+func NewYouTube(videoID string) *YouTube {
+	y := &YouTube{VideoID: videoID, Title: "Fake stream", pollMS: 50}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/watch", y.handleWatch)
+	mux.HandleFunc("/youtubei/v1/live_chat/get_live_chat", y.handleLiveChat)
+	y.srv = httptest.NewServer(mux)
+	return y
+}
+
+// URL is the fake server's base URL.
+func (y *YouTube) URL() string { return y.srv.URL }
+
+// WatchURL is the real YouTube watch URL for the fake video; requests for
+// it reach the fake through Transport.
+func (y *YouTube) WatchURL() string {
+	return "https://www.youtube.com/watch?v=" + url.QueryEscape(y.VideoID)
+}
+
+// Transport sends every request to the fake server whatever its host, so
+// clients keep using real YouTube URLs. Responses report the original
+// request.
+func (y *YouTube) Transport() http.RoundTripper {
+	target, _ := url.Parse(y.srv.URL)
+	return roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		out := req.Clone(req.Context())
+		out.URL.Scheme, out.URL.Host, out.Host = target.Scheme, target.Host, ""
+		resp, err := http.DefaultTransport.RoundTrip(out)
+		if resp != nil {
+			resp.Request = req
+		}
+		return resp, err
+	})
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// SetPollInterval sets the timeoutMs the fake tells clients to wait
+// between polls.
+func (y *YouTube) SetPollInterval(d time.Duration) {
+	y.mu.Lock()
+	y.pollMS = int(d.Milliseconds())
+	y.mu.Unlock()
+}
+
+// Push queues one poll's worth of messages.
+func (y *YouTube) Push(msgs ...YouTubeMessage) {
+	y.mu.Lock()
+	y.batches = append(y.batches, msgs)
+	y.mu.Unlock()
+}
+
+// FailPolls makes the next n polls answer with status.
+func (y *YouTube) FailPolls(status, n int) {
+	y.mu.Lock()
+	for i := 0; i < n; i++ {
+		y.failures = append(y.failures, status)
+	}
+	y.mu.Unlock()
+}
+
+// Polls is how many get_live_chat requests have been answered.
+func (y *YouTube) Polls() int {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	return y.polls
+}
+
+// Pending is how many pushed batches have not been delivered yet.
+func (y *YouTube) Pending() int {
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	return len(y.batches)
+}
+
+// Close stops the server.
+func (y *YouTube) Close() { y.srv.Close() }
+
+func (y *YouTube) continuation() string { return fmt.Sprintf("fake-cont-%d", y.token) }
+
+func (y *YouTube) handleWatch(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("v") != y.VideoID {
+		http.NotFound(w, r)
+		return
+	}
+	y.mu.Lock()
+	cont := y.continuation()
+	y.mu.Unlock()
+	player, _ := json.Marshal(map[string]any{
+		"videoDetails": map[string]any{"videoId": y.VideoID, "title": y.Title, "isLive": true, "isLiveContent": true},
+	})
+	initial, _ := json.Marshal(map[string]any{
+		"contents": map[string]any{"twoColumnWatchNextResults": map[string]any{"conversationBar": map[string]any{
+			"liveChatRenderer": map[string]any{"continuations": []any{
+				map[string]any{"reloadContinuationData": map[string]any{"continuation": cont}},
+			}},
+		}}},
+	})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, `<!doctype html><html><head><title>%s - YouTube</title></head><body>
+<script>ytcfg.set({"INNERTUBE_API_KEY":"%s","INNERTUBE_CLIENT_VERSION":"%s"});</script>
+<script>var ytInitialPlayerResponse = %s;</script>
+<script>var ytInitialData = %s;</script>
+</body></html>`, y.Title, YouTubeAPIKey, YouTubeClientVersion, player, initial)
+}
+
+func (y *YouTube) handleLiveChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Query().Get("key") != YouTubeAPIKey {
+		http.Error(w, `{"error":{"code":403,"status":"PERMISSION_DENIED"}}`, http.StatusForbidden)
+		return
+	}
+	var body struct {
+		Continuation string `json:"continuation"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, `{"error":{"code":400,"status":"INVALID_ARGUMENT"}}`, http.StatusBadRequest)
+		return
+	}
+
+	y.mu.Lock()
+	defer y.mu.Unlock()
+	y.polls++
+	if len(y.failures) > 0 {
+		status := y.failures[0]
+		y.failures = y.failures[1:]
+		http.Error(w, fmt.Sprintf(`{"error":{"code":%d}}`, status), status)
+		return
+	}
+	if body.Continuation != y.continuation() {
+		http.Error(w, `{"error":{"code":400,"status":"INVALID_ARGUMENT"}}`, http.StatusBadRequest)
+		return
+	}
+	var batch []YouTubeMessage
+	if len(y.batches) > 0 {
+		batch, y.batches = y.batches[0], y.batches[1:]
+	}
+	y.token++
+	actions := make([]any, 0, len(batch))
+	for _, msg := range batch {
+		actions = append(actions, map[string]any{"addChatItemAction": map[string]any{"item": map[string]any{
+			"liveChatTextMessageRenderer": textRenderer(msg),
+		}}})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"continuationContents": map[string]any{"liveChatContinuation": map[string]any{
+			"continuations": []any{map[string]any{"invalidationContinuationData": map[string]any{
+				"continuation": y.continuation(),
+				"timeoutMs":    y.pollMS,
+			}}},
+			"actions": actions,
+		}},
+	})
+}
+
+func textRenderer(msg YouTubeMessage) map[string]any {
+	ts := msg.Ts
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	channelID := msg.ChannelID
+	if channelID == "" {
+		channelID = "UC" + strings.ToLower(strings.ReplaceAll(msg.Author, " ", ""))
+	}
+	return map[string]any{
+		"id":                      msg.ID,
+		"timestampUsec":           fmt.Sprint(ts.UnixMicro()),
+		"authorName":              map[string]any{"simpleText": msg.Author},
+		"authorExternalChannelId": channelID,
+		"message":                 map[string]any{"runs": []any{map[string]any{"text": msg.Text}}},
+	}
+}
@@ -0,0 +1,245 @@
+package triggers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+)
+
+const (
+	queueSize      = 256
+	webhookTimeout = 5 * time.Second
+)
+
+// MarkerStore stores the rows written by marker actions.
+type MarkerStore interface {
+	AddMarker(ctx context.Context, m core.Marker) (int64, error)
+}
+
+// TTSQueue stores the entries written by tts actions.
+type TTSQueue interface {
+	EnqueueTTS(ctx context.Context, item core.TTSItem) (int64, error)
+}
+
+// Options wires actions to the rest of the harvester. Actions whose
+// dependency is nil are skipped with a log line.
+type Options struct {
+	Markers MarkerStore
+	TTS     TTSQueue
+	// Reply sends text to the chat msg arrived on.
+	Reply func(ctx context.Context, msg core.ChatMessage, text string) error
+	// Alert shows msg on the alert overlay, labelled by the rule.
+	Alert      func(msg core.ChatMessage, rule, label string)
+	HTTPClient *http.Client
+}
+
+// Engine evaluates rules against written messages. Matching and actions run
+// on a background worker so Write never blocks ingest; messages are dropped
+// while the queue is full.
+type Engine struct {
+	rules []Rule
+	opts  Options
+	queue chan core.ChatMessage
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu        sync.Mutex
+	closed    bool
+	dropped   int64
+	lastFired map[string]time.Time
+}
+
+// New starts an engine for rules (as returned by Load).
+func New(rules []Rule, opts Options) *Engine {
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: webhookTimeout}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Engine{
+		rules:     rules,
+		opts:      opts,
+		queue:     make(chan core.ChatMessage, queueSize),
+		cancel:    cancel,
+		done:      make(chan struct{}),
+		lastFired: map[string]time.Time{},
+	}
+	go e.run(ctx)
+	return e
+}
+
+// Write queues msg for rule evaluation.
+func (e *Engine) Write(msg core.ChatMessage, _ *ingesttrace.MessageTrace) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return errors.New("triggers closed")
+	}
+	select {
+	case e.queue <- msg:
+	default:
+		e.dropped++
+		if e.dropped == 1 || e.dropped%1000 == 0 {
+			log.Printf("triggers: queue full, dropped=%d", e.dropped)
+		}
+	}
+	return nil
+}
+
+// Close stops accepting messages, lets queued ones finish and stops the
+// worker.
+func (e *Engine) Close() error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+	e.closed = true
+	close(e.queue)
+	e.mu.Unlock()
+	<-e.done
+	e.cancel()
+	return nil
+}
+
+func (e *Engine) run(ctx context.Context) {
+	defer close(e.done)
+	for msg := range e.queue {
+		e.handle(ctx, msg, time.Now())
+	}
+}
+
+// handle fires every rule msg matches that is not cooling down, returning
+// the names of the rules fired.
+func (e *Engine) handle(ctx context.Context, msg core.ChatMessage, now time.Time) []string {
+	var fired []string
+	for i := range e.rules {
+		rule := &e.rules[i]
+		args, ok := rule.match(msg)
+		if !ok {
+			continue
+		}
+		if last, seen := e.lastFired[rule.Name]; seen && now.Sub(last) < rule.Cooldown() {
+			continue
+		}
+		e.lastFired[rule.Name] = now
+		fired = append(fired, rule.Name)
+		for _, action := range rule.Actions {
+			if err := e.fire(ctx, rule, action, msg, args); err != nil && ctx.Err() == nil {
+				log.Printf("triggers: %s: %s: %v", rule.Name, action.Type, err)
+			}
+		}
+	}
+	return fired
+}
+
+func (e *Engine) fire(ctx context.Context, rule *Rule, action Action, msg core.ChatMessage, args string) error {
+	switch action.Type {
+	case ActionMarker:
+		if e.opts.Markers == nil {
+			return errors.New("markers need the sqlite sink")
+		}
+		label := rule.expand(action.Label, msg, args)
+		if label == "" {
+			label = rule.Name
+		}
+		_, err := e.opts.Markers.AddMarker(ctx, core.Marker{
+			Platform:  msg.Platform,
+			Rule:      rule.Name,
+			Label:     label,
+			MessageID: msg.ID,
+			Username:  msg.Username,
+			Ts:        msg.Ts,
+		})
+		return err
+	case ActionReply:
+		if e.opts.Reply == nil {
+			return errors.New("replies are not available")
+		}
+		return e.opts.Reply(ctx, msg, rule.expand(action.Text, msg, args))
+	case ActionTTS:
+		if e.opts.TTS == nil {
+			return errors.New("tts needs the sqlite sink")
+		}
+		text := msg.Text
+		if action.Text != "" {
+			text = rule.expand(action.Text, msg, args)
+		}
+		_, err := e.opts.TTS.EnqueueTTS(ctx, core.TTSItem{
+			Platform:  msg.Platform,
+			Channel:   msg.Channel,
+			Rule:      rule.Name,
+			MessageID: msg.ID,
+			UserID:    msg.UserID,
+			Username:  msg.Username,
+			Text:      text,
+			Ts:        msg.Ts,
+		})
+		return err
+	case ActionAlert:
+		if e.opts.Alert == nil {
+			return errors.New("alerts need the http api")
+		}
+		e.opts.Alert(msg, rule.Name, rule.expand(action.Label, msg, args))
+		return nil
+	case ActionWebhook:
+		return e.webhook(ctx, rule, action.URL, msg, args)
+	case ActionHomeAssistant:
+		payload := action.Payload
+		if payload == nil {
+			payload = map[string]any{
+				"rule":     "{rule}",
+				"platform": "{platform}",
+				"user":     "{user}",
+				"text":     "{text}",
+				"type":     "{type}",
+				"amount":   "{amount}",
+			}
+		}
+		return e.post(ctx, action.URL, rule.expandPayload(payload, msg, args))
+	}
+	return fmt.Errorf("unknown action type %q", action.Type)
+}
+
+func (e *Engine) webhook(ctx context.Context, rule *Rule, url string, msg core.ChatMessage, args string) error {
+	return e.post(ctx, url, map[string]any{
+		"rule":    rule.Name,
+		"args":    args,
+		"message": msg,
+	})
+}
+
+// post sends payload to url as JSON.
+func (e *Engine) post(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.opts.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook status %s", resp.Status)
+	}
+	return nil
+}
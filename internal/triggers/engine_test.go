@@ -0,0 +1,303 @@
+package triggers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func writeRules(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(body), 0o600); err != nil {
+		t.Fatalf("write rules: %v", err)
+	}
+	return path
+}
+
+func TestLoadValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"missing name", `{"rules":[{"command":"!a","actions":[{"type":"marker"}]}]}`, "name is required"},
+		{"command and pattern", `{"rules":[{"name":"a","command":"!a","pattern":"a","actions":[{"type":"marker"}]}]}`, "cannot be combined"},
+		{"no matcher", `{"rules":[{"name":"a","actions":[{"type":"marker"}]}]}`, "one of command, pattern, types or min_amount"},
+		{"bad type", `{"rules":[{"name":"a","types":["cheer"],"actions":[{"type":"marker"}]}]}`, "unknown message type"},
+		{"negative amount", `{"rules":[{"name":"a","types":["superchat"],"min_amount":-1,"actions":[{"type":"marker"}]}]}`, "min_amount"},
+		{"bad homeassistant url", `{"rules":[{"name":"a","types":["raid"],"actions":[{"type":"homeassistant","url":"ha.local"}]}]}`, "url must be http(s)"},
+		{"bad pattern", `{"rules":[{"name":"a","pattern":"(","actions":[{"type":"marker"}]}]}`, "pattern"},
+		{"bad platform", `{"rules":[{"name":"a","command":"!a","platforms":["kick"],"actions":[{"type":"marker"}]}]}`, "unknown platform"},
+		{"no actions", `{"rules":[{"name":"a","command":"!a"}]}`, "at least one action"},
+		{"bad webhook", `{"rules":[{"name":"a","command":"!a","actions":[{"type":"webhook","url":"ftp://x"}]}]}`, "webhook url"},
+		{"empty reply", `{"rules":[{"name":"a","command":"!a","actions":[{"type":"reply"}]}]}`, "reply text"},
+		{"unknown action", `{"rules":[{"name":"a","command":"!a","actions":[{"type":"email"}]}]}`, "unknown action"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeRules(t, tt.body))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+
+	rules, err := Load(writeRules(t, `{"rules":[{"name":"clip","command":"!clip","platforms":["Twitch"],"cooldown_secs":30,"actions":[{"type":"marker"}]}]}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(rules) != 1 || rules[0].Cooldown() != 30*time.Second {
+		t.Fatalf("unexpected rules %+v", rules)
+	}
+}
+
+func TestRuleMatch(t *testing.T) {
+	rules, err := Load(writeRules(t, `{"rules":[
+		{"name":"clip","command":"!clip","platforms":["twitch"],"actions":[{"type":"marker"}]},
+		{"name":"gg","pattern":"(?i)\\bgg\\b","actions":[{"type":"marker"}]}
+	]}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	clip, gg := &rules[0], &rules[1]
+
+	tests := []struct {
+		rule     *Rule
+		platform string
+		text     string
+		args     string
+		ok       bool
+	}{
+		{clip, "Twitch", "!clip", "", true},
+		{clip, "Twitch", "!CLIP that was huge ", "that was huge", true},
+		{clip, "Twitch", "!clipped", "", false},
+		{clip, "Twitch", "nice !clip", "", false},
+		{clip, "YouTube", "!clip", "", false},
+		{gg, "YouTube", "GG everyone", "GG everyone", true},
+		{gg, "Twitch", "eggs", "", false},
+	}
+	for _, tt := range tests {
+		args, ok := tt.rule.match(core.ChatMessage{Platform: tt.platform, Text: tt.text})
+		if ok != tt.ok || args != tt.args {
+			t.Fatalf("%s.match(%s %q) = %q, %v; want %q, %v", tt.rule.Name, tt.platform, tt.text, args, ok, tt.args, tt.ok)
+		}
+	}
+}
+
+func TestRuleMatchTypesAndAmount(t *testing.T) {
+	rules, err := Load(writeRules(t, `{"rules":[
+		{"name":"raid","types":["raid"],"actions":[{"type":"marker"}]},
+		{"name":"big","types":["superchat"],"min_amount":10,"actions":[{"type":"marker"}]},
+		{"name":"dm","types":["whisper"],"actions":[{"type":"marker"}]}
+	]}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	raid, big, dm := &rules[0], &rules[1], &rules[2]
+
+	tests := []struct {
+		rule   *Rule
+		typ    string
+		amount string
+		ok     bool
+	}{
+		{raid, core.MessageTypeRaid, "", true},
+		{raid, "", "", false},
+		{big, core.MessageTypeSuperchat, "$10.00", true},
+		{big, core.MessageTypeSuperchat, "CA$1,234.56", true},
+		{big, core.MessageTypeSuperchat, "10,00 €", true},
+		{big, core.MessageTypeSuperchat, "¥500", true},
+		{big, core.MessageTypeSuperchat, "$9.99", false},
+		{big, core.MessageTypeSuperchat, "", false},
+		{big, core.MessageTypeChat, "$50.00", false},
+		{dm, core.MessageTypeWhisper, "", true},
+		{dm, "", "", false},
+	}
+	for _, tt := range tests {
+		msg := core.ChatMessage{Platform: "YouTube", MessageType: tt.typ, PaidAmount: tt.amount, Text: "hi"}
+		if _, ok := tt.rule.match(msg); ok != tt.ok {
+			t.Fatalf("%s.match(%q %q) = %v; want %v", tt.rule.Name, tt.typ, tt.amount, ok, tt.ok)
+		}
+	}
+}
+
+type markerRecorder struct{ markers []core.Marker }
+
+func (m *markerRecorder) AddMarker(_ context.Context, marker core.Marker) (int64, error) {
+	m.markers = append(m.markers, marker)
+	return int64(len(m.markers)), nil
+}
+
+func TestEngineHandle(t *testing.T) {
+	var hooks []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode webhook: %v", err)
+		}
+		hooks = append(hooks, body)
+	}))
+	defer srv.Close()
+
+	rules, err := Load(writeRules(t, `{"rules":[{"name":"clip","command":"!clip","cooldown_secs":60,"actions":[
+		{"type":"marker","label":"{user}: {args}"},
+		{"type":"reply","text":"@{user} clipped on {platform}"},
+		{"type":"webhook","url":"`+srv.URL+`"}
+	]}]}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	markers := &markerRecorder{}
+	var replies []string
+	e := &Engine{
+		rules: rules,
+		opts: Options{
+			Markers: markers,
+			Reply: func(_ context.Context, _ core.ChatMessage, text string) error {
+				replies = append(replies, text)
+				return nil
+			},
+			HTTPClient: srv.Client(),
+		},
+		lastFired: map[string]time.Time{},
+	}
+
+	ctx := context.Background()
+	now := time.Unix(1700000000, 0).UTC()
+	msg := core.ChatMessage{ID: "m1", Platform: "Twitch", Username: "alice", Text: "!clip big play", Ts: now}
+	if fired := e.handle(ctx, msg, now); !reflect.DeepEqual(fired, []string{"clip"}) {
+		t.Fatalf("expected clip to fire, got %v", fired)
+	}
+	if len(markers.markers) != 1 || markers.markers[0].Label != "alice: big play" || markers.markers[0].MessageID != "m1" {
+		t.Fatalf("unexpected markers %+v", markers.markers)
+	}
+	if !reflect.DeepEqual(replies, []string{"@alice clipped on Twitch"}) {
+		t.Fatalf("unexpected replies %v", replies)
+	}
+	if len(hooks) != 1 || hooks[0]["rule"] != "clip" || hooks[0]["args"] != "big play" {
+		t.Fatalf("unexpected webhooks %v", hooks)
+	}
+
+	if fired := e.handle(ctx, msg, now.Add(30*time.Second)); len(fired) != 0 {
+		t.Fatalf("expected cooldown to suppress the rule, got %v", fired)
+	}
+	if fired := e.handle(ctx, msg, now.Add(time.Minute)); len(fired) != 1 {
+		t.Fatalf("expected the rule to fire after the cooldown, got %v", fired)
+	}
+}
+
+func TestEngineHomeAssistant(t *testing.T) {
+	var hooks []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode webhook: %v", err)
+		}
+		hooks = append(hooks, body)
+	}))
+	defer srv.Close()
+
+	rules, err := Load(writeRules(t, `{"rules":[{"name":"superchat","types":["superchat"],"actions":[
+		{"type":"homeassistant","url":"`+srv.URL+`/api/webhook/chat"},
+		{"type":"homeassistant","url":"`+srv.URL+`/api/webhook/lights","payload":{"scene":"party","by":"{user} ({amount})","n":3}}
+	]}]}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	e := &Engine{rules: rules, opts: Options{HTTPClient: srv.Client()}, lastFired: map[string]time.Time{}}
+
+	now := time.Unix(1700000000, 0).UTC()
+	msg := core.ChatMessage{ID: "m1", Platform: "YouTube", Username: "bob", Text: "love it", MessageType: core.MessageTypeSuperchat, PaidAmount: "$5.00", Ts: now}
+	if fired := e.handle(context.Background(), msg, now); len(fired) != 1 {
+		t.Fatalf("expected the rule to fire, got %v", fired)
+	}
+	want := []map[string]any{
+		{"rule": "superchat", "platform": "YouTube", "user": "bob", "text": "love it", "type": "superchat", "amount": "$5.00"},
+		{"scene": "party", "by": "bob ($5.00)", "n": float64(3)},
+	}
+	if !reflect.DeepEqual(hooks, want) {
+		t.Fatalf("unexpected payloads %v", hooks)
+	}
+}
+
+func TestEngineAlert(t *testing.T) {
+	rules, err := Load(writeRules(t, `{"rules":[{"name":"hype","pattern":"(?i)hype","actions":[{"type":"alert","label":"{user} is hyped"}]}]}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	var got []string
+	e := &Engine{
+		rules: rules,
+		opts: Options{Alert: func(msg core.ChatMessage, rule, label string) {
+			got = append(got, msg.ID+" "+rule+" "+label)
+		}},
+		lastFired: map[string]time.Time{},
+	}
+	now := time.Unix(1700000000, 0).UTC()
+	e.handle(context.Background(), core.ChatMessage{ID: "m1", Username: "carol", Text: "HYPE"}, now)
+	// A whisper must not reach the /alerts stream through an untyped rule.
+	e.handle(context.Background(), core.ChatMessage{ID: "m2", Username: "dave", Text: "hype", MessageType: core.MessageTypeWhisper}, now.Add(time.Minute))
+	if !reflect.DeepEqual(got, []string{"m1 hype carol is hyped"}) {
+		t.Fatalf("unexpected alerts %v", got)
+	}
+}
+
+type ttsRecorder struct{ items []core.TTSItem }
+
+func (q *ttsRecorder) EnqueueTTS(_ context.Context, item core.TTSItem) (int64, error) {
+	q.items = append(q.items, item)
+	return int64(len(q.items)), nil
+}
+
+func TestEngineTTS(t *testing.T) {
+	rules, err := Load(writeRules(t, `{"rules":[
+		{"name":"superchat","types":["superchat"],"actions":[{"type":"tts"}]},
+		{"name":"say","command":"!say","actions":[{"type":"tts","text":"{user} says {args}"}]}
+	]}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	queue := &ttsRecorder{}
+	e := &Engine{rules: rules, opts: Options{TTS: queue}, lastFired: map[string]time.Time{}}
+	now := time.Unix(1700000000, 0).UTC()
+	ctx := context.Background()
+	e.handle(ctx, core.ChatMessage{ID: "m1", Platform: "YouTube", Username: "bob", Text: "great stream", MessageType: core.MessageTypeSuperchat}, now)
+	e.handle(ctx, core.ChatMessage{ID: "m2", Platform: "Twitch", Channel: "elora", Username: "amy", Text: "!say hi all"}, now)
+	// Rules without types never read whispers aloud.
+	e.handle(ctx, core.ChatMessage{ID: "m3", Platform: "Twitch", Username: "amy", Text: "!say secret", MessageType: core.MessageTypeWhisper}, now.Add(time.Minute))
+	if len(queue.items) != 2 || queue.items[0].Text != "great stream" || queue.items[0].Rule != "superchat" ||
+		queue.items[1].Text != "amy says hi all" || queue.items[1].Channel != "elora" {
+		t.Fatalf("unexpected tts items %+v", queue.items)
+	}
+}
+
+func TestEngineWriteAndClose(t *testing.T) {
+	rules, err := Load(writeRules(t, `{"rules":[{"name":"mark","command":"!mark","actions":[{"type":"marker"}]}]}`))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	markers := &markerRecorder{}
+	e := New(rules, Options{Markers: markers})
+	if err := e.Write(core.ChatMessage{Platform: "YouTube", Text: "!mark"}, nil); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if len(markers.markers) != 1 || markers.markers[0].Label != "mark" {
+		t.Fatalf("expected queued message to be handled before close, got %+v", markers.markers)
+	}
+	if err := e.Write(core.ChatMessage{Platform: "YouTube", Text: "!mark"}, nil); err == nil {
+		t.Fatalf("expected write after close to fail")
+	}
+}
@@ -0,0 +1,245 @@
+// Package triggers matches incoming chat against configured rules (prefix
+// commands, regular expressions or message types) and fires actions:
+// webhooks, Home Assistant webhooks, marker rows, chat replies, overlay
+// alerts and text-to-speech queue entries.
+package triggers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// Action types.
+const (
+	ActionWebhook       = "webhook"
+	ActionHomeAssistant = "homeassistant"
+	ActionMarker        = "marker"
+	ActionReply         = "reply"
+	ActionAlert         = "alert"
+	ActionTTS           = "tts"
+)
+
+// Rule is one trigger as written in the rules file.
+type Rule struct {
+	Name string `json:"name"`
+	// Command matches messages that are exactly the command or start with
+	// it followed by a space (case-insensitive), e.g. "!clip".
+	Command string `json:"command,omitempty"`
+	// Pattern is a regular expression matched against the message text.
+	Pattern string `json:"pattern,omitempty"`
+	// Types limits the rule to these message types (core.MessageTypes),
+	// e.g. "raid" or "superchat". A rule may match on types alone.
+	Types []string `json:"types,omitempty"`
+	// MinAmount only matches paid messages whose amount (in the message's
+	// own currency, see core.ParseAmount) is at least this much.
+	MinAmount float64 `json:"min_amount,omitempty"`
+	// Platforms limits the rule to "twitch" and/or "youtube"; empty means
+	// every platform.
+	Platforms    []string `json:"platforms,omitempty"`
+	CooldownSecs int      `json:"cooldown_secs,omitempty"`
+	Actions      []Action `json:"actions"`
+
+	pattern   *regexp.Regexp
+	platforms map[string]bool
+	types     map[string]bool
+}
+
+// Action is fired when its rule matches. URL applies to webhooks, Label to
+// markers and alerts, Text to replies and TTS entries (which default to the
+// message text) and Payload to Home Assistant webhooks. Label, Text and the
+// strings in Payload accept the {user}, {platform}, {channel},
+// {text}, {type}, {amount}, {args}, {command} and {rule} placeholders.
+type Action struct {
+	Type  string `json:"type"`
+	URL   string `json:"url,omitempty"`
+	Label string `json:"label,omitempty"`
+	Text  string `json:"text,omitempty"`
+	// Payload is the JSON body posted by homeassistant actions; it
+	// defaults to an object with the rule, platform, user, text, type and
+	// amount.
+	Payload any `json:"payload,omitempty"`
+}
+
+// Load reads and validates a JSON rules file ({"rules": [...]}).
+func Load(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []Rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for i := range file.Rules {
+		if err := file.Rules[i].compile(); err != nil {
+			return nil, fmt.Errorf("%s: rule %d: %w", path, i+1, err)
+		}
+	}
+	return file.Rules, nil
+}
+
+func (r *Rule) compile() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Command = strings.TrimSpace(r.Command)
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if r.Command != "" && r.Pattern != "" {
+		return fmt.Errorf("%s: command and pattern cannot be combined", r.Name)
+	}
+	if r.Command == "" && r.Pattern == "" && len(r.Types) == 0 && r.MinAmount == 0 {
+		return fmt.Errorf("%s: one of command, pattern, types or min_amount is required", r.Name)
+	}
+	if r.MinAmount < 0 {
+		return fmt.Errorf("%s: min_amount must not be negative", r.Name)
+	}
+	r.types = nil
+	for _, t := range r.Types {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if !slices.Contains(core.MessageTypes, t) || t == core.MessageTypeDeleted {
+			return fmt.Errorf("%s: unknown message type %q", r.Name, t)
+		}
+		if r.types == nil {
+			r.types = map[string]bool{}
+		}
+		r.types[t] = true
+	}
+	if r.Pattern != "" {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return fmt.Errorf("%s: pattern: %w", r.Name, err)
+		}
+		r.pattern = re
+	}
+	if r.CooldownSecs < 0 {
+		return fmt.Errorf("%s: cooldown_secs must not be negative", r.Name)
+	}
+	r.platforms = nil
+	for _, p := range r.Platforms {
+		switch strings.ToLower(strings.TrimSpace(p)) {
+		case "twitch":
+			p = "Twitch"
+		case "youtube":
+			p = "YouTube"
+		default:
+			return fmt.Errorf("%s: unknown platform %q", r.Name, p)
+		}
+		if r.platforms == nil {
+			r.platforms = map[string]bool{}
+		}
+		r.platforms[p] = true
+	}
+	if len(r.Actions) == 0 {
+		return fmt.Errorf("%s: at least one action is required", r.Name)
+	}
+	for _, a := range r.Actions {
+		switch a.Type {
+		case ActionWebhook, ActionHomeAssistant:
+			u, err := url.Parse(a.URL)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("%s: webhook url must be http(s), got %q", r.Name, a.URL)
+			}
+		case ActionMarker, ActionAlert, ActionTTS:
+		case ActionReply:
+			if strings.TrimSpace(a.Text) == "" {
+				return fmt.Errorf("%s: reply text is required", r.Name)
+			}
+		default:
+			return fmt.Errorf("%s: unknown action type %q", r.Name, a.Type)
+		}
+	}
+	return nil
+}
+
+// Cooldown is the minimum time between two firings of the rule.
+func (r *Rule) Cooldown() time.Duration {
+	return time.Duration(r.CooldownSecs) * time.Second
+}
+
+// match reports whether msg triggers r, returning the text after the
+// command (or the whole text for patterns) as args.
+func (r *Rule) match(msg core.ChatMessage) (string, bool) {
+	if r.platforms != nil && !r.platforms[msg.Platform] {
+		return "", false
+	}
+	msgType := core.NormalizeMessageType(msg.MessageType)
+	if r.types != nil && !r.types[msgType] {
+		return "", false
+	}
+	// Whispers are private, so only rules that ask for them by type see them.
+	if msgType == core.MessageTypeWhisper && !r.types[msgType] {
+		return "", false
+	}
+	if r.MinAmount > 0 {
+		amount, ok := core.ParseAmount(msg.PaidAmount)
+		if !ok || amount < r.MinAmount {
+			return "", false
+		}
+	}
+	text := strings.TrimSpace(msg.Text)
+	if r.Command == "" && r.pattern == nil {
+		return text, true
+	}
+	if r.pattern != nil {
+		if !r.pattern.MatchString(text) {
+			return "", false
+		}
+		return text, true
+	}
+	if len(text) < len(r.Command) || !strings.EqualFold(text[:len(r.Command)], r.Command) {
+		return "", false
+	}
+	rest := text[len(r.Command):]
+	if rest != "" && rest[0] != ' ' {
+		return "", false
+	}
+	return strings.TrimSpace(rest), true
+}
+
+// expand fills the placeholders in tmpl for a matched message.
+func (r *Rule) expand(tmpl string, msg core.ChatMessage, args string) string {
+	return strings.NewReplacer(
+		"{user}", msg.Username,
+		"{platform}", msg.Platform,
+		"{channel}", msg.Channel,
+		"{text}", msg.Text,
+		"{type}", core.NormalizeMessageType(msg.MessageType),
+		"{amount}", msg.PaidAmount,
+		"{args}", args,
+		"{command}", r.Command,
+		"{rule}", r.Name,
+	).Replace(tmpl)
+}
+
+// expandPayload returns payload with the placeholders in every string
+// expanded, leaving keys and other values alone.
+func (r *Rule) expandPayload(payload any, msg core.ChatMessage, args string) any {
+	switch v := payload.(type) {
+	case string:
+		return r.expand(v, msg, args)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, val := range v {
+			out[key] = r.expandPayload(val, msg, args)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, val := range v {
+			out[i] = r.expandPayload(val, msg, args)
+		}
+		return out
+	}
+	return payload
+}
@@ -22,8 +22,11 @@ type refreshResp struct {
 
 // Refresh exchanges the refresh token in refreshFile for a new access + refresh
 // token pair and atomically writes them to ircFile ("oauth:<access>") and
-// refreshFile ("<refresh>").
-func Refresh(clientID, clientSecret, refreshFile, ircFile string) error {
+// refreshFile ("<refresh>"). A nil client uses http.DefaultClient.
+func Refresh(client *http.Client, clientID, clientSecret, refreshFile, ircFile string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
 	refreshPath := strings.TrimSpace(refreshFile)
 	if refreshPath == "" {
 		return errors.New("twitch: refresh file path is empty")
@@ -54,7 +57,7 @@ func Refresh(clientID, clientSecret, refreshFile, ircFile string) error {
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("twitch: refresh request: %w", err)
 	}
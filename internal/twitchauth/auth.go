@@ -35,10 +35,13 @@ func (t TokenFiles) ReadRefresh() (string, error) {
 	return strings.TrimSpace(string(b)), nil
 }
 
-func ValidateLogin(access string) (string, error) {
+func ValidateLogin(client *http.Client, access string) (string, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
 	req, _ := http.NewRequest(http.MethodGet, "https://id.twitch.tv/oauth2/validate", nil)
 	req.Header.Set("Authorization", "Bearer "+access)
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -58,7 +61,10 @@ func ValidateLogin(access string) (string, error) {
 	return v.Login, nil
 }
 
-func RefreshAccess(clientID, clientSecret, refresh string) (string, error) {
+func RefreshAccess(client *http.Client, clientID, clientSecret, refresh string) (string, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
 	form := url.Values{}
 	form.Set("client_id", clientID)
 	form.Set("client_secret", clientSecret)
@@ -66,7 +72,7 @@ func RefreshAccess(clientID, clientSecret, refresh string) (string, error) {
 	form.Set("refresh_token", refresh)
 	req, _ := http.NewRequest(http.MethodPost, "https://id.twitch.tv/oauth2/token", bytes.NewBufferString(form.Encode()))
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", err
 	}
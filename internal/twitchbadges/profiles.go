@@ -0,0 +1,172 @@
+package twitchbadges
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// helixUsersBatch is the maximum number of logins Helix accepts per request.
+const helixUsersBatch = 100
+
+var loginPattern = regexp.MustCompile(`^[a-z0-9_]{1,25}$`)
+
+// Profile is the public Helix profile of a chatter.
+type Profile struct {
+	ID              string
+	Login           string
+	DisplayName     string
+	ProfileImageURL string
+	BroadcasterType string
+	CreatedAt       time.Time
+}
+
+type helixProfile struct {
+	ID              string `json:"id"`
+	Login           string `json:"login"`
+	DisplayName     string `json:"display_name"`
+	ProfileImageURL string `json:"profile_image_url"`
+	BroadcasterType string `json:"broadcaster_type"`
+	CreatedAt       string `json:"created_at"`
+}
+
+// ValidLogin reports whether login can be looked up via Helix.
+func ValidLogin(login string) bool {
+	return loginPattern.MatchString(login)
+}
+
+// Profiles looks up Helix profiles for logins, batching requests and caching
+// results for the resolver TTL. Logins that do not exist (or are not valid
+// Twitch logins) are absent from the result.
+func (r *Resolver) Profiles(ctx context.Context, logins []string) (map[string]Profile, error) {
+	if r == nil || strings.TrimSpace(r.ClientID) == "" || strings.TrimSpace(r.ClientSecret) == "" {
+		return nil, fmt.Errorf("twitch client credentials not configured")
+	}
+	ttl := r.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	out := make(map[string]Profile, len(logins))
+	var missing []string
+	seen := make(map[string]bool, len(logins))
+	for _, login := range logins {
+		login = strings.ToLower(strings.TrimSpace(login))
+		if !ValidLogin(login) || seen[login] {
+			continue
+		}
+		seen[login] = true
+		if p, ok := r.cachedProfile(login); ok {
+			if p.ID != "" {
+				out[login] = p
+			}
+			continue
+		}
+		missing = append(missing, login)
+	}
+	if len(missing) == 0 {
+		return out, nil
+	}
+
+	token, err := r.appToken(ctx)
+	if err != nil {
+		return out, fmt.Errorf("app token: %w", err)
+	}
+	for start := 0; start < len(missing); start += helixUsersBatch {
+		end := start + helixUsersBatch
+		if end > len(missing) {
+			end = len(missing)
+		}
+		batch := missing[start:end]
+		found, err := r.fetchProfiles(ctx, token, batch)
+		if err != nil {
+			return out, err
+		}
+		for _, login := range batch {
+			p := found[login]
+			// Cache misses too so unknown logins are not re-requested.
+			r.storeProfile(login, p, ttl)
+			if p.ID != "" {
+				out[login] = p
+			}
+		}
+	}
+	return out, nil
+}
+
+func (r *Resolver) fetchProfiles(ctx context.Context, token string, logins []string) (map[string]Profile, error) {
+	query := url.Values{}
+	for _, login := range logins {
+		query.Add("login", login)
+	}
+	endpoint := strings.TrimSuffix(helixBaseURL, "/") + usersPath + "?" + query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Client-Id", strings.TrimSpace(r.ClientID))
+
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var parsed struct {
+		Data []helixProfile `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	out := make(map[string]Profile, len(parsed.Data))
+	for _, u := range parsed.Data {
+		if u.ID == "" {
+			continue
+		}
+		p := Profile{
+			ID:              u.ID,
+			Login:           strings.ToLower(u.Login),
+			DisplayName:     u.DisplayName,
+			ProfileImageURL: u.ProfileImageURL,
+			BroadcasterType: u.BroadcasterType,
+		}
+		if t, err := time.Parse(time.RFC3339, u.CreatedAt); err == nil {
+			p.CreatedAt = t.UTC()
+		}
+		out[p.Login] = p
+	}
+	return out, nil
+}
+
+func (r *Resolver) cachedProfile(login string) (Profile, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.profiles[login]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return Profile{}, false
+	}
+	p, _ := entry.value.(Profile)
+	return p, true
+}
+
+func (r *Resolver) storeProfile(login string, p Profile, ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.profiles == nil {
+		r.profiles = map[string]cacheEntry{}
+	}
+	r.profiles[login] = cacheEntry{value: p, expiresAt: time.Now().Add(ttl)}
+}
@@ -0,0 +1,72 @@
+package twitchbadges
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolverProfilesBatchesAndCaches(t *testing.T) {
+	userCalls := &atomic.Int64{}
+	mux := http.NewServeMux()
+	mux.Handle("/oauth2/token", tokenResponder{count: &atomic.Int64{}})
+	mux.HandleFunc("/helix/users", func(w http.ResponseWriter, r *http.Request) {
+		userCalls.Add(1)
+		var data []map[string]any
+		for _, login := range r.URL.Query()["login"] {
+			if login == "ghost" {
+				continue
+			}
+			data = append(data, map[string]any{
+				"id":                "id-" + login,
+				"login":             login,
+				"profile_image_url": "https://cdn/" + login + ".png",
+				"broadcaster_type":  "partner",
+				"created_at":        "2016-05-01T12:00:00Z",
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	helixBaseURL = srv.URL + "/helix"
+	oauthTokenURL = srv.URL + "/oauth2/token"
+
+	r := &Resolver{ClientID: "client", ClientSecret: "secret", TTL: time.Minute, HTTP: srv.Client()}
+
+	logins := []string{"ghost", "Not Valid!"}
+	for i := 0; i < 150; i++ {
+		logins = append(logins, fmt.Sprintf("user%d", i))
+	}
+	got, err := r.Profiles(context.Background(), logins)
+	if err != nil {
+		t.Fatalf("profiles: %v", err)
+	}
+	if len(got) != 150 {
+		t.Fatalf("expected 150 profiles, got %d", len(got))
+	}
+	if userCalls.Load() != 2 {
+		t.Fatalf("expected 2 batched requests, got %d", userCalls.Load())
+	}
+	p := got["user0"]
+	if p.ID != "id-user0" || p.ProfileImageURL != "https://cdn/user0.png" || p.BroadcasterType != "partner" {
+		t.Fatalf("unexpected profile %+v", p)
+	}
+	if !p.CreatedAt.Equal(time.Date(2016, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected created_at %v", p.CreatedAt)
+	}
+
+	if _, err := r.Profiles(context.Background(), logins); err != nil {
+		t.Fatalf("cached profiles: %v", err)
+	}
+	if userCalls.Load() != 2 {
+		t.Fatalf("expected cached lookups (including misses), got %d requests", userCalls.Load())
+	}
+}
@@ -24,6 +24,8 @@ var (
 	badgeGlobalPath  = "/chat/badges/global"
 	badgeChannelPath = "/chat/badges"
 	usersPath        = "/users"
+	streamsPath      = "/streams"
+	videosPath       = "/videos"
 )
 
 type Resolver struct {
@@ -36,6 +38,7 @@ type Resolver struct {
 	token     cachedToken
 	badgeSets map[string]cacheEntry
 	users     map[string]cacheEntry
+	profiles  map[string]cacheEntry
 
 	enriched sync.Map
 }
@@ -0,0 +1,88 @@
+package twitchbadges
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Stream describes a live Twitch broadcast as reported by Helix.
+type Stream struct {
+	ID        string
+	Login     string
+	Title     string
+	GameName  string
+	StartedAt time.Time
+	// ViewerCount is the concurrent viewer count Helix reported.
+	ViewerCount int
+	// ThumbnailURL is the 1280x720 live preview image.
+	ThumbnailURL string
+}
+
+// Stream reports the live broadcast for login, or ok=false when the channel is
+// offline. Results are not cached; callers poll at their own interval.
+func (r *Resolver) Stream(ctx context.Context, login string) (Stream, bool, error) {
+	if r == nil || strings.TrimSpace(r.ClientID) == "" || strings.TrimSpace(r.ClientSecret) == "" {
+		return Stream{}, false, fmt.Errorf("twitch client credentials not configured")
+	}
+	login = strings.ToLower(strings.TrimSpace(login))
+	if !ValidLogin(login) {
+		return Stream{}, false, fmt.Errorf("invalid twitch login %q", login)
+	}
+	token, err := r.appToken(ctx)
+	if err != nil {
+		return Stream{}, false, fmt.Errorf("app token: %w", err)
+	}
+
+	endpoint := strings.TrimSuffix(helixBaseURL, "/") + streamsPath + "?user_login=" + url.QueryEscape(login)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return Stream{}, false, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Client-Id", strings.TrimSpace(r.ClientID))
+
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return Stream{}, false, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return Stream{}, false, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var parsed struct {
+		Data []struct {
+			ID           string `json:"id"`
+			UserLogin    string `json:"user_login"`
+			Type         string `json:"type"`
+			Title        string `json:"title"`
+			GameName     string `json:"game_name"`
+			StartedAt    string `json:"started_at"`
+			ViewerCount  int    `json:"viewer_count"`
+			ThumbnailURL string `json:"thumbnail_url"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return Stream{}, false, fmt.Errorf("decode response: %w", err)
+	}
+	for _, d := range parsed.Data {
+		if d.ID == "" || d.Type != "live" {
+			continue
+		}
+		st := Stream{ID: d.ID, Login: strings.ToLower(d.UserLogin), Title: d.Title, GameName: d.GameName, ViewerCount: d.ViewerCount}
+		// Helix returns a template with {width}x{height} placeholders.
+		st.ThumbnailURL = strings.NewReplacer("{width}", "1280", "{height}", "720").Replace(d.ThumbnailURL)
+		if t, err := time.Parse(time.RFC3339, d.StartedAt); err == nil {
+			st.StartedAt = t.UTC()
+		}
+		return st, true, nil
+	}
+	return Stream{}, false, nil
+}
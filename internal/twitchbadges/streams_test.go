@@ -0,0 +1,55 @@
+package twitchbadges
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolverStream(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/oauth2/token", tokenResponder{count: &atomic.Int64{}})
+	mux.HandleFunc("/helix/streams", func(w http.ResponseWriter, r *http.Request) {
+		var data []map[string]any
+		if r.URL.Query().Get("user_login") == "elora" {
+			data = append(data, map[string]any{
+				"id":            "4001",
+				"user_login":    "elora",
+				"type":          "live",
+				"title":         "Tuesday stream",
+				"started_at":    "2026-10-13T18:00:00Z",
+				"viewer_count":  1234,
+				"game_name":     "Just Chatting",
+				"thumbnail_url": "https://static-cdn.jtvnw.net/previews-ttv/live_user_elora-{width}x{height}.jpg",
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": data})
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	helixBaseURL = srv.URL + "/helix"
+	oauthTokenURL = srv.URL + "/oauth2/token"
+
+	r := &Resolver{ClientID: "client", ClientSecret: "secret", HTTP: srv.Client()}
+
+	st, live, err := r.Stream(context.Background(), "Elora")
+	if err != nil || !live {
+		t.Fatalf("expected live stream, got live=%t err=%v", live, err)
+	}
+	if st.ID != "4001" || st.Title != "Tuesday stream" || !st.StartedAt.Equal(time.Date(2026, 10, 13, 18, 0, 0, 0, time.UTC)) || st.ViewerCount != 1234 {
+		t.Fatalf("unexpected stream %+v", st)
+	}
+	if st.GameName != "Just Chatting" || st.ThumbnailURL != "https://static-cdn.jtvnw.net/previews-ttv/live_user_elora-1280x720.jpg" {
+		t.Fatalf("unexpected stream %+v", st)
+	}
+
+	if _, live, err := r.Stream(context.Background(), "offline"); err != nil || live {
+		t.Fatalf("expected offline channel, got live=%t err=%v", live, err)
+	}
+}
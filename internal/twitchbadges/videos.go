@@ -0,0 +1,88 @@
+package twitchbadges
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Video is a past broadcast (VOD) as reported by Helix.
+type Video struct {
+	ID string
+	// UserID is the broadcaster's user ID.
+	UserID    string
+	CreatedAt time.Time
+	// Duration is the VOD length so far; it grows while the broadcast is
+	// still live.
+	Duration time.Duration
+}
+
+// End is when the VOD's recording currently stops.
+func (v Video) End() time.Time {
+	return v.CreatedAt.Add(v.Duration)
+}
+
+// Archives returns login's most recent past broadcasts, newest first.
+// Results are not cached; a VOD of a live broadcast keeps growing.
+func (r *Resolver) Archives(ctx context.Context, login string) ([]Video, error) {
+	login = strings.ToLower(strings.TrimSpace(login))
+	profiles, err := r.Profiles(ctx, []string{login})
+	if err != nil {
+		return nil, err
+	}
+	profile, ok := profiles[login]
+	if !ok || profile.ID == "" {
+		return nil, fmt.Errorf("twitch user %q not found", login)
+	}
+	token, err := r.appToken(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("app token: %w", err)
+	}
+
+	query := url.Values{"user_id": {profile.ID}, "type": {"archive"}, "first": {"20"}}
+	endpoint := strings.TrimSuffix(helixBaseURL, "/") + videosPath + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Client-Id", strings.TrimSpace(r.ClientID))
+
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var parsed struct {
+		Data []struct {
+			ID        string `json:"id"`
+			UserID    string `json:"user_id"`
+			CreatedAt string `json:"created_at"`
+			Duration  string `json:"duration"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	var out []Video
+	for _, d := range parsed.Data {
+		created, err := time.Parse(time.RFC3339, d.CreatedAt)
+		if d.ID == "" || err != nil {
+			continue
+		}
+		// Helix formats durations like "3h8m33s", which ParseDuration reads.
+		duration, _ := time.ParseDuration(d.Duration)
+		out = append(out, Video{ID: d.ID, UserID: d.UserID, CreatedAt: created.UTC(), Duration: duration})
+	}
+	return out, nil
+}
@@ -0,0 +1,46 @@
+package twitchbadges
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestResolverArchives(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/oauth2/token", tokenResponder{count: &atomic.Int64{}})
+	mux.HandleFunc("/helix/users", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"id": "77", "login": "elora"}}})
+	})
+	mux.HandleFunc("/helix/videos", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("user_id") != "77" || r.URL.Query().Get("type") != "archive" {
+			t.Errorf("unexpected query %s", r.URL.RawQuery)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{
+			{"id": "9001", "user_id": "77", "created_at": "2026-10-13T18:00:00Z", "duration": "3h8m33s"},
+			{"id": "", "created_at": "2026-10-12T18:00:00Z", "duration": "1h"},
+		}})
+	})
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	helixBaseURL = srv.URL + "/helix"
+	oauthTokenURL = srv.URL + "/oauth2/token"
+
+	r := &Resolver{ClientID: "client", ClientSecret: "secret", HTTP: srv.Client()}
+	videos, err := r.Archives(context.Background(), "Elora")
+	if err != nil {
+		t.Fatalf("archives: %v", err)
+	}
+	if len(videos) != 1 || videos[0].ID != "9001" || videos[0].UserID != "77" {
+		t.Fatalf("unexpected videos %+v", videos)
+	}
+	if want := time.Date(2026, 10, 13, 21, 8, 33, 0, time.UTC); !videos[0].End().Equal(want) {
+		t.Fatalf("End() = %s, want %s", videos[0].End(), want)
+	}
+}
@@ -0,0 +1,610 @@
+// Package twitcheventsub follows a broadcaster's polls, predictions, raids,
+// AutoMod holds and channel points redemptions over Twitch EventSub
+// WebSockets.
+package twitcheventsub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+const (
+	defaultWSURL    = "wss://eventsub.wss.twitch.tv/ws"
+	defaultHelixURL = "https://api.twitch.tv/helix"
+
+	// keepaliveSlack is added to the session keepalive before a silent
+	// connection is considered dead.
+	keepaliveSlack   = 10 * time.Second
+	defaultKeepalive = 30 * time.Second
+	maxBackoff       = 60 * time.Second
+)
+
+// pollTypes are the poll and prediction topics. They need a broadcaster
+// token with the channel:read:polls and channel:read:predictions scopes.
+var pollTypes = []string{
+	"channel.poll.begin",
+	"channel.poll.progress",
+	"channel.poll.end",
+	"channel.prediction.begin",
+	"channel.prediction.progress",
+	"channel.prediction.lock",
+	"channel.prediction.end",
+}
+
+// autoModTypes are the AutoMod topics. They need a broadcaster token with
+// the moderator:manage:automod scope.
+var autoModTypes = []string{
+	"automod.message.hold",
+	"automod.message.update",
+}
+
+// redemptionType is the channel points redemption topic. It needs a
+// broadcaster token with the channel:read:redemptions scope.
+const redemptionType = "channel.channel_points_custom_reward_redemption.add"
+
+// subscription is one EventSub topic and the condition field naming the
+// broadcaster. Moderator topics also name the token's user (the
+// broadcaster) as moderator_user_id.
+type subscription struct {
+	Type         string
+	ConditionKey string
+	Moderator    bool
+}
+
+// PollHandler receives every poll and prediction update.
+type PollHandler func(core.Poll)
+
+// RaidHandler receives raids into and out of the channel.
+type RaidHandler func(core.Raid)
+
+// AutoModHandler receives messages held by AutoMod and their resolution.
+type AutoModHandler func(core.AutoModEvent)
+
+// RedemptionHandler receives channel points redemptions.
+type RedemptionHandler func(core.Redemption)
+
+// Config configures a Client.
+type Config struct {
+	ClientID string
+	// Channel is the broadcaster login whose polls are followed.
+	Channel string
+	// Token returns the current user access token ("oauth:" prefix
+	// optional). It must belong to the broadcaster.
+	Token func() string
+	// OnPoll, OnRaid, OnAutoMod and OnRedemption select the topics: polls
+	// and predictions need OnPoll, channel.raid (both directions) needs
+	// OnRaid, automod.message.* needs OnAutoMod and reward redemptions need
+	// OnRedemption.
+	OnPoll       PollHandler
+	OnRaid       RaidHandler
+	OnAutoMod    AutoModHandler
+	OnRedemption RedemptionHandler
+	// OnError, when set, receives each session failure tagged with its
+	// core.ErrorKind before the client reconnects.
+	OnError func(error)
+
+	// WSURL and HelixURL override the Twitch endpoints (tests).
+	WSURL      string
+	HelixURL   string
+	HTTPClient *http.Client
+}
+
+// Client maintains an EventSub WebSocket session, resubscribing whenever a
+// new session starts.
+type Client struct {
+	cfg  Config
+	http *http.Client
+
+	mu            sync.Mutex
+	broadcasterID string
+}
+
+// New returns a client for cfg.
+func New(cfg Config) *Client {
+	if cfg.WSURL == "" {
+		cfg.WSURL = defaultWSURL
+	}
+	if cfg.HelixURL == "" {
+		cfg.HelixURL = defaultHelixURL
+	}
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &Client{cfg: cfg, http: httpClient}
+}
+
+// Run connects and delivers poll events until ctx is cancelled,
+// reconnecting with exponential backoff.
+func (c *Client) Run(ctx context.Context) error {
+	if strings.TrimSpace(c.cfg.ClientID) == "" || c.cfg.Token == nil {
+		return errors.New("twitcheventsub: client id and token are required")
+	}
+	backoff := time.Second
+	wsURL, subscribe := c.cfg.WSURL, true
+	for {
+		next, welcomed, err := c.session(ctx, wsURL, subscribe)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if next != "" {
+			// Subscriptions carry over to the session at reconnect_url.
+			wsURL, subscribe = next, false
+			continue
+		}
+		wsURL, subscribe = c.cfg.WSURL, true
+		if welcomed {
+			backoff = time.Second
+		}
+		log.Printf("twitcheventsub: session ended: %v; reconnecting in %s", err, backoff)
+		if c.cfg.OnError != nil && err != nil {
+			c.cfg.OnError(fmt.Errorf("twitcheventsub: %w", err))
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// subscriptions lists the topics implied by the configured handlers.
+func (c *Client) subscriptions() []subscription {
+	var subs []subscription
+	if c.cfg.OnPoll != nil {
+		for _, t := range pollTypes {
+			subs = append(subs, subscription{Type: t, ConditionKey: "broadcaster_user_id"})
+		}
+	}
+	if c.cfg.OnRaid != nil {
+		subs = append(subs,
+			subscription{Type: "channel.raid", ConditionKey: "to_broadcaster_user_id"},
+			subscription{Type: "channel.raid", ConditionKey: "from_broadcaster_user_id"},
+		)
+	}
+	if c.cfg.OnAutoMod != nil {
+		for _, t := range autoModTypes {
+			subs = append(subs, subscription{Type: t, ConditionKey: "broadcaster_user_id", Moderator: true})
+		}
+	}
+	if c.cfg.OnRedemption != nil {
+		subs = append(subs, subscription{Type: redemptionType, ConditionKey: "broadcaster_user_id"})
+	}
+	return subs
+}
+
+type envelope struct {
+	Metadata struct {
+		MessageID        string    `json:"message_id"`
+		MessageType      string    `json:"message_type"`
+		MessageTimestamp time.Time `json:"message_timestamp"`
+		SubscriptionType string    `json:"subscription_type"`
+	} `json:"metadata"`
+	Payload struct {
+		Session struct {
+			ID                      string `json:"id"`
+			KeepaliveTimeoutSeconds int    `json:"keepalive_timeout_seconds"`
+			ReconnectURL            string `json:"reconnect_url"`
+		} `json:"session"`
+		Subscription struct {
+			Type   string `json:"type"`
+			Status string `json:"status"`
+		} `json:"subscription"`
+		Event json.RawMessage `json:"event"`
+	} `json:"payload"`
+}
+
+// session runs one WebSocket connection. It returns the URL to reconnect to
+// when Twitch asks the client to move, and whether a welcome was received.
+func (c *Client) session(ctx context.Context, wsURL string, subscribe bool) (string, bool, error) {
+	conn, _, err := websocket.Dial(ctx, wsURL, &websocket.DialOptions{HTTPClient: c.http})
+	if err != nil {
+		return "", false, core.NewError(core.ErrorNetwork, fmt.Errorf("dial: %w", err))
+	}
+	defer conn.Close(websocket.StatusNormalClosure, "")
+	conn.SetReadLimit(1 << 20)
+
+	keepalive := defaultKeepalive
+	welcomed := false
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, keepalive+keepaliveSlack)
+		_, data, err := conn.Read(readCtx)
+		cancel()
+		if err != nil {
+			return "", welcomed, core.NewError(core.ErrorNetwork, fmt.Errorf("read: %w", err))
+		}
+		var msg envelope
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return "", welcomed, core.NewError(core.ErrorParse, fmt.Errorf("decode message: %w", err))
+		}
+		switch msg.Metadata.MessageType {
+		case "session_welcome":
+			welcomed = true
+			if secs := msg.Payload.Session.KeepaliveTimeoutSeconds; secs > 0 {
+				keepalive = time.Duration(secs) * time.Second
+			}
+			if subscribe {
+				if err := c.subscribe(ctx, msg.Payload.Session.ID); err != nil {
+					return "", welcomed, err
+				}
+				log.Printf("twitcheventsub: subscribed to %d topics for %s", len(c.subscriptions()), c.cfg.Channel)
+			}
+		case "session_keepalive":
+		case "notification":
+			subType := msg.Metadata.SubscriptionType
+			if subType == "" {
+				subType = msg.Payload.Subscription.Type
+			}
+			if subType == "channel.raid" {
+				if raid, ok := parseRaid(msg.Payload.Event, c.login(), msg.Metadata.MessageID, msg.Metadata.MessageTimestamp); ok && c.cfg.OnRaid != nil {
+					c.cfg.OnRaid(raid)
+				}
+				continue
+			}
+			if strings.HasPrefix(subType, "automod.message.") {
+				if ev, ok := parseAutoMod(subType, msg.Payload.Event, msg.Metadata.MessageTimestamp); ok && c.cfg.OnAutoMod != nil {
+					c.cfg.OnAutoMod(ev)
+				}
+				continue
+			}
+			if subType == redemptionType {
+				if r, ok := parseRedemption(msg.Payload.Event, msg.Metadata.MessageTimestamp); ok && c.cfg.OnRedemption != nil {
+					c.cfg.OnRedemption(r)
+				}
+				continue
+			}
+			if poll, ok := parseEvent(subType, msg.Payload.Event); ok && c.cfg.OnPoll != nil {
+				c.cfg.OnPoll(poll)
+			}
+		case "session_reconnect":
+			if next := msg.Payload.Session.ReconnectURL; next != "" {
+				return next, welcomed, nil
+			}
+		case "revocation":
+			log.Printf("twitcheventsub: subscription %s revoked: %s", msg.Payload.Subscription.Type, msg.Payload.Subscription.Status)
+		}
+	}
+}
+
+func (c *Client) login() string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(c.cfg.Channel), "#"))
+}
+
+func (c *Client) token() string {
+	return strings.TrimPrefix(strings.TrimSpace(c.cfg.Token()), "oauth:")
+}
+
+func (c *Client) helixRequest(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.cfg.HelixURL, "/")+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token())
+	req.Header.Set("Client-Id", strings.TrimSpace(c.cfg.ClientID))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return c.http.Do(req)
+}
+
+// statusErrorKind classifies a failed Helix response.
+func statusErrorKind(status int) core.ErrorKind {
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return core.ErrorAuth
+	}
+	return core.ErrorNetwork
+}
+
+func (c *Client) lookupBroadcasterID(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	id := c.broadcasterID
+	c.mu.Unlock()
+	if id != "" {
+		return id, nil
+	}
+	login := c.login()
+	resp, err := c.helixRequest(ctx, http.MethodGet, "/users?login="+url.QueryEscape(login), nil)
+	if err != nil {
+		return "", core.NewError(core.ErrorNetwork, fmt.Errorf("lookup broadcaster: %w", err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		err := fmt.Errorf("lookup broadcaster: status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		return "", core.NewError(statusErrorKind(resp.StatusCode), err)
+	}
+	var parsed struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return "", core.NewError(core.ErrorParse, fmt.Errorf("lookup broadcaster: decode: %w", err))
+	}
+	if len(parsed.Data) == 0 || parsed.Data[0].ID == "" {
+		return "", fmt.Errorf("lookup broadcaster: unknown login %q", login)
+	}
+	c.mu.Lock()
+	c.broadcasterID = parsed.Data[0].ID
+	c.mu.Unlock()
+	return parsed.Data[0].ID, nil
+}
+
+func (c *Client) subscribe(ctx context.Context, sessionID string) error {
+	broadcasterID, err := c.lookupBroadcasterID(ctx)
+	if err != nil {
+		return err
+	}
+	for _, sub := range c.subscriptions() {
+		subType := sub.Type
+		condition := map[string]string{sub.ConditionKey: broadcasterID}
+		if sub.Moderator {
+			condition["moderator_user_id"] = broadcasterID
+		}
+		resp, err := c.helixRequest(ctx, http.MethodPost, "/eventsub/subscriptions", map[string]any{
+			"type":      subType,
+			"version":   "1",
+			"condition": condition,
+			"transport": map[string]string{"method": "websocket", "session_id": sessionID},
+		})
+		if err != nil {
+			return core.NewError(core.ErrorNetwork, fmt.Errorf("subscribe %s: %w", subType, err))
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusAccepted || resp.StatusCode == http.StatusConflict:
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			return core.NewError(core.ErrorAuth, fmt.Errorf("subscribe %s: status %d (the token must belong to the broadcaster and have %s): %s",
+				subType, resp.StatusCode, requiredScope(subType), strings.TrimSpace(string(body))))
+		default:
+			return core.NewError(core.ErrorNetwork, fmt.Errorf("subscribe %s: status %d: %s", subType, resp.StatusCode, strings.TrimSpace(string(body))))
+		}
+	}
+	return nil
+}
+
+// requiredScope names the token scope a topic needs, for error messages.
+func requiredScope(subType string) string {
+	switch {
+	case strings.HasPrefix(subType, "channel.poll."):
+		return "channel:read:polls"
+	case strings.HasPrefix(subType, "channel.prediction."):
+		return "channel:read:predictions"
+	case strings.HasPrefix(subType, "automod."):
+		return "moderator:manage:automod"
+	case subType == redemptionType:
+		return "channel:read:redemptions"
+	}
+	return "the scopes the topic requires"
+}
+
+type eventChoice struct {
+	ID            string `json:"id"`
+	Title         string `json:"title"`
+	Votes         int    `json:"votes"`
+	Users         int    `json:"users"`
+	ChannelPoints int    `json:"channel_points"`
+}
+
+type pollEvent struct {
+	ID                   string        `json:"id"`
+	BroadcasterUserLogin string        `json:"broadcaster_user_login"`
+	Title                string        `json:"title"`
+	Choices              []eventChoice `json:"choices"`
+	Outcomes             []eventChoice `json:"outcomes"`
+	Status               string        `json:"status"`
+	WinningOutcomeID     string        `json:"winning_outcome_id"`
+	StartedAt            time.Time     `json:"started_at"`
+	EndedAt              *time.Time    `json:"ended_at"`
+}
+
+// parseEvent converts a channel.poll.* or channel.prediction.* event.
+func parseEvent(subType string, raw json.RawMessage) (core.Poll, bool) {
+	var kind string
+	switch {
+	case strings.HasPrefix(subType, "channel.poll."):
+		kind = core.PollKindPoll
+	case strings.HasPrefix(subType, "channel.prediction."):
+		kind = core.PollKindPrediction
+	default:
+		return core.Poll{}, false
+	}
+	var ev pollEvent
+	if err := json.Unmarshal(raw, &ev); err != nil || ev.ID == "" {
+		return core.Poll{}, false
+	}
+	poll := core.Poll{
+		Platform:        "Twitch",
+		Channel:         ev.BroadcasterUserLogin,
+		ID:              ev.ID,
+		Kind:            kind,
+		Title:           ev.Title,
+		WinningOptionID: ev.WinningOutcomeID,
+		StartedAt:       ev.StartedAt.UTC(),
+	}
+	switch subType[strings.LastIndex(subType, ".")+1:] {
+	case "begin", "progress":
+		poll.Status = "active"
+	case "lock":
+		poll.Status = "locked"
+	case "end":
+		poll.Status = strings.ToLower(ev.Status)
+		if poll.Status == "" {
+			poll.Status = "ended"
+		}
+		if ev.EndedAt != nil {
+			poll.EndedAt = ev.EndedAt.UTC()
+		}
+	}
+	choices := ev.Choices
+	if kind == core.PollKindPrediction {
+		choices = ev.Outcomes
+	}
+	for _, ch := range choices {
+		opt := core.PollOption{ID: ch.ID, Title: ch.Title, Votes: ch.Votes}
+		if kind == core.PollKindPrediction {
+			opt.Votes = ch.Users
+			opt.ChannelPoints = ch.ChannelPoints
+		}
+		poll.Options = append(poll.Options, opt)
+	}
+	return poll, true
+}
+
+type raidEvent struct {
+	FromLogin string `json:"from_broadcaster_user_login"`
+	FromName  string `json:"from_broadcaster_user_name"`
+	ToLogin   string `json:"to_broadcaster_user_login"`
+	ToName    string `json:"to_broadcaster_user_name"`
+	Viewers   int    `json:"viewers"`
+}
+
+// parseRaid converts a channel.raid event seen while following channel.
+func parseRaid(raw json.RawMessage, channel, id string, ts time.Time) (core.Raid, bool) {
+	var ev raidEvent
+	if err := json.Unmarshal(raw, &ev); err != nil || ev.FromLogin == "" || ev.ToLogin == "" {
+		return core.Raid{}, false
+	}
+	raid := core.Raid{
+		Platform:    "Twitch",
+		ID:          id,
+		Channel:     channel,
+		Direction:   core.RaidIncoming,
+		FromChannel: strings.ToLower(ev.FromLogin),
+		FromName:    ev.FromName,
+		ToChannel:   strings.ToLower(ev.ToLogin),
+		ToName:      ev.ToName,
+		Viewers:     ev.Viewers,
+		Ts:          ts.UTC(),
+	}
+	if raid.FromChannel == channel {
+		raid.Direction = core.RaidOutgoing
+	}
+	return raid, true
+}
+
+type autoModEvent struct {
+	BroadcasterLogin string `json:"broadcaster_user_login"`
+	UserID           string `json:"user_id"`
+	UserLogin        string `json:"user_login"`
+	MessageID        string `json:"message_id"`
+	Message          struct {
+		Text string `json:"text"`
+	} `json:"message"`
+	Category       string    `json:"category"`
+	Level          int       `json:"level"`
+	Status         string    `json:"status"`
+	ModeratorLogin string    `json:"moderator_user_login"`
+	HeldAt         time.Time `json:"held_at"`
+}
+
+// parseAutoMod converts an automod.message.hold or automod.message.update
+// event delivered at ts.
+func parseAutoMod(subType string, raw json.RawMessage, ts time.Time) (core.AutoModEvent, bool) {
+	var ev autoModEvent
+	if err := json.Unmarshal(raw, &ev); err != nil || ev.MessageID == "" {
+		return core.AutoModEvent{}, false
+	}
+	out := core.AutoModEvent{
+		Platform:  "Twitch",
+		Channel:   strings.ToLower(ev.BroadcasterLogin),
+		MessageID: ev.MessageID,
+		UserID:    ev.UserID,
+		Username:  ev.UserLogin,
+		Text:      ev.Message.Text,
+		Status:    core.AutoModHeld,
+		Reason:    ev.Category,
+		Level:     ev.Level,
+		HeldAt:    ev.HeldAt.UTC(),
+	}
+	if out.HeldAt.IsZero() {
+		out.HeldAt = ts.UTC()
+	}
+	if subType == "automod.message.update" {
+		switch strings.ToLower(ev.Status) {
+		case "approved":
+			out.Status = core.AutoModApproved
+		case "denied":
+			out.Status = core.AutoModDenied
+		case "expired":
+			out.Status = core.AutoModExpired
+		default:
+			return core.AutoModEvent{}, false
+		}
+		out.Moderator = strings.ToLower(ev.ModeratorLogin)
+		out.ResolvedAt = ts.UTC()
+		if out.ResolvedAt.IsZero() {
+			out.ResolvedAt = time.Now().UTC()
+		}
+	}
+	return out, true
+}
+
+type redemptionEvent struct {
+	ID               string `json:"id"`
+	BroadcasterLogin string `json:"broadcaster_user_login"`
+	UserID           string `json:"user_id"`
+	UserLogin        string `json:"user_login"`
+	UserInput        string `json:"user_input"`
+	Status           string `json:"status"`
+	Reward           struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+		Cost  int    `json:"cost"`
+	} `json:"reward"`
+	RedeemedAt time.Time `json:"redeemed_at"`
+}
+
+// parseRedemption converts a reward redemption event delivered at ts.
+func parseRedemption(raw json.RawMessage, ts time.Time) (core.Redemption, bool) {
+	var ev redemptionEvent
+	if err := json.Unmarshal(raw, &ev); err != nil || ev.ID == "" {
+		return core.Redemption{}, false
+	}
+	out := core.Redemption{
+		Platform:    "Twitch",
+		Channel:     strings.ToLower(ev.BroadcasterLogin),
+		ID:          ev.ID,
+		RewardID:    ev.Reward.ID,
+		RewardTitle: ev.Reward.Title,
+		Cost:        ev.Reward.Cost,
+		UserID:      ev.UserID,
+		Username:    ev.UserLogin,
+		UserInput:   ev.UserInput,
+		Status:      strings.ToLower(ev.Status),
+		RedeemedAt:  ev.RedeemedAt.UTC(),
+	}
+	if out.RedeemedAt.IsZero() {
+		out.RedeemedAt = ts.UTC()
+	}
+	return out, true
+}
@@ -0,0 +1,196 @@
+package twitcheventsub
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nhooyr.io/websocket"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestParseEvent(t *testing.T) {
+	pollEnd := `{"id":"p1","broadcaster_user_login":"elora","title":"Next game?","choices":[
+		{"id":"c1","title":"Celeste","votes":12},{"id":"c2","title":"Hades","votes":30}],
+		"status":"completed","started_at":"2024-05-01T20:00:00Z","ended_at":"2024-05-01T20:02:00Z"}`
+	poll, ok := parseEvent("channel.poll.end", json.RawMessage(pollEnd))
+	if !ok {
+		t.Fatalf("expected poll end to parse")
+	}
+	want := core.Poll{
+		Platform: "Twitch", Channel: "elora", ID: "p1", Kind: core.PollKindPoll, Title: "Next game?", Status: "completed",
+		Options:   []core.PollOption{{ID: "c1", Title: "Celeste", Votes: 12}, {ID: "c2", Title: "Hades", Votes: 30}},
+		StartedAt: time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC),
+		EndedAt:   time.Date(2024, 5, 1, 20, 2, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(poll, want) {
+		t.Fatalf("unexpected poll\n got %+v\nwant %+v", poll, want)
+	}
+
+	predictionLock := `{"id":"r1","broadcaster_user_login":"elora","title":"Win?","outcomes":[
+		{"id":"o1","title":"Yes","users":10,"channel_points":5000},{"id":"o2","title":"No","users":4,"channel_points":800}],
+		"started_at":"2024-05-01T20:00:00Z","locked_at":"2024-05-01T20:05:00Z"}`
+	pred, ok := parseEvent("channel.prediction.lock", json.RawMessage(predictionLock))
+	if !ok || pred.Kind != core.PollKindPrediction || pred.Status != "locked" || !pred.EndedAt.IsZero() {
+		t.Fatalf("unexpected prediction %+v", pred)
+	}
+	if len(pred.Options) != 2 || pred.Options[0].Votes != 10 || pred.Options[0].ChannelPoints != 5000 {
+		t.Fatalf("unexpected outcomes %+v", pred.Options)
+	}
+
+	resolved, ok := parseEvent("channel.prediction.end", json.RawMessage(`{"id":"r1","status":"resolved","winning_outcome_id":"o1","outcomes":[]}`))
+	if !ok || resolved.Status != "resolved" || resolved.WinningOptionID != "o1" {
+		t.Fatalf("unexpected resolved prediction %+v", resolved)
+	}
+
+	if _, ok := parseEvent("channel.follow", json.RawMessage(`{"id":"x"}`)); ok {
+		t.Fatalf("expected unrelated subscription types to be ignored")
+	}
+}
+
+func TestParseAutoMod(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 20, 0, 5, 0, time.UTC)
+	hold := `{"broadcaster_user_login":"Elora","user_id":"42","user_login":"troll","message_id":"m1",
+		"message":{"text":"rude words","fragments":[]},"category":"swearing","level":3,"held_at":"2024-05-01T20:00:00Z"}`
+	ev, ok := parseAutoMod("automod.message.hold", json.RawMessage(hold), ts)
+	want := core.AutoModEvent{Platform: "Twitch", Channel: "elora", MessageID: "m1", UserID: "42", Username: "troll", Text: "rude words",
+		Status: core.AutoModHeld, Reason: "swearing", Level: 3, HeldAt: time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)}
+	if !ok || !reflect.DeepEqual(ev, want) {
+		t.Fatalf("unexpected hold\n got %+v\nwant %+v", ev, want)
+	}
+
+	update := `{"broadcaster_user_login":"elora","user_login":"troll","message_id":"m1","moderator_user_login":"Mod","status":"Denied",
+		"category":"swearing","level":3,"held_at":"2024-05-01T20:00:00Z","message":{"text":"rude words"}}`
+	ev, ok = parseAutoMod("automod.message.update", json.RawMessage(update), ts)
+	if !ok || ev.Status != core.AutoModDenied || ev.Moderator != "mod" || !ev.ResolvedAt.Equal(ts) {
+		t.Fatalf("unexpected update %+v", ev)
+	}
+
+	if _, ok := parseAutoMod("automod.message.update", json.RawMessage(`{"message_id":"m1","status":"Unknown"}`), ts); ok {
+		t.Fatalf("expected an unknown status to be ignored")
+	}
+}
+
+func TestParseRedemption(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 20, 0, 5, 0, time.UTC)
+	raw := `{"id":"r1","broadcaster_user_login":"Elora","user_id":"42","user_login":"amy","user_name":"Amy","user_input":"play it again",
+		"status":"UNFULFILLED","reward":{"id":"rw1","title":"Song request","cost":500,"prompt":"Name a song"},"redeemed_at":"2024-05-01T20:00:00Z"}`
+	r, ok := parseRedemption(json.RawMessage(raw), ts)
+	want := core.Redemption{Platform: "Twitch", Channel: "elora", ID: "r1", RewardID: "rw1", RewardTitle: "Song request", Cost: 500,
+		UserID: "42", Username: "amy", UserInput: "play it again", Status: "unfulfilled", RedeemedAt: time.Date(2024, 5, 1, 20, 0, 0, 0, time.UTC)}
+	if !ok || !reflect.DeepEqual(r, want) {
+		t.Fatalf("unexpected redemption\n got %+v\nwant %+v", r, want)
+	}
+	if _, ok := parseRedemption(json.RawMessage(`{"user_login":"amy"}`), ts); ok {
+		t.Fatalf("expected a redemption without an id to be ignored")
+	}
+}
+
+func TestClientSubscribesAndDeliversPolls(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		subs []string
+	)
+	helix := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer usertoken" {
+			t.Errorf("unexpected authorization %q", got)
+		}
+		switch r.URL.Path {
+		case "/users":
+			_, _ = w.Write([]byte(`{"data":[{"id":"1234"}]}`))
+		case "/eventsub/subscriptions":
+			var body struct {
+				Type      string            `json:"type"`
+				Condition map[string]string `json:"condition"`
+				Transport map[string]string `json:"transport"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body.Condition["broadcaster_user_id"] != "1234" || body.Transport["session_id"] != "sess-1" {
+				t.Errorf("unexpected subscription %+v", body)
+			}
+			mu.Lock()
+			subs = append(subs, body.Type)
+			mu.Unlock()
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer helix.Close()
+
+	ws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close(websocket.StatusNormalClosure, "")
+		ctx := r.Context()
+		_ = conn.Write(ctx, websocket.MessageText, []byte(`{"metadata":{"message_type":"session_welcome"},"payload":{"session":{"id":"sess-1","keepalive_timeout_seconds":10}}}`))
+		// Wait for the client to subscribe before notifying.
+		for i := 0; i < 100; i++ {
+			mu.Lock()
+			n := len(subs)
+			mu.Unlock()
+			if n == len(pollTypes) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		_ = conn.Write(ctx, websocket.MessageText, []byte(`{"metadata":{"message_type":"notification","subscription_type":"channel.poll.begin"},
+			"payload":{"subscription":{"type":"channel.poll.begin"},"event":{"id":"p1","broadcaster_user_login":"elora","title":"Q","choices":[{"id":"c1","title":"A"}],"started_at":"2024-05-01T20:00:00Z"}}}`))
+		<-ctx.Done()
+	}))
+	defer ws.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got := make(chan core.Poll, 1)
+	c := New(Config{
+		ClientID: "cid",
+		Channel:  "#Elora",
+		Token:    func() string { return "oauth:usertoken" },
+		OnPoll:   func(p core.Poll) { got <- p },
+		WSURL:    "ws" + strings.TrimPrefix(ws.URL, "http"),
+		HelixURL: helix.URL,
+	})
+	go func() { _ = c.Run(ctx) }()
+
+	select {
+	case p := <-got:
+		if p.ID != "p1" || p.Status != "active" || len(p.Options) != 1 {
+			t.Fatalf("unexpected poll %+v", p)
+		}
+	case <-ctx.Done():
+		t.Fatalf("timed out waiting for poll")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(subs) != len(pollTypes) {
+		t.Fatalf("expected %d subscriptions, got %v", len(pollTypes), subs)
+	}
+}
+
+func TestParseRaid(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 21, 0, 0, 0, time.UTC)
+	in := `{"from_broadcaster_user_id":"1","from_broadcaster_user_login":"friend","from_broadcaster_user_name":"Friend",
+		"to_broadcaster_user_id":"2","to_broadcaster_user_login":"elora","to_broadcaster_user_name":"Elora","viewers":42}`
+	raid, ok := parseRaid(json.RawMessage(in), "elora", "msg-1", ts)
+	want := core.Raid{Platform: "Twitch", ID: "msg-1", Channel: "elora", Direction: core.RaidIncoming, FromChannel: "friend", FromName: "Friend",
+		ToChannel: "elora", ToName: "Elora", Viewers: 42, Ts: ts}
+	if !ok || !reflect.DeepEqual(raid, want) {
+		t.Fatalf("parseRaid = %+v, %v; want %+v", raid, ok, want)
+	}
+
+	out := `{"from_broadcaster_user_login":"elora","to_broadcaster_user_login":"friend","viewers":100}`
+	raid, ok = parseRaid(json.RawMessage(out), "elora", "msg-2", ts)
+	if !ok || raid.Direction != core.RaidOutgoing || raid.ToChannel != "friend" {
+		t.Fatalf("unexpected outgoing raid %+v", raid)
+	}
+}
@@ -12,14 +12,21 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"golang.org/x/time/rate"
+
+	"github.com/you/gnasty-chat/internal/backoff"
 	"github.com/you/gnasty-chat/internal/core"
 	"github.com/you/gnasty-chat/internal/ingesttrace"
 )
 
 type Config struct {
-	Channel       string
+	Channel string
+	// Channels lists further channels joined on the same connection. Use
+	// a Pool to spread large channel counts across several connections.
+	Channels      []string
 	Nick          string
 	Token         string
 	UseTLS        bool
@@ -27,6 +34,39 @@ type Config struct {
 	RefreshNow    func(context.Context) (string, error)
 	Addr          string
 	Badges        BadgeResolver
+	// Sender, when set, delivers queued outbound messages over this
+	// connection and receives ROOMSTATE updates.
+	Sender *Sender
+	// OnRoomState, when set, receives the merged room settings after every
+	// ROOMSTATE update.
+	OnRoomState func(channel string, state RoomState)
+	// OnDelete, when set, receives messages removed by moderation
+	// (CLEARMSG and CLEARCHAT).
+	OnDelete func(core.MessageDeletion)
+	// OnRaid, when set, receives incoming raids (USERNOTICE msg-id=raid).
+	OnRaid func(core.Raid)
+	// Whispers passes WHISPERs addressed to Nick to the handler as
+	// core.MessageTypeWhisper messages instead of dropping them.
+	Whispers bool
+	// OnPresence, when set, receives chatters joining and leaving (JOIN
+	// and PART), excluding the client's own nick.
+	OnPresence func(core.Presence)
+	// OnGap, when set, receives the window in which a channel's chat may
+	// have been missed, from the last line read before a disconnect until
+	// the channel is joined again.
+	OnGap func(channel string, start, end time.Time)
+	// OnError, when set, receives connection and authentication failures
+	// tagged with their core.ErrorKind before each retry.
+	OnError func(error)
+	// Backoff paces reconnects and token refresh retries.
+	Backoff backoff.Policy
+	// JoinLimiter paces JOIN commands. Twitch limits joins per account, so
+	// clients sharing a login should share a limiter; nil uses a private
+	// one allowing 20 joins per 10 seconds.
+	JoinLimiter *rate.Limiter
+	// Dial, when set, opens the TCP connection (for example through a
+	// proxy); TLS is layered on top when UseTLS is set.
+	Dial func(ctx context.Context, network, addr string) (net.Conn, error)
 }
 
 type Handler func(core.ChatMessage, *ingesttrace.MessageTrace)
@@ -39,25 +79,187 @@ type BadgeResolver interface {
 
 const badgeEnrichTimeout = 2 * time.Second
 
+// joinRate and joinBurst match Twitch's JOIN limit of 20 per 10 seconds
+// for regular accounts.
+const (
+	joinRate  = rate.Limit(20.0 / 10.0)
+	joinBurst = 20
+)
+
+// NewJoinLimiter returns a limiter enforcing Twitch's JOIN rate limit.
+func NewJoinLimiter() *rate.Limiter {
+	return rate.NewLimiter(joinRate, joinBurst)
+}
+
 type Client struct {
-	cfg    Config
-	handle Handler
-	badges BadgeResolver
+	cfg     Config
+	handle  Handler
+	badges  BadgeResolver
+	limiter *rate.Limiter
+
+	mu       sync.Mutex
+	channels []string
+	send     func(string) error
+	connCtx  context.Context
+
+	// lastRead and gapStart track disconnect gaps; only Run's goroutine
+	// touches them.
+	lastRead time.Time
+	gapStart map[string]time.Time
 }
 
 var errAuthFailed = errors.New("twitchirc: authentication failed")
 
 func New(cfg Config, h Handler) *Client {
-	return &Client{cfg: cfg, handle: h, badges: cfg.Badges}
+	c := &Client{cfg: cfg, handle: h, badges: cfg.Badges, limiter: cfg.JoinLimiter}
+	if c.limiter == nil {
+		c.limiter = NewJoinLimiter()
+	}
+	for _, channel := range append([]string{cfg.Channel}, cfg.Channels...) {
+		if channel = normalizeChannel(channel); channel != "" && !c.joined(channel) {
+			c.channels = append(c.channels, channel)
+		}
+	}
+	return c
+}
+
+// normalizeChannel lowercases a channel name and strips any leading "#".
+func normalizeChannel(channel string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(channel), "#"))
+}
+
+// Channels returns the channels the client joins, in join order.
+func (c *Client) Channels() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.channels...)
+}
+
+// joined reports whether channel is in the join list. Callers outside New
+// must hold c.mu.
+func (c *Client) joined(channel string) bool {
+	for _, existing := range c.channels {
+		if existing == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// Join adds channel to the join list. While connected the JOIN is sent in
+// the background once the join limiter allows it; otherwise it happens on
+// the next connect.
+func (c *Client) Join(channel string) error {
+	channel = normalizeChannel(channel)
+	if channel == "" {
+		return errors.New("twitchirc: channel is required")
+	}
+	c.mu.Lock()
+	if c.joined(channel) {
+		c.mu.Unlock()
+		return nil
+	}
+	c.channels = append(c.channels, channel)
+	send, ctx := c.send, c.connCtx
+	c.mu.Unlock()
+	if send != nil {
+		go c.joinChannels(ctx, send, []string{channel})
+	}
+	return nil
+}
+
+// Part removes channel from the join list, leaving it if connected.
+func (c *Client) Part(channel string) error {
+	channel = normalizeChannel(channel)
+	c.mu.Lock()
+	idx := -1
+	for i, existing := range c.channels {
+		if existing == channel {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		c.mu.Unlock()
+		return nil
+	}
+	c.channels = append(c.channels[:idx], c.channels[idx+1:]...)
+	send := c.send
+	c.mu.Unlock()
+	if send == nil {
+		return nil
+	}
+	return send("PART #" + channel)
+}
+
+// joinChannels sends JOINs for channels still in the join list, pacing
+// them with the limiter. It runs alongside the read loop so chat from the
+// first channels flows while later JOINs wait.
+func (c *Client) joinChannels(ctx context.Context, send func(string) error, channels []string) {
+	for _, channel := range channels {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return
+		}
+		c.mu.Lock()
+		still := c.joined(channel)
+		c.mu.Unlock()
+		if !still {
+			continue
+		}
+		if err := send("JOIN #" + channel); err != nil {
+			return
+		}
+		log.Printf("twitchirc: joined #%s as %s", channel, c.cfg.Nick)
+	}
+}
+
+// attach records send as the live connection's writer for Join and Part;
+// ctx ends with the connection.
+func (c *Client) attach(ctx context.Context, send func(string) error) {
+	c.mu.Lock()
+	c.send, c.connCtx = send, ctx
+	c.mu.Unlock()
+}
+
+func (c *Client) detach() {
+	c.mu.Lock()
+	c.send, c.connCtx = nil, nil
+	c.mu.Unlock()
+}
+
+// lineChannel returns the joined channel a PRIVMSG line was sent to,
+// falling back to the configured channel.
+func (c *Client) lineChannel(line string) string {
+	if target := normalizeChannel(privmsgChannel(line)); target != "" {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.joined(target) {
+			return target
+		}
+	}
+	return c.cfg.Channel
+}
+
+// reportError passes err to Config.OnError, keeping any kind it already
+// carries.
+func (c *Client) reportError(kind core.ErrorKind, err error) {
+	if c.cfg.OnError == nil {
+		return
+	}
+	var tagged *core.Error
+	if !errors.As(err, &tagged) {
+		err = core.NewError(kind, err)
+	}
+	c.cfg.OnError(err)
 }
 
 func (c *Client) Run(ctx context.Context) error {
-	if strings.TrimSpace(c.cfg.Channel) == "" || strings.TrimSpace(c.cfg.Nick) == "" {
+	if len(c.Channels()) == 0 || strings.TrimSpace(c.cfg.Nick) == "" {
 		return errors.New("twitchirc: channel and nick are required")
 	}
 
-	backoff := time.Second
-	refreshBackoff := time.Second
+	retry := backoff.New(c.cfg.Backoff)
+	refreshRetry := backoff.New(c.cfg.Backoff)
 	for {
 		if ctx.Err() != nil {
 			return ctx.Err()
@@ -69,21 +271,18 @@ func (c *Client) Run(ctx context.Context) error {
 			}
 
 			if errors.Is(err, errAuthFailed) {
+				c.noteDisconnect()
+				c.reportError(core.ErrorAuth, err)
 				if c.cfg.RefreshNow == nil {
-					log.Printf("twitchirc: authentication failed; retrying in %s", backoff)
-					timer := time.NewTimer(backoff)
+					delay := retry.Next()
+					log.Printf("twitchirc: authentication failed; retrying in %s", delay)
+					timer := time.NewTimer(delay)
 					select {
 					case <-ctx.Done():
 						timer.Stop()
 						return ctx.Err()
 					case <-timer.C:
 					}
-					if backoff < 60*time.Second {
-						backoff *= 2
-						if backoff > 60*time.Second {
-							backoff = 60 * time.Second
-						}
-					}
 					continue
 				}
 
@@ -95,8 +294,8 @@ func (c *Client) Run(ctx context.Context) error {
 
 					_, refreshErr := c.cfg.RefreshNow(ctx)
 					if refreshErr == nil {
-						refreshBackoff = time.Second
-						backoff = time.Second
+						refreshRetry.Reset()
+						retry.Reset()
 						break
 					}
 
@@ -104,47 +303,38 @@ func (c *Client) Run(ctx context.Context) error {
 						return ctx.Err()
 					}
 
-					log.Printf("twitchirc: refresh failed: %v; retrying in %s", refreshErr, refreshBackoff)
-					timer := time.NewTimer(refreshBackoff)
+					delay := refreshRetry.Next()
+					log.Printf("twitchirc: refresh failed: %v; retrying in %s", refreshErr, delay)
+					c.reportError(core.ErrorAuth, fmt.Errorf("twitchirc: refresh token: %w", refreshErr))
+					timer := time.NewTimer(delay)
 					select {
 					case <-ctx.Done():
 						timer.Stop()
 						return ctx.Err()
 					case <-timer.C:
 					}
-
-					if refreshBackoff < time.Minute {
-						refreshBackoff *= 2
-						if refreshBackoff > time.Minute {
-							refreshBackoff = time.Minute
-						}
-					}
 				}
 
 				continue
 			}
 
-			log.Printf("twitchirc: disconnected: %v; reconnecting in %s", err, backoff)
+			c.noteDisconnect()
+			delay := retry.Next()
+			log.Printf("twitchirc: disconnected: %v; reconnecting in %s", err, delay)
+			c.reportError(core.ErrorNetwork, fmt.Errorf("twitchirc: disconnected: %w", err))
 
-			timer := time.NewTimer(backoff)
+			timer := time.NewTimer(delay)
 			select {
 			case <-ctx.Done():
 				timer.Stop()
 				return ctx.Err()
 			case <-timer.C:
 			}
-
-			if backoff < 60*time.Second {
-				backoff *= 2
-				if backoff > 60*time.Second {
-					backoff = 60 * time.Second
-				}
-			}
 			continue
 		}
 
-		backoff = time.Second
-		refreshBackoff = time.Second
+		retry.Reset()
+		refreshRetry.Reset()
 	}
 }
 
@@ -170,23 +360,34 @@ func (c *Client) runOnce(ctx context.Context) error {
 
 	log.Printf("twitchirc: connecting to %s (tls=%v)", addr, c.cfg.UseTLS)
 
-	d := &net.Dialer{Timeout: 10 * time.Second}
-	var conn net.Conn
-	var err error
-	if c.cfg.UseTLS {
-		conn, err = tls.DialWithDialer(d, "tcp", addr, &tls.Config{ServerName: host})
-	} else {
-		conn, err = d.DialContext(ctx, "tcp", addr)
+	dial := c.cfg.Dial
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 10 * time.Second}).DialContext
 	}
+	conn, err := dial(ctx, "tcp", addr)
 	if err != nil {
 		return fmt.Errorf("dial: %w", err)
 	}
+	if c.cfg.UseTLS {
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		hsCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		err = tlsConn.HandshakeContext(hsCtx)
+		cancel()
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("tls handshake: %w", err)
+		}
+		conn = tlsConn
+	}
 	defer conn.Close()
 
 	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
 
-	// write one IRC line and flush
+	// write one IRC line and flush; the Sender writes from its own goroutine
+	var writeMu sync.Mutex
 	send := func(s string) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
 		_, err := rw.WriteString(s + "\r\n")
 		if err != nil {
 			return err
@@ -215,20 +416,23 @@ func (c *Client) runOnce(ctx context.Context) error {
 	if err := send("CAP REQ :twitch.tv/tags twitch.tv/commands twitch.tv/membership"); err != nil {
 		return fmt.Errorf("send CAP REQ: %w", err)
 	}
-	if err := send("JOIN #" + c.cfg.Channel); err != nil {
-		return fmt.Errorf("send JOIN: %w", err)
+	joinCtx, stopJoins := context.WithCancel(ctx)
+	defer stopJoins()
+	c.attach(joinCtx, send)
+	defer c.detach()
+	go c.joinChannels(joinCtx, send, c.Channels())
+	if c.cfg.Sender != nil {
+		c.cfg.Sender.attach(send)
+		defer c.cfg.Sender.detach()
 	}
-	log.Printf("twitchirc: joined #%s as %s", c.cfg.Channel, c.cfg.Nick)
 
 	reader := rw.Reader
 	droppedLog := newDropLogger(time.Now(), readTwitchDropDebugEnv(), dropSummaryInterval)
 	defer droppedLog.flush(time.Now())
 	var (
-		total        int
-		window       int
-		nextTick     = time.Now().Add(10 * time.Second)
 		readDeadline = 2 * time.Minute
 		nextPing     = time.Now().Add(4 * time.Minute)
+		roomState    = defaultRoomState
 	)
 
 	for {
@@ -250,23 +454,14 @@ func (c *Client) runOnce(ctx context.Context) error {
 					}
 					nextPing = now.Add(4 * time.Minute)
 				}
-				if now.After(nextTick) || now.Equal(nextTick) {
-					log.Printf("twitchirc: recv %d msgs (total %d)", window, total)
-					window = 0
-					nextTick = now.Add(10 * time.Second)
-				}
 				continue
 			}
 			return fmt.Errorf("read: %w", err)
 		}
 
 		now := time.Now()
-		if now.After(nextTick) || now.Equal(nextTick) {
-			log.Printf("twitchirc: recv %d msgs (total %d)", window, total)
-			window = 0
-			nextTick = now.Add(10 * time.Second)
-		}
 		nextPing = now.Add(4 * time.Minute)
+		c.lastRead = now
 
 		line = strings.TrimRight(line, "\r\n")
 		if line == "" {
@@ -290,10 +485,57 @@ func (c *Client) runOnce(ctx context.Context) error {
 			return fmt.Errorf("server requested reconnect")
 		}
 
-		msg, trace, ok, reason := parsePrivmsg(ctx, line, c.cfg.Channel, c.badges)
+		if channel, tags, ok := parseRoomState(line); ok {
+			roomState = roomState.apply(tags)
+			if c.cfg.Sender != nil {
+				c.cfg.Sender.UpdateRoomState(channel, tags)
+			}
+			if c.cfg.OnRoomState != nil {
+				c.cfg.OnRoomState(channel, roomState)
+			}
+			c.reportGap(channel, now)
+			continue
+		}
+
+		if del, ok := parseModeration(line, time.Now()); ok {
+			if c.cfg.OnDelete != nil {
+				c.cfg.OnDelete(del)
+			}
+			continue
+		}
+
+		if raid, ok := parseRaid(line, time.Now()); ok {
+			if c.cfg.OnRaid != nil {
+				c.cfg.OnRaid(raid)
+			}
+			continue
+		}
+
+		if msg, ok := parseUserNotice(ctx, line, c.badges); ok {
+			if c.handle != nil {
+				c.handle(msg, nil)
+			}
+			continue
+		}
+
+		if c.cfg.Whispers {
+			if msg, ok := parseWhisper(line, c.cfg.Nick, time.Now()); ok {
+				if c.handle != nil {
+					c.handle(msg, nil)
+				}
+				continue
+			}
+		}
+
+		if presence, ok := parseMembership(line, time.Now()); ok {
+			if c.cfg.OnPresence != nil && !strings.EqualFold(presence.Username, c.cfg.Nick) {
+				c.cfg.OnPresence(presence)
+			}
+			continue
+		}
+
+		msg, trace, ok, reason := parsePrivmsg(ctx, line, c.lineChannel(line), c.badges)
 		if ok {
-			total++
-			window++
 			if c.handle != nil {
 				c.handle(msg, trace)
 			}
@@ -363,7 +605,11 @@ func parsePrivmsg(ctx context.Context, line, channel string, badgeResolver Badge
 	if !strings.HasPrefix(rest, ":") {
 		return core.ChatMessage{}, nil, false, "missing_text"
 	}
-	text := rest[1:]
+	text, isAction := stripAction(rest[1:])
+	msgType := core.MessageTypeChat
+	if isAction {
+		msgType = core.MessageTypeAction
+	}
 
 	user := extractUser(prefix)
 	if display := tags["display-name"]; display != "" {
@@ -374,16 +620,20 @@ func parsePrivmsg(ctx context.Context, line, channel string, badgeResolver Badge
 	twitchMetrics.incSeenFromProvider()
 	trace.LogTrace(slog.Default(), "provider_seen")
 
-	ts := time.Now().UTC()
+	received := time.Now().UTC()
+	ts := received
 	if tsStr := tags["tmi-sent-ts"]; tsStr != "" {
 		if ms, err := strconv.ParseInt(tsStr, 10, 64); err == nil {
 			ts = time.Unix(0, ms*int64(time.Millisecond)).UTC()
 		}
 	}
 
+	// Messages without a platform ID are keyed by their ULID.
+	var ulid string
 	id := tags["id"]
 	if id == "" {
-		id = fmt.Sprintf("%s-%d", user, ts.UnixNano())
+		ulid = core.NewID(ts)
+		id = ulid
 	}
 
 	badgeList, badgesRaw := parseTwitchBadges(tags, channel)
@@ -408,6 +658,7 @@ func parsePrivmsg(ctx context.Context, line, channel string, badgeResolver Badge
 	return core.ChatMessage{
 		ID:            id,
 		PlatformMsgID: id,
+		ULID:          ulid,
 		Ts:            ts,
 		Username:      user,
 		Platform:      "Twitch",
@@ -418,9 +669,28 @@ func parsePrivmsg(ctx context.Context, line, channel string, badgeResolver Badge
 		BadgesRaw:     badgesRaw,
 		BadgesJSON:    encodeBadgesPayload(badgeList, badgesRaw),
 		Colour:        tags["color"],
+		MessageType:   msgType,
+		Channel:       strings.ToLower(chanName),
+		UserID:        tags["user-id"],
+		ChannelID:     tags["room-id"],
+		ReceivedAt:    &received,
+		RewardID:      tags["custom-reward-id"],
+		FirstMessage:  tags["first-msg"] == "1",
 	}, trace, true, ""
 }
 
+// stripAction removes the CTCP framing of a /me message
+// ("\x01ACTION waves\x01") and reports whether text was one. The line as
+// received is kept in RawJSON.
+func stripAction(text string) (string, bool) {
+	const prefix = "\x01ACTION"
+	if !strings.HasPrefix(text, prefix) {
+		return text, false
+	}
+	body := strings.TrimSuffix(text[len(prefix):], "\x01")
+	return strings.TrimPrefix(body, " "), true
+}
+
 func parseTwitchBadges(tags map[string]string, channel string) ([]core.ChatBadge, core.BadgesRaw) {
 	rawBadges := tags["badges"]
 	rawBadgeInfo := tags["badge-info"]
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -143,6 +144,9 @@ func TestParsePrivmsgBadges(t *testing.T) {
 			if !ok {
 				t.Fatalf("expected parsePrivmsg to succeed")
 			}
+			if msg.MessageType != core.MessageTypeChat {
+				t.Fatalf("message type = %q, want chat", msg.MessageType)
+			}
 			if !reflect.DeepEqual(msg.Badges, tt.expected) {
 				t.Fatalf("badges mismatch:\nexpected %#v\nactual   %#v", tt.expected, msg.Badges)
 			}
@@ -244,6 +248,37 @@ func TestParsePrivmsgWithoutResolverKeepsBadges(t *testing.T) {
 	}
 }
 
+func TestParsePrivmsgWithoutIDUsesULID(t *testing.T) {
+	line := "@display-name=User;tmi-sent-ts=1760529600000 :user!user@user.tmi.twitch.tv PRIVMSG #chan :hi"
+	msg, _, ok, _ := parsePrivmsg(context.Background(), line, "chan", nil)
+	if !ok {
+		t.Fatalf("expected parsePrivmsg to succeed")
+	}
+	ts, isULID := core.ULIDTime(msg.ID)
+	if !isULID || msg.ULID != msg.ID || msg.PlatformMsgID != msg.ID || !ts.Equal(msg.Ts) {
+		t.Fatalf("unexpected fallback ids %+v", msg)
+	}
+
+	line = "@display-name=User;id=msg-8 :user!user@user.tmi.twitch.tv PRIVMSG #chan :hi"
+	if msg, _, _, _ = parsePrivmsg(context.Background(), line, "chan", nil); msg.ID != "msg-8" || msg.ULID != "" {
+		t.Fatalf("platform id replaced: %+v", msg)
+	}
+}
+
+func TestParsePrivmsgAction(t *testing.T) {
+	line := "@display-name=User;id=msg-7 :user!user@user.tmi.twitch.tv PRIVMSG #chan :\x01ACTION does a thing\x01"
+	msg, _, ok, _ := parsePrivmsg(context.Background(), line, "chan", nil)
+	if !ok {
+		t.Fatalf("expected parsePrivmsg to succeed")
+	}
+	if msg.Text != "does a thing" || msg.MessageType != core.MessageTypeAction {
+		t.Fatalf("unexpected action message %+v", msg)
+	}
+	if !strings.Contains(msg.RawJSON, `\u0001ACTION does a thing\u0001`) {
+		t.Fatalf("expected the original line in raw json, got %s", msg.RawJSON)
+	}
+}
+
 type roomIDBadgeResolver struct {
 	channel string
 }
@@ -264,8 +299,28 @@ func (r roomIDBadgeResolver) Enrich(_ context.Context, channel string, badges []
 	return out
 }
 
+func TestParsePrivmsgTagsRewardRedemption(t *testing.T) {
+	line := "@custom-reward-id=rw-1;display-name=Amy;id=msg-9;user-id=42 :amy!amy@amy.tmi.twitch.tv PRIVMSG #chan :play it again"
+	msg, _, ok, _ := parsePrivmsg(context.Background(), line, "chan", nil)
+	if !ok || msg.RewardID != "rw-1" || msg.Text != "play it again" {
+		t.Fatalf("expected a message tagged with the reward, got ok=%v %+v", ok, msg)
+	}
+}
+
+func TestParsePrivmsgFirstMessage(t *testing.T) {
+	line := "@display-name=New;first-msg=1;id=msg-10;user-id=77 :new!new@new.tmi.twitch.tv PRIVMSG #chan :hello"
+	msg, _, ok, _ := parsePrivmsg(context.Background(), line, "chan", nil)
+	if !ok || !msg.FirstMessage {
+		t.Fatalf("expected a first message, got ok=%v %+v", ok, msg)
+	}
+	line = "@display-name=Old;first-msg=0;id=msg-11;user-id=78 :old!old@old.tmi.twitch.tv PRIVMSG #chan :hello"
+	if msg, _, _, _ = parsePrivmsg(context.Background(), line, "chan", nil); msg.FirstMessage {
+		t.Fatal("first-msg=0 parsed as a first message")
+	}
+}
+
 func TestParsePrivmsgUsesRoomIDForBadgeResolver(t *testing.T) {
-	line := "@badges=subscriber/12,premium/1;badge-info=subscriber/19;display-name=User;id=msg-8;room-id=1234;" +
+	line := "@badges=subscriber/12,premium/1;badge-info=subscriber/19;display-name=User;id=msg-8;room-id=1234;user-id=5678;" +
 		" :user!user@user.tmi.twitch.tv PRIVMSG #chan :hi"
 	resolver := roomIDBadgeResolver{channel: "1234"}
 
@@ -276,6 +331,9 @@ func TestParsePrivmsgUsesRoomIDForBadgeResolver(t *testing.T) {
 	if len(msg.Badges) != 2 {
 		t.Fatalf("expected two badges, got %d", len(msg.Badges))
 	}
+	if msg.ChannelID != "1234" || msg.UserID != "5678" {
+		t.Fatalf("expected room-id and user-id as IDs, got channel=%q user=%q", msg.ChannelID, msg.UserID)
+	}
 	if msg.Badges[0].ID != "subscriber" || msg.Badges[0].Version != "12" {
 		t.Fatalf("unexpected subscriber badge: %#v", msg.Badges[0])
 	}
@@ -318,3 +376,168 @@ func TestParsePrivmsgBadgeEnrichmentTimeout(t *testing.T) {
 		t.Fatalf("expected badge resolver context to include a deadline")
 	}
 }
+
+func TestParseModeration(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cases := []struct {
+		line string
+		want core.MessageDeletion
+	}{
+		{
+			line: "@login=ronni;room-id=;target-msg-id=abc-123;tmi-sent-ts=1642720582342 :tmi.twitch.tv CLEARMSG #dallas :HeyGuys",
+			want: core.MessageDeletion{Platform: "Twitch", Channel: "dallas", ID: "abc-123", Author: "ronni", DeletedAt: time.UnixMilli(1642720582342), DeletedBy: "twitch:clearmsg"},
+		},
+		{
+			line: "@ban-duration=350;room-id=12345678;target-user-id=87654321;tmi-sent-ts=1642719320727 :tmi.twitch.tv CLEARCHAT #dallas :Ronni",
+			want: core.MessageDeletion{Platform: "Twitch", Channel: "dallas", Author: "ronni", DeletedAt: time.UnixMilli(1642719320727), DeletedBy: "twitch:timeout"},
+		},
+		{
+			line: "@room-id=12345678;target-user-id=87654321 :tmi.twitch.tv CLEARCHAT #dallas :ronni",
+			want: core.MessageDeletion{Platform: "Twitch", Channel: "dallas", Author: "ronni", DeletedAt: now, DeletedBy: "twitch:ban"},
+		},
+		{
+			line: "@room-id=12345678;tmi-sent-ts=1642715695392 :tmi.twitch.tv CLEARCHAT #dallas",
+			want: core.MessageDeletion{Platform: "Twitch", Channel: "dallas", DeletedAt: time.UnixMilli(1642715695392), DeletedBy: "twitch:clearchat"},
+		},
+	}
+	for _, tc := range cases {
+		got, ok := parseModeration(tc.line, now)
+		if !ok {
+			t.Fatalf("expected %q to parse", tc.line)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("parseModeration(%q) = %+v, want %+v", tc.line, got, tc.want)
+		}
+	}
+
+	if _, ok := parseModeration("@id=1 :u!u@u PRIVMSG #dallas :CLEARCHAT", now); ok {
+		t.Fatalf("PRIVMSG must not parse as moderation")
+	}
+	if _, ok := parseModeration("@login=ronni :tmi.twitch.tv CLEARMSG #dallas :hi", now); ok {
+		t.Fatalf("CLEARMSG without target-msg-id must be ignored")
+	}
+}
+
+func TestParseRaid(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	line := `@display-name=TestChannel;id=3d830f12-795c-447d-af3c-ea05e40fbddb;login=testchannel;msg-id=raid;msg-param-displayName=TestChannel;msg-param-login=testchannel;msg-param-viewerCount=15;room-id=33332222;system-msg=15\sraiders\sfrom\sTestChannel\shave\sjoined!;tmi-sent-ts=1507246572675;user-id=123456 :tmi.twitch.tv USERNOTICE #othertestchannel`
+	got, ok := parseRaid(line, now)
+	if !ok {
+		t.Fatalf("expected raid to parse")
+	}
+	want := core.Raid{
+		Platform:    "Twitch",
+		ID:          "3d830f12-795c-447d-af3c-ea05e40fbddb",
+		Channel:     "othertestchannel",
+		Direction:   core.RaidIncoming,
+		FromChannel: "testchannel",
+		FromName:    "TestChannel",
+		ToChannel:   "othertestchannel",
+		Viewers:     15,
+		Text:        "15 raiders from TestChannel have joined!",
+		Ts:          time.UnixMilli(1507246572675),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("parseRaid = %+v, want %+v", got, want)
+	}
+
+	for _, other := range []string{
+		`@msg-id=sub;login=alice :tmi.twitch.tv USERNOTICE #chan :hi`,
+		`@msg-id=raid :tmi.twitch.tv USERNOTICE #chan`,
+		`:alice!alice@alice.tmi.twitch.tv PRIVMSG #chan :raid`,
+	} {
+		if _, ok := parseRaid(other, now); ok {
+			t.Fatalf("expected %q not to parse as a raid", other)
+		}
+	}
+}
+
+func TestParseMembership(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	got, ok := parseMembership(":Alice!alice@alice.tmi.twitch.tv JOIN #Chan", now)
+	if !ok {
+		t.Fatalf("expected JOIN to parse")
+	}
+	want := core.Presence{Platform: "Twitch", Channel: "chan", Username: "alice", Joined: true, Ts: now}
+	if got != want {
+		t.Fatalf("parseMembership = %+v, want %+v", got, want)
+	}
+
+	got, ok = parseMembership(":bob!bob@bob.tmi.twitch.tv PART #chan", now)
+	if !ok || got.Joined || got.Username != "bob" {
+		t.Fatalf("unexpected PART parse: %+v ok=%v", got, ok)
+	}
+
+	for _, other := range []string{
+		`:alice!alice@alice.tmi.twitch.tv PRIVMSG #chan :JOIN #chan`,
+		`:tmi.twitch.tv 366 nick #chan :End of /NAMES list`,
+		`PING :tmi.twitch.tv`,
+	} {
+		if _, ok := parseMembership(other, now); ok {
+			t.Fatalf("expected %q not to parse as membership", other)
+		}
+	}
+}
+
+func TestParseUserNotice(t *testing.T) {
+	line := `@badges=staff/1,broadcaster/1;color=#008000;display-name=Ronni;emotes=;id=db25007f-7a18-43eb-9379-80131e44d633;login=ronni;msg-id=resub;msg-param-cumulative-months=6;room-id=12345678;system-msg=ronni\shas\ssubscribed\sfor\s6\smonths!;tmi-sent-ts=1507246572675;user-id=87654321 :tmi.twitch.tv USERNOTICE #Dallas :Great stream -- keep it up!`
+	msg, ok := parseUserNotice(context.Background(), line, nil)
+	if !ok {
+		t.Fatalf("expected resub to parse")
+	}
+	if msg.MessageType != core.MessageTypeResub || msg.Username != "Ronni" || msg.Channel != "dallas" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	if msg.Text != "ronni has subscribed for 6 months! Great stream -- keep it up!" {
+		t.Fatalf("unexpected text %q", msg.Text)
+	}
+	if msg.ID != "db25007f-7a18-43eb-9379-80131e44d633" || !msg.Ts.Equal(time.UnixMilli(1507246572675)) || msg.Colour != "#008000" {
+		t.Fatalf("unexpected metadata %+v", msg)
+	}
+	if len(msg.Badges) != 2 {
+		t.Fatalf("expected badges, got %+v", msg.Badges)
+	}
+
+	announce := `@display-name=Mod;id=a1;login=mod;msg-id=announcement;msg-param-color=PRIMARY;system-msg= :tmi.twitch.tv USERNOTICE #dallas :Giveaway at 8pm`
+	msg, ok = parseUserNotice(context.Background(), announce, nil)
+	if !ok || msg.MessageType != core.MessageTypeAnnouncement || msg.Text != "Giveaway at 8pm" {
+		t.Fatalf("unexpected announcement %+v ok=%v", msg, ok)
+	}
+
+	ritual := `@msg-id=ritual;login=alice;display-name=Alice;system-msg=@Alice\sis\snew\shere! :tmi.twitch.tv USERNOTICE #chan :HeyGuys`
+	msg, ok = parseUserNotice(context.Background(), ritual, nil)
+	if !ok || msg.MessageType != core.MessageTypeSystem || msg.Text != "@Alice is new here! HeyGuys" {
+		t.Fatalf("unexpected ritual %+v ok=%v", msg, ok)
+	}
+
+	for _, other := range []string{
+		`@msg-id=raid;login=alice;display-name=Alice :tmi.twitch.tv USERNOTICE #chan`,
+		`@msg-id=sub;login=alice :alice!alice@alice.tmi.twitch.tv PRIVMSG #chan :hi`,
+	} {
+		if _, ok := parseUserNotice(context.Background(), other, nil); ok {
+			t.Fatalf("expected %q not to parse as a user notice", other)
+		}
+	}
+}
+
+func TestParseWhisper(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	line := `@badges=;color=#1E90FF;display-name=Alice;emotes=;message-id=7;thread-id=111_222;turbo=0;user-id=111;user-type= :alice!alice@alice.tmi.twitch.tv WHISPER Harvester_Bot :can you clip that?`
+	msg, ok := parseWhisper(line, "harvester_bot", now)
+	if !ok {
+		t.Fatalf("expected whisper to parse")
+	}
+	if msg.MessageType != core.MessageTypeWhisper || msg.ID != "whisper-111_222-7" || msg.Username != "Alice" || msg.Text != "can you clip that?" || msg.Channel != "" {
+		t.Fatalf("unexpected whisper %+v", msg)
+	}
+	if !msg.Ts.Equal(now) {
+		t.Fatalf("expected whisper stamped with now, got %s", msg.Ts)
+	}
+
+	if _, ok := parseWhisper(line, "someone_else", now); ok {
+		t.Fatalf("expected whispers to other accounts to be ignored")
+	}
+	if _, ok := parseWhisper(`:alice!alice@alice.tmi.twitch.tv PRIVMSG #chan :WHISPER harvester_bot`, "harvester_bot", now); ok {
+		t.Fatalf("expected PRIVMSG not to parse as a whisper")
+	}
+}
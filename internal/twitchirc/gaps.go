@@ -0,0 +1,33 @@
+package twitchirc
+
+import "time"
+
+// noteDisconnect marks every joined channel as missing chat since the last
+// line read, unless an earlier disconnect already did. It is a no-op
+// before the first line and without Config.OnGap.
+func (c *Client) noteDisconnect() {
+	if c.cfg.OnGap == nil || c.lastRead.IsZero() {
+		return
+	}
+	if c.gapStart == nil {
+		c.gapStart = map[string]time.Time{}
+	}
+	for _, channel := range c.Channels() {
+		if _, ok := c.gapStart[channel]; !ok {
+			c.gapStart[channel] = c.lastRead
+		}
+	}
+}
+
+// reportGap passes channel's pending gap to Config.OnGap once the channel
+// is joined again, which Twitch confirms with a ROOMSTATE.
+func (c *Client) reportGap(channel string, now time.Time) {
+	start, ok := c.gapStart[channel]
+	if !ok {
+		return
+	}
+	delete(c.gapStart, channel)
+	if now.After(start) {
+		c.cfg.OnGap(channel, start, now)
+	}
+}
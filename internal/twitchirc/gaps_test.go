@@ -0,0 +1,78 @@
+package twitchirc
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/backoff"
+)
+
+func TestRunReportsGapAfterReconnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for conn := 0; ; conn++ {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(c net.Conn, first bool) {
+				defer c.Close()
+				reader := bufio.NewReader(c)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if !strings.HasPrefix(line, "JOIN ") {
+						continue
+					}
+					c.Write([]byte("@slow=0 :tmi.twitch.tv ROOMSTATE #alpha\r\n"))
+					if first {
+						// Drop the first connection once joined.
+						time.Sleep(20 * time.Millisecond)
+						return
+					}
+				}
+			}(c, conn == 0)
+		}
+	}()
+
+	type gap struct {
+		channel    string
+		start, end time.Time
+	}
+	gaps := make(chan gap, 2)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := New(Config{
+		Channel: "alpha",
+		Nick:    "nick",
+		Token:   "oauth:token",
+		Addr:    ln.Addr().String(),
+		Backoff: backoff.Policy{Initial: time.Millisecond, Max: time.Millisecond},
+		OnGap:   func(channel string, start, end time.Time) { gaps <- gap{channel, start, end} },
+	}, nil)
+	go client.Run(ctx)
+
+	select {
+	case g := <-gaps:
+		if g.channel != "alpha" || g.start.IsZero() || !g.end.After(g.start) {
+			t.Fatalf("unexpected gap %+v", g)
+		}
+	case <-ctx.Done():
+		t.Fatal("no gap reported after reconnecting")
+	}
+	select {
+	case g := <-gaps:
+		t.Fatalf("unexpected second gap %+v", g)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,37 @@
+package twitchirc
+
+import (
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// parseMembership turns a JOIN or PART from the twitch.tv/membership
+// capability into a presence change. Twitch batches these and only sends
+// them for channels with fewer than 1000 chatters.
+func parseMembership(line string, now time.Time) (core.Presence, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[0], ":") || !strings.HasPrefix(fields[2], "#") {
+		return core.Presence{}, false
+	}
+	var joined bool
+	switch fields[1] {
+	case "JOIN":
+		joined = true
+	case "PART":
+	default:
+		return core.Presence{}, false
+	}
+	user := strings.ToLower(extractUser(fields[0]))
+	if user == "" {
+		return core.Presence{}, false
+	}
+	return core.Presence{
+		Platform: "Twitch",
+		Channel:  strings.ToLower(fields[2][1:]),
+		Username: user,
+		Joined:   joined,
+		Ts:       now,
+	}, true
+}
@@ -0,0 +1,65 @@
+package twitchirc
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// parseModeration turns CLEARMSG (one message removed) and CLEARCHAT (a
+// user timed out or banned, or the whole chat cleared) into a deletion.
+func parseModeration(line string, now time.Time) (core.MessageDeletion, bool) {
+	tags := map[string]string{}
+	rest := line
+	if strings.HasPrefix(rest, "@") {
+		idx := strings.Index(rest, " ")
+		if idx == -1 {
+			return core.MessageDeletion{}, false
+		}
+		for _, kv := range strings.Split(rest[1:idx], ";") {
+			key, val, _ := strings.Cut(kv, "=")
+			if key != "" {
+				tags[key] = unescapeIRC(val)
+			}
+		}
+		rest = strings.TrimSpace(rest[idx+1:])
+	}
+	fields := strings.Fields(rest)
+	if len(fields) < 3 || !strings.HasPrefix(fields[2], "#") {
+		return core.MessageDeletion{}, false
+	}
+	target := ""
+	if idx := strings.Index(rest, " :"); idx != -1 {
+		target = strings.TrimSpace(rest[idx+2:])
+	}
+
+	del := core.MessageDeletion{Platform: "Twitch", Channel: strings.ToLower(fields[2][1:]), DeletedAt: now}
+	if ms, err := strconv.ParseInt(tags["tmi-sent-ts"], 10, 64); err == nil && ms > 0 {
+		del.DeletedAt = time.UnixMilli(ms)
+	}
+	switch fields[1] {
+	case "CLEARMSG":
+		del.ID = tags["target-msg-id"]
+		if del.ID == "" {
+			return core.MessageDeletion{}, false
+		}
+		del.Author = tags["login"]
+		del.DeletedBy = "twitch:clearmsg"
+	case "CLEARCHAT":
+		if target == "" {
+			del.DeletedBy = "twitch:clearchat"
+			break
+		}
+		del.Author = strings.ToLower(target)
+		if _, ok := tags["ban-duration"]; ok {
+			del.DeletedBy = "twitch:timeout"
+		} else {
+			del.DeletedBy = "twitch:ban"
+		}
+	default:
+		return core.MessageDeletion{}, false
+	}
+	return del, true
+}
@@ -0,0 +1,345 @@
+package twitchirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// DefaultChannelsPerConnection caps how many channels a Pool joins on one
+// IRC connection when PoolOptions.PerConnection is zero.
+const DefaultChannelsPerConnection = 50
+
+// PoolOptions tunes how a Pool shards channels across connections.
+type PoolOptions struct {
+	// PerConnection caps the channels joined on one connection.
+	PerConnection int
+}
+
+// ShardStatus describes one connection of a Pool.
+type ShardStatus struct {
+	Index     int      `json:"index"`
+	Channels  []string `json:"channels"`
+	Connected bool     `json:"connected"`
+}
+
+// Pool joins many channels by sharding them across several IRC connections.
+// All shards share one join limiter so the account stays within Twitch's
+// JOIN rate limit. Channels can be added and removed while the pool runs;
+// new channels go to the least loaded shard with room, and shards emptied
+// by removals are drained into the others and closed.
+//
+// The first channel stays on shard 0, which also carries Config.Sender and
+// is the only shard that captures whispers.
+type Pool struct {
+	perConn int
+	limiter *rate.Limiter
+
+	mu     sync.Mutex
+	shards []*poolShard
+	run    *poolRun
+}
+
+type poolShard struct {
+	channels []string
+	client   *Client
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// poolRun holds the state of an active Run.
+type poolRun struct {
+	ctx    context.Context
+	cfg    Config
+	handle Handler
+	errs   chan error
+	panics chan string
+}
+
+// NewPool plans connections for channels; nothing connects until Run.
+func NewPool(channels []string, opts PoolOptions) *Pool {
+	p := &Pool{
+		perConn: opts.PerConnection,
+		limiter: NewJoinLimiter(),
+		shards:  []*poolShard{{}},
+	}
+	if p.perConn <= 0 {
+		p.perConn = DefaultChannelsPerConnection
+	}
+	p.Add(channels...)
+	return p
+}
+
+// Channels returns every channel in the pool, in shard order.
+func (p *Pool) Channels() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []string
+	for _, sh := range p.shards {
+		out = append(out, sh.channels...)
+	}
+	return out
+}
+
+// Shards reports the channels assigned to each connection.
+func (p *Pool) Shards() []ShardStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]ShardStatus, 0, len(p.shards))
+	for i, sh := range p.shards {
+		out = append(out, ShardStatus{
+			Index:     i,
+			Channels:  append([]string{}, sh.channels...),
+			Connected: sh.client != nil,
+		})
+	}
+	return out
+}
+
+// Add assigns channels to shards, joining them right away when running.
+func (p *Pool) Add(channels ...string) {
+	p.mu.Lock()
+	var pending []func()
+	for _, channel := range channels {
+		channel = normalizeChannel(channel)
+		if channel == "" || p.find(channel) != nil {
+			continue
+		}
+		pending = append(pending, p.assign(channel)...)
+	}
+	p.mu.Unlock()
+	for _, fn := range pending {
+		fn()
+	}
+}
+
+// Remove drops channels from the pool and rebalances the remaining ones
+// onto as few connections as the per-connection cap allows.
+func (p *Pool) Remove(channels ...string) {
+	p.mu.Lock()
+	var pending []func()
+	for _, channel := range channels {
+		channel = normalizeChannel(channel)
+		sh := p.find(channel)
+		if sh == nil {
+			continue
+		}
+		sh.channels = removeChannel(sh.channels, channel)
+		if client := sh.client; client != nil {
+			pending = append(pending, func() { _ = client.Part(channel) })
+		}
+	}
+	pending = append(pending, p.compact()...)
+	p.mu.Unlock()
+	for _, fn := range pending {
+		fn()
+	}
+}
+
+// SetChannels makes channels the pool's full channel list.
+func (p *Pool) SetChannels(channels []string) {
+	want := make(map[string]bool, len(channels))
+	for _, channel := range channels {
+		if channel = normalizeChannel(channel); channel != "" {
+			want[channel] = true
+		}
+	}
+	var drop []string
+	for _, channel := range p.Channels() {
+		if !want[channel] {
+			drop = append(drop, channel)
+		}
+	}
+	p.Remove(drop...)
+	p.Add(channels...)
+}
+
+// Run connects every shard using cfg as the template for each connection
+// and blocks until ctx is done or a shard fails to start. Config.Channel,
+// Config.Channels and Config.JoinLimiter are set per shard. A panic in a
+// shard stops every shard and is raised again from Run, so a supervisor
+// around Run sees it.
+func (p *Pool) Run(ctx context.Context, cfg Config, h Handler) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	p.mu.Lock()
+	if p.run != nil {
+		p.mu.Unlock()
+		return errors.New("twitchirc: pool already running")
+	}
+	run := &poolRun{ctx: runCtx, cfg: cfg, handle: h, errs: make(chan error, 1), panics: make(chan string, 1)}
+	p.run = run
+	for _, sh := range p.shards {
+		if len(sh.channels) > 0 {
+			p.start(sh)
+		}
+	}
+	p.mu.Unlock()
+
+	var (
+		err      error
+		panicked string
+	)
+	select {
+	case <-runCtx.Done():
+		err = ctx.Err()
+	case err = <-run.errs:
+	case panicked = <-run.panics:
+	}
+	cancel()
+
+	p.mu.Lock()
+	var done []chan struct{}
+	for _, sh := range p.shards {
+		if sh.client != nil {
+			done = append(done, sh.done)
+			sh.client, sh.cancel, sh.done = nil, nil, nil
+		}
+	}
+	p.run = nil
+	p.mu.Unlock()
+	for _, ch := range done {
+		<-ch
+	}
+	if panicked != "" {
+		panic(panicked)
+	}
+	return err
+}
+
+// find returns the shard holding channel. Callers hold p.mu.
+func (p *Pool) find(channel string) *poolShard {
+	for _, sh := range p.shards {
+		for _, existing := range sh.channels {
+			if existing == channel {
+				return sh
+			}
+		}
+	}
+	return nil
+}
+
+// assign places channel on the least loaded shard with room, opening a new
+// shard when all are full. Callers hold p.mu and run the returned
+// functions after releasing it.
+func (p *Pool) assign(channel string) []func() {
+	var target *poolShard
+	for _, sh := range p.shards {
+		if len(sh.channels) >= p.perConn {
+			continue
+		}
+		if target == nil || len(sh.channels) < len(target.channels) {
+			target = sh
+		}
+	}
+	if target == nil {
+		target = &poolShard{}
+		p.shards = append(p.shards, target)
+	}
+	target.channels = append(target.channels, channel)
+
+	if p.run == nil {
+		return nil
+	}
+	if target.client == nil {
+		p.start(target)
+		return nil
+	}
+	client := target.client
+	return []func(){func() { _ = client.Join(channel) }}
+}
+
+// compact closes surplus shards once removals leave fewer channels than
+// the open shards need, moving their channels onto the remaining ones.
+// Shard 0 is never closed. Callers hold p.mu.
+func (p *Pool) compact() []func() {
+	total := 0
+	for _, sh := range p.shards {
+		total += len(sh.channels)
+	}
+	needed := (total + p.perConn - 1) / p.perConn
+	if needed < 1 {
+		needed = 1
+	}
+
+	var pending []func()
+	for len(p.shards) > needed {
+		// drain the emptiest shard other than shard 0
+		rest := append([]*poolShard(nil), p.shards[1:]...)
+		sort.SliceStable(rest, func(i, j int) bool { return len(rest[i].channels) < len(rest[j].channels) })
+		victim := rest[0]
+		p.stop(victim)
+		for i, sh := range p.shards {
+			if sh == victim {
+				p.shards = append(p.shards[:i], p.shards[i+1:]...)
+				break
+			}
+		}
+		if len(victim.channels) > 0 {
+			log.Printf("twitchirc: pool: moving %d channels off a drained connection", len(victim.channels))
+		}
+		for _, channel := range victim.channels {
+			pending = append(pending, p.assign(channel)...)
+		}
+	}
+	return pending
+}
+
+// start runs a client for sh. Callers hold p.mu with p.run set.
+func (p *Pool) start(sh *poolShard) {
+	run := p.run
+	cfg := run.cfg
+	cfg.Channel = ""
+	cfg.Channels = append([]string(nil), sh.channels...)
+	cfg.JoinLimiter = p.limiter
+	if sh != p.shards[0] {
+		// every connection of the account receives whispers
+		cfg.Sender = nil
+		cfg.Whispers = false
+	}
+	client := New(cfg, run.handle)
+	ctx, cancel := context.WithCancel(run.ctx)
+	done := make(chan struct{})
+	sh.client, sh.cancel, sh.done = client, cancel, done
+	go func() {
+		defer close(done)
+		defer func() {
+			if r := recover(); r != nil {
+				select {
+				case run.panics <- fmt.Sprintf("twitchirc: pool shard panicked: %v\n%s", r, debug.Stack()):
+				default:
+				}
+			}
+		}()
+		if err := client.Run(ctx); err != nil && ctx.Err() == nil {
+			select {
+			case run.errs <- err:
+			default:
+			}
+		}
+	}()
+}
+
+// stop disconnects sh without waiting. Callers hold p.mu.
+func (p *Pool) stop(sh *poolShard) {
+	if sh.cancel != nil {
+		sh.cancel()
+	}
+	sh.client, sh.cancel, sh.done = nil, nil, nil
+}
+
+func removeChannel(channels []string, channel string) []string {
+	for i, existing := range channels {
+		if existing == channel {
+			return append(channels[:i], channels[i+1:]...)
+		}
+	}
+	return channels
+}
@@ -0,0 +1,215 @@
+package twitchirc
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/ingesttrace"
+)
+
+func shardChannels(p *Pool) [][]string {
+	var out [][]string
+	for _, sh := range p.Shards() {
+		out = append(out, sh.Channels)
+	}
+	return out
+}
+
+func TestPoolShardsChannels(t *testing.T) {
+	p := NewPool([]string{"#Alpha", "bravo", "charlie", "delta", "echo", "bravo"}, PoolOptions{PerConnection: 2})
+
+	want := [][]string{{"alpha", "bravo"}, {"charlie", "delta"}, {"echo"}}
+	if got := shardChannels(p); !reflect.DeepEqual(got, want) {
+		t.Fatalf("shards = %v, want %v", got, want)
+	}
+
+	p.Add("foxtrot")
+	if got := p.Shards()[2].Channels; !reflect.DeepEqual(got, []string{"echo", "foxtrot"}) {
+		t.Fatalf("expected foxtrot on the least loaded shard, got %v", got)
+	}
+}
+
+func TestPoolRebalancesOnRemove(t *testing.T) {
+	p := NewPool([]string{"alpha", "bravo", "charlie", "delta", "echo"}, PoolOptions{PerConnection: 2})
+
+	p.Remove("bravo", "delta")
+	shards := shardChannels(p)
+	if len(shards) != 2 {
+		t.Fatalf("expected 2 shards after removal, got %v", shards)
+	}
+	if shards[0][0] != "alpha" {
+		t.Fatalf("expected the first channel to stay on shard 0, got %v", shards)
+	}
+	got := p.Channels()
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"alpha", "charlie", "echo"}) {
+		t.Fatalf("channels = %v", got)
+	}
+
+	p.SetChannels([]string{"alpha"})
+	if got := shardChannels(p); !reflect.DeepEqual(got, [][]string{{"alpha"}}) {
+		t.Fatalf("shards after SetChannels = %v", got)
+	}
+}
+
+// fakeIRC accepts connections, reports each JOIN as "conn:channel" and
+// answers it with a PRIVMSG in the joined channel.
+func fakeIRC(t *testing.T, ctx context.Context) (string, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() {
+		<-ctx.Done()
+		_ = ln.Close()
+	}()
+
+	joins := make(chan string, 16)
+	go func() {
+		for n := 0; ; n++ {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(id int, c net.Conn) {
+				defer c.Close()
+				reader := bufio.NewReader(c)
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					channel, ok := strings.CutPrefix(strings.TrimSpace(line), "JOIN #")
+					if !ok {
+						continue
+					}
+					joins <- fmt.Sprintf("%d:%s", id, channel)
+					fmt.Fprintf(c, "@id=%s-msg :viewer!viewer@viewer.tmi.twitch.tv PRIVMSG #%s :hello\r\n", channel, channel)
+				}
+			}(n, conn)
+		}
+	}()
+	return ln.Addr().String(), joins
+}
+
+func expectJoin(t *testing.T, joins <-chan string, want string) {
+	t.Helper()
+	select {
+	case got := <-joins:
+		if got != want {
+			t.Fatalf("join = %q, want %q", got, want)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for join %q", want)
+	}
+}
+
+func TestPoolJoinsAddedChannelsWhileRunning(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, joins := fakeIRC(t, ctx)
+
+	msgs := make(chan core.ChatMessage, 16)
+	handler := func(msg core.ChatMessage, _ *ingesttrace.MessageTrace) { msgs <- msg }
+
+	p := NewPool([]string{"alpha"}, PoolOptions{PerConnection: 2})
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Run(ctx, Config{Nick: "nick", Token: "oauth:token", Addr: addr}, handler)
+	}()
+
+	expectJoin(t, joins, "0:alpha")
+	p.Add("bravo")
+	expectJoin(t, joins, "0:bravo")
+	p.Add("charlie")
+	expectJoin(t, joins, "1:charlie")
+
+	seen := map[string]bool{}
+	for len(seen) < 3 {
+		select {
+		case msg := <-msgs:
+			if msg.ID != msg.Channel+"-msg" {
+				t.Fatalf("message %q tagged with channel %q", msg.ID, msg.Channel)
+			}
+			seen[msg.Channel] = true
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timed out waiting for messages, saw %v", seen)
+		}
+	}
+
+	for _, sh := range p.Shards() {
+		if !sh.Connected {
+			t.Fatalf("shard %d not connected", sh.Index)
+		}
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("pool did not exit")
+	}
+}
+
+func TestPoolRaisesShardPanicsFromRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, joins := fakeIRC(t, ctx)
+
+	handler := func(msg core.ChatMessage, _ *ingesttrace.MessageTrace) {
+		if msg.Channel == "bravo" {
+			panic("bad message")
+		}
+	}
+	p := NewPool([]string{"alpha", "bravo"}, PoolOptions{PerConnection: 1})
+	done := make(chan any, 1)
+	go func() {
+		defer func() { done <- recover() }()
+		_ = p.Run(ctx, Config{Nick: "nick", Token: "oauth:token", Addr: addr}, handler)
+	}()
+
+	select {
+	case r := <-done:
+		if msg, _ := r.(string); !strings.Contains(msg, "bad message") {
+			t.Fatalf("Run recovered %v, want the shard panic", r)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("pool did not stop after a shard panicked")
+	}
+	for _, sh := range p.Shards() {
+		if sh.Connected {
+			t.Fatalf("shard %d still connected after the panic", sh.Index)
+		}
+	}
+
+	// The pool can be started again, as a supervisor would.
+	restarted := make(chan error, 1)
+	go func() {
+		restarted <- p.Run(ctx, Config{Nick: "nick", Token: "oauth:token", Addr: addr}, func(core.ChatMessage, *ingesttrace.MessageTrace) {})
+	}()
+	for alpha := 0; alpha < 2; {
+		select {
+		case join := <-joins:
+			if strings.HasSuffix(join, ":alpha") {
+				alpha++
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("restarted pool did not rejoin alpha")
+		}
+	}
+	cancel()
+	select {
+	case <-restarted:
+	case <-time.After(2 * time.Second):
+		t.Fatal("restarted pool did not exit")
+	}
+}
@@ -0,0 +1,59 @@
+package twitchirc
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// parseRaid turns a USERNOTICE with msg-id=raid into an incoming raid on the
+// line's channel.
+func parseRaid(line string, now time.Time) (core.Raid, bool) {
+	if !strings.HasPrefix(line, "@") {
+		return core.Raid{}, false
+	}
+	idx := strings.Index(line, " ")
+	if idx == -1 {
+		return core.Raid{}, false
+	}
+	tags := map[string]string{}
+	for _, kv := range strings.Split(line[1:idx], ";") {
+		key, val, _ := strings.Cut(kv, "=")
+		if key != "" {
+			tags[key] = unescapeIRC(val)
+		}
+	}
+	fields := strings.Fields(line[idx+1:])
+	if len(fields) < 3 || fields[1] != "USERNOTICE" || !strings.HasPrefix(fields[2], "#") || tags["msg-id"] != "raid" {
+		return core.Raid{}, false
+	}
+
+	channel := strings.ToLower(fields[2][1:])
+	raid := core.Raid{
+		Platform:    "Twitch",
+		ID:          tags["id"],
+		Channel:     channel,
+		Direction:   core.RaidIncoming,
+		FromChannel: strings.ToLower(tags["msg-param-login"]),
+		FromName:    tags["msg-param-displayName"],
+		ToChannel:   channel,
+		Text:        strings.TrimSpace(tags["system-msg"]),
+		Ts:          now,
+	}
+	if raid.FromChannel == "" {
+		raid.FromChannel = strings.ToLower(tags["login"])
+	}
+	if raid.FromName == "" {
+		raid.FromName = tags["display-name"]
+	}
+	if raid.FromChannel == "" {
+		return core.Raid{}, false
+	}
+	raid.Viewers, _ = strconv.Atoi(tags["msg-param-viewerCount"])
+	if ms, err := strconv.ParseInt(tags["tmi-sent-ts"], 10, 64); err == nil && ms > 0 {
+		raid.Ts = time.UnixMilli(ms)
+	}
+	return raid, true
+}
@@ -0,0 +1,63 @@
+package twitchirc
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// Reenrich rebuilds the badge and emote payloads and the user and channel IDs
+// of a stored Twitch message from the IRC tags captured in its RawJSON,
+// running badges through resolver when one is provided. ok is false when the raw payload is missing or not a
+// Twitch PRIVMSG capture.
+func Reenrich(ctx context.Context, msg core.ChatMessage, resolver BadgeResolver) (core.ChatMessage, bool) {
+	var raw struct {
+		Tags map[string]string `json:"tags"`
+		Line string            `json:"line"`
+	}
+	if strings.TrimSpace(msg.RawJSON) == "" {
+		return msg, false
+	}
+	if err := json.Unmarshal([]byte(msg.RawJSON), &raw); err != nil || raw.Tags == nil {
+		return msg, false
+	}
+
+	channel := privmsgChannel(raw.Line)
+	badgeList, badgesRaw := parseTwitchBadges(raw.Tags, channel)
+	if resolver != nil && len(badgeList) > 0 {
+		resolverChannel := channel
+		if roomID := strings.TrimSpace(raw.Tags["room-id"]); roomID != "" {
+			resolverChannel = roomID
+		}
+		enrichCtx, cancel := context.WithTimeout(ctx, badgeEnrichTimeout)
+		badgeList = resolver.Enrich(enrichCtx, resolverChannel, badgeList)
+		cancel()
+	}
+
+	msg.Badges = badgeList
+	msg.BadgesRaw = badgesRaw
+	msg.BadgesJSON = encodeBadgesPayload(badgeList, badgesRaw)
+	msg.EmotesJSON = encodeList(splitList(raw.Tags["emotes"], "/"))
+	if id := raw.Tags["user-id"]; id != "" {
+		msg.UserID = id
+	}
+	if id := raw.Tags["room-id"]; id != "" {
+		msg.ChannelID = id
+	}
+	return msg, true
+}
+
+// privmsgChannel extracts the channel (without '#') from a raw PRIVMSG line.
+func privmsgChannel(line string) string {
+	idx := strings.Index(strings.ToUpper(line), " PRIVMSG #")
+	if idx == -1 {
+		return ""
+	}
+	rest := line[idx+len(" PRIVMSG #"):]
+	if end := strings.IndexByte(rest, ' '); end != -1 {
+		rest = rest[:end]
+	}
+	return rest
+}
@@ -0,0 +1,82 @@
+package twitchirc
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RoomState mirrors the chat restrictions Twitch reports via ROOMSTATE.
+type RoomState struct {
+	EmoteOnly bool
+	SubsOnly  bool
+	// Slow is the minimum delay between messages from one user (0 when off).
+	Slow time.Duration
+	// FollowersOnly is the minimum follow age; negative when the mode is off.
+	FollowersOnly time.Duration
+}
+
+// Fields renders rs for logging and storage.
+func (rs RoomState) Fields() map[string]any {
+	followers := -1
+	if rs.FollowersOnly >= 0 {
+		followers = int(rs.FollowersOnly / time.Minute)
+	}
+	return map[string]any{
+		"emote_only":          rs.EmoteOnly,
+		"subs_only":           rs.SubsOnly,
+		"slow_secs":           int(rs.Slow / time.Second),
+		"followers_only_mins": followers,
+	}
+}
+
+// defaultRoomState is assumed until the first ROOMSTATE arrives.
+var defaultRoomState = RoomState{FollowersOnly: -1}
+
+// parseRoomState extracts the channel and tags from a ROOMSTATE line. Twitch
+// sends every tag on JOIN and only the changed ones afterwards.
+func parseRoomState(line string) (string, map[string]string, bool) {
+	tags := map[string]string{}
+	rest := line
+	if strings.HasPrefix(rest, "@") {
+		idx := strings.Index(rest, " ")
+		if idx == -1 {
+			return "", nil, false
+		}
+		for _, kv := range strings.Split(rest[1:idx], ";") {
+			key, val, _ := strings.Cut(kv, "=")
+			if key != "" {
+				tags[key] = unescapeIRC(val)
+			}
+		}
+		rest = strings.TrimSpace(rest[idx+1:])
+	}
+	fields := strings.Fields(rest)
+	if len(fields) < 3 || fields[1] != "ROOMSTATE" || !strings.HasPrefix(fields[2], "#") {
+		return "", nil, false
+	}
+	return strings.ToLower(fields[2][1:]), tags, true
+}
+
+// apply merges a (possibly partial) ROOMSTATE tag set into rs.
+func (rs RoomState) apply(tags map[string]string) RoomState {
+	if v, ok := tags["emote-only"]; ok {
+		rs.EmoteOnly = v == "1"
+	}
+	if v, ok := tags["subs-only"]; ok {
+		rs.SubsOnly = v == "1"
+	}
+	if v, ok := tags["slow"]; ok {
+		n, _ := strconv.Atoi(v)
+		rs.Slow = time.Duration(n) * time.Second
+	}
+	if v, ok := tags["followers-only"]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			rs.FollowersOnly = -1
+		} else {
+			rs.FollowersOnly = time.Duration(n) * time.Minute
+		}
+	}
+	return rs
+}
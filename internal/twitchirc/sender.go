@@ -0,0 +1,252 @@
+package twitchirc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSendInterval keeps a regular account under Twitch's 20 messages
+	// per 30 seconds limit.
+	defaultSendInterval = 1500 * time.Millisecond
+	defaultSendQueue    = 100
+	// slowModeMargin pads slow mode so clock skew does not trip the limit.
+	slowModeMargin = 250 * time.Millisecond
+)
+
+// ErrSendQueueFull is returned by Enqueue when a channel's queue is at capacity.
+var ErrSendQueueFull = errors.New("twitchirc: send queue full")
+
+// SenderOptions configures a Sender.
+type SenderOptions struct {
+	// Interval is the minimum gap between any two sends (default 1.5s).
+	Interval time.Duration
+	// MaxQueue bounds the number of pending messages per channel (default 100).
+	MaxQueue int
+}
+
+// SendQueueStatus reports the state of one channel's outbound queue.
+type SendQueueStatus struct {
+	Channel           string     `json:"channel"`
+	Queued            int        `json:"queued"`
+	Sent              int64      `json:"sent"`
+	Failed            int64      `json:"failed"`
+	Connected         bool       `json:"connected"`
+	SlowSecs          int        `json:"slow_secs"`
+	FollowersOnlyMins int        `json:"followers_only_mins"`
+	SubsOnly          bool       `json:"subs_only"`
+	EmoteOnly         bool       `json:"emote_only"`
+	NextSendAt        *time.Time `json:"next_send_at,omitempty"`
+}
+
+// Sender queues outbound chat messages per channel and releases them no
+// faster than the channel's ROOMSTATE (slow mode) and the account-wide rate
+// allow. Messages wait in the queue while the IRC connection is down instead
+// of failing.
+type Sender struct {
+	interval time.Duration
+	maxQueue int
+	wake     chan struct{}
+
+	mu       sync.Mutex
+	write    func(string) error
+	channels map[string]*sendQueue
+	lastSend time.Time
+}
+
+type sendQueue struct {
+	pending  []string
+	state    RoomState
+	lastSend time.Time
+	sent     int64
+	failed   int64
+}
+
+// NewSender constructs a Sender; call Run to start delivering.
+func NewSender(opts SenderOptions) *Sender {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultSendInterval
+	}
+	if opts.MaxQueue <= 0 {
+		opts.MaxQueue = defaultSendQueue
+	}
+	return &Sender{
+		interval: opts.Interval,
+		maxQueue: opts.MaxQueue,
+		wake:     make(chan struct{}, 1),
+		channels: make(map[string]*sendQueue),
+	}
+}
+
+// Enqueue queues text for channel and returns its position in the queue.
+func (s *Sender) Enqueue(channel, text string) (int, error) {
+	channel = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(channel), "#"))
+	text = strings.TrimSpace(strings.NewReplacer("\r", " ", "\n", " ").Replace(text))
+	if channel == "" || text == "" {
+		return 0, errors.New("twitchirc: channel and text are required")
+	}
+	s.mu.Lock()
+	q := s.queue(channel)
+	if len(q.pending) >= s.maxQueue {
+		s.mu.Unlock()
+		return 0, ErrSendQueueFull
+	}
+	q.pending = append(q.pending, text)
+	pos := len(q.pending)
+	s.mu.Unlock()
+	s.notify()
+	return pos, nil
+}
+
+// Status returns per-channel queue state sorted by channel.
+func (s *Sender) Status() []SendQueueStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]SendQueueStatus, 0, len(s.channels))
+	for name, q := range s.channels {
+		st := SendQueueStatus{
+			Channel:           name,
+			Queued:            len(q.pending),
+			Sent:              q.sent,
+			Failed:            q.failed,
+			Connected:         s.write != nil,
+			SlowSecs:          int(q.state.Slow / time.Second),
+			FollowersOnlyMins: -1,
+			SubsOnly:          q.state.SubsOnly,
+			EmoteOnly:         q.state.EmoteOnly,
+		}
+		if q.state.FollowersOnly >= 0 {
+			st.FollowersOnlyMins = int(q.state.FollowersOnly / time.Minute)
+		}
+		if len(q.pending) > 0 {
+			next := s.nextSendLocked(q)
+			st.NextSendAt = &next
+		}
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Channel < out[j].Channel })
+	return out
+}
+
+// Run delivers queued messages until ctx is cancelled.
+func (s *Sender) Run(ctx context.Context) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		wait := s.flush(time.Now())
+		if wait <= 0 {
+			wait = time.Hour
+		}
+		timer.Reset(wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// UpdateRoomState merges ROOMSTATE tags for channel.
+func (s *Sender) UpdateRoomState(channel string, tags map[string]string) {
+	s.mu.Lock()
+	q := s.queue(strings.ToLower(channel))
+	q.state = q.state.apply(tags)
+	s.mu.Unlock()
+	s.notify()
+}
+
+// attach routes sends through write while an IRC connection is up.
+func (s *Sender) attach(write func(string) error) {
+	s.mu.Lock()
+	s.write = write
+	s.mu.Unlock()
+	s.notify()
+}
+
+func (s *Sender) detach() {
+	s.mu.Lock()
+	s.write = nil
+	s.mu.Unlock()
+}
+
+// flush sends at most one due message per channel and returns the delay
+// until the next message becomes due (0 when nothing is pending).
+func (s *Sender) flush(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.write == nil {
+		return 0
+	}
+
+	names := make([]string, 0, len(s.channels))
+	for name := range s.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var next time.Duration
+	for _, name := range names {
+		q := s.channels[name]
+		if len(q.pending) == 0 {
+			continue
+		}
+		if due := s.nextSendLocked(q).Sub(now); due > 0 {
+			if next == 0 || due < next {
+				next = due
+			}
+			continue
+		}
+		text := q.pending[0]
+		if err := s.write(fmt.Sprintf("PRIVMSG #%s :%s", name, text)); err != nil {
+			// Keep the message queued; the connection will be replaced.
+			q.failed++
+			log.Printf("twitchirc: send to #%s failed: %v", name, err)
+			return s.interval
+		}
+		q.pending = q.pending[1:]
+		q.sent++
+		q.lastSend = now
+		s.lastSend = now
+		if len(q.pending) > 0 {
+			if due := s.nextSendLocked(q).Sub(now); next == 0 || due < next {
+				next = due
+			}
+		}
+	}
+	return next
+}
+
+func (s *Sender) nextSendLocked(q *sendQueue) time.Time {
+	gap := s.interval
+	if q.state.Slow > 0 && q.state.Slow+slowModeMargin > gap {
+		gap = q.state.Slow + slowModeMargin
+	}
+	next := q.lastSend.Add(gap)
+	if global := s.lastSend.Add(s.interval); global.After(next) {
+		next = global
+	}
+	return next
+}
+
+func (s *Sender) queue(channel string) *sendQueue {
+	q, ok := s.channels[channel]
+	if !ok {
+		q = &sendQueue{state: defaultRoomState}
+		s.channels[channel] = q
+	}
+	return q
+}
+
+func (s *Sender) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
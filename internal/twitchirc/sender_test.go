@@ -0,0 +1,89 @@
+package twitchirc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseRoomState(t *testing.T) {
+	channel, tags, ok := parseRoomState("@emote-only=0;followers-only=10;r9k=0;room-id=1234;slow=30;subs-only=1 :tmi.twitch.tv ROOMSTATE #Elora")
+	if !ok || channel != "elora" {
+		t.Fatalf("expected ROOMSTATE for elora, got %q ok=%v", channel, ok)
+	}
+	state := defaultRoomState.apply(tags)
+	if state.Slow != 30*time.Second || state.FollowersOnly != 10*time.Minute || !state.SubsOnly || state.EmoteOnly {
+		t.Fatalf("unexpected state %+v", state)
+	}
+
+	// Partial updates only touch the tags they carry.
+	_, tags, _ = parseRoomState("@room-id=1234;slow=0 :tmi.twitch.tv ROOMSTATE #elora")
+	state = state.apply(tags)
+	if state.Slow != 0 || !state.SubsOnly || state.FollowersOnly != 10*time.Minute {
+		t.Fatalf("unexpected partial update %+v", state)
+	}
+
+	if _, _, ok := parseRoomState("@id=1 :u!u@u PRIVMSG #elora :ROOMSTATE"); ok {
+		t.Fatalf("PRIVMSG must not parse as ROOMSTATE")
+	}
+}
+
+func TestSenderRespectsSlowMode(t *testing.T) {
+	s := NewSender(SenderOptions{Interval: time.Second})
+	var sent []string
+	s.attach(func(line string) error {
+		sent = append(sent, line)
+		return nil
+	})
+	s.UpdateRoomState("elora", map[string]string{"slow": "10"})
+
+	for _, text := range []string{"one", "two\r\nthree"} {
+		if _, err := s.Enqueue("#Elora", text); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	now := time.Now()
+	wait := s.flush(now)
+	if len(sent) != 1 || sent[0] != "PRIVMSG #elora :one" {
+		t.Fatalf("unexpected first send %v", sent)
+	}
+	if wait != 10*time.Second+slowModeMargin {
+		t.Fatalf("expected slow-mode wait, got %s", wait)
+	}
+	if s.flush(now.Add(5 * time.Second)); len(sent) != 1 {
+		t.Fatalf("sent during slow mode: %v", sent)
+	}
+	status := s.Status()
+	if len(status) != 1 || status[0].Queued != 1 || status[0].SlowSecs != 10 || status[0].NextSendAt == nil {
+		t.Fatalf("unexpected status %+v", status)
+	}
+
+	s.flush(now.Add(11 * time.Second))
+	if len(sent) != 2 || sent[1] != "PRIVMSG #elora :two  three" {
+		t.Fatalf("unexpected second send %v", sent)
+	}
+}
+
+func TestSenderQueuesWhileDisconnected(t *testing.T) {
+	s := NewSender(SenderOptions{MaxQueue: 1})
+	if _, err := s.Enqueue("elora", "hello"); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := s.Enqueue("elora", "again"); !errors.Is(err, ErrSendQueueFull) {
+		t.Fatalf("expected queue full, got %v", err)
+	}
+	if wait := s.flush(time.Now()); wait != 0 {
+		t.Fatalf("expected no schedule while detached, got %s", wait)
+	}
+	if st := s.Status(); st[0].Connected || st[0].Queued != 1 {
+		t.Fatalf("unexpected status %+v", st)
+	}
+
+	failing := errors.New("broken pipe")
+	s.attach(func(string) error { return failing })
+	s.flush(time.Now())
+	if st := s.Status(); st[0].Queued != 1 || st[0].Failed != 1 {
+		t.Fatalf("failed send should stay queued: %+v", st)
+	}
+}
@@ -0,0 +1,122 @@
+package twitchirc
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// userNoticeTypes maps USERNOTICE msg-id values to the message type stored
+// for them. Raids are handled by parseRaid; other msg-ids are stored as
+// core.MessageTypeSystem.
+var userNoticeTypes = map[string]string{
+	"sub":                 core.MessageTypeSub,
+	"resub":               core.MessageTypeResub,
+	"subgift":             core.MessageTypeSubGift,
+	"submysterygift":      core.MessageTypeSubGift,
+	"anonsubgift":         core.MessageTypeSubGift,
+	"announcement":        core.MessageTypeAnnouncement,
+	"giftpaidupgrade":     core.MessageTypeSub,
+	"anongiftpaidupgrade": core.MessageTypeSub,
+	"primepaidupgrade":    core.MessageTypeSub,
+}
+
+// parseUserNotice turns a subscription, announcement or other USERNOTICE into a
+// chat message attributed to the chatter. Text is the system-msg followed
+// by the chatter's own message, if they attached one.
+func parseUserNotice(ctx context.Context, line string, badgeResolver BadgeResolver) (core.ChatMessage, bool) {
+	if !strings.HasPrefix(line, "@") {
+		return core.ChatMessage{}, false
+	}
+	idx := strings.Index(line, " ")
+	if idx == -1 {
+		return core.ChatMessage{}, false
+	}
+	tags := map[string]string{}
+	for _, kv := range strings.Split(line[1:idx], ";") {
+		key, val, _ := strings.Cut(kv, "=")
+		if key != "" {
+			tags[key] = unescapeIRC(val)
+		}
+	}
+	rest := strings.TrimSpace(line[idx+1:])
+	fields := strings.Fields(rest)
+	if len(fields) < 3 || fields[1] != "USERNOTICE" || !strings.HasPrefix(fields[2], "#") {
+		return core.ChatMessage{}, false
+	}
+	if tags["msg-id"] == "" || tags["msg-id"] == "raid" {
+		return core.ChatMessage{}, false
+	}
+	msgType, ok := userNoticeTypes[tags["msg-id"]]
+	if !ok {
+		msgType = core.MessageTypeSystem
+	}
+	channel := strings.ToLower(fields[2][1:])
+	message := ""
+	if i := strings.Index(rest, " :"); i != -1 {
+		message = rest[i+2:]
+	}
+
+	user := tags["display-name"]
+	if user == "" {
+		user = tags["login"]
+	}
+	if user == "" {
+		return core.ChatMessage{}, false
+	}
+	text := strings.TrimSpace(strings.TrimSpace(tags["system-msg"]) + " " + message)
+
+	received := time.Now().UTC()
+	ts := received
+	if ms, err := strconv.ParseInt(tags["tmi-sent-ts"], 10, 64); err == nil && ms > 0 {
+		ts = time.UnixMilli(ms).UTC()
+	}
+	var ulid string
+	id := tags["id"]
+	if id == "" {
+		ulid = core.NewID(ts)
+		id = ulid
+	}
+
+	badgeList, badgesRaw := parseTwitchBadges(tags, channel)
+	if badgeResolver != nil {
+		enrichCtx, cancel := context.WithTimeout(ctx, badgeEnrichTimeout)
+		resolverChannel := channel
+		if roomID := strings.TrimSpace(tags["room-id"]); roomID != "" {
+			resolverChannel = roomID
+		}
+		badgeList = badgeResolver.Enrich(enrichCtx, resolverChannel, badgeList)
+		cancel()
+	}
+
+	rawJSON, _ := json.Marshal(map[string]any{
+		"tags":   tags,
+		"prefix": strings.TrimPrefix(fields[0], ":"),
+		"line":   line,
+	})
+
+	return core.ChatMessage{
+		ID:            id,
+		PlatformMsgID: id,
+		ULID:          ulid,
+		Ts:            ts,
+		Username:      user,
+		Platform:      "Twitch",
+		Text:          text,
+		EmotesJSON:    encodeList(splitList(tags["emotes"], "/")),
+		RawJSON:       string(rawJSON),
+		Badges:        badgeList,
+		BadgesRaw:     badgesRaw,
+		BadgesJSON:    encodeBadgesPayload(badgeList, badgesRaw),
+		Colour:        tags["color"],
+		MessageType:   msgType,
+		Channel:       channel,
+		UserID:        tags["user-id"],
+		ChannelID:     tags["room-id"],
+		ReceivedAt:    &received,
+	}, true
+}
@@ -0,0 +1,71 @@
+package twitchirc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// parseWhisper turns a WHISPER addressed to nick into a message of type
+// core.MessageTypeWhisper. Whispers carry no channel and no tmi-sent-ts, so
+// the message is stamped with now.
+func parseWhisper(line, nick string, now time.Time) (core.ChatMessage, bool) {
+	tags := map[string]string{}
+	rest := line
+	if strings.HasPrefix(rest, "@") {
+		idx := strings.Index(rest, " ")
+		if idx == -1 {
+			return core.ChatMessage{}, false
+		}
+		for _, kv := range strings.Split(rest[1:idx], ";") {
+			key, val, _ := strings.Cut(kv, "=")
+			if key != "" {
+				tags[key] = unescapeIRC(val)
+			}
+		}
+		rest = strings.TrimSpace(rest[idx+1:])
+	}
+	fields := strings.Fields(rest)
+	if len(fields) < 3 || !strings.HasPrefix(fields[0], ":") || fields[1] != "WHISPER" || !strings.EqualFold(fields[2], nick) {
+		return core.ChatMessage{}, false
+	}
+	text := ""
+	if i := strings.Index(rest, " :"); i != -1 {
+		text = rest[i+2:]
+	}
+
+	user := tags["display-name"]
+	if user == "" {
+		user = extractUser(fields[0])
+	}
+	var ulid string
+	// message-id only counts within a thread.
+	id := fmt.Sprintf("whisper-%s-%s", tags["thread-id"], tags["message-id"])
+	if tags["thread-id"] == "" || tags["message-id"] == "" {
+		ulid = core.NewID(now)
+		id = ulid
+	}
+	rawJSON, _ := json.Marshal(map[string]any{
+		"tags":   tags,
+		"prefix": strings.TrimPrefix(fields[0], ":"),
+		"line":   line,
+	})
+
+	return core.ChatMessage{
+		ID:            id,
+		PlatformMsgID: id,
+		ULID:          ulid,
+		Ts:            now.UTC(),
+		Username:      user,
+		Platform:      "Twitch",
+		Text:          text,
+		EmotesJSON:    encodeList(splitList(tags["emotes"], "/")),
+		RawJSON:       string(rawJSON),
+		Colour:        tags["color"],
+		MessageType:   core.MessageTypeWhisper,
+		UserID:        tags["user-id"],
+	}, true
+}
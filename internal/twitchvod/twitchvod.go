@@ -0,0 +1,281 @@
+// Package twitchvod replays chat from Twitch past broadcasts (VODs) so gaps
+// in the archive can be backfilled. Comments are read through Twitch's
+// public GraphQL endpoint, the same one the web player's chat replay uses.
+package twitchvod
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/you/gnasty-chat/internal/backfill"
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/twitchbadges"
+	"github.com/you/gnasty-chat/internal/twitchirc"
+)
+
+const (
+	// gqlClientID is the public client ID of the Twitch web player.
+	gqlClientID = "kimne78kx3ncx6brgo4mv6wki5h1ko"
+	// commentsQuery and commentsHash name the persisted query the web
+	// player pages VOD chat with.
+	commentsQuery = "VideoCommentsByOffsetOrCursor"
+	commentsHash  = "b70a3591ff0f4e0313d126c6a1502d79a1c02baebb288227c582044aa76adf6a"
+	// maxPages bounds the comment pages fetched for one VOD and gap.
+	maxPages = 1000
+)
+
+var gqlURL = "https://gql.twitch.tv/gql"
+
+// Videos lists a channel's past broadcasts; *twitchbadges.Resolver
+// satisfies it.
+type Videos interface {
+	Archives(ctx context.Context, login string) ([]twitchbadges.Video, error)
+}
+
+// Source fetches VOD chat for a gap. It satisfies backfill.Source; the gap's
+// Channel is the Twitch login.
+type Source struct {
+	videos Videos
+	badges twitchirc.BadgeResolver
+	http   *http.Client
+}
+
+// New creates a source listing VODs through videos and enriching badges
+// through badges, which may be nil. If client is nil a default client with
+// a sane timeout is used.
+func New(videos Videos, badges twitchirc.BadgeResolver, client *http.Client) *Source {
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &Source{videos: videos, badges: badges, http: client}
+}
+
+// Fetch returns the comments of every VOD overlapping gap, from gap.Start
+// onwards. Chat sent while the channel was offline is not recorded by
+// Twitch, so a gap no VOD covers yields no messages.
+func (s *Source) Fetch(ctx context.Context, gap backfill.Gap) ([]core.ChatMessage, error) {
+	login := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(gap.Channel), "#"))
+	videos, err := s.videos.Archives(ctx, login)
+	if err != nil {
+		return nil, err
+	}
+	var out []core.ChatMessage
+	for _, video := range videos {
+		if video.CreatedAt.After(gap.End) || video.End().Before(gap.Start) {
+			continue
+		}
+		msgs, err := s.comments(ctx, login, video, gap)
+		if err != nil {
+			return nil, fmt.Errorf("twitchvod: video %s: %w", video.ID, err)
+		}
+		out = append(out, msgs...)
+	}
+	return out, nil
+}
+
+type commentsResponse struct {
+	Data struct {
+		Video *struct {
+			Comments struct {
+				Edges []struct {
+					Cursor string      `json:"cursor"`
+					Node   commentNode `json:"node"`
+				} `json:"edges"`
+				PageInfo struct {
+					HasNextPage bool `json:"hasNextPage"`
+				} `json:"pageInfo"`
+			} `json:"comments"`
+		} `json:"video"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type commentNode struct {
+	ID        string `json:"id"`
+	CreatedAt string `json:"createdAt"`
+	Commenter *struct {
+		ID          string `json:"id"`
+		Login       string `json:"login"`
+		DisplayName string `json:"displayName"`
+	} `json:"commenter"`
+	Message struct {
+		Fragments []struct {
+			Text  string `json:"text"`
+			Emote *struct {
+				EmoteID string `json:"emoteID"`
+			} `json:"emote"`
+		} `json:"fragments"`
+		UserBadges []struct {
+			SetID   string `json:"setID"`
+			Version string `json:"version"`
+		} `json:"userBadges"`
+		UserColor string `json:"userColor"`
+	} `json:"message"`
+}
+
+// comments pages video's chat from gap.Start until past gap.End.
+func (s *Source) comments(ctx context.Context, login string, video twitchbadges.Video, gap backfill.Gap) ([]core.ChatMessage, error) {
+	offset := int(gap.Start.Sub(video.CreatedAt) / time.Second)
+	if offset < 0 {
+		offset = 0
+	}
+	vars := map[string]any{"videoID": video.ID, "contentOffsetSeconds": offset}
+
+	var out []core.ChatMessage
+	for page := 0; page < maxPages; page++ {
+		resp, err := s.query(ctx, vars)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Data.Video == nil {
+			return out, nil
+		}
+		edges := resp.Data.Video.Comments.Edges
+		cursor := ""
+		for _, edge := range edges {
+			cursor = edge.Cursor
+			msg, ok := s.message(ctx, login, video, edge.Node)
+			if !ok {
+				continue
+			}
+			if msg.Ts.After(gap.End) {
+				return out, nil
+			}
+			out = append(out, msg)
+		}
+		if len(edges) == 0 || cursor == "" || !resp.Data.Video.Comments.PageInfo.HasNextPage {
+			return out, nil
+		}
+		vars = map[string]any{"videoID": video.ID, "cursor": cursor}
+	}
+	return out, nil
+}
+
+func (s *Source) query(ctx context.Context, vars map[string]any) (commentsResponse, error) {
+	body, err := json.Marshal([]any{map[string]any{
+		"operationName": commentsQuery,
+		"variables":     vars,
+		"extensions": map[string]any{"persistedQuery": map[string]any{
+			"version":    1,
+			"sha256Hash": commentsHash,
+		}},
+	}})
+	if err != nil {
+		return commentsResponse{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, gqlURL, bytes.NewReader(body))
+	if err != nil {
+		return commentsResponse{}, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Client-Id", gqlClientID)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return commentsResponse{}, core.NewError(core.ErrorNetwork, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return commentsResponse{}, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(text)))
+	}
+	var parsed []commentsResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 8<<20)).Decode(&parsed); err != nil {
+		return commentsResponse{}, core.NewError(core.ErrorParse, fmt.Errorf("decode response: %w", err))
+	}
+	if len(parsed) == 0 {
+		return commentsResponse{}, core.NewError(core.ErrorParse, errors.New("empty response"))
+	}
+	if errs := parsed[0].Errors; len(errs) > 0 {
+		return commentsResponse{}, fmt.Errorf("gql: %s", errs[0].Message)
+	}
+	return parsed[0], nil
+}
+
+// message converts a VOD comment to the message IRC would have delivered.
+// The comment is recorded as IRC tags in RawJSON, so badges, emotes and IDs
+// come from twitchirc.Reenrich exactly as for live chat.
+func (s *Source) message(ctx context.Context, login string, video twitchbadges.Video, node commentNode) (core.ChatMessage, bool) {
+	ts, err := time.Parse(time.RFC3339, node.CreatedAt)
+	if node.ID == "" || node.Commenter == nil || err != nil {
+		return core.ChatMessage{}, false
+	}
+	ts = ts.UTC()
+
+	var (
+		text   strings.Builder
+		emotes []string
+		spans  = map[string][]string{}
+		pos    int
+	)
+	for _, frag := range node.Message.Fragments {
+		n := utf8.RuneCountInString(frag.Text)
+		if frag.Emote != nil && frag.Emote.EmoteID != "" && n > 0 {
+			id := frag.Emote.EmoteID
+			if _, ok := spans[id]; !ok {
+				emotes = append(emotes, id)
+			}
+			spans[id] = append(spans[id], fmt.Sprintf("%d-%d", pos, pos+n-1))
+		}
+		text.WriteString(frag.Text)
+		pos += n
+	}
+	emoteTags := make([]string, 0, len(emotes))
+	for _, id := range emotes {
+		emoteTags = append(emoteTags, id+":"+strings.Join(spans[id], ","))
+	}
+	badges := make([]string, 0, len(node.Message.UserBadges))
+	for _, b := range node.Message.UserBadges {
+		if b.SetID != "" {
+			badges = append(badges, b.SetID+"/"+b.Version)
+		}
+	}
+
+	user := node.Commenter.DisplayName
+	if user == "" {
+		user = node.Commenter.Login
+	}
+	tags := map[string]string{
+		"id":           node.ID,
+		"display-name": node.Commenter.DisplayName,
+		"user-id":      node.Commenter.ID,
+		"room-id":      video.UserID,
+		"color":        node.Message.UserColor,
+		"badges":       strings.Join(badges, ","),
+		"emotes":       strings.Join(emoteTags, "/"),
+		"tmi-sent-ts":  strconv.FormatInt(ts.UnixMilli(), 10),
+	}
+	prefix := fmt.Sprintf("%[1]s!%[1]s@%[1]s.tmi.twitch.tv", node.Commenter.Login)
+	raw, _ := json.Marshal(map[string]any{
+		"tags":   tags,
+		"prefix": prefix,
+		"line":   fmt.Sprintf(":%s PRIVMSG #%s :%s", prefix, login, text.String()),
+		"vod":    video.ID,
+	})
+
+	msg := core.ChatMessage{
+		ID:            node.ID,
+		PlatformMsgID: node.ID,
+		Ts:            ts,
+		Username:      user,
+		Platform:      "Twitch",
+		Text:          text.String(),
+		RawJSON:       string(raw),
+		Colour:        node.Message.UserColor,
+		MessageType:   core.MessageTypeChat,
+		Channel:       login,
+	}
+	msg, _ = twitchirc.Reenrich(ctx, msg, s.badges)
+	return msg, true
+}
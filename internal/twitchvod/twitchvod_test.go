@@ -0,0 +1,102 @@
+package twitchvod
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/backfill"
+	"github.com/you/gnasty-chat/internal/twitchbadges"
+)
+
+type fakeVideos []twitchbadges.Video
+
+func (f fakeVideos) Archives(ctx context.Context, login string) ([]twitchbadges.Video, error) {
+	return f, nil
+}
+
+func comment(id string, ts time.Time) map[string]any {
+	return map[string]any{"cursor": "cur-" + id, "node": map[string]any{
+		"id":        id,
+		"createdAt": ts.Format(time.RFC3339),
+		"commenter": map[string]any{"id": "501", "login": "ann", "displayName": "Ann"},
+		"message": map[string]any{
+			"fragments": []any{
+				map[string]any{"text": "héllo "},
+				map[string]any{"text": "Kappa", "emote": map[string]any{"emoteID": "25"}},
+			},
+			"userBadges": []any{map[string]any{"setID": "subscriber", "version": "12"}},
+			"userColor":  "#FF0000",
+		},
+	}}
+}
+
+func TestSourceFetch(t *testing.T) {
+	vodStart := time.Date(2026, 10, 13, 18, 0, 0, 0, time.UTC)
+	gap := backfill.Gap{Platform: "Twitch", Channel: "elora", Start: vodStart.Add(time.Hour), End: vodStart.Add(time.Hour + 5*time.Minute)}
+
+	var requests []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Client-Id") != gqlClientID {
+			t.Errorf("Client-Id = %q", r.Header.Get("Client-Id"))
+		}
+		var body []struct {
+			Variables map[string]any `json:"variables"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		vars := body[0].Variables
+		requests = append(requests, vars)
+		var edges []any
+		hasNext := true
+		switch {
+		case vars["videoID"] != "9001":
+			hasNext = false
+		case vars["cursor"] == nil:
+			edges = []any{comment("c1", gap.Start.Add(time.Second)), comment("c2", gap.Start.Add(time.Minute))}
+		default:
+			edges = []any{comment("c3", gap.End.Add(-time.Second)), comment("c4", gap.End.Add(time.Second))}
+		}
+		_ = json.NewEncoder(w).Encode([]any{map[string]any{"data": map[string]any{"video": map[string]any{
+			"comments": map[string]any{"edges": edges, "pageInfo": map[string]any{"hasNextPage": hasNext}},
+		}}}})
+	}))
+	defer srv.Close()
+	gqlURL = srv.URL
+
+	videos := fakeVideos{
+		{ID: "9001", UserID: "77", CreatedAt: vodStart, Duration: 3 * time.Hour},
+		{ID: "8000", UserID: "77", CreatedAt: vodStart.Add(-48 * time.Hour), Duration: 2 * time.Hour},
+	}
+	msgs, err := New(videos, nil, srv.Client()).Fetch(context.Background(), gap)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	var ids []string
+	for _, msg := range msgs {
+		ids = append(ids, msg.ID)
+	}
+	if fmt.Sprint(ids) != "[c1 c2 c3]" {
+		t.Fatalf("ids = %v", ids)
+	}
+	if len(requests) != 2 || requests[0]["contentOffsetSeconds"] != float64(3600) || requests[1]["cursor"] != "cur-c2" {
+		t.Fatalf("requests = %v", requests)
+	}
+
+	msg := msgs[0]
+	if msg.Text != "héllo Kappa" || msg.Username != "Ann" || msg.Channel != "elora" || msg.Platform != "Twitch" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	if msg.UserID != "501" || msg.ChannelID != "77" || msg.Colour != "#FF0000" {
+		t.Fatalf("unexpected ids %+v", msg)
+	}
+	if msg.EmotesJSON != `["25:6-10"]` {
+		t.Fatalf("EmotesJSON = %s", msg.EmotesJSON)
+	}
+	if len(msg.Badges) != 1 || msg.Badges[0].ID != "subscriber" || msg.Badges[0].Version != "12" {
+		t.Fatalf("Badges = %+v", msg.Badges)
+	}
+}
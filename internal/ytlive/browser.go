@@ -0,0 +1,85 @@
+package ytlive
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+)
+
+// userAgents are current desktop browser strings. YouTube serves reduced or
+// blocked pages to unknown agents, so requests present as a browser and move
+// to the next string when a poll is rejected.
+var userAgents = []string{
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+	"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:127.0) Gecko/20100101 Firefox/127.0",
+	"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36",
+}
+
+// userAgentRotator hands out userAgents round-robin. The zero value starts
+// at the first entry.
+type userAgentRotator struct {
+	n atomic.Uint32
+}
+
+func (r *userAgentRotator) current() string {
+	return userAgents[int(r.n.Load())%len(userAgents)]
+}
+
+func (r *userAgentRotator) rotate() string {
+	return userAgents[int(r.n.Add(1))%len(userAgents)]
+}
+
+// setBrowserHeaders makes req look like a browser that has already answered
+// the EU consent prompt, so region interstitials do not replace the page.
+// Consent cookies already in req or in jar (a loaded cookie file) win.
+func setBrowserHeaders(req *http.Request, userAgent string, jar http.CookieJar) {
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	if hasCookie(jar, req.URL, "CONSENT", "SOCS") {
+		return
+	}
+	for _, c := range req.Cookies() {
+		if c.Name == "CONSENT" || c.Name == "SOCS" {
+			return
+		}
+	}
+	req.AddCookie(&http.Cookie{Name: "CONSENT", Value: "YES+cb"})
+	req.AddCookie(&http.Cookie{Name: "SOCS", Value: "CAI"})
+}
+
+// errConsentRequired reports that YouTube redirected to its consent page
+// despite the consent cookies.
+var errConsentRequired = errors.New("ytlive: redirected to the consent page; set cookies for an account that has accepted it")
+
+func isConsentPage(u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+	host := strings.ToLower(u.Host)
+	return host == "consent.youtube.com" || host == "consent.google.com"
+}
+
+// pollStatusError is a non-200 get_live_chat response.
+type pollStatusError struct {
+	status int
+	text   string
+	body   string
+}
+
+func (e *pollStatusError) Error() string {
+	return fmt.Sprintf("ytlive: poll status %s: %s", e.text, e.body)
+}
+
+// staleSession reports whether err is a rejection that usually means the
+// Innertube API key or client version went stale.
+func staleSession(err error) bool {
+	var status *pollStatusError
+	if !errors.As(err, &status) {
+		return false
+	}
+	return status.status == http.StatusBadRequest || status.status == http.StatusForbidden
+}
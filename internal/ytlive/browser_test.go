@@ -0,0 +1,96 @@
+package ytlive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+func TestRunRefreshesKeyAfterRejectedPoll(t *testing.T) {
+	var (
+		mu         sync.Mutex
+		bootstraps int
+		polls      []string
+		agents     []string
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("/watch", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		bootstraps++
+		key := fmt.Sprintf("key%d", bootstraps)
+		mu.Unlock()
+		if c, err := r.Cookie("CONSENT"); err != nil || c.Value == "" {
+			t.Errorf("expected a consent cookie on the watch page request")
+		}
+		fmt.Fprintf(w, `<script>ytcfg.set({"INNERTUBE_API_KEY":"%s","INNERTUBE_CLIENT_VERSION":"2.0"});</script><script>var ytInitialData = {"contents":{"liveChatRenderer":{"continuations":[{"timedContinuationData":{"continuation":"cont-%s"}}]}}};</script>`, key, key)
+	})
+	handler.HandleFunc("/youtubei/v1/live_chat/get_live_chat", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Continuation string `json:"continuation"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		polls = append(polls, r.URL.Query().Get("key")+"/"+body.Continuation)
+		agents = append(agents, r.Header.Get("User-Agent"))
+		mu.Unlock()
+		if r.URL.Query().Get("key") == "key1" {
+			http.Error(w, "stale", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"continuationContents":{"liveChatContinuation":{"continuations":[{"timedContinuationData":{"continuation":"next","timeoutMs":10}}],"actions":[{"addChatItemAction":{"item":{"liveChatTextMessageRenderer":{"id":"m1","authorName":{"simpleText":"viewer"},"message":{"runs":[{"text":"hi"}]},"timestampUsec":"1717243200000000"}}}}]}}}`))
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	got := make(chan core.ChatMessage, 1)
+	client := New(Config{LiveURL: "https://www.youtube.com/watch?v=abc"}, func(msg core.ChatMessage) {
+		select {
+		case got <- msg:
+		default:
+		}
+		cancel()
+	})
+	client.http = &http.Client{Transport: rewriteTransport(server.URL), Timeout: 2 * time.Second}
+	go client.Run(ctx)
+
+	select {
+	case msg := <-got:
+		if msg.Text != "hi" {
+			t.Fatalf("unexpected message %+v", msg)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatalf("no message after the refresh")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if bootstraps != 2 {
+		t.Fatalf("expected one refresh bootstrap, got %d bootstraps", bootstraps)
+	}
+	// The retry reuses the original continuation with the new key.
+	if len(polls) < 2 || polls[0] != "key1/cont-key1" || polls[1] != "key2/cont-key1" {
+		t.Fatalf("unexpected polls %v", polls)
+	}
+	if agents[0] == agents[1] {
+		t.Fatalf("expected the user agent to rotate, got %q twice", agents[0])
+	}
+}
+
+func TestStaleSession(t *testing.T) {
+	if !staleSession(core.NewError(core.ErrorAuth, &pollStatusError{status: http.StatusForbidden})) {
+		t.Fatalf("expected 403 to be a stale session")
+	}
+	if staleSession(core.NewError(core.ErrorNetwork, &pollStatusError{status: http.StatusServiceUnavailable})) {
+		t.Fatalf("expected 503 not to be a stale session")
+	}
+}
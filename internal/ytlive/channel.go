@@ -0,0 +1,256 @@
+package ytlive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Live selection preferences for channels with several simultaneous streams.
+// Any other value is matched case-insensitively against stream titles.
+const (
+	LiveSelectFirst = "first"
+	LiveSelectAll   = "all"
+)
+
+var channelIDPattern = regexp.MustCompile(`^UC[A-Za-z0-9_-]{22}$`)
+
+// IsChannelTarget reports whether raw names a channel (a bare channel ID, an
+// @handle, or a /channel/, /c/, /user/ or /@ URL) rather than a video or an
+// explicit /live page.
+func IsChannelTarget(raw string) bool {
+	_, ok := channelStreamsURL(raw)
+	return ok
+}
+
+// channelStreamsURL returns the streams tab for a channel target.
+func channelStreamsURL(raw string) (string, bool) {
+	trimmed := strings.TrimSpace(raw)
+	if channelIDPattern.MatchString(trimmed) {
+		return "https://www.youtube.com/channel/" + trimmed + "/streams", true
+	}
+	if strings.HasPrefix(trimmed, "@") {
+		trimmed = "https://www.youtube.com/" + trimmed
+	}
+	if !strings.Contains(trimmed, "://") {
+		trimmed = "https://" + trimmed
+	}
+	u, err := url.Parse(trimmed)
+	if err != nil {
+		return "", false
+	}
+	switch strings.ToLower(u.Host) {
+	case "youtube.com", "www.youtube.com", "m.youtube.com":
+	default:
+		return "", false
+	}
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	var base string
+	switch {
+	case strings.HasPrefix(parts[0], "@") && len(parts[0]) > 1:
+		base = "/" + parts[0]
+		parts = parts[1:]
+	case (parts[0] == "channel" || parts[0] == "c" || parts[0] == "user") && len(parts) > 1 && parts[1] != "":
+		base = "/" + parts[0] + "/" + parts[1]
+		parts = parts[2:]
+	default:
+		return "", false
+	}
+	// An explicit /live page keeps the single-stream lookup.
+	if len(parts) > 0 && parts[0] == "live" {
+		return "", false
+	}
+	return "https://www.youtube.com" + base + "/streams", true
+}
+
+// ResolveChannel lists the live and upcoming videos on a channel's streams
+// tab, live ones first in tab order. Live videos are resolved through their
+// watch page so ChatURL, viewers and metadata are filled in; upcoming ones
+// carry Upcoming and, when published, ScheduledStart.
+func (r *Resolver) ResolveChannel(ctx context.Context, raw string) ([]ResolveResult, error) {
+	streamsURL, ok := channelStreamsURL(raw)
+	if !ok {
+		return nil, fmt.Errorf("ytlive: %q is not a channel", raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, streamsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	setBrowserHeaders(req, r.agents.current(), r.http.Jar)
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("ytlive: channel streams status %s", resp.Status)
+	}
+	if isConsentPage(resp.Request.URL) {
+		return nil, errConsentRequired
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	initJSON, ok := extractJSONAssignment(string(body), "ytInitialData")
+	if !ok {
+		return nil, errors.New("ytlive: channel streams page has no initial data")
+	}
+	var data map[string]any
+	if err := json.Unmarshal([]byte(initJSON), &data); err != nil {
+		return nil, fmt.Errorf("ytlive: parse channel streams: %w", err)
+	}
+
+	var live, upcoming []ResolveResult
+	for _, video := range channelVideos(data) {
+		watchURL := canonicalWatchFromVideoID(video.id)
+		switch {
+		case video.live:
+			res, err := r.Resolve(ctx, watchURL)
+			if err != nil {
+				// Still poll it; the watch page only adds metadata.
+				res = ResolveResult{Live: true, WatchURL: watchURL, ChatURL: canonicalChatFromVideoID(video.id), Title: video.title}
+			} else if !res.Live {
+				continue
+			}
+			live = append(live, res)
+		case video.upcoming:
+			upcoming = append(upcoming, ResolveResult{
+				WatchURL:       watchURL,
+				Title:          video.title,
+				Upcoming:       true,
+				ScheduledStart: video.scheduled,
+			})
+		}
+	}
+	return append(live, upcoming...), nil
+}
+
+type channelVideo struct {
+	id        string
+	title     string
+	live      bool
+	upcoming  bool
+	scheduled time.Time
+}
+
+// channelVideos walks ytInitialData for video renderers, once per video ID.
+func channelVideos(data map[string]any) []channelVideo {
+	var out []channelVideo
+	seen := map[string]bool{}
+	var walk func(v any)
+	walk = func(v any) {
+		switch node := v.(type) {
+		case map[string]any:
+			for key, child := range node {
+				if key == "videoRenderer" || key == "gridVideoRenderer" {
+					if renderer, ok := child.(map[string]any); ok {
+						if video, ok := parseChannelVideo(renderer); ok && !seen[video.id] {
+							seen[video.id] = true
+							out = append(out, video)
+						}
+						continue
+					}
+				}
+				walk(child)
+			}
+		case []any:
+			for _, child := range node {
+				walk(child)
+			}
+		}
+	}
+	// Map iteration is unordered; walk the tab contents in order so "first"
+	// means first on the page.
+	if tabs := digSlice(data, "contents", "twoColumnBrowseResultsRenderer", "tabs"); tabs != nil {
+		walk(tabs)
+	} else {
+		walk(data)
+	}
+	return out
+}
+
+func parseChannelVideo(renderer map[string]any) (channelVideo, bool) {
+	video := channelVideo{id: strings.TrimSpace(stringField(renderer, "videoId"))}
+	if video.id == "" {
+		return channelVideo{}, false
+	}
+	video.title = textField(renderer, "title")
+	if overlays, ok := renderer["thumbnailOverlays"].([]any); ok {
+		for _, overlay := range overlays {
+			status := digMap(asMap(overlay), "thumbnailOverlayTimeStatusRenderer")
+			switch stringField(status, "style") {
+			case "LIVE":
+				video.live = true
+			case "UPCOMING":
+				video.upcoming = true
+			}
+		}
+	}
+	if badges, ok := renderer["badges"].([]any); ok {
+		for _, badge := range badges {
+			if stringField(digMap(asMap(badge), "metadataBadgeRenderer"), "style") == "BADGE_STYLE_TYPE_LIVE_NOW" {
+				video.live = true
+			}
+		}
+	}
+	if event := digMap(renderer, "upcomingEventData"); event != nil {
+		video.upcoming = !video.live
+		if secs, err := strconv.ParseInt(stringField(event, "startTime"), 10, 64); err == nil && secs > 0 {
+			video.scheduled = time.Unix(secs, 0).UTC()
+		}
+	}
+	return video, true
+}
+
+func asMap(v any) map[string]any {
+	m, _ := v.(map[string]any)
+	return m
+}
+
+// digSlice follows keys through nested maps and returns the slice at the end.
+func digSlice(m map[string]any, keys ...string) []any {
+	if len(keys) == 0 {
+		return nil
+	}
+	out, _ := digMap(m, keys[:len(keys)-1]...)[keys[len(keys)-1]].([]any)
+	return out
+}
+
+// SelectLive picks the live results to poll according to pref: the first
+// live stream, all of them, or the first whose title contains pref (falling
+// back to the first).
+func SelectLive(results []ResolveResult, pref string) []ResolveResult {
+	var live []ResolveResult
+	for _, res := range results {
+		if res.Live && res.WatchURL != "" {
+			live = append(live, res)
+		}
+	}
+	if len(live) == 0 {
+		return nil
+	}
+	pref = strings.ToLower(strings.TrimSpace(pref))
+	switch pref {
+	case LiveSelectAll:
+		return live
+	case "", LiveSelectFirst:
+		return live[:1]
+	}
+	for _, res := range live {
+		if strings.Contains(strings.ToLower(res.Title), pref) {
+			return []ResolveResult{res}
+		}
+	}
+	return live[:1]
+}
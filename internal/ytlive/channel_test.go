@@ -0,0 +1,87 @@
+package ytlive
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestChannelStreamsURL(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"UCabcdefghijklmnopqrstuv", "https://www.youtube.com/channel/UCabcdefghijklmnopqrstuv/streams"},
+		{"@creator", "https://www.youtube.com/@creator/streams"},
+		{"https://www.youtube.com/@creator/videos", "https://www.youtube.com/@creator/streams"},
+		{"youtube.com/channel/UCabcdefghijklmnopqrstuv", "https://www.youtube.com/channel/UCabcdefghijklmnopqrstuv/streams"},
+		{"https://www.youtube.com/c/Creator", "https://www.youtube.com/c/Creator/streams"},
+		{"https://www.youtube.com/@creator/live", ""},
+		{"https://www.youtube.com/watch?v=abc123", ""},
+		{"https://youtu.be/abc123", ""},
+	}
+	for _, tc := range tests {
+		got, _ := channelStreamsURL(tc.in)
+		if got != tc.want {
+			t.Fatalf("channelStreamsURL(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func streamsVideo(id, title, style string, extra string) string {
+	return fmt.Sprintf(`{"richItemRenderer":{"content":{"videoRenderer":{"videoId":%q,"title":{"runs":[{"text":%q}]},"thumbnailOverlays":[{"thumbnailOverlayTimeStatusRenderer":{"style":%q}}]%s}}}}`, id, title, style, extra)
+}
+
+func TestResolveChannel(t *testing.T) {
+	items := streamsVideo("live1", "Morning show", "LIVE", "") + "," +
+		streamsVideo("soon1", "Premiere night", "UPCOMING", `,"upcomingEventData":{"startTime":"1717243200"}`) + "," +
+		streamsVideo("live2", "Speedrun marathon", "LIVE", "") + "," +
+		streamsVideo("vod1", "Yesterday", "DEFAULT", "")
+	streams := `<script>var ytInitialData = {"contents":{"twoColumnBrowseResultsRenderer":{"tabs":[{"tabRenderer":{"content":{"richGridRenderer":{"contents":[` + items + `]}}}}]}}};</script>`
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("/channel/UCabcdefghijklmnopqrstuv/streams", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(streams))
+	})
+	handler.HandleFunc("/watch", func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("v")
+		fmt.Fprintf(w, `<script>var ytInitialPlayerResponse = {"videoDetails":{"videoId":%q,"isLive":true,"title":"%s (live)"}};</script>`, id, id)
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resolver := NewResolver(&http.Client{Transport: rewriteTransport(server.URL), Timeout: 2 * time.Second})
+	results, err := resolver.ResolveChannel(context.Background(), "UCabcdefghijklmnopqrstuv")
+	if err != nil {
+		t.Fatalf("ResolveChannel() error = %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected two live and one upcoming result, got %+v", results)
+	}
+	if !results[0].Live || results[0].ChatURL != "https://www.youtube.com/live_chat?v=live1" || results[0].Title != "live1 (live)" {
+		t.Fatalf("unexpected first result %+v", results[0])
+	}
+	if !results[1].Live || results[1].WatchURL != "https://www.youtube.com/watch?v=live2" {
+		t.Fatalf("unexpected second result %+v", results[1])
+	}
+	upcoming := results[2]
+	if upcoming.Live || !upcoming.Upcoming || upcoming.Title != "Premiere night" || !upcoming.ScheduledStart.Equal(time.Unix(1717243200, 0)) {
+		t.Fatalf("unexpected upcoming result %+v", upcoming)
+	}
+
+	if got := SelectLive(results, ""); len(got) != 1 || got[0].WatchURL != results[0].WatchURL {
+		t.Fatalf("default selection = %+v", got)
+	}
+	if got := SelectLive(results, "all"); len(got) != 2 {
+		t.Fatalf("all selection = %+v", got)
+	}
+	if got := SelectLive(results, "LIVE2"); len(got) != 1 || got[0].WatchURL != results[1].WatchURL {
+		t.Fatalf("title selection = %+v", got)
+	}
+	if got := SelectLive(results[2:], "all"); got != nil {
+		t.Fatalf("expected no live selection, got %+v", got)
+	}
+}
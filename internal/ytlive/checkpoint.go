@@ -0,0 +1,67 @@
+package ytlive
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// checkpointEvery bounds how often the poll position is saved. Resuming from
+// a slightly older continuation only replays chat the sinks already dedupe.
+const checkpointEvery = 10 * time.Second
+
+// CheckpointStore persists the poll position per video; *sink.SQLiteSink
+// satisfies it.
+type CheckpointStore interface {
+	YouTubeCheckpoint(ctx context.Context, videoID string) (core.YouTubeCheckpoint, bool, error)
+	SaveYouTubeCheckpoint(ctx context.Context, cp core.YouTubeCheckpoint) error
+}
+
+// resumeCheckpoint returns the stored position for liveURL's video when it
+// is recent enough to resume.
+func (c *Client) resumeCheckpoint(ctx context.Context, liveURL string) (core.YouTubeCheckpoint, bool) {
+	videoID := VideoID(liveURL)
+	if c.cfg.Checkpoints == nil || videoID == "" {
+		return core.YouTubeCheckpoint{}, false
+	}
+	cp, found, err := c.cfg.Checkpoints.YouTubeCheckpoint(ctx, videoID)
+	if err != nil {
+		log.Printf("ytlive: load checkpoint: %v", err)
+		return core.YouTubeCheckpoint{}, false
+	}
+	if !found || cp.Continuation == "" || cp.APIKey == "" || cp.ClientVersion == "" {
+		return core.YouTubeCheckpoint{}, false
+	}
+	age := time.Since(cp.UpdatedAt)
+	if c.cfg.CheckpointMaxAge > 0 && age > c.cfg.CheckpointMaxAge {
+		log.Printf("ytlive: checkpoint for %s is %s old, bootstrapping instead", videoID, age.Round(time.Second))
+		return core.YouTubeCheckpoint{}, false
+	}
+	log.Printf("ytlive: resuming %s from checkpoint saved %s ago", videoID, age.Round(time.Second))
+	return cp, true
+}
+
+// saveCheckpoint stores the current session for liveURL's video, at most
+// once per checkpointEvery.
+func (c *Client) saveCheckpoint(ctx context.Context, liveURL, apiKey, clientVersion, continuation string, now time.Time) {
+	videoID := VideoID(liveURL)
+	if c.cfg.Checkpoints == nil || videoID == "" || continuation == "" || now.Sub(c.checkpointAt) < checkpointEvery {
+		return
+	}
+	err := c.cfg.Checkpoints.SaveYouTubeCheckpoint(ctx, core.YouTubeCheckpoint{
+		VideoID:       videoID,
+		Continuation:  continuation,
+		APIKey:        apiKey,
+		ClientVersion: clientVersion,
+		UpdatedAt:     now,
+	})
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("ytlive: save checkpoint: %v", err)
+		}
+		return
+	}
+	c.checkpointAt = now
+}
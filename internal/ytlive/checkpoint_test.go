@@ -0,0 +1,118 @@
+package ytlive
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/testservers"
+)
+
+type memoryCheckpoints struct {
+	mu    sync.Mutex
+	saved map[string]core.YouTubeCheckpoint
+}
+
+func (m *memoryCheckpoints) YouTubeCheckpoint(_ context.Context, videoID string) (core.YouTubeCheckpoint, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp, ok := m.saved[videoID]
+	return cp, ok, nil
+}
+
+func (m *memoryCheckpoints) SaveYouTubeCheckpoint(_ context.Context, cp core.YouTubeCheckpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saved[cp.VideoID] = cp
+	return nil
+}
+
+func (m *memoryCheckpoints) get(videoID string) (core.YouTubeCheckpoint, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp, ok := m.saved[videoID]
+	return cp, ok
+}
+
+func TestRunResumesFromCheckpoint(t *testing.T) {
+	yt := testservers.NewYouTube("checkpoint1")
+	defer yt.Close()
+	yt.SetPollInterval(time.Hour)
+	store := &memoryCheckpoints{saved: map[string]core.YouTubeCheckpoint{}}
+
+	run := func(rep *recordingReporter, handler Handler, until func() bool) {
+		t.Helper()
+		client := New(Config{
+			LiveURL:          yt.WatchURL(),
+			Transport:        yt.Transport(),
+			Reporter:         rep,
+			Checkpoints:      store,
+			CheckpointMaxAge: time.Minute,
+		}, handler)
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() { done <- client.Run(ctx) }()
+		deadline := time.Now().Add(5 * time.Second)
+		for !until() {
+			if time.Now().After(deadline) {
+				cancel()
+				t.Fatal("timed out")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		cancel()
+		<-done
+	}
+
+	first := &recordingReporter{polls: map[string]int{}, bootstraps: map[bool]int{}, resets: map[string]int{}}
+	run(first, nil, func() bool {
+		_, ok := store.get("checkpoint1")
+		return ok
+	})
+	cp, _ := store.get("checkpoint1")
+	if cp.Continuation != "fake-cont-1" || cp.APIKey != testservers.YouTubeAPIKey || cp.ClientVersion != testservers.YouTubeClientVersion {
+		t.Fatalf("checkpoint = %+v", cp)
+	}
+
+	// Chat posted while the harvester was down is picked up by polling the
+	// saved continuation, without fetching the watch page again.
+	yt.Push(testservers.YouTubeMessage{ID: "during-restart", Author: "Ann", Text: "still here"})
+	second := &recordingReporter{polls: map[string]int{}, bootstraps: map[bool]int{}, resets: map[string]int{}}
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	run(second, func(msg core.ChatMessage) {
+		mu.Lock()
+		got = append(got, msg.ID)
+		mu.Unlock()
+	}, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(got) > 0
+	})
+	if got[0] != "during-restart" {
+		t.Fatalf("got %v", got)
+	}
+	second.mu.Lock()
+	defer second.mu.Unlock()
+	if len(second.bootstraps) != 0 {
+		t.Fatalf("resumed client bootstrapped: %v", second.bootstraps)
+	}
+}
+
+func TestResumeCheckpointIgnoresStale(t *testing.T) {
+	store := &memoryCheckpoints{saved: map[string]core.YouTubeCheckpoint{
+		"old": {VideoID: "old", Continuation: "c", APIKey: "k", ClientVersion: "v", UpdatedAt: time.Now().Add(-time.Hour)},
+	}}
+	client := New(Config{Checkpoints: store, CheckpointMaxAge: time.Minute}, nil)
+	if _, ok := client.resumeCheckpoint(context.Background(), "https://www.youtube.com/watch?v=old"); ok {
+		t.Fatal("resumed a checkpoint older than CheckpointMaxAge")
+	}
+	client.cfg.CheckpointMaxAge = 2 * time.Hour
+	if cp, ok := client.resumeCheckpoint(context.Background(), "https://www.youtube.com/watch?v=old"); !ok || cp.Continuation != "c" {
+		t.Fatalf("resume = %+v %t", cp, ok)
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"time"
 	"unicode/utf16"
 
+	"github.com/you/gnasty-chat/internal/backoff"
 	"github.com/you/gnasty-chat/internal/core"
 )
 
@@ -26,16 +27,57 @@ type Config struct {
 	PollTimeoutSecs int
 	PollIntervalMS  int
 	Debug           bool
+	// Backoff paces retries after bootstrap and poll failures.
+	Backoff backoff.Policy
+	// Transport carries the page and Innertube requests; nil uses
+	// http.DefaultTransport.
+	Transport http.RoundTripper
+	// Jar supplies signed-in cookies (see LoadCookieJar); Innertube polls
+	// then carry a SAPISIDHASH authorization.
+	Jar http.CookieJar
+	// Reporter, if set, receives poll, bootstrap and continuation counters.
+	Reporter Reporter
+	// Checkpoints, if set, keeps the poll position of watch URLs so Run
+	// resumes a video after a restart without a fresh bootstrap.
+	Checkpoints CheckpointStore
+	// CheckpointMaxAge is how old a stored position may be and still be
+	// resumed; zero means no limit.
+	CheckpointMaxAge time.Duration
 }
 
 type Handler func(core.ChatMessage)
 
+// UpdateHandler receives edits to messages that were already delivered.
+type UpdateHandler func(core.MessageUpdate)
+
+// DeleteHandler receives moderation removals of delivered messages.
+type DeleteHandler func(core.MessageDeletion)
+
+// PollHandler receives chat poll updates.
+type PollHandler func(core.Poll)
+
+// ErrorHandler receives failures tagged with their core.ErrorKind.
+type ErrorHandler func(error)
+
+// GapHandler receives a window in which chat may have been missed because
+// the continuation was lost and polling restarted from a fresh bootstrap.
+type GapHandler func(start, end time.Time)
+
 type Client struct {
 	cfg         Config
 	handler     Handler
+	onUpdate    UpdateHandler
+	onDelete    DeleteHandler
+	onPoll      PollHandler
+	onError     ErrorHandler
+	onGap       GapHandler
+	polls       *pollTracker
 	http        *http.Client
+	agents      userAgentRotator
 	pollDelay   time.Duration
 	pollTimeout time.Duration
+	// checkpointAt is when the poll position was last saved.
+	checkpointAt time.Time
 }
 
 const (
@@ -62,7 +104,7 @@ type ytEmote struct {
 }
 
 func New(cfg Config, handler Handler) *Client {
-	httpClient := &http.Client{}
+	httpClient := &http.Client{Transport: cfg.Transport, Jar: cfg.Jar}
 
 	timeout := defaultPollTimeout
 	switch {
@@ -89,6 +131,49 @@ func New(cfg Config, handler Handler) *Client {
 	}
 }
 
+// OnUpdate registers h to receive message edits. It must be called before
+// Run.
+func (c *Client) OnUpdate(h UpdateHandler) {
+	c.onUpdate = h
+}
+
+// OnDelete registers h to receive message deletions. It must be called
+// before Run.
+func (c *Client) OnDelete(h DeleteHandler) {
+	c.onDelete = h
+}
+
+// OnPoll registers h to receive chat poll updates, including a final
+// "ended" report when the poll panel closes. It must be called before Run.
+func (c *Client) OnPoll(h PollHandler) {
+	c.onPoll = h
+	c.polls = newPollTracker()
+}
+
+// OnError registers h to receive bootstrap and poll failures and chat
+// messages that could not be parsed. It must be called before Run.
+func (c *Client) OnError(h ErrorHandler) {
+	c.onError = h
+}
+
+// OnGap registers h to receive windows of possibly missed chat, for
+// backfill from the live chat replay. It must be called before Run.
+func (c *Client) OnGap(h GapHandler) {
+	c.onGap = h
+}
+
+func (c *Client) reportGap(start, end time.Time) {
+	if c.onGap != nil && end.After(start) {
+		c.onGap(start, end)
+	}
+}
+
+func (c *Client) reportError(err error) {
+	if c.onError != nil && err != nil {
+		c.onError(err)
+	}
+}
+
 func (c *Client) Run(ctx context.Context) error {
 	liveURL := strings.TrimSpace(c.cfg.LiveURL)
 	if liveURL == "" {
@@ -98,35 +183,46 @@ func (c *Client) Run(ctx context.Context) error {
 		return fmt.Errorf("ytlive: invalid LiveURL: %w", err)
 	}
 
-	backoff := time.Second
-	const maxBackoff = 60 * time.Second
+	retry := backoff.New(c.cfg.Backoff)
 
 	var (
 		apiKey        string
 		clientVersion string
 		continuation  string
-		totalMessages int
-		lastLog       = time.Now()
+		// refreshed is set after a rejected poll swapped in a fresh key and
+		// client version, so a second rejection falls back to a full
+		// re-bootstrap.
+		refreshed bool
 	)
+	var (
+		// lastPoll is when chat was last known to be complete, and
+		// gapStart the lastPoll before a discarded continuation; the gap
+		// is reported once polling recovers.
+		lastPoll time.Time
+		gapStart time.Time
+	)
+	if cp, ok := c.resumeCheckpoint(ctx, liveURL); ok {
+		apiKey, clientVersion, continuation = cp.APIKey, cp.ClientVersion, cp.Continuation
+		lastPoll = cp.UpdatedAt
+	}
+	// Messages are tagged with their video's session (see SessionID) so
+	// consumers can tell concurrent streams apart.
+	sessionID := SessionID(liveURL)
 
 	bootstrap := func() bool {
 		var err error
 		apiKey, clientVersion, continuation, err = c.bootstrap(ctx, liveURL)
+		if ctx.Err() == nil {
+			c.reportBootstrap(err)
+		}
 		if err != nil {
 			log.Printf("ytlive: bootstrap failed: %v", err)
-			if !sleepContext(ctx, backoff) {
-				return false
-			}
-			if backoff < maxBackoff {
-				backoff *= 2
-				if backoff > maxBackoff {
-					backoff = maxBackoff
-				}
-			}
+			c.reportError(fmt.Errorf("ytlive: bootstrap: %w", err))
+			sleepContext(ctx, retry.Next())
 			return false
 		}
 		log.Printf("ytlive: bootstrap succeeded (version=%s)", clientVersion)
-		backoff = time.Second
+		retry.Reset()
 		return true
 	}
 
@@ -161,21 +257,47 @@ func (c *Client) Run(ctx context.Context) error {
 		}
 		if err != nil {
 			log.Printf("ytlive: poll error: %v", err)
-			if !sleepContext(ctx, backoff) {
-				return ctx.Err()
+			c.reportError(err)
+			if ctx.Err() == nil {
+				c.reportPoll(err)
 			}
-			if backoff < maxBackoff {
-				backoff *= 2
-				if backoff > maxBackoff {
-					backoff = maxBackoff
+			if staleSession(err) && !refreshed {
+				// Keep the continuation so no chat is skipped; only the
+				// key, client version and user agent are replaced.
+				c.agents.rotate()
+				key, version, _, berr := c.bootstrap(ctx, liveURL)
+				c.reportBootstrap(berr)
+				if berr == nil {
+					log.Printf("ytlive: poll rejected, refreshed innertube key and client version (version=%s)", version)
+					apiKey, clientVersion, refreshed = key, version, true
+					continue
 				}
+				log.Printf("ytlive: refresh after rejected poll failed: %v", berr)
+			}
+			if !sleepContext(ctx, retry.Next()) {
+				return ctx.Err()
 			}
 			apiKey, clientVersion, continuation = "", "", ""
+			refreshed = false
+			c.reportContinuationReset(ResetPollError)
+			if gapStart.IsZero() {
+				gapStart = lastPoll
+			}
 			continue
 		}
+		refreshed = false
+		c.reportPoll(nil)
+		lastPoll = time.Now()
+		if !gapStart.IsZero() {
+			c.reportGap(gapStart, lastPoll)
+			gapStart = time.Time{}
+		}
 
 		if len(messages) > 0 && c.handler != nil {
 			for _, msg := range messages {
+				if msg.SessionID == "" {
+					msg.SessionID = sessionID
+				}
 				c.handler(msg)
 			}
 		}
@@ -190,16 +312,16 @@ func (c *Client) Run(ctx context.Context) error {
 			)
 		}
 
-		totalMessages += len(messages)
-		if time.Since(lastLog) >= 10*time.Second {
-			log.Printf("ytlive: received %d messages (total %d)", len(messages), totalMessages)
-			lastLog = time.Now()
-		}
-
 		continuation = nextContinuation
 		if continuation == "" {
 			log.Printf("ytlive: missing continuation, re-bootstrap")
 			apiKey, clientVersion, continuation = "", "", ""
+			c.reportContinuationReset(ResetMissing)
+			if gapStart.IsZero() {
+				gapStart = lastPoll
+			}
+		} else {
+			c.saveCheckpoint(ctx, liveURL, apiKey, clientVersion, continuation, time.Now())
 		}
 
 		delay, fromContinuation := nextLivePollDelay(timeoutMs, hasTimeout, c.pollDelay)
@@ -211,12 +333,21 @@ func (c *Client) Run(ctx context.Context) error {
 			}
 			log.Printf("ytlive: next poll in %dms (fallback)", delay.Milliseconds())
 		}
+		c.reportPollInterval(delay)
 		if !sleepContext(ctx, delay) {
 			return ctx.Err()
 		}
 	}
 }
 
+// statusErrorKind classifies a failed HTTP response.
+func statusErrorKind(status int) core.ErrorKind {
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return core.ErrorAuth
+	}
+	return core.ErrorNetwork
+}
+
 func (c *Client) pollTimeoutString() string {
 	if c.pollTimeout <= 0 {
 		return "none"
@@ -229,21 +360,24 @@ func (c *Client) bootstrap(ctx context.Context, liveURL string) (apiKey, clientV
 	if err != nil {
 		return "", "", "", err
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; ytlive-harvester/1.0)")
+	setBrowserHeaders(req, c.agents.current(), c.http.Jar)
 
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return "", "", "", err
+		return "", "", "", core.NewError(core.ErrorNetwork, err)
 	}
 	defer resp.Body.Close()
+	if isConsentPage(resp.Request.URL) {
+		return "", "", "", core.NewError(core.ErrorAuth, errConsentRequired)
+	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", "", "", fmt.Errorf("unexpected status %s", resp.Status)
+		return "", "", "", core.NewError(statusErrorKind(resp.StatusCode), fmt.Errorf("unexpected status %s", resp.Status))
 	}
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
 	if err != nil {
-		return "", "", "", err
+		return "", "", "", core.NewError(core.ErrorNetwork, err)
 	}
 	text := string(body)
 
@@ -251,35 +385,17 @@ func (c *Client) bootstrap(ctx context.Context, liveURL string) (apiKey, clientV
 	clientVersion = extractString(text, `"INNERTUBE_CLIENT_VERSION":"`)
 
 	if apiKey == "" || clientVersion == "" {
-		return "", "", "", errors.New("ytlive: could not locate api key or client version")
+		return "", "", "", core.NewError(core.ErrorParse, errors.New("ytlive: could not locate api key or client version"))
 	}
 
-	var initJSON string
-	markers := []string{
-		`ytInitialData"] = `,
-		`ytInitialData" = `,
-		`ytInitialData":`,
-		`ytInitialData = `,
-		`window["ytInitialData"] = `,
-	}
-	for _, marker := range markers {
-		initJSON = extractJSONObject(text, marker)
-		if initJSON != "" {
-			break
-		}
-	}
-	if initJSON == "" {
-		return "", "", "", errors.New("ytlive: could not locate initial data")
-	}
-
-	var data map[string]any
-	if err := json.Unmarshal([]byte(initJSON), &data); err != nil {
-		return "", "", "", fmt.Errorf("ytlive: parse initial data: %w", err)
+	data, err := extractInitialData(text)
+	if err != nil {
+		return "", "", "", err
 	}
 
 	continuation = findInitialContinuation(data)
 	if continuation == "" {
-		return "", "", "", errors.New("ytlive: continuation not found in initial data")
+		return "", "", "", core.NewError(core.ErrorParse, errors.New("ytlive: continuation not found in initial data"))
 	}
 
 	return apiKey, clientVersion, continuation, nil
@@ -313,22 +429,28 @@ func (c *Client) poll(ctx context.Context, apiKey, clientVersion, continuation s
 		return nil, continuation, 0, false, err
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; ytlive-harvester/1.0)")
+	setBrowserHeaders(req, c.agents.current(), c.http.Jar)
+	if auth := sapisidHash(c.http.Jar, youtubeOrigin, time.Now()); auth != "" {
+		req.Header.Set("Authorization", auth)
+		req.Header.Set("X-Origin", youtubeOrigin)
+		req.Header.Set("X-Goog-AuthUser", "0")
+	}
 
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return nil, continuation, 0, false, err
+		return nil, continuation, 0, false, core.NewError(core.ErrorNetwork, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
-		return nil, continuation, 0, false, fmt.Errorf("ytlive: poll status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+		err := &pollStatusError{status: resp.StatusCode, text: resp.Status, body: strings.TrimSpace(string(body))}
+		return nil, continuation, 0, false, core.NewError(statusErrorKind(resp.StatusCode), err)
 	}
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
 	if err != nil {
-		return nil, continuation, 0, false, err
+		return nil, continuation, 0, false, core.NewError(core.ErrorNetwork, err)
 	}
 
 	if c.cfg.Debug {
@@ -342,7 +464,7 @@ func (c *Client) poll(ctx context.Context, apiKey, clientVersion, continuation s
 
 	var payloadResp map[string]any
 	if err := json.Unmarshal(body, &payloadResp); err != nil {
-		return nil, continuation, 0, false, fmt.Errorf("ytlive: decode poll response: %w", err)
+		return nil, continuation, 0, false, core.NewError(core.ErrorParse, fmt.Errorf("ytlive: decode poll response: %w", err))
 	}
 
 	continuation, timeout, hasTimeout := extractContinuation(payloadResp)
@@ -360,6 +482,27 @@ func (c *Client) poll(ctx context.Context, apiKey, clientVersion, continuation s
 	}
 
 	logPollResults(summary, failures, nonChats, c.cfg.DumpUnhandled)
+	c.reportActions(summary, failures)
+	for _, failure := range failures {
+		c.reportError(core.NewError(core.ErrorParse, fmt.Errorf("ytlive: dropped chat message: %s", failure.reason)))
+	}
+
+	if c.onUpdate != nil {
+		for _, upd := range extractUpdates(payloadResp, time.Now().UTC()) {
+			c.onUpdate(upd)
+		}
+	}
+	if c.onDelete != nil {
+		for _, del := range extractDeletions(payloadResp, time.Now().UTC()) {
+			c.onDelete(del)
+		}
+	}
+
+	if c.onPoll != nil {
+		for _, poll := range c.polls.observe(payloadResp, time.Now().UTC()) {
+			c.onPoll(poll)
+		}
+	}
 
 	return messages, continuation, timeout, hasTimeout, nil
 }
@@ -452,7 +595,20 @@ func extractMessages(payload map[string]any) ([]core.ChatMessage, pollSummary, [
 	)
 
 	for _, action := range actions {
-		renderers := collectTextRenderers(action)
+		if _, ok := action["replaceChatItemAction"]; ok {
+			// Edits are reported by extractUpdates, not as new messages.
+			continue
+		}
+		if _, ok := action["markChatItemAsDeletedAction"]; ok {
+			continue // reported by extractDeletions
+		}
+		if _, ok := action["markChatItemsByAuthorAsDeletedAction"]; ok {
+			continue // reported by extractDeletions
+		}
+		if _, ok := action["updateLiveChatPollAction"]; ok {
+			continue // reported by pollTracker
+		}
+		renderers := collectChatRenderers(action)
 		if len(renderers) == 0 {
 			nonChats = append(nonChats, nonChatAction{
 				actionType: detectActionType(action),
@@ -464,12 +620,13 @@ func extractMessages(payload map[string]any) ([]core.ChatMessage, pollSummary, [
 
 		summary.chatMessages += len(renderers)
 		for _, renderer := range renderers {
-			if msg, ok, reason := buildMessage(renderer); ok {
+			if msg, ok, reason := buildMessage(renderer.fields); ok {
+				msg.MessageType = renderer.msgType
 				messages = append(messages, msg)
 				continue
 			} else {
 				failures = append(failures, chatFailure{
-					id:     shortActionID(renderer),
+					id:     shortActionID(renderer.fields),
 					reason: reason,
 				})
 			}
@@ -485,6 +642,61 @@ func extractMessages(payload map[string]any) ([]core.ChatMessage, pollSummary, [
 	return messages, summary, failures, nonChats
 }
 
+// extractUpdates returns the message edits carried by replaceChatItemAction,
+// which YouTube sends when an existing chat item's content changes.
+func extractUpdates(payload map[string]any, now time.Time) []core.MessageUpdate {
+	var out []core.MessageUpdate
+	for _, action := range gatherActions(payload) {
+		replace, ok := action["replaceChatItemAction"].(map[string]any)
+		if !ok {
+			continue
+		}
+		item, ok := replace["replacementItem"].(map[string]any)
+		if !ok {
+			continue
+		}
+		for _, renderer := range collectChatRenderers(item) {
+			msg, ok, _ := buildMessage(renderer.fields)
+			if !ok {
+				continue
+			}
+			id := stringField(replace, "targetItemId")
+			if id == "" {
+				id = msg.PlatformMsgID
+			}
+			out = append(out, core.MessageUpdate{
+				Platform:   "YouTube",
+				ID:         id,
+				Text:       msg.Text,
+				EmotesJSON: msg.EmotesJSON,
+				EditedAt:   now,
+			})
+		}
+	}
+	return out
+}
+
+// extractDeletions returns the removals carried by markChatItemAsDeletedAction
+// (one message) and markChatItemsByAuthorAsDeletedAction (everything an
+// author sent, as when they are banned or timed out).
+func extractDeletions(payload map[string]any, now time.Time) []core.MessageDeletion {
+	var out []core.MessageDeletion
+	for _, action := range gatherActions(payload) {
+		if mark, ok := action["markChatItemAsDeletedAction"].(map[string]any); ok {
+			if id := stringField(mark, "targetItemId"); id != "" {
+				out = append(out, core.MessageDeletion{Platform: "YouTube", ID: id, DeletedAt: now, DeletedBy: "youtube:delete"})
+			}
+			continue
+		}
+		if mark, ok := action["markChatItemsByAuthorAsDeletedAction"].(map[string]any); ok {
+			if author := stringField(mark, "externalChannelId"); author != "" {
+				out = append(out, core.MessageDeletion{Platform: "YouTube", Author: author, DeletedAt: now, DeletedBy: "youtube:author"})
+			}
+		}
+	}
+	return out
+}
+
 func gatherActions(payload map[string]any) []map[string]any {
 	var out []map[string]any
 	collect := func(arr []any) {
@@ -508,20 +720,41 @@ func gatherActions(payload map[string]any) []map[string]any {
 	return out
 }
 
-func collectTextRenderers(action map[string]any) []map[string]any {
-	var renderers []map[string]any
-	keys := []string{"liveChatTextMessageRenderer", "liveChatLegacyTextMessageRenderer"}
+// chatRendererTypes maps the renderers stored as messages to their
+// message type.
+var chatRendererTypes = map[string]string{
+	"liveChatTextMessageRenderer":       core.MessageTypeChat,
+	"liveChatLegacyTextMessageRenderer": core.MessageTypeChat,
+	"liveChatPaidMessageRenderer":       core.MessageTypeSuperchat,
+	"liveChatPaidStickerRenderer":       core.MessageTypeSuperchat,
+	"liveChatMembershipItemRenderer":    core.MessageTypeSub,
+}
+
+// chatRenderer is a renderer found by collectChatRenderers.
+type chatRenderer struct {
+	msgType string
+	fields  map[string]any
+}
+
+// collectChatRenderers returns the message renderers nested in action.
+// Ticker items and action panels repeat items already delivered as chat
+// items, so they are not searched.
+func collectChatRenderers(action map[string]any) []chatRenderer {
+	var renderers []chatRenderer
 
 	var walk func(any)
 	walk = func(v any) {
 		switch val := v.(type) {
 		case map[string]any:
-			for _, key := range keys {
-				if renderer, ok := val[key].(map[string]any); ok {
-					renderers = append(renderers, renderer)
+			for key, child := range val {
+				if key == "addLiveChatTickerItemAction" || key == "showLiveChatActionPanelAction" {
+					continue
+				}
+				if msgType, ok := chatRendererTypes[key]; ok {
+					if renderer, ok := child.(map[string]any); ok {
+						renderers = append(renderers, chatRenderer{msgType: msgType, fields: renderer})
+					}
 				}
-			}
-			for _, child := range val {
 				walk(child)
 			}
 		case []any:
@@ -543,6 +776,7 @@ func detectActionType(action map[string]any) string {
 		"addLiveChatItemAction",
 		"markChatItemAsDeletedAction",
 		"markChatItemsByAuthorAsDeletedAction",
+		"replaceChatItemAction",
 		"liveChatItemListRenderer",
 		"addLiveChatWarningMessageAction",
 		"showLiveChatActionPanelAction",
@@ -644,6 +878,14 @@ func truncateString(s string, limit int) string {
 func buildMessage(renderer map[string]any) (core.ChatMessage, bool, string) {
 	badges, badgesRaw := parseYouTubeBadges(renderer)
 	text, emotes := messageTextAndEmotes(renderer)
+	if text == "" {
+		// Super Stickers and new-member items carry no message.
+		for _, key := range []string{"purchaseAmountText", "headerSubtext", "headerPrimaryText"} {
+			if text = textField(renderer, key); text != "" {
+				break
+			}
+		}
+	}
 
 	msg := core.ChatMessage{
 		ID:            stringField(renderer, "id"),
@@ -651,8 +893,14 @@ func buildMessage(renderer map[string]any) (core.ChatMessage, bool, string) {
 		Username:      textField(renderer, "authorName"),
 		Platform:      "YouTube",
 		Text:          text,
+		MessageType:   core.MessageTypeChat,
 		Badges:        badges,
 		BadgesRaw:     badgesRaw,
+
+		AuthorChannelID: stringField(renderer, "authorExternalChannelId"),
+		UserID:          stringField(renderer, "authorExternalChannelId"),
+		AvatarURL:       authorPhotoURL(renderer),
+		PaidAmount:      textField(renderer, "purchaseAmountText"),
 	}
 	if len(emotes) > 0 {
 		if data, err := json.Marshal(emotes); err == nil {
@@ -665,13 +913,20 @@ func buildMessage(renderer map[string]any) (core.ChatMessage, bool, string) {
 	if msg.Text == "" {
 		return core.ChatMessage{}, false, "empty text"
 	}
+	received := time.Now().UTC()
+	msg.Ts = timestampField(renderer, "timestampUsec")
+	msg.ReceivedAt = &received
 	if msg.ID == "" {
-		msg.ID = fmt.Sprintf("yt-%d", time.Now().UnixNano())
+		idTime := msg.Ts
+		if idTime.IsZero() {
+			idTime = received
+		}
+		msg.ULID = core.NewID(idTime)
+		msg.ID = msg.ULID
 	}
 	if msg.PlatformMsgID == "" {
 		msg.PlatformMsgID = msg.ID
 	}
-	msg.Ts = timestampField(renderer, "timestampUsec")
 	return msg, true, ""
 }
 
@@ -983,6 +1238,36 @@ func emojiImages(emoji map[string]any) []ytEmoteImage {
 	return images
 }
 
+// authorPhotoURL returns the largest authorPhoto thumbnail.
+func authorPhotoURL(renderer map[string]any) string {
+	photo, ok := renderer["authorPhoto"].(map[string]any)
+	if !ok {
+		return ""
+	}
+	thumbs, ok := photo["thumbnails"].([]any)
+	if !ok {
+		return ""
+	}
+	best, bestSize := "", -1
+	for _, entry := range thumbs {
+		thumb, ok := entry.(map[string]any)
+		if !ok {
+			continue
+		}
+		url := stringField(thumb, "url")
+		if url == "" {
+			continue
+		}
+		if size := intField(thumb, "width") * intField(thumb, "height"); size > bestSize {
+			best, bestSize = url, size
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return normalizeImageURL(best)
+}
+
 func normalizeImageURL(raw string) string {
 	raw = strings.TrimSpace(raw)
 	if strings.HasPrefix(raw, "//") {
@@ -1043,6 +1328,34 @@ func timestampField(m map[string]any, key string) time.Time {
 	return ts
 }
 
+// extractInitialData decodes the ytInitialData object embedded in a watch
+// or live chat page.
+func extractInitialData(text string) (map[string]any, error) {
+	var initJSON string
+	markers := []string{
+		`ytInitialData"] = `,
+		`ytInitialData" = `,
+		`ytInitialData":`,
+		`ytInitialData = `,
+		`window["ytInitialData"] = `,
+	}
+	for _, marker := range markers {
+		initJSON = extractJSONObject(text, marker)
+		if initJSON != "" {
+			break
+		}
+	}
+	if initJSON == "" {
+		return nil, core.NewError(core.ErrorParse, errors.New("ytlive: could not locate initial data"))
+	}
+
+	var data map[string]any
+	if err := json.Unmarshal([]byte(initJSON), &data); err != nil {
+		return nil, core.NewError(core.ErrorParse, fmt.Errorf("ytlive: parse initial data: %w", err))
+	}
+	return data, nil
+}
+
 func extractJSONObject(text, marker string) string {
 	idx := strings.Index(text, marker)
 	if idx == -1 {
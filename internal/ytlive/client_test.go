@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"log"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
 )
 
 func TestNewNormalizesTimingDefaults(t *testing.T) {
@@ -145,7 +148,10 @@ func TestExtractMessagesAndLogging(t *testing.T) {
 				"addChatItemAction": map[string]any{
 					"item": map[string]any{
 						"liveChatPaidMessageRenderer": map[string]any{
-							"id": "nonchat-1",
+							"id":                 "paid-1",
+							"timestampUsec":      "1234567890",
+							"authorName":         map[string]any{"simpleText": "User4"},
+							"purchaseAmountText": map[string]any{"simpleText": "$5.00"},
 						},
 					},
 				},
@@ -163,26 +169,32 @@ func TestExtractMessagesAndLogging(t *testing.T) {
 	}
 
 	messages, summary, failures, nonChats := extractMessages(payload)
-	if len(messages) != 3 {
-		t.Fatalf("expected 3 messages, got %d", len(messages))
+	if len(messages) != 4 {
+		t.Fatalf("expected 4 messages, got %d", len(messages))
+	}
+	if messages[0].MessageType != core.MessageTypeChat {
+		t.Fatalf("expected chat type, got %q", messages[0].MessageType)
+	}
+	if paid := messages[3]; paid.MessageType != core.MessageTypeSuperchat || paid.Text != "$5.00" || paid.PaidAmount != "$5.00" {
+		t.Fatalf("unexpected super chat %+v", paid)
 	}
 	if summary.actions != 5 {
 		t.Fatalf("expected 5 actions, got %d", summary.actions)
 	}
-	if summary.chatMessages != 3 {
-		t.Fatalf("expected 3 chat messages, got %d", summary.chatMessages)
+	if summary.chatMessages != 4 {
+		t.Fatalf("expected 4 chat messages, got %d", summary.chatMessages)
 	}
-	if summary.stored != 3 {
-		t.Fatalf("expected 3 stored messages, got %d", summary.stored)
+	if summary.stored != 4 {
+		t.Fatalf("expected 4 stored messages, got %d", summary.stored)
 	}
-	if summary.skipped != 2 {
-		t.Fatalf("expected 2 skipped actions, got %d", summary.skipped)
+	if summary.skipped != 1 {
+		t.Fatalf("expected 1 skipped action, got %d", summary.skipped)
 	}
 	if len(failures) != 0 {
 		t.Fatalf("expected no failures, got %d", len(failures))
 	}
-	if len(nonChats) != 2 {
-		t.Fatalf("expected 2 non-chat actions, got %d", len(nonChats))
+	if len(nonChats) != 1 {
+		t.Fatalf("expected 1 non-chat action, got %d", len(nonChats))
 	}
 
 	var buf bytes.Buffer
@@ -192,14 +204,14 @@ func TestExtractMessagesAndLogging(t *testing.T) {
 
 	logPollResults(summary, failures, nonChats, false)
 	output := buf.String()
-	if !strings.Contains(output, "ytlive: poll summary actions=5 chat_messages=3 stored=3 skipped=2") {
+	if !strings.Contains(output, "ytlive: poll summary actions=5 chat_messages=4 stored=4 skipped=1") {
 		t.Fatalf("missing poll summary log, got %q", output)
 	}
 	if strings.Contains(output, "unhandled action dump") {
 		t.Fatalf("unexpected dump without env set: %q", output)
 	}
-	if count := strings.Count(output, "ytlive: skipped non-chat action"); count != 2 {
-		t.Fatalf("expected 2 skip logs, got %d in %q", count, output)
+	if count := strings.Count(output, "ytlive: skipped non-chat action"); count != 1 {
+		t.Fatalf("expected 1 skip log, got %d in %q", count, output)
 	}
 
 	buf.Reset()
@@ -540,3 +552,151 @@ func TestBuildMessageEmotes(t *testing.T) {
 		t.Fatalf("expected https normalization, got %q", emote.Images[1].URL)
 	}
 }
+
+func TestBuildMessageAuthorMetadata(t *testing.T) {
+	renderer := map[string]any{
+		"id":                      "msg-author",
+		"timestampUsec":           "1700000000000000",
+		"authorName":              map[string]any{"simpleText": "User"},
+		"authorExternalChannelId": "UC123",
+		"authorPhoto": map[string]any{
+			"thumbnails": []any{
+				map[string]any{"url": "//yt4.ggpht.com/a/s32", "width": 32, "height": 32},
+				map[string]any{"url": "https://yt4.ggpht.com/a/s64", "width": 64, "height": 64},
+			},
+		},
+		"message": map[string]any{"simpleText": "hi"},
+	}
+
+	msg, ok, reason := buildMessage(renderer)
+	if !ok {
+		t.Fatalf("expected message, got reason %q", reason)
+	}
+	if msg.AuthorChannelID != "UC123" {
+		t.Fatalf("unexpected author channel id %q", msg.AuthorChannelID)
+	}
+	if msg.AvatarURL != "https://yt4.ggpht.com/a/s64" {
+		t.Fatalf("expected largest avatar, got %q", msg.AvatarURL)
+	}
+}
+
+func TestExtractUpdates(t *testing.T) {
+	edited := map[string]any{
+		"id":            "chat-1",
+		"timestampUsec": "1234567890",
+		"authorName":    map[string]any{"simpleText": "User1"},
+		"message":       map[string]any{"simpleText": "Hello world (fixed)"},
+	}
+	payload := map[string]any{
+		"actions": []any{
+			map[string]any{
+				"replaceChatItemAction": map[string]any{
+					"targetItemId": "chat-1",
+					"replacementItem": map[string]any{
+						"liveChatTextMessageRenderer": edited,
+					},
+				},
+			},
+		},
+	}
+
+	now := time.Unix(1700000000, 0).UTC()
+	updates := extractUpdates(payload, now)
+	if len(updates) != 1 {
+		t.Fatalf("expected 1 update, got %d", len(updates))
+	}
+	upd := updates[0]
+	if upd.ID != "chat-1" || upd.Platform != "YouTube" || upd.Text != "Hello world (fixed)" || !upd.EditedAt.Equal(now) {
+		t.Fatalf("unexpected update %+v", upd)
+	}
+
+	if messages, _, _, _ := extractMessages(payload); len(messages) != 0 {
+		t.Fatalf("expected edits not to be stored as new messages, got %d", len(messages))
+	}
+}
+
+func TestExtractDeletions(t *testing.T) {
+	payload := map[string]any{
+		"continuationContents": map[string]any{
+			"liveChatContinuation": map[string]any{
+				"actions": []any{
+					map[string]any{
+						"markChatItemAsDeletedAction": map[string]any{
+							"deletedStateMessage": map[string]any{"runs": []any{map[string]any{"text": "[message retracted]"}}},
+							"targetItemId":        "chat-1",
+						},
+					},
+					map[string]any{
+						"markChatItemsByAuthorAsDeletedAction": map[string]any{
+							"deletedStateMessage": map[string]any{"runs": []any{map[string]any{"text": "[message deleted]"}}},
+							"externalChannelId":   "UC123",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	now := time.Unix(1700000000, 0).UTC()
+	got := extractDeletions(payload, now)
+	want := []core.MessageDeletion{
+		{Platform: "YouTube", ID: "chat-1", DeletedAt: now, DeletedBy: "youtube:delete"},
+		{Platform: "YouTube", Author: "UC123", DeletedAt: now, DeletedBy: "youtube:author"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("extractDeletions = %+v, want %+v", got, want)
+	}
+
+	if messages, _, nonChats, _ := extractMessages(payload); len(messages) != 0 || len(nonChats) != 0 {
+		t.Fatalf("expected deletions to be skipped, got %d messages and %d non-chat actions", len(messages), len(nonChats))
+	}
+}
+
+func TestPollTracker(t *testing.T) {
+	pollRenderer := func(total string, ratios ...float64) map[string]any {
+		var choices []any
+		for i, ratio := range ratios {
+			choices = append(choices, map[string]any{
+				"text":      map[string]any{"runs": []any{map[string]any{"text": []string{"Yes", "No"}[i]}}},
+				"voteRatio": ratio,
+			})
+		}
+		return map[string]any{
+			"liveChatPollId": "poll-1",
+			"choices":        choices,
+			"header": map[string]any{"pollHeaderRenderer": map[string]any{
+				"pollQuestion": map[string]any{"runs": []any{map[string]any{"text": "Ship it?"}}},
+				"metadataText": map[string]any{"runs": []any{
+					map[string]any{"text": "Host"}, map[string]any{"text": " • "}, map[string]any{"text": total},
+				}},
+			}},
+		}
+	}
+	now := time.Unix(1700000000, 0).UTC()
+	tracker := newPollTracker()
+
+	shown := tracker.observe(map[string]any{"actions": []any{
+		map[string]any{"showLiveChatActionPanelAction": map[string]any{"panelToShow": map[string]any{
+			"liveChatActionPanelRenderer": map[string]any{"id": "panel-1", "contents": map[string]any{"pollRenderer": pollRenderer("0 votes", 0, 0)}},
+		}}},
+	}}, now)
+	if len(shown) != 1 || shown[0].ID != "poll-1" || shown[0].Title != "Ship it?" || shown[0].Status != "active" || len(shown[0].Options) != 2 {
+		t.Fatalf("unexpected shown poll %+v", shown)
+	}
+
+	updated := tracker.observe(map[string]any{"actions": []any{
+		map[string]any{"updateLiveChatPollAction": map[string]any{"pollToUpdate": map[string]any{"pollRenderer": pollRenderer("1,200 votes", 0.75, 0.25)}}},
+	}}, now.Add(time.Minute))
+	want := []core.PollOption{{Title: "Yes", Votes: 900, Percent: 75}, {Title: "No", Votes: 300, Percent: 25}}
+	if len(updated) != 1 || !reflect.DeepEqual(updated[0].Options, want) {
+		t.Fatalf("unexpected updated poll %+v", updated)
+	}
+
+	closed := tracker.observe(map[string]any{"actions": []any{
+		map[string]any{"closeLiveChatActionPanelAction": map[string]any{"targetPanelId": "panel-1"}},
+		map[string]any{"closeLiveChatActionPanelAction": map[string]any{"targetPanelId": "unrelated"}},
+	}}, now.Add(2*time.Minute))
+	if len(closed) != 1 || closed[0].Status != "ended" || !closed[0].EndedAt.Equal(now.Add(2*time.Minute)) || closed[0].Options[0].Votes != 900 {
+		t.Fatalf("unexpected closed poll %+v", closed)
+	}
+}
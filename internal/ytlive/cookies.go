@@ -0,0 +1,141 @@
+package ytlive
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const youtubeOrigin = "https://www.youtube.com"
+
+// LoadCookieJar reads a Netscape cookies.txt file, as exported by browser
+// extensions or yt-dlp, into a cookie jar. Signed-in cookies let the poller
+// read member-only and unlisted chats the account can see.
+func LoadCookieJar(path string) (http.CookieJar, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("ytlive: cookies: %w", err)
+	}
+	defer f.Close()
+	cookies, err := parseNetscapeCookies(f)
+	if err != nil {
+		return nil, fmt.Errorf("ytlive: cookies %s: %w", path, err)
+	}
+	jar, _ := cookiejar.New(nil)
+	for _, c := range cookies {
+		scheme := "http"
+		if c.cookie.Secure {
+			scheme = "https"
+		}
+		jar.SetCookies(&url.URL{Scheme: scheme, Host: c.host, Path: c.cookie.Path}, []*http.Cookie{c.cookie})
+	}
+	return jar, nil
+}
+
+// fileCookie is one cookies.txt entry and the host it was set for.
+type fileCookie struct {
+	host   string
+	cookie *http.Cookie
+}
+
+// parseNetscapeCookies parses the tab-separated cookies.txt format:
+// domain, include-subdomains, path, secure, expiry, name, value. Expired
+// cookies are skipped; an expiry of 0 is a session cookie.
+func parseNetscapeCookies(r io.Reader) ([]fileCookie, error) {
+	var out []fileCookie
+	now := time.Now()
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1<<20)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := strings.TrimRight(sc.Text(), "\r")
+		httpOnly := false
+		if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+			line, httpOnly = rest, true
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("line %d: expected 7 tab-separated fields, got %d", lineNo, len(fields))
+		}
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: bad expiry %q", lineNo, fields[4])
+		}
+		c := &http.Cookie{
+			Name:     fields[5],
+			Value:    strings.Join(fields[6:], "\t"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			HttpOnly: httpOnly,
+		}
+		host := strings.TrimPrefix(fields[0], ".")
+		if strings.EqualFold(fields[1], "TRUE") {
+			c.Domain = host
+		}
+		if expiry > 0 {
+			c.Expires = time.Unix(expiry, 0)
+			if c.Expires.Before(now) {
+				continue
+			}
+		}
+		out = append(out, fileCookie{host: host, cookie: c})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// sapisidHash returns the Authorization value YouTube expects on Innertube
+// requests from a signed-in session, or "" when jar has no SAPISID cookie.
+func sapisidHash(jar http.CookieJar, origin string, now time.Time) string {
+	if jar == nil {
+		return ""
+	}
+	u, _ := url.Parse(origin)
+	var sapisid string
+	for _, c := range jar.Cookies(u) {
+		switch c.Name {
+		case "SAPISID":
+			sapisid = c.Value
+		case "__Secure-3PAPISID":
+			if sapisid == "" {
+				sapisid = c.Value
+			}
+		}
+	}
+	if sapisid == "" {
+		return ""
+	}
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sum := sha1.Sum([]byte(ts + " " + sapisid + " " + origin))
+	return "SAPISIDHASH " + ts + "_" + hex.EncodeToString(sum[:])
+}
+
+// hasCookie reports whether jar holds a cookie named name for u.
+func hasCookie(jar http.CookieJar, u *url.URL, names ...string) bool {
+	if jar == nil || u == nil {
+		return false
+	}
+	for _, c := range jar.Cookies(u) {
+		for _, name := range names {
+			if c.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
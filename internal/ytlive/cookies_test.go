@@ -0,0 +1,109 @@
+package ytlive
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCookies = "# Netscape HTTP Cookie File\n" +
+	".youtube.com\tTRUE\t/\tTRUE\t0\tSAPISID\tsapisid-value\n" +
+	"#HttpOnly_.youtube.com\tTRUE\t/\tTRUE\t4102444800\tLOGIN_INFO\tlogin\n" +
+	".youtube.com\tTRUE\t/\tTRUE\t4102444800\tSOCS\tsigned-in\n" +
+	"www.youtube.com\tFALSE\t/\tFALSE\t4102444800\tPREF\thl=en\n" +
+	".youtube.com\tTRUE\t/\tTRUE\t946684800\tOLD\texpired\n"
+
+func writeCookies(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	if err := os.WriteFile(path, []byte(testCookies), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadCookieJar(t *testing.T) {
+	jar, err := LoadCookieJar(writeCookies(t))
+	if err != nil {
+		t.Fatalf("LoadCookieJar() error = %v", err)
+	}
+	names := map[string]string{}
+	u, _ := url.Parse("https://www.youtube.com/watch?v=abc")
+	for _, c := range jar.Cookies(u) {
+		names[c.Name] = c.Value
+	}
+	for _, want := range []string{"SAPISID", "LOGIN_INFO", "SOCS", "PREF"} {
+		if _, ok := names[want]; !ok {
+			t.Fatalf("missing cookie %s in %v", want, names)
+		}
+	}
+	if _, ok := names["OLD"]; ok {
+		t.Fatalf("expired cookie was loaded")
+	}
+	// PREF is host-only for www.youtube.com.
+	m, _ := url.Parse("https://m.youtube.com/")
+	for _, c := range jar.Cookies(m) {
+		if c.Name == "PREF" {
+			t.Fatalf("host-only cookie leaked to m.youtube.com")
+		}
+	}
+
+	if _, err := parseNetscapeCookies(strings.NewReader("youtube.com\tTRUE\t/\n")); err == nil {
+		t.Fatalf("expected an error for a short line")
+	}
+}
+
+func TestSAPISIDHash(t *testing.T) {
+	jar, err := LoadCookieJar(writeCookies(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := sapisidHash(jar, youtubeOrigin, time.Unix(1700000000, 0))
+	if got != "SAPISIDHASH 1700000000_ade2239a1ec948ec8b27fe884ba5c1cdc9388057" {
+		t.Fatalf("sapisidHash() = %q", got)
+	}
+	if sapisidHash(nil, youtubeOrigin, time.Now()) != "" {
+		t.Fatalf("expected no hash without a jar")
+	}
+}
+
+func TestPollSendsSignedInHeaders(t *testing.T) {
+	jar, err := LoadCookieJar(writeCookies(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		auth, origin string
+		socs         []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		origin = r.Header.Get("X-Origin")
+		for _, c := range r.Cookies() {
+			if c.Name == "SOCS" || c.Name == "CONSENT" {
+				socs = append(socs, c.Name+"="+c.Value)
+			}
+		}
+		w.Write([]byte(`{"continuationContents":{"liveChatContinuation":{"continuations":[{"timedContinuationData":{"continuation":"next","timeoutMs":10}}]}}}`))
+	}))
+	defer server.Close()
+
+	client := New(Config{LiveURL: "https://www.youtube.com/watch?v=abc", Jar: jar}, nil)
+	client.http.Transport = rewriteTransport(server.URL)
+	if _, _, _, _, err := client.poll(context.Background(), "key", "2.0", "cont"); err != nil {
+		t.Fatalf("poll() error = %v", err)
+	}
+	if !strings.HasPrefix(auth, "SAPISIDHASH ") || origin != youtubeOrigin {
+		t.Fatalf("missing signed-in headers: Authorization=%q X-Origin=%q", auth, origin)
+	}
+	// The account's own consent cookie replaces the anonymous defaults.
+	if len(socs) != 1 || socs[0] != "SOCS=signed-in" {
+		t.Fatalf("unexpected consent cookies %v", socs)
+	}
+}
@@ -0,0 +1,80 @@
+package ytlive
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// Poll results reported to Reporter. Failed polls are reported with their
+// core.ErrorKind ("network", "auth", "parse") or PollTimeout.
+const (
+	PollOK      = "ok"
+	PollTimeout = "timeout"
+)
+
+// Continuation reset reasons reported to Reporter.
+const (
+	// ResetMissing is a poll response that carried no continuation.
+	ResetMissing = "missing"
+	// ResetPollError is a failed poll that forced a full re-bootstrap.
+	ResetPollError = "poll_error"
+)
+
+// Reporter receives poll health so a chat that silently stops delivering
+// can be alerted on; *httpapi.Server satisfies it.
+type Reporter interface {
+	ReportYouTubePoll(result string)
+	ReportYouTubeBootstrap(ok bool)
+	ReportYouTubeContinuationReset(reason string)
+	// ReportYouTubeActions counts one poll's actions: chat messages stored,
+	// chat messages that could not be parsed, and non-chat actions.
+	ReportYouTubeActions(parsed, dropped, skipped int)
+	ReportYouTubePollInterval(d time.Duration)
+}
+
+func (c *Client) reportPoll(err error) {
+	if c.cfg.Reporter == nil {
+		return
+	}
+	c.cfg.Reporter.ReportYouTubePoll(pollResult(err))
+}
+
+// pollResult classifies a poll outcome for Reporter.
+func pollResult(err error) string {
+	if err == nil {
+		return PollOK
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return PollTimeout
+	}
+	return string(core.ErrorKindOf(err))
+}
+
+func (c *Client) reportBootstrap(err error) {
+	if c.cfg.Reporter != nil {
+		c.cfg.Reporter.ReportYouTubeBootstrap(err == nil)
+	}
+}
+
+func (c *Client) reportContinuationReset(reason string) {
+	if c.cfg.Reporter != nil {
+		c.cfg.Reporter.ReportYouTubeContinuationReset(reason)
+	}
+}
+
+func (c *Client) reportActions(summary pollSummary, failures []chatFailure) {
+	if c.cfg.Reporter != nil {
+		c.cfg.Reporter.ReportYouTubeActions(summary.stored, len(failures), summary.skipped)
+	}
+}
+
+func (c *Client) reportPollInterval(d time.Duration) {
+	if c.cfg.Reporter != nil {
+		c.cfg.Reporter.ReportYouTubePollInterval(d)
+	}
+}
@@ -0,0 +1,134 @@
+package ytlive
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/backoff"
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/testservers"
+)
+
+type recordingReporter struct {
+	mu         sync.Mutex
+	polls      map[string]int
+	bootstraps map[bool]int
+	resets     map[string]int
+	parsed     int
+	interval   time.Duration
+}
+
+func (r *recordingReporter) ReportYouTubePoll(result string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.polls[result]++
+}
+
+func (r *recordingReporter) ReportYouTubeBootstrap(ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.bootstraps[ok]++
+}
+
+func (r *recordingReporter) ReportYouTubeContinuationReset(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.resets[reason]++
+}
+
+func (r *recordingReporter) ReportYouTubeActions(parsed, dropped, skipped int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.parsed += parsed
+}
+
+func (r *recordingReporter) ReportYouTubePollInterval(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interval = d
+}
+
+func TestRunReportsPollHealth(t *testing.T) {
+	yt := testservers.NewYouTube("metricsvid1")
+	defer yt.Close()
+	yt.SetPollInterval(10 * time.Millisecond)
+	yt.FailPolls(http.StatusInternalServerError, 1)
+	yt.Push(testservers.YouTubeMessage{ID: "m1", Author: "Ann", Text: "hi"})
+
+	rep := &recordingReporter{polls: map[string]int{}, bootstraps: map[bool]int{}, resets: map[string]int{}}
+	got := make(chan core.ChatMessage, 1)
+	client := New(Config{
+		LiveURL:   yt.WatchURL(),
+		Backoff:   backoff.Policy{Initial: time.Millisecond, Max: time.Millisecond},
+		Transport: yt.Transport(),
+		Reporter:  rep,
+	}, func(msg core.ChatMessage) {
+		select {
+		case got <- msg:
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- client.Run(ctx) }()
+
+	select {
+	case <-got:
+	case <-ctx.Done():
+		t.Fatal("no message delivered")
+	}
+	// Let the poll that delivered the message finish reporting.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		rep.mu.Lock()
+		ready := rep.interval > 0 && rep.parsed > 0
+		rep.mu.Unlock()
+		if ready || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v", err)
+	}
+
+	rep.mu.Lock()
+	defer rep.mu.Unlock()
+	if rep.polls[string(core.ErrorNetwork)] != 1 || rep.polls[PollOK] < 1 {
+		t.Fatalf("polls = %v", rep.polls)
+	}
+	if rep.bootstraps[true] != 2 || rep.bootstraps[false] != 0 {
+		t.Fatalf("bootstraps = %v", rep.bootstraps)
+	}
+	if rep.resets[ResetPollError] != 1 {
+		t.Fatalf("resets = %v", rep.resets)
+	}
+	if rep.parsed != 1 {
+		t.Fatalf("parsed = %d, want 1", rep.parsed)
+	}
+	if rep.interval != 10*time.Millisecond {
+		t.Fatalf("interval = %s", rep.interval)
+	}
+}
+
+func TestPollResult(t *testing.T) {
+	cases := map[string]error{
+		PollOK:                    nil,
+		PollTimeout:               context.DeadlineExceeded,
+		string(core.ErrorAuth):    core.NewError(core.ErrorAuth, errors.New("forbidden")),
+		string(core.ErrorParse):   core.NewError(core.ErrorParse, errors.New("bad json")),
+		string(core.ErrorNetwork): core.NewError(core.ErrorNetwork, errors.New("reset")),
+	}
+	for want, err := range cases {
+		if got := pollResult(err); got != want {
+			t.Fatalf("pollResult(%v) = %q, want %q", err, got, want)
+		}
+	}
+}
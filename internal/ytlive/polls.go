@@ -0,0 +1,110 @@
+package ytlive
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+var pollVotesPattern = regexp.MustCompile(`([\d,.]+)\s*votes?`)
+
+// pollTracker remembers which action panel shows which poll so that the
+// panel closing can be reported as the poll ending. It is only used from the
+// poll loop.
+type pollTracker struct {
+	panels map[string]string // panel id -> poll id
+	last   map[string]core.Poll
+}
+
+func newPollTracker() *pollTracker {
+	return &pollTracker{panels: map[string]string{}, last: map[string]core.Poll{}}
+}
+
+// observe returns the poll states carried by showLiveChatActionPanelAction
+// and updateLiveChatPollAction, plus a final "ended" state for each tracked
+// poll whose panel closeLiveChatActionPanelAction removes.
+func (t *pollTracker) observe(payload map[string]any, now time.Time) []core.Poll {
+	var out []core.Poll
+	for _, action := range gatherActions(payload) {
+		if show, ok := action["showLiveChatActionPanelAction"].(map[string]any); ok {
+			panel := digMap(show, "panelToShow", "liveChatActionPanelRenderer")
+			renderer := digMap(panel, "contents", "pollRenderer")
+			if poll, ok := buildPoll(renderer, now); ok {
+				if id := stringField(panel, "id"); id != "" {
+					t.panels[id] = poll.ID
+				}
+				t.last[poll.ID] = poll
+				out = append(out, poll)
+			}
+			continue
+		}
+		if update, ok := action["updateLiveChatPollAction"].(map[string]any); ok {
+			if poll, ok := buildPoll(digMap(update, "pollToUpdate", "pollRenderer"), now); ok {
+				t.last[poll.ID] = poll
+				out = append(out, poll)
+			}
+			continue
+		}
+		if closePanel, ok := action["closeLiveChatActionPanelAction"].(map[string]any); ok {
+			panelID := stringField(closePanel, "targetPanelId")
+			pollID, ok := t.panels[panelID]
+			if !ok {
+				continue
+			}
+			delete(t.panels, panelID)
+			poll, ok := t.last[pollID]
+			delete(t.last, pollID)
+			if !ok {
+				continue
+			}
+			poll.Status = "ended"
+			poll.EndedAt = now
+			out = append(out, poll)
+		}
+	}
+	return out
+}
+
+// buildPoll converts a pollRenderer. YouTube reports each choice as a vote
+// ratio and the total in the header metadata ("... • 42 votes"), so option
+// counts are derived from the two.
+func buildPoll(renderer map[string]any, now time.Time) (core.Poll, bool) {
+	if renderer == nil {
+		return core.Poll{}, false
+	}
+	id := stringField(renderer, "liveChatPollId")
+	if id == "" {
+		return core.Poll{}, false
+	}
+	header := digMap(renderer, "header", "pollHeaderRenderer")
+	poll := core.Poll{
+		Platform:  "YouTube",
+		ID:        id,
+		Kind:      core.PollKindPoll,
+		Title:     textField(header, "pollQuestion"),
+		Status:    "active",
+		StartedAt: now,
+	}
+	total := 0
+	if match := pollVotesPattern.FindStringSubmatch(textField(header, "metadataText")); len(match) > 1 {
+		total, _ = strconv.Atoi(strings.NewReplacer(",", "", ".", "").Replace(match[1]))
+	}
+	choices, _ := renderer["choices"].([]any)
+	for _, raw := range choices {
+		choice, ok := raw.(map[string]any)
+		if !ok {
+			continue
+		}
+		opt := core.PollOption{Title: textField(choice, "text")}
+		if ratio, ok := choice["voteRatio"].(float64); ok {
+			opt.Percent = math.Round(ratio*1000) / 10
+			opt.Votes = int(math.Round(ratio * float64(total)))
+		}
+		poll.Options = append(poll.Options, opt)
+	}
+	return poll, true
+}
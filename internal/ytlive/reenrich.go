@@ -0,0 +1,34 @@
+package ytlive
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// Reenrich rebuilds the badge and emote payloads and the author ID of a
+// stored YouTube message from the chat renderer captured in its RawJSON. ok is false when the raw
+// payload is missing or unparseable.
+func Reenrich(msg core.ChatMessage) (core.ChatMessage, bool) {
+	if strings.TrimSpace(msg.RawJSON) == "" {
+		return msg, false
+	}
+	var renderer map[string]any
+	if err := json.Unmarshal([]byte(msg.RawJSON), &renderer); err != nil || renderer == nil {
+		return msg, false
+	}
+
+	msg.Badges, msg.BadgesRaw = parseYouTubeBadges(renderer)
+	if id := stringField(renderer, "authorExternalChannelId"); id != "" {
+		msg.UserID = id
+	}
+	msg.BadgesJSON = ""
+	msg.EmotesJSON = ""
+	if _, emotes := messageTextAndEmotes(renderer); len(emotes) > 0 {
+		if data, err := json.Marshal(emotes); err == nil {
+			msg.EmotesJSON = string(data)
+		}
+	}
+	return msg, true
+}
@@ -0,0 +1,266 @@
+package ytlive
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/backfill"
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// replayMaxPages bounds the get_live_chat_replay requests for one gap.
+const replayMaxPages = 500
+
+// Replay fetches chat from the live chat replay of a finished stream. It
+// satisfies backfill.Source; the gap's Channel is the stream's watch URL.
+type Replay struct {
+	http   *http.Client
+	agents userAgentRotator
+}
+
+// NewReplay creates a replay source backed by the provided HTTP client.
+// If client is nil a default client with a sane timeout is used.
+func NewReplay(client *http.Client) *Replay {
+	if client == nil {
+		client = &http.Client{Timeout: 20 * time.Second}
+	}
+	return &Replay{http: client}
+}
+
+// replayPage is what the watch page of a (possibly finished) stream says
+// about its chat replay.
+type replayPage struct {
+	apiKey        string
+	clientVersion string
+	continuation  string
+	live          bool
+	start         time.Time
+}
+
+// Fetch returns the replayed chat from gap.Start onwards, stopping at the
+// first page past gap.End. It returns backfill.ErrNotReady while the
+// stream is still live or its replay is not published yet.
+func (r *Replay) Fetch(ctx context.Context, gap backfill.Gap) ([]core.ChatMessage, error) {
+	page, err := r.watchPage(ctx, gap.Channel)
+	if err != nil {
+		return nil, err
+	}
+	if page.live || page.continuation == "" {
+		return nil, backfill.ErrNotReady
+	}
+	offset := gap.Start.Sub(page.start)
+	if page.start.IsZero() || offset < 0 {
+		offset = 0
+	}
+
+	var out []core.ChatMessage
+	continuation := page.continuation
+	for i := 0; i < replayMaxPages && continuation != ""; i++ {
+		msgs, next, err := r.fetchPage(ctx, page, continuation, offset)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, msgs...)
+		if len(msgs) > 0 && msgs[len(msgs)-1].Ts.After(gap.End) {
+			break
+		}
+		continuation = next
+	}
+	return out, nil
+}
+
+func (r *Replay) watchPage(ctx context.Context, watchURL string) (replayPage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, watchURL, nil)
+	if err != nil {
+		return replayPage{}, err
+	}
+	setBrowserHeaders(req, r.agents.current(), r.http.Jar)
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return replayPage{}, core.NewError(core.ErrorNetwork, err)
+	}
+	defer resp.Body.Close()
+	if isConsentPage(resp.Request.URL) {
+		return replayPage{}, core.NewError(core.ErrorAuth, errConsentRequired)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return replayPage{}, core.NewError(statusErrorKind(resp.StatusCode), fmt.Errorf("unexpected status %s", resp.Status))
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	if err != nil {
+		return replayPage{}, core.NewError(core.ErrorNetwork, err)
+	}
+	text := string(body)
+
+	page := replayPage{
+		apiKey:        extractString(text, `"INNERTUBE_API_KEY":"`),
+		clientVersion: extractString(text, `"INNERTUBE_CLIENT_VERSION":"`),
+	}
+	if page.apiKey == "" || page.clientVersion == "" {
+		return replayPage{}, core.NewError(core.ErrorParse, errors.New("ytlive: could not locate api key or client version"))
+	}
+	if raw, ok := extractJSONAssignment(text, "ytInitialPlayerResponse"); ok {
+		page.live, page.start = parseReplayPlayer(raw)
+	}
+	data, err := extractInitialData(text)
+	if err != nil {
+		return replayPage{}, err
+	}
+	page.continuation = replayInitialContinuation(data)
+	return page, nil
+}
+
+// parseReplayPlayer reports whether the player response is still live and
+// when the broadcast started.
+func parseReplayPlayer(raw string) (bool, time.Time) {
+	var payload struct {
+		VideoDetails struct {
+			IsLive bool `json:"isLive"`
+		} `json:"videoDetails"`
+		Microformat struct {
+			Renderer struct {
+				Broadcast struct {
+					IsLiveNow      bool   `json:"isLiveNow"`
+					StartTimestamp string `json:"startTimestamp"`
+				} `json:"liveBroadcastDetails"`
+			} `json:"playerMicroformatRenderer"`
+		} `json:"microformat"`
+	}
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		return false, time.Time{}
+	}
+	broadcast := payload.Microformat.Renderer.Broadcast
+	start, _ := time.Parse(time.RFC3339, broadcast.StartTimestamp)
+	return payload.VideoDetails.IsLive || broadcast.IsLiveNow, start
+}
+
+// replayInitialContinuation prefers the "Live chat replay" view, which
+// includes every message, over the default "Top chat replay".
+func replayInitialContinuation(data map[string]any) string {
+	items := findSliceRecursive(data, "subMenuItems")
+	for i := len(items) - 1; i >= 0; i-- {
+		if item := asMap(items[i]); item != nil {
+			if cont := digMap(item, "continuation", "reloadContinuationData"); cont != nil {
+				if s, ok := cont["continuation"].(string); ok && s != "" {
+					return s
+				}
+			}
+		}
+	}
+	return findInitialContinuation(data)
+}
+
+func findSliceRecursive(v any, key string) []any {
+	switch val := v.(type) {
+	case map[string]any:
+		if arr, ok := val[key].([]any); ok {
+			return arr
+		}
+		for _, child := range val {
+			if arr := findSliceRecursive(child, key); arr != nil {
+				return arr
+			}
+		}
+	case []any:
+		for _, child := range val {
+			if arr := findSliceRecursive(child, key); arr != nil {
+				return arr
+			}
+		}
+	}
+	return nil
+}
+
+// fetchPage requests one page of replayed chat, seeking to offset into the
+// broadcast, and returns its messages and the next continuation.
+func (r *Replay) fetchPage(ctx context.Context, page replayPage, continuation string, offset time.Duration) ([]core.ChatMessage, string, error) {
+	endpoint := fmt.Sprintf("https://www.youtube.com/youtubei/v1/live_chat/get_live_chat_replay?key=%s", url.QueryEscape(page.apiKey))
+	payload := map[string]any{
+		"context": map[string]any{
+			"client": map[string]any{
+				"clientName":    "WEB",
+				"clientVersion": page.clientVersion,
+				"hl":            "en",
+			},
+		},
+		"continuation": continuation,
+		"currentPlayerState": map[string]any{
+			"playerOffsetMs": strconv.FormatInt(offset.Milliseconds(), 10),
+		},
+	}
+	buf, err := json.Marshal(payload)
+	if err != nil {
+		return nil, "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(buf))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setBrowserHeaders(req, r.agents.current(), r.http.Jar)
+	if auth := sapisidHash(r.http.Jar, youtubeOrigin, time.Now()); auth != "" {
+		req.Header.Set("Authorization", auth)
+		req.Header.Set("X-Origin", youtubeOrigin)
+		req.Header.Set("X-Goog-AuthUser", "0")
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, "", core.NewError(core.ErrorNetwork, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		err := &pollStatusError{status: resp.StatusCode, text: resp.Status, body: strings.TrimSpace(string(body))}
+		return nil, "", core.NewError(statusErrorKind(resp.StatusCode), err)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, "", core.NewError(core.ErrorNetwork, err)
+	}
+	var payloadResp map[string]any
+	if err := json.Unmarshal(body, &payloadResp); err != nil {
+		return nil, "", core.NewError(core.ErrorParse, fmt.Errorf("ytlive: decode replay response: %w", err))
+	}
+	msgs, _, _, _ := extractMessages(map[string]any{"actions": unwrapReplayActions(payloadResp)})
+	return msgs, replayContinuation(payloadResp), nil
+}
+
+// unwrapReplayActions returns the live chat actions wrapped in a replay
+// response's replayChatItemActions.
+func unwrapReplayActions(payload map[string]any) []any {
+	var out []any
+	for _, action := range gatherActions(payload) {
+		if replay := digMap(action, "replayChatItemAction"); replay != nil {
+			if inner, ok := replay["actions"].([]any); ok {
+				out = append(out, inner...)
+			}
+			continue
+		}
+		out = append(out, action)
+	}
+	return out
+}
+
+// replayContinuation returns the token for the next replay page, or "" at
+// the end of the replay.
+func replayContinuation(payload map[string]any) string {
+	for _, elem := range digSlice(payload, "continuationContents", "liveChatContinuation", "continuations") {
+		if next := digMap(asMap(elem), "liveChatReplayContinuationData"); next != nil {
+			if s, ok := next["continuation"].(string); ok && s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
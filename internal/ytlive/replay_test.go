@@ -0,0 +1,171 @@
+package ytlive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/backfill"
+	"github.com/you/gnasty-chat/internal/backoff"
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/testservers"
+)
+
+func replayAction(id string, ts time.Time) map[string]any {
+	return map[string]any{"replayChatItemAction": map[string]any{"actions": []any{
+		map[string]any{"addChatItemAction": map[string]any{"item": map[string]any{
+			"liveChatTextMessageRenderer": map[string]any{
+				"id":                      id,
+				"timestampUsec":           fmt.Sprint(ts.UnixMicro()),
+				"authorName":              map[string]any{"simpleText": "Ann"},
+				"authorExternalChannelId": "UCann",
+				"message":                 map[string]any{"runs": []any{map[string]any{"text": "hi " + id}}},
+			},
+		}}},
+	}}}
+}
+
+func TestReplayFetch(t *testing.T) {
+	streamStart := time.Date(2026, 3, 1, 18, 0, 0, 0, time.UTC)
+	gap := backfill.Gap{
+		Platform: "YouTube",
+		Channel:  "https://www.youtube.com/watch?v=replay1",
+		Start:    streamStart.Add(10 * time.Minute),
+		End:      streamStart.Add(12 * time.Minute),
+	}
+
+	var (
+		mu       sync.Mutex
+		live     = true
+		requests []string
+		offsets  []string
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/watch", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		isLive := live
+		mu.Unlock()
+		player, _ := json.Marshal(map[string]any{
+			"videoDetails": map[string]any{"videoId": "replay1", "isLive": isLive},
+			"microformat": map[string]any{"playerMicroformatRenderer": map[string]any{
+				"liveBroadcastDetails": map[string]any{"isLiveNow": isLive, "startTimestamp": streamStart.Format(time.RFC3339)},
+			}},
+		})
+		initial, _ := json.Marshal(map[string]any{"contents": map[string]any{"liveChatRenderer": map[string]any{
+			"continuations": []any{map[string]any{"reloadContinuationData": map[string]any{"continuation": "top-cont"}}},
+			"header": map[string]any{"viewSelector": map[string]any{"subMenuItems": []any{
+				map[string]any{"title": "Top chat replay", "continuation": map[string]any{"reloadContinuationData": map[string]any{"continuation": "top-cont"}}},
+				map[string]any{"title": "Live chat replay", "continuation": map[string]any{"reloadContinuationData": map[string]any{"continuation": "all-cont"}}},
+			}}},
+		}}})
+		fmt.Fprintf(w, `<script>ytcfg.set({"INNERTUBE_API_KEY":"key","INNERTUBE_CLIENT_VERSION":"2.0"});</script>
+<script>var ytInitialPlayerResponse = %s;</script>
+<script>var ytInitialData = %s;</script>`, player, initial)
+	})
+	mux.HandleFunc("/youtubei/v1/live_chat/get_live_chat_replay", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Continuation string `json:"continuation"`
+			State        struct {
+				Offset string `json:"playerOffsetMs"`
+			} `json:"currentPlayerState"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		requests = append(requests, body.Continuation)
+		offsets = append(offsets, body.State.Offset)
+		mu.Unlock()
+		var actions []any
+		next := ""
+		switch body.Continuation {
+		case "all-cont":
+			actions = []any{replayAction("a", gap.Start.Add(-time.Second)), replayAction("b", gap.Start.Add(time.Minute))}
+			next = "page-2"
+		case "page-2":
+			actions = []any{replayAction("c", gap.End.Add(-time.Second)), replayAction("d", gap.End.Add(time.Second))}
+			next = "page-3"
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"continuationContents": map[string]any{"liveChatContinuation": map[string]any{
+			"continuations": []any{map[string]any{"liveChatReplayContinuationData": map[string]any{"continuation": next}}},
+			"actions":       actions,
+		}}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	replay := NewReplay(&http.Client{Transport: rewriteTransport(server.URL), Timeout: 2 * time.Second})
+	if _, err := replay.Fetch(context.Background(), gap); !errors.Is(err, backfill.ErrNotReady) {
+		t.Fatalf("Fetch while live = %v, want ErrNotReady", err)
+	}
+
+	mu.Lock()
+	live = false
+	mu.Unlock()
+	msgs, err := replay.Fetch(context.Background(), gap)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	var ids []string
+	for _, msg := range msgs {
+		ids = append(ids, msg.PlatformMsgID)
+	}
+	if fmt.Sprint(ids) != "[a b c d]" {
+		t.Fatalf("ids = %v", ids)
+	}
+	if !msgs[1].Ts.Equal(gap.Start.Add(time.Minute)) {
+		t.Fatalf("ts = %s", msgs[1].Ts)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(requests) != "[all-cont page-2]" {
+		t.Fatalf("requests = %v, want the live chat view and no page past the gap", requests)
+	}
+	if offsets[0] != "600000" {
+		t.Fatalf("playerOffsetMs = %s", offsets[0])
+	}
+}
+
+func TestRunReportsGapAfterLostContinuation(t *testing.T) {
+	yt := testservers.NewYouTube("gapvid1")
+	defer yt.Close()
+	yt.SetPollInterval(10 * time.Millisecond)
+	yt.Push(testservers.YouTubeMessage{ID: "m1", Author: "Ann", Text: "hi"})
+
+	got := make(chan core.ChatMessage, 4)
+	gaps := make(chan [2]time.Time, 1)
+	client := New(Config{
+		LiveURL:   yt.WatchURL(),
+		Backoff:   backoff.Policy{Initial: time.Millisecond, Max: time.Millisecond},
+		Transport: yt.Transport(),
+	}, func(msg core.ChatMessage) { got <- msg })
+	client.OnGap(func(start, end time.Time) { gaps <- [2]time.Time{start, end} })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- client.Run(ctx) }()
+
+	select {
+	case <-got:
+	case <-ctx.Done():
+		t.Fatal("no message delivered")
+	}
+	yt.FailPolls(http.StatusInternalServerError, 1)
+	select {
+	case gap := <-gaps:
+		if gap[0].IsZero() || !gap[1].After(gap[0]) {
+			t.Fatalf("gap = %s - %s", gap[0], gap[1])
+		}
+	case <-ctx.Done():
+		t.Fatal("no gap reported")
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("Run returned %v", err)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 	"unicode"
@@ -20,11 +21,24 @@ type ResolveResult struct {
 	Live     bool
 	WatchURL string
 	ChatURL  string
+	// Viewers is the concurrent viewer count shown on the watch page, or
+	// zero when it could not be found.
+	Viewers int
+	// Title, Category and ThumbnailURL describe the live video when the
+	// watch page carries them.
+	Title        string
+	Category     string
+	ThumbnailURL string
+	// Upcoming marks a scheduled stream or premiere that has not started;
+	// ScheduledStart is its announced start time, when published.
+	Upcoming       bool
+	ScheduledStart time.Time
 }
 
 // Resolver locates the active livestream for a configured YouTube URL or handle.
 type Resolver struct {
-	http *http.Client
+	http   *http.Client
+	agents userAgentRotator
 }
 
 // NewResolver creates a resolver backed by the provided HTTP client.
@@ -53,7 +67,7 @@ func (r *Resolver) Resolve(ctx context.Context, raw string) (ResolveResult, erro
 	if err != nil {
 		return ResolveResult{}, err
 	}
-	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; ytlive-resolver/1.0)")
+	setBrowserHeaders(req, r.agents.current(), r.http.Jar)
 
 	resp, err := r.http.Do(req)
 	if err != nil {
@@ -62,11 +76,17 @@ func (r *Resolver) Resolve(ctx context.Context, raw string) (ResolveResult, erro
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
+		if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests {
+			r.agents.rotate()
+		}
 		return ResolveResult{}, fmt.Errorf("ytlive: resolve status %s", resp.Status)
 	}
 
 	// Final request URL after redirects.
 	finalURL := resp.Request.URL
+	if isConsentPage(finalURL) {
+		return ResolveResult{}, errConsentRequired
+	}
 	watchURL := canonicalWatchURL(finalURL)
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
@@ -75,6 +95,11 @@ func (r *Resolver) Resolve(ctx context.Context, raw string) (ResolveResult, erro
 	}
 
 	rawBody := string(body)
+	if videoID, start, ok := extractUpcoming(rawBody); ok {
+		res := ResolveResult{WatchURL: canonicalWatchFromVideoID(videoID), Upcoming: true, ScheduledStart: start}
+		res.Title, res.Category, res.ThumbnailURL = extractVideoMetadata(rawBody)
+		return res, nil
+	}
 	if videoID, live, ok := extractInitialPlayerState(rawBody); ok {
 		if !live {
 			if watchURL == "" {
@@ -83,11 +108,14 @@ func (r *Resolver) Resolve(ctx context.Context, raw string) (ResolveResult, erro
 			return ResolveResult{Live: false, WatchURL: watchURL}, nil
 		}
 		watchURL = canonicalWatchFromVideoID(videoID)
-		return ResolveResult{
+		res := ResolveResult{
 			Live:     true,
 			WatchURL: watchURL,
 			ChatURL:  canonicalChatFromVideoID(videoID),
-		}, nil
+			Viewers:  extractConcurrentViewers(rawBody),
+		}
+		res.Title, res.Category, res.ThumbnailURL = extractVideoMetadata(rawBody)
+		return res, nil
 	}
 
 	text := decodePage(rawBody)
@@ -108,7 +136,110 @@ func (r *Resolver) Resolve(ctx context.Context, raw string) (ResolveResult, erro
 		return ResolveResult{Live: false, WatchURL: watchURL}, nil
 	}
 
-	return ResolveResult{Live: true, WatchURL: watchURL, ChatURL: chatURL}, nil
+	res := ResolveResult{Live: true, WatchURL: watchURL, ChatURL: chatURL, Viewers: extractConcurrentViewers(rawBody)}
+	res.Title, res.Category, res.ThumbnailURL = extractVideoMetadata(rawBody)
+	return res, nil
+}
+
+// extractVideoMetadata reads the title, category and largest thumbnail from
+// ytInitialPlayerResponse (videoDetails and microformat).
+func extractVideoMetadata(body string) (title, category, thumbnail string) {
+	raw, ok := extractJSONAssignment(body, "ytInitialPlayerResponse")
+	if !ok {
+		return "", "", ""
+	}
+	var payload struct {
+		VideoDetails struct {
+			Title     string `json:"title"`
+			Thumbnail struct {
+				Thumbnails []struct {
+					URL   string `json:"url"`
+					Width int    `json:"width"`
+				} `json:"thumbnails"`
+			} `json:"thumbnail"`
+		} `json:"videoDetails"`
+		Microformat struct {
+			Renderer struct {
+				Category string `json:"category"`
+			} `json:"playerMicroformatRenderer"`
+		} `json:"microformat"`
+	}
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		return "", "", ""
+	}
+	best := 0
+	for _, thumb := range payload.VideoDetails.Thumbnail.Thumbnails {
+		if thumb.URL != "" && (thumbnail == "" || thumb.Width > best) {
+			thumbnail, best = thumb.URL, thumb.Width
+		}
+	}
+	return strings.TrimSpace(payload.VideoDetails.Title), strings.TrimSpace(payload.Microformat.Renderer.Category), thumbnail
+}
+
+// extractUpcoming reports whether the page is a scheduled stream or premiere
+// that has not started, with its announced start time when published.
+func extractUpcoming(body string) (videoID string, start time.Time, ok bool) {
+	raw, found := extractJSONAssignment(body, "ytInitialPlayerResponse")
+	if !found {
+		return "", time.Time{}, false
+	}
+	var payload struct {
+		VideoDetails struct {
+			VideoID    string `json:"videoId"`
+			IsUpcoming bool   `json:"isUpcoming"`
+		} `json:"videoDetails"`
+		PlayabilityStatus struct {
+			LiveStreamability struct {
+				Renderer struct {
+					OfflineSlate struct {
+						Renderer struct {
+							ScheduledStartTime string `json:"scheduledStartTime"`
+						} `json:"liveStreamOfflineSlateRenderer"`
+					} `json:"offlineSlate"`
+				} `json:"liveStreamabilityRenderer"`
+			} `json:"liveStreamability"`
+		} `json:"playabilityStatus"`
+		Microformat struct {
+			Renderer struct {
+				LiveBroadcastDetails struct {
+					StartTimestamp string `json:"startTimestamp"`
+				} `json:"liveBroadcastDetails"`
+			} `json:"playerMicroformatRenderer"`
+		} `json:"microformat"`
+	}
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		return "", time.Time{}, false
+	}
+	videoID = strings.TrimSpace(payload.VideoDetails.VideoID)
+	if videoID == "" || !payload.VideoDetails.IsUpcoming {
+		return "", time.Time{}, false
+	}
+	if secs, err := strconv.ParseInt(payload.PlayabilityStatus.LiveStreamability.Renderer.OfflineSlate.Renderer.ScheduledStartTime, 10, 64); err == nil && secs > 0 {
+		start = time.Unix(secs, 0).UTC()
+	} else if ts, err := time.Parse(time.RFC3339, payload.Microformat.Renderer.LiveBroadcastDetails.StartTimestamp); err == nil {
+		start = ts.UTC()
+	}
+	return videoID, start, true
+}
+
+// extractConcurrentViewers reads the "N watching now" count from a live
+// watch page (videoViewCountRenderer.originalViewCount in ytInitialData).
+func extractConcurrentViewers(body string) int {
+	for _, marker := range []string{`"originalViewCount":"`, `"concurrentViewers":"`} {
+		if raw := extractString(body, marker); raw != "" {
+			if n, err := strconv.Atoi(raw); err == nil && n >= 0 {
+				return n
+			}
+		}
+	}
+	return 0
+}
+
+// ValidateURL reports whether raw has a shape the resolver can poll (watch,
+// youtu.be, handle, or other youtube.com URLs).
+func ValidateURL(raw string) error {
+	_, err := normalizeYouTubeURL(raw)
+	return err
 }
 
 // normalizeYouTubeURL coerces YouTube URLs and handle shorthand into canonical
@@ -121,6 +252,8 @@ func normalizeYouTubeURL(raw string) (*url.URL, error) {
 
 	if strings.HasPrefix(trimmed, "@") {
 		trimmed = "https://www.youtube.com/" + trimmed
+	} else if channelIDPattern.MatchString(trimmed) {
+		trimmed = "https://www.youtube.com/channel/" + trimmed + "/live"
 	}
 
 	if !strings.Contains(trimmed, "://") {
@@ -205,6 +338,25 @@ func canonicalWatchURL(u *url.URL) string {
 	return (&url.URL{Scheme: "https", Host: "www.youtube.com", Path: "/watch", RawQuery: values.Encode()}).String()
 }
 
+// VideoID returns the v= parameter of a watch URL, or "" when absent.
+func VideoID(watchURL string) string {
+	u, err := url.Parse(watchURL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(u.Query().Get("v"))
+}
+
+// SessionID returns the broadcast session ID of watchURL's video, the same
+// "youtube:<video id>" the SQLite sink gives the session it opens for it, or
+// "" when the URL names no video.
+func SessionID(watchURL string) string {
+	if id := VideoID(watchURL); id != "" {
+		return "youtube:" + id
+	}
+	return ""
+}
+
 func defaultChatURL(u *url.URL) string {
 	if u == nil {
 		return ""
@@ -19,6 +19,7 @@ func TestNormalizeYouTubeURL_HandleVariants(t *testing.T) {
 		{"bare handle", "@creator", "https://www.youtube.com/@creator/live"},
 		{"short host", "youtube.com/@creator/live", "https://www.youtube.com/@creator/live"},
 		{"www host", "https://www.youtube.com/@creator", "https://www.youtube.com/@creator/live"},
+		{"channel id", "UCabcdefghijklmnopqrstuv", "https://www.youtube.com/channel/UCabcdefghijklmnopqrstuv/live"},
 	}
 
 	for _, tc := range tests {
@@ -44,7 +45,7 @@ func TestResolver_HandleLive(t *testing.T) {
 			t.Fatalf("unexpected query: %s", r.URL.RawQuery)
 		}
 		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(`<!DOCTYPE html><html><head><script nonce="test">var ytInitialPlayerResponse = {"streamingData":{"hlsManifestUrl":"https://example.com/hls.m3u8"},"videoDetails":{"videoId":"abc123","isLiveContent":true}};</script></head><body></body></html>`))
+		w.Write([]byte(`<!DOCTYPE html><html><head><script nonce="test">var ytInitialPlayerResponse = {"streamingData":{"hlsManifestUrl":"https://example.com/hls.m3u8"},"videoDetails":{"videoId":"abc123","isLiveContent":true,"title":"Late night build","thumbnail":{"thumbnails":[{"url":"https://i.ytimg.com/vi/abc123/default_live.jpg","width":120},{"url":"https://i.ytimg.com/vi/abc123/hqdefault_live.jpg","width":480}]}},"microformat":{"playerMicroformatRenderer":{"category":"Science \u0026 Technology"}}};</script><script nonce="test">var ytInitialData = {"viewCount":{"videoViewCountRenderer":{"viewCount":{"runs":[{"text":"1,234"},{"text":" watching now"}]},"isLive":true,"originalViewCount":"1234"}}};</script></head><body></body></html>`))
 	})
 
 	server := httptest.NewServer(handler)
@@ -68,6 +69,12 @@ func TestResolver_HandleLive(t *testing.T) {
 	if res.ChatURL != "https://www.youtube.com/live_chat?v=abc123" {
 		t.Fatalf("Resolve() ChatURL = %q", res.ChatURL)
 	}
+	if res.Viewers != 1234 {
+		t.Fatalf("Resolve() Viewers = %d, want 1234", res.Viewers)
+	}
+	if res.Title != "Late night build" || res.Category != "Science & Technology" || res.ThumbnailURL != "https://i.ytimg.com/vi/abc123/hqdefault_live.jpg" {
+		t.Fatalf("Resolve() metadata = %q/%q/%q", res.Title, res.Category, res.ThumbnailURL)
+	}
 }
 
 func TestResolver_HandleOffline(t *testing.T) {
@@ -162,3 +169,24 @@ func cloneURL(u *url.URL) *url.URL {
 	copy := *u
 	return &copy
 }
+
+func TestResolver_Upcoming(t *testing.T) {
+	handler := http.NewServeMux()
+	handler.HandleFunc("/watch", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<script>var ytInitialPlayerResponse = {"playabilityStatus":{"status":"LIVE_STREAM_OFFLINE","liveStreamability":{"liveStreamabilityRenderer":{"offlineSlate":{"liveStreamOfflineSlateRenderer":{"scheduledStartTime":"1717243200"}}}}},"videoDetails":{"videoId":"soon1","isLiveContent":true,"isUpcoming":true,"title":"Premiere night"}};</script>`))
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	resolver := NewResolver(&http.Client{Transport: rewriteTransport(server.URL), Timeout: 2 * time.Second})
+	res, err := resolver.Resolve(context.Background(), "https://www.youtube.com/watch?v=soon1")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if res.Live || !res.Upcoming || res.Title != "Premiere night" || !res.ScheduledStart.Equal(time.Unix(1717243200, 0)) {
+		t.Fatalf("unexpected upcoming result %+v", res)
+	}
+	if res.WatchURL != "https://www.youtube.com/watch?v=soon1" {
+		t.Fatalf("Resolve() WatchURL = %q", res.WatchURL)
+	}
+}
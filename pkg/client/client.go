@@ -0,0 +1,272 @@
+// Package client is a typed Go client for the gnasty-chat HTTP API: message
+// queries and counts, plus SSE and WebSocket streams that reconnect and
+// resume from the last delivered event.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+)
+
+// Message is a chat message as returned by the API.
+type Message = core.ChatMessage
+
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// Options configures a Client. The zero value is usable.
+type Options struct {
+	// HTTPClient performs requests; http.DefaultClient when nil. Its
+	// Timeout must be zero for streams to stay open.
+	HTTPClient *http.Client
+	// MinBackoff and MaxBackoff bound the delay between stream reconnects
+	// (defaults 1s and 30s).
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// Client talks to one gnasty-chat HTTP API instance.
+type Client struct {
+	base *url.URL
+	http *http.Client
+	opts Options
+}
+
+// New returns a client for the API rooted at baseURL (e.g.
+// "http://localhost:8765").
+func New(baseURL string, opts Options) (*Client, error) {
+	u, err := url.Parse(strings.TrimSpace(baseURL))
+	if err != nil {
+		return nil, fmt.Errorf("client: parse base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: unsupported scheme %q", u.Scheme)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = http.DefaultClient
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = defaultMaxBackoff
+		if opts.MaxBackoff < opts.MinBackoff {
+			opts.MaxBackoff = opts.MinBackoff
+		}
+	}
+	return &Client{base: u, http: opts.HTTPClient, opts: opts}, nil
+}
+
+// Query selects messages. Zero fields are omitted and the server defaults
+// apply.
+type Query struct {
+	Platforms  []string
+	Usernames  []string
+	SessionIDs []string
+	// UserIDs and ChannelIDs match the stable platform IDs of the author
+	// and channel (Message.UserID and ChannelID).
+	UserIDs    []string
+	ChannelIDs []string
+	Since      time.Time
+	Until      time.Time
+	Limit      int
+	// Order is "asc" or "desc" (the server default).
+	Order string
+	// LastEventID resumes a stream after this event (see
+	// Message.EventID). Ignored by Query and Count.
+	LastEventID string
+	// Backlog asks a stream without a resume point for the latest Backlog
+	// matching messages before live delivery. Ignored by Query and Count.
+	Backlog int
+	// MaxRate (messages per second) and Sample (fraction of messages kept)
+	// thin a stream's live feed on the server. Ignored by Query and Count.
+	MaxRate float64
+	Sample  float64
+}
+
+func (q Query) values() url.Values {
+	v := url.Values{}
+	if len(q.Platforms) > 0 {
+		v.Set("platform", strings.Join(q.Platforms, ","))
+	}
+	for _, u := range q.Usernames {
+		v.Add("username", u)
+	}
+	if len(q.SessionIDs) > 0 {
+		v.Set("session_id", strings.Join(q.SessionIDs, ","))
+	}
+	if len(q.UserIDs) > 0 {
+		v.Set("user_id", strings.Join(q.UserIDs, ","))
+	}
+	if len(q.ChannelIDs) > 0 {
+		v.Set("channel_id", strings.Join(q.ChannelIDs, ","))
+	}
+	if !q.Since.IsZero() {
+		v.Set("since", q.Since.UTC().Format(time.RFC3339Nano))
+	}
+	if !q.Until.IsZero() {
+		v.Set("until", q.Until.UTC().Format(time.RFC3339Nano))
+	}
+	if q.Limit > 0 {
+		v.Set("limit", strconv.Itoa(q.Limit))
+	}
+	if q.Order != "" {
+		v.Set("order", q.Order)
+	}
+	return v
+}
+
+// APIError is a non-2xx response carrying the server's error envelope.
+type APIError struct {
+	Status    int
+	Code      string
+	Message   string
+	RequestID string
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Status)
+	}
+	if e.RequestID != "" {
+		return fmt.Sprintf("gnasty api: %d %s: %s (request_id=%s)", e.Status, e.Code, msg, e.RequestID)
+	}
+	return fmt.Sprintf("gnasty api: %d %s: %s", e.Status, e.Code, msg)
+}
+
+// temporary reports whether retrying the request may succeed.
+func (e *APIError) temporary() bool {
+	return e.Status == http.StatusTooManyRequests || e.Status >= 500
+}
+
+func readAPIError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	apiErr := &APIError{Status: resp.StatusCode}
+	var envelope struct {
+		Error struct {
+			Code      string `json:"code"`
+			Message   string `json:"message"`
+			RequestID string `json:"request_id"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &envelope); err == nil {
+		apiErr.Code = envelope.Error.Code
+		apiErr.Message = envelope.Error.Message
+		apiErr.RequestID = envelope.Error.RequestID
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+	return apiErr
+}
+
+func (c *Client) endpoint(path string, values url.Values) *url.URL {
+	u := *c.base
+	u.Path += path
+	u.RawQuery = values.Encode()
+	return &u
+}
+
+func (c *Client) getJSON(ctx context.Context, path string, values url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint(path, values).String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return readAPIError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decode %s: %w", path, err)
+	}
+	return nil
+}
+
+// Query returns stored messages matching q (GET /messages).
+func (c *Client) Query(ctx context.Context, q Query) ([]Message, error) {
+	var rows []Message
+	if err := c.getJSON(ctx, "/messages", q.values(), &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// Count returns the number of stored messages matching q (GET /count).
+func (c *Client) Count(ctx context.Context, q Query) (int64, error) {
+	var payload struct {
+		Count int64 `json:"count"`
+	}
+	if err := c.getJSON(ctx, "/count", q.values(), &payload); err != nil {
+		return 0, err
+	}
+	return payload.Count, nil
+}
+
+// Handler receives streamed messages. Returning an error stops the stream
+// and is returned from StreamSSE/StreamWS.
+type Handler func(Message) error
+
+type handlerError struct{ err error }
+
+func (h handlerError) Error() string { return h.err.Error() }
+
+// stream runs connect until ctx is done, the handler fails, or the server
+// rejects the request permanently. Each reconnect resumes after the last
+// delivered event.
+func (c *Client) stream(ctx context.Context, lastID string, fn Handler, connect func(ctx context.Context, lastID string, deliver Handler) error) error {
+	backoff := c.opts.MinBackoff
+	for {
+		delivered := false
+		err := connect(ctx, lastID, func(msg Message) error {
+			delivered = true
+			lastID = msg.EventID()
+			if err := fn(msg); err != nil {
+				return handlerError{err}
+			}
+			return nil
+		})
+		var hErr handlerError
+		if errors.As(err, &hErr) {
+			return hErr.err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && !apiErr.temporary() {
+			return err
+		}
+		if delivered {
+			backoff = c.opts.MinBackoff
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+		if backoff > c.opts.MaxBackoff {
+			backoff = c.opts.MaxBackoff
+		}
+	}
+}
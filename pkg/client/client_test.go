@@ -0,0 +1,198 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/you/gnasty-chat/internal/core"
+	"github.com/you/gnasty-chat/internal/httpapi"
+)
+
+// memoryStore honours the since/order/limit filters used by queries and
+// stream resume.
+type memoryStore struct {
+	messages []core.ChatMessage
+}
+
+func (s *memoryStore) list(filters httpapi.Filters) []core.ChatMessage {
+	var out []core.ChatMessage
+	for _, msg := range s.messages {
+		if filters.Matches(msg) {
+			out = append(out, msg)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if filters.Order == httpapi.OrderAsc {
+			return out[i].Ts.Before(out[j].Ts)
+		}
+		return out[i].Ts.After(out[j].Ts)
+	})
+	if filters.Limit > 0 && len(out) > filters.Limit {
+		out = out[:filters.Limit]
+	}
+	return out
+}
+
+func (s *memoryStore) CountMessages(_ context.Context, filters httpapi.Filters) (int64, error) {
+	filters.Limit = 0
+	return int64(len(s.list(filters))), nil
+}
+
+func (s *memoryStore) ListMessages(_ context.Context, filters httpapi.Filters) ([]core.ChatMessage, error) {
+	return s.list(filters), nil
+}
+
+func testMessages() []core.ChatMessage {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var out []core.ChatMessage
+	for i := 0; i < 4; i++ {
+		platform := "Twitch"
+		if i%2 == 1 {
+			platform = "YouTube"
+		}
+		out = append(out, core.ChatMessage{
+			ID:       fmt.Sprintf("m%d", i),
+			Platform: platform,
+			Username: "viewer",
+			Text:     fmt.Sprintf("hello %d", i),
+			Ts:       base.Add(time.Duration(i) * time.Second),
+		})
+	}
+	return out
+}
+
+func TestQueryCountAndErrors(t *testing.T) {
+	api := httpapi.New(&memoryStore{messages: testMessages()}, httpapi.Options{})
+	ts := httptest.NewServer(api.Mux())
+	defer ts.Close()
+
+	c, err := New(ts.URL+"/", Options{})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := context.Background()
+
+	rows, err := c.Query(ctx, Query{Platforms: []string{"twitch"}, Order: "asc"})
+	if err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if len(rows) != 2 || rows[0].ID != "m0" || rows[1].ID != "m2" {
+		t.Fatalf("unexpected rows: %+v", rows)
+	}
+
+	n, err := c.Count(ctx, Query{Since: testMessages()[1].Ts})
+	if err != nil || n != 3 {
+		t.Fatalf("count = %d, %v; want 3", n, err)
+	}
+
+	_, err = c.Query(ctx, Query{Order: "sideways"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest || apiErr.Code != httpapi.ErrCodeBadRequest || apiErr.RequestID == "" {
+		t.Fatalf("expected bad_request APIError, got %#v", err)
+	}
+
+	if _, err := New("ftp://example.com", Options{}); err == nil {
+		t.Fatalf("expected unsupported scheme error")
+	}
+}
+
+func TestStreamsResumeFromLastEventID(t *testing.T) {
+	msgs := testMessages()
+	api := httpapi.New(&memoryStore{messages: msgs}, httpapi.Options{})
+	ts := httptest.NewServer(api.Mux())
+	defer ts.Close()
+
+	c, err := New(ts.URL, Options{})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+
+	for name, stream := range map[string]func(context.Context, Query, Handler) error{
+		"sse": c.StreamSSE,
+		"ws":  c.StreamWS,
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		var got []string
+		errDone := errors.New("done")
+		err := stream(ctx, Query{LastEventID: msgs[1].EventID()}, func(msg Message) error {
+			got = append(got, msg.ID)
+			if len(got) == 2 {
+				return errDone
+			}
+			return nil
+		})
+		cancel()
+		if !errors.Is(err, errDone) {
+			t.Fatalf("%s: expected handler error, got %v", name, err)
+		}
+		if got[0] != "m2" || got[1] != "m3" {
+			t.Fatalf("%s: expected replay of m2,m3, got %v", name, got)
+		}
+	}
+}
+
+func TestStreamSSEReconnects(t *testing.T) {
+	msgs := testMessages()
+	var lastIDs []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastIDs = append(lastIDs, r.Header.Get("Last-Event-ID"))
+		w.Header().Set("Content-Type", "text/event-stream")
+		msg := msgs[len(lastIDs)-1]
+		fmt.Fprintf(w, ":ok\n\nid: %s\nevent: message\ndata: {\"ID\":%q,\"Text\":\"x\\ny\",\"Ts\":%q}\n\n",
+			msg.EventID(), msg.ID, msg.Ts.Format(time.RFC3339Nano))
+		fmt.Fprintf(w, "event: shutdown\ndata: {\"reason\":\"server restarting\"}\n\n")
+	}))
+	defer ts.Close()
+
+	c, err := New(ts.URL, Options{MinBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var got []Message
+	errDone := errors.New("done")
+	err = c.StreamSSE(ctx, Query{}, func(msg Message) error {
+		got = append(got, msg)
+		if len(got) == 2 {
+			return errDone
+		}
+		return nil
+	})
+	if !errors.Is(err, errDone) {
+		t.Fatalf("expected handler error, got %v", err)
+	}
+	if got[0].ID != "m0" || got[1].ID != "m1" || got[0].Text != "x\ny" {
+		t.Fatalf("unexpected messages: %+v", got)
+	}
+	if len(lastIDs) != 2 || lastIDs[0] != "" || lastIDs[1] != msgs[0].EventID() {
+		t.Fatalf("expected resume from first event, got %q", lastIDs)
+	}
+}
+
+func TestStreamStopsOnPermanentError(t *testing.T) {
+	api := httpapi.New(&memoryStore{}, httpapi.Options{})
+	ts := httptest.NewServer(api.Mux())
+	defer ts.Close()
+
+	c, err := New(ts.URL, Options{MinBackoff: time.Millisecond})
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, stream := range []func(context.Context, Query, Handler) error{c.StreamSSE, c.StreamWS} {
+		err := stream(ctx, Query{Platforms: []string{"myspace"}}, func(Message) error { return nil })
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+			t.Fatalf("expected 400 APIError, got %v", err)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"nhooyr.io/websocket"
+	"nhooyr.io/websocket/wsjson"
+)
+
+const maxEventSize = 1 << 20
+
+// streamValues returns the query parameters for /stream and /ws.
+func (q Query) streamValues() url.Values {
+	values := q.values()
+	values.Del("limit")
+	if q.Backlog > 0 {
+		values.Set("backlog", strconv.Itoa(q.Backlog))
+	}
+	if q.MaxRate > 0 {
+		values.Set("max_rate", strconv.FormatFloat(q.MaxRate, 'f', -1, 64))
+	}
+	if q.Sample > 0 {
+		values.Set("sample", strconv.FormatFloat(q.Sample, 'f', -1, 64))
+	}
+	return values
+}
+
+// StreamSSE follows GET /stream, calling fn for every message until ctx is
+// done or fn returns an error. Dropped connections are retried with backoff
+// and resumed via Last-Event-ID, so messages stored while disconnected are
+// replayed.
+func (c *Client) StreamSSE(ctx context.Context, q Query, fn Handler) error {
+	values := q.streamValues()
+	return c.stream(ctx, q.LastEventID, fn, func(ctx context.Context, lastID string, deliver Handler) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.endpoint("/stream", values).String(), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", "text/event-stream")
+		if lastID != "" {
+			req.Header.Set("Last-Event-ID", lastID)
+		}
+		resp, err := c.http.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return readAPIError(resp)
+		}
+		return readEvents(resp, deliver)
+	})
+}
+
+// readEvents parses the text/event-stream body. It returns nil when the
+// server ends the stream (including its "shutdown" event).
+func readEvents(resp *http.Response, deliver Handler) error {
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxEventSize)
+	var (
+		event string
+		data  strings.Builder
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if data.Len() > 0 && (event == "" || event == "message") {
+				var msg Message
+				if err := json.Unmarshal([]byte(data.String()), &msg); err != nil {
+					return fmt.Errorf("client: decode event: %w", err)
+				}
+				if err := deliver(msg); err != nil {
+					return err
+				}
+			}
+			event = ""
+			data.Reset()
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		}
+	}
+	return scanner.Err()
+}
+
+// StreamWS follows GET /ws with the same reconnect and resume behaviour as
+// StreamSSE; the resume point is sent as the last_event_id parameter.
+func (c *Client) StreamWS(ctx context.Context, q Query, fn Handler) error {
+	return c.stream(ctx, q.LastEventID, fn, func(ctx context.Context, lastID string, deliver Handler) error {
+		values := q.streamValues()
+		if lastID != "" {
+			values.Set("last_event_id", lastID)
+		}
+		u := c.endpoint("/ws", values)
+		if u.Scheme == "https" {
+			u.Scheme = "wss"
+		} else {
+			u.Scheme = "ws"
+		}
+		conn, resp, err := websocket.Dial(ctx, u.String(), &websocket.DialOptions{HTTPClient: c.http})
+		if err != nil {
+			if resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+				return readAPIError(resp)
+			}
+			return err
+		}
+		defer conn.CloseNow()
+		conn.SetReadLimit(maxEventSize)
+		for {
+			var msg Message
+			if err := wsjson.Read(ctx, conn, &msg); err != nil {
+				var closeErr websocket.CloseError
+				if errors.As(err, &closeErr) {
+					return nil
+				}
+				return err
+			}
+			if err := deliver(msg); err != nil {
+				_ = conn.Close(websocket.StatusNormalClosure, "")
+				return err
+			}
+		}
+	})
+}